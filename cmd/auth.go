@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var authAnonymize bool
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage auth users",
+	Long:  `Manage the auth users stored by GoTrue in the auth schema.`,
+}
+
+var authExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export auth users to a JSON file",
+	Long: `Export every auth user and identity to a JSON file, including hashed
+passwords and metadata, so they can be imported into another instance.
+
+With --anonymize, emails are replaced with <id>@example.invalid and phones,
+user metadata, password hashes, and pending tokens are removed. Ids, roles,
+and app metadata are kept, which makes the file suitable for seeding a
+staging environment.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAuthExport,
+}
+
+var authImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import auth users from a JSON file",
+	Long: `Import auth users and identities written by 'supalite auth export'.
+
+Users whose id, email, or phone already exist are skipped, so importing the
+same file twice is safe. The import runs in a single transaction.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAuthImport,
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authExportCmd)
+	authCmd.AddCommand(authImportCmd)
+	authExportCmd.Flags().BoolVar(&authAnonymize, "anonymize", false, "Replace emails and remove personal data and password hashes")
+}
+
+// runAuthExport writes auth users and identities to a file
+func runAuthExport(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	export, err := auth.ExportUsers(context.Background(), conn)
+	if err != nil {
+		return err
+	}
+	if authAnonymize {
+		export.Anonymize()
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	// The file holds password hashes, so keep it private
+	if err := os.WriteFile(args[0], data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", args[0], err)
+	}
+
+	fmt.Printf("✓ Exported %d users and %d identities to %s\n", len(export.Users), len(export.Identities), args[0])
+	return nil
+}
+
+// runAuthImport reads auth users and identities from a file
+func runAuthImport(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	var export auth.UserExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result, err := auth.ImportUsers(context.Background(), conn, &export)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Imported %d users and %d identities\n", result.Users, result.Identities)
+	if result.SkippedUsers > 0 || result.SkippedIdentities > 0 {
+		fmt.Printf("  Skipped %d existing users and %d identities\n", result.SkippedUsers, result.SkippedIdentities)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/markb/supalite/internal/chaos"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagChaosURL           string
+	flagChaosDBFailPercent float64
+	flagChaosAuthDelay     time.Duration
+)
+
+var chaosCmd = &cobra.Command{
+	Use:   "chaos",
+	Short: "Inject failures into a running server",
+	Long: `Inject failures into a server started with --chaos, to test how clients
+and supalite itself cope with them: database connections that fail, a slow
+auth API, and a GoTrue process that crashes.
+
+Failures are drawn from the seed set with --chaos-seed, so the same seed and
+the same requests fail the same way every run. The commands call the
+server's /admin/v1/chaos API with the service_role key.`,
+}
+
+var chaosStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the active faults and how often they fired",
+	Args:  cobra.NoArgs,
+	RunE:  runChaosStatus,
+}
+
+var chaosSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the injected faults",
+	Long: `Change the injected faults. Faults not given keep their current value.
+
+  supalite chaos set --db-fail-percent 25   # a quarter of REST requests get 503
+  supalite chaos set --auth-delay 2s        # every /auth/v1 request takes 2s longer`,
+	Args: cobra.NoArgs,
+	RunE: runChaosSet,
+}
+
+var chaosResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Clear the faults and counters and reseed",
+	Args:  cobra.NoArgs,
+	RunE:  runChaosReset,
+}
+
+var chaosKillGoTrueCmd = &cobra.Command{
+	Use:   "kill-gotrue",
+	Short: "Kill the GoTrue process, as a crash would",
+	Long: `Kill the GoTrue process with SIGKILL. supalite restarts it after a short
+delay; /health reports the auth API unavailable until it is back.`,
+	Args: cobra.NoArgs,
+	RunE: runChaosKillGoTrue,
+}
+
+func init() {
+	rootCmd.AddCommand(chaosCmd)
+	chaosCmd.AddCommand(chaosStatusCmd)
+	chaosCmd.AddCommand(chaosSetCmd)
+	chaosCmd.AddCommand(chaosResetCmd)
+	chaosCmd.AddCommand(chaosKillGoTrueCmd)
+
+	chaosCmd.PersistentFlags().StringVar(&flagChaosURL, "url", "", "URL of the running server (default: http://localhost:<port>)")
+	chaosSetCmd.Flags().Float64Var(&flagChaosDBFailPercent, "db-fail-percent", 0, "Share of database connections that fail, 0-100")
+	chaosSetCmd.Flags().DurationVar(&flagChaosAuthDelay, "auth-delay", 0, "Delay added to each auth API request")
+}
+
+// runChaosStatus prints the active faults
+func runChaosStatus(cmd *cobra.Command, args []string) error {
+	status, err := chaosRequest(http.MethodGet, "/admin/v1/chaos", nil)
+	if err != nil {
+		return err
+	}
+	printChaosStatus(status)
+	return nil
+}
+
+// runChaosSet changes the faults given on the command line
+func runChaosSet(cmd *cobra.Command, args []string) error {
+	status, err := chaosRequest(http.MethodGet, "/admin/v1/chaos", nil)
+	if err != nil {
+		return err
+	}
+
+	faults := status.Faults
+	if cmd.Flags().Changed("db-fail-percent") {
+		faults.DBFailPercent = flagChaosDBFailPercent
+	}
+	if cmd.Flags().Changed("auth-delay") {
+		faults.AuthDelayMS = int(flagChaosAuthDelay.Milliseconds())
+	}
+	if err := faults.Validate(); err != nil {
+		return err
+	}
+
+	if status, err = chaosRequest(http.MethodPut, "/admin/v1/chaos", faults); err != nil {
+		return err
+	}
+	fmt.Println("✓ Faults updated")
+	printChaosStatus(status)
+	return nil
+}
+
+// runChaosReset clears the faults
+func runChaosReset(cmd *cobra.Command, args []string) error {
+	if _, err := chaosRequest(http.MethodDelete, "/admin/v1/chaos", nil); err != nil {
+		return err
+	}
+	fmt.Println("✓ Faults cleared")
+	return nil
+}
+
+// runChaosKillGoTrue kills GoTrue
+func runChaosKillGoTrue(cmd *cobra.Command, args []string) error {
+	if _, err := chaosRequest(http.MethodPost, "/admin/v1/chaos/kill-gotrue", nil); err != nil {
+		return err
+	}
+	fmt.Println("✓ Killed GoTrue, it will be restarted")
+	return nil
+}
+
+// printChaosStatus prints faults and counters
+func printChaosStatus(status *chaosStatusResponse) {
+	fmt.Printf("Database failures: %g%% of connections (%d of %d failed)\n",
+		status.Faults.DBFailPercent, status.Stats.DBFailures, status.Stats.DBConnections)
+	fmt.Printf("Auth delay:        %s (%d requests delayed)\n",
+		time.Duration(status.Faults.AuthDelayMS)*time.Millisecond, status.Stats.AuthDelays)
+	fmt.Printf("GoTrue kills:      %d\n", status.Stats.GoTrueKills)
+}
+
+// chaosStatusResponse mirrors the server's /admin/v1/chaos response
+type chaosStatusResponse struct {
+	Faults chaos.Faults `json:"faults"`
+	Stats  chaos.Stats  `json:"stats"`
+}
+
+// chaosRequest calls the chaos API of the running server with the
+// service_role key
+func chaosRequest(method, path string, body interface{}) (*chaosStatusResponse, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	serviceKey, err := localServiceKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := flagChaosURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+serviceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the server at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("chaos is not enabled on the server at %s - start it with --chaos", baseURL)
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	}
+
+	var status chaosStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid response from server: %w", err)
+	}
+	return &status, nil
+}
+
+// localServiceKey returns the service_role key of the server configured
+// in this directory: the configured key, or the one the key manager
+// derives or keeps in keys.json
+func localServiceKey(cfg *config.Config) (string, error) {
+	if cfg.ServiceRoleKey != "" {
+		return cfg.ServiceRoleKey, nil
+	}
+	manager, err := localKeyManager(cfg)
+	if err != nil {
+		return "", err
+	}
+	return manager.GetServiceKey(), nil
+}
+
+// localAnonKey returns the anon key of the server configured in this
+// directory, found the way localServiceKey finds the service_role key
+func localAnonKey(cfg *config.Config) (string, error) {
+	if cfg.AnonKey != "" {
+		return cfg.AnonKey, nil
+	}
+	manager, err := localKeyManager(cfg)
+	if err != nil {
+		return "", err
+	}
+	return manager.GetAnonKey(), nil
+}
+
+// localKeyManager loads the keys of the server configured in this directory
+func localKeyManager(cfg *config.Config) (*keys.Manager, error) {
+	var manager *keys.Manager
+	var err error
+	if cfg.Deterministic {
+		seed := cfg.DeterministicSeed
+		if seed == "" {
+			seed = server.DefaultDeterministicSeed
+		}
+		manager, err = keys.NewDeterministicManager(seed, cfg.ProjectRef, cfg.JWTSecret)
+	} else {
+		manager, err = keys.NewManager(cfg.DataDir, cfg.JWTSecret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+	return manager, nil
+}
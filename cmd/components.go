@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/components"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/pg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagComponentsPGVersion string
+	flagComponentsFrom      string
+)
+
+// componentNames are the components install can download
+var componentNames = []string{"gotrue", "postgresql-client"}
+
+var componentsCmd = &cobra.Command{
+	Use:   "components",
+	Short: "Manage downloaded components",
+	Long:  `Manage the component binaries Supalite downloads on first use.`,
+}
+
+var componentsInstallCmd = &cobra.Command{
+	Use:   "install [gotrue|postgresql-client]...",
+	Short: "Download components ahead of time",
+	Long: `Download components into <data-dir>/bin/, verified against the SHA-256
+checksums published with the release: the GoTrue binary, and the PostgreSQL
+client programs (psql, pg_dump, pg_dumpall, pg_restore) that db shell, db
+dump --format, db restore, and db upgrade run. Without arguments, both.
+
+The client programs match the data directory's PostgreSQL major version, or
+--pg-version. 'supalite serve' downloads GoTrue on first run, and the db
+commands download the client programs when they find none, anyway. Run
+this on a machine with network access to prepare a data directory for an
+offline machine, or before using --deterministic, which never downloads.
+Components already cached and intact are kept.
+
+For an offline machine, download checksums.txt and the archive for its
+platform, e.g. postgresql-client-16-linux-amd64.tar.gz, from the release,
+copy them over, and install from their directory:
+
+  supalite components install postgresql-client --from ./downloads`,
+	RunE: runComponentsInstall,
+}
+
+var componentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the components in the data directory",
+	Args:  cobra.NoArgs,
+	RunE:  runComponentsList,
+}
+
+func init() {
+	rootCmd.AddCommand(componentsCmd)
+	componentsCmd.AddCommand(componentsInstallCmd)
+	componentsCmd.AddCommand(componentsListCmd)
+	componentsInstallCmd.Flags().StringVar(&flagComponentsPGVersion, "pg-version", "", "PostgreSQL major version of the client programs (default: the data directory's)")
+	componentsInstallCmd.Flags().StringVar(&flagComponentsFrom, "from", "", "Install the client programs from this directory of release downloads instead of the network")
+}
+
+// runComponentsInstall downloads components into the data directory's cache
+func runComponentsInstall(cmd *cobra.Command, args []string) error {
+	for _, name := range args {
+		if !slices.Contains(componentNames, name) {
+			return fmt.Errorf("unknown component %q (use %s)", name, strings.Join(componentNames, ", "))
+		}
+	}
+	if len(args) == 0 {
+		args = componentNames
+	}
+	if flagComponentsFrom != "" && slices.Contains(args, "gotrue") {
+		return fmt.Errorf("--from installs postgresql-client only; to install GoTrue offline, copy <data-dir>/bin/%s from another machine", auth.GoTrueVersion)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	for _, name := range args {
+		switch name {
+		case "gotrue":
+			path, err := auth.InstallGoTrue(auth.BinDir(cfg.DataDir))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✓ GoTrue %s: %s\n", auth.GoTrueVersion, path)
+		case "postgresql-client":
+			major, err := clientMajorVersion(cfg)
+			if err != nil {
+				return err
+			}
+			var bin string
+			if flagComponentsFrom != "" {
+				bin, err = components.InstallClientFrom(cfg.DataDir, major, flagComponentsFrom)
+			} else {
+				bin, err = components.InstallClient(cfg.DataDir, major)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✓ PostgreSQL %d client programs: %s\n", major, bin)
+		}
+	}
+	return nil
+}
+
+// clientMajorVersion returns the PostgreSQL major version to install client
+// programs for: --pg-version, the data directory's cluster's, or the
+// configured one, in that order
+func clientMajorVersion(cfg *config.Config) (int, error) {
+	version := flagComponentsPGVersion
+	if version == "" {
+		clusterVersion, err := pg.ClusterVersion(cfg.DataDir)
+		if err != nil {
+			return 0, err
+		}
+		version = clusterVersion
+	}
+	if version == "" {
+		version = cfg.PGVersion
+	}
+	if version == "" {
+		version = pg.DefaultVersion
+	}
+	major, err := strconv.Atoi(pg.MajorVersion(version))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PostgreSQL version %q", version)
+	}
+	return major, nil
+}
+
+// runComponentsList shows what is cached in the data directory
+func runComponentsList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if path, err := auth.CachedGoTrue(auth.BinDir(cfg.DataDir)); err == nil {
+		fmt.Printf("GoTrue %s: %s\n", auth.GoTrueVersion, path)
+	} else {
+		fmt.Printf("GoTrue %s: not installed\n", auth.GoTrueVersion)
+	}
+
+	clients, err := components.InstalledClients(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		fmt.Println("PostgreSQL client programs: not installed")
+	}
+	for _, c := range clients {
+		if c.Installed {
+			fmt.Printf("PostgreSQL %d client programs: %s\n", c.Major, c.Dir)
+		} else {
+			fmt.Printf("PostgreSQL %d client programs: incomplete install in %s; run supalite components install again\n", c.Major, c.Dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/backup"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/dump"
+	"github.com/markb/supalite/internal/pg"
+	"github.com/markb/supalite/internal/seed"
+	"github.com/markb/supalite/internal/upgrade"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbDumpOut       string
+	dbDumpAnonymize string
+	dbDumpSchemas   []string
+	dbDumpExclude   []string
+	dbDumpFormat    string
+
+	dbSeedFiles []string
+
+	dbUpgradeTo string
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Work with the database",
+	Long:  `Export and manage the data in the embedded PostgreSQL database.`,
+}
+
+var dbDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Export table data as SQL",
+	Long: `Export the rows of the public, auth, and storage schemas as INSERT
+statements, to load into a database that already has the schema.
+
+With --anonymize, columns are masked as the rules file says, so
+production-shaped data can be shared with developers:
+
+  seed: some-secret          # optional: same seed, same fake values
+  exclude: [auth.sessions, auth.refresh_tokens]
+  tables:
+    auth.users:
+      email: email
+      phone: phone
+      encrypted_password: null
+      raw_user_meta_data: {set: "{}"}
+    profiles:
+      full_name: name
+      api_token: token
+
+Strategies: keep, null, email, name, phone, token, hash, redact, and
+{set: value}. A value always maps to the same fake within a dump, so unique
+constraints and references between tables hold. A rule naming a column that
+doesn't exist fails the dump, so a typo can't leak data.
+
+A running server keeps serving; the dump reads one consistent snapshot.
+
+With --format plain or custom, the dump is a full backup made with pg_dump
+instead: schema and data, restorable with supalite db restore. pg_dump
+comes from the data directory's client programs, the embedded PostgreSQL
+distribution, or PATH, and is downloaded when none of those has it.
+
+  supalite db dump --format plain --out backup.sql
+  supalite db dump --format custom --out backup.dump   # compressed`,
+	Args: cobra.NoArgs,
+	RunE: runDBDump,
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore a backup made with db dump --format plain or custom",
+	Long: `Load a backup made with supalite db dump --format plain or custom, or
+with pg_dump, into the database. The format is detected from the file.
+Tables, functions, and other objects in the backup replace those of the
+same name; objects the backup doesn't have are left alone.
+
+The backup is loaded in one transaction, so a failure leaves the database
+as it was. A running server keeps serving, and sees the restored data
+once the restore commits. pg_restore and psql come from the data
+directory's client programs, the embedded PostgreSQL distribution, or
+PATH, and are downloaded when none of those has them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDBRestore,
+}
+
+var dbShellCmd = &cobra.Command{
+	Use:   "shell [-- psql arguments]",
+	Short: "Open psql on the database",
+	Long: `Open an interactive psql session on the database as the superuser.
+Arguments after -- are passed to psql:
+
+  supalite db shell
+  supalite db shell -- -c 'SELECT count(*) FROM auth.users'
+
+With the server stopped, a temporary database is started on the data
+directory for the session. psql comes from the data directory's client
+programs, the embedded PostgreSQL distribution, or PATH, and is downloaded
+into the data directory when none of those has one as new as the server
+(see supalite components install).`,
+	RunE: runDBShell,
+}
+
+var dbSeedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Load seed data from SQL files",
+	Long: `Run the project's seed files against the database, to load demo or test
+data: seed.sql, then the .sql files in seeds/ in name order. A Supabase CLI
+project's supabase/seed.sql is used when neither exists.
+
+All files run in one transaction, so a failing statement leaves the
+database as it was, and the error names the file and line it is on. Seed
+files that should be run more than once can use ON CONFLICT DO NOTHING
+or TRUNCATE their tables first.
+
+supalite init runs the seed files too, after creating the schema.`,
+	Args: cobra.NoArgs,
+	RunE: runDBSeed,
+}
+
+var dbUpgradeCmd = &cobra.Command{
+	Use:   "upgrade --to <version>",
+	Short: "Move the database to a newer PostgreSQL major version",
+	Long: `Upgrade the embedded PostgreSQL cluster to a newer major version, e.g.
+from 16 to 17. A data directory only opens with the major version that
+created it, so this backs up every database and the roles with the old
+version, creates a new cluster with the new one, and restores into it.
+pg_version in supalite.json is then set, so serve runs the new version.
+
+  supalite db upgrade --to 17        # the default 17.x release
+  supalite db upgrade --to 17.5.0
+
+Stop the server first. Nothing is deleted: the old cluster is moved to
+<data-dir>/data-pg<old major>, and the backup is kept in
+<data-dir>/backups/upgrade-<time>/. If the restore fails, the old cluster
+is put back. Once the application works on the new version, remove both
+to free the space. Both PostgreSQL versions are downloaded if they aren't
+cached.`,
+	Args: cobra.NoArgs,
+	RunE: runDBUpgrade,
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbDumpCmd)
+	dbCmd.AddCommand(dbSeedCmd)
+	dbCmd.AddCommand(dbShellCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbUpgradeCmd)
+	dbDumpCmd.Flags().StringVarP(&dbDumpOut, "out", "o", "", "File to write (default: standard output)")
+	dbDumpCmd.Flags().StringVar(&dbDumpAnonymize, "anonymize", "", "YAML file of column masking rules")
+	dbDumpCmd.Flags().StringSliceVar(&dbDumpSchemas, "schema", nil, "Schemas to dump (default: public, auth, storage)")
+	dbDumpCmd.Flags().StringSliceVar(&dbDumpExclude, "exclude", nil, "Tables to leave out, as schema.table")
+	dbDumpCmd.Flags().StringVar(&dbDumpFormat, "format", "inserts", "inserts (data only), or plain or custom for a full backup with pg_dump")
+	dbSeedCmd.Flags().StringSliceVarP(&dbSeedFiles, "file", "f", nil, "Seed files to run instead of seed.sql and seeds/*.sql")
+	dbUpgradeCmd.Flags().StringVar(&dbUpgradeTo, "to", "", "PostgreSQL version to upgrade to: a major version (e.g. 17) or a full one (e.g. 17.5.0)")
+	dbUpgradeCmd.MarkFlagRequired("to")
+}
+
+// runDBDump writes the database's rows as SQL, or a full backup
+func runDBDump(cmd *cobra.Command, args []string) error {
+	if dbDumpFormat != "inserts" {
+		return runDBBackup()
+	}
+
+	opts := dump.Options{Schemas: dbDumpSchemas, Exclude: dbDumpExclude}
+	if dbDumpAnonymize != "" {
+		rules, err := dump.LoadRules(dbDumpAnonymize)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", dbDumpAnonymize, err)
+		}
+		opts.Rules = rules
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var w io.Writer = os.Stdout
+	if dbDumpOut != "" {
+		// The dump may hold personal data and password hashes, so keep it private
+		f, err := os.OpenFile(dbDumpOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", dbDumpOut, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	result, err := dump.Dump(context.Background(), conn, w, opts)
+	if err != nil {
+		if dbDumpOut != "" {
+			os.Remove(dbDumpOut)
+		}
+		return err
+	}
+	if dbDumpOut != "" {
+		if err := w.(*os.File).Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dbDumpOut, err)
+		}
+	}
+
+	// Keep standard output clean for the dump itself
+	fmt.Fprintf(os.Stderr, "✓ Dumped %d rows from %d tables", result.Rows, result.Tables)
+	if opts.Rules != nil {
+		fmt.Fprintf(os.Stderr, ", %d columns masked", result.Masked)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// runDBBackup writes a full backup with pg_dump
+func runDBBackup() error {
+	format, err := backup.ParseFormat(dbDumpFormat)
+	if err != nil {
+		return fmt.Errorf("%w (or inserts)", err)
+	}
+	if dbDumpAnonymize != "" {
+		return fmt.Errorf("--anonymize only works with --format inserts")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	target, err := backup.TargetFor(ctx, conn, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if dbDumpOut != "" {
+		// The backup holds password hashes and keys, so keep it private.
+		// pg_dump writes into the file as it finds it.
+		f, err := os.OpenFile(dbDumpOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", dbDumpOut, err)
+		}
+		f.Close()
+		if err := os.Chmod(dbDumpOut, 0600); err != nil {
+			return err
+		}
+	}
+
+	opts := backup.DumpOptions{Format: format, Out: dbDumpOut, Schemas: dbDumpSchemas, Exclude: dbDumpExclude}
+	if err := backup.Dump(ctx, target, opts); err != nil {
+		if dbDumpOut != "" {
+			os.Remove(dbDumpOut)
+		}
+		return err
+	}
+	if dbDumpOut != "" {
+		fmt.Fprintf(os.Stderr, "✓ Backed up %s to %s (%s format)\n", cfg.PGDatabase, dbDumpOut, format)
+	}
+	return nil
+}
+
+// runDBRestore loads a backup with pg_restore or psql
+func runDBRestore(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	target, err := backup.TargetFor(ctx, conn, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if err := backup.Restore(ctx, target, path); err != nil {
+		return fmt.Errorf("restore failed, nothing was changed: %w", err)
+	}
+	fmt.Printf("✓ Restored %s into %s\n", path, cfg.PGDatabase)
+	return nil
+}
+
+// runDBShell runs psql interactively against the database
+func runDBShell(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	target, err := backup.TargetFor(context.Background(), conn, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	return backup.Shell(target, args)
+}
+
+// runDBSeed runs the seed files in one transaction
+func runDBSeed(cmd *cobra.Command, args []string) error {
+	files := dbSeedFiles
+	if len(files) == 0 {
+		var err error
+		if files, err = seed.Find("."); err != nil {
+			return fmt.Errorf("failed to find seed files: %w", err)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no seed files found: create %s or %s/*.sql", seed.File, seed.Dir)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := seed.Run(context.Background(), conn, files); err != nil {
+		return fmt.Errorf("seeding failed, nothing was loaded:\n%w", err)
+	}
+	for _, file := range files {
+		fmt.Printf("✓ Seeded %s\n", file)
+	}
+	return nil
+}
+
+// runDBUpgrade moves the cluster to a newer PostgreSQL major version
+func runDBUpgrade(cmd *cobra.Command, args []string) error {
+	to, err := pg.ResolveVersion(dbUpgradeTo)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.DatabaseURL != "" {
+		return fmt.Errorf("database_url is set: upgrade that PostgreSQL with its own tools")
+	}
+
+	result, err := upgrade.Run(context.Background(), upgrade.Options{
+		DataDir:   cfg.DataDir,
+		To:        to,
+		Port:      cfg.PGPort,
+		Username:  cfg.PGUsername,
+		Password:  cfg.PGPassword,
+		Database:  cfg.PGDatabase,
+		Locale:    cfg.PGLocale,
+		ICULocale: cfg.PGICULocale,
+		Progress: func(step string) {
+			fmt.Printf("→ %s\n", step)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := setPGVersion(to); err != nil {
+		return fmt.Errorf("upgraded, but failed to set pg_version in supalite.json: %w (set it to %s yourself)", err, to)
+	}
+
+	fmt.Printf("✓ Upgraded %s from PostgreSQL %s to %s (%s)\n", cfg.DataDir, result.From, result.To, strings.Join(result.Databases, ", "))
+	fmt.Printf("  pg_version in supalite.json is now %s\n", to)
+	fmt.Printf("  The old cluster is in %s and the backup in %s;\n", result.OldCluster, result.BackupDir)
+	fmt.Println("  remove them once the application works on the new version.")
+	return nil
+}
+
+// setPGVersion sets pg_version in supalite.json, creating the file if
+// there is none. Only the file's own settings are written back, not
+// those from environment variables.
+func setPGVersion(version string) error {
+	cfg := &config.Config{}
+	if data, err := os.ReadFile("supalite.json"); err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	cfg.PGVersion = version
+	return saveConfig(cfg)
+}
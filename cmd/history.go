@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyShowKey    []string
+	historyShowLimit  int
+	historyPruneAge   time.Duration
+	historyPruneTable string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Track and view row-level change history",
+	Long: `Track changes to tables and view their history.
+
+Tracking is opt-in per table. A tracked table's inserts, updates, deletes,
+and truncates are recorded in audit.record_version, with the old and new
+row as JSON and the role and user of the request that made the change.
+The audit schema follows Supabase's supa_audit extension, so it can also be
+used from SQL:
+
+  SELECT audit.enable_tracking('public.todos');
+
+Tables can also be tracked from startup with history.tables in
+supalite.json, and history.retention_days deletes old versions while the
+server runs.`,
+}
+
+var historyEnableCmd = &cobra.Command{
+	Use:   "enable <table> [table...]",
+	Short: "Start tracking changes to tables",
+	Long: `Start tracking changes to tables. Tables may be schema-qualified
+(app.orders) and default to public.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runHistoryEnable,
+}
+
+var historyDisableCmd = &cobra.Command{
+	Use:   "disable <table> [table...]",
+	Short: "Stop tracking changes to tables",
+	Long:  `Stop tracking changes to tables. The history recorded so far is kept.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runHistoryDisable,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tracked tables",
+	Args:  cobra.NoArgs,
+	RunE:  runHistoryList,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <table>",
+	Short: "Show the change history of a table or row",
+	Long: `Show the change history of a table, newest first, as JSON lines.
+
+With --key, only one row's history is shown. Give every primary key
+column:
+
+  supalite history show todos --key id=42`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistoryShow,
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old change history",
+	Long: `Delete versions older than --older-than, of every table or only
+--table.
+
+  supalite history prune --older-than 720h`,
+	Args: cobra.NoArgs,
+	RunE: runHistoryPrune,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyEnableCmd, historyDisableCmd, historyListCmd, historyShowCmd, historyPruneCmd)
+
+	historyShowCmd.Flags().StringSliceVar(&historyShowKey, "key", nil, "Primary key of a row, as column=value (repeat for composite keys)")
+	historyShowCmd.Flags().IntVar(&historyShowLimit, "limit", history.DefaultLimit, "Most versions to show")
+	historyPruneCmd.Flags().DurationVar(&historyPruneAge, "older-than", 0, "Delete versions older than this, e.g. 720h")
+	historyPruneCmd.Flags().StringVar(&historyPruneTable, "table", "", "Only prune this table's history")
+	historyPruneCmd.MarkFlagRequired("older-than")
+}
+
+// withHistory connects to the database, makes sure the audit schema
+// exists, and runs fn
+func withHistory(fn func(ctx context.Context, conn *pgx.Conn) error) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := history.Install(ctx, conn); err != nil {
+		return err
+	}
+	return fn(ctx, conn)
+}
+
+// runHistoryEnable starts tracking each table
+func runHistoryEnable(cmd *cobra.Command, args []string) error {
+	return withHistory(func(ctx context.Context, conn *pgx.Conn) error {
+		for _, table := range args {
+			name, err := history.Enable(ctx, conn, table)
+			if err != nil {
+				return fmt.Errorf("failed to track %s: %w", table, err)
+			}
+			fmt.Printf("✓ %s: tracking changes\n", name)
+		}
+		return nil
+	})
+}
+
+// runHistoryDisable stops tracking each table
+func runHistoryDisable(cmd *cobra.Command, args []string) error {
+	return withHistory(func(ctx context.Context, conn *pgx.Conn) error {
+		for _, table := range args {
+			name, err := history.Disable(ctx, conn, table)
+			if err != nil {
+				return fmt.Errorf("failed to stop tracking %s: %w", table, err)
+			}
+			fmt.Printf("✓ %s: no longer tracking changes\n", name)
+		}
+		return nil
+	})
+}
+
+// runHistoryList prints the tracked tables
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	return withHistory(func(ctx context.Context, conn *pgx.Conn) error {
+		tables, err := history.Tracked(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(tables) == 0 {
+			fmt.Println("No tables are tracked. Start with: supalite history enable <table>")
+			return nil
+		}
+		for _, t := range tables {
+			fmt.Printf("%s.%s: %d versions", t.Schema, t.Name, t.Versions)
+			if t.Oldest != nil {
+				fmt.Printf(" (%s to %s)", t.Oldest.Format(time.RFC3339), t.Newest.Format(time.RFC3339))
+			}
+			fmt.Println()
+		}
+		return nil
+	})
+}
+
+// runHistoryShow prints a table's or row's versions as JSON lines
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	params := url.Values{"limit": {fmt.Sprint(historyShowLimit)}}
+	for _, pair := range historyShowKey {
+		column, value, ok := strings.Cut(pair, "=")
+		if !ok || column == "" {
+			return fmt.Errorf("invalid --key %q: expected column=value", pair)
+		}
+		if column == "limit" || column == "before" {
+			return fmt.Errorf("invalid --key %q: %s is reserved", pair, column)
+		}
+		params.Set(column, value)
+	}
+	q, err := history.ParseQuery(args[0], params)
+	if err != nil {
+		return err
+	}
+
+	return withHistory(func(ctx context.Context, conn *pgx.Conn) error {
+		versions, err := history.Versions(ctx, conn, q)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, v := range versions {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runHistoryPrune deletes old versions
+func runHistoryPrune(cmd *cobra.Command, args []string) error {
+	if historyPruneAge <= 0 {
+		return fmt.Errorf("--older-than must be positive")
+	}
+	return withHistory(func(ctx context.Context, conn *pgx.Conn) error {
+		deleted, err := history.Prune(ctx, conn, historyPruneAge, historyPruneTable)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Deleted %d versions\n", deleted)
+		return nil
+	})
+}
@@ -5,38 +5,68 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+
 	"github.com/markb/supalite/internal/admin"
 	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/migrate"
 	"github.com/markb/supalite/internal/pg"
 	"github.com/markb/supalite/internal/prompt"
+	"github.com/markb/supalite/internal/seed"
 	"github.com/spf13/cobra"
 )
 
 var initConfig struct {
-	dbPath    string
-	port      uint16
-	username  string
-	password  string
-	database  string
-	pgVersion string
+	dbPath     string
+	port       uint16
+	username   string
+	password   string
+	database   string
+	pgVersion  string
+	locale     string
+	icuLocale  string
+	extensions []string
+	noSeed     bool
 }
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the embedded PostgreSQL database",
-	Long:  `Creates and initializes the embedded PostgreSQL database with Supabase schema.`,
+	Long: `Creates and initializes the embedded PostgreSQL database with Supabase schema.
+
+If the project has seed files (seed.sql, seeds/*.sql, or supabase/seed.sql),
+they are loaded afterwards, once any migrations have been applied; see
+supalite db seed. Use --no-seed to skip them.
+
+--extensions creates PostgreSQL extensions before the migrations run, and
+adds them to the new supalite.json so serve keeps them enabled:
+
+  supalite init --extensions=pg_trgm,uuid-ossp
+
+The embedded PostgreSQL ships the contrib extensions (pg_trgm, uuid-ossp,
+pgcrypto, hstore, citext, ...) but not pgvector or PostGIS; use database_url
+to serve from a PostgreSQL that has them.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("Initializing Supalite database...")
 
+		pgVersion, err := pg.ResolveVersion(initConfig.pgVersion)
+		if err != nil {
+			return err
+		}
+
 		cfg := pg.Config{
 			Port:     initConfig.port,
 			Username: initConfig.username,
 			Password: initConfig.password,
 			Database: initConfig.database,
 			DataDir:  initConfig.dbPath,
-			Version:  initConfig.pgVersion,
+			Version:  pgVersion,
+
+			Locale:    initConfig.locale,
+			ICULocale: initConfig.icuLocale,
 		}
 		database := pg.NewEmbeddedDatabase(cfg)
 
@@ -59,6 +89,13 @@ var initCmd = &cobra.Command{
 		}
 		defer conn.Close(ctx)
 
+		if err := pg.EnableExtensions(ctx, conn, initConfig.extensions); err != nil {
+			return err
+		}
+		for _, ext := range initConfig.extensions {
+			fmt.Printf("✓ Enabled extension %s\n", pg.ExtensionName(ext))
+		}
+
 		count, err := admin.Count(ctx, conn)
 		if err != nil {
 			return fmt.Errorf("failed to check admin users: %w", err)
@@ -104,7 +141,7 @@ var initCmd = &cobra.Command{
 		}
 
 		// Create supalite.json with capture mode enabled by default
-		if err := createDefaultConfig(initConfig.dbPath, initConfig.port, initConfig.username, initConfig.password, initConfig.database); err != nil {
+		if err := createDefaultConfig(initConfig.dbPath, initConfig.port, initConfig.username, initConfig.password, initConfig.database, pgVersion, initConfig.extensions); err != nil {
 			return fmt.Errorf("failed to create config file: %w", err)
 		}
 
@@ -115,10 +152,46 @@ var initCmd = &cobra.Command{
 		fmt.Printf("\nMail capture mode enabled by default for development.\n")
 		fmt.Printf("Configuration written to: %s\n", getConfigPath(initConfig.dbPath))
 
+		if !initConfig.noSeed {
+			if err := seedOnInit(ctx, conn); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	},
 }
 
+// seedOnInit loads the project's seed files, if any, applying its
+// migrations first so the tables they fill exist
+func seedOnInit(ctx context.Context, conn *pgx.Conn) error {
+	files, err := seed.Find(".")
+	if err != nil {
+		return fmt.Errorf("failed to find seed files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	dir := migrate.FindDir()
+	applied, err := migrate.Up(ctx, conn, dir, 0)
+	for _, m := range applied {
+		fmt.Printf("✓ Applied %s\n", filepath.Base(m.Path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations from %s before seeding: %w", dir, err)
+	}
+
+	if err := seed.Run(ctx, conn, files); err != nil {
+		return fmt.Errorf("seeding failed, nothing was loaded (fix it and run supalite db seed):\n%w", err)
+	}
+	for _, file := range files {
+		fmt.Printf("✓ Seeded %s\n", file)
+	}
+	return nil
+}
+
 func initSchema(ctx context.Context, db *pg.EmbeddedDatabase) error {
 	conn, err := db.Connect(ctx)
 	if err != nil {
@@ -169,7 +242,7 @@ func initSchema(ctx context.Context, db *pg.EmbeddedDatabase) error {
 }
 
 // createDefaultConfig creates a supalite.json file with capture mode enabled by default
-func createDefaultConfig(dataDir string, pgPort uint16, username, password, database string) error {
+func createDefaultConfig(dataDir string, pgPort uint16, username, password, database, pgVersion string, extensions []string) error {
 	configPath := getConfigPath(dataDir)
 
 	// Check if config already exists
@@ -185,12 +258,18 @@ func createDefaultConfig(dataDir string, pgPort uint16, username, password, data
 		PGUsername: username,
 		PGPassword: password,
 		PGDatabase: database,
+		Extensions: extensions,
 		Email: &config.EmailConfig{
 			CaptureMode: true,
 			CapturePort: 1025,
 		},
 	}
 
+	// serve must run the major version the cluster was created with
+	if pgVersion != pg.DefaultVersion {
+		cfg.PGVersion = pgVersion
+	}
+
 	// Marshal to JSON with indentation
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -222,5 +301,9 @@ func init() {
 	initCmd.Flags().StringVar(&initConfig.username, "username", "postgres", "Database username")
 	initCmd.Flags().StringVar(&initConfig.password, "password", "postgres", "Database password")
 	initCmd.Flags().StringVar(&initConfig.database, "database", "postgres", "Database name")
-	initCmd.Flags().StringVar(&initConfig.pgVersion, "pg-version", "16.9.0", "PostgreSQL version (e.g., 16.9.0, 15.8.0, 14.13.0)")
+	initCmd.Flags().StringVar(&initConfig.pgVersion, "pg-version", pg.DefaultVersion, "PostgreSQL version: a major version (e.g. 17) or a full one (e.g. 17.5.0)")
+	initCmd.Flags().StringVar(&initConfig.locale, "locale", "", "Database locale, e.g. de_DE.UTF-8 (default: the environment's)")
+	initCmd.Flags().StringVar(&initConfig.icuLocale, "icu-locale", "", "ICU collation, e.g. de-DE (overrides the locale's collation)")
+	initCmd.Flags().StringSliceVar(&initConfig.extensions, "extensions", nil, "PostgreSQL extensions to enable, e.g. pg_trgm,uuid-ossp")
+	initCmd.Flags().BoolVar(&initConfig.noSeed, "no-seed", false, "Don't load seed.sql or seeds/*.sql")
 }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagKeysGracePeriod time.Duration
+	flagKeysRevoke      bool
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage JWT signing keys",
+	Long:  `Manage the ES256 signing key and the anon and service_role API keys in <data-dir>/keys.json.`,
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate the JWT signing key",
+	Long: `Generate a new ES256 signing key and re-mint the anon and service_role
+keys with it. The old public key stays in /.well-known/jwks.json and keeps
+verifying tokens for the grace period, so clients holding the old keys keep
+working while they move to the new ones. Each rotation is recorded in
+keys.json.
+
+Restart the server afterwards to sign with the new key.`,
+	Args: cobra.NoArgs,
+	RunE: runKeysRotate,
+}
+
+var keysRegenerateCmd = &cobra.Command{
+	Use:   "regenerate anon|service_role",
+	Short: "Replace the anon or service_role API keys",
+	Long: `Mint a new JWT for the role, and a new publishable (anon) or secret
+(service_role) key, without changing the signing key.
+
+By default the old keys keep working, so clients can move to the new ones
+at their own pace. With --revoke they stop working as soon as the server
+restarts, along with any earlier keys of the role replaced without
+--revoke. Use it when a key has leaked:
+
+  supalite keys regenerate service_role --revoke
+
+Restart the server afterwards to pick up the change.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"anon", "service_role"},
+	RunE:      runKeysRegenerate,
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysRotateCmd)
+	keysCmd.AddCommand(keysRegenerateCmd)
+
+	keysRotateCmd.Flags().DurationVar(&flagKeysGracePeriod, "grace-period", keys.DefaultGracePeriod, "How long the old key keeps verifying tokens (0 = not at all)")
+	keysRegenerateCmd.Flags().BoolVar(&flagKeysRevoke, "revoke", false, "Make the old keys stop working")
+}
+
+// runKeysRotate replaces the signing key in keys.json
+func runKeysRotate(cmd *cobra.Command, args []string) error {
+	if flagKeysGracePeriod < 0 {
+		return fmt.Errorf("--grace-period must not be negative")
+	}
+
+	manager, err := loadStoredKeys("key rotation")
+	if err != nil {
+		return err
+	}
+
+	rotation, err := manager.Rotate(flagKeysGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Rotated signing key %s → %s\n", rotation.OldKeyID, rotation.NewKeyID)
+	if flagKeysGracePeriod > 0 {
+		fmt.Printf("  %s keeps verifying tokens until %s\n", rotation.OldKeyID, rotation.GraceUntil.Local().Format("2006-01-02 15:04:05"))
+	} else {
+		fmt.Printf("  Tokens signed by %s no longer verify\n", rotation.OldKeyID)
+	}
+	fmt.Println()
+	fmt.Println("anon key:")
+	fmt.Println("  " + manager.GetAnonKey())
+	fmt.Println("service_role key (keep this secret!):")
+	fmt.Println("  " + manager.GetServiceKey())
+	fmt.Println()
+	fmt.Println("Restart the server to sign with the new key.")
+	return nil
+}
+
+// runKeysRegenerate replaces the API keys of a role in keys.json
+func runKeysRegenerate(cmd *cobra.Command, args []string) error {
+	role := args[0]
+	if role != "anon" && role != "service_role" {
+		return fmt.Errorf("unknown role %q: use anon or service_role", role)
+	}
+
+	manager, err := loadStoredKeys("key regeneration")
+	if err != nil {
+		return err
+	}
+
+	result, err := manager.Regenerate(role, flagKeysRevoke)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Regenerated the %s keys\n", role)
+	if result.Revoked > 0 {
+		fmt.Printf("  Revoked %d old key(s)\n", result.Revoked)
+	} else {
+		fmt.Println("  The old keys keep working; run again with --revoke to cut them off")
+	}
+	fmt.Println()
+	if role == "anon" {
+		fmt.Println("anon key:")
+		fmt.Println("  " + manager.GetAnonKey())
+		fmt.Println("publishable key:")
+		fmt.Println("  " + manager.GetPublishableKey())
+	} else {
+		fmt.Println("service_role key (keep this secret!):")
+		fmt.Println("  " + manager.GetServiceKey())
+		fmt.Println("secret key (keep this secret!):")
+		fmt.Println("  " + manager.GetSecretKey())
+	}
+	fmt.Println()
+	fmt.Println("Restart the server to pick up the new keys.")
+	return nil
+}
+
+// loadStoredKeys loads the keys in <data-dir>/keys.json for a command that
+// changes them. operation names the command in errors.
+func loadStoredKeys(operation string) (*keys.Manager, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if cfg.JWTSecret != "" {
+		return nil, fmt.Errorf("%s is not available in legacy mode (--jwt-secret); change the secret instead", operation)
+	}
+	if cfg.Deterministic {
+		return nil, fmt.Errorf("%s is not available in deterministic mode; change --deterministic-seed instead", operation)
+	}
+
+	// Don't let NewManager generate first keys only to replace them
+	if _, err := os.Stat(filepath.Join(cfg.DataDir, "keys.json")); err != nil {
+		return nil, fmt.Errorf("no keys found in %s - start the server once to generate them", cfg.DataDir)
+	}
+
+	manager, err := keys.NewManager(cfg.DataDir, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keys: %w", err)
+	}
+	return manager, nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/migrate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateDir       string
+	migrateUpSteps   int
+	migrateDownSteps int
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply and manage SQL migrations",
+	Long: `Apply versioned SQL migrations to the database.
+
+Migrations are files named <version>_<name>.sql in ./migrations, or in
+./supabase/migrations for a Supabase CLI project, and are applied in
+version order. Applied versions are recorded in
+supabase_migrations.schema_migrations, as the Supabase CLI does.
+
+A migration may have a down migration with the same file name in the
+down/ subdirectory, used by 'supalite migrate down'.
+
+Pending migrations can also be applied whenever the server starts, with
+'supalite serve --migrate'.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Long: `Apply the pending migrations, oldest first, each in its own transaction.
+Applying stops at the first migration that fails, which is rolled back.`,
+	Args: cobra.NoArgs,
+	RunE: runMigrateUp,
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the newest applied migrations",
+	Long: `Revert the newest applied migration, or the newest --steps of them,
+with their down migrations.`,
+	Args: cobra.NoArgs,
+	RunE: runMigrateDown,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they are applied",
+	Args:  cobra.NoArgs,
+	RunE:  runMigrateStatus,
+}
+
+var migrateNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create an empty migration",
+	Long: `Create an empty migration versioned with the current time, and an
+empty down migration for it.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMigrateNew,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateNewCmd)
+	migrateCmd.PersistentFlags().StringVar(&migrateDir, "dir", "", "Migrations directory (default: ./migrations, or ./supabase/migrations)")
+	migrateUpCmd.Flags().IntVar(&migrateUpSteps, "steps", 0, "Apply at most this many migrations (default: all)")
+	migrateDownCmd.Flags().IntVar(&migrateDownSteps, "steps", 1, "Number of migrations to revert")
+}
+
+// migrationsDir returns the directory migrations are read from: --dir,
+// the configured one, or the default
+func migrationsDir(cfg *config.Config) string {
+	if migrateDir != "" {
+		return migrateDir
+	}
+	if cfg.Migrations != nil && cfg.Migrations.Dir != "" {
+		return cfg.Migrations.Dir
+	}
+	return migrate.FindDir()
+}
+
+// withMigrations connects to the database and calls fn with it and the
+// migrations directory
+func withMigrations(fn func(ctx context.Context, conn *pgx.Conn, dir string) error) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return fn(context.Background(), conn, migrationsDir(cfg))
+}
+
+// runMigrateUp applies pending migrations
+func runMigrateUp(cmd *cobra.Command, args []string) error {
+	return withMigrations(func(ctx context.Context, conn *pgx.Conn, dir string) error {
+		applied, err := migrate.Up(ctx, conn, dir, migrateUpSteps)
+		for _, m := range applied {
+			fmt.Printf("✓ Applied %s\n", filepath.Base(m.Path))
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return nil
+	})
+}
+
+// runMigrateDown reverts the newest applied migrations
+func runMigrateDown(cmd *cobra.Command, args []string) error {
+	return withMigrations(func(ctx context.Context, conn *pgx.Conn, dir string) error {
+		reverted, err := migrate.Down(ctx, conn, dir, migrateDownSteps)
+		for _, m := range reverted {
+			fmt.Printf("✓ Reverted %s\n", filepath.Base(m.Path))
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+		return nil
+	})
+}
+
+// runMigrateStatus prints each migration and whether it is applied
+func runMigrateStatus(cmd *cobra.Command, args []string) error {
+	return withMigrations(func(ctx context.Context, conn *pgx.Conn, dir string) error {
+		migrations, err := migrate.Status(ctx, conn, dir)
+		if err != nil {
+			return err
+		}
+		if len(migrations) == 0 {
+			fmt.Printf("No migrations in %s\n", dir)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+		for _, m := range migrations {
+			status := "pending"
+			switch {
+			case m.Missing():
+				status = "applied, file missing"
+			case m.Applied:
+				status = "applied"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", m.Version, m.Name, status)
+		}
+		return w.Flush()
+	})
+}
+
+// runMigrateNew creates an empty migration and its down migration
+func runMigrateNew(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	m, err := migrate.New(migrationsDir(cfg), strings.Join(args, " "), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Created %s\n", m.Path)
+	fmt.Printf("  and %s\n", m.DownPath)
+	return nil
+}
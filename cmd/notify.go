@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage operational alerts",
+	Long: `supalite alerts the people running it when a component crashes or can't be
+restarted, a backup fails, the disk runs low, or one client fails to
+authenticate over and over. Alerts go to the channels set under
+"notifications" in supalite.json: a Slack incoming webhook, a webhook that
+receives signed JSON, or email through the email.smtp_* server.`,
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Send a test alert to every configured channel",
+	Args:  cobra.NoArgs,
+	RunE:  runNotifyTest,
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+}
+
+// runNotifyTest sends a test alert and reports whether it was delivered
+func runNotifyTest(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	n := newNotifier(cfg)
+	if n == nil {
+		return fmt.Errorf("no notification channels configured (see \"notifications\" in supalite.json)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = n.Send(ctx, notify.Event{
+		Kind:     "test",
+		Severity: notify.SeverityCritical, // passes any min_severity
+		Message:  "test alert from supalite notify test",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send test alert: %w", err)
+	}
+
+	fmt.Println("✓ Test alert sent")
+	return nil
+}
+
+// newNotifier builds the notifier for the configured channels, or nil if
+// there are none. Settings are validated by config.Load.
+func newNotifier(cfg *config.Config) *notify.Notifier {
+	n := cfg.Notifications
+	if n == nil {
+		return nil
+	}
+
+	var channels []notify.Channel
+	if n.SlackWebhookURL != "" {
+		channels = append(channels, &notify.SlackChannel{URL: n.SlackWebhookURL})
+	}
+	if n.WebhookURL != "" {
+		channels = append(channels, &notify.WebhookChannel{URL: n.WebhookURL, Secret: n.WebhookSecret})
+	}
+	if len(n.Email) > 0 && cfg.Email != nil {
+		from := cfg.Email.SMTPAdminEmail
+		if from == "" {
+			from = cfg.Email.SMTPUser
+		}
+		channels = append(channels, &notify.EmailChannel{
+			Host: cfg.Email.SMTPHost,
+			Port: cfg.Email.SMTPPort,
+			User: cfg.Email.SMTPUser,
+			Pass: cfg.Email.SMTPPass,
+			From: from,
+			To:   n.Email,
+		})
+	}
+
+	severity, _ := notify.ParseSeverity(n.MinSeverity)
+	return notify.New(notify.Config{
+		Channels:    channels,
+		MinSeverity: severity,
+		Cooldown:    time.Duration(n.CooldownSeconds) * time.Second,
+		Instance:    n.Instance,
+	})
+}
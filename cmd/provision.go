@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/migrate"
+	"github.com/markb/supalite/internal/pg"
+	"github.com/markb/supalite/internal/provision"
+	"github.com/markb/supalite/internal/seed"
+	"github.com/markb/supalite/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagProvisionConfig  string
+	flagProvisionTimeout time.Duration
+)
+
+var provisionCmd = &cobra.Command{
+	Use:   "provision --config <file>",
+	Short: "Set up a data directory from a declaration, without prompting",
+	Long: `Create or update a data directory to match a YAML (or JSON) file, then
+exit, for Terraform, Pulumi, Ansible, and the like:
+
+  data_dir: /var/lib/supalite
+  url: https://api.example.com
+  pg_password_env: PG_PASSWORD
+  extensions: [pg_trgm]
+  admins:
+    - email: ops@example.com
+      password_env: SUPALITE_ADMIN_PASSWORD
+  buckets:
+    - id: avatars
+      public: true
+      file_size_limit: 5MB
+      allowed_mime_types: [image/*]
+  migrations_dir: supabase/migrations
+  seed: [supabase/seed.sql]
+
+It initializes the database, generates the API keys, creates or updates the
+admin users and buckets, applies new migrations, and writes supalite.json.
+Seed files are loaded only by the run that creates the database. What
+already matches is left alone, so running it again changes nothing.
+
+The result is printed on stdout as JSON: the API keys, the URLs under url,
+the database URL, and what each step did ("changed" is false when nothing
+did). Logs go to stderr. Relative paths are relative to the working
+directory, as for serve. Stop the server first; provisioning needs the
+database to itself.`,
+	Args: cobra.NoArgs,
+	RunE: runProvision,
+}
+
+func init() {
+	rootCmd.AddCommand(provisionCmd)
+	provisionCmd.Flags().StringVar(&flagProvisionConfig, "config", "", "Provisioning file (YAML or JSON)")
+	provisionCmd.Flags().DurationVar(&flagProvisionTimeout, "timeout", 5*time.Minute, "Give up after this long")
+	provisionCmd.MarkFlagRequired("config")
+}
+
+// runProvision applies a provisioning file and prints the result
+func runProvision(cmd *cobra.Command, args []string) error {
+	spec, err := provision.Load(flagProvisionConfig)
+	if err != nil {
+		return err
+	}
+
+	if postmaster, err := pg.ReadPostmaster(pg.ClusterPath(spec.DataDir)); err == nil && postmaster.Running() {
+		return fmt.Errorf("supalite is running on %s (PostgreSQL pid %d); stop it before provisioning", spec.DataDir, postmaster.PID)
+	}
+
+	// An existing cluster opens with the major version that created it
+	clusterVersion, err := pg.ClusterVersion(spec.DataDir)
+	if err != nil {
+		return err
+	}
+	requested := spec.PGVersion
+	if requested == "" {
+		requested = pg.DefaultVersion
+		if clusterVersion != "" {
+			requested = clusterVersion
+		}
+	}
+	pgVersion, err := pg.ResolveVersion(requested)
+	if err != nil {
+		return err
+	}
+	if clusterVersion != "" && pg.MajorVersion(pgVersion) != clusterVersion {
+		return fmt.Errorf("%s holds a PostgreSQL %s cluster, not %s; run supalite db upgrade to move it", spec.DataDir, clusterVersion, pg.MajorVersion(pgVersion))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flagProvisionTimeout)
+	defer cancel()
+
+	database := pg.NewEmbeddedDatabase(pg.Config{
+		Port:     spec.PGPort,
+		Username: spec.PGUsername,
+		Password: spec.PGPassword,
+		Database: spec.PGDatabase,
+		DataDir:  spec.DataDir,
+		Version:  pgVersion,
+	})
+	if err := database.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start database: %w", err)
+	}
+	defer database.Stop()
+
+	created := clusterVersion == ""
+	result, err := provisionDatabase(ctx, database, spec, created)
+	if err != nil {
+		return err
+	}
+	result.Created = created
+	result.Changed = result.Changed || created
+
+	manager, err := keys.NewManager(spec.DataDir, "")
+	if err != nil {
+		return fmt.Errorf("failed to load keys: %w", err)
+	}
+	result.ProjectRef = manager.GetProjectRef()
+	result.Keys = provision.Keys{
+		Anon:        manager.GetAnonKey(),
+		ServiceRole: manager.GetServiceKey(),
+		Publishable: manager.GetPublishableKey(),
+		Secret:      manager.GetSecretKey(),
+	}
+
+	configPath := getConfigPath(spec.DataDir)
+	if _, err := os.Stat(configPath); err != nil {
+		result.Changed = true
+	}
+	if err := createDefaultConfig(spec.DataDir, spec.PGPort, spec.PGUsername, spec.PGPassword, spec.PGDatabase, pgVersion, spec.Extensions); err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+
+	result.DataDir, _ = filepath.Abs(spec.DataDir)
+	result.Config, _ = filepath.Abs(configPath)
+	result.URLs = provision.NewURLs(spec.URL)
+	result.URLs.DatabaseURL = pg.ConnectionURL(spec.PGUsername, spec.PGPassword, spec.PGDatabase, "", int(spec.PGPort))
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// provisionDatabase brings the database in line with spec: schema,
+// extensions, admin users, buckets, migrations, and, when this run created
+// the database, seed data
+func provisionDatabase(ctx context.Context, database *pg.EmbeddedDatabase, spec *provision.Spec, created bool) (*provision.Result, error) {
+	result := &provision.Result{
+		Admins:     []provision.ItemResult{},
+		Buckets:    []provision.ItemResult{},
+		Migrations: []string{},
+		Seeded:     []string{},
+		Extensions: []string{},
+	}
+
+	if err := initSchema(ctx, database); err != nil {
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	conn, err := database.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := pg.EnableExtensions(ctx, conn, spec.Extensions); err != nil {
+		return nil, err
+	}
+	for _, ext := range spec.Extensions {
+		result.Extensions = append(result.Extensions, pg.ExtensionName(ext))
+	}
+
+	for _, a := range spec.Admins {
+		status, err := ensureAdmin(ctx, conn, a)
+		if err != nil {
+			return nil, err
+		}
+		result.Admins = append(result.Admins, provision.ItemResult{Name: a.Email, Status: status})
+		result.Changed = result.Changed || status != provision.StatusUnchanged
+	}
+
+	if len(spec.Buckets) > 0 {
+		// Only the schema is needed; serve sets up the rest of storage
+		store := storage.NewServer(storage.Config{Database: database, Root: filepath.Join(spec.DataDir, "storage")})
+		if err := store.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		for _, b := range spec.Buckets {
+			status, err := storage.EnsureBucket(ctx, conn, storage.BucketSpec{
+				ID:               b.ID,
+				Public:           b.Public,
+				FileSizeLimit:    b.FileSizeLimit,
+				AllowedMimeTypes: b.AllowedMimeTypes,
+			})
+			if err != nil {
+				return nil, err
+			}
+			result.Buckets = append(result.Buckets, provision.ItemResult{Name: b.ID, Status: status})
+			result.Changed = result.Changed || status != storage.BucketUnchanged
+		}
+	}
+
+	if spec.MigrationsDir != "" {
+		applied, err := migrate.Up(ctx, conn, spec.MigrationsDir, 0)
+		for _, m := range applied {
+			result.Migrations = append(result.Migrations, filepath.Base(m.Path))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply migrations from %s: %w", spec.MigrationsDir, err)
+		}
+		result.Changed = result.Changed || len(applied) > 0
+	}
+
+	// Seed files aren't idempotent, so only a new database gets them
+	if len(spec.Seed) > 0 && created {
+		if err := seed.Run(ctx, conn, spec.Seed); err != nil {
+			return nil, fmt.Errorf("seeding failed, nothing was loaded:\n%w", err)
+		}
+		result.Seeded = append(result.Seeded, spec.Seed...)
+		result.Changed = true
+	}
+
+	return result, nil
+}
+
+// ensureAdmin creates an admin user, or sets an existing one's password
+// when it doesn't match, and returns which it did
+func ensureAdmin(ctx context.Context, conn *pgx.Conn, a provision.Admin) (string, error) {
+	user, err := admin.FindByEmail(ctx, conn, a.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := admin.Create(ctx, conn, a.Email, a.Password); err != nil {
+			return "", fmt.Errorf("failed to create admin %s: %w", a.Email, err)
+		}
+		return provision.StatusCreated, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if admin.VerifyPassword(a.Password, user.PasswordHash) == nil {
+		return provision.StatusUnchanged, nil
+	}
+	if err := admin.UpdatePassword(ctx, conn, a.Email, a.Password); err != nil {
+		return "", fmt.Errorf("failed to update admin %s: %w", a.Email, err)
+	}
+	return provision.StatusUpdated, nil
+}
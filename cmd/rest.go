@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagRESTURL     string
+	flagRESTMethod  string
+	flagRESTData    string
+	flagRESTHeaders []string
+	flagRESTJSON    bool
+)
+
+var restCmd = &cobra.Command{
+	Use:   "rest",
+	Short: "Debug the REST API of a running server",
+}
+
+var restExplainCmd = &cobra.Command{
+	Use:   "explain <url>",
+	Short: "Show the SQL a REST request runs, without changing anything",
+	Long: `Run a REST request against the running server in a transaction that is
+rolled back, and print the SQL statements it ran with their bind
+parameters. Writes are undone, so inserts, updates, and deletes can be
+explained safely.
+
+The URL is a full URL or a path; paths not under /rest are taken to be
+relative to /rest/v1:
+
+  supalite rest explain 'todos?select=id,title&done=eq.false&order=id'
+  supalite rest explain -X PATCH -d '{"done":true}' 'todos?id=eq.1'
+  supalite rest explain -H 'Authorization: Bearer <user token>' 'todos'
+
+The request runs as service_role unless -H gives another apikey or
+Authorization header. Row level security applies as it would to that role.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRESTExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(restCmd)
+	restCmd.AddCommand(restExplainCmd)
+
+	restCmd.PersistentFlags().StringVar(&flagRESTURL, "url", "", "URL of the running server (default: http://localhost:<port>)")
+	restExplainCmd.Flags().StringVarP(&flagRESTMethod, "method", "X", "", "HTTP method (default: GET, or POST with --data)")
+	restExplainCmd.Flags().StringVarP(&flagRESTData, "data", "d", "", "Request body, e.g. a JSON row to insert")
+	restExplainCmd.Flags().StringArrayVarP(&flagRESTHeaders, "header", "H", nil, "Request header as 'Name: value' (repeatable), e.g. 'Prefer: return=representation'")
+	restExplainCmd.Flags().BoolVar(&flagRESTJSON, "json", false, "Print the result as JSON")
+}
+
+// restExplainResponse mirrors the server's /admin/v1/rest/explain response
+type restExplainResponse struct {
+	Method     string               `json:"method"`
+	Path       string               `json:"path"`
+	Status     int                  `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	Statements []recorder.Statement `json:"statements"`
+}
+
+// runRESTExplain sends the request to the server's explain API and prints
+// the statements it ran
+func runRESTExplain(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	serviceKey, err := localServiceKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	baseURL := strings.TrimSuffix(flagRESTURL, "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+
+	path, err := restExplainPath(args[0])
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(flagRESTMethod)
+	if method == "" {
+		method = http.MethodGet
+		if flagRESTData != "" {
+			method = http.MethodPost
+		}
+	}
+	headers := map[string]string{}
+	for _, h := range flagRESTHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("invalid header %q: want 'Name: value'", h)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	body := map[string]interface{}{
+		"method":  method,
+		"path":    path,
+		"headers": headers,
+	}
+	if flagRESTData != "" {
+		if !json.Valid([]byte(flagRESTData)) {
+			return fmt.Errorf("--data is not valid JSON")
+		}
+		body["body"] = json.RawMessage(flagRESTData)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/admin/v1/rest/explain", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+serviceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the server at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result restExplainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid response from server: %w", err)
+	}
+
+	if flagRESTJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	printRESTExplain(&result)
+	return nil
+}
+
+// restExplainPath returns the path and query of a URL given to rest
+// explain, relative to /rest/v1 unless it is under /rest
+func restExplainPath(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	path := u.Path
+	if !strings.HasPrefix(path, "/rest/") && path != "/rest" {
+		path = "/rest/v1/" + strings.TrimPrefix(path, "/")
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path, nil
+}
+
+// printRESTExplain prints the response status and each statement with its
+// bind parameters
+func printRESTExplain(result *restExplainResponse) {
+	fmt.Printf("%s %s\n", result.Method, result.Path)
+	fmt.Printf("Status: %d %s (rolled back)\n", result.Status, http.StatusText(result.Status))
+	if result.Error != "" {
+		fmt.Printf("Error:  %s\n", result.Error)
+	}
+
+	if len(result.Statements) == 0 {
+		fmt.Println("\nNo SQL was run")
+		return
+	}
+	for i, st := range result.Statements {
+		fmt.Printf("\n-- Statement %d (%.2fms, %d rows)\n", i+1, st.DurationMS, st.Rows)
+		fmt.Println(strings.TrimSpace(st.SQL))
+		for j, arg := range st.Args {
+			value, _ := json.Marshal(arg)
+			fmt.Printf("--   $%d = %s\n", j+1, value)
+		}
+		if st.Error != "" {
+			fmt.Printf("-- Error: %s\n", st.Error)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/markb/supalite/internal/log"
 	"github.com/spf13/cobra"
 )
 
@@ -13,11 +14,40 @@ var (
 	GitCommit = ""
 )
 
+var (
+	flagLogFormat string
+	flagLogLevel  string
+)
+
 var rootCmd = &cobra.Command{
 	Use:     "supalite",
 	Short:   "Supalite - lightweight Supabase-compatible backend",
 	Long:    `A single-binary backend with embedded PostgreSQL, pREST, and Supabase Auth (GoTrue).`,
 	Version: Version,
+
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogging()
+	},
+}
+
+// configureLogging applies --log-format and --log-level, falling back to
+// SUPALITE_LOG_FORMAT and SUPALITE_LOG_LEVEL.
+func configureLogging() error {
+	format := flagLogFormat
+	if format == "" {
+		format = os.Getenv("SUPALITE_LOG_FORMAT")
+	}
+	if format != "" {
+		if err := log.SetFormat(format); err != nil {
+			return err
+		}
+	}
+
+	levels := flagLogLevel
+	if levels == "" {
+		levels = os.Getenv("SUPALITE_LOG_LEVEL")
+	}
+	return log.SetLevels(levels)
 }
 
 func init() {
@@ -31,6 +61,9 @@ func init() {
 	}
 	versionTmpl += "\n"
 	rootCmd.SetVersionTemplate(versionTmpl)
+
+	rootCmd.PersistentFlags().StringVar(&flagLogFormat, "log-format", "", "Log format: text or json (env: SUPALITE_LOG_FORMAT, default: text)")
+	rootCmd.PersistentFlags().StringVar(&flagLogLevel, "log-level", "", "Log level, with optional per-component levels, e.g. info,pg=debug (env: SUPALITE_LOG_LEVEL, default: info)")
 }
 
 func Execute() {
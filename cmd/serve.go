@@ -3,12 +3,25 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/markb/supalite/internal/auditlog"
 	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/chaos"
 	"github.com/markb/supalite/internal/config"
-	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/history"
+	"github.com/markb/supalite/internal/limits"
+	"github.com/markb/supalite/internal/migrate"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rules"
 	"github.com/markb/supalite/internal/server"
+	"github.com/markb/supalite/internal/status"
+	"github.com/markb/supalite/internal/tracing"
+	"github.com/markb/supalite/internal/usage"
+	"github.com/markb/supalite/internal/watchdog"
 	"github.com/spf13/cobra"
 )
 
@@ -20,14 +33,43 @@ var (
 	flagHost           string
 	flagPort           int
 	flagPgPort         uint16
+	flagPgSocketDir    string
 	flagDataDir        string
+	flagDatabaseURL    string
 	flagJwtSecret      string
 	flagSiteURL        string
+	flagPublicURL      string
 	flagPgUsername     string
 	flagPgPassword     string
 	flagPgDatabase     string
+	flagPgMinConns     int32
+	flagPgMaxConns     int32
+	flagPgLocale       string
+	flagPgICULocale    string
+	flagPgTimezone     string
 	flagAnonKey        string
 	flagServiceRoleKey string
+	flagGoTrueBinary   string
+	flagAuthMode       string
+	flagPREST          bool
+	flagDocs           bool
+	flagStatus         bool
+	flagAccessLog      bool
+	flagAuditLog       bool
+	flagMigrate        bool
+	flagRESTRecord     bool
+	flagUsage          bool
+
+	// Tracing flags
+	flagTracingEndpoint string
+
+	// TLS flags
+	flagTLSCert        string
+	flagTLSKey         string
+	flagTLSSelfSigned  bool
+	flagTLSACMEDomains []string
+	flagTLSACMEEmail   string
+	flagTLSHTTPPort    int
 
 	// Email flags
 	flagSmtpHost            string
@@ -42,20 +84,58 @@ var (
 	flagMailerUrlpathsEmailChange  string
 
 	// Email capture mode flags
-	flagCaptureMode bool
-	flagCapturePort int
+	flagCaptureMode          bool
+	flagCapturePort          int
+	flagCaptureSink          string
+	flagCaptureDir           string
+	flagCaptureWebhookURL    string
+	flagCaptureWebhookSecret string
+
+	// SMS flags
+	flagSMSProvider                string
+	flagSMSTwilioAccountSID        string
+	flagSMSTwilioAuthToken         string
+	flagSMSTwilioMessageServiceSID string
+	flagSMSMessageBirdAccessKey    string
+	flagSMSMessageBirdOriginator   string
+	flagSMSAutoconfirm             bool
+	flagSMSCaptureMode             bool
+
+	// REST API version flag
+	flagRESTDefaultVersion int
+	flagRESTIdentifierCase string
+	flagRESTMaxFieldSize   int
+
+	// Watchdog flags
+	flagWatchdogMinFreeMB    int
+	flagWatchdogRefuseWrites bool
+
+	// Chaos flags
+	flagChaos     bool
+	flagChaosSeed int64
+
+	// Seed user flags
+	flagSeedUsers []string
+
+	// OAuth provider flags
+	flagExternalProviders []string
+
+	// Ephemeral mode flag
+	flagEphemeral bool
+
+	// Deterministic mode flags
+	flagDeterministic     bool
+	flagDeterministicSeed string
+	flagProjectRef        string
 )
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the Supalite server",
-	Long: `Start the Supalite server with embedded PostgreSQL, pREST, and GoTrue auth.
+	Long: `Start the Supalite server with embedded PostgreSQL and GoTrue auth.
 
 The server orchestrates all components and provides a unified API endpoint.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Set log level
-		log.SetLevel(log.LevelInfo)
-
 		// Load configuration (file + env vars)
 		cfg, err := config.Load()
 		if err != nil {
@@ -65,9 +145,53 @@ The server orchestrates all components and provides a unified API endpoint.`,
 		// Apply flag overrides (flags take precedence over file and env vars)
 		applyFlagOverrides(cfg)
 
+		// Seed users from flags are added to those from the config file/env vars
+		for _, entry := range flagSeedUsers {
+			user, err := config.ParseSeedUser(entry)
+			if err != nil {
+				return err
+			}
+			cfg.SeedUsers = append(cfg.SeedUsers, user)
+		}
+
+		// OAuth providers from flags replace those configured elsewhere
+		for _, entry := range flagExternalProviders {
+			name, provider, err := config.ParseExternalProvider(entry)
+			if err != nil {
+				return err
+			}
+			if cfg.ExternalProviders == nil {
+				cfg.ExternalProviders = map[string]*config.ExternalProviderConfig{}
+			}
+			cfg.ExternalProviders[name] = provider
+		}
+
+		// Resolve the public URL; it is also the default site URL, so
+		// redirects after confirming an email stay on the other device
+		scheme := "http"
+		if cfg.TLS.Enabled() {
+			scheme = "https"
+		}
+		if cfg.PublicURL != "" {
+			publicURL, err := server.ResolvePublicURL(cfg.PublicURL, cfg.Port, scheme)
+			if err != nil {
+				return err
+			}
+			cfg.PublicURL = publicURL
+			if cfg.SiteURL == "" {
+				cfg.SiteURL = publicURL
+			}
+		}
+
 		// Set default site URL if not provided
 		if cfg.SiteURL == "" {
-			cfg.SiteURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+			cfg.SiteURL = fmt.Sprintf("%s://localhost:%d", scheme, cfg.Port)
+			if cfg.TLS.Enabled() && len(cfg.TLS.ACMEDomains) > 0 {
+				cfg.SiteURL = "https://" + cfg.TLS.ACMEDomains[0]
+				if cfg.Port != 443 {
+					cfg.SiteURL = fmt.Sprintf("https://%s:%d", cfg.TLS.ACMEDomains[0], cfg.Port)
+				}
+			}
 		}
 
 		// Convert config.Email to auth.EmailConfig
@@ -86,9 +210,295 @@ The server orchestrates all components and provides a unified API endpoint.`,
 				URLPathsEmailChange: cfg.Email.MailerURLPathsEmailChange,
 				CaptureMode:         cfg.Email.CaptureMode,
 				CapturePort:         cfg.Email.CapturePort,
+				CaptureSink:         cfg.Email.CaptureSink,
+				CaptureDir:          cfg.Email.CaptureDir,
+				CaptureWebhookURL:   cfg.Email.CaptureWebhookURL,
+				CaptureWebhookSecret: cfg.Email.CaptureWebhookSecret,
+			}
+		}
+
+		// Convert config.SMS to auth.SMSConfig
+		var smsCfg *auth.SMSConfig
+		if cfg.SMS != nil && hasSMSConfig(cfg.SMS) {
+			if cfg.SMS.Provider != "" && !slices.Contains(config.SMSProviders, cfg.SMS.Provider) {
+				return fmt.Errorf("unknown SMS provider %q (supported: %s)", cfg.SMS.Provider, strings.Join(config.SMSProviders, ", "))
+			}
+			smsCfg = &auth.SMSConfig{
+				Provider:                cfg.SMS.Provider,
+				TwilioAccountSID:        cfg.SMS.TwilioAccountSID,
+				TwilioAuthToken:         cfg.SMS.TwilioAuthToken,
+				TwilioMessageServiceSID: cfg.SMS.TwilioMessageServiceSID,
+				MessageBirdAccessKey:    cfg.SMS.MessageBirdAccessKey,
+				MessageBirdOriginator:   cfg.SMS.MessageBirdOriginator,
+				Template:                cfg.SMS.Template,
+				OTPExp:                  cfg.SMS.OTPExp,
+				OTPLength:               cfg.SMS.OTPLength,
+				Autoconfirm:             cfg.SMS.Autoconfirm,
+				CaptureMode:             cfg.SMS.CaptureMode,
+			}
+		}
+
+		// Convert config.Auth to the auth package's hardening settings
+		var (
+			mfaCfg      *auth.MFAConfig
+			captchaCfg  *auth.CaptchaConfig
+			passwordCfg *auth.PasswordPolicy
+			rateLimits  *auth.RateLimits
+		)
+		if cfg.Auth != nil {
+			if m := cfg.Auth.MFA; m != nil && *m != (config.MFAConfig{}) {
+				mfaCfg = &auth.MFAConfig{
+					TOTPEnroll:         m.TOTPEnrollEnabled,
+					TOTPVerify:         m.TOTPVerifyEnabled,
+					PhoneEnroll:        m.PhoneEnrollEnabled,
+					PhoneVerify:        m.PhoneVerifyEnabled,
+					MaxEnrolledFactors: m.MaxEnrolledFactors,
+					MaxVerifiedFactors: m.MaxVerifiedFactors,
+					ChallengeExpiry:    time.Duration(m.ChallengeExpirySeconds) * time.Second,
+				}
+			}
+			if c := cfg.Auth.Captcha; c != nil && c.Secret != "" {
+				captchaCfg = &auth.CaptchaConfig{Provider: c.Provider, Secret: c.Secret}
+			}
+			if p := cfg.Auth.Password; p != nil && *p != (config.PasswordConfig{}) {
+				passwordCfg = &auth.PasswordPolicy{MinLength: p.MinLength, RequiredCharacters: p.RequiredCharacters}
+			}
+			if r := cfg.Auth.RateLimits; r != nil && *r != (config.RateLimitConfig{}) {
+				rateLimits = &auth.RateLimits{
+					EmailSent:      r.EmailSent,
+					SMSSent:        r.SMSSent,
+					Verify:         r.Verify,
+					TokenRefresh:   r.TokenRefresh,
+					OTP:            r.OTP,
+					AnonymousUsers: r.AnonymousUsers,
+					SSO:            r.SSO,
+					MFA:            r.MFA,
+					Header:         r.Header,
+				}
+			}
+		}
+
+		seedUsers := make([]auth.SeedUser, 0, len(cfg.SeedUsers))
+		for _, u := range cfg.SeedUsers {
+			seedUsers = append(seedUsers, auth.SeedUser{
+				Email:        u.Email,
+				Phone:        u.Phone,
+				Password:     u.Password,
+				Confirmed:    u.Confirmed,
+				UserMetadata: u.UserMetadata,
+				AppMetadata:  u.AppMetadata,
+			})
+		}
+
+		externalProviders := make(map[string]auth.ExternalProvider, len(cfg.ExternalProviders))
+		for name, p := range cfg.ExternalProviders {
+			if p == nil {
+				continue
+			}
+			externalProviders[name] = auth.ExternalProvider{
+				Enabled:     p.Enabled,
+				ClientID:    p.ClientID,
+				Secret:      p.Secret,
+				RedirectURI: p.RedirectURI,
+				URL:         p.URL,
+			}
+		}
+
+		var rpcCfg server.RPCConfig
+		if cfg.RPC != nil {
+			rpcCfg = server.RPCConfig{
+				Schemas:              cfg.RPC.Schemas,
+				Functions:            cfg.RPC.Functions,
+				ServiceRoleFunctions: cfg.RPC.ServiceRoleFunctions,
+			}
+		}
+		var responseCfg server.ResponseConfig
+		if cfg.Response != nil {
+			responseCfg = server.ResponseConfig{
+				OmitNulls:     cfg.Response.OmitNulls,
+				CamelCaseKeys: cfg.Response.CamelCaseKeys,
+			}
+		}
+
+		var restCfg server.RESTConfig
+		if cfg.REST != nil {
+			if v := cfg.REST.DefaultVersion; v != 0 && v != server.RESTv1 && v != server.RESTv2 {
+				return fmt.Errorf("invalid REST default version %d: use 1 or 2", v)
+			}
+			sunset, err := cfg.REST.Sunset()
+			if err != nil {
+				return err
+			}
+			if c := cfg.REST.IdentifierCase; c != "" && !slices.Contains(config.IdentifierCases, c) {
+				return fmt.Errorf("invalid REST identifier case %q (use %s)", c, strings.Join(config.IdentifierCases, ", "))
+			}
+			if cfg.REST.MaxFieldSize < 0 {
+				return fmt.Errorf("invalid REST max field size %d: must not be negative", cfg.REST.MaxFieldSize)
+			}
+			restCfg = server.RESTConfig{
+				DefaultVersion:  cfg.REST.DefaultVersion,
+				V1Sunset:        sunset,
+				IdentifierCase:  server.IdentifierCase(cfg.REST.IdentifierCase),
+				MaxFieldSize:    cfg.REST.MaxFieldSize,
+				FieldSizeLimits: cfg.REST.FieldSizeLimits,
+				CacheControl:    cfg.REST.CacheControl,
+			}
+		}
+
+		var pgLimits, authLimits limits.Limits
+		var pgSharedBuffers string
+		if cfg.Limits != nil {
+			pgLimits = limits.Limits{MemoryMB: cfg.Limits.PGMemoryMB, CPUs: cfg.Limits.PGCPUs}
+			authLimits = limits.Limits{MemoryMB: cfg.Limits.AuthMemoryMB, CPUs: cfg.Limits.AuthCPUs}
+			pgSharedBuffers = cfg.Limits.PGSharedBuffers
+		}
+
+		var chaosCfg *server.ChaosConfig
+		if c := cfg.Chaos; c != nil && c.Enabled {
+			seed := c.Seed
+			if seed == 0 {
+				seed = 1
+			}
+			chaosCfg = &server.ChaosConfig{
+				Seed:   seed,
+				Faults: chaos.Faults{DBFailPercent: c.DBFailPercent, AuthDelayMS: c.AuthDelayMS},
+			}
+		}
+
+		var watchdogCfg watchdog.Config
+		if w := cfg.Watchdog; w != nil {
+			watchdogCfg = watchdog.Config{
+				Interval:        time.Duration(w.IntervalSeconds) * time.Second,
+				WarnFreePercent: w.WarnFreePercent,
+				MinFreeMB:       w.MinFreeMB,
+				RefuseWrites:    w.RefuseWrites,
+				WALWarnMB:       w.WALWarnMB,
+			}
+		}
+
+		var tlsCfg *server.TLSConfig
+		if t := cfg.TLS; t.Enabled() {
+			tlsCfg = &server.TLSConfig{
+				CertFile:         t.CertFile,
+				KeyFile:          t.KeyFile,
+				SelfSigned:       t.SelfSigned,
+				SelfSignedHosts:  urlHosts(cfg.PublicURL, cfg.SiteURL),
+				ACMEDomains:      t.ACMEDomains,
+				ACMEEmail:        t.ACMEEmail,
+				ACMEDirectoryURL: t.ACMEDirectoryURL,
+				HTTPPort:         t.HTTPPort,
+			}
+		}
+
+		var historyCfg history.Config
+		if h := cfg.History; h != nil {
+			historyCfg = history.Config{
+				Tables:    h.Tables,
+				Retention: time.Duration(h.RetentionDays) * 24 * time.Hour,
+			}
+			restCfg.TimeTravel = h.TimeTravel
+		}
+
+		routeRules := make([]rules.Rule, 0, len(cfg.Rules))
+		for _, r := range cfg.Rules {
+			rule := rules.Rule{
+				Path:         r.Path,
+				Methods:      r.Methods,
+				Roles:        r.Roles,
+				Deny:         r.Deny,
+				RequireRoles: r.RequireRoles,
+				Headers:      r.Headers,
+			}
+			if c := r.CORS; c != nil {
+				rule.CORS = &rules.CORS{
+					AllowedOrigins:   c.AllowedOrigins,
+					AllowedMethods:   c.AllowedMethods,
+					AllowedHeaders:   c.AllowedHeaders,
+					AllowCredentials: c.AllowCredentials,
+					MaxAge:           time.Duration(c.MaxAgeSeconds) * time.Second,
+				}
+			}
+			if l := r.RateLimit; l != nil {
+				rule.RateLimit = &rules.RateLimit{
+					Requests: l.Requests,
+					Window:   time.Duration(l.WindowSeconds) * time.Second,
+					Per:      l.Per,
+				}
+			}
+			routeRules = append(routeRules, rule)
+		}
+
+		var statusCfg *status.Config
+		if st := cfg.Status; st != nil && st.Enabled {
+			statusCfg = &status.Config{
+				Title:    st.Title,
+				Interval: time.Duration(st.IntervalSeconds) * time.Second,
+			}
+		}
+
+		var auditLogCfg *auditlog.Config
+		if a := cfg.AuditLog; a != nil && a.Enabled {
+			auditLogCfg = &auditlog.Config{
+				Retention: time.Duration(a.RetentionDays) * 24 * time.Hour,
+			}
+		}
+
+		var restRecord int
+		if rr := cfg.RESTRecord; rr != nil && rr.Enabled {
+			restRecord = rr.Size
+			if restRecord == 0 {
+				restRecord = recorder.DefaultSize
+			}
+		}
+
+		var usageCfg *usage.Config
+		if u := cfg.Usage; u != nil && u.Enabled {
+			usageCfg = &usage.Config{
+				Period: u.Period,
+				PerKey: u.PerKey,
+				Soft:   usageLimits(u.Soft),
+				Hard:   usageLimits(u.Hard),
+			}
+		}
+
+		// Migrations are applied at startup with auto_apply, and through
+		// the management API either way
+		managedMigrationsDir := migrate.FindDir()
+		if m := cfg.Migrations; m != nil && m.Dir != "" {
+			managedMigrationsDir = m.Dir
+		}
+		var migrationsDir string
+		if m := cfg.Migrations; m != nil && m.AutoApply {
+			migrationsDir = managedMigrationsDir
+		}
+
+		var tracingCfg *tracing.Config
+		if t := cfg.Tracing; t != nil && t.Endpoint != "" {
+			tracingCfg = &tracing.Config{
+				Endpoint:    t.Endpoint,
+				ServiceName: t.ServiceName,
+				SampleRatio: t.SampleRatio,
+			}
+		}
+
+		var apiRateLimits []rules.RateLimit
+		if l := cfg.RateLimit; l != nil {
+			window := time.Duration(l.WindowSeconds) * time.Second
+			if l.PerIP > 0 {
+				apiRateLimits = append(apiRateLimits, rules.RateLimit{Requests: l.PerIP, Window: window, Per: rules.PerIP})
+			}
+			if l.PerKey > 0 {
+				apiRateLimits = append(apiRateLimits, rules.RateLimit{Requests: l.PerKey, Window: window, Per: rules.PerKey})
 			}
 		}
 
+		var authFailureThreshold int
+		var authFailureWindow time.Duration
+		if n := cfg.Notifications; n != nil {
+			authFailureThreshold = n.AuthFailureThreshold
+			authFailureWindow = time.Duration(n.AuthFailureWindowSeconds) * time.Second
+		}
+
 		// Create server configuration
 		srvCfg := server.Config{
 			Host:           cfg.Host,
@@ -97,12 +507,70 @@ The server orchestrates all components and provides a unified API endpoint.`,
 			DataDir:        cfg.DataDir,
 			JWTSecret:      cfg.JWTSecret,
 			SiteURL:        cfg.SiteURL,
+			PublicURL:      cfg.PublicURL,
 			PGUsername:     cfg.PGUsername,
 			PGPassword:     cfg.PGPassword,
 			PGDatabase:     cfg.PGDatabase,
+			DatabaseURL:    cfg.DatabaseURL,
+			PGVersion:      cfg.PGVersion,
+			PGSocketDir:    cfg.PGSocketDir,
+			PGMinConns:     cfg.PGMinConns,
+			PGMaxConns:     cfg.PGMaxConns,
+			PGRolePools:    cfg.PGRolePools,
+			PGLocale:       cfg.PGLocale,
+			PGICULocale:    cfg.PGICULocale,
+			PGTimezone:     cfg.PGTimezone,
+			Extensions:     cfg.Extensions,
+			GoTrueBinary:   cfg.GoTrueBinary,
+			PREST:          cfg.PREST,
+			Docs:           cfg.Docs,
+			AuthMode:       cfg.AuthMode,
 			AnonKey:        cfg.AnonKey,
 			ServiceRoleKey: cfg.ServiceRoleKey,
 			Email:          emailCfg,
+			SeedUsers:      seedUsers,
+			External:       externalProviders,
+			SMS:            smsCfg,
+			MFA:            mfaCfg,
+			Captcha:        captchaCfg,
+			Password:       passwordCfg,
+			RateLimits:     rateLimits,
+			RPC:            rpcCfg,
+			Response:       responseCfg,
+			REST:           restCfg,
+
+			PGLimits:        pgLimits,
+			PGSharedBuffers: pgSharedBuffers,
+			AuthLimits:      authLimits,
+
+			TLS:      tlsCfg,
+			Watchdog: watchdogCfg,
+			History:  historyCfg,
+			Status:   statusCfg,
+			Chaos:    chaosCfg,
+			Rules:    routeRules,
+			Tracing:  tracingCfg,
+
+			AccessLog: cfg.AccessLog,
+			AuditLog:  auditLogCfg,
+
+			RESTRecord: restRecord,
+			Usage:      usageCfg,
+
+			MigrationsDir:        migrationsDir,
+			ManagedMigrationsDir: managedMigrationsDir,
+
+			APIRateLimits: apiRateLimits,
+
+			Notifier:             newNotifier(cfg),
+			AuthFailureThreshold: authFailureThreshold,
+			AuthFailureWindow:    authFailureWindow,
+
+			Ephemeral: cfg.Ephemeral,
+
+			Deterministic:     cfg.Deterministic,
+			DeterministicSeed: cfg.DeterministicSeed,
+			ProjectRef:        cfg.ProjectRef,
 		}
 
 		// Create and start server
@@ -117,6 +585,18 @@ The server orchestrates all components and provides a unified API endpoint.`,
 
 // applyFlagOverrides applies command-line flag values to the config
 // Flags take precedence over both file and environment variable values
+// urlHosts returns the host names of URLs, so a self-signed certificate
+// also covers the addresses other devices use
+func urlHosts(urls ...string) []string {
+	var hosts []string
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return hosts
+}
+
 func applyFlagOverrides(cfg *config.Config) {
 	if flagHost != "" {
 		cfg.Host = flagHost
@@ -127,15 +607,94 @@ func applyFlagOverrides(cfg *config.Config) {
 	if flagPgPort != 0 {
 		cfg.PGPort = flagPgPort
 	}
+	if flagPgSocketDir != "" {
+		cfg.PGSocketDir = flagPgSocketDir
+	}
 	if flagDataDir != "" {
 		cfg.DataDir = flagDataDir
 	}
+	if flagDatabaseURL != "" {
+		cfg.DatabaseURL = flagDatabaseURL
+	}
 	if flagJwtSecret != "" {
 		cfg.JWTSecret = flagJwtSecret
 	}
 	if flagSiteURL != "" {
 		cfg.SiteURL = flagSiteURL
 	}
+	if flagPublicURL != "" {
+		cfg.PublicURL = flagPublicURL
+	}
+	if flagGoTrueBinary != "" {
+		cfg.GoTrueBinary = flagGoTrueBinary
+	}
+	if flagPREST {
+		cfg.PREST = true
+	}
+	if flagDocs {
+		cfg.Docs = true
+	}
+	if flagStatus {
+		if cfg.Status == nil {
+			cfg.Status = &config.StatusConfig{}
+		}
+		cfg.Status.Enabled = true
+	}
+	if flagAccessLog {
+		cfg.AccessLog = true
+	}
+	if flagAuditLog {
+		if cfg.AuditLog == nil {
+			cfg.AuditLog = &config.AuditLogConfig{}
+		}
+		cfg.AuditLog.Enabled = true
+	}
+	if flagRESTRecord {
+		if cfg.RESTRecord == nil {
+			cfg.RESTRecord = &config.RESTRecordConfig{}
+		}
+		cfg.RESTRecord.Enabled = true
+	}
+	if flagUsage {
+		if cfg.Usage == nil {
+			cfg.Usage = &config.UsageConfig{}
+		}
+		cfg.Usage.Enabled = true
+	}
+	if flagMigrate {
+		if cfg.Migrations == nil {
+			cfg.Migrations = &config.MigrationsConfig{}
+		}
+		cfg.Migrations.AutoApply = true
+	}
+	if flagTracingEndpoint != "" {
+		if cfg.Tracing == nil {
+			cfg.Tracing = &config.TracingConfig{}
+		}
+		cfg.Tracing.Endpoint = flagTracingEndpoint
+	}
+
+	// TLS overrides. A certificate source given by flag replaces the
+	// configured one.
+	if flagTLSCert != "" || flagTLSKey != "" || flagTLSSelfSigned || len(flagTLSACMEDomains) > 0 || flagTLSACMEEmail != "" || flagTLSHTTPPort != 0 {
+		if cfg.TLS == nil {
+			cfg.TLS = &config.TLSConfig{}
+		}
+		if flagTLSCert != "" || flagTLSKey != "" || flagTLSSelfSigned || len(flagTLSACMEDomains) > 0 {
+			cfg.TLS.CertFile, cfg.TLS.KeyFile = flagTLSCert, flagTLSKey
+			cfg.TLS.SelfSigned = flagTLSSelfSigned
+			cfg.TLS.ACMEDomains = flagTLSACMEDomains
+		}
+		if flagTLSACMEEmail != "" {
+			cfg.TLS.ACMEEmail = flagTLSACMEEmail
+		}
+		if flagTLSHTTPPort != 0 {
+			cfg.TLS.HTTPPort = flagTLSHTTPPort
+		}
+	}
+	if flagAuthMode != "" {
+		cfg.AuthMode = flagAuthMode
+	}
 	if flagPgUsername != "" {
 		cfg.PGUsername = flagPgUsername
 	}
@@ -145,6 +704,21 @@ func applyFlagOverrides(cfg *config.Config) {
 	if flagPgDatabase != "" {
 		cfg.PGDatabase = flagPgDatabase
 	}
+	if flagPgMinConns != 0 {
+		cfg.PGMinConns = flagPgMinConns
+	}
+	if flagPgMaxConns != 0 {
+		cfg.PGMaxConns = flagPgMaxConns
+	}
+	if flagPgLocale != "" {
+		cfg.PGLocale = flagPgLocale
+	}
+	if flagPgICULocale != "" {
+		cfg.PGICULocale = flagPgICULocale
+	}
+	if flagPgTimezone != "" {
+		cfg.PGTimezone = flagPgTimezone
+	}
 	if flagAnonKey != "" {
 		cfg.AnonKey = flagAnonKey
 	}
@@ -194,9 +768,122 @@ func applyFlagOverrides(cfg *config.Config) {
 	if flagCapturePort != 0 {
 		cfg.Email.CapturePort = flagCapturePort
 	}
+	if flagCaptureSink != "" {
+		cfg.Email.CaptureSink = flagCaptureSink
+	}
+	if flagCaptureDir != "" {
+		cfg.Email.CaptureDir = flagCaptureDir
+	}
+	if flagCaptureWebhookURL != "" {
+		cfg.Email.CaptureWebhookURL = flagCaptureWebhookURL
+	}
+	if flagCaptureWebhookSecret != "" {
+		cfg.Email.CaptureWebhookSecret = flagCaptureWebhookSecret
+	}
+
+	// SMS overrides
+	if cfg.SMS == nil {
+		cfg.SMS = &config.SMSConfig{}
+	}
+	if flagSMSProvider != "" {
+		cfg.SMS.Provider = flagSMSProvider
+	}
+	if flagSMSTwilioAccountSID != "" {
+		cfg.SMS.TwilioAccountSID = flagSMSTwilioAccountSID
+	}
+	if flagSMSTwilioAuthToken != "" {
+		cfg.SMS.TwilioAuthToken = flagSMSTwilioAuthToken
+	}
+	if flagSMSTwilioMessageServiceSID != "" {
+		cfg.SMS.TwilioMessageServiceSID = flagSMSTwilioMessageServiceSID
+	}
+	if flagSMSMessageBirdAccessKey != "" {
+		cfg.SMS.MessageBirdAccessKey = flagSMSMessageBirdAccessKey
+	}
+	if flagSMSMessageBirdOriginator != "" {
+		cfg.SMS.MessageBirdOriginator = flagSMSMessageBirdOriginator
+	}
+	if flagSMSAutoconfirm {
+		cfg.SMS.Autoconfirm = true
+	}
+	if flagSMSCaptureMode {
+		cfg.SMS.CaptureMode = true
+	}
+
+	// REST API version and identifier overrides
+	if flagRESTDefaultVersion != 0 || flagRESTIdentifierCase != "" || flagRESTMaxFieldSize != 0 {
+		if cfg.REST == nil {
+			cfg.REST = &config.RESTConfig{}
+		}
+		if flagRESTDefaultVersion != 0 {
+			cfg.REST.DefaultVersion = flagRESTDefaultVersion
+		}
+		if flagRESTIdentifierCase != "" {
+			cfg.REST.IdentifierCase = flagRESTIdentifierCase
+		}
+		if flagRESTMaxFieldSize != 0 {
+			cfg.REST.MaxFieldSize = flagRESTMaxFieldSize
+		}
+	}
+
+	// Watchdog overrides
+	if flagWatchdogMinFreeMB != 0 || flagWatchdogRefuseWrites {
+		if cfg.Watchdog == nil {
+			cfg.Watchdog = &config.WatchdogConfig{}
+		}
+		if flagWatchdogMinFreeMB != 0 {
+			cfg.Watchdog.MinFreeMB = flagWatchdogMinFreeMB
+		}
+		if flagWatchdogRefuseWrites {
+			cfg.Watchdog.RefuseWrites = true
+		}
+	}
+
+	// Chaos overrides
+	if flagChaos || flagChaosSeed != 0 {
+		if cfg.Chaos == nil {
+			cfg.Chaos = &config.ChaosConfig{}
+		}
+		if flagChaos {
+			cfg.Chaos.Enabled = true
+		}
+		if flagChaosSeed != 0 {
+			cfg.Chaos.Seed = flagChaosSeed
+		}
+	}
+
+	// Ephemeral mode override
+	if flagEphemeral {
+		cfg.Ephemeral = true
+	}
+
+	// Deterministic mode overrides
+	if flagDeterministic {
+		cfg.Deterministic = true
+	}
+	if flagDeterministicSeed != "" {
+		cfg.DeterministicSeed = flagDeterministicSeed
+	}
+	if flagProjectRef != "" {
+		cfg.ProjectRef = flagProjectRef
+	}
 }
 
 // hasEmailConfig checks if any email configuration is set
+// usageLimits converts configured usage limits; nil is unlimited.
+func usageLimits(l *config.UsageLimits) usage.Limits {
+	if l == nil {
+		return usage.Limits{}
+	}
+	return usage.Limits{
+		Requests:     l.Requests,
+		RowsRead:     l.RowsRead,
+		RowsWritten:  l.RowsWritten,
+		EgressBytes:  l.EgressBytes,
+		StorageBytes: l.StorageBytes,
+	}
+}
+
 func hasEmailConfig(e *config.EmailConfig) bool {
 	return e.SMTPHost != "" || e.SMTPPort != 0 || e.SMTPUser != "" ||
 		e.SMTPPass != "" || e.SMTPAdminEmail != "" ||
@@ -205,6 +892,12 @@ func hasEmailConfig(e *config.EmailConfig) bool {
 		e.MailerAutoconfirm || e.CaptureMode
 }
 
+// hasSMSConfig checks if phone auth is configured
+func hasSMSConfig(s *config.SMSConfig) bool {
+	return s.Provider != "" || s.TwilioAccountSID != "" || s.MessageBirdAccessKey != "" ||
+		s.Autoconfirm || s.CaptureMode
+}
+
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
@@ -214,16 +907,41 @@ func init() {
 
 	// Database configuration
 	serveCmd.Flags().StringVar(&flagDataDir, "data-dir", "", "Data directory for PostgreSQL (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagDatabaseURL, "database-url", "", "Serve from the PostgreSQL at this postgres:// URL instead of the embedded one (overrides config file and env vars)")
 	serveCmd.Flags().Uint16Var(&flagPgPort, "pg-port", 0, "PostgreSQL port (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagPgSocketDir, "pg-socket-dir", "", "Run PostgreSQL on a unix socket in this directory, with no TCP port")
 	serveCmd.Flags().StringVar(&flagPgUsername, "pg-username", "", "PostgreSQL username (overrides config file and env vars)")
 	serveCmd.Flags().StringVar(&flagPgPassword, "pg-password", "", "PostgreSQL password (overrides config file and env vars)")
 	serveCmd.Flags().StringVar(&flagPgDatabase, "pg-database", "", "PostgreSQL database name (overrides config file and env vars)")
+	serveCmd.Flags().Int32Var(&flagPgMinConns, "pg-min-conns", 0, "Idle connections kept in the database pool (overrides config file and env vars)")
+	serveCmd.Flags().Int32Var(&flagPgMaxConns, "pg-max-conns", 0, "Maximum connections of the database pools together (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagPgLocale, "pg-locale", "", "Locale for a new data directory, e.g. de_DE.UTF-8 (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagPgICULocale, "pg-icu-locale", "", "ICU collation for a new data directory, e.g. de-DE (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagPgTimezone, "pg-timezone", "", "PostgreSQL time zone, e.g. Europe/Berlin (overrides config file and env vars)")
 
 	// Auth configuration
 	serveCmd.Flags().StringVar(&flagJwtSecret, "jwt-secret", "", "JWT secret for signing tokens - legacy mode (overrides config file and env vars)")
 	serveCmd.Flags().StringVar(&flagSiteURL, "site-url", "", "Site URL for auth callbacks (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagPublicURL, "public-url", "", "URL other devices reach supalite at, used in auth email links (\"lan\" for this machine's LAN address)")
 	serveCmd.Flags().StringVar(&flagAnonKey, "anon-key", "", "Anonymous/public key (overrides config file and env vars)")
 	serveCmd.Flags().StringVar(&flagServiceRoleKey, "service-role-key", "", "Service role key (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagTLSCert, "tls-cert", "", "Serve HTTPS with this PEM certificate (with --tls-key)")
+	serveCmd.Flags().StringVar(&flagTLSKey, "tls-key", "", "PEM private key for --tls-cert")
+	serveCmd.Flags().BoolVar(&flagTLSSelfSigned, "tls-self-signed", false, "Serve HTTPS with a self-signed certificate generated into the data directory")
+	serveCmd.Flags().StringSliceVar(&flagTLSACMEDomains, "tls-acme-domains", nil, "Serve HTTPS with Let's Encrypt certificates for these domains")
+	serveCmd.Flags().StringVar(&flagTLSACMEEmail, "tls-acme-email", "", "Contact email for the ACME account")
+	serveCmd.Flags().IntVar(&flagTLSHTTPPort, "tls-http-port", 0, "Also serve plain HTTP on this port for ACME challenges and HTTPS redirects (e.g. 80)")
+	serveCmd.Flags().BoolVar(&flagPREST, "prest", false, "Serve pREST's API at /prest for the service_role key (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagDocs, "docs", false, "Serve API docs with example requests for each table at /docs (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagStatus, "status", false, "Serve a public status page at /status (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagAccessLog, "access-log", false, "Log a line for each HTTP request (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagAuditLog, "audit-log", false, "Record writes made through the REST API in audit.api_log (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagRESTRecord, "rest-record", false, "Record recent REST requests with the SQL they ran, shown in the dashboard (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagUsage, "usage", false, "Count usage per API key and enforce configured quotas (overrides config file and env vars)")
+	serveCmd.Flags().BoolVar(&flagMigrate, "migrate", false, "Apply pending SQL migrations before serving (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagTracingEndpoint, "tracing-endpoint", "", "Export OpenTelemetry traces to this OTLP/HTTP collector URL (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagGoTrueBinary, "gotrue-binary", "", "GoTrue binary to run instead of finding or downloading one (overrides config file and env vars)")
+	serveCmd.Flags().StringVar(&flagAuthMode, "auth-mode", "", "Auth implementation: \"gotrue\" (default) or \"native\" to serve core auth endpoints in process without GoTrue")
 
 	// Email configuration (all optional - overrides config file and env vars)
 	serveCmd.Flags().StringVar(&flagSmtpHost, "smtp-host", "", "SMTP server hostname")
@@ -240,4 +958,41 @@ func init() {
 	// Email capture mode (for development)
 	serveCmd.Flags().BoolVar(&flagCaptureMode, "capture-mode", false, "Enable email capture mode (captures emails to database instead of sending)")
 	serveCmd.Flags().IntVar(&flagCapturePort, "capture-port", 0, "Port for mail capture SMTP server (default: 1025)")
+	serveCmd.Flags().StringVar(&flagCaptureSink, "capture-sink", "", "Where captured emails go: database, maildir, or webhook (default: database)")
+	serveCmd.Flags().StringVar(&flagCaptureDir, "capture-dir", "", "Maildir for the maildir capture sink (default: <data-dir>/mail)")
+	serveCmd.Flags().StringVar(&flagCaptureWebhookURL, "capture-webhook-url", "", "URL the webhook capture sink POSTs emails to")
+	serveCmd.Flags().StringVar(&flagCaptureWebhookSecret, "capture-webhook-secret", "", "Secret (whsec_...) the webhook capture sink signs requests with")
+
+	// REST API versioning
+	serveCmd.Flags().StringVar(&flagRESTIdentifierCase, "rest-identifier-case", "", "How REST table and column names map to identifiers: exact (default), fold, or snake_case")
+	serveCmd.Flags().IntVar(&flagRESTMaxFieldSize, "rest-max-field-size", 0, "Return REST text and bytea values longer than this many characters or bytes as truncation markers (default: no limit)")
+	serveCmd.Flags().IntVar(&flagRESTDefaultVersion, "rest-default-version", 0, "REST API version served at /rest: 1 (default) or 2 (/rest/v1 and /rest/v2 are always available)")
+	serveCmd.Flags().IntVar(&flagWatchdogMinFreeMB, "watchdog-min-free-mb", 0, "Free disk in MB below which the disk counts as exhausted (default: 256)")
+	serveCmd.Flags().BoolVar(&flagChaos, "chaos", false, "Enable fault injection for testing (see 'supalite chaos'); never use for real traffic")
+	serveCmd.Flags().Int64Var(&flagChaosSeed, "chaos-seed", 0, "Seed for which requests fail under --chaos (default: 1)")
+	serveCmd.Flags().BoolVar(&flagWatchdogRefuseWrites, "watchdog-refuse-writes", false, "Answer writes with 507 Insufficient Storage while free disk is below --watchdog-min-free-mb")
+
+	// Phone auth configuration (all optional - overrides config file and env vars)
+	serveCmd.Flags().StringVar(&flagSMSProvider, "sms-provider", "", "SMS provider: twilio, twilio_verify, or messagebird")
+	serveCmd.Flags().StringVar(&flagSMSTwilioAccountSID, "sms-twilio-account-sid", "", "Twilio account SID")
+	serveCmd.Flags().StringVar(&flagSMSTwilioAuthToken, "sms-twilio-auth-token", "", "Twilio auth token")
+	serveCmd.Flags().StringVar(&flagSMSTwilioMessageServiceSID, "sms-twilio-message-service-sid", "", "Twilio messaging service SID (or Verify service SID for twilio_verify)")
+	serveCmd.Flags().StringVar(&flagSMSMessageBirdAccessKey, "sms-messagebird-access-key", "", "MessageBird access key")
+	serveCmd.Flags().StringVar(&flagSMSMessageBirdOriginator, "sms-messagebird-originator", "", "MessageBird sender name or number")
+	serveCmd.Flags().BoolVar(&flagSMSAutoconfirm, "sms-autoconfirm", false, "Skip phone confirmation for new users")
+	serveCmd.Flags().BoolVar(&flagSMSCaptureMode, "sms-capture-mode", false, "Enable SMS capture mode (stores OTP messages in public.captured_sms instead of sending)")
+
+	// Seed users (for tests and demos)
+	serveCmd.Flags().StringArrayVar(&flagSeedUsers, "seed-user", nil, "Create a confirmed auth user at startup, as email:password (repeatable)")
+
+	// OAuth providers
+	serveCmd.Flags().StringArrayVar(&flagExternalProviders, "external-provider", nil, "Enable an OAuth provider, as name:client_id:secret[:redirect_uri] (repeatable)")
+
+	// Ephemeral mode (for tests)
+	serveCmd.Flags().BoolVar(&flagEphemeral, "ephemeral", false, "Keep all data in a temporary directory (on tmpfs when possible) that is deleted on exit, with fsync off")
+
+	// Deterministic mode (for CI)
+	serveCmd.Flags().BoolVar(&flagDeterministic, "deterministic", false, "Reproducible mode: keys derived from --deterministic-seed, no downloads, email autoconfirm")
+	serveCmd.Flags().StringVar(&flagDeterministicSeed, "deterministic-seed", "", "Seed for deterministic keys (default: \"supalite\")")
+	serveCmd.Flags().StringVar(&flagProjectRef, "project-ref", "", "Project ref for deterministic keys (default: derived from seed)")
 }
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var timestampsCmd = &cobra.Command{
+	Use:   "timestamps",
+	Short: "Manage created_at/updated_at columns",
+	Long:  `Manage the created_at/updated_at convention on tables.`,
+}
+
+var timestampsAddCmd = &cobra.Command{
+	Use:   "add <table> [table...]",
+	Short: "Add created_at/updated_at columns to tables",
+	Long: `Add created_at and updated_at columns to each table, with a trigger that
+keeps updated_at current on every UPDATE.
+
+Tables may be schema-qualified (app.todos) and default to public. Existing
+columns are kept, so running it twice is safe. This calls the
+extensions.add_timestamps SQL function, which can also be used directly:
+
+  SELECT extensions.add_timestamps('todos');`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runTimestampsAdd,
+}
+
+func init() {
+	rootCmd.AddCommand(timestampsCmd)
+	timestampsCmd.AddCommand(timestampsAddCmd)
+}
+
+// runTimestampsAdd adds the timestamp columns and trigger to each table
+func runTimestampsAdd(cmd *cobra.Command, args []string) error {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Connect to database
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, table := range args {
+		// The regclass cast resolves the name like SQL would, so quoted
+		// names ("My Table") work too
+		_, err := conn.Exec(ctx, "SELECT extensions.add_timestamps($1::regclass)", table)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42883" {
+			return fmt.Errorf("extensions.add_timestamps is not installed; start ./supalite serve once to install it")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add timestamps to %s: %w", table, err)
+		}
+		fmt.Printf("✓ %s: created_at, updated_at\n", table)
+	}
+
+	return nil
+}
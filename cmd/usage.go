@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagUsageURL  string
+	flagUsageJSON bool
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show this period's usage per API key",
+	Long: `Show what each API key used this period - requests, rows read and
+written, and response bytes - with the project's totals, stored objects'
+size, and the configured quotas.
+
+Calls the /admin/v1/usage API of a server started with --usage, with the
+service_role key. Keys are shown by ID, a short hash of the key; every
+period's counts are also in the admin.api_usage table.`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+
+	usageCmd.Flags().StringVar(&flagUsageURL, "url", "", "URL of the running server (default: http://localhost:<port>)")
+	usageCmd.Flags().BoolVar(&flagUsageJSON, "json", false, "Print the report as JSON")
+}
+
+// runUsage prints the usage report of the running server
+func runUsage(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	serviceKey, err := localServiceKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	baseURL := strings.TrimSuffix(flagUsageURL, "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/admin/v1/usage", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+serviceKey)
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the server at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("usage accounting is not enabled on the server at %s - start it with --usage", baseURL)
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var report usage.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return fmt.Errorf("invalid response from server: %w", err)
+	}
+
+	if flagUsageJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printUsage(&report)
+	return nil
+}
+
+// printUsage prints the project's usage against its quotas, then each key's
+func printUsage(report *usage.Report) {
+	fmt.Printf("Period: %s to %s (UTC)\n\n",
+		report.PeriodStart.UTC().Format("2006-01-02 15:04"), report.PeriodEnd.UTC().Format("2006-01-02 15:04"))
+
+	scope := "project"
+	if report.PerKey {
+		scope = "each key"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "METRIC\tUSED\tSOFT\tHARD (%s)\n", scope)
+	for _, row := range []struct {
+		metric     string
+		used       int64
+		soft, hard int64
+	}{
+		{usage.MetricRequests, report.Project.Requests, report.Soft.Requests, report.Hard.Requests},
+		{usage.MetricRowsRead, report.Project.RowsRead, report.Soft.RowsRead, report.Hard.RowsRead},
+		{usage.MetricRowsWritten, report.Project.RowsWritten, report.Soft.RowsWritten, report.Hard.RowsWritten},
+		{usage.MetricEgressBytes, report.Project.EgressBytes, report.Soft.EgressBytes, report.Hard.EgressBytes},
+		{usage.MetricStorageBytes, report.StorageBytes, report.Soft.StorageBytes, report.Hard.StorageBytes},
+	} {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", row.metric, row.used, usageLimit(row.soft), usageLimit(row.hard))
+	}
+	w.Flush()
+
+	if len(report.Keys) == 0 {
+		fmt.Println("\nNo requests this period")
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tROLE\tREQUESTS\tROWS READ\tROWS WRITTEN\tEGRESS BYTES")
+	for _, k := range report.Keys {
+		key := k.Key
+		if key == "" {
+			key = "(no valid key)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", key, k.Role, k.Requests, k.RowsRead, k.RowsWritten, k.EgressBytes)
+	}
+	w.Flush()
+}
+
+// usageLimit formats a quota; zero is unlimited
+func usageLimit(limit int64) string {
+	if limit == 0 {
+		return "-"
+	}
+	return fmt.Sprint(limit)
+}
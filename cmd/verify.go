@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/config"
+	"github.com/markb/supalite/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagVerifyURL  string
+	flagVerifyJSON bool
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check a running server's compatibility with the Supabase clients",
+	Long: `Run the requests supabase-js makes for common operations against the
+running server, and report which of them behave as they do on Supabase:
+
+  REST     insert, upsert, select with filters, counts, single rows,
+           update, delete, embedded resources, rpc, row level security
+  Auth     sign-up, sign-in with a password, fetching the user
+  Storage  creating a bucket, uploading and downloading a file
+
+The checks use fixture tables and a function (supalite_verify_*) created in
+the public schema for the run, and a throwaway user and bucket. All of
+them are removed afterwards. Run it on the machine the server runs on,
+since the fixtures are created through a database connection.
+
+The command exits with an error if any check fails, so it can gate a
+release or an upgrade in CI:
+
+  supalite verify --json > compat.json`,
+	Args: cobra.NoArgs,
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVar(&flagVerifyURL, "url", "", "URL of the running server (default: http://localhost:<port>)")
+	verifyCmd.Flags().BoolVar(&flagVerifyJSON, "json", false, "Print the report as JSON")
+}
+
+// runVerify sets up the fixtures, runs the checks, and prints the report
+func runVerify(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	anonKey, err := localAnonKey(cfg)
+	if err != nil {
+		return err
+	}
+	serviceKey, err := localServiceKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	baseURL := strings.TrimSuffix(flagVerifyURL, "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	}
+
+	// Check the server is up first: connecting to the database would
+	// otherwise start a temporary one
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(baseURL + "/health")
+	if err != nil {
+		return fmt.Errorf("failed to reach the server at %s: %w", baseURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the server at %s is not healthy (%s)", baseURL, resp.Status)
+	}
+
+	conn, cleanup, err := admin.ConnectToDatabase(int(cfg.PGPort), cfg.PGUsername, cfg.PGPassword, cfg.PGDatabase, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	if _, err := conn.Exec(ctx, verify.SetupSQL); err != nil {
+		return fmt.Errorf("failed to create the fixtures: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(ctx, verify.TeardownSQL); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to drop the fixtures: %v\n", err)
+		}
+	}()
+
+	report := verify.Run(ctx, verify.Config{
+		URL:        baseURL,
+		AnonKey:    anonKey,
+		ServiceKey: serviceKey,
+		Client:     client,
+	})
+
+	if flagVerifyJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printVerifyReport(report)
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("%d of %d checks did not pass", report.Failed+report.Skipped, len(report.Results))
+	}
+	return nil
+}
+
+// printVerifyReport prints each check under its API, with failures'
+// errors and skipped checks' reasons
+func printVerifyReport(report *verify.Report) {
+	fmt.Printf("Supabase compatibility of %s\n", report.URL)
+
+	api := ""
+	for _, result := range report.Results {
+		if result.API != api {
+			api = result.API
+			fmt.Printf("\n%s\n", strings.ToUpper(api))
+		}
+		switch result.Status {
+		case verify.StatusPass:
+			fmt.Printf("  ✓ %-42s %s\n", result.Name, result.Duration.Round(time.Millisecond))
+		case verify.StatusFail:
+			fmt.Printf("  ✗ %-42s %s\n", result.Name, result.Error)
+		default:
+			fmt.Printf("  - %-42s skipped: %s\n", result.Name, result.Error)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", report.Passed, report.Failed, report.Skipped)
+}
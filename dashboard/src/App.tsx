@@ -2,6 +2,12 @@ import { BrowserRouter as Router, Routes, Route } from 'react-router-dom'
 import LoginPage from './pages/LoginPage'
 import OverviewPage from './pages/OverviewPage'
 import TablesPage from './pages/TablesPage'
+import TypesPage from './pages/TypesPage'
+import UsersPage from './pages/UsersPage'
+import LogsPage from './pages/LogsPage'
+import HistoryPage from './pages/HistoryPage'
+import RequestsPage from './pages/RequestsPage'
+import SQLPage from './pages/SQLPage'
 import ProtectedRoute from './components/ProtectedRoute'
 
 function App() {
@@ -31,6 +37,66 @@ function App() {
             </ProtectedRoute>
           }
         />
+        <Route
+          path="/types"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <TypesPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
+        <Route
+          path="/users"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <UsersPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
+        <Route
+          path="/logs"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <LogsPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
+        <Route
+          path="/history"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <HistoryPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
+        <Route
+          path="/sql"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <SQLPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
+        <Route
+          path="/requests"
+          element={
+            <ProtectedRoute>
+              <div className="min-h-screen bg-gray-50">
+                <RequestsPage />
+              </div>
+            </ProtectedRoute>
+          }
+        />
       </Routes>
     </Router>
   )
@@ -36,6 +36,42 @@ function Header({ userEmail }: HeaderProps) {
               >
                 Tables
               </Link>
+              <Link
+                to="/types"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                Types
+              </Link>
+              <Link
+                to="/users"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                Users
+              </Link>
+              <Link
+                to="/logs"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                Logs
+              </Link>
+              <Link
+                to="/sql"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                SQL
+              </Link>
+              <Link
+                to="/requests"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                Requests
+              </Link>
+              <Link
+                to="/history"
+                className="border-transparent text-gray-500 hover:border-gray-300 hover:text-gray-700 inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium"
+              >
+                History
+              </Link>
             </div>
           </div>
           <div className="flex items-center">
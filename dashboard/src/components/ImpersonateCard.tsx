@@ -0,0 +1,67 @@
+import { useState } from 'react'
+import { api } from '../lib/api'
+import ApiKeyCard from './ApiKeyCard'
+
+interface ImpersonationResponse {
+  access_token: string
+  expires_at: string
+  user_id: string
+  email: string
+  role: string
+}
+
+function ImpersonateCard() {
+  const [email, setEmail] = useState('')
+  const [result, setResult] = useState<ImpersonationResponse | null>(null)
+  const [error, setError] = useState('')
+  const [loading, setLoading] = useState(false)
+
+  const impersonate = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError('')
+    setResult(null)
+    setLoading(true)
+    try {
+      setResult(await api.impersonateUser(email))
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to impersonate user')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  return (
+    <div className="bg-white overflow-hidden shadow rounded-lg">
+      <div className="p-5">
+        <form onSubmit={impersonate} className="flex items-center gap-2">
+          <input
+            type="email"
+            required
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+            placeholder="user@example.com"
+            className="flex-1 border border-gray-300 rounded px-3 py-1.5 text-sm focus:outline-none focus:ring-2 focus:ring-indigo-500"
+          />
+          <button
+            type="submit"
+            disabled={loading}
+            className="inline-flex items-center px-3 py-1.5 border border-transparent text-sm font-medium rounded text-white bg-indigo-600 hover:bg-indigo-700 disabled:opacity-50"
+          >
+            {loading ? 'Minting...' : 'Impersonate'}
+          </button>
+        </form>
+        {error && <p className="mt-2 text-sm text-red-600">{error}</p>}
+        {result && (
+          <div className="mt-4">
+            <ApiKeyCard label={`Access token for ${result.email} (${result.role})`} value={result.access_token} />
+            <p className="mt-2 text-xs text-gray-500">
+              Expires {new Date(result.expires_at).toLocaleString()}. Use as the Authorization bearer token.
+            </p>
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}
+
+export default ImpersonateCard
@@ -0,0 +1,165 @@
+import { useState } from 'react'
+import { api } from '../lib/api'
+
+interface PolicyResult {
+  name: string
+  command: string
+  roles: string[]
+  using?: string
+  with_check?: string
+  applies: boolean
+  matched_rows?: number
+  error?: string
+}
+
+interface SimulationResponse {
+  role: string
+  rls_enabled: boolean
+  permitted: boolean
+  reason: string
+  rows_total: number
+  rows_visible?: number
+  rows_affected?: number
+  policies: PolicyResult[]
+  error?: string
+}
+
+const operations = ['select', 'insert', 'update', 'delete']
+
+function parseJSON(label: string, value: string) {
+  if (!value.trim()) return undefined
+  try {
+    return JSON.parse(value)
+  } catch {
+    throw new Error(`${label} must be valid JSON`)
+  }
+}
+
+function PolicyTestCard() {
+  const [table, setTable] = useState('')
+  const [operation, setOperation] = useState('select')
+  const [token, setToken] = useState('')
+  const [role, setRole] = useState('authenticated')
+  const [row, setRow] = useState('')
+  const [match, setMatch] = useState('')
+  const [result, setResult] = useState<SimulationResponse | null>(null)
+  const [error, setError] = useState('')
+  const [loading, setLoading] = useState(false)
+
+  const simulate = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError('')
+    setResult(null)
+    setLoading(true)
+    try {
+      setResult(
+        await api.simulateRls({
+          table,
+          operation,
+          token: token || undefined,
+          role: token ? undefined : role,
+          row: parseJSON('Row', row),
+          match: parseJSON('Match', match),
+        })
+      )
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to simulate policy')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const inputClass =
+    'border border-gray-300 rounded px-3 py-1.5 text-sm focus:outline-none focus:ring-2 focus:ring-indigo-500'
+
+  return (
+    <div className="bg-white overflow-hidden shadow rounded-lg">
+      <div className="p-5">
+        <form onSubmit={simulate} className="space-y-2">
+          <div className="flex items-center gap-2">
+            <input
+              required
+              value={table}
+              onChange={(e) => setTable(e.target.value)}
+              placeholder="table"
+              className={`flex-1 ${inputClass}`}
+            />
+            <select value={operation} onChange={(e) => setOperation(e.target.value)} className={inputClass}>
+              {operations.map((op) => (
+                <option key={op} value={op}>
+                  {op}
+                </option>
+              ))}
+            </select>
+            <select
+              value={role}
+              onChange={(e) => setRole(e.target.value)}
+              disabled={!!token}
+              className={inputClass}
+            >
+              <option value="anon">anon</option>
+              <option value="authenticated">authenticated</option>
+              <option value="service_role">service_role</option>
+            </select>
+          </div>
+          <input
+            value={token}
+            onChange={(e) => setToken(e.target.value)}
+            placeholder="Access token (optional, overrides role)"
+            className={`w-full font-mono ${inputClass}`}
+          />
+          {(operation === 'insert' || operation === 'update') && (
+            <input
+              value={row}
+              onChange={(e) => setRow(e.target.value)}
+              placeholder='Row JSON, e.g. {"title": "hello"}'
+              className={`w-full font-mono ${inputClass}`}
+            />
+          )}
+          {operation !== 'insert' && (
+            <input
+              value={match}
+              onChange={(e) => setMatch(e.target.value)}
+              placeholder='Match JSON, e.g. {"id": 1}'
+              className={`w-full font-mono ${inputClass}`}
+            />
+          )}
+          <button
+            type="submit"
+            disabled={loading}
+            className="inline-flex items-center px-3 py-1.5 border border-transparent text-sm font-medium rounded text-white bg-indigo-600 hover:bg-indigo-700 disabled:opacity-50"
+          >
+            {loading ? 'Testing...' : 'Test policy'}
+          </button>
+        </form>
+        {error && <p className="mt-2 text-sm text-red-600">{error}</p>}
+        {result && (
+          <div className="mt-4 text-sm">
+            <p className={result.permitted ? 'text-green-700 font-medium' : 'text-red-700 font-medium'}>
+              {result.permitted ? 'Permitted' : 'Denied'} as {result.role}
+            </p>
+            <p className="mt-1 text-gray-600">{result.reason}</p>
+            <ul className="mt-3 divide-y divide-gray-200">
+              {result.policies.map((p) => (
+                <li key={p.name} className={`py-2 ${p.applies ? '' : 'opacity-50'}`}>
+                  <div className="flex justify-between">
+                    <span className="font-medium text-gray-900">{p.name}</span>
+                    <span className="text-gray-500">
+                      {p.command} · {p.roles.join(', ')}
+                      {p.matched_rows !== undefined && ` · ${p.matched_rows} rows`}
+                    </span>
+                  </div>
+                  {p.using && <code className="block text-xs text-gray-600">USING {p.using}</code>}
+                  {p.with_check && <code className="block text-xs text-gray-600">WITH CHECK {p.with_check}</code>}
+                  {p.error && <p className="text-xs text-red-600">{p.error}</p>}
+                </li>
+              ))}
+            </ul>
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}
+
+export default PolicyTestCard
@@ -0,0 +1,284 @@
+import { useState, useEffect, useCallback, type FormEvent } from 'react'
+import { api } from '../lib/api'
+
+interface Column {
+  name: string
+  type: string
+  nullable: boolean
+  identity?: string
+  generated?: string
+}
+
+type Row = Record<string, unknown>
+
+interface TableDataCardProps {
+  table: string
+  schema: string
+}
+
+const pageSize = 50
+
+const inputClass =
+  'block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm px-3 py-2 border font-mono'
+const buttonClass =
+  'inline-flex items-center px-3 py-2 border border-transparent text-sm leading-4 font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700 disabled:opacity-50'
+const secondaryButtonClass =
+  'inline-flex items-center px-3 py-2 border border-gray-300 text-sm leading-4 font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 disabled:opacity-50'
+
+const formatValue = (value: unknown): string => (typeof value === 'string' ? value : JSON.stringify(value))
+
+// parseValue turns what was typed into a column's value: JSON for json
+// and array columns, text for everything else, which the server converts
+// to the column's type
+const parseValue = (column: Column, text: string): unknown => {
+  if (/^(json|jsonb)$|\[\]$/.test(column.type)) return JSON.parse(text)
+  return text
+}
+
+// rowKey picks a row's primary key values, as request parameters
+const rowKey = (primaryKey: string[], row: Row): Record<string, string> =>
+  Object.fromEntries(primaryKey.map((column) => [column, formatValue(row[column])]))
+
+// TableDataCard shows a table's rows a page at a time, sorted by a column,
+// and adds rows. Rows of tables with a primary key can be edited and
+// deleted too
+function TableDataCard({ table, schema }: TableDataCardProps) {
+  const [columns, setColumns] = useState<Column[]>([])
+  const [primaryKey, setPrimaryKey] = useState<string[]>([])
+  const [rows, setRows] = useState<Row[]>([])
+  const [total, setTotal] = useState(0)
+  const [offset, setOffset] = useState(0)
+  const [order, setOrder] = useState('')
+  const [filterColumn, setFilterColumn] = useState('')
+  const [filterText, setFilterText] = useState('')
+  const [filter, setFilter] = useState<Record<string, string>>({})
+  // The row being edited, null for a new row, undefined for none
+  const [editing, setEditing] = useState<Row | null | undefined>(undefined)
+  const [draft, setDraft] = useState<Record<string, string | null>>({})
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState('')
+
+  const load = useCallback(async () => {
+    setLoading(true)
+    try {
+      const data = await api.getRows(table, { schema, limit: pageSize, offset, order, filters: filter })
+      setColumns(data.columns)
+      setPrimaryKey(data.primary_key)
+      setRows(data.rows)
+      setTotal(data.total)
+      setError('')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load rows')
+    } finally {
+      setLoading(false)
+    }
+  }, [table, schema, offset, order, filter])
+
+  useEffect(() => {
+    load()
+  }, [load])
+
+  const handleSort = (column: string) => {
+    setOrder(order === column ? `${column}.desc` : column)
+    setOffset(0)
+  }
+
+  const handleFilter = (e: FormEvent) => {
+    e.preventDefault()
+    setFilter(filterColumn && filterText ? { [filterColumn]: `ilike.%${filterText}%` } : {})
+    setOffset(0)
+  }
+
+  const startEditing = (row: Row | null) => {
+    const values: Record<string, string | null> = {}
+    if (row) {
+      for (const column of columns) values[column.name] = row[column.name] === null ? null : formatValue(row[column.name])
+    }
+    setDraft(values)
+    setEditing(row)
+  }
+
+  const handleSave = async (e: FormEvent) => {
+    e.preventDefault()
+    try {
+      // Only send what was typed, or changed, so other columns keep their
+      // defaults or values
+      const values: Row = {}
+      for (const column of columns) {
+        if (!(column.name in draft)) continue
+        const text = draft[column.name]
+        if (editing && text === (editing[column.name] === null ? null : formatValue(editing[column.name]))) continue
+        values[column.name] = text === null ? null : parseValue(column, text)
+      }
+      if (editing) {
+        if (Object.keys(values).length > 0) await api.updateRow(table, schema, rowKey(primaryKey, editing), values)
+      } else {
+        await api.insertRow(table, schema, values)
+      }
+      setEditing(undefined)
+      await load()
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to save row')
+    }
+  }
+
+  const handleDelete = async (row: Row) => {
+    if (!window.confirm('Delete this row?')) return
+    try {
+      await api.deleteRow(table, schema, rowKey(primaryKey, row))
+      await load()
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to delete row')
+    }
+  }
+
+  const editable = primaryKey.length > 0
+
+  return (
+    <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+      <div className="px-4 py-4 sm:px-6 flex flex-wrap items-center justify-between gap-2">
+        <form onSubmit={handleFilter} className="flex items-center space-x-2">
+          <select
+            value={filterColumn}
+            onChange={(e) => setFilterColumn(e.target.value)}
+            className="rounded-md border border-gray-300 text-sm px-2 py-2"
+          >
+            <option value="">Filter column...</option>
+            {columns.map((column) => (
+              <option key={column.name} value={column.name}>
+                {column.name}
+              </option>
+            ))}
+          </select>
+          <input
+            value={filterText}
+            onChange={(e) => setFilterText(e.target.value)}
+            placeholder="contains"
+            className="rounded-md border border-gray-300 text-sm px-2 py-2"
+          />
+          <button type="submit" className={secondaryButtonClass}>
+            Filter
+          </button>
+        </form>
+        <div className="flex items-center space-x-3">
+          {!editable && <span className="text-sm text-gray-400">No primary key: rows can't be edited</span>}
+          <button onClick={() => startEditing(null)} className={buttonClass}>
+            Add row
+          </button>
+        </div>
+      </div>
+
+      {error && <div className="mx-4 mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded text-sm">{error}</div>}
+
+      {editing !== undefined && (
+        <form onSubmit={handleSave} className="mx-4 mb-4 p-4 border border-gray-200 rounded-md space-y-3">
+          <h4 className="text-sm font-medium text-gray-900">{editing ? 'Edit row' : 'New row'}</h4>
+          {columns.map((column) => (
+            <div key={column.name} className="grid grid-cols-3 gap-2 items-center">
+              <label className="text-sm text-gray-700">
+                {column.name}
+                <span className="ml-1 text-xs text-gray-400">{column.type}</span>
+              </label>
+              <div className="col-span-2 flex items-center space-x-2">
+                <input
+                  value={draft[column.name] ?? ''}
+                  disabled={draft[column.name] === null || !!column.generated}
+                  placeholder={
+                    draft[column.name] === null ? 'NULL' : column.name in draft ? '' : column.identity ? 'identity' : 'default'
+                  }
+                  onChange={(e) => setDraft({ ...draft, [column.name]: e.target.value })}
+                  className={inputClass}
+                />
+                {column.nullable && (
+                  <label className="inline-flex items-center text-xs text-gray-500">
+                    <input
+                      type="checkbox"
+                      checked={draft[column.name] === null}
+                      onChange={(e) => setDraft({ ...draft, [column.name]: e.target.checked ? null : '' })}
+                      className="mr-1"
+                    />
+                    NULL
+                  </label>
+                )}
+              </div>
+            </div>
+          ))}
+          <div className="flex space-x-2">
+            <button type="submit" className={buttonClass}>
+              Save
+            </button>
+            <button type="button" onClick={() => setEditing(undefined)} className={secondaryButtonClass}>
+              Cancel
+            </button>
+          </div>
+        </form>
+      )}
+
+      <div className="overflow-x-auto border-t border-gray-200">
+        <table className="min-w-full divide-y divide-gray-200 text-xs font-mono">
+          <thead className="bg-gray-50">
+            <tr>
+              {columns.map((column) => (
+                <th
+                  key={column.name}
+                  onClick={() => handleSort(column.name)}
+                  className="px-3 py-2 text-left font-semibold text-gray-700 whitespace-nowrap cursor-pointer hover:bg-gray-100"
+                >
+                  {column.name}
+                  {order === column.name && ' ▲'}
+                  {order === `${column.name}.desc` && ' ▼'}
+                </th>
+              ))}
+              {editable && <th />}
+            </tr>
+          </thead>
+          <tbody className="divide-y divide-gray-200">
+            {rows.map((row, i) => (
+              <tr key={i} className="hover:bg-gray-50">
+                {columns.map((column) => (
+                  <td key={column.name} className="px-3 py-1 text-gray-900 whitespace-pre max-w-xs truncate">
+                    {row[column.name] === null ? <span className="text-gray-400">NULL</span> : formatValue(row[column.name])}
+                  </td>
+                ))}
+                {editable && (
+                  <td className="px-3 py-1 whitespace-nowrap text-right">
+                    <button onClick={() => startEditing(row)} className="text-indigo-600 hover:text-indigo-900 mr-3">
+                      Edit
+                    </button>
+                    <button onClick={() => handleDelete(row)} className="text-red-600 hover:text-red-900">
+                      Delete
+                    </button>
+                  </td>
+                )}
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+
+      <div className="px-4 py-3 sm:px-6 flex items-center justify-between border-t border-gray-200 text-sm text-gray-500">
+        <span>
+          {loading ? 'Loading...' : total === 0 ? 'No rows' : `Rows ${offset + 1}–${offset + rows.length} of ${total}`}
+        </span>
+        <div className="space-x-2">
+          <button
+            onClick={() => setOffset(Math.max(0, offset - pageSize))}
+            disabled={offset === 0}
+            className={secondaryButtonClass}
+          >
+            Previous
+          </button>
+          <button
+            onClick={() => setOffset(offset + pageSize)}
+            disabled={offset + rows.length >= total}
+            className={secondaryButtonClass}
+          >
+            Next
+          </button>
+        </div>
+      </div>
+    </div>
+  )
+}
+
+export default TableDataCard
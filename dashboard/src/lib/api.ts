@@ -37,6 +37,28 @@ export const authFetch = async (url: string, options: RequestInit = {}): Promise
   return response
 }
 
+// rowsError reads the error of a rows request, which is PostgreSQL's
+// error as JSON when it rejected the change, or plain text
+const rowsError = async (response: Response, fallback: string): Promise<string> => {
+  const text = await response.text()
+  try {
+    return JSON.parse(text).message || fallback
+  } catch {
+    return text || fallback
+  }
+}
+
+// authUsersError reads the error of an auth user request, which is
+// GoTrue's JSON error or plain text
+const authUsersError = async (response: Response, fallback: string): Promise<string> => {
+  const text = await response.text()
+  try {
+    return JSON.parse(text).msg || fallback
+  } catch {
+    return text || fallback
+  }
+}
+
 // API object with all backend methods
 export const api = {
   // Auth
@@ -82,11 +104,263 @@ export const api = {
     return response.json()
   },
 
-  getTableSchema: async (tableName: string) => {
-    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/schema`)
+  getTableSchema: async (tableName: string, schema?: string) => {
+    const query = schema ? `?schema=${encodeURIComponent(schema)}` : ''
+    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/schema${query}`)
     if (!response.ok) throw new Error('Failed to fetch table schema')
     return response.json()
   },
+
+  getRows: async (
+    tableName: string,
+    page: { schema?: string; limit?: number; offset?: number; order?: string; filters?: Record<string, string> } = {}
+  ) => {
+    const params = new URLSearchParams(page.filters)
+    if (page.schema) params.set('schema', page.schema)
+    if (page.limit) params.set('limit', String(page.limit))
+    if (page.offset) params.set('offset', String(page.offset))
+    if (page.order) params.set('order', page.order)
+    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/rows?${params}`)
+    if (!response.ok) throw new Error(await rowsError(response, 'Failed to fetch rows'))
+    return response.json()
+  },
+
+  insertRow: async (tableName: string, schema: string, values: Record<string, unknown>) => {
+    const params = new URLSearchParams({ schema })
+    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/rows?${params}`, {
+      method: 'POST',
+      body: JSON.stringify(values),
+    })
+    if (!response.ok) throw new Error(await rowsError(response, 'Failed to insert row'))
+    return response.json()
+  },
+
+  updateRow: async (tableName: string, schema: string, key: Record<string, string>, values: Record<string, unknown>) => {
+    const params = new URLSearchParams({ ...key, schema })
+    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/rows?${params}`, {
+      method: 'PATCH',
+      body: JSON.stringify(values),
+    })
+    if (!response.ok) throw new Error(await rowsError(response, 'Failed to update row'))
+    return response.json()
+  },
+
+  deleteRow: async (tableName: string, schema: string, key: Record<string, string>) => {
+    const params = new URLSearchParams({ ...key, schema })
+    const response = await authFetch(`/tables/${encodeURIComponent(tableName)}/rows?${params}`, {
+      method: 'DELETE',
+    })
+    if (!response.ok) throw new Error(await rowsError(response, 'Failed to delete row'))
+    return response.json()
+  },
+
+  // Debugging
+  debugJwt: async (token: string) => {
+    const response = await authFetch('/debug/jwt', {
+      method: 'POST',
+      body: JSON.stringify({ token }),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to decode token')
+    return response.json()
+  },
+
+  simulateRls: async (request: {
+    table: string
+    operation: string
+    token?: string
+    role?: string
+    claims?: Record<string, unknown>
+    row?: Record<string, unknown>
+    match?: Record<string, unknown>
+  }) => {
+    const response = await authFetch('/debug/rls', {
+      method: 'POST',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to simulate policy')
+    return response.json()
+  },
+
+  // Auth users
+  impersonateUser: async (email: string, expiresIn?: number) => {
+    const response = await authFetch('/auth/impersonate', {
+      method: 'POST',
+      body: JSON.stringify({ email, expires_in: expiresIn }),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to impersonate user')
+    return response.json()
+  },
+
+  getAuthUsers: async (page = 1, perPage = 50) => {
+    const params = new URLSearchParams({ page: String(page), per_page: String(perPage) })
+    const response = await authFetch(`/auth/users?${params}`)
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to fetch users'))
+    return response.json()
+  },
+
+  createAuthUser: async (request: { email: string; password?: string; email_confirm?: boolean }) => {
+    const response = await authFetch('/auth/users', {
+      method: 'POST',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to create user'))
+    return response.json()
+  },
+
+  deleteAuthUser: async (id: string) => {
+    const response = await authFetch(`/auth/users/${encodeURIComponent(id)}`, { method: 'DELETE' })
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to delete user'))
+  },
+
+  banAuthUser: async (id: string, banned: boolean, duration?: string) => {
+    const response = await authFetch(`/auth/users/${encodeURIComponent(id)}/ban`, {
+      method: banned ? 'POST' : 'DELETE',
+      body: banned && duration ? JSON.stringify({ duration }) : undefined,
+    })
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to change ban'))
+    return response.json()
+  },
+
+  confirmAuthUser: async (id: string) => {
+    const response = await authFetch(`/auth/users/${encodeURIComponent(id)}/confirm`, { method: 'POST' })
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to confirm user'))
+    return response.json()
+  },
+
+  sendPasswordReset: async (id: string) => {
+    const response = await authFetch(`/auth/users/${encodeURIComponent(id)}/recover`, { method: 'POST' })
+    if (!response.ok) throw new Error(await authUsersError(response, 'Failed to send password reset'))
+  },
+
+  // Types
+  getTypes: async () => {
+    const response = await authFetch('/types')
+    if (!response.ok) throw new Error('Failed to fetch types')
+    return response.json()
+  },
+
+  createEnum: async (request: { schema?: string; name: string; values: string[] }) => {
+    const response = await authFetch('/types/enums', {
+      method: 'POST',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to create enum')
+    return response.json()
+  },
+
+  alterEnum: async (
+    schema: string,
+    name: string,
+    request: { add_value?: string; before?: string; after?: string; rename_value?: { from: string; to: string } }
+  ) => {
+    const response = await authFetch(`/types/enums/${encodeURIComponent(schema)}/${encodeURIComponent(name)}`, {
+      method: 'PATCH',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to alter enum')
+    return response.json()
+  },
+
+  createDomain: async (request: {
+    schema?: string
+    name: string
+    base_type: string
+    not_null?: boolean
+    default?: string
+    check?: string
+  }) => {
+    const response = await authFetch('/types/domains', {
+      method: 'POST',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to create domain')
+    return response.json()
+  },
+
+  alterDomain: async (
+    schema: string,
+    name: string,
+    request: {
+      default?: string
+      drop_default?: boolean
+      not_null?: boolean
+      add_check?: { name?: string; expression: string }
+      drop_constraint?: string
+    }
+  ) => {
+    const response = await authFetch(`/types/domains/${encodeURIComponent(schema)}/${encodeURIComponent(name)}`, {
+      method: 'PATCH',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to alter domain')
+    return response.json()
+  },
+
+  // Logs
+  getLogs: async (filter: { component?: string; level?: string; limit?: number } = {}) => {
+    const params = new URLSearchParams()
+    if (filter.component) params.set('component', filter.component)
+    if (filter.level) params.set('level', filter.level)
+    if (filter.limit) params.set('limit', String(filter.limit))
+    const response = await authFetch(`/logs?${params}`)
+    if (!response.ok) throw new Error('Failed to fetch logs')
+    return response.json()
+  },
+
+  // Recorded REST requests
+  getRESTRequests: async () => {
+    const response = await authFetch('/rest/requests')
+    if (!response.ok) throw new Error('Failed to fetch requests')
+    return response.json()
+  },
+
+  clearRESTRequests: async () => {
+    const response = await authFetch('/rest/requests', { method: 'DELETE' })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to clear requests')
+  },
+
+  // SQL editor
+  runSQL: async (request: { query: string; read_only?: boolean; timeout_ms?: number; max_rows?: number }) => {
+    const response = await authFetch('/sql', {
+      method: 'POST',
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) {
+      const text = await response.text()
+      let error: { message?: string; position?: number | null } = { message: text }
+      try {
+        error = JSON.parse(text)
+      } catch {
+        // Plain-text errors are request errors
+      }
+      throw Object.assign(new Error(error.message || 'Failed to run query'), { position: error.position ?? null })
+    }
+    return response.json()
+  },
+
+  // Change history
+  getHistoryTables: async () => {
+    const response = await authFetch('/history')
+    if (!response.ok) throw new Error('Failed to fetch tracked tables')
+    return response.json()
+  },
+
+  getHistory: async (table: string, filter: { key?: Record<string, string>; limit?: number; before?: number } = {}) => {
+    const params = new URLSearchParams(filter.key)
+    if (filter.limit) params.set('limit', String(filter.limit))
+    if (filter.before) params.set('before', String(filter.before))
+    const response = await authFetch(`/history/${encodeURIComponent(table)}?${params}`)
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to fetch history')
+    return response.json()
+  },
+
+  setHistoryTracking: async (table: string, tracked: boolean) => {
+    const response = await authFetch(`/history/${encodeURIComponent(table)}`, {
+      method: tracked ? 'PUT' : 'DELETE',
+    })
+    if (!response.ok) throw new Error((await response.text()) || 'Failed to change tracking')
+    return response.json()
+  },
 }
 
 export default api
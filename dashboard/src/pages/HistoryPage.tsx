@@ -0,0 +1,274 @@
+import { useState, useEffect, useCallback, type FormEvent } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface TrackedTable {
+  schema: string
+  name: string
+  versions: number
+  oldest?: string
+  newest?: string
+}
+
+interface Version {
+  id: number
+  op: string
+  ts: string
+  table_schema: string
+  table_name: string
+  record: Record<string, unknown> | null
+  old_record: Record<string, unknown> | null
+  auth_role: string | null
+  auth_uid: string | null
+}
+
+const opClass: Record<string, string> = {
+  INSERT: 'bg-green-100 text-green-800',
+  UPDATE: 'bg-blue-100 text-blue-800',
+  DELETE: 'bg-red-100 text-red-800',
+  TRUNCATE: 'bg-gray-200 text-gray-800',
+}
+
+const inputClass =
+  'block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm px-3 py-2 border'
+const buttonClass =
+  'inline-flex items-center px-3 py-2 border border-transparent text-sm leading-4 font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500'
+const secondaryButtonClass =
+  'inline-flex items-center px-3 py-2 border border-gray-300 text-sm leading-4 font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50'
+
+const pageSize = 50
+
+// parseKey reads "id=42, tenant=acme" into primary key parameters
+const parseKey = (text: string): Record<string, string> => {
+  const key: Record<string, string> = {}
+  for (const part of text.split(',')) {
+    const [column, ...rest] = part.split('=')
+    if (column.trim() && rest.length > 0) key[column.trim()] = rest.join('=').trim()
+  }
+  return key
+}
+
+const formatValue = (value: unknown): string => (typeof value === 'string' ? value : JSON.stringify(value))
+
+// changes lists the columns an UPDATE changed, with old and new values
+const changes = (version: Version): [string, unknown, unknown][] => {
+  const before = version.old_record ?? {}
+  const after = version.record ?? {}
+  return Object.keys(after)
+    .filter((column) => JSON.stringify(before[column]) !== JSON.stringify(after[column]))
+    .map((column) => [column, before[column], after[column]])
+}
+
+function HistoryPage() {
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [tables, setTables] = useState<TrackedTable[]>([])
+  const [selected, setSelected] = useState('')
+  const [keyText, setKeyText] = useState('')
+  const [versions, setVersions] = useState<Version[]>([])
+  const [hasMore, setHasMore] = useState(false)
+  const [newTable, setNewTable] = useState('')
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState('')
+
+  const loadTables = useCallback(async () => {
+    try {
+      const data = await api.getHistoryTables()
+      setTables(data.tables)
+      setError('')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load tracked tables')
+    }
+  }, [])
+
+  const loadVersions = useCallback(
+    async (before?: number) => {
+      if (!selected) return
+      try {
+        const data = await api.getHistory(selected, { key: parseKey(keyText), limit: pageSize, before })
+        setVersions((current) => (before ? [...current, ...data.versions] : data.versions))
+        setHasMore(data.versions.length === pageSize)
+        setError('')
+      } catch (err) {
+        setError(err instanceof Error ? err.message : 'Failed to load history')
+      }
+    },
+    [selected, keyText]
+  )
+
+  useEffect(() => {
+    api
+      .me()
+      .then((userData) => setUserEmail(userData.email))
+      .catch(() => {})
+    loadTables().finally(() => setLoading(false))
+  }, [loadTables])
+
+  useEffect(() => {
+    setVersions([])
+    loadVersions()
+    // Reload only when the table changes; the key is applied with Filter
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [selected])
+
+  const handleTrack = async (e: FormEvent) => {
+    e.preventDefault()
+    try {
+      await api.setHistoryTracking(newTable, true)
+      setNewTable('')
+      await loadTables()
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to track table')
+    }
+  }
+
+  const handleUntrack = async (table: string) => {
+    if (!window.confirm(`Stop tracking ${table}? Its history so far is kept.`)) return
+    try {
+      await api.setHistoryTracking(table, false)
+      await loadTables()
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to stop tracking')
+    }
+  }
+
+  const handleFilter = (e: FormEvent) => {
+    e.preventDefault()
+    loadVersions()
+  }
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
+      </div>
+    )
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">Change History</h2>
+          </div>
+          <form onSubmit={handleTrack} className="mt-4 flex space-x-3 md:mt-0 md:ml-4">
+            <input
+              className={inputClass}
+              placeholder="schema.table"
+              value={newTable}
+              onChange={(e) => setNewTable(e.target.value)}
+              required
+            />
+            <button type="submit" className={buttonClass}>
+              Track
+            </button>
+          </form>
+        </div>
+
+        {error && <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">{error}</div>}
+
+        <div className="grid grid-cols-1 gap-6 lg:grid-cols-4">
+          <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+            {tables.length === 0 ? (
+              <div className="px-4 py-5 sm:px-6 text-sm text-gray-500">No tables are tracked</div>
+            ) : (
+              <ul className="divide-y divide-gray-200">
+                {tables.map((t) => {
+                  const name = `${t.schema}.${t.name}`
+                  return (
+                    <li
+                      key={name}
+                      className={`px-4 py-3 cursor-pointer ${selected === name ? 'bg-indigo-50' : 'hover:bg-gray-50'}`}
+                      onClick={() => setSelected(name)}
+                    >
+                      <div className="text-sm font-medium text-gray-900">{name}</div>
+                      <div className="flex justify-between text-xs text-gray-500">
+                        <span>{t.versions} versions</span>
+                        <button
+                          className="text-red-600 hover:text-red-800"
+                          onClick={(e) => {
+                            e.stopPropagation()
+                            handleUntrack(name)
+                          }}
+                        >
+                          Stop
+                        </button>
+                      </div>
+                    </li>
+                  )
+                })}
+              </ul>
+            )}
+          </div>
+
+          <div className="lg:col-span-3">
+            {selected ? (
+              <>
+                <form onSubmit={handleFilter} className="mb-4 flex space-x-3">
+                  <input
+                    className={inputClass}
+                    placeholder="Primary key of one row, e.g. id=42"
+                    value={keyText}
+                    onChange={(e) => setKeyText(e.target.value)}
+                  />
+                  <button type="submit" className={secondaryButtonClass}>
+                    Filter
+                  </button>
+                </form>
+                <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+                  {versions.length === 0 ? (
+                    <div className="px-4 py-5 sm:px-6 text-sm text-gray-500">No changes recorded</div>
+                  ) : (
+                    <ul className="divide-y divide-gray-200 text-xs">
+                      {versions.map((v) => (
+                        <li key={v.id} className="px-4 py-3 sm:px-6">
+                          <div className="flex items-center space-x-3">
+                            <span className={`px-2 rounded-full font-semibold ${opClass[v.op] ?? opClass.TRUNCATE}`}>
+                              {v.op}
+                            </span>
+                            <span className="text-gray-500">{new Date(v.ts).toLocaleString()}</span>
+                            <span className="text-gray-500">
+                              {v.auth_role ?? 'database'}
+                              {v.auth_uid && ` · ${v.auth_uid}`}
+                            </span>
+                          </div>
+                          <div className="mt-2 font-mono text-gray-700 break-all">
+                            {v.op === 'UPDATE' ? (
+                              changes(v).map(([column, before, after]) => (
+                                <div key={column}>
+                                  {column}: <span className="text-red-700 line-through">{formatValue(before)}</span>{' '}
+                                  <span className="text-green-700">{formatValue(after)}</span>
+                                </div>
+                              ))
+                            ) : v.op === 'TRUNCATE' ? (
+                              <div className="text-gray-500">All rows deleted</div>
+                            ) : (
+                              <div>{JSON.stringify(v.record ?? v.old_record)}</div>
+                            )}
+                          </div>
+                        </li>
+                      ))}
+                    </ul>
+                  )}
+                </div>
+                {hasMore && (
+                  <button
+                    className={`${secondaryButtonClass} mt-4`}
+                    onClick={() => loadVersions(versions[versions.length - 1].id)}
+                  >
+                    Load older
+                  </button>
+                )}
+              </>
+            ) : (
+              <div className="text-sm text-gray-500">Select a table to see its changes</div>
+            )}
+          </div>
+        </div>
+      </div>
+    </>
+  )
+}
+
+export default HistoryPage
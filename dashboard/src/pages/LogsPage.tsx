@@ -0,0 +1,119 @@
+import { useState, useEffect, useCallback } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface LogEntry {
+  time: string
+  level: string
+  component?: string
+  message: string
+  fields?: Record<string, string>
+}
+
+const levelClass: Record<string, string> = {
+  debug: 'bg-gray-100 text-gray-600',
+  info: 'bg-blue-100 text-blue-800',
+  warn: 'bg-yellow-100 text-yellow-800',
+  error: 'bg-red-100 text-red-800',
+}
+
+const selectClass =
+  'rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm px-3 py-2 border'
+
+function LogsPage() {
+  const [entries, setEntries] = useState<LogEntry[]>([])
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [component, setComponent] = useState('gotrue')
+  const [level, setLevel] = useState('')
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState('')
+
+  const loadLogs = useCallback(async () => {
+    try {
+      const data = await api.getLogs({ component, level })
+      setEntries(data.entries)
+      setError('')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load logs')
+    }
+  }, [component, level])
+
+  useEffect(() => {
+    api
+      .me()
+      .then((userData) => setUserEmail(userData.email))
+      .catch(() => {})
+  }, [])
+
+  // Refresh every few seconds while the page is open
+  useEffect(() => {
+    loadLogs().finally(() => setLoading(false))
+    const timer = setInterval(loadLogs, 3000)
+    return () => clearInterval(timer)
+  }, [loadLogs])
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
+      </div>
+    )
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">Logs</h2>
+          </div>
+          <div className="mt-4 flex space-x-3 md:mt-0 md:ml-4">
+            <select className={selectClass} value={component} onChange={(e) => setComponent(e.target.value)}>
+              <option value="">All components</option>
+              <option value="gotrue">Auth (GoTrue)</option>
+            </select>
+            <select className={selectClass} value={level} onChange={(e) => setLevel(e.target.value)}>
+              <option value="">All levels</option>
+              <option value="info">Info and above</option>
+              <option value="warn">Warnings and errors</option>
+              <option value="error">Errors</option>
+            </select>
+          </div>
+        </div>
+
+        {error && <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">{error}</div>}
+
+        <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+          {entries.length === 0 ? (
+            <div className="px-4 py-5 sm:px-6 text-sm text-gray-500">No log entries</div>
+          ) : (
+            <ul className="divide-y divide-gray-200 font-mono text-xs">
+              {[...entries].reverse().map((entry, i) => (
+                <li key={`${entry.time}-${i}`} className="px-4 py-2 sm:px-6">
+                  <div className="flex items-start space-x-3">
+                    <span className="text-gray-400 whitespace-nowrap">{new Date(entry.time).toLocaleTimeString()}</span>
+                    <span className={`px-2 rounded-full font-semibold ${levelClass[entry.level] ?? levelClass.info}`}>
+                      {entry.level}
+                    </span>
+                    {entry.component && <span className="text-indigo-600">{entry.component}</span>}
+                    <span className="text-gray-900 break-all">{entry.message}</span>
+                  </div>
+                  {entry.fields && (
+                    <div className="mt-1 ml-24 text-gray-500 break-all">
+                      {Object.entries(entry.fields)
+                        .map(([key, value]) => `${key}=${value}`)
+                        .join(' ')}
+                    </div>
+                  )}
+                </li>
+              ))}
+            </ul>
+          )}
+        </div>
+      </div>
+    </>
+  )
+}
+
+export default LogsPage
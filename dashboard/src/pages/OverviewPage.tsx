@@ -4,12 +4,22 @@ import { api } from '../lib/api'
 import Header from '../components/Header'
 import StatusCard from '../components/StatusCard'
 import ApiKeyCard from '../components/ApiKeyCard'
+import ImpersonateCard from '../components/ImpersonateCard'
+import PolicyTestCard from '../components/PolicyTestCard'
 
 interface StatusResponse {
   status: string
   timestamp: string
   uptime: string
   version: string
+  database?: {
+    encoding: string
+    provider: string
+    collate: string
+    ctype: string
+    icu_locale?: string
+    timezone: string
+  }
 }
 
 interface TablesResponse {
@@ -17,6 +27,7 @@ interface TablesResponse {
     name: string
     schema: string
     rows?: number
+    rows_estimated?: boolean
     size_bytes?: string
   }>
 }
@@ -82,7 +93,7 @@ function OverviewPage() {
       {/* Status Cards */}
       <div className="mt-8">
         <h3 className="text-lg leading-6 font-medium text-gray-900">System Status</h3>
-        <dl className="mt-5 grid grid-cols-1 gap-5 sm:grid-cols-3">
+        <dl className="mt-5 grid grid-cols-1 gap-5 sm:grid-cols-2 lg:grid-cols-4">
           <StatusCard
             title="Status"
             value={status?.status || 'Unknown'}
@@ -98,6 +109,18 @@ function OverviewPage() {
             value={totalRows.toString()}
             description="Across all tables"
           />
+          <StatusCard
+            title="Collation"
+            value={
+              status?.database
+                ? status.database.icu_locale || status.database.collate
+                : 'Unknown'
+            }
+            description={
+              status?.database &&
+              `${status.database.provider}, ${status.database.encoding}, time zone ${status.database.timezone}`
+            }
+          />
         </dl>
       </div>
 
@@ -119,6 +142,28 @@ function OverviewPage() {
         </div>
       </div>
 
+      {/* Impersonation */}
+      <div className="mt-8">
+        <h3 className="text-lg leading-6 font-medium text-gray-900">Impersonate User</h3>
+        <p className="mt-1 text-sm text-gray-500">
+          Mint a short-lived token for an auth user to test RLS policies as them
+        </p>
+        <div className="mt-4">
+          <ImpersonateCard />
+        </div>
+      </div>
+
+      {/* Policy simulator */}
+      <div className="mt-8">
+        <h3 className="text-lg leading-6 font-medium text-gray-900">Test Policy</h3>
+        <p className="mt-1 text-sm text-gray-500">
+          Run an operation as a role or token in a rolled-back transaction and see which RLS policies match
+        </p>
+        <div className="mt-4">
+          <PolicyTestCard />
+        </div>
+      </div>
+
       {/* Tables Preview */}
       <div className="mt-8">
         <div className="flex items-center justify-between">
@@ -140,7 +185,7 @@ function OverviewPage() {
                   </div>
                   <div className="ml-2 flex-shrink-0 flex">
                     <p className="px-2 inline-flex text-xs leading-5 font-semibold rounded-full bg-green-100 text-green-800">
-                      {table.rows || 0} rows
+                      {table.rows_estimated ? '~' : ''}{table.rows || 0} rows
                     </p>
                   </div>
                 </div>
@@ -0,0 +1,161 @@
+import { useState, useEffect, useCallback } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface Statement {
+  sql: string
+  args: unknown[]
+  rows: number
+  duration_ms: number
+  error?: string
+}
+
+interface RecordedRequest {
+  id: number
+  time: string
+  method: string
+  path: string
+  query?: string
+  role?: string
+  status: number
+  duration_ms: number
+  statements: Statement[]
+}
+
+function statusClass(status: number) {
+  if (status >= 500) return 'bg-red-100 text-red-800'
+  if (status >= 400) return 'bg-yellow-100 text-yellow-800'
+  return 'bg-green-100 text-green-800'
+}
+
+function RequestsPage() {
+  const [requests, setRequests] = useState<RecordedRequest[]>([])
+  const [enabled, setEnabled] = useState(true)
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [expanded, setExpanded] = useState<number | null>(null)
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState('')
+
+  const loadRequests = useCallback(async () => {
+    try {
+      const data = await api.getRESTRequests()
+      setEnabled(data.enabled)
+      setRequests(data.requests)
+      setError('')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load requests')
+    }
+  }, [])
+
+  useEffect(() => {
+    api
+      .me()
+      .then((userData) => setUserEmail(userData.email))
+      .catch(() => {})
+  }, [])
+
+  // Refresh every few seconds while the page is open
+  useEffect(() => {
+    loadRequests().finally(() => setLoading(false))
+    const timer = setInterval(loadRequests, 3000)
+    return () => clearInterval(timer)
+  }, [loadRequests])
+
+  const handleClear = async () => {
+    try {
+      await api.clearRESTRequests()
+      setRequests([])
+      setExpanded(null)
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to clear requests')
+    }
+  }
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
+      </div>
+    )
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">REST Requests</h2>
+          </div>
+          {enabled && (
+            <div className="mt-4 flex md:mt-0 md:ml-4">
+              <button
+                onClick={handleClear}
+                className="inline-flex items-center px-4 py-2 border border-gray-300 rounded-md shadow-sm text-sm font-medium text-gray-700 bg-white hover:bg-gray-50"
+              >
+                Clear
+              </button>
+            </div>
+          )}
+        </div>
+
+        {error && <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">{error}</div>}
+
+        {!enabled ? (
+          <div className="bg-white shadow sm:rounded-lg px-4 py-5 sm:px-6 text-sm text-gray-500">
+            Request recording is off. Start the server with <code>--rest-record</code> to see each REST request with
+            the SQL it ran.
+          </div>
+        ) : (
+          <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+            {requests.length === 0 ? (
+              <div className="px-4 py-5 sm:px-6 text-sm text-gray-500">No requests recorded yet</div>
+            ) : (
+              <ul className="divide-y divide-gray-200 text-xs">
+                {requests.map((req) => (
+                  <li key={req.id} className="px-4 py-2 sm:px-6">
+                    <button
+                      onClick={() => setExpanded(expanded === req.id ? null : req.id)}
+                      className="w-full flex items-start space-x-3 text-left font-mono"
+                    >
+                      <span className="text-gray-400 whitespace-nowrap">{new Date(req.time).toLocaleTimeString()}</span>
+                      <span className={`px-2 rounded-full font-semibold ${statusClass(req.status)}`}>{req.status}</span>
+                      <span className="font-semibold text-gray-900">{req.method}</span>
+                      <span className="text-gray-900 break-all flex-1">
+                        {req.path}
+                        {req.query && `?${req.query}`}
+                      </span>
+                      {req.role && <span className="text-indigo-600">{req.role}</span>}
+                      <span className="text-gray-400 whitespace-nowrap">{req.duration_ms.toFixed(1)}ms</span>
+                    </button>
+                    {expanded === req.id && (
+                      <div className="mt-2 ml-4 space-y-2">
+                        {req.statements.length === 0 && <div className="text-gray-500">No SQL was run</div>}
+                        {req.statements.map((st, i) => (
+                          <div key={i} className="bg-gray-50 rounded p-2 font-mono">
+                            <pre className="whitespace-pre-wrap break-all text-gray-900">{st.sql}</pre>
+                            {st.args.length > 0 && (
+                              <div className="mt-1 text-gray-500 break-all">
+                                {st.args.map((arg, j) => `$${j + 1} = ${JSON.stringify(arg)}`).join('  ')}
+                              </div>
+                            )}
+                            <div className="mt-1 text-gray-400">
+                              {st.rows} rows, {st.duration_ms.toFixed(2)}ms
+                            </div>
+                            {st.error && <div className="mt-1 text-red-700">{st.error}</div>}
+                          </div>
+                        ))}
+                      </div>
+                    )}
+                  </li>
+                ))}
+              </ul>
+            )}
+          </div>
+        )}
+      </div>
+    </>
+  )
+}
+
+export default RequestsPage
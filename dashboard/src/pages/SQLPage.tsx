@@ -0,0 +1,166 @@
+import { useState, useEffect, type KeyboardEvent } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface Column {
+  name: string
+  type: string
+}
+
+interface Result {
+  command: string
+  columns: Column[] | null
+  rows: (string | null)[][] | null
+  row_count: number
+  truncated?: boolean
+}
+
+interface QueryError {
+  message: string
+  position: number | null
+}
+
+// lineAndColumn turns a 1-based character position in query into a line
+// and column for the error message
+function lineAndColumn(query: string, position: number) {
+  const before = query.slice(0, position - 1).split('\n')
+  return `line ${before.length}, column ${before[before.length - 1].length + 1}`
+}
+
+function SQLPage() {
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [query, setQuery] = useState('')
+  const [readOnly, setReadOnly] = useState(true)
+  const [running, setRunning] = useState(false)
+  const [results, setResults] = useState<Result[]>([])
+  const [durationMS, setDurationMS] = useState<number | null>(null)
+  const [error, setError] = useState<QueryError | null>(null)
+
+  useEffect(() => {
+    api
+      .me()
+      .then((userData) => setUserEmail(userData.email))
+      .catch(() => {})
+  }, [])
+
+  const handleRun = async () => {
+    if (!query.trim()) return
+    setRunning(true)
+    setError(null)
+    try {
+      const data = await api.runSQL({ query, read_only: readOnly })
+      setResults(data.results)
+      setDurationMS(data.duration_ms)
+    } catch (err) {
+      setResults([])
+      setDurationMS(null)
+      setError({
+        message: err instanceof Error ? err.message : 'Failed to run query',
+        position: (err as { position?: number | null }).position ?? null,
+      })
+    } finally {
+      setRunning(false)
+    }
+  }
+
+  // Ctrl+Enter or Cmd+Enter runs the query
+  const handleKeyDown = (e: KeyboardEvent<HTMLTextAreaElement>) => {
+    if (e.key === 'Enter' && (e.ctrlKey || e.metaKey)) {
+      e.preventDefault()
+      handleRun()
+    }
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">SQL Editor</h2>
+          </div>
+        </div>
+
+        <div className="bg-white shadow sm:rounded-lg p-4 mb-6">
+          <textarea
+            value={query}
+            onChange={(e) => setQuery(e.target.value)}
+            onKeyDown={handleKeyDown}
+            rows={8}
+            spellCheck={false}
+            placeholder="SELECT * FROM todos LIMIT 10;"
+            className="w-full font-mono text-sm border border-gray-300 rounded-md p-2 focus:ring-indigo-500 focus:border-indigo-500"
+          />
+          <div className="mt-3 flex items-center justify-between">
+            <label className="inline-flex items-center text-sm text-gray-700">
+              <input
+                type="checkbox"
+                checked={readOnly}
+                onChange={(e) => setReadOnly(e.target.checked)}
+                className="mr-2 rounded border-gray-300 text-indigo-600"
+              />
+              Read-only
+            </label>
+            <div className="flex items-center space-x-4">
+              {durationMS !== null && <span className="text-sm text-gray-400">{durationMS}ms</span>}
+              <button
+                onClick={handleRun}
+                disabled={running || !query.trim()}
+                className="inline-flex items-center px-4 py-2 border border-transparent rounded-md shadow-sm text-sm font-medium text-white bg-indigo-600 hover:bg-indigo-700 disabled:opacity-50"
+              >
+                {running ? 'Running...' : 'Run'}
+              </button>
+            </div>
+          </div>
+        </div>
+
+        {error && (
+          <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded font-mono text-sm">
+            {error.message}
+            {error.position !== null && <span className="text-red-500"> ({lineAndColumn(query, error.position)})</span>}
+          </div>
+        )}
+
+        <div className="space-y-6">
+          {results.map((result, i) => (
+            <div key={i} className="bg-white shadow overflow-hidden sm:rounded-lg">
+              <div className="px-4 py-2 sm:px-6 border-b border-gray-200 text-sm text-gray-500 font-mono">
+                {result.command}
+                {result.truncated && ` (showing ${result.rows?.length} of ${result.row_count} rows)`}
+              </div>
+              {result.columns && result.rows && (
+                <div className="overflow-x-auto">
+                  <table className="min-w-full divide-y divide-gray-200 text-xs font-mono">
+                    <thead className="bg-gray-50">
+                      <tr>
+                        {result.columns.map((col, j) => (
+                          <th key={j} className="px-3 py-2 text-left font-semibold text-gray-700 whitespace-nowrap">
+                            {col.name}
+                            <span className="ml-1 font-normal text-gray-400">{col.type}</span>
+                          </th>
+                        ))}
+                      </tr>
+                    </thead>
+                    <tbody className="divide-y divide-gray-200">
+                      {result.rows.map((row, j) => (
+                        <tr key={j}>
+                          {row.map((value, k) => (
+                            <td key={k} className="px-3 py-1 text-gray-900 whitespace-pre max-w-md truncate">
+                              {value === null ? <span className="text-gray-400">NULL</span> : value}
+                            </td>
+                          ))}
+                        </tr>
+                      ))}
+                    </tbody>
+                  </table>
+                </div>
+              )}
+            </div>
+          ))}
+        </div>
+      </div>
+    </>
+  )
+}
+
+export default SQLPage
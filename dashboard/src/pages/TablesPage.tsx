@@ -1,25 +1,72 @@
 import { useState, useEffect } from 'react'
 import { api } from '../lib/api'
 import Header from '../components/Header'
+import TableDataCard from '../components/TableDataCard'
 
 interface Table {
   name: string
   schema: string
   rows?: number
+  rows_estimated?: boolean
   size_bytes?: string
 }
 
+interface ForeignKeyRef {
+  schema: string
+  table: string
+  columns: string[]
+  on_update: string
+  on_delete: string
+}
+
 interface Column {
   name: string
   type: string
   nullable: boolean
+  default: string | null
+  identity?: string
+  generated?: string
+  primary_key: boolean
+  unique: boolean
+  references?: ForeignKeyRef
+  checks?: string[]
   key?: string
 }
 
+interface Constraint {
+  name: string
+  type: string
+  columns: string[]
+  definition: string
+  references?: ForeignKeyRef
+}
+
 interface TableSchema {
   table_name: string
   schema: string
   columns: Column[]
+  primary_key: string[]
+  constraints: Constraint[]
+}
+
+// columnDefault describes how a column gets its value when none is given
+function columnDefault(column: Column): string {
+  if (column.identity) return `identity (${column.identity.toLowerCase()})`
+  if (column.generated) return `generated: ${column.generated}`
+  return column.default ?? '-'
+}
+
+// columnKey describes the constraints on a single column
+function columnKey(column: Column): string {
+  const parts: string[] = []
+  if (column.primary_key) parts.push('PRIMARY KEY')
+  if (column.unique) parts.push('UNIQUE')
+  if (column.references) {
+    const ref = column.references
+    parts.push(`→ ${ref.schema}.${ref.table}(${ref.columns.join(', ')})`)
+  }
+  if (column.checks) parts.push(...column.checks)
+  return parts.length > 0 ? parts.join(', ') : '-'
 }
 
 function TablesPage() {
@@ -29,6 +76,7 @@ function TablesPage() {
   const [userEmail, setUserEmail] = useState<string>('')
   const [loading, setLoading] = useState(true)
   const [schemaLoading, setSchemaLoading] = useState(false)
+  const [view, setView] = useState<'schema' | 'data'>('schema')
   const [error, setError] = useState('')
 
   useEffect(() => {
@@ -56,7 +104,7 @@ function TablesPage() {
     setError('')
 
     try {
-      const schema = await api.getTableSchema(table.name)
+      const schema = await api.getTableSchema(table.name, table.schema)
       setTableSchema(schema)
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to load table schema')
@@ -117,7 +165,7 @@ function TablesPage() {
                       </div>
                       <div className="ml-2 flex-shrink-0 flex">
                         <p className="px-2 inline-flex text-xs leading-5 font-semibold rounded-full bg-green-100 text-green-800">
-                          {table.rows || 0} rows
+                          {table.rows_estimated ? '~' : ''}{table.rows || 0} rows
                         </p>
                       </div>
                     </div>
@@ -134,9 +182,30 @@ function TablesPage() {
             </div>
           </div>
 
-          {/* Table Schema */}
+          {/* Table Schema or Data */}
           <div className="lg:col-span-2">
-            {schemaLoading ? (
+            {selectedTable && (
+              <div className="mb-4 flex space-x-2">
+                {(['schema', 'data'] as const).map((v) => (
+                  <button
+                    key={v}
+                    onClick={() => setView(v)}
+                    className={`px-3 py-1 rounded-md text-sm font-medium ${
+                      view === v ? 'bg-indigo-100 text-indigo-700' : 'text-gray-500 hover:text-gray-700'
+                    }`}
+                  >
+                    {v === 'schema' ? 'Schema' : 'Data'}
+                  </button>
+                ))}
+              </div>
+            )}
+            {selectedTable && view === 'data' ? (
+              <TableDataCard
+                key={`${selectedTable.schema}.${selectedTable.name}`}
+                table={selectedTable.name}
+                schema={selectedTable.schema}
+              />
+            ) : schemaLoading ? (
               <div className="flex items-center justify-center h-64">
                 <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
               </div>
@@ -164,7 +233,10 @@ function TablesPage() {
                           Nullable
                         </th>
                         <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">
-                          Key
+                          Default
+                        </th>
+                        <th className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">
+                          Constraints
                         </th>
                       </tr>
                     </thead>
@@ -180,8 +252,11 @@ function TablesPage() {
                           <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-500">
                             {column.nullable ? 'Yes' : 'No'}
                           </td>
-                          <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-500">
-                            {column.key || '-'}
+                          <td className="px-6 py-4 text-sm text-gray-500 font-mono">
+                            {columnDefault(column)}
+                          </td>
+                          <td className="px-6 py-4 text-sm text-gray-500">
+                            {columnKey(column)}
                           </td>
                         </tr>
                       ))}
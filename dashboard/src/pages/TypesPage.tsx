@@ -0,0 +1,286 @@
+import { useState, useEffect, type FormEvent } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface EnumType {
+  schema: string
+  name: string
+  values: string[]
+}
+
+interface DomainConstraint {
+  name: string
+  definition: string
+}
+
+interface DomainType {
+  schema: string
+  name: string
+  base_type: string
+  not_null: boolean
+  default: string | null
+  constraints: DomainConstraint[]
+}
+
+const inputClass =
+  'block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm px-3 py-2 border'
+const buttonClass =
+  'inline-flex items-center px-3 py-2 border border-transparent text-sm leading-4 font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500'
+
+function TypesPage() {
+  const [enums, setEnums] = useState<EnumType[]>([])
+  const [domains, setDomains] = useState<DomainType[]>([])
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState('')
+  const [message, setMessage] = useState('')
+
+  // New enum
+  const [enumName, setEnumName] = useState('')
+  const [enumValues, setEnumValues] = useState('')
+
+  // New domain
+  const [domainName, setDomainName] = useState('')
+  const [domainBaseType, setDomainBaseType] = useState('text')
+  const [domainNotNull, setDomainNotNull] = useState(false)
+  const [domainDefault, setDomainDefault] = useState('')
+  const [domainCheck, setDomainCheck] = useState('')
+
+  const loadTypes = async () => {
+    const types = await api.getTypes()
+    setEnums(types.enums)
+    setDomains(types.domains)
+  }
+
+  useEffect(() => {
+    const loadData = async () => {
+      try {
+        const [, userData] = await Promise.all([loadTypes(), api.me()])
+        setUserEmail(userData.email)
+      } catch (err) {
+        setError(err instanceof Error ? err.message : 'Failed to load data')
+      } finally {
+        setLoading(false)
+      }
+    }
+
+    loadData()
+  }, [])
+
+  // run performs a change, then reloads the type list
+  const run = async (change: () => Promise<{ statements: string[] }>) => {
+    setError('')
+    setMessage('')
+    try {
+      const result = await change()
+      setMessage(result.statements.join(';\n'))
+      await loadTypes()
+      return true
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Change failed')
+      return false
+    }
+  }
+
+  const handleCreateEnum = async (e: FormEvent) => {
+    e.preventDefault()
+    const values = enumValues.split(',').map((v) => v.trim()).filter((v) => v !== '')
+    if (await run(() => api.createEnum({ name: enumName, values }))) {
+      setEnumName('')
+      setEnumValues('')
+    }
+  }
+
+  const handleAddEnumValue = (enumType: EnumType) => {
+    const value = window.prompt(`New value for ${enumType.schema}.${enumType.name}`)
+    if (value) {
+      run(() => api.alterEnum(enumType.schema, enumType.name, { add_value: value }))
+    }
+  }
+
+  const handleRenameEnumValue = (enumType: EnumType, from: string) => {
+    const to = window.prompt(`Rename "${from}" to`, from)
+    if (to && to !== from) {
+      run(() => api.alterEnum(enumType.schema, enumType.name, { rename_value: { from, to } }))
+    }
+  }
+
+  const handleCreateDomain = async (e: FormEvent) => {
+    e.preventDefault()
+    const ok = await run(() =>
+      api.createDomain({
+        name: domainName,
+        base_type: domainBaseType,
+        not_null: domainNotNull,
+        default: domainDefault || undefined,
+        check: domainCheck || undefined,
+      })
+    )
+    if (ok) {
+      setDomainName('')
+      setDomainNotNull(false)
+      setDomainDefault('')
+      setDomainCheck('')
+    }
+  }
+
+  const handleAddDomainCheck = (domain: DomainType) => {
+    const expression = window.prompt(`CHECK expression for ${domain.schema}.${domain.name} (use VALUE)`)
+    if (expression) {
+      run(() => api.alterDomain(domain.schema, domain.name, { add_check: { expression } }))
+    }
+  }
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
+      </div>
+    )
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">
+              Types
+            </h2>
+          </div>
+        </div>
+
+        {error && (
+          <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">{error}</div>
+        )}
+        {message && (
+          <pre className="mb-4 bg-green-50 border border-green-200 text-green-800 px-4 py-3 rounded text-xs whitespace-pre-wrap">
+            {message}
+          </pre>
+        )}
+
+        <div className="grid grid-cols-1 lg:grid-cols-2 gap-6">
+          {/* Enums */}
+          <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+            <div className="px-4 py-5 sm:px-6">
+              <h3 className="text-lg leading-6 font-medium text-gray-900">Enums</h3>
+              <p className="mt-1 text-sm text-gray-500">Click a value to rename it. Values can't be removed.</p>
+            </div>
+            <ul className="border-t border-gray-200 divide-y divide-gray-200">
+              {enums.length === 0 && <li className="px-4 py-4 sm:px-6 text-sm text-gray-500">No enum types</li>}
+              {enums.map((enumType) => (
+                <li key={`${enumType.schema}.${enumType.name}`} className="px-4 py-4 sm:px-6">
+                  <div className="flex items-center justify-between">
+                    <div className="text-sm font-medium text-indigo-600">
+                      {enumType.schema}.{enumType.name}
+                    </div>
+                    <button onClick={() => handleAddEnumValue(enumType)} className="text-sm text-indigo-600 hover:text-indigo-800">
+                      Add value
+                    </button>
+                  </div>
+                  <div className="mt-2 flex flex-wrap gap-2">
+                    {enumType.values.map((value) => (
+                      <button
+                        key={value}
+                        onClick={() => handleRenameEnumValue(enumType, value)}
+                        className="px-2 inline-flex text-xs leading-5 font-semibold rounded-full bg-gray-100 text-gray-800 hover:bg-gray-200"
+                      >
+                        {value}
+                      </button>
+                    ))}
+                  </div>
+                </li>
+              ))}
+            </ul>
+            <form onSubmit={handleCreateEnum} className="border-t border-gray-200 px-4 py-4 sm:px-6 space-y-3">
+              <input className={inputClass} placeholder="Name, e.g. mood" value={enumName} onChange={(e) => setEnumName(e.target.value)} required />
+              <input
+                className={inputClass}
+                placeholder="Values, comma-separated, e.g. sad, ok, happy"
+                value={enumValues}
+                onChange={(e) => setEnumValues(e.target.value)}
+                required
+              />
+              <button type="submit" className={buttonClass}>
+                Create enum
+              </button>
+            </form>
+          </div>
+
+          {/* Domains */}
+          <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+            <div className="px-4 py-5 sm:px-6">
+              <h3 className="text-lg leading-6 font-medium text-gray-900">Domains</h3>
+              <p className="mt-1 text-sm text-gray-500">Base types with defaults and constraints.</p>
+            </div>
+            <ul className="border-t border-gray-200 divide-y divide-gray-200">
+              {domains.length === 0 && <li className="px-4 py-4 sm:px-6 text-sm text-gray-500">No domain types</li>}
+              {domains.map((domain) => (
+                <li key={`${domain.schema}.${domain.name}`} className="px-4 py-4 sm:px-6">
+                  <div className="flex items-center justify-between">
+                    <div className="text-sm font-medium text-indigo-600">
+                      {domain.schema}.{domain.name}
+                      <span className="ml-2 text-gray-500 font-normal">
+                        {domain.base_type}
+                        {domain.not_null ? ' not null' : ''}
+                        {domain.default ? ` default ${domain.default}` : ''}
+                      </span>
+                    </div>
+                    <button onClick={() => handleAddDomainCheck(domain)} className="text-sm text-indigo-600 hover:text-indigo-800">
+                      Add check
+                    </button>
+                  </div>
+                  {domain.constraints.map((constraint) => (
+                    <div key={constraint.name} className="mt-1 flex items-center justify-between text-xs text-gray-500">
+                      <code>
+                        {constraint.name}: {constraint.definition}
+                      </code>
+                      <button
+                        onClick={() => run(() => api.alterDomain(domain.schema, domain.name, { drop_constraint: constraint.name }))}
+                        className="ml-2 text-red-600 hover:text-red-800"
+                      >
+                        Drop
+                      </button>
+                    </div>
+                  ))}
+                </li>
+              ))}
+            </ul>
+            <form onSubmit={handleCreateDomain} className="border-t border-gray-200 px-4 py-4 sm:px-6 space-y-3">
+              <input className={inputClass} placeholder="Name, e.g. email" value={domainName} onChange={(e) => setDomainName(e.target.value)} required />
+              <input
+                className={inputClass}
+                placeholder="Base type, e.g. text"
+                value={domainBaseType}
+                onChange={(e) => setDomainBaseType(e.target.value)}
+                required
+              />
+              <input
+                className={inputClass}
+                placeholder="Default (SQL expression, optional)"
+                value={domainDefault}
+                onChange={(e) => setDomainDefault(e.target.value)}
+              />
+              <input
+                className={inputClass}
+                placeholder="Check (SQL expression using VALUE, optional)"
+                value={domainCheck}
+                onChange={(e) => setDomainCheck(e.target.value)}
+              />
+              <label className="flex items-center text-sm text-gray-700">
+                <input type="checkbox" className="mr-2" checked={domainNotNull} onChange={(e) => setDomainNotNull(e.target.checked)} />
+                Not null
+              </label>
+              <button type="submit" className={buttonClass}>
+                Create domain
+              </button>
+            </form>
+          </div>
+        </div>
+      </div>
+    </>
+  )
+}
+
+export default TypesPage
@@ -0,0 +1,219 @@
+import { useState, useEffect, useCallback, type FormEvent } from 'react'
+import { api } from '../lib/api'
+import Header from '../components/Header'
+
+interface AuthUser {
+  id: string
+  email?: string
+  phone?: string
+  created_at: string
+  last_sign_in_at?: string | null
+  email_confirmed_at?: string | null
+  banned_until?: string | null
+}
+
+const inputClass =
+  'block w-full rounded-md border-gray-300 shadow-sm focus:border-indigo-500 focus:ring-indigo-500 sm:text-sm px-3 py-2 border'
+const buttonClass =
+  'inline-flex items-center px-3 py-2 border border-transparent text-sm leading-4 font-medium rounded-md text-white bg-indigo-600 hover:bg-indigo-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-indigo-500'
+const secondaryButtonClass =
+  'inline-flex items-center px-3 py-2 border border-gray-300 text-sm leading-4 font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50 disabled:opacity-50'
+
+const pageSize = 50
+
+// isBanned reports whether a user's ban is still in effect
+const isBanned = (user: AuthUser): boolean => !!user.banned_until && new Date(user.banned_until) > new Date()
+
+const formatTime = (time?: string | null): string => (time ? new Date(time).toLocaleString() : '-')
+
+function UsersPage() {
+  const [userEmail, setUserEmail] = useState<string>('')
+  const [users, setUsers] = useState<AuthUser[]>([])
+  const [page, setPage] = useState(1)
+  const [email, setEmail] = useState('')
+  const [password, setPassword] = useState('')
+  const [confirmed, setConfirmed] = useState(true)
+  const [loading, setLoading] = useState(true)
+  const [message, setMessage] = useState('')
+  const [error, setError] = useState('')
+
+  const loadUsers = useCallback(async () => {
+    try {
+      const data = await api.getAuthUsers(page, pageSize)
+      setUsers(data.users ?? [])
+      setError('')
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load users')
+    }
+  }, [page])
+
+  useEffect(() => {
+    api
+      .me()
+      .then((userData) => setUserEmail(userData.email))
+      .catch(() => {})
+  }, [])
+
+  useEffect(() => {
+    loadUsers().finally(() => setLoading(false))
+  }, [loadUsers])
+
+  // run performs an action on a user, then reloads the list
+  const run = async (action: () => Promise<unknown>, done: string) => {
+    setMessage('')
+    try {
+      await action()
+      setMessage(done)
+      await loadUsers()
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Request failed')
+    }
+  }
+
+  const handleCreate = async (e: FormEvent) => {
+    e.preventDefault()
+    await run(
+      () => api.createAuthUser({ email, password: password || undefined, email_confirm: confirmed }),
+      `Created ${email}`
+    )
+    setEmail('')
+    setPassword('')
+  }
+
+  const handleDelete = (user: AuthUser) => {
+    if (!window.confirm(`Delete ${user.email || user.phone || user.id}? This can't be undone.`)) return
+    run(() => api.deleteAuthUser(user.id), `Deleted ${user.email || user.id}`)
+  }
+
+  const handleBan = (user: AuthUser) => {
+    if (isBanned(user)) {
+      run(() => api.banAuthUser(user.id, false), `Lifted the ban on ${user.email || user.id}`)
+      return
+    }
+    const duration = window.prompt('Ban for how long? e.g. 24h, or leave empty to ban for good', '')
+    if (duration === null) return
+    run(() => api.banAuthUser(user.id, true, duration || undefined), `Banned ${user.email || user.id}`)
+  }
+
+  if (loading) {
+    return (
+      <div className="flex items-center justify-center h-64">
+        <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-indigo-600"></div>
+      </div>
+    )
+  }
+
+  return (
+    <>
+      <Header userEmail={userEmail} />
+      <div className="max-w-7xl mx-auto py-6 sm:px-6 lg:px-8">
+        <div className="md:flex md:items-center md:justify-between mb-6">
+          <div className="flex-1 min-w-0">
+            <h2 className="text-2xl font-bold leading-7 text-gray-900 sm:text-3xl sm:truncate">Auth Users</h2>
+          </div>
+          <form onSubmit={handleCreate} className="mt-4 flex items-center space-x-3 md:mt-0 md:ml-4">
+            <input
+              className={inputClass}
+              type="email"
+              placeholder="Email"
+              value={email}
+              onChange={(e) => setEmail(e.target.value)}
+              required
+            />
+            <input
+              className={inputClass}
+              type="password"
+              placeholder="Password (optional)"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+            />
+            <label className="inline-flex items-center text-sm text-gray-700 whitespace-nowrap">
+              <input type="checkbox" checked={confirmed} onChange={(e) => setConfirmed(e.target.checked)} className="mr-2" />
+              Confirmed
+            </label>
+            <button type="submit" className={buttonClass}>
+              Create
+            </button>
+          </form>
+        </div>
+
+        {error && <div className="mb-4 bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">{error}</div>}
+        {message && <div className="mb-4 bg-green-50 border border-green-200 text-green-700 px-4 py-3 rounded">{message}</div>}
+
+        <div className="bg-white shadow overflow-hidden sm:rounded-lg">
+          {users.length === 0 ? (
+            <div className="px-4 py-5 sm:px-6 text-sm text-gray-500">No users</div>
+          ) : (
+            <table className="min-w-full divide-y divide-gray-200 text-sm">
+              <thead className="bg-gray-50">
+                <tr>
+                  <th className="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">User</th>
+                  <th className="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Created</th>
+                  <th className="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Last sign-in</th>
+                  <th className="px-4 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Status</th>
+                  <th />
+                </tr>
+              </thead>
+              <tbody className="divide-y divide-gray-200">
+                {users.map((user) => (
+                  <tr key={user.id}>
+                    <td className="px-4 py-3">
+                      <div className="font-medium text-gray-900">{user.email || user.phone || '-'}</div>
+                      <div className="text-xs text-gray-400 font-mono">{user.id}</div>
+                    </td>
+                    <td className="px-4 py-3 text-gray-500">{formatTime(user.created_at)}</td>
+                    <td className="px-4 py-3 text-gray-500">{formatTime(user.last_sign_in_at)}</td>
+                    <td className="px-4 py-3">
+                      {isBanned(user) ? (
+                        <span className="px-2 rounded-full text-xs font-semibold bg-red-100 text-red-800">banned</span>
+                      ) : user.email && !user.email_confirmed_at ? (
+                        <span className="px-2 rounded-full text-xs font-semibold bg-yellow-100 text-yellow-800">unconfirmed</span>
+                      ) : (
+                        <span className="px-2 rounded-full text-xs font-semibold bg-green-100 text-green-800">active</span>
+                      )}
+                    </td>
+                    <td className="px-4 py-3 whitespace-nowrap text-right space-x-3">
+                      {user.email && !user.email_confirmed_at && (
+                        <button
+                          className="text-indigo-600 hover:text-indigo-900"
+                          onClick={() => run(() => api.confirmAuthUser(user.id), `Confirmed ${user.email}`)}
+                        >
+                          Confirm
+                        </button>
+                      )}
+                      {user.email && (
+                        <button
+                          className="text-indigo-600 hover:text-indigo-900"
+                          onClick={() => run(() => api.sendPasswordReset(user.id), `Sent a password reset to ${user.email}`)}
+                        >
+                          Reset password
+                        </button>
+                      )}
+                      <button className="text-yellow-700 hover:text-yellow-900" onClick={() => handleBan(user)}>
+                        {isBanned(user) ? 'Unban' : 'Ban'}
+                      </button>
+                      <button className="text-red-600 hover:text-red-800" onClick={() => handleDelete(user)}>
+                        Delete
+                      </button>
+                    </td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          )}
+        </div>
+
+        <div className="mt-4 flex justify-end space-x-2">
+          <button className={secondaryButtonClass} disabled={page === 1} onClick={() => setPage(page - 1)}>
+            Previous
+          </button>
+          <button className={secondaryButtonClass} disabled={users.length < pageSize} onClick={() => setPage(page + 1)}>
+            Next
+          </button>
+        </div>
+      </div>
+    </>
+  )
+}
+
+export default UsersPage
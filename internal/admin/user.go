@@ -3,6 +3,7 @@ package admin
 import (
 	"context"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
@@ -160,8 +161,12 @@ func Count(ctx context.Context, conn *pgx.Conn) (int, error) {
 
 // ConnectToDatabase establishes a connection to the database.
 //
-// It first tries to connect to an already-running database instance.
-// If that fails, it starts a new embedded database.
+// If supalite is serving dataDir, it connects to that instance, on the port
+// recorded in its postmaster.pid. Otherwise it tries a database already
+// listening on port, and only when nothing is running does it start a
+// temporary embedded database on dataDir. Starting a second Postgres on a
+// data directory that is in use would fail or damage it, so a running
+// instance that refuses the connection is an error.
 //
 // This allows admin commands to work whether the main server is running or not.
 //
@@ -176,24 +181,57 @@ func Count(ctx context.Context, conn *pgx.Conn) (int, error) {
 func ConnectToDatabase(port int, username, password, database, dataDir string) (*pgx.Conn, func(), error) {
 	ctx := context.Background()
 
-	// First, try to connect to an already-running database
-	connURL := fmt.Sprintf("postgres://%s:%s@localhost:%d/%s", username, password, port, database)
-	conn, err := pgx.Connect(ctx, connURL)
-	if err == nil {
-		// Successfully connected to running database
+	connect := func(socketDir string, port int) (*pgx.Conn, func(), error) {
+		conn, err := pgx.Connect(ctx, pg.ConnectionURL(username, password, database, socketDir, port))
+		if err != nil {
+			return nil, nil, err
+		}
 		cleanup := func() {
 			conn.Close(ctx)
 		}
 		return conn, cleanup, nil
 	}
 
-	// Connection failed, try starting a new embedded database
+	// A server using dataDir may listen on another port than the configured
+	// one (e.g. serve --pg-port), or on a unix socket only
+	if postmaster, err := pg.ReadPostmaster(pg.ClusterPath(dataDir)); err == nil && postmaster.Running() {
+		var socketDir string
+		if postmaster.SocketOnly() {
+			socketDir = postmaster.SocketDir
+		}
+		conn, cleanup, err := connect(socketDir, postmaster.Port)
+		if err != nil {
+			return nil, nil, fmt.Errorf("supalite is running on %s (PostgreSQL port %d) but connecting failed: %w", dataDir, postmaster.Port, err)
+		}
+		return conn, cleanup, nil
+	}
+
+	// Then try an already-running database on the configured port
+	if conn, cleanup, err := connect("", port); err == nil {
+		return conn, cleanup, nil
+	}
+
+	// Nothing is running, start a temporary embedded database. embedded-postgres
+	// empties its runtime path on start, so that gets a directory of its own.
+	runtimePath, err := os.MkdirTemp("", "supalite-admin-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+
 	dbCfg := pg.Config{
 		Port:        uint16(port),
 		Username:    username,
 		Password:    password,
 		Database:    database,
-		RuntimePath: dataDir,
+		DataDir:     dataDir,
+		RuntimePath: runtimePath,
+	}
+	// Open the cluster with the major version that created it, which may
+	// not be the default after supalite db upgrade
+	if major, err := pg.ClusterVersion(dataDir); err == nil && major != "" {
+		if version, err := pg.ResolveVersion(major); err == nil {
+			dbCfg.Version = version
+		}
 	}
 
 	db := pg.NewEmbeddedDatabase(dbCfg)
@@ -203,14 +241,16 @@ func ConnectToDatabase(port int, username, password, database, dataDir string) (
 	// Start database
 	if err := db.Start(ctx); err != nil {
 		cancel()
+		os.RemoveAll(runtimePath)
 		return nil, nil, fmt.Errorf("failed to start database: %w", err)
 	}
 
 	// Connect to database
-	conn, err = db.Connect(ctx)
+	conn, err := db.Connect(ctx)
 	if err != nil {
 		cancel()
 		db.Stop()
+		os.RemoveAll(runtimePath)
 		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
@@ -219,6 +259,7 @@ func ConnectToDatabase(port int, username, password, database, dataDir string) (
 		conn.Close(ctx)
 		db.Stop()
 		cancel()
+		os.RemoveAll(runtimePath)
 	}
 
 	return conn, cleanup, nil
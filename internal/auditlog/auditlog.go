@@ -0,0 +1,208 @@
+// Package auditlog records who changed which table through the REST API,
+// one row per successful write request, in audit.api_log:
+//
+//	SELECT ts, auth_role, auth_uid, method, table_name, query
+//	FROM audit.api_log ORDER BY id DESC LIMIT 20;
+//
+// Unlike change history (package history), which keeps every version of
+// the rows of tracked tables, the log records requests: which caller wrote
+// to which table and with what filters, for every table.
+//
+// Entries are written in the background, so requests don't wait on them.
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	DefaultInterval  = time.Hour // How often old entries are pruned
+	DefaultQueueSize = 1000      // Entries waiting to be written before new ones are dropped
+)
+
+var logger = log.Component("audit")
+
+// schemaSQL creates the log table. The audit schema is shared with change
+// history; API roles have no access to it.
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS audit;
+
+CREATE TABLE IF NOT EXISTS audit.api_log (
+	id bigserial PRIMARY KEY,
+	ts timestamptz NOT NULL DEFAULT now(),
+	method text NOT NULL,
+	table_schema name NOT NULL,
+	table_name name NOT NULL,
+	query text NOT NULL DEFAULT '',
+	status int NOT NULL,
+	auth_role text,
+	auth_uid text,
+	client_addr text
+);
+
+CREATE INDEX IF NOT EXISTS api_log_table ON audit.api_log (table_schema, table_name, id);
+CREATE INDEX IF NOT EXISTS api_log_ts ON audit.api_log USING brin (ts);
+`
+
+// PostgresAcquirer lends the writer pooled connections, on which it
+// creates audit.api_log, inserts entries, and prunes old ones.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Config holds the configuration for the writer.
+type Config struct {
+	Database  PostgresAcquirer
+	Retention time.Duration // Optional: how long entries are kept (default: forever)
+	Interval  time.Duration // Optional: how often old entries are pruned
+	QueueSize int           // Optional: entries buffered for writing (default: 1000)
+}
+
+// Entry is one write request.
+type Entry struct {
+	Time       time.Time
+	Method     string // POST, PUT, PATCH, or DELETE
+	Schema     string
+	Table      string
+	Query      string // The request's filters, e.g. "id=eq.42"
+	Status     int
+	Role       string // The caller's JWT role
+	UserID     string // The caller's JWT "sub", empty for API keys
+	ClientAddr string
+}
+
+// Writer creates the log table and writes entries to it.
+type Writer struct {
+	config  Config
+	entries chan Entry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewWriter creates a new writer.
+func NewWriter(cfg Config) *Writer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	return &Writer{config: cfg, entries: make(chan Entry, cfg.QueueSize)}
+}
+
+// Start creates the log table and begins writing entries, and pruning old
+// ones if a retention period is set.
+func (w *Writer) Start(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, schemaSQL)
+	conn.Release()
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	// The writer outlives the startup context
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.write()
+	}()
+	if w.config.Retention > 0 {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.prune(runCtx)
+		}()
+	}
+	return nil
+}
+
+// Record queues an entry for writing. When the queue is full, because the
+// database can't keep up, the entry is dropped and logged instead.
+func (w *Writer) Record(e Entry) {
+	select {
+	case w.entries <- e:
+	default:
+		logger.Warn("audit log queue full, entry dropped",
+			"method", e.Method, "table", e.Schema+"."+e.Table, "role", e.Role, "user", e.UserID)
+	}
+}
+
+// Stop writes the queued entries and stops. Record must not be called
+// after Stop.
+func (w *Writer) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+		close(w.entries)
+	}
+	w.wg.Wait()
+}
+
+func (w *Writer) write() {
+	for e := range w.entries {
+		if err := w.insert(e); err != nil {
+			logger.Warn("failed to write audit log entry", "error", err,
+				"method", e.Method, "table", e.Schema+"."+e.Table, "role", e.Role, "user", e.UserID)
+		}
+	}
+}
+
+func (w *Writer) insert(e Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO audit.api_log (ts, method, table_schema, table_name, query, status, auth_role, auth_uid, client_addr)
+		VALUES ($1, $2, $3, $4, $5, $6, nullif($7, ''), nullif($8, ''), nullif($9, ''))
+	`, e.Time, e.Method, e.Schema, e.Table, e.Query, e.Status, e.Role, e.UserID, e.ClientAddr)
+	return err
+}
+
+func (w *Writer) prune(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.deleteOld(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to prune audit log", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Writer) deleteOld(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM audit.api_log WHERE ts < now() - make_interval(secs => $1)", w.config.Retention.Seconds())
+	if err == nil && tag.RowsAffected() > 0 {
+		logger.Info("pruned audit log", "entries", tag.RowsAffected())
+	}
+	return err
+}
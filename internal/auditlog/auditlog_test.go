@@ -0,0 +1,18 @@
+package auditlog
+
+import (
+	"testing"
+)
+
+func TestRecord_QueueFull(t *testing.T) {
+	w := NewWriter(Config{QueueSize: 1})
+	w.Record(Entry{Method: "POST", Table: "todos"})
+	w.Record(Entry{Method: "DELETE", Table: "todos"})
+
+	if len(w.entries) != 1 {
+		t.Fatalf("queued %d entries, want 1", len(w.entries))
+	}
+	if e := <-w.entries; e.Method != "POST" {
+		t.Errorf("queued %s, want the first entry kept", e.Method)
+	}
+}
@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// releaseURL is where GoTrue binaries and their checksums are published,
+// by version and file name
+const releaseURL = "https://github.com/burggraf/supalite/releases/download/%s/%s"
+
+// checksumsFile lists the SHA-256 of every binary of a release, in
+// sha256sum format
+const checksumsFile = "checksums.txt"
+
+// binaryName is the release asset name for this platform, such as
+// gotrue-linux-amd64
+func binaryName() string {
+	return "gotrue-" + runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// BinDir returns the GoTrue download cache under a Supalite data directory
+func BinDir(dataDir string) string {
+	return filepath.Join(dataDir, "bin")
+}
+
+// defaultBinDir is the download cache used when none is configured
+func defaultBinDir() string {
+	return filepath.Join(os.TempDir(), "supalite-gotrue")
+}
+
+// cachePath is where the GoTrue binary for this version is kept under
+// binDir. Its SHA-256 is stored next to it with a .sha256 suffix.
+func cachePath(binDir string) string {
+	return filepath.Join(binDir, GoTrueVersion, binaryName())
+}
+
+// findGoTrueBinary searches for the GoTrue binary in various locations,
+// and downloads from GitHub releases if not found locally
+func findGoTrueBinary(binDir string) (string, error) {
+	if path, err := findLocalGoTrueBinary(binDir); err == nil {
+		return path, nil
+	}
+
+	// Not found locally, download from GitHub releases
+	path, err := InstallGoTrue(binDir)
+	if err != nil {
+		return "", fmt.Errorf("%w (%s)", err, offlineHint)
+	}
+	return path, nil
+}
+
+// offlineHint tells users without network access how to provide GoTrue
+const offlineHint = "to run offline, run 'supalite components install' on a machine with network access and copy <data-dir>/bin, " +
+	"build supalite with the GoTrue binary embedded (make build-embedded), or set --gotrue-binary"
+
+// configuredGoTrueBinary checks a GoTrue binary given explicitly, which is
+// used as is: no search, no download, and no checksum.
+func configuredGoTrueBinary(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("configured GoTrue binary: %w", err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return "", fmt.Errorf("configured GoTrue binary %s is not an executable file", path)
+	}
+	return filepath.Abs(path)
+}
+
+// findLocalGoTrueBinary searches for the GoTrue binary in various locations,
+// including the download cache, without touching the network
+func findLocalGoTrueBinary(binDir string) (string, error) {
+	// List of locations to search
+	searchPaths := []string{
+		"./bin/gotrue",
+		"./gotrue",
+		"/usr/local/bin/gotrue",
+		"/usr/bin/gotrue",
+	}
+
+	// Also search in PATH
+	if path := os.Getenv("PATH"); path != "" {
+		searchPaths = append(searchPaths, strings.Split(path, ":")...)
+	}
+
+	for _, path := range searchPaths {
+		if path == "" {
+			continue
+		}
+
+		// Check if the path is executable
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			// Check if it's executable
+			if info.Mode().Perm()&0111 != 0 {
+				return filepath.Abs(path)
+			}
+		}
+	}
+
+	if path, err := cachedGoTrueBinary(binDir); err == nil {
+		return path, nil
+	} else if embeddedGoTrue == nil {
+		return "", err
+	}
+
+	// Builds with the gotrue_embed tag carry the binary; it's unpacked into
+	// the cache because it has to be a file to run
+	return extractEmbeddedGoTrue(binDir)
+}
+
+// cachedGoTrueBinary returns a previously downloaded GoTrue binary without
+// touching the network. A binary that no longer matches the checksum it
+// was downloaded with is treated as missing.
+func cachedGoTrueBinary(binDir string) (string, error) {
+	path := cachePath(binDir)
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0111 == 0 {
+		return "", fmt.Errorf("GoTrue binary not found locally (looked in ./bin, PATH, and %s)", path)
+	}
+
+	sum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return "", fmt.Errorf("GoTrue binary %s has no checksum: %w", path, err)
+	}
+	if err := verifyFile(path, strings.TrimSpace(string(sum))); err != nil {
+		gotrueLogger.Warn("cached GoTrue binary is corrupt", "path", path, "error", err)
+		return "", err
+	}
+	return path, nil
+}
+
+// CachedGoTrue returns the GoTrue binary cached in binDir, if it was
+// installed and is intact, without touching the network.
+func CachedGoTrue(binDir string) (string, error) {
+	return cachedGoTrueBinary(binDir)
+}
+
+// InstallGoTrue downloads the GoTrue binary for this version and platform
+// into binDir, verifying it against the release's published checksums. A
+// binary already cached there is kept. It returns the binary's path.
+func InstallGoTrue(binDir string) (string, error) {
+	if path, err := cachedGoTrueBinary(binDir); err == nil {
+		return path, nil
+	}
+
+	path := cachePath(binDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	checksums, err := download(fmt.Sprintf(releaseURL, GoTrueVersion, checksumsFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to download GoTrue checksums: %w", err)
+	}
+	want, err := findChecksum(checksums, binaryName())
+	if err != nil {
+		return "", err
+	}
+
+	downloadURL := fmt.Sprintf(releaseURL, GoTrueVersion, binaryName())
+	gotrueLogger.Info("downloading GoTrue", "url", downloadURL)
+
+	data, err := download(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download GoTrue: %w", err)
+	}
+	if got := sha256Hex(bytes.NewReader(data)); got != want {
+		return "", fmt.Errorf("GoTrue download is corrupt: SHA-256 %s, want %s", got, want)
+	}
+
+	if err := writeCached(path, data, want); err != nil {
+		return "", err
+	}
+
+	gotrueLogger.Info("downloaded GoTrue", "path", path)
+	return path, nil
+}
+
+// extractEmbeddedGoTrue writes the binary embedded at build time into the
+// cache under binDir, verified against the checksum embedded with it.
+func extractEmbeddedGoTrue(binDir string) (string, error) {
+	want := strings.TrimSpace(embeddedGoTrueSum)
+	if got := sha256Hex(bytes.NewReader(embeddedGoTrue)); got != want {
+		return "", fmt.Errorf("embedded GoTrue binary is corrupt: SHA-256 %s, want %s", got, want)
+	}
+
+	path := cachePath(binDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeCached(path, embeddedGoTrue, want); err != nil {
+		return "", err
+	}
+	gotrueLogger.Info("unpacked embedded GoTrue", "path", path)
+	return path, nil
+}
+
+// writeCached stores a verified binary at path along with its checksum.
+// The binary is written next to path and renamed, so an interrupted write
+// never leaves a partial binary in the cache.
+func writeCached(path string, data []byte, sum string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write GoTrue binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write GoTrue binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write GoTrue binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to write GoTrue binary: %w", err)
+	}
+	if err := os.WriteFile(path+".sha256", []byte(sum+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write GoTrue checksum: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write GoTrue binary: %w", err)
+	}
+	return nil
+}
+
+// download fetches url into memory.
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// findChecksum returns the SHA-256 listed for name in a sha256sum-format
+// file ("<hex>  <name>" per line, with "*" before binary-mode names).
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s", checksumsFile, name)
+}
+
+// verifyFile checks that the file at path has the given SHA-256.
+func verifyFile(path, want string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if got := sha256Hex(f); got != want {
+		return fmt.Errorf("SHA-256 %s, want %s", got, want)
+	}
+	return nil
+}
+
+// sha256Hex returns the hex SHA-256 of everything r yields, or "" if r
+// fails.
+func sha256Hex(r io.Reader) string {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindChecksum(t *testing.T) {
+	checksums := []byte(strings.Join([]string{
+		"aaaa  gotrue-darwin-arm64",
+		"BBBB *gotrue-linux-amd64",
+		"",
+	}, "\n"))
+
+	if got, err := findChecksum(checksums, "gotrue-darwin-arm64"); err != nil || got != "aaaa" {
+		t.Errorf("findChecksum(darwin) = %q, %v, want aaaa", got, err)
+	}
+	if got, err := findChecksum(checksums, "gotrue-linux-amd64"); err != nil || got != "bbbb" {
+		t.Errorf("findChecksum(linux) = %q, %v, want bbbb", got, err)
+	}
+	if _, err := findChecksum(checksums, "gotrue-windows-amd64"); err == nil {
+		t.Error("findChecksum() should fail for an unlisted binary")
+	}
+}
+
+func TestCachedGoTrueBinary(t *testing.T) {
+	binDir := t.TempDir()
+	path := cachePath(binDir)
+
+	if _, err := cachedGoTrueBinary(binDir); err == nil {
+		t.Fatal("cachedGoTrueBinary() found a binary in an empty cache")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cachedGoTrueBinary(binDir); err == nil {
+		t.Error("cachedGoTrueBinary() accepted a binary without a checksum")
+	}
+
+	// sha256("binary")
+	sum := "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd"
+	if err := os.WriteFile(path+".sha256", []byte(sum+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cachedGoTrueBinary(binDir); err != nil || got != path {
+		t.Errorf("cachedGoTrueBinary() = %q, %v, want %q", got, err, path)
+	}
+
+	if err := os.WriteFile(path, []byte("corrupt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cachedGoTrueBinary(binDir); err == nil {
+		t.Error("cachedGoTrueBinary() accepted a binary that doesn't match its checksum")
+	}
+}
+
+func TestConfiguredGoTrueBinary(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "gotrue")
+	if err := os.WriteFile(exe, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "gotrue.txt")
+	if err := os.WriteFile(plain, []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := configuredGoTrueBinary(exe); err != nil || got != exe {
+		t.Errorf("configuredGoTrueBinary(executable) = %q, %v, want %q", got, err, exe)
+	}
+	for _, path := range []string{plain, dir, filepath.Join(dir, "missing")} {
+		if _, err := configuredGoTrueBinary(path); err == nil {
+			t.Errorf("configuredGoTrueBinary(%q) should fail", path)
+		}
+	}
+}
+
+func TestExtractEmbeddedGoTrue(t *testing.T) {
+	defer func(data []byte, sum string) {
+		embeddedGoTrue, embeddedGoTrueSum = data, sum
+	}(embeddedGoTrue, embeddedGoTrueSum)
+
+	binDir := t.TempDir()
+	embeddedGoTrue = []byte("binary")
+	embeddedGoTrueSum = "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd\n"
+
+	path, err := extractEmbeddedGoTrue(binDir)
+	if err != nil {
+		t.Fatalf("extractEmbeddedGoTrue() error = %v", err)
+	}
+	// The unpacked binary is an ordinary, verified cache entry
+	if got, err := cachedGoTrueBinary(binDir); err != nil || got != path {
+		t.Errorf("cachedGoTrueBinary() = %q, %v, want %q", got, err, path)
+	}
+
+	embeddedGoTrueSum = "0000"
+	if _, err := extractEmbeddedGoTrue(t.TempDir()); err == nil {
+		t.Error("extractEmbeddedGoTrue() accepted a binary that doesn't match its checksum")
+	}
+}
@@ -1,6 +1,10 @@
 package auth
 
-import "time"
+import (
+	"time"
+
+	"github.com/markb/supalite/internal/limits"
+)
 
 // EmailConfig holds email configuration for GoTrue
 type EmailConfig struct {
@@ -23,6 +27,97 @@ type EmailConfig struct {
 	// Capture mode configuration
 	CaptureMode bool
 	CapturePort int
+
+	// Capture sink: "database" (default), "maildir", or "webhook"
+	CaptureSink       string
+	CaptureDir        string
+	CaptureWebhookURL string
+	// CaptureWebhookSecret signs webhook sink requests when set
+	CaptureWebhookSecret string
+}
+
+// SMSConfig holds phone auth configuration for GoTrue
+type SMSConfig struct {
+	// Provider is "twilio", "twilio_verify", or "messagebird"
+	Provider string
+
+	// Twilio and Twilio Verify credentials
+	TwilioAccountSID        string
+	TwilioAuthToken         string
+	TwilioMessageServiceSID string
+
+	// MessageBird credentials
+	MessageBirdAccessKey  string
+	MessageBirdOriginator string
+
+	// Message template and code settings (default: GoTrue's)
+	Template  string
+	OTPExp    int
+	OTPLength int
+
+	// Autoconfirm skips phone confirmation when true
+	Autoconfirm bool
+
+	// CaptureMode stores OTP messages in public.captured_sms through
+	// GoTrue's send SMS hook instead of sending them
+	CaptureMode bool
+}
+
+// SMSCaptureHook is the Postgres function GoTrue calls in SMS capture mode
+const SMSCaptureHook = "admin.capture_sms"
+
+// MFAConfig controls multi-factor enrollment and verification
+// (GOTRUE_MFA_*). Unset fields keep GoTrue's defaults.
+type MFAConfig struct {
+	TOTPEnroll         *bool // Default: true
+	TOTPVerify         *bool // Default: true
+	PhoneEnroll        bool
+	PhoneVerify        bool
+	MaxEnrolledFactors int
+	MaxVerifiedFactors int
+	ChallengeExpiry    time.Duration
+}
+
+// CaptchaConfig makes GoTrue require a captcha token on sign up, sign in,
+// and password recovery (GOTRUE_SECURITY_CAPTCHA_*)
+type CaptchaConfig struct {
+	Provider string // "hcaptcha" or "turnstile"
+	Secret   string
+}
+
+// PasswordPolicy sets the password requirements (GOTRUE_PASSWORD_*)
+type PasswordPolicy struct {
+	MinLength int
+
+	// RequiredCharacters lists character sets a password must draw from,
+	// separated by ":", e.g. "abcdefghijklmnopqrstuvwxyz:0123456789"
+	RequiredCharacters string
+}
+
+// RateLimits overrides GoTrue's per-endpoint rate limits
+// (GOTRUE_RATE_LIMIT_*). Zero values keep GoTrue's defaults.
+type RateLimits struct {
+	EmailSent      float64 // Emails per hour
+	SMSSent        float64 // SMS messages per hour
+	Verify         float64 // Verifications per 5 minutes per IP
+	TokenRefresh   float64 // Token refreshes per 5 minutes per IP
+	OTP            float64 // OTP and magic link requests per 5 minutes per IP
+	AnonymousUsers float64 // Anonymous sign-ins per hour per IP
+	SSO            float64 // SSO requests per 5 minutes per IP
+	MFA            float64 // MFA challenges and verifications per minute per IP
+
+	// Header names the request header holding the client IP, for
+	// deployments behind a proxy
+	Header string
+}
+
+// ExternalProvider configures an OAuth provider (GOTRUE_EXTERNAL_<NAME>_*)
+type ExternalProvider struct {
+	Enabled     bool
+	ClientID    string
+	Secret      string
+	RedirectURI string // Default: <PublicURL or SiteURL>/auth/v1/callback
+	URL         string // Optional: base URL of self-hosted providers
 }
 
 // Config holds the configuration for the GoTrue auth server
@@ -39,6 +134,10 @@ type Config struct {
 	// SiteURL is the base URL of the application (for callbacks, etc.)
 	SiteURL string
 
+	// PublicURL is the address other devices reach supalite at. Links in
+	// auth emails use it instead of SiteURL when set.
+	PublicURL string
+
 	// URI is the base URI for the auth API (default: /auth/v1)
 	URI string
 
@@ -59,6 +158,41 @@ type Config struct {
 
 	// Email configuration for sending auth emails
 	Email *EmailConfig
+
+	// SMS configuration for phone auth (default: phone auth disabled)
+	SMS *SMSConfig
+
+	// Hardening settings (default: GoTrue's defaults)
+	MFA        *MFAConfig
+	Captcha    *CaptchaConfig
+	Password   *PasswordPolicy
+	RateLimits *RateLimits
+
+	// External holds OAuth providers by GoTrue name ("google", "github", ...)
+	External map[string]ExternalProvider
+
+	// BinaryPath is a GoTrue binary to run instead of searching for one
+	// and downloading it when missing (default: none)
+	BinaryPath string
+
+	// BinDir is where downloaded GoTrue binaries are cached, one directory
+	// per version (default: a directory under os.TempDir)
+	BinDir string
+
+	// DisableDownload fails startup instead of downloading a missing GoTrue binary
+	DisableDownload bool
+
+	// Limits caps the GoTrue process's memory and CPU (default: none)
+	Limits limits.Limits
+
+	// TracingEndpoint is an OTLP/HTTP collector GoTrue sends its own
+	// traces to, continuing the traces of proxied requests (default: none)
+	TracingEndpoint string
+
+	// OnExit is called after GoTrue exits unexpectedly and a restart was
+	// attempted, with the exit error and the restart error (nil when
+	// GoTrue came back). Optional.
+	OnExit func(exitErr, restartErr error)
 }
 
 // DefaultConfig returns a configuration with sensible defaults
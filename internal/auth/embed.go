@@ -0,0 +1,14 @@
+//go:build gotrue_embed
+
+package auth
+
+import _ "embed"
+
+// The GoTrue binary for the target platform and its SHA-256, placed in
+// embedded/ by make embed-gotrue before building with -tags gotrue_embed
+
+//go:embed embedded/gotrue
+var embeddedGoTrue []byte
+
+//go:embed embedded/gotrue.sha256
+var embeddedGoTrueSum string
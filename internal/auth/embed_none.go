@@ -0,0 +1,10 @@
+//go:build !gotrue_embed
+
+package auth
+
+// Without the gotrue_embed build tag no binary is embedded, and GoTrue is
+// found on disk or downloaded (see findGoTrueBinary)
+var (
+	embeddedGoTrue    []byte
+	embeddedGoTrueSum string
+)
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeUsers answers the auth.users lookup with a single user, or none.
+type fakeUsers struct {
+	user  *fakeUser
+	query string
+}
+
+type fakeUser struct {
+	id, email   string
+	bannedUntil *time.Time
+}
+
+func (f *fakeUsers) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	f.query = sql
+	return fakeRow{f.user}
+}
+
+type fakeRow struct{ user *fakeUser }
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.user == nil {
+		return pgx.ErrNoRows
+	}
+	*dest[0].(*string) = r.user.id
+	*dest[1].(*string) = r.user.email
+	*dest[2].(*string) = "authenticated"
+	*dest[3].(*string) = "authenticated"
+	*dest[4].(*[]byte) = []byte(`{"provider":"email"}`)
+	*dest[5].(*[]byte) = []byte(`{}`)
+	*dest[6].(**time.Time) = r.user.bannedUntil
+	return nil
+}
+
+// fakeSigner records the claims and lifetime of the token it mints.
+type fakeSigner struct {
+	claims   map[string]interface{}
+	lifetime time.Duration
+}
+
+func (s *fakeSigner) GenerateUserToken(claims map[string]interface{}, lifetime time.Duration) (string, error) {
+	s.claims, s.lifetime = claims, lifetime
+	return "token", nil
+}
+
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	user := &fakeUser{id: "6f7d1c2e-0000-4000-8000-000000000001", email: "a@example.com"}
+
+	tests := []struct {
+		name      string
+		expiresIn int
+		want      time.Duration
+	}{
+		{"default lifetime", 0, DefaultImpersonationLifetime},
+		{"requested lifetime", 600, 10 * time.Minute},
+		{"capped lifetime", int((48 * time.Hour).Seconds()), MaxImpersonationLifetime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &fakeSigner{}
+			result, err := Impersonate(ctx, &fakeUsers{user: user}, signer, ImpersonateRequest{Email: user.email, ExpiresIn: tt.expiresIn})
+			if err != nil {
+				t.Fatalf("Impersonate() error = %v", err)
+			}
+			if signer.lifetime != tt.want || result.ExpiresIn != int(tt.want.Seconds()) {
+				t.Errorf("lifetime = %v, expires_in = %d; want %v", signer.lifetime, result.ExpiresIn, tt.want)
+			}
+			if signer.claims["sub"] != user.id || signer.claims["role"] != "authenticated" || signer.claims["impersonated"] != true {
+				t.Errorf("claims = %v", signer.claims)
+			}
+		})
+	}
+
+	// Unknown and deleted users are not found
+	users := &fakeUsers{}
+	if _, err := Impersonate(ctx, users, &fakeSigner{}, ImpersonateRequest{UserID: user.id}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: error = %v, want ErrUserNotFound", err)
+	}
+	if !strings.Contains(users.query, "deleted_at IS NULL") {
+		t.Error("lookup does not skip deleted users")
+	}
+
+	// Banned users are refused until the ban ends
+	until := time.Now().Add(time.Hour)
+	banned := &fakeUser{id: user.id, email: user.email, bannedUntil: &until}
+	if _, err := Impersonate(ctx, &fakeUsers{user: banned}, &fakeSigner{}, ImpersonateRequest{UserID: user.id}); !errors.Is(err, ErrUserBanned) {
+		t.Errorf("banned user: error = %v, want ErrUserBanned", err)
+	}
+	ended := time.Now().Add(-time.Hour)
+	unbanned := &fakeUser{id: user.id, email: user.email, bannedUntil: &ended}
+	if _, err := Impersonate(ctx, &fakeUsers{user: unbanned}, &fakeSigner{}, ImpersonateRequest{UserID: user.id}); err != nil {
+		t.Errorf("user whose ban ended: error = %v", err)
+	}
+
+	if _, err := Impersonate(ctx, &fakeUsers{user: user}, &fakeSigner{}, ImpersonateRequest{}); err == nil {
+		t.Error("Impersonate() without user_id or email succeeded")
+	}
+}
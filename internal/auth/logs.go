@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+// gotrueLogger logs GoTrue's own output, and about the GoTrue binary.
+var gotrueLogger = log.Component("gotrue")
+
+// logGoTrueLine relays a line of GoTrue output through the logger with
+// component=gotrue, so it is filtered by level and shows up in the
+// dashboard's log viewer.
+func logGoTrueLine(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	level, msg, args := parseGoTrueLine(line)
+	gotrueLogger.Log(level, msg, args...)
+}
+
+// parseGoTrueLine parses a GoTrue log line. GoTrue logs JSON objects with
+// level and msg fields; the other fields are returned as sorted key/value
+// arguments. Lines that aren't JSON, such as panics, are logged as they are
+// at info level, or error level for panics.
+func parseGoTrueLine(line string) (log.Level, string, []interface{}) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		if strings.HasPrefix(line, "panic:") || strings.HasPrefix(line, "fatal error:") {
+			return log.LevelError, line, nil
+		}
+		return log.LevelInfo, line, nil
+	}
+
+	level := log.LevelInfo
+	if name, ok := fields["level"].(string); ok {
+		level, _ = log.ParseLevel(name)
+	}
+	msg, _ := fields["msg"].(string)
+	delete(fields, "level")
+	delete(fields, "msg")
+	delete(fields, "time") // The logger adds its own timestamp
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		name := key
+		if key == "component" {
+			// Keep GoTrue's own component (api, mailer, ...) apart from ours
+			name = "gotrue_component"
+		}
+		value := fields[key]
+		if _, isString := value.(string); !isString {
+			if data, err := json.Marshal(value); err == nil {
+				value = string(data)
+			} else {
+				value = fmt.Sprint(value)
+			}
+		}
+		args = append(args, name, value)
+	}
+	return level, msg, args
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+func TestParseGoTrueLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantLevel log.Level
+		wantMsg   string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "request log",
+			line:      `{"component":"api","level":"info","method":"POST","msg":"request completed","path":"/token","status":200,"time":"2026-01-29T12:00:00Z"}`,
+			wantLevel: log.LevelInfo,
+			wantMsg:   "request completed",
+			wantArgs:  []interface{}{"gotrue_component", "api", "method", "POST", "path", "/token", "status", "200"},
+		},
+		{
+			name:      "warning",
+			line:      `{"level":"warning","msg":"smtp not configured"}`,
+			wantLevel: log.LevelWarn,
+			wantMsg:   "smtp not configured",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "fatal",
+			line:      `{"level":"fatal","msg":"unable to connect","error":"connection refused"}`,
+			wantLevel: log.LevelError,
+			wantMsg:   "unable to connect",
+			wantArgs:  []interface{}{"error", "connection refused"},
+		},
+		{
+			name:      "plain text",
+			line:      "Migrations applied",
+			wantLevel: log.LevelInfo,
+			wantMsg:   "Migrations applied",
+		},
+		{
+			name:      "panic",
+			line:      "panic: runtime error: invalid memory address",
+			wantLevel: log.LevelError,
+			wantMsg:   "panic: runtime error: invalid memory address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, msg, args := parseGoTrueLine(tt.line)
+			if level != tt.wantLevel {
+				t.Errorf("level = %v, want %v", level, tt.wantLevel)
+			}
+			if msg != tt.wantMsg {
+				t.Errorf("msg = %q, want %q", msg, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
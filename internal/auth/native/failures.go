@@ -0,0 +1,66 @@
+package native
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxVerifyFailures is how many wrong codes a pending confirmation or
+// recovery token survives. Codes have six digits and stay valid for
+// otpExpiry, so without a cap anyone knowing an email address could guess
+// their way into the account.
+const maxVerifyFailures = 5
+
+// verifyFailures counts the wrong codes entered for each email address
+// since its current token was issued.
+type verifyFailures struct {
+	mu     sync.Mutex
+	counts map[string]verifyFailure
+}
+
+type verifyFailure struct {
+	count int
+	since time.Time // First failure, counts older than otpExpiry are dropped
+}
+
+func newVerifyFailures() *verifyFailures {
+	return &verifyFailures{counts: make(map[string]verifyFailure)}
+}
+
+// add records a wrong code for email. It reports whether that was one too
+// many, in which case the caller must invalidate the user's tokens; the
+// count then starts over.
+func (f *verifyFailures) add(email string, now time.Time) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Tokens outlive no count, so expired counts can go
+	for key, failure := range f.counts {
+		if now.Sub(failure.since) > otpExpiry {
+			delete(f.counts, key)
+		}
+	}
+
+	failure, ok := f.counts[email]
+	if !ok {
+		failure.since = now
+	}
+	failure.count++
+	if failure.count >= maxVerifyFailures {
+		delete(f.counts, email)
+		return true
+	}
+	f.counts[email] = failure
+	return false
+}
+
+// reset forgets the failures of email, when a token was redeemed or a new
+// one issued.
+func (f *verifyFailures) reset(email string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.counts, email)
+}
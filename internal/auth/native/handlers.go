@@ -0,0 +1,617 @@
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// otpExpiry matches GoTrue's default GOTRUE_MAILER_OTP_EXP
+const otpExpiry = 24 * time.Hour
+
+// maxPasswordLength is the longest password bcrypt can hash
+const maxPasswordLength = 72
+
+var errEmailTaken = errors.New("email address already registered")
+
+// apiError is GoTrue's error body.
+type apiError struct {
+	Code      int    `json:"code"`
+	ErrorCode string `json:"error_code"`
+	Msg       string `json:"msg"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiError{Code: status, ErrorCode: code, Msg: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeInternalError(w http.ResponseWriter, what string, err error) {
+	logger.Error("native auth: "+what, "error", err)
+	writeError(w, http.StatusInternalServerError, "unexpected_failure", "Unexpected failure, please check server logs for more information")
+}
+
+// withTx runs fn in a transaction that commits when fn returns nil.
+func (s *Server) withTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	conn, err := s.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkPassword applies the minimum password length.
+func (s *Server) checkPassword(w http.ResponseWriter, password string) bool {
+	if len(password) < s.config.PasswordMinLength {
+		writeError(w, http.StatusUnprocessableEntity, "weak_password",
+			fmt.Sprintf("Password should be at least %d characters.", s.config.PasswordMinLength))
+		return false
+	}
+	if len(password) > maxPasswordLength {
+		writeError(w, http.StatusUnprocessableEntity, "validation_failed", "Password cannot be longer than 72 characters")
+		return false
+	}
+	return true
+}
+
+func validEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	return at > 0 && at < len(email)-1 && !strings.ContainsAny(email, " \t\r\n")
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"version":     "native",
+		"name":        "GoTrue",
+		"description": "GoTrue is a user registration and authentication API",
+	})
+}
+
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"external":           map[string]bool{"email": true, "phone": false},
+		"disable_signup":     false,
+		"mailer_autoconfirm": s.autoconfirm(),
+		"phone_autoconfirm":  false,
+		"sms_provider":       "",
+		"saml_enabled":       false,
+	})
+}
+
+func (s *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string                 `json:"email"`
+		Password string                 `json:"password"`
+		Data     map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+	if !validEmail(email) {
+		writeError(w, http.StatusBadRequest, "validation_failed", "Unable to validate email address: invalid format")
+		return
+	}
+	if !s.checkPassword(w, body.Password) {
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeInternalError(w, "failed to hash password", err)
+		return
+	}
+	if body.Data == nil {
+		body.Data = map[string]interface{}{}
+	}
+
+	autoconfirm := s.autoconfirm()
+	var result interface{}
+	var exists bool
+	err = s.withTx(r.Context(), func(tx pgx.Tx) error {
+		if _, err := userByEmail(r.Context(), tx, email); err == nil {
+			exists = true
+			return nil
+		} else if !errors.Is(err, errNoUser) {
+			return err
+		}
+
+		u, err := createUser(r.Context(), tx, email, string(hash), autoconfirm, nil, body.Data)
+		if err != nil {
+			return err
+		}
+
+		if autoconfirm {
+			sess, err := s.newSession(r.Context(), tx, u, "password")
+			if err != nil {
+				return err
+			}
+			result = sess
+			return nil
+		}
+
+		if err := s.sendToken(r.Context(), tx, u, mailConfirmation, r.URL.Query().Get("redirect_to")); err != nil {
+			return err
+		}
+		result = u
+		return nil
+	})
+	if err != nil {
+		writeInternalError(w, "signup failed", err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusUnprocessableEntity, "user_already_exists", "User already registered")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// sendToken stores a fresh confirmation or recovery token for u and mails it.
+func (s *Server) sendToken(ctx context.Context, tx pgx.Tx, u *user, kind mailKind, redirectTo string) error {
+	otp, err := newOTP()
+	if err != nil {
+		return err
+	}
+	column, sentAt := "confirmation_token", "confirmation_sent_at"
+	if kind == mailRecovery {
+		column, sentAt = "recovery_token", "recovery_sent_at"
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE auth.users SET "+column+" = $1, "+sentAt+" = now(), updated_at = now() WHERE id::text = $2",
+		tokenHash(u.Email, otp), u.ID); err != nil {
+		return err
+	}
+	s.failures.reset(u.Email)
+	now := time.Now()
+	if kind == mailRecovery {
+		u.RecoverySentAt = &now
+	} else {
+		u.ConfirmationSent = &now
+	}
+	return s.sendMail(kind, u.Email, otp, redirectTo)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	switch grant := r.URL.Query().Get("grant_type"); grant {
+	case "password":
+		s.passwordGrant(w, r)
+	case "refresh_token":
+		s.refreshGrant(w, r)
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported_grant_type")
+	}
+}
+
+func (s *Server) passwordGrant(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+
+	var sess *session
+	var status int
+	var code, msg string
+	err := s.withTx(r.Context(), func(tx pgx.Tx) error {
+		u, err := userByEmail(r.Context(), tx, strings.TrimSpace(body.Email))
+		if errors.Is(err, errNoUser) {
+			status, code, msg = http.StatusBadRequest, "invalid_credentials", "Invalid login credentials"
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case u.encryptedPassword == "" || bcrypt.CompareHashAndPassword([]byte(u.encryptedPassword), []byte(body.Password)) != nil:
+			status, code, msg = http.StatusBadRequest, "invalid_credentials", "Invalid login credentials"
+		case !u.confirmed():
+			status, code, msg = http.StatusBadRequest, "email_not_confirmed", "Email not confirmed"
+		case u.banned():
+			status, code, msg = http.StatusBadRequest, "user_banned", "User is banned"
+		default:
+			sess, err = s.newSession(r.Context(), tx, u, "password")
+		}
+		return err
+	})
+	if err != nil {
+		writeInternalError(w, "password grant failed", err)
+		return
+	}
+	if sess == nil {
+		writeError(w, status, code, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) refreshGrant(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+
+	var sess *session
+	err := s.withTx(r.Context(), func(tx pgx.Tx) error {
+		var err error
+		sess, err = s.refreshSession(r.Context(), tx, body.RefreshToken)
+		return err
+	})
+	if errors.Is(err, errInvalidRefreshToken) {
+		writeError(w, http.StatusBadRequest, "refresh_token_not_found", "Invalid Refresh Token: Refresh Token Not Found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "refresh token grant failed", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+// bearer verifies the request's access token, writing a 401 when it is
+// missing or invalid.
+func (s *Server) bearer(w http.ResponseWriter, r *http.Request) (userID, sessionID string, ok bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "no_authorization", "This endpoint requires a Bearer token")
+		return "", "", false
+	}
+	userID, sessionID, err := s.parseAccessToken(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "bad_jwt", "invalid JWT: unable to parse or verify signature")
+		return "", "", false
+	}
+	return userID, sessionID, true
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := s.bearer(w, r)
+	if !ok {
+		return
+	}
+
+	var query string
+	var args []interface{}
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "", "global":
+		query, args = "DELETE FROM auth.sessions WHERE user_id::text = $1", []interface{}{userID}
+	case "local":
+		query, args = "DELETE FROM auth.sessions WHERE id::text = $1", []interface{}{sessionID}
+	case "others":
+		query, args = "DELETE FROM auth.sessions WHERE user_id::text = $1 AND id::text <> $2", []interface{}{userID, sessionID}
+	default:
+		writeError(w, http.StatusBadRequest, "validation_failed", "Unsupported logout scope \""+scope+"\"")
+		return
+	}
+
+	err := s.withTx(r.Context(), func(tx pgx.Tx) error {
+		_, err := tx.Exec(r.Context(), query, args...)
+		return err
+	})
+	if err != nil {
+		writeInternalError(w, "logout failed", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRecover(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+	if !validEmail(strings.TrimSpace(body.Email)) {
+		writeError(w, http.StatusBadRequest, "validation_failed", "Unable to validate email address: invalid format")
+		return
+	}
+
+	err := s.withTx(r.Context(), func(tx pgx.Tx) error {
+		u, err := userByEmail(r.Context(), tx, strings.TrimSpace(body.Email))
+		if errors.Is(err, errNoUser) {
+			// Answer as if the mail was sent, so addresses can't be probed
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.sendToken(r.Context(), tx, u, mailRecovery, r.URL.Query().Get("redirect_to"))
+	})
+	if err != nil {
+		writeInternalError(w, "failed to send recovery email", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// verifyColumn maps a verification type to the column its token is kept in.
+func verifyColumn(verifyType string) (string, bool) {
+	switch verifyType {
+	case "signup", "email":
+		return "confirmation_token", true
+	case "recovery":
+		return "recovery_token", true
+	}
+	return "", false
+}
+
+// verify redeems a confirmation or recovery token and starts a session.
+// It returns a nil session when the token is unknown or expired.
+func (s *Server) verify(ctx context.Context, verifyType, hash string) (*session, error) {
+	column, _ := verifyColumn(verifyType)
+	var sess *session
+	err := s.withTx(ctx, func(tx pgx.Tx) error {
+		u, err := userByToken(ctx, tx, column, hash)
+		if errors.Is(err, errNoUser) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sentAt := u.ConfirmationSent
+		if column == "recovery_token" {
+			sentAt = u.RecoverySentAt
+		}
+		if sentAt == nil || time.Since(*sentAt) > otpExpiry || u.banned() {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx,
+			"UPDATE auth.users SET "+column+" = '', email_confirmed_at = COALESCE(email_confirmed_at, now()), updated_at = now() WHERE id::text = $1",
+			u.ID); err != nil {
+			return err
+		}
+		if u, err = userByID(ctx, tx, u.ID); err != nil {
+			return err
+		}
+		sess, err = s.newSession(ctx, tx, u, "otp")
+		return err
+	})
+	return sess, err
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Type      string `json:"type"`
+		Token     string `json:"token"`
+		TokenHash string `json:"token_hash"`
+		Email     string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+	if _, ok := verifyColumn(body.Type); !ok {
+		writeError(w, http.StatusBadRequest, "validation_failed", "Verify requires a verification type")
+		return
+	}
+	hash := body.TokenHash
+	if hash == "" {
+		if body.Token == "" || body.Email == "" {
+			writeError(w, http.StatusBadRequest, "validation_failed", "Verify requires either a token hash or an email and token")
+			return
+		}
+		hash = tokenHash(strings.TrimSpace(body.Email), body.Token)
+	}
+
+	sess, err := s.verify(r.Context(), body.Type, hash)
+	if err != nil {
+		writeInternalError(w, "verification failed", err)
+		return
+	}
+	if sess == nil {
+		// Codes are short, so a few wrong ones burn the user's token
+		if body.TokenHash == "" && s.failures.add(body.Email, time.Now()) {
+			if err := s.invalidateTokens(r.Context(), body.Email); err != nil {
+				writeInternalError(w, "failed to invalidate tokens", err)
+				return
+			}
+			logger.Warn("native auth: too many wrong codes, pending tokens invalidated", "email", body.Email)
+		}
+		writeError(w, http.StatusForbidden, "otp_expired", "Token has expired or is invalid")
+		return
+	}
+	if body.TokenHash == "" {
+		s.failures.reset(body.Email)
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+// invalidateTokens clears the pending confirmation and recovery tokens of
+// the user with email, so neither code nor link works any more.
+func (s *Server) invalidateTokens(ctx context.Context, email string) error {
+	conn, err := s.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx,
+		"UPDATE auth.users SET confirmation_token = '', recovery_token = '', updated_at = now() WHERE lower(email) = lower($1) AND is_sso_user = false AND deleted_at IS NULL",
+		strings.TrimSpace(email))
+	return err
+}
+
+// handleVerifyRedirect serves the links in confirmation and recovery mails,
+// redirecting with the session in the URL fragment as GoTrue does.
+func (s *Server) handleVerifyRedirect(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectTo := s.redirectTarget(q.Get("redirect_to"))
+	verifyType := q.Get("type")
+
+	fragment := url.Values{}
+	var sess *session
+	if _, ok := verifyColumn(verifyType); ok && q.Get("token") != "" {
+		var err error
+		if sess, err = s.verify(r.Context(), verifyType, q.Get("token")); err != nil {
+			logger.Error("native auth: verification failed", "error", err)
+		}
+	}
+	if sess == nil {
+		fragment.Set("error", "access_denied")
+		fragment.Set("error_code", "otp_expired")
+		fragment.Set("error_description", "Email link is invalid or has expired")
+	} else {
+		fragment.Set("access_token", sess.AccessToken)
+		fragment.Set("expires_at", strconv.FormatInt(sess.ExpiresAt, 10))
+		fragment.Set("expires_in", strconv.Itoa(sess.ExpiresIn))
+		fragment.Set("refresh_token", sess.RefreshToken)
+		fragment.Set("token_type", sess.TokenType)
+		fragment.Set("type", verifyType)
+	}
+	http.Redirect(w, r, redirectTo+"#"+fragment.Encode(), http.StatusSeeOther)
+}
+
+// redirectTarget only allows redirects back to the site, so verification
+// links can't be turned into open redirects.
+func (s *Server) redirectTarget(requested string) string {
+	site, err := url.Parse(s.config.SiteURL)
+	if err != nil || requested == "" {
+		return s.config.SiteURL
+	}
+	target, err := url.Parse(requested)
+	if err != nil || target.Scheme != site.Scheme || target.Host != site.Host {
+		return s.config.SiteURL
+	}
+	return requested
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := s.bearer(w, r)
+	if !ok {
+		return
+	}
+	conn, err := s.config.Database.Acquire(r.Context())
+	if err != nil {
+		writeInternalError(w, "failed to acquire connection", err)
+		return
+	}
+	defer conn.Release()
+
+	u, err := userByID(r.Context(), conn, userID)
+	if errors.Is(err, errNoUser) {
+		writeError(w, http.StatusNotFound, "user_not_found", "User from sub claim in JWT does not exist")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "failed to load user", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := s.bearer(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Email    *string                `json:"email"`
+		Password *string                `json:"password"`
+		Data     map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+
+	var hash []byte
+	if body.Password != nil {
+		if !s.checkPassword(w, *body.Password) {
+			return
+		}
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword([]byte(*body.Password), bcrypt.DefaultCost); err != nil {
+			writeInternalError(w, "failed to hash password", err)
+			return
+		}
+	}
+	var email string
+	if body.Email != nil {
+		email = strings.ToLower(strings.TrimSpace(*body.Email))
+		if !validEmail(email) {
+			writeError(w, http.StatusBadRequest, "validation_failed", "Unable to validate email address: invalid format")
+			return
+		}
+		// Changing an address safely means confirming the new one first,
+		// which needs the email change flow only GoTrue implements
+		if !s.autoconfirm() {
+			writeError(w, http.StatusUnprocessableEntity, "validation_failed", "Email changes require confirmation, which the native auth server does not support")
+			return
+		}
+	}
+
+	var u *user
+	err := s.withTx(r.Context(), func(tx pgx.Tx) error {
+		if hash != nil {
+			if _, err := tx.Exec(r.Context(),
+				"UPDATE auth.users SET encrypted_password = $1, updated_at = now() WHERE id::text = $2", string(hash), userID); err != nil {
+				return err
+			}
+		}
+		if body.Data != nil {
+			data, _ := json.Marshal(body.Data)
+			if _, err := tx.Exec(r.Context(),
+				"UPDATE auth.users SET raw_user_meta_data = COALESCE(raw_user_meta_data, '{}'::jsonb) || $1::jsonb, updated_at = now() WHERE id::text = $2", data, userID); err != nil {
+				return err
+			}
+		}
+		if email != "" {
+			if other, err := userByEmail(r.Context(), tx, email); err == nil && other.ID != userID {
+				return errEmailTaken
+			}
+			if _, err := tx.Exec(r.Context(),
+				"UPDATE auth.users SET email = $1, email_confirmed_at = now(), updated_at = now() WHERE id::text = $2", email, userID); err != nil {
+				return err
+			}
+		}
+		var err error
+		u, err = userByID(r.Context(), tx, userID)
+		return err
+	})
+	if errors.Is(err, errEmailTaken) {
+		writeError(w, http.StatusUnprocessableEntity, "email_exists", "A user with this email address has already been registered")
+		return
+	}
+	if errors.Is(err, errNoUser) {
+		writeError(w, http.StatusNotFound, "user_not_found", "User from sub claim in JWT does not exist")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "failed to update user", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
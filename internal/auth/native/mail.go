@@ -0,0 +1,79 @@
+package native
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// mailKind selects the subject and the verify type of a mail.
+type mailKind string
+
+const (
+	mailConfirmation mailKind = "signup"
+	mailRecovery     mailKind = "recovery"
+)
+
+var mailSubjects = map[mailKind]string{
+	mailConfirmation: "Confirm Your Signup",
+	mailRecovery:     "Reset Your Password",
+}
+
+// sendMail mails a verification link and its one-time code.
+func (s *Server) sendMail(kind mailKind, to, otp, redirectTo string) error {
+	email := s.config.Email
+	if email == nil || email.SMTPHost == "" {
+		return fmt.Errorf("no SMTP host configured")
+	}
+
+	link := s.verifyURL(kind, tokenHash(to, otp), redirectTo)
+	from := email.AdminEmail
+	if from == "" {
+		from = "noreply@localhost"
+	}
+
+	body := fmt.Sprintf("Follow this link:\r\n\r\n%s\r\n\r\nAlternatively, enter the code: %s\r\n", link, otp)
+	msg := "From: " + from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mailSubjects[kind] + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+
+	var smtpAuth smtp.Auth
+	if email.SMTPUser != "" {
+		smtpAuth = smtp.PlainAuth("", email.SMTPUser, email.SMTPPass, email.SMTPHost)
+	}
+	port := email.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(email.SMTPHost, strconv.Itoa(port))
+	return smtp.SendMail(addr, smtpAuth, from, []string{to}, []byte(msg))
+}
+
+// verifyURL is the link mails point at, in the form GoTrue uses.
+func (s *Server) verifyURL(kind mailKind, hash, redirectTo string) string {
+	q := url.Values{}
+	q.Set("token", hash)
+	q.Set("type", string(kind))
+	if redirectTo == "" {
+		redirectTo = s.config.SiteURL
+	}
+	q.Set("redirect_to", redirectTo)
+	return strings.TrimSuffix(s.config.PublicURL, "/") + "/auth/v1/verify?" + q.Encode()
+}
+
+// newOTP returns a six digit one-time code.
+func newOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,159 @@
+// Package native implements the core of GoTrue's API in process.
+//
+// It serves signup, password and refresh token grants, logout, password
+// recovery, email verification, and the current user endpoints directly
+// against the auth schema, so supalite can run without a GoTrue subprocess
+// on platforms GoTrue has no release binary for. Sessions are signed with
+// the same HS256 secret GoTrue would use, so /rest/v1, storage, and
+// realtime accept them unchanged.
+//
+// OAuth, phone, magic link, MFA, and the admin API are not implemented;
+// deployments that need them should run GoTrue.
+package native
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/log"
+)
+
+// logger logs for the auth component.
+var logger = log.Component("auth")
+
+const (
+	// DefaultJWTExpiry matches GoTrue's GOTRUE_JWT_EXP default
+	DefaultJWTExpiry = time.Hour
+
+	// DefaultPasswordMinLength matches GoTrue's default GOTRUE_PASSWORD_MIN_LENGTH
+	DefaultPasswordMinLength = 6
+)
+
+// PostgresAcquirer lends the server pooled connections to the auth schema,
+// which it creates at startup and reads and writes on each request.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Config holds the configuration for the native auth server.
+type Config struct {
+	Database  PostgresAcquirer
+	JWTSecret string // HS256 secret sessions are signed with
+
+	// SiteURL is where verification links redirect to by default
+	SiteURL string
+
+	// PublicURL is the address email links point at (default: SiteURL)
+	PublicURL string
+
+	// JWTExpiry is the lifetime of access tokens (default: DefaultJWTExpiry)
+	JWTExpiry time.Duration
+
+	// PasswordMinLength is the shortest password accepted
+	// (default: DefaultPasswordMinLength)
+	PasswordMinLength int
+
+	// Email configures confirmation and recovery mails. Without an SMTP
+	// host no mail is sent and new users are confirmed immediately.
+	Email *auth.EmailConfig
+}
+
+// Server serves the auth API from the auth schema.
+type Server struct {
+	config   Config
+	router   chi.Router
+	running  atomic.Bool
+	failures *verifyFailures // Wrong codes per email address
+}
+
+var _ auth.Provider = (*Server)(nil)
+
+// NewServer creates a new native auth server.
+func NewServer(cfg Config) *Server {
+	if cfg.JWTExpiry <= 0 {
+		cfg.JWTExpiry = DefaultJWTExpiry
+	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = cfg.SiteURL
+	}
+	if cfg.PasswordMinLength <= 0 {
+		cfg.PasswordMinLength = DefaultPasswordMinLength
+	}
+
+	s := &Server{config: cfg, failures: newVerifyFailures()}
+	s.router = s.routes()
+	return s
+}
+
+// Start creates the auth schema tables the server needs.
+func (s *Server) Start(ctx context.Context) error {
+	conn, err := s.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("failed to create auth schema: %w", err)
+	}
+	s.running.Store(true)
+	return nil
+}
+
+// Stop marks the server stopped. Requests are served in process, so there
+// is nothing to shut down.
+func (s *Server) Stop() error {
+	s.running.Store(false)
+	return nil
+}
+
+// IsRunning reports whether Start has succeeded and Stop not been called.
+func (s *Server) IsRunning() bool {
+	return s.running.Load()
+}
+
+// WaitUntilReady returns at once: the server is ready as soon as Start
+// returns.
+func (s *Server) WaitUntilReady(ctx context.Context, timeout time.Duration) error {
+	if !s.IsRunning() {
+		return fmt.Errorf("native auth is not started")
+	}
+	return nil
+}
+
+// Handler returns the auth API, with paths relative to /auth/v1.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+func (s *Server) routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/health", s.handleHealth)
+	r.Get("/settings", s.handleSettings)
+	r.Post("/signup", s.handleSignup)
+	r.Post("/token", s.handleToken)
+	r.Post("/logout", s.handleLogout)
+	r.Post("/recover", s.handleRecover)
+	r.Get("/verify", s.handleVerifyRedirect)
+	r.Post("/verify", s.handleVerify)
+	r.Get("/user", s.handleGetUser)
+	r.Put("/user", s.handleUpdateUser)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "Not supported by the native auth server")
+	})
+	return r
+}
+
+// autoconfirm reports whether new users skip email confirmation.
+func (s *Server) autoconfirm() bool {
+	email := s.config.Email
+	return email == nil || email.Autoconfirm || email.SMTPHost == ""
+}
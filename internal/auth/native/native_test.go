@@ -0,0 +1,224 @@
+package native
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/auth"
+)
+
+func newTestServer(email *auth.EmailConfig) *Server {
+	return NewServer(Config{
+		JWTSecret: "test-secret-at-least-32-characters-long",
+		SiteURL:   "http://localhost:3000",
+		PublicURL: "http://192.168.1.10:8080",
+		Email:     email,
+	})
+}
+
+// serve runs a request that is rejected before the database is needed.
+func serve(s *Server, method, target, body, token string) (int, apiError) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	var apiErr apiError
+	json.Unmarshal(rec.Body.Bytes(), &apiErr)
+	return rec.Code, apiErr
+}
+
+func TestHandlerValidation(t *testing.T) {
+	s := newTestServer(nil)
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		body          string
+		token         string
+		wantStatus    int
+		wantErrorCode string
+	}{
+		{"signup invalid email", "POST", "/signup", `{"email":"nope","password":"secret123"}`, "", 400, "validation_failed"},
+		{"signup weak password", "POST", "/signup", `{"email":"a@example.com","password":"123"}`, "", 422, "weak_password"},
+		{"signup bad json", "POST", "/signup", `{`, "", 400, "bad_json"},
+		{"unsupported grant", "POST", "/token?grant_type=magic", `{}`, "", 400, "unsupported_grant_type"},
+		{"logout without token", "POST", "/logout", ``, "", 401, "no_authorization"},
+		{"user with bad token", "GET", "/user", ``, "not-a-jwt", 401, "bad_jwt"},
+		{"verify without type", "POST", "/verify", `{"token_hash":"abc"}`, "", 400, "validation_failed"},
+		{"verify without token", "POST", "/verify", `{"type":"signup","email":"a@example.com"}`, "", 400, "validation_failed"},
+		{"recover invalid email", "POST", "/recover", `{"email":""}`, "", 400, "validation_failed"},
+		{"unsupported endpoint", "GET", "/factors", ``, "", 404, "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, apiErr := serve(s, tt.method, tt.target, tt.body, tt.token)
+			if status != tt.wantStatus || apiErr.ErrorCode != tt.wantErrorCode {
+				t.Errorf("status = %d, error_code = %q, want %d, %q", status, apiErr.ErrorCode, tt.wantStatus, tt.wantErrorCode)
+			}
+		})
+	}
+}
+
+func TestSignSessionRoundTrip(t *testing.T) {
+	s := newTestServer(nil)
+	u := &user{
+		ID:           "6f7d1c2e-0000-4000-8000-000000000001",
+		Aud:          "authenticated",
+		Role:         "authenticated",
+		Email:        "a@example.com",
+		AppMetadata:  map[string]interface{}{"provider": "email"},
+		UserMetadata: map[string]interface{}{},
+	}
+
+	sess, err := s.signSession(u, "session-1", "refresh", "password", time.Now())
+	if err != nil {
+		t.Fatalf("signSession() error = %v", err)
+	}
+	if sess.TokenType != "bearer" || sess.ExpiresIn != int(DefaultJWTExpiry.Seconds()) || sess.RefreshToken != "refresh" {
+		t.Errorf("signSession() = %+v", sess)
+	}
+
+	userID, sessionID, err := s.parseAccessToken(sess.AccessToken)
+	if err != nil || userID != u.ID || sessionID != "session-1" {
+		t.Errorf("parseAccessToken() = %q, %q, %v, want %q, session-1", userID, sessionID, err, u.ID)
+	}
+
+	other := NewServer(Config{JWTSecret: "a-different-secret-of-32-characters!"})
+	if _, _, err := other.parseAccessToken(sess.AccessToken); err == nil {
+		t.Error("parseAccessToken() should reject tokens signed with another secret")
+	}
+}
+
+func TestTokenHash(t *testing.T) {
+	// GoTrue stores hex(sha224(email + otp))
+	if got, want := tokenHash("a@example.com", "123456"), "a1828f725cbf9d6eecc3a3afeb2d88a886ccb3280cc2dc88aafb5c82"; got != want {
+		t.Errorf("tokenHash() = %q, want %q", got, want)
+	}
+	if tokenHash("A@Example.com", "123456") != tokenHash("a@example.com", "123456") {
+		t.Error("tokenHash() should ignore the case of the email")
+	}
+	if tokenHash("a@example.com", "123456") == tokenHash("a@example.com", "654321") {
+		t.Error("tokenHash() should depend on the code")
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	s := newTestServer(nil)
+
+	link, err := url.Parse(s.verifyURL(mailRecovery, "hash", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := link.Query()
+	if link.Host != "192.168.1.10:8080" || link.Path != "/auth/v1/verify" {
+		t.Errorf("verifyURL() = %s, want a link to the public URL", link)
+	}
+	if q.Get("token") != "hash" || q.Get("type") != "recovery" || q.Get("redirect_to") != "http://localhost:3000" {
+		t.Errorf("verifyURL() query = %v", q)
+	}
+}
+
+func TestRedirectTarget(t *testing.T) {
+	s := newTestServer(nil)
+
+	tests := map[string]string{
+		"":                                "http://localhost:3000",
+		"http://localhost:3000/welcome":   "http://localhost:3000/welcome",
+		"https://evil.example.com/phish":  "http://localhost:3000",
+		"http://localhost:3001/other-app": "http://localhost:3000",
+	}
+	for requested, want := range tests {
+		if got := s.redirectTarget(requested); got != want {
+			t.Errorf("redirectTarget(%q) = %q, want %q", requested, got, want)
+		}
+	}
+}
+
+func TestVerifyRedirectInvalid(t *testing.T) {
+	s := newTestServer(nil)
+
+	req := httptest.NewRequest("GET", "/verify?type=unknown&token=abc&redirect_to=http://localhost:3000/done", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want 303", rec.Code)
+	}
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, "http://localhost:3000/done#") || !strings.Contains(location, "error_code=otp_expired") {
+		t.Errorf("Location = %q, want an error fragment on the redirect target", location)
+	}
+}
+
+func TestAutoconfirm(t *testing.T) {
+	tests := []struct {
+		email *auth.EmailConfig
+		want  bool
+	}{
+		{nil, true},
+		{&auth.EmailConfig{}, true},
+		{&auth.EmailConfig{SMTPHost: "localhost"}, false},
+		{&auth.EmailConfig{SMTPHost: "localhost", Autoconfirm: true}, true},
+	}
+	for _, tt := range tests {
+		if got := newTestServer(tt.email).autoconfirm(); got != tt.want {
+			t.Errorf("autoconfirm(%+v) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordMinLength(t *testing.T) {
+	s := NewServer(Config{JWTSecret: "test-secret-at-least-32-characters-long", PasswordMinLength: 10})
+
+	status, apiErr := serve(s, "POST", "/signup", `{"email":"a@example.com","password":"secret123"}`, "")
+	if status != 422 || apiErr.ErrorCode != "weak_password" || !strings.Contains(apiErr.Msg, "10 characters") {
+		t.Errorf("signup with a 9 character password = %d, %+v, want weak_password", status, apiErr)
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	f := newVerifyFailures()
+	now := time.Now()
+
+	for i := 1; i < maxVerifyFailures; i++ {
+		if f.add("a@example.com", now) {
+			t.Fatalf("wrong code %d invalidated the token, want %d allowed", i, maxVerifyFailures-1)
+		}
+	}
+	if f.add("other@example.com", now) {
+		t.Error("another user's wrong code invalidated the token")
+	}
+	if !f.add(" A@Example.com", now) {
+		t.Fatalf("wrong code %d did not invalidate the token", maxVerifyFailures)
+	}
+	// The count starts over for the next token
+	if f.add("a@example.com", now) {
+		t.Error("first wrong code after invalidation invalidated the token")
+	}
+
+	// A redeemed or reissued token forgets the failures
+	for i := 1; i < maxVerifyFailures; i++ {
+		f.add("b@example.com", now)
+	}
+	f.reset("b@example.com")
+	if f.add("b@example.com", now) {
+		t.Error("wrong code after reset invalidated the token")
+	}
+
+	// Counts expire with the tokens they were for
+	for i := 1; i < maxVerifyFailures; i++ {
+		f.add("c@example.com", now)
+	}
+	if f.add("c@example.com", now.Add(otpExpiry+time.Minute)) {
+		t.Error("wrong code after the token expired invalidated the next one")
+	}
+}
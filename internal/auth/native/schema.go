@@ -0,0 +1,112 @@
+package native
+
+// schemaSQL creates the parts of GoTrue's auth schema the native server
+// uses. Tables and columns carry GoTrue's names and types, so users created
+// here can sign in through GoTrue later, and the existing tooling that reads
+// auth.users (impersonation, export/import, the dashboard) works in both
+// modes. Everything is guarded with IF NOT EXISTS so a schema GoTrue already
+// migrated is left alone.
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS auth;
+
+CREATE TABLE IF NOT EXISTS auth.users (
+	instance_id uuid,
+	id uuid PRIMARY KEY,
+	aud varchar(255),
+	role varchar(255),
+	email varchar(255),
+	encrypted_password varchar(255),
+	email_confirmed_at timestamptz,
+	invited_at timestamptz,
+	confirmation_token varchar(255),
+	confirmation_sent_at timestamptz,
+	recovery_token varchar(255),
+	recovery_sent_at timestamptz,
+	email_change_token_new varchar(255),
+	email_change varchar(255),
+	email_change_sent_at timestamptz,
+	last_sign_in_at timestamptz,
+	raw_app_meta_data jsonb,
+	raw_user_meta_data jsonb,
+	is_super_admin boolean,
+	created_at timestamptz,
+	updated_at timestamptz,
+	phone text UNIQUE DEFAULT NULL,
+	phone_confirmed_at timestamptz,
+	confirmed_at timestamptz GENERATED ALWAYS AS (LEAST(email_confirmed_at, phone_confirmed_at)) STORED,
+	banned_until timestamptz,
+	deleted_at timestamptz,
+	is_sso_user boolean NOT NULL DEFAULT false,
+	is_anonymous boolean NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_partial_key ON auth.users (email) WHERE is_sso_user = false;
+
+CREATE TABLE IF NOT EXISTS auth.identities (
+	provider_id text NOT NULL,
+	user_id uuid NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+	identity_data jsonb NOT NULL,
+	provider text NOT NULL,
+	last_sign_in_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz,
+	email text GENERATED ALWAYS AS (lower(identity_data->>'email')) STORED,
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	UNIQUE (provider_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS auth.sessions (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES auth.users (id) ON DELETE CASCADE,
+	created_at timestamptz,
+	updated_at timestamptz,
+	aal text,
+	not_after timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS auth.refresh_tokens (
+	instance_id uuid,
+	id bigserial PRIMARY KEY,
+	token varchar(255) UNIQUE,
+	user_id varchar(255),
+	revoked boolean,
+	created_at timestamptz,
+	updated_at timestamptz,
+	parent varchar(255),
+	session_id uuid REFERENCES auth.sessions (id) ON DELETE CASCADE
+);
+
+CREATE OR REPLACE FUNCTION auth.uid() RETURNS uuid
+LANGUAGE sql STABLE AS $$
+	SELECT coalesce(
+		nullif(current_setting('request.jwt.claim.sub', true), ''),
+		(nullif(current_setting('request.jwt.claims', true), '')::jsonb ->> 'sub')
+	)::uuid
+$$;
+
+CREATE OR REPLACE FUNCTION auth.role() RETURNS text
+LANGUAGE sql STABLE AS $$
+	SELECT coalesce(
+		nullif(current_setting('request.jwt.claim.role', true), ''),
+		(nullif(current_setting('request.jwt.claims', true), '')::jsonb ->> 'role')
+	)::text
+$$;
+
+CREATE OR REPLACE FUNCTION auth.email() RETURNS text
+LANGUAGE sql STABLE AS $$
+	SELECT coalesce(
+		nullif(current_setting('request.jwt.claim.email', true), ''),
+		(nullif(current_setting('request.jwt.claims', true), '')::jsonb ->> 'email')
+	)::text
+$$;
+
+CREATE OR REPLACE FUNCTION auth.jwt() RETURNS jsonb
+LANGUAGE sql STABLE AS $$
+	SELECT coalesce(
+		nullif(current_setting('request.jwt.claim', true), ''),
+		nullif(current_setting('request.jwt.claims', true), '')
+	)::jsonb
+$$;
+
+GRANT USAGE ON SCHEMA auth TO anon, authenticated, service_role;
+`
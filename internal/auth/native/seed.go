@@ -0,0 +1,50 @@
+package native
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SeedUsers creates the given users directly in auth.users. Users that
+// already exist are skipped, so seeding is safe on every start.
+func (s *Server) SeedUsers(ctx context.Context, users []auth.SeedUser) error {
+	for _, seed := range users {
+		if seed.Email == "" {
+			return fmt.Errorf("native auth seed users require an email")
+		}
+
+		var hash []byte
+		if seed.Password != "" {
+			var err error
+			if hash, err = bcrypt.GenerateFromPassword([]byte(seed.Password), bcrypt.DefaultCost); err != nil {
+				return fmt.Errorf("failed to hash password for seed user %s: %w", seed.Email, err)
+			}
+		}
+
+		created := false
+		err := s.withTx(ctx, func(tx pgx.Tx) error {
+			if _, err := userByEmail(ctx, tx, seed.Email); err == nil {
+				return nil
+			} else if !errors.Is(err, errNoUser) {
+				return err
+			}
+			_, err := createUser(ctx, tx, seed.Email, string(hash), seed.Confirmed, seed.AppMetadata, seed.UserMetadata)
+			created = err == nil
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create seed user %s: %w", seed.Email, err)
+		}
+		if created {
+			logger.Info("seeded auth user", "user", seed.Email)
+		} else {
+			logger.Debug("seed user already exists", "user", seed.Email)
+		}
+	}
+	return nil
+}
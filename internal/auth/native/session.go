@@ -0,0 +1,176 @@
+package native
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// session is the token response of /token, /verify, and autoconfirmed /signup.
+type session struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	ExpiresAt    int64  `json:"expires_at"`
+	RefreshToken string `json:"refresh_token"`
+	User         *user  `json:"user"`
+}
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// newSession starts a session for u and signs its first access token.
+// method is recorded in the token's amr claim ("password", "otp", ...).
+func (s *Server) newSession(ctx context.Context, tx pgx.Tx, u *user, method string) (*session, error) {
+	sessionID := uuid.NewString()
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth.sessions (id, user_id, created_at, updated_at, aal)
+		VALUES ($1, $2, now(), now(), 'aal1')
+	`, sessionID, u.ID); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	refreshToken, err := s.issueRefreshToken(ctx, tx, u.ID, sessionID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		"UPDATE auth.users SET last_sign_in_at = now(), updated_at = now() WHERE id::text = $1", u.ID); err != nil {
+		return nil, fmt.Errorf("failed to record sign in: %w", err)
+	}
+	now := time.Now()
+	u.LastSignInAt = &now
+
+	return s.signSession(u, sessionID, refreshToken, method, now)
+}
+
+// refreshSession exchanges a refresh token for a new one and a fresh access
+// token. Refresh tokens are single use, as in GoTrue.
+func (s *Server) refreshSession(ctx context.Context, tx pgx.Tx, token string) (*session, error) {
+	var userID, sessionID string
+	var revoked bool
+	err := tx.QueryRow(ctx, `
+		SELECT user_id, COALESCE(session_id::text, ''), COALESCE(revoked, false)
+		FROM auth.refresh_tokens WHERE token = $1
+		FOR UPDATE
+	`, token).Scan(&userID, &sessionID, &revoked)
+	if errors.Is(err, pgx.ErrNoRows) || revoked || sessionID == "" {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := userByID(ctx, tx, userID)
+	if errors.Is(err, errNoUser) {
+		return nil, errInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.banned() {
+		return nil, errInvalidRefreshToken
+	}
+
+	if _, err := tx.Exec(ctx,
+		"UPDATE auth.refresh_tokens SET revoked = true, updated_at = now() WHERE token = $1", token); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		"UPDATE auth.sessions SET updated_at = now() WHERE id::text = $1", sessionID); err != nil {
+		return nil, err
+	}
+	next, err := s.issueRefreshToken(ctx, tx, userID, sessionID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signSession(u, sessionID, next, "token_refresh", time.Now())
+}
+
+func (s *Server) issueRefreshToken(ctx context.Context, tx pgx.Tx, userID, sessionID, parent string) (string, error) {
+	token, err := randomToken(12)
+	if err != nil {
+		return "", err
+	}
+	var parentArg interface{}
+	if parent != "" {
+		parentArg = parent
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth.refresh_tokens (token, user_id, revoked, created_at, updated_at, parent, session_id)
+		VALUES ($1, $2, false, now(), now(), $3, $4)
+	`, token, userID, parentArg, sessionID); err != nil {
+		return "", fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// signSession signs an access token carrying the claims GoTrue puts in
+// its own, so auth.uid() and auth.jwt() behave the same in both modes.
+func (s *Server) signSession(u *user, sessionID, refreshToken, method string, now time.Time) (*session, error) {
+	expiresAt := now.Add(s.config.JWTExpiry)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":           s.config.PublicURL + "/auth/v1",
+		"sub":           u.ID,
+		"aud":           u.Aud,
+		"exp":           expiresAt.Unix(),
+		"iat":           now.Unix(),
+		"email":         u.Email,
+		"phone":         u.Phone,
+		"app_metadata":  u.AppMetadata,
+		"user_metadata": u.UserMetadata,
+		"role":          u.Role,
+		"aal":           "aal1",
+		"amr":           []map[string]interface{}{{"method": method, "timestamp": now.Unix()}},
+		"session_id":    sessionID,
+		"is_anonymous":  u.IsAnonymous,
+	})
+	signed, err := token.SignedString([]byte(s.config.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return &session{
+		AccessToken:  signed,
+		TokenType:    "bearer",
+		ExpiresIn:    int(s.config.JWTExpiry.Seconds()),
+		ExpiresAt:    expiresAt.Unix(),
+		RefreshToken: refreshToken,
+		User:         u,
+	}, nil
+}
+
+// parseAccessToken verifies a bearer token and returns its sub and
+// session_id claims.
+func (s *Server) parseAccessToken(raw string) (userID, sessionID string, err error) {
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", "", err
+	}
+	userID, _ = claims["sub"].(string)
+	sessionID, _ = claims["session_id"].(string)
+	if userID == "" {
+		return "", "", errors.New("token has no subject")
+	}
+	return userID, sessionID, nil
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,171 @@
+package native
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// user is a row of auth.users, encoded the way GoTrue returns users.
+type user struct {
+	ID               string                 `json:"id"`
+	Aud              string                 `json:"aud"`
+	Role             string                 `json:"role"`
+	Email            string                 `json:"email"`
+	EmailConfirmedAt *time.Time             `json:"email_confirmed_at,omitempty"`
+	Phone            string                 `json:"phone"`
+	ConfirmedAt      *time.Time             `json:"confirmed_at,omitempty"`
+	ConfirmationSent *time.Time             `json:"confirmation_sent_at,omitempty"`
+	RecoverySentAt   *time.Time             `json:"recovery_sent_at,omitempty"`
+	LastSignInAt     *time.Time             `json:"last_sign_in_at,omitempty"`
+	AppMetadata      map[string]interface{} `json:"app_metadata"`
+	UserMetadata     map[string]interface{} `json:"user_metadata"`
+	Identities       []interface{}          `json:"identities"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	IsAnonymous      bool                   `json:"is_anonymous"`
+
+	encryptedPassword string
+	bannedUntil       *time.Time
+}
+
+var errNoUser = errors.New("user not found")
+
+// userColumns is the select list scanUser reads.
+const userColumns = `
+	id::text, COALESCE(aud, ''), COALESCE(role, ''), COALESCE(email, ''), email_confirmed_at,
+	COALESCE(phone, ''), confirmed_at, confirmation_sent_at, recovery_sent_at, last_sign_in_at,
+	COALESCE(raw_app_meta_data, '{}'::jsonb), COALESCE(raw_user_meta_data, '{}'::jsonb),
+	COALESCE(created_at, now()), COALESCE(updated_at, now()), is_anonymous,
+	COALESCE(encrypted_password, ''), banned_until`
+
+func scanUser(row pgx.Row) (*user, error) {
+	var u user
+	var appMeta, userMeta []byte
+	err := row.Scan(&u.ID, &u.Aud, &u.Role, &u.Email, &u.EmailConfirmedAt,
+		&u.Phone, &u.ConfirmedAt, &u.ConfirmationSent, &u.RecoverySentAt, &u.LastSignInAt,
+		&appMeta, &userMeta, &u.CreatedAt, &u.UpdatedAt, &u.IsAnonymous,
+		&u.encryptedPassword, &u.bannedUntil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errNoUser
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(appMeta, &u.AppMetadata); err != nil || u.AppMetadata == nil {
+		u.AppMetadata = map[string]interface{}{}
+	}
+	if err := json.Unmarshal(userMeta, &u.UserMetadata); err != nil || u.UserMetadata == nil {
+		u.UserMetadata = map[string]interface{}{}
+	}
+	u.Identities = []interface{}{}
+	return &u, nil
+}
+
+// querier is satisfied by connections and transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func userByID(ctx context.Context, q querier, id string) (*user, error) {
+	return scanUser(q.QueryRow(ctx,
+		"SELECT "+userColumns+" FROM auth.users WHERE id::text = $1 AND deleted_at IS NULL", id))
+}
+
+func userByEmail(ctx context.Context, q querier, email string) (*user, error) {
+	return scanUser(q.QueryRow(ctx,
+		"SELECT "+userColumns+" FROM auth.users WHERE lower(email) = lower($1) AND is_sso_user = false AND deleted_at IS NULL", email))
+}
+
+// userByToken finds the user a confirmation or recovery token hash was issued to.
+func userByToken(ctx context.Context, q querier, column, tokenHash string) (*user, error) {
+	if column != "confirmation_token" && column != "recovery_token" {
+		return nil, fmt.Errorf("unknown token column %q", column)
+	}
+	return scanUser(q.QueryRow(ctx,
+		"SELECT "+userColumns+" FROM auth.users WHERE "+column+" = $1 AND deleted_at IS NULL", tokenHash))
+}
+
+// banned reports whether the user may not sign in right now.
+func (u *user) banned() bool {
+	return u.bannedUntil != nil && u.bannedUntil.After(time.Now())
+}
+
+// confirmed reports whether the user's email has been confirmed.
+func (u *user) confirmed() bool {
+	return u.EmailConfirmedAt != nil
+}
+
+// tokenHash derives the value stored in confirmation_token and
+// recovery_token from the one-time code mailed to the user, the same way
+// GoTrue does, so links GoTrue sent keep working with the native server
+// and the other way around.
+func tokenHash(email, otp string) string {
+	return fmt.Sprintf("%x", sha256.Sum224([]byte(strings.ToLower(email)+otp)))
+}
+
+// identityData is the identity_data of a user's email identity.
+func identityData(u *user) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":            u.ID,
+		"email":          u.Email,
+		"email_verified": u.confirmed(),
+		"phone_verified": false,
+	}
+}
+
+// createUser inserts an email user and its identity, as GoTrue's signup
+// does. appMeta is merged over the provider entries GoTrue records.
+func createUser(ctx context.Context, tx pgx.Tx, email, encryptedPassword string, confirmed bool, appMeta, userMeta map[string]interface{}) (*user, error) {
+	app := map[string]interface{}{"provider": "email", "providers": []string{"email"}}
+	for k, v := range appMeta {
+		app[k] = v
+	}
+	if userMeta == nil {
+		userMeta = map[string]interface{}{}
+	}
+	appJSON, err := json.Marshal(app)
+	if err != nil {
+		return nil, err
+	}
+	userJSON, err := json.Marshal(userMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth.users (
+			instance_id, id, aud, role, email, encrypted_password, email_confirmed_at,
+			raw_app_meta_data, raw_user_meta_data, created_at, updated_at
+		) VALUES (
+			'00000000-0000-0000-0000-000000000000', $1, 'authenticated', 'authenticated', $2, $3,
+			CASE WHEN $4 THEN now() END, $5, $6, now(), now()
+		)
+	`, id, strings.ToLower(email), encryptedPassword, confirmed, appJSON, userJSON); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	u, err := userByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := json.Marshal(identityData(u))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO auth.identities (provider_id, user_id, identity_data, provider, last_sign_in_at, created_at, updated_at)
+		VALUES ($1, $1::uuid, $2, 'email', now(), now(), now())
+	`, id, identity); err != nil {
+		return nil, fmt.Errorf("failed to create identity: %w", err)
+	}
+	return u, nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Provider serves the /auth/v1 API. Server, which supervises a GoTrue
+// subprocess and proxies to it, is the default; package native serves the
+// core endpoints in process. Clients see the same API either way, and
+// sessions from both are signed with the project's JWT secret.
+type Provider interface {
+	// Start creates what the provider needs in the auth schema and begins
+	// serving.
+	Start(ctx context.Context) error
+
+	// Stop stops serving. It is safe to call on a provider that never
+	// started.
+	Stop() error
+
+	// IsRunning reports whether requests can be served now.
+	IsRunning() bool
+
+	// WaitUntilReady blocks until requests can be served or the timeout
+	// expires.
+	WaitUntilReady(ctx context.Context, timeout time.Duration) error
+
+	// Handler serves the API, with paths relative to /auth/v1.
+	Handler() http.Handler
+
+	// SeedUsers creates the given users, skipping those that exist.
+	SeedUsers(ctx context.Context, users []SeedUser) error
+}
+
+var _ Provider = (*Server)(nil)
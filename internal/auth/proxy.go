@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/markb/supalite/internal/tracing"
+)
+
+// proxyTransport is shared by every request to GoTrue, so connections are
+// kept alive and reused. supabase-js refreshes sessions on a timer, and a
+// burst of refreshes would otherwise open (and leave in TIME_WAIT) one
+// connection each.
+var proxyTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 100, // There is only one host
+	IdleConnTimeout:     90 * time.Second,
+
+	// Bounds the wait for GoTrue to start answering. Bodies are streamed
+	// without a limit, so event streams stay open.
+	ResponseHeaderTimeout: 30 * time.Second,
+}
+
+// goTrueClient returns a client for supalite's own requests to GoTrue,
+// such as readiness checks and seeding, over proxyTransport's pooled
+// connections.
+func goTrueClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: proxyTransport, Timeout: timeout}
+}
+
+// corsHeaders are dropped from GoTrue's responses, since the main server
+// handles CORS. This prevents duplicate CORS headers (e.g.,
+// "Access-Control-Allow-Origin: *, *").
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// Handler returns an HTTP handler that proxies requests to the GoTrue server.
+// Requests made while GoTrue is down get 503.
+func (s *Server) Handler() http.Handler {
+	s.proxyOnce.Do(func() {
+		s.proxy = newReverseProxy(fmt.Sprintf("http://localhost:%d", s.config.Port))
+	})
+	return s.proxy
+}
+
+// AdminHandler returns a handler that proxies requests to GoTrue like
+// Handler, authorized as service_role, so they may call its admin API. It
+// is for supalite's own features, such as the dashboard's user management,
+// and must never be reachable by clients.
+func (s *Server) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// GoTrue verifies admin requests against its own HS256 secret,
+		// which differs from the project keys in ES256 mode
+		token, err := s.adminToken()
+		if err != nil {
+			proxyError(w, r, fmt.Errorf("failed to create admin token: %w", err))
+			return
+		}
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+		s.Handler().ServeHTTP(w, r)
+	})
+}
+
+// reverseProxy forwards requests to GoTrue. Request and response bodies are
+// streamed, event streams are flushed as they arrive, and protocol upgrades
+// such as WebSocket handshakes are relayed both ways.
+type reverseProxy struct {
+	proxy *httputil.ReverseProxy
+}
+
+// newReverseProxy creates a proxy to target, a base URL such as
+// "http://localhost:9999".
+func newReverseProxy(target string) *reverseProxy {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		panic(fmt.Sprintf("invalid GoTrue URL %q: %v", target, err))
+	}
+
+	return &reverseProxy{proxy: &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(targetURL)
+			pr.Out.Header.Set("X-Forwarded-For", forwardedFor(pr.In))
+		},
+		Transport: tracing.Transport(proxyTransport), // Spans are no-ops unless tracing is set up
+		ModifyResponse: func(resp *http.Response) error {
+			for _, name := range corsHeaders {
+				resp.Header.Del(name)
+			}
+			return nil
+		},
+		ErrorHandler: proxyError,
+	}}
+}
+
+func (p *reverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.proxy.ServeHTTP(w, r)
+}
+
+// forwardedFor returns the client address GoTrue sees, which its rate
+// limits are keyed by: X-Real-IP when a proxy in front of supalite set it,
+// else the address the request came from.
+func forwardedFor(r *http.Request) string {
+	if clientIP := r.Header.Get("X-Real-IP"); clientIP != "" {
+		return clientIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// proxyError answers a request GoTrue could not serve with a GoTrue-style
+// error: 503 while GoTrue is down (for instance restarting after a crash),
+// 504 when it doesn't answer in time, and 502 otherwise.
+func proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	// The client went away; there is no one to answer
+	if r.Context().Err() != nil {
+		return
+	}
+
+	status, code, msg := http.StatusBadGateway, "bad_gateway", "Failed to proxy request to the auth server"
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		status, code, msg = http.StatusServiceUnavailable, "auth_unavailable", "The auth server is not running, try again shortly"
+		w.Header().Set("Retry-After", "2")
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		status, code, msg = http.StatusGatewayTimeout, "request_timeout", "The auth server did not respond in time"
+	}
+	logger.Warn("auth proxy error", "path", r.URL.Path, "status", status, "error", err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":       status,
+		"error_code": code,
+		"msg":        msg,
+	})
+}
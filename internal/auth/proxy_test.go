@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestReverseProxy_Upgrade(t *testing.T) {
+	// Backend that switches protocols and echoes lines back
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		line, _ := buf.ReadString('\n')
+		fmt.Fprint(buf, "echo: "+line)
+		buf.Flush()
+	}))
+	defer backend.Close()
+
+	proxy := httptest.NewServer(newReverseProxy(backend.URL))
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET /socket HTTP/1.1\r\nHost: localhost\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+
+	fmt.Fprint(conn, "hello\n")
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read after upgrade failed: %v", err)
+	}
+	if line != "echo: hello\n" {
+		t.Errorf("got %q, want %q", line, "echo: hello\n")
+	}
+}
+
+func TestReverseProxy_EventStream(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	proxy := httptest.NewServer(newReverseProxy(backend.URL))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The first event must arrive while the backend is still streaming
+	got := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		got <- line
+	}()
+	select {
+	case line := <-got:
+		if line != "data: first\n" {
+			t.Errorf("got %q, want %q", line, "data: first\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not flushed to the client")
+	}
+}
+
+func TestReverseProxy_Headers(t *testing.T) {
+	var forwardedFor, host string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedFor = r.Header.Get("X-Forwarded-For")
+		host = r.Host
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Location", "/callback")
+		w.WriteHeader(http.StatusSeeOther)
+	}))
+	defer backend.Close()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/authorize?provider=github", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	newReverseProxy(backend.URL).ServeHTTP(rec, req)
+
+	// Redirects are relayed, not followed
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/callback" {
+		t.Errorf("got %d to %q, want 303 to /callback", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("CORS headers from GoTrue should be dropped")
+	}
+	if forwardedFor != "192.0.2.7" {
+		t.Errorf("X-Forwarded-For = %q, want 192.0.2.7", forwardedFor)
+	}
+	if host != strings.TrimPrefix(backend.URL, "http://") {
+		t.Errorf("Host = %q, want the backend's", host)
+	}
+}
+
+func TestReverseProxy_StreamsRequestBody(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%d", len(body))
+	}))
+	defer backend.Close()
+
+	rec := httptest.NewRecorder()
+	body := strings.Repeat("x", 1<<20)
+	newReverseProxy(backend.URL).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(body)))
+	if rec.Body.String() != fmt.Sprint(len(body)) {
+		t.Errorf("backend read %s bytes, want %d", rec.Body.String(), len(body))
+	}
+}
+
+func TestReverseProxy_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	backend.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	proxy := newReverseProxy(backend.URL)
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/token?grant_type=refresh_token", strings.NewReader(`{}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("opened %d connections for 20 sequential requests, want 1", n)
+	}
+}
+
+func TestReverseProxy_BackendDown(t *testing.T) {
+	// Find a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	rec := httptest.NewRecorder()
+	newReverseProxy("http://"+addr).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/user", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After")
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error_code"] != "auth_unavailable" {
+		t.Errorf("body = %s, want a GoTrue-style auth_unavailable error", rec.Body.String())
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	var authorization string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"users": []}`))
+	}))
+	defer backend.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+
+	s := &Server{config: Config{JWTSecret: "gotrue-secret-at-least-32-bytes-long"}}
+	fmt.Sscan(port, &s.config.Port)
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	r.Header.Set("Authorization", "Bearer anon-key")
+	rec := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(authorization, "Bearer "), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWTSecret), nil
+	})
+	if err != nil || claims["role"] != "service_role" {
+		t.Errorf("GoTrue got Authorization %q (%v), want a service_role token signed with its secret", authorization, err)
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SeedUser describes an auth user to create at startup via GoTrue's admin API
+type SeedUser struct {
+	Email        string
+	Phone        string
+	Password     string
+	Confirmed    bool
+	UserMetadata map[string]interface{}
+	AppMetadata  map[string]interface{}
+}
+
+// WaitUntilReady blocks until GoTrue answers health checks or the timeout expires
+func (s *Server) WaitUntilReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if s.IsRunning() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("GoTrue not ready after %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// SeedUsers creates the given users through GoTrue's admin API.
+// Users that already exist are skipped, so seeding is safe on every start.
+func (s *Server) SeedUsers(ctx context.Context, users []SeedUser) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	// GoTrue verifies admin requests against its own HS256 secret, which
+	// differs from the project keys in ES256 mode
+	token, err := s.adminToken()
+	if err != nil {
+		return fmt.Errorf("failed to create admin token: %w", err)
+	}
+
+	client := goTrueClient(10 * time.Second)
+	url := fmt.Sprintf("http://localhost:%d/admin/users", s.config.Port)
+
+	for _, user := range users {
+		if user.Email == "" && user.Phone == "" {
+			return fmt.Errorf("seed user requires an email or phone")
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"email":         user.Email,
+			"phone":         user.Phone,
+			"password":      user.Password,
+			"email_confirm": user.Confirmed && user.Email != "",
+			"phone_confirm": user.Confirmed && user.Phone != "",
+			"user_metadata": user.UserMetadata,
+			"app_metadata":  user.AppMetadata,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode seed user: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to create seed user %s: %w", seedUserName(user), err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			logger.Info("seeded auth user", "user", seedUserName(user))
+		case isAlreadyExists(resp.StatusCode, respBody):
+			logger.Debug("seed user already exists", "user", seedUserName(user))
+		default:
+			return fmt.Errorf("failed to create seed user %s: HTTP %d: %s", seedUserName(user), resp.StatusCode, strings.TrimSpace(string(respBody)))
+		}
+	}
+
+	return nil
+}
+
+// adminToken mints a short-lived service_role token signed with GoTrue's secret
+func (s *Server) adminToken() (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":  "supabase",
+		"role": "service_role",
+		"iat":  now.Unix(),
+		"exp":  now.Add(5 * time.Minute).Unix(),
+	})
+	return token.SignedString([]byte(s.config.JWTSecret))
+}
+
+// isAlreadyExists reports whether a GoTrue admin response means the user exists
+func isAlreadyExists(status int, body []byte) bool {
+	if status != http.StatusUnprocessableEntity {
+		return false
+	}
+	var apiErr struct {
+		ErrorCode string `json:"error_code"`
+		Msg       string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return false
+	}
+	return apiErr.ErrorCode == "email_exists" || apiErr.ErrorCode == "phone_exists" ||
+		strings.Contains(apiErr.Msg, "already been registered")
+}
+
+func seedUserName(user SeedUser) string {
+	if user.Email != "" {
+		return user.Email
+	}
+	return user.Phone
+}
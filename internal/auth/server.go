@@ -5,19 +5,22 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/markb/supalite/internal/limits"
 	"github.com/markb/supalite/internal/log"
 )
 
+// logger logs for the auth component.
+var logger = log.Component("auth")
+
 // GoTrueVersion is the version of GoTrue to download/use
 // Should match Supabase hosted auth version for 100% compatibility
 const GoTrueVersion = "v2.186.0"
@@ -29,7 +32,11 @@ type Server struct {
 	mu     sync.RWMutex
 	running bool
 	ready   bool
+	stopped bool // Stop was called; the exit is expected
 	cancel  context.CancelFunc
+
+	proxyOnce sync.Once
+	proxy     *reverseProxy // Built by Handler on first use
 }
 
 // NewServer creates a new GoTrue server instance
@@ -51,13 +58,14 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 
 	// Find the GoTrue binary
-	binaryPath, err := findGoTrueBinary()
+	binaryPath, err := s.binaryPath()
 	if err != nil {
-		return fmt.Errorf("failed to find GoTrue binary: %w", err)
+		return err
 	}
 
 	// Create a context for the subprocess
 	ctx, s.cancel = context.WithCancel(ctx)
+	s.stopped = false
 
 	// Prepare environment variables
 	env := s.buildEnv()
@@ -85,6 +93,10 @@ func (s *Server) Start(ctx context.Context) error {
 
 	s.running = true
 
+	if err := limits.Apply("gotrue", s.cmd.Process.Pid, s.config.Limits); err != nil {
+		logger.Warn("could not apply GoTrue resource limits", "error", err)
+	}
+
 	// Start goroutines to monitor output
 	go s.monitorOutput(stdout)
 	go s.monitorOutput(stderr)
@@ -93,16 +105,72 @@ func (s *Server) Start(ctx context.Context) error {
 	go s.waitReady()
 
 	// Monitor the subprocess and restart if it crashes
-	go s.monitorAndRestart()
+	go s.monitorAndRestart(s.cmd)
 
 	return nil
 }
 
+// Migrate runs GoTrue's database migrations synchronously.
+//
+// GoTrue migrates on startup too, but only after it begins accepting
+// connections to its port, so the auth API fails for the first seconds of
+// a fresh database. Migrating beforehand makes that startup step a no-op.
+func (s *Server) Migrate(ctx context.Context) error {
+	binaryPath, err := s.binaryPath()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binaryPath, "migrate")
+	cmd.Env = append(os.Environ(), s.buildEnv()...)
+	// Migrations are read from ./migrations relative to the binary
+	cmd.Dir = filepath.Dir(binaryPath)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			logGoTrueLine(line)
+		}
+		return fmt.Errorf("GoTrue migrations failed: %w", err)
+	}
+	return nil
+}
+
+// binaryPath locates the GoTrue binary, downloading it unless downloads
+// are disabled. A configured BinaryPath is used as is.
+func (s *Server) binaryPath() (string, error) {
+	if s.config.BinaryPath != "" {
+		return configuredGoTrueBinary(s.config.BinaryPath)
+	}
+
+	binDir := s.config.BinDir
+	if binDir == "" {
+		binDir = defaultBinDir()
+	}
+	if s.config.DisableDownload {
+		path, err := findLocalGoTrueBinary(binDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to find GoTrue binary and downloads are disabled: %w (%s)", err, offlineHint)
+		}
+		return path, nil
+	}
+	path, err := findGoTrueBinary(binDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to find GoTrue binary: %w", err)
+	}
+	return path, nil
+}
+
 // Stop gracefully stops the GoTrue server
 func (s *Server) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.stopped = true
+
 	if !s.running {
 		return nil
 	}
@@ -113,10 +181,32 @@ func (s *Server) Stop() error {
 
 	s.running = false
 	s.ready = false
+	proxyTransport.CloseIdleConnections()
 
 	return nil
 }
 
+// PID returns the GoTrue process ID, or 0 if it isn't running.
+func (s *Server) PID() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.running || s.cmd == nil || s.cmd.Process == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+// Kill terminates the GoTrue process with SIGKILL, as a crash would. The
+// supervisor restarts it like after any other unexpected exit.
+func (s *Server) Kill() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.running || s.cmd == nil || s.cmd.Process == nil {
+		return fmt.Errorf("GoTrue is not running")
+	}
+	return s.cmd.Process.Kill()
+}
+
 // IsRunning returns true if the server is currently running
 func (s *Server) IsRunning() bool {
 	s.mu.RLock()
@@ -141,95 +231,6 @@ func (s *Server) IsRunning() bool {
 	return s.ready
 }
 
-// Handler returns an HTTP handler that proxies requests to the GoTrue server
-// The handler checks ready state on each request
-func (s *Server) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Create a reverse proxy handler for this request
-		// The proxy will handle connection errors if GoTrue is not ready
-		targetURL := fmt.Sprintf("http://localhost:%d", s.config.Port)
-		proxy := &reverseProxy{
-			target: targetURL,
-		}
-		proxy.ServeHTTP(w, r)
-	})
-}
-
-// reverseProxy is a simple HTTP reverse proxy
-type reverseProxy struct {
-	target string
-}
-
-func (p *reverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Build the target URL (including query parameters)
-	target := p.target + r.URL.Path
-	if r.URL.RawQuery != "" {
-		target += "?" + r.URL.RawQuery
-	}
-
-	// Create the HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		// Don't follow redirects automatically
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	// Create the proxy request
-	proxyReq, err := http.NewRequest(r.Method, target, r.Body)
-	if err != nil {
-		http.Error(w, "Failed to create proxy request", http.StatusBadGateway)
-		return
-	}
-
-	// Copy headers
-	for name, values := range r.Header {
-		for _, value := range values {
-			proxyReq.Header.Add(name, value)
-		}
-	}
-
-	// Set X-Forwarded-For header
-	if clientIP := r.Header.Get("X-Real-IP"); clientIP != "" {
-		proxyReq.Header.Set("X-Forwarded-For", clientIP)
-	} else {
-		proxyReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
-	}
-
-	// Execute the request
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		http.Error(w, "Failed to proxy request", http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Copy response headers, but skip CORS headers since the main server handles those
-	// This prevents duplicate CORS headers (e.g., "Access-Control-Allow-Origin: *, *")
-	corsHeaders := map[string]bool{
-		"Access-Control-Allow-Origin":      true,
-		"Access-Control-Allow-Methods":     true,
-		"Access-Control-Allow-Headers":     true,
-		"Access-Control-Allow-Credentials": true,
-		"Access-Control-Expose-Headers":    true,
-		"Access-Control-Max-Age":           true,
-	}
-	for name, values := range resp.Header {
-		if !corsHeaders[name] {
-			for _, value := range values {
-				w.Header().Add(name, value)
-			}
-		}
-	}
-
-	// Write status code
-	w.WriteHeader(resp.StatusCode)
-
-	// Copy response body
-	io.Copy(w, resp.Body)
-}
-
 // buildEnv constructs the environment variables for GoTrue
 func (s *Server) buildEnv() []string {
 	var env []string
@@ -247,9 +248,9 @@ func (s *Server) buildEnv() []string {
 	env = append(env, fmt.Sprintf("SITE_URL=%s", s.config.SiteURL))
 
 	// API configuration
-	// API_EXTERNAL_URL is required by GoTrue v2.x
-	if s.config.SiteURL != "" {
-		env = append(env, fmt.Sprintf("API_EXTERNAL_URL=%s", s.config.SiteURL))
+	// API_EXTERNAL_URL is required by GoTrue v2.x, and email links point at it
+	if externalURL := s.externalURL(); externalURL != "" {
+		env = append(env, fmt.Sprintf("API_EXTERNAL_URL=%s", externalURL))
 	}
 	if s.config.URI != "" {
 		env = append(env, fmt.Sprintf("URI=%s", s.config.URI))
@@ -263,6 +264,15 @@ func (s *Server) buildEnv() []string {
 		env = append(env, fmt.Sprintf("LOG_LEVEL=%s", s.config.LogLevel))
 	}
 
+	// Tracing, with spans joined to supalite's through traceparent headers
+	if s.config.TracingEndpoint != "" {
+		env = append(env, "GOTRUE_TRACING_ENABLED=true")
+		env = append(env, "GOTRUE_TRACING_EXPORTER=opentelemetry")
+		env = append(env, "OTEL_SERVICE_NAME=gotrue")
+		env = append(env, "OTEL_EXPORTER_OTLP_PROTOCOL=http/protobuf")
+		env = append(env, fmt.Sprintf("OTEL_EXPORTER_OTLP_ENDPOINT=%s", s.config.TracingEndpoint))
+	}
+
 	// Operator token
 	if s.config.OperatorToken != "" {
 		env = append(env, fmt.Sprintf("OPERATOR_TOKEN=%s", s.config.OperatorToken))
@@ -312,103 +322,172 @@ func (s *Server) buildEnv() []string {
 		}
 	}
 
-	return env
-}
+	// Phone auth configuration
+	if sms := s.config.SMS; sms != nil {
+		env = append(env, "GOTRUE_EXTERNAL_PHONE_ENABLED=true")
+		if sms.Provider != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_PROVIDER=%s", sms.Provider))
+		}
+		if sms.Autoconfirm {
+			env = append(env, "GOTRUE_SMS_AUTOCONFIRM=true")
+		}
+		if sms.Template != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_TEMPLATE=%s", sms.Template))
+		}
+		if sms.OTPExp > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_OTP_EXP=%d", sms.OTPExp))
+		}
+		if sms.OTPLength > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_OTP_LENGTH=%d", sms.OTPLength))
+		}
 
-// findGoTrueBinary searches for the GoTrue binary in various locations,
-// and downloads from GitHub releases if not found locally
-func findGoTrueBinary() (string, error) {
-	// List of locations to search
-	searchPaths := []string{
-		"./bin/gotrue",
-		"./gotrue",
-		"/usr/local/bin/gotrue",
-		"/usr/bin/gotrue",
-	}
+		// Twilio Verify takes the same credentials under its own prefix
+		twilio := "GOTRUE_SMS_TWILIO_"
+		if sms.Provider == "twilio_verify" {
+			twilio = "GOTRUE_SMS_TWILIO_VERIFY_"
+		}
+		if sms.TwilioAccountSID != "" {
+			env = append(env, fmt.Sprintf("%sACCOUNT_SID=%s", twilio, sms.TwilioAccountSID))
+		}
+		if sms.TwilioAuthToken != "" {
+			env = append(env, fmt.Sprintf("%sAUTH_TOKEN=%s", twilio, sms.TwilioAuthToken))
+		}
+		if sms.TwilioMessageServiceSID != "" {
+			env = append(env, fmt.Sprintf("%sMESSAGE_SERVICE_SID=%s", twilio, sms.TwilioMessageServiceSID))
+		}
+		if sms.MessageBirdAccessKey != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_MESSAGEBIRD_ACCESS_KEY=%s", sms.MessageBirdAccessKey))
+		}
+		if sms.MessageBirdOriginator != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_SMS_MESSAGEBIRD_ORIGINATOR=%s", sms.MessageBirdOriginator))
+		}
 
-	// Also search in PATH
-	if path := os.Getenv("PATH"); path != "" {
-		searchPaths = append(searchPaths, strings.Split(path, ":")...)
+		// Capture mode hands every SMS to a database function instead of
+		// the provider
+		if sms.CaptureMode {
+			schema, function, _ := strings.Cut(SMSCaptureHook, ".")
+			env = append(env, "GOTRUE_HOOK_SEND_SMS_ENABLED=true")
+			env = append(env, fmt.Sprintf("GOTRUE_HOOK_SEND_SMS_URI=pg-functions://postgres/%s/%s", schema, function))
+		}
 	}
 
-	for _, path := range searchPaths {
-		if path == "" {
-			continue
-		}
+	env = append(env, s.securityEnv()...)
 
-		// Check if the path is executable
-		if info, err := os.Stat(path); err == nil && !info.IsDir() {
-			// Check if it's executable
-			if info.Mode().Perm()&0111 != 0 {
-				return filepath.Abs(path)
-			}
+	// OAuth providers, in a stable order
+	names := make([]string, 0, len(s.config.External))
+	for name := range s.config.External {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		provider := s.config.External[name]
+		prefix := "GOTRUE_EXTERNAL_" + strings.ToUpper(name) + "_"
+		env = append(env, fmt.Sprintf("%sENABLED=%t", prefix, provider.Enabled))
+		if provider.ClientID != "" {
+			env = append(env, fmt.Sprintf("%sCLIENT_ID=%s", prefix, provider.ClientID))
+		}
+		if provider.Secret != "" {
+			env = append(env, fmt.Sprintf("%sSECRET=%s", prefix, provider.Secret))
+		}
+		redirectURI := provider.RedirectURI
+		if redirectURI == "" {
+			redirectURI = strings.TrimSuffix(s.externalURL(), "/") + "/auth/v1/callback"
+		}
+		env = append(env, fmt.Sprintf("%sREDIRECT_URI=%s", prefix, redirectURI))
+		if provider.URL != "" {
+			env = append(env, fmt.Sprintf("%sURL=%s", prefix, provider.URL))
 		}
 	}
 
-	// Not found locally, download from GitHub releases
-	return downloadGoTrueFromGitHub()
+	return env
 }
 
-// downloadGoTrueFromGitHub downloads the GoTrue binary from GitHub releases
-func downloadGoTrueFromGitHub() (string, error) {
-	// Version to download - should match Supabase hosted auth
-	version := GoTrueVersion
-
-	// Determine the platform-specific binary name
-	// GitHub releases use: darwin-arm64, linux-amd64, etc.
-	platform := runtime.GOOS + "-" + runtime.GOARCH
-	binaryName := "gotrue-" + platform
+// securityEnv returns the MFA, captcha, password, and rate limit settings
+func (s *Server) securityEnv() []string {
+	var env []string
 
-	// Cache directory for downloaded binaries
-	cacheDir := filepath.Join(os.TempDir(), "supalite-gotrue")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	if mfa := s.config.MFA; mfa != nil {
+		if mfa.TOTPEnroll != nil {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_TOTP_ENROLL_ENABLED=%t", *mfa.TOTPEnroll))
+		}
+		if mfa.TOTPVerify != nil {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_TOTP_VERIFY_ENABLED=%t", *mfa.TOTPVerify))
+		}
+		if mfa.PhoneEnroll {
+			env = append(env, "GOTRUE_MFA_PHONE_ENROLL_ENABLED=true")
+		}
+		if mfa.PhoneVerify {
+			env = append(env, "GOTRUE_MFA_PHONE_VERIFY_ENABLED=true")
+		}
+		if mfa.MaxEnrolledFactors > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_MAX_ENROLLED_FACTORS=%d", mfa.MaxEnrolledFactors))
+		}
+		if mfa.MaxVerifiedFactors > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_MAX_VERIFIED_FACTORS=%d", mfa.MaxVerifiedFactors))
+		}
+		if mfa.ChallengeExpiry > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_CHALLENGE_EXPIRY_DURATION=%g", mfa.ChallengeExpiry.Seconds()))
+		}
 	}
 
-	extractPath := filepath.Join(cacheDir, binaryName+"-"+version)
-
-	// Check if already downloaded and valid
-	if info, err := os.Stat(extractPath); err == nil && info.Mode().Perm()&0111 != 0 {
-		return extractPath, nil
+	if captcha := s.config.Captcha; captcha != nil && captcha.Secret != "" {
+		provider := captcha.Provider
+		if provider == "" {
+			provider = "hcaptcha"
+		}
+		env = append(env, "GOTRUE_SECURITY_CAPTCHA_ENABLED=true")
+		env = append(env, fmt.Sprintf("GOTRUE_SECURITY_CAPTCHA_PROVIDER=%s", provider))
+		env = append(env, fmt.Sprintf("GOTRUE_SECURITY_CAPTCHA_SECRET=%s", captcha.Secret))
 	}
 
-	// Download URL from GitHub releases
-	// Assumes releases are structured as: gotrue-darwin-arm64, gotrue-linux-amd64, etc.
-	downloadURL := fmt.Sprintf("https://github.com/burggraf/supalite/releases/download/%s/%s", version, binaryName)
-
-	fmt.Printf("[GoTrue] Downloading from %s\n", downloadURL)
-
-	// Download the binary
-	resp, err := http.Get(downloadURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to download GoTrue: %w", err)
+	if password := s.config.Password; password != nil {
+		if password.MinLength > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_PASSWORD_MIN_LENGTH=%d", password.MinLength))
+		}
+		if password.RequiredCharacters != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_PASSWORD_REQUIRED_CHARACTERS=%s", password.RequiredCharacters))
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download GoTrue: HTTP %d", resp.StatusCode)
+	if limits := s.config.RateLimits; limits != nil {
+		for _, limit := range []struct {
+			name  string
+			value float64
+		}{
+			{"EMAIL_SENT", limits.EmailSent},
+			{"SMS_SENT", limits.SMSSent},
+			{"VERIFY", limits.Verify},
+			{"TOKEN_REFRESH", limits.TokenRefresh},
+			{"OTP", limits.OTP},
+			{"ANONYMOUS_USERS", limits.AnonymousUsers},
+			{"SSO", limits.SSO},
+		} {
+			if limit.value > 0 {
+				env = append(env, fmt.Sprintf("GOTRUE_RATE_LIMIT_%s=%g", limit.name, limit.value))
+			}
+		}
+		if limits.MFA > 0 {
+			env = append(env, fmt.Sprintf("GOTRUE_MFA_RATE_LIMIT_CHALLENGE_AND_VERIFY=%g", limits.MFA))
+		}
+		if limits.Header != "" {
+			env = append(env, fmt.Sprintf("GOTRUE_RATE_LIMIT_HEADER=%s", limits.Header))
+		}
 	}
 
-	// Read the response body
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
+	return env
+}
 
-	// Write to cache
-	if err := os.WriteFile(extractPath, data, 0755); err != nil {
-		return "", fmt.Errorf("failed to write GoTrue binary: %w", err)
+// externalURL is the address clients reach the API at
+func (s *Server) externalURL() string {
+	if s.config.PublicURL != "" {
+		return s.config.PublicURL
 	}
-
-	fmt.Printf("[GoTrue] Downloaded to %s\n", extractPath)
-	return extractPath, nil
+	return s.config.SiteURL
 }
 
 // waitReady polls the settings endpoint until the server is ready
 func (s *Server) waitReady() {
-	client := &http.Client{
-		Timeout: 2 * time.Second,
-	}
+	client := goTrueClient(2 * time.Second)
 
 	// Use /settings endpoint as health check since /health may not exist
 	healthURL := fmt.Sprintf("http://localhost:%d/settings", s.config.Port)
@@ -437,40 +516,55 @@ func (s *Server) waitReady() {
 func (s *Server) monitorOutput(r io.Reader) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		line := scanner.Text()
-		// In a real implementation, this would go to a logger
-		fmt.Printf("[GoTrue] %s\n", line)
+		logGoTrueLine(scanner.Text())
 	}
 }
 
 // monitorAndRestart waits for the GoTrue process to exit and restarts it
-func (s *Server) monitorAndRestart() {
+// unless it was stopped
+func (s *Server) monitorAndRestart(cmd *exec.Cmd) {
 	// Wait for the command to exit
-	err := s.cmd.Wait()
+	err := cmd.Wait()
 
 	s.mu.Lock()
+	if s.stopped || s.cmd != cmd {
+		s.mu.Unlock()
+		return
+	}
 	s.running = false
 	s.ready = false
 	s.mu.Unlock()
 
 	// Log the exit
 	if err != nil {
-		log.Warn("GoTrue process exited unexpectedly", "error", err)
+		logger.Warn("GoTrue process exited unexpectedly", "error", err)
 	} else {
-		log.Info("GoTrue process exited")
+		logger.Info("GoTrue process exited")
+		err = fmt.Errorf("exited with status 0")
 	}
 
 	// Attempt to restart after a short delay
 	time.Sleep(2 * time.Second)
 
-	log.Info("Attempting to restart GoTrue...")
+	s.mu.RLock()
+	stopped := s.stopped
+	s.mu.RUnlock()
+	if stopped {
+		return
+	}
+
+	logger.Info("Attempting to restart GoTrue...")
 
 	// Restart by calling Start again with a new context
 	// We need to use the parent context that was passed to the original Start call
 	// Since we don't have access to it here, we'll create a new one
 	ctx := context.Background()
-	if err := s.Start(ctx); err != nil {
-		log.Error("Failed to restart GoTrue", "error", err)
-		log.Warn("Auth API will not be available until GoTrue is manually restarted")
+	restartErr := s.Start(ctx)
+	if restartErr != nil {
+		logger.Error("Failed to restart GoTrue", "error", restartErr)
+		logger.Warn("Auth API will not be available until GoTrue is manually restarted")
+	}
+	if s.config.OnExit != nil {
+		s.config.OnExit(err, restartErr)
 	}
 }
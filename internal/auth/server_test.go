@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,7 +11,7 @@ import (
 
 func TestGoTrueServer_Start(t *testing.T) {
 	// Skip test if GoTrue is not installed
-	if _, err := findGoTrueBinary(); err != nil {
+	if _, err := findGoTrueBinary(defaultBinDir()); err != nil {
 		t.Skip("GoTrue binary not found, skipping test. Install with: go install github.com/supabase/auth/cmd/gotrue@latest")
 	}
 	// Start embedded Postgres
@@ -74,3 +75,123 @@ func TestGoTrueServer_Start(t *testing.T) {
 		t.Error("GoTrue server is not running")
 	}
 }
+
+func TestBuildEnv_ExternalProviders(t *testing.T) {
+	s := NewServer(Config{
+		PublicURL: "http://192.168.1.10:8080",
+		External: map[string]ExternalProvider{
+			"github": {Enabled: true, ClientID: "gh-id", Secret: "gh-secret"},
+			"gitlab": {Enabled: true, ClientID: "gl-id", Secret: "gl-secret", RedirectURI: "https://app.example.com/cb", URL: "https://gitlab.example.com"},
+		},
+	})
+
+	env := map[string]bool{}
+	for _, kv := range s.buildEnv() {
+		env[kv] = true
+	}
+	for _, want := range []string{
+		"GOTRUE_EXTERNAL_GITHUB_ENABLED=true",
+		"GOTRUE_EXTERNAL_GITHUB_CLIENT_ID=gh-id",
+		"GOTRUE_EXTERNAL_GITHUB_SECRET=gh-secret",
+		"GOTRUE_EXTERNAL_GITHUB_REDIRECT_URI=http://192.168.1.10:8080/auth/v1/callback",
+		"GOTRUE_EXTERNAL_GITLAB_REDIRECT_URI=https://app.example.com/cb",
+		"GOTRUE_EXTERNAL_GITLAB_URL=https://gitlab.example.com",
+	} {
+		if !env[want] {
+			t.Errorf("buildEnv() is missing %s", want)
+		}
+	}
+}
+
+func TestBuildEnv_SMS(t *testing.T) {
+	tests := []struct {
+		name string
+		sms  *SMSConfig
+		want []string
+		not  []string
+	}{
+		{
+			name: "twilio",
+			sms:  &SMSConfig{Provider: "twilio", TwilioAccountSID: "AC1", TwilioAuthToken: "tok", TwilioMessageServiceSID: "MG1", OTPLength: 8},
+			want: []string{"GOTRUE_EXTERNAL_PHONE_ENABLED=true", "GOTRUE_SMS_PROVIDER=twilio", "GOTRUE_SMS_TWILIO_ACCOUNT_SID=AC1", "GOTRUE_SMS_TWILIO_AUTH_TOKEN=tok", "GOTRUE_SMS_TWILIO_MESSAGE_SERVICE_SID=MG1", "GOTRUE_SMS_OTP_LENGTH=8"},
+			not:  []string{"GOTRUE_HOOK_SEND_SMS_ENABLED=true"},
+		},
+		{
+			name: "twilio verify",
+			sms:  &SMSConfig{Provider: "twilio_verify", TwilioAccountSID: "AC1"},
+			want: []string{"GOTRUE_SMS_TWILIO_VERIFY_ACCOUNT_SID=AC1"},
+			not:  []string{"GOTRUE_SMS_TWILIO_ACCOUNT_SID=AC1"},
+		},
+		{
+			name: "messagebird",
+			sms:  &SMSConfig{Provider: "messagebird", MessageBirdAccessKey: "key", MessageBirdOriginator: "supalite"},
+			want: []string{"GOTRUE_SMS_MESSAGEBIRD_ACCESS_KEY=key", "GOTRUE_SMS_MESSAGEBIRD_ORIGINATOR=supalite"},
+		},
+		{
+			name: "capture mode",
+			sms:  &SMSConfig{CaptureMode: true},
+			want: []string{"GOTRUE_EXTERNAL_PHONE_ENABLED=true", "GOTRUE_HOOK_SEND_SMS_ENABLED=true", "GOTRUE_HOOK_SEND_SMS_URI=pg-functions://postgres/admin/capture_sms"},
+		},
+		{
+			name: "disabled",
+			not:  []string{"GOTRUE_EXTERNAL_PHONE_ENABLED=true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]bool{}
+			for _, kv := range NewServer(Config{SMS: tt.sms}).buildEnv() {
+				env[kv] = true
+			}
+			for _, want := range tt.want {
+				if !env[want] {
+					t.Errorf("buildEnv() is missing %s", want)
+				}
+			}
+			for _, not := range tt.not {
+				if env[not] {
+					t.Errorf("buildEnv() should not contain %s", not)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildEnv_Security(t *testing.T) {
+	enroll := false
+	s := NewServer(Config{
+		MFA:        &MFAConfig{TOTPEnroll: &enroll, PhoneEnroll: true, MaxEnrolledFactors: 3, ChallengeExpiry: 5 * time.Minute},
+		Captcha:    &CaptchaConfig{Provider: "turnstile", Secret: "captcha-secret"},
+		Password:   &PasswordPolicy{MinLength: 12, RequiredCharacters: "abcdefghijklmnopqrstuvwxyz:0123456789"},
+		RateLimits: &RateLimits{EmailSent: 30, TokenRefresh: 150, MFA: 15, Header: "X-Forwarded-For"},
+	})
+
+	env := map[string]bool{}
+	for _, kv := range s.buildEnv() {
+		env[kv] = true
+	}
+	for _, want := range []string{
+		"GOTRUE_MFA_TOTP_ENROLL_ENABLED=false",
+		"GOTRUE_MFA_PHONE_ENROLL_ENABLED=true",
+		"GOTRUE_MFA_MAX_ENROLLED_FACTORS=3",
+		"GOTRUE_MFA_CHALLENGE_EXPIRY_DURATION=300",
+		"GOTRUE_SECURITY_CAPTCHA_ENABLED=true",
+		"GOTRUE_SECURITY_CAPTCHA_PROVIDER=turnstile",
+		"GOTRUE_SECURITY_CAPTCHA_SECRET=captcha-secret",
+		"GOTRUE_PASSWORD_MIN_LENGTH=12",
+		"GOTRUE_PASSWORD_REQUIRED_CHARACTERS=abcdefghijklmnopqrstuvwxyz:0123456789",
+		"GOTRUE_RATE_LIMIT_EMAIL_SENT=30",
+		"GOTRUE_RATE_LIMIT_TOKEN_REFRESH=150",
+		"GOTRUE_MFA_RATE_LIMIT_CHALLENGE_AND_VERIFY=15",
+		"GOTRUE_RATE_LIMIT_HEADER=X-Forwarded-For",
+	} {
+		if !env[want] {
+			t.Errorf("buildEnv() is missing %s", want)
+		}
+	}
+	for kv := range env {
+		if strings.HasPrefix(kv, "GOTRUE_MFA_TOTP_VERIFY_ENABLED") || strings.HasPrefix(kv, "GOTRUE_RATE_LIMIT_VERIFY") {
+			t.Errorf("buildEnv() should leave unset settings to GoTrue, got %s", kv)
+		}
+	}
+}
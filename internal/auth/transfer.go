@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UserExportVersion is the version of the export file format
+const UserExportVersion = 1
+
+// UserExport is the file written by `supalite auth export`.
+//
+// Rows are kept as GoTrue stores them, keyed by column name, so hashed
+// passwords, metadata, and confirmation state survive the trip, and exports
+// taken with one GoTrue version import into another: unknown columns are
+// ignored and missing ones take their defaults.
+type UserExport struct {
+	Version    int                      `json:"version"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Anonymized bool                     `json:"anonymized,omitempty"`
+	Users      []map[string]interface{} `json:"users"`
+	Identities []map[string]interface{} `json:"identities"`
+}
+
+// ImportResult counts the rows an import added and skipped.
+type ImportResult struct {
+	Users             int
+	SkippedUsers      int
+	Identities        int
+	SkippedIdentities int
+}
+
+// ExportUsers reads every row of auth.users and auth.identities.
+func ExportUsers(ctx context.Context, conn *pgx.Conn) (*UserExport, error) {
+	users, err := exportRows(ctx, conn, "SELECT to_jsonb(u) FROM auth.users u ORDER BY u.created_at, u.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to export users: %w", err)
+	}
+	identities, err := exportRows(ctx, conn, "SELECT to_jsonb(i) FROM auth.identities i ORDER BY i.created_at, i.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to export identities: %w", err)
+	}
+
+	return &UserExport{
+		Version:    UserExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Users:      users,
+		Identities: identities,
+	}, nil
+}
+
+func exportRows(ctx context.Context, conn *pgx.Conn, query string) ([]map[string]interface{}, error) {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[map[string]interface{}])
+}
+
+// ImportUsers inserts the users and identities of an export in a single
+// transaction. Users whose id, email, or phone already exists are skipped,
+// along with identities that conflict or belong to a skipped user, so
+// importing the same file twice is safe.
+func ImportUsers(ctx context.Context, conn *pgx.Conn, export *UserExport) (*ImportResult, error) {
+	if export.Version != UserExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d (want %d)", export.Version, UserExportVersion)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	result := &ImportResult{}
+	userColumns, err := insertableColumns(ctx, tx, "auth.users")
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range export.Users {
+		inserted, err := importRow(ctx, tx, "auth.users", userColumns, user, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to import user %v: %w", user["id"], err)
+		}
+		if inserted {
+			result.Users++
+		} else {
+			result.SkippedUsers++
+		}
+	}
+
+	identityColumns, err := insertableColumns(ctx, tx, "auth.identities")
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range export.Identities {
+		inserted, err := importRow(ctx, tx, "auth.identities", identityColumns, identity,
+			"EXISTS (SELECT 1 FROM auth.users u WHERE u.id = r.user_id)")
+		if err != nil {
+			return nil, fmt.Errorf("failed to import identity %v: %w", identity["id"], err)
+		}
+		if inserted {
+			result.Identities++
+		} else {
+			result.SkippedIdentities++
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// insertableColumns returns the columns of table that accept values, leaving
+// out generated columns such as auth.users.confirmed_at.
+func insertableColumns(ctx context.Context, tx pgx.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT attname
+		FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attnum > 0
+			AND NOT attisdropped AND attgenerated = ''
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s not found; start ./supalite serve once so GoTrue creates its schema", table)
+	}
+
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[name] = true
+	}
+	return columns, nil
+}
+
+// importRow inserts one exported row, setting only the columns it has. The
+// row is decoded by jsonb_populate_record, so Postgres converts each value
+// to its column's type. It reports false if the row conflicted.
+func importRow(ctx context.Context, tx pgx.Tx, table string, columns map[string]bool, row map[string]interface{}, condition string) (bool, error) {
+	var names []string
+	for name := range row {
+		if columns[name] {
+			names = append(names, pgx.Identifier{name}.Sanitize())
+		}
+	}
+	if len(names) == 0 {
+		return false, fmt.Errorf("row has no %s columns", table)
+	}
+
+	list := strings.Join(names, ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM jsonb_populate_record(NULL::%s, $1) r", table, list, list, table)
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	query += " ON CONFLICT DO NOTHING"
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, query, string(data))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// tokenColumns hold one-time tokens and pending changes, which an
+// anonymized export clears. GoTrue expects empty strings, not NULLs.
+var tokenColumns = []string{
+	"confirmation_token",
+	"recovery_token",
+	"email_change_token_new",
+	"email_change_token_current",
+	"email_change",
+	"phone_change_token",
+	"phone_change",
+	"reauthentication_token",
+}
+
+// Anonymize replaces personal data in the export, for seeding staging from
+// production. Emails become <id>@example.invalid, and phones, user metadata,
+// password hashes, and pending tokens are removed. Ids, roles, app metadata,
+// and confirmation state are kept, so RLS behaves the same; anonymized
+// users can't sign in with a password until one is set.
+func (e *UserExport) Anonymize() {
+	emails := make(map[string]string, len(e.Users))
+	for _, user := range e.Users {
+		id, _ := user["id"].(string)
+		if email, ok := user["email"].(string); ok && email != "" {
+			emails[id] = id + "@example.invalid"
+			user["email"] = emails[id]
+		}
+		if _, ok := user["phone"]; ok {
+			user["phone"] = nil
+		}
+		if _, ok := user["raw_user_meta_data"]; ok {
+			user["raw_user_meta_data"] = map[string]interface{}{}
+		}
+		if _, ok := user["encrypted_password"]; ok {
+			user["encrypted_password"] = ""
+		}
+		for _, col := range tokenColumns {
+			if _, ok := user[col]; ok {
+				user[col] = ""
+			}
+		}
+	}
+
+	for _, identity := range e.Identities {
+		data, _ := identity["identity_data"].(map[string]interface{})
+		userID, _ := identity["user_id"].(string)
+		anonymized := map[string]interface{}{}
+		if sub, ok := data["sub"]; ok {
+			anonymized["sub"] = sub
+		}
+		if email, ok := emails[userID]; ok {
+			anonymized["email"] = email
+			if identity["provider"] == "email" {
+				identity["provider_id"] = userID
+			}
+		}
+		identity["identity_data"] = anonymized
+	}
+
+	e.Anonymized = true
+}
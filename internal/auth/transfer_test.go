@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUserExport_Anonymize(t *testing.T) {
+	const exported = `{
+		"version": 1,
+		"users": [
+			{
+				"id": "8d6e0b8a-4a4b-4a2a-9d3f-2f1e8c3b7a10",
+				"email": "alice@example.com",
+				"phone": "+15551234567",
+				"role": "authenticated",
+				"encrypted_password": "$2a$10$abcdefghijklmnopqrstuv",
+				"recovery_token": "secret",
+				"email_confirmed_at": "2024-01-02T03:04:05Z",
+				"raw_user_meta_data": {"full_name": "Alice Liddell"},
+				"raw_app_meta_data": {"provider": "email", "plan": "pro"}
+			}
+		],
+		"identities": [
+			{
+				"id": "0f3b9e52-5b1d-4f7e-8a3c-6f2d1e0a9b84",
+				"user_id": "8d6e0b8a-4a4b-4a2a-9d3f-2f1e8c3b7a10",
+				"provider": "email",
+				"provider_id": "8d6e0b8a-4a4b-4a2a-9d3f-2f1e8c3b7a10",
+				"identity_data": {"sub": "8d6e0b8a-4a4b-4a2a-9d3f-2f1e8c3b7a10", "email": "alice@example.com", "full_name": "Alice Liddell"}
+			}
+		]
+	}`
+
+	var export UserExport
+	if err := json.Unmarshal([]byte(exported), &export); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	export.Anonymize()
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	for _, leaked := range []string{"alice", "Alice", "+1555", "$2a$", "secret"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("anonymized export still contains %q: %s", leaked, data)
+		}
+	}
+
+	user := export.Users[0]
+	if got, want := user["email"], "8d6e0b8a-4a4b-4a2a-9d3f-2f1e8c3b7a10@example.invalid"; got != want {
+		t.Errorf("email = %v, want %v", got, want)
+	}
+	if user["phone"] != nil {
+		t.Errorf("phone = %v, want nil", user["phone"])
+	}
+	if user["recovery_token"] != "" {
+		t.Errorf("recovery_token = %v, want empty string", user["recovery_token"])
+	}
+	if user["email_confirmed_at"] != "2024-01-02T03:04:05Z" || user["role"] != "authenticated" {
+		t.Errorf("confirmation state and role should be kept: %v", user)
+	}
+	if meta, _ := user["raw_app_meta_data"].(map[string]interface{}); meta["plan"] != "pro" {
+		t.Errorf("raw_app_meta_data = %v, want it kept", user["raw_app_meta_data"])
+	}
+	if _, ok := user["encrypted_password"]; !ok {
+		t.Error("encrypted_password should be cleared, not removed")
+	}
+
+	identityData := export.Identities[0]["identity_data"].(map[string]interface{})
+	if identityData["email"] != user["email"] {
+		t.Errorf("identity email = %v, want %v", identityData["email"], user["email"])
+	}
+	if identityData["sub"] != user["id"] {
+		t.Errorf("identity sub = %v, want %v", identityData["sub"], user["id"])
+	}
+	if !export.Anonymized {
+		t.Error("Anonymized should be set")
+	}
+}
+
+func TestImportUsers_RejectsUnknownVersion(t *testing.T) {
+	_, err := ImportUsers(context.Background(), nil, &UserExport{Version: 99})
+	if err == nil || !strings.Contains(err.Error(), "unsupported export version") {
+		t.Errorf("ImportUsers() error = %v, want unsupported version", err)
+	}
+}
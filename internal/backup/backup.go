@@ -0,0 +1,357 @@
+// Package backup backs up and restores a database with PostgreSQL's own
+// client programs: pg_dump writes a backup, and pg_restore (custom format)
+// or psql (plain SQL) loads one.
+//
+// The programs are taken from the data directory's client programs of the
+// server's version (see package components), or from the PostgreSQL
+// distribution embedded-postgres extracted: the bin directory of the
+// running postmaster, or embedded-postgres' default runtime directory.
+// Client tools on PATH are used when neither has them. pg_dump can't dump
+// a server newer than itself, so a program older than the server is
+// skipped. When none is found, the client programs of the server's version
+// are downloaded into the data directory.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/components"
+	"github.com/markb/supalite/internal/pg"
+)
+
+// Format is a backup file's format.
+type Format string
+
+const (
+	// FormatPlain is a SQL script, loaded with psql. It drops the objects
+	// it creates first, so it can be loaded over an existing database.
+	FormatPlain Format = "plain"
+
+	// FormatCustom is pg_dump's compressed archive, loaded with pg_restore.
+	FormatCustom Format = "custom"
+)
+
+// customMagic starts every custom-format archive.
+var customMagic = []byte("PGDMP")
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatPlain, FormatCustom:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("invalid backup format %q: must be plain or custom", s)
+}
+
+// DetectFormat returns the format of the backup file at path.
+func DetectFormat(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, len(customMagic))
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if bytes.Equal(head[:n], customMagic) {
+		return FormatCustom, nil
+	}
+	return FormatPlain, nil
+}
+
+// Target is the database to back up or restore.
+type Target struct {
+	Host     string
+	Port     uint16
+	User     string
+	Password string
+	Database string
+
+	DataDir string // Optional: the supalite data directory, to find the running postmaster's programs
+	Version int    // Server major version; programs older than this are skipped
+}
+
+// TargetFor returns the database conn is connected to. dataDir is the
+// supalite data directory it belongs to.
+func TargetFor(ctx context.Context, conn *pgx.Conn, dataDir string) (Target, error) {
+	var versionNum int
+	if err := conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&versionNum); err != nil {
+		return Target{}, fmt.Errorf("failed to read the server version: %w", err)
+	}
+	cfg := conn.Config()
+	return Target{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		Password: cfg.Password,
+		Database: cfg.Database,
+		DataDir:  dataDir,
+		Version:  versionNum / 10000,
+	}, nil
+}
+
+// DumpOptions selects what Dump writes.
+type DumpOptions struct {
+	Format  Format
+	Out     string   // File to write; standard output when empty (plain format only)
+	Schemas []string // Optional: only these schemas
+	Exclude []string // Optional: tables to leave out, as schema.table
+
+	// Keep each object's owner, for a backup restored with RestoreOwned
+	// into a cluster that has the same roles
+	KeepOwners bool
+}
+
+// Dump backs up the target with pg_dump: schema and data, without
+// ownership unless opts.KeepOwners is set, so the backup can be restored
+// as another user.
+func Dump(ctx context.Context, t Target, opts DumpOptions) error {
+	if opts.Format == FormatCustom && opts.Out == "" {
+		return fmt.Errorf("a custom-format backup needs an output file")
+	}
+	tool, err := FindTool("pg_dump", t)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"--format", string(opts.Format)}
+	if !opts.KeepOwners {
+		args = append(args, "--no-owner")
+	}
+	if opts.Format == FormatPlain {
+		args = append(args, "--clean", "--if-exists")
+	}
+	if opts.Out != "" {
+		args = append(args, "--file", opts.Out)
+	}
+	for _, schema := range opts.Schemas {
+		args = append(args, "--schema", schema)
+	}
+	for _, table := range opts.Exclude {
+		args = append(args, "--exclude-table", table)
+	}
+	return run(ctx, tool, t, args, os.Stdout)
+}
+
+// Restore loads the backup at path into the target in one transaction:
+// if any statement fails, the database is left as it was. Objects in the
+// backup replace existing ones of the same name.
+func Restore(ctx context.Context, t Target, path string) error {
+	return restore(ctx, t, path, false)
+}
+
+// RestoreOwned is Restore for a custom-format backup made with
+// KeepOwners: objects get the owners they had, so the target's cluster
+// must have their roles (see DumpGlobals).
+func RestoreOwned(ctx context.Context, t Target, path string) error {
+	return restore(ctx, t, path, true)
+}
+
+func restore(ctx context.Context, t Target, path string, keepOwners bool) error {
+	format, err := DetectFormat(path)
+	if err != nil {
+		return err
+	}
+
+	var tool string
+	var args []string
+	switch format {
+	case FormatCustom:
+		if tool, err = FindTool("pg_restore", t); err != nil {
+			return err
+		}
+		args = []string{"--clean", "--if-exists", "--single-transaction", "--exit-on-error", path}
+		if !keepOwners {
+			args = append([]string{"--no-owner"}, args...)
+		}
+	default:
+		if tool, err = FindTool("psql", t); err != nil {
+			return err
+		}
+		args = []string{"--quiet", "--no-psqlrc", "--single-transaction", "--set", "ON_ERROR_STOP=1", "--file", path}
+	}
+	return run(ctx, tool, t, args, io.Discard)
+}
+
+// DumpGlobals writes the target cluster's roles, with their passwords
+// and memberships, and its tablespaces to out as SQL, with pg_dumpall.
+// They belong to the cluster rather than to a database, so Dump leaves
+// them out.
+func DumpGlobals(ctx context.Context, t Target, out string) error {
+	tool, err := FindTool("pg_dumpall", t)
+	if err != nil {
+		return err
+	}
+	return run(ctx, tool, t, []string{"--globals-only", "--file", out}, io.Discard)
+}
+
+// LoadGlobals runs a file written by DumpGlobals against the target. The
+// cluster's bootstrap superuser already exists, so statements that fail
+// are skipped rather than stopping the load.
+func LoadGlobals(ctx context.Context, t Target, path string) error {
+	tool, err := FindTool("psql", t)
+	if err != nil {
+		return err
+	}
+	return run(ctx, tool, t, []string{"--quiet", "--no-psqlrc", "--file", path}, io.Discard)
+}
+
+// Shell runs psql interactively against the target, on the terminal, with
+// args after the connection options.
+func Shell(t Target, args []string) error {
+	tool, err := FindTool("psql", t)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(tool, append(connectionArgs(t), args...)...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+t.Password)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// run runs a client program against the target. The password goes in the
+// environment, where other users can't see it.
+func run(ctx context.Context, tool string, t Target, args []string, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, tool, append(connectionArgs(t), args...)...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+t.Password)
+	cmd.Stdout = stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %s", filepath.Base(tool), msg)
+		}
+		return fmt.Errorf("%s failed: %w", filepath.Base(tool), err)
+	}
+	return nil
+}
+
+// connectionArgs are the options that point a client program at the target.
+func connectionArgs(t Target) []string {
+	return []string{
+		"--host", t.Host,
+		"--port", strconv.Itoa(int(t.Port)),
+		"--username", t.User,
+		"--dbname", t.Database,
+	}
+}
+
+// FindTool returns the path of the client program name, at least as new
+// as the target's server: from the data directory's client programs, the
+// running postmaster's bin directory, embedded-postgres' runtime
+// directory, or PATH, in that order. Failing those, the data directory's
+// client programs are downloaded (see components.InstallClient).
+func FindTool(name string, t Target) (string, error) {
+	path, err := findLocalTool(name, t)
+	if err == nil || t.DataDir == "" || t.Version == 0 {
+		return path, err
+	}
+	bin, installErr := components.InstallClient(t.DataDir, t.Version)
+	if installErr != nil {
+		return "", fmt.Errorf("%w; downloading them failed: %v", err, installErr)
+	}
+	return filepath.Join(bin, toolFile(name)), nil
+}
+
+// findLocalTool is FindTool without the download.
+func findLocalTool(name string, t Target) (string, error) {
+	name = toolFile(name)
+
+	var candidates []string
+	if t.DataDir != "" && t.Version > 0 {
+		if bin, err := components.CachedClient(t.DataDir, t.Version); err == nil {
+			candidates = append(candidates, filepath.Join(bin, name))
+		}
+	}
+	for _, dir := range binDirs(t.DataDir) {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		candidates = append(candidates, path)
+	}
+
+	var tooOld []string
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		version, err := toolVersion(path)
+		if err != nil {
+			continue
+		}
+		if version < t.Version {
+			tooOld = append(tooOld, fmt.Sprintf("%s (%d)", path, version))
+			continue
+		}
+		return path, nil
+	}
+
+	if len(tooOld) > 0 {
+		return "", fmt.Errorf("%s is older than the server (PostgreSQL %d): %s", name, t.Version, strings.Join(tooOld, ", "))
+	}
+	return "", fmt.Errorf("%s not found in the embedded PostgreSQL distribution or on PATH; install the PostgreSQL %d client programs", name, t.Version)
+}
+
+// toolFile is a program's file name on this platform.
+func toolFile(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// binDirs returns the directories the embedded distribution's programs may
+// be in: the running postmaster's, where the platform can tell, and
+// embedded-postgres' default runtime directory.
+func binDirs(dataDir string) []string {
+	var dirs []string
+	if dataDir != "" {
+		if postmaster, err := pg.ReadPostmaster(pg.ClusterPath(dataDir)); err == nil && postmaster.Running() {
+			if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", postmaster.PID)); err == nil {
+				dirs = append(dirs, filepath.Dir(exe))
+			}
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".embedded-postgres-go", "extracted", "bin"))
+	}
+	return dirs
+}
+
+// versionPattern finds the major version in a program's --version output,
+// e.g. "pg_dump (PostgreSQL) 16.9".
+var versionPattern = regexp.MustCompile(`\(PostgreSQL\) (\d+)`)
+
+// toolVersion returns the major version of the program at path.
+func toolVersion(path string) (int, error) {
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return 0, err
+	}
+	return parseVersion(string(out))
+}
+
+func parseVersion(output string) (int, error) {
+	match := versionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("unrecognized version %q", strings.TrimSpace(output))
+	}
+	return strconv.Atoi(match[1])
+}
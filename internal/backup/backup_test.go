@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"backup.dump": "PGDMP\x01\x0e\x00",
+		"backup.sql":  "--\n-- PostgreSQL database dump\n--\n",
+		"empty.sql":   "",
+	}
+	want := map[string]Format{"backup.dump": FormatCustom, "backup.sql": FormatPlain, "empty.sql": FormatPlain}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if got, err := DetectFormat(path); err != nil || got != want[name] {
+			t.Errorf("DetectFormat(%s) = %q, %v, want %q", name, got, err, want[name])
+		}
+	}
+
+	if _, err := DetectFormat(filepath.Join(dir, "missing.sql")); err == nil {
+		t.Error("DetectFormat(missing) succeeded")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("custom"); err != nil || f != FormatCustom {
+		t.Errorf("ParseFormat(custom) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("tar"); err == nil {
+		t.Error("ParseFormat(tar) succeeded")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]int{
+		"pg_dump (PostgreSQL) 16.9\n":                    16,
+		"psql (PostgreSQL) 14.13 (Ubuntu 14.13-1)\n":     14,
+		"pg_restore (PostgreSQL) 17beta1\n":              17,
+		"pg_dump (PostgreSQL) 9.6.24\n":                  9,
+		"pg_dump (PostgreSQL) 15.8 (Homebrew) \nextra\n": 15,
+	}
+	for output, want := range tests {
+		if got, err := parseVersion(output); err != nil || got != want {
+			t.Errorf("parseVersion(%q) = %d, %v, want %d", output, got, err, want)
+		}
+	}
+	if _, err := parseVersion("something else"); err == nil {
+		t.Error("parseVersion(something else) succeeded")
+	}
+}
+
+func TestFindTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as programs")
+	}
+
+	// A PATH with an old pg_dump and a current pg_restore, and a home
+	// without embedded-postgres' runtime directory
+	bin := t.TempDir()
+	for name, version := range map[string]string{"pg_dump": "13.4", "pg_restore": "16.9"} {
+		script := "#!/bin/sh\necho '" + name + " (PostgreSQL) " + version + "'\n"
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("HOME", t.TempDir())
+
+	target := Target{Version: 16}
+	if path, err := FindTool("pg_restore", target); err != nil || path != filepath.Join(bin, "pg_restore") {
+		t.Errorf("FindTool(pg_restore) = %q, %v", path, err)
+	}
+	if _, err := FindTool("pg_dump", target); err == nil || !strings.Contains(err.Error(), "older than the server") {
+		t.Errorf("FindTool(pg_dump) error = %v, want older than the server", err)
+	}
+	if _, err := FindTool("psql", target); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("FindTool(psql) error = %v, want not found", err)
+	}
+}
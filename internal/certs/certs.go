@@ -0,0 +1,206 @@
+// Package certs provides the certificates supalite serves HTTPS with: a
+// self-signed certificate for local development, and certificate files
+// that are reloaded when they are renewed.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	// SelfSignedValidity is how long a generated certificate is valid.
+	SelfSignedValidity = 365 * 24 * time.Hour
+
+	// renewBefore is how long before expiry a generated certificate is
+	// replaced.
+	renewBefore = 30 * 24 * time.Hour
+
+	// checkInterval is how often certificate files are checked for changes.
+	checkInterval = time.Minute
+)
+
+// DefaultHosts are the names a self-signed certificate is always valid for.
+var DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// SelfSigned returns the paths of a self-signed certificate and key in dir,
+// valid for DefaultHosts and hosts (names or IP addresses). An existing
+// certificate is reused unless it expires within 30 days or doesn't cover
+// every host, so browsers only need to trust it once.
+func SelfSigned(dir string, hosts []string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, "selfsigned.crt")
+	keyFile = filepath.Join(dir, "selfsigned.key")
+
+	hosts = append(slices.Clone(DefaultHosts), hosts...)
+	slices.Sort(hosts)
+	hosts = slices.Compact(hosts)
+
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && covers(cert.Leaf, hosts) {
+		return certFile, keyFile, nil
+	}
+
+	certPEM, keyPEM, err := generate(hosts, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", keyFile, err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+	log.Info("generated self-signed certificate", "path", certFile, "hosts", hosts)
+	return certFile, keyFile, nil
+}
+
+// covers reports whether a certificate is valid for every host for at
+// least another 30 days.
+func covers(cert *x509.Certificate, hosts []string) bool {
+	if cert == nil || time.Until(cert.NotAfter) < renewBefore {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generate creates a self-signed ECDSA P-256 certificate for hosts, in PEM.
+func generate(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"supalite"}, CommonName: "supalite self-signed"},
+		NotBefore:             now.Add(-time.Hour), // Tolerate clock skew
+		NotAfter:              now.Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true, // So it can be trusted directly, as its own root
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Reloader serves a certificate from files, loading it again when the
+// files change, so renewed certificates (e.g. by certbot) are picked up
+// without a restart.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewReloader loads a certificate and key from files.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= checkInterval {
+		r.checked = time.Now()
+		if modTime, err := r.latestModTime(); err == nil && modTime.After(r.modTime) {
+			// Keep serving the old certificate if the new one can't be
+			// loaded, e.g. while it is half written
+			if err := r.loadLocked(); err != nil {
+				log.Warn("failed to reload TLS certificate", "path", r.certFile, "error", err)
+			} else {
+				log.Info("reloaded TLS certificate", "path", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *Reloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checked = time.Now()
+	return r.loadLocked()
+}
+
+func (r *Reloader) loadLocked() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime returns when the certificate or key last changed.
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
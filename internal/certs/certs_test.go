@@ -0,0 +1,102 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, err := SelfSigned(dir, nil)
+	if err != nil {
+		t.Fatalf("SelfSigned() failed: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("generated certificate doesn't load: %v", err)
+	}
+	for _, host := range DefaultHosts {
+		if err := cert.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("certificate not valid for %s: %v", host, err)
+		}
+	}
+	if info, _ := os.Stat(keyFile); info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Reused while it covers the hosts, so browsers trust it once
+	first, _ := os.ReadFile(certFile)
+	SelfSigned(dir, []string{"localhost"})
+	if again, _ := os.ReadFile(certFile); !bytes.Equal(first, again) {
+		t.Error("certificate should be reused")
+	}
+
+	// Replaced when a new host is needed
+	if _, _, err := SelfSigned(dir, []string{"192.168.1.20", "dev.local"}); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = tls.LoadX509KeyPair(certFile, keyFile)
+	for _, host := range []string{"localhost", "192.168.1.20", "dev.local"} {
+		if err := cert.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("regenerated certificate not valid for %s: %v", host, err)
+		}
+	}
+}
+
+func TestCovers_Expiring(t *testing.T) {
+	certPEM, keyPEM, err := generate(DefaultHosts, time.Now().Add(-SelfSignedValidity+renewBefore/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if covers(cert.Leaf, DefaultHosts) {
+		t.Error("a certificate expiring within 30 days should be replaced")
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := dir+"/tls.crt", dir+"/tls.key"
+	write := func(host string, modTime time.Time) {
+		certPEM, keyPEM, err := generate([]string{host}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(certFile, certPEM, 0644)
+		os.WriteFile(keyFile, keyPEM, 0600)
+		os.Chtimes(certFile, modTime, modTime)
+		os.Chtimes(keyFile, modTime, modTime)
+	}
+
+	write("one.example", time.Now().Add(-time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader() failed: %v", err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf.VerifyHostname("one.example") != nil {
+		t.Fatal("expected the first certificate")
+	}
+
+	// A renewed certificate is picked up at the next check
+	write("two.example", time.Now())
+	r.checked = time.Time{}
+	cert, _ = r.GetCertificate(nil)
+	if cert.Leaf.VerifyHostname("two.example") != nil {
+		t.Error("expected the renewed certificate")
+	}
+
+	// A broken file keeps the current certificate
+	os.WriteFile(keyFile, []byte("half written"), 0600)
+	os.Chtimes(keyFile, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	r.checked = time.Time{}
+	if cert, _ = r.GetCertificate(nil); cert.Leaf.VerifyHostname("two.example") != nil {
+		t.Error("a broken renewal should keep the current certificate")
+	}
+}
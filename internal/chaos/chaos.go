@@ -0,0 +1,165 @@
+// Package chaos injects failures into a running supalite, so the retry and
+// supervision logic around them can be exercised on purpose: database
+// connections that fail, a slow auth API, and a GoTrue process that dies.
+//
+// Injection is off unless the server runs with chaos enabled. Failures
+// are drawn from a seeded random source, so a run with the same seed and
+// the same sequence of requests fails the same requests every time.
+//
+//	injector := chaos.New(42)
+//	injector.Set(chaos.Faults{DBFailPercent: 25})
+//	if err := injector.DBFault(); err != nil {
+//	    // the request fails as if the connection had been refused
+//	}
+//
+// A nil *Injector injects nothing, so callers need no checks.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Faults selects the failures to inject.
+type Faults struct {
+	DBFailPercent float64 `json:"db_fail_percent"` // Share of database connections that fail, 0-100
+	AuthDelayMS   int     `json:"auth_delay_ms"`   // Delay added to each auth API request
+}
+
+// Validate checks that the faults are in range.
+func (f Faults) Validate() error {
+	if f.DBFailPercent < 0 || f.DBFailPercent > 100 {
+		return fmt.Errorf("db_fail_percent must be between 0 and 100, got %v", f.DBFailPercent)
+	}
+	if f.AuthDelayMS < 0 {
+		return fmt.Errorf("auth_delay_ms must not be negative, got %d", f.AuthDelayMS)
+	}
+	return nil
+}
+
+// Stats counts the failures injected since the injector was created.
+type Stats struct {
+	DBFailures    int64 `json:"db_failures"`
+	AuthDelays    int64 `json:"auth_delays"`
+	GoTrueKills   int64 `json:"gotrue_kills"`
+	DBConnections int64 `json:"db_connections"` // Connections the DB fault was drawn for
+}
+
+// Injector decides which operations fail.
+type Injector struct {
+	mu     sync.Mutex
+	seed   int64
+	rng    *rand.Rand
+	faults Faults
+	stats  Stats
+}
+
+// New creates an injector drawing failures from seed. It injects nothing
+// until faults are set.
+func New(seed int64) *Injector {
+	return &Injector{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Set replaces the active faults.
+func (i *Injector) Set(f Faults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = f
+	return nil
+}
+
+// Reset clears the faults and counters and reseeds the random source, so
+// the next run fails the same requests as the first.
+func (i *Injector) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = Faults{}
+	i.stats = Stats{}
+	i.rng = rand.New(rand.NewSource(i.seed))
+}
+
+// Faults returns the active faults.
+func (i *Injector) Faults() Faults {
+	if i == nil {
+		return Faults{}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.faults
+}
+
+// Stats returns the injection counters.
+func (i *Injector) Stats() Stats {
+	if i == nil {
+		return Stats{}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.stats
+}
+
+// DBFault returns an error for the share of database connections set by
+// DBFailPercent, or nil. The error is Postgres' cannot_connect_now, which
+// callers treat as transient, as they would a server that is restarting.
+func (i *Injector) DBFault() error {
+	if i == nil {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.faults.DBFailPercent <= 0 {
+		return nil
+	}
+	i.stats.DBConnections++
+	if i.rng.Float64()*100 >= i.faults.DBFailPercent {
+		return nil
+	}
+	i.stats.DBFailures++
+	return &pgconn.PgError{
+		Severity: "FATAL",
+		Code:     "57P03", // cannot_connect_now
+		Message:  "chaos: injected database failure",
+	}
+}
+
+// DelayAuth waits AuthDelayMS before an auth API request is served,
+// returning early if ctx ends.
+func (i *Injector) DelayAuth(ctx context.Context) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	delay := time.Duration(i.faults.AuthDelayMS) * time.Millisecond
+	if delay > 0 {
+		i.stats.AuthDelays++
+	}
+	i.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// RecordKill counts a GoTrue kill.
+func (i *Injector) RecordKill() {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.GoTrueKills++
+}
@@ -0,0 +1,118 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// failures draws n database faults and returns which ones failed.
+func failures(i *Injector, n int) []bool {
+	failed := make([]bool, n)
+	for k := range failed {
+		failed[k] = i.DBFault() != nil
+	}
+	return failed
+}
+
+func TestDBFault(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent float64
+		wantMin int
+		wantMax int
+	}{
+		{"off", 0, 0, 0},
+		{"always", 100, 1000, 1000},
+		{"a quarter", 25, 200, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := New(1)
+			if err := i.Set(Faults{DBFailPercent: tt.percent}); err != nil {
+				t.Fatal(err)
+			}
+			failed := 0
+			for _, f := range failures(i, 1000) {
+				if f {
+					failed++
+				}
+			}
+			if failed < tt.wantMin || failed > tt.wantMax {
+				t.Errorf("%d of 1000 connections failed, want %d-%d", failed, tt.wantMin, tt.wantMax)
+			}
+			if got := i.Stats().DBFailures; got != int64(failed) {
+				t.Errorf("Stats().DBFailures = %d, want %d", got, failed)
+			}
+		})
+	}
+}
+
+func TestDBFault_Deterministic(t *testing.T) {
+	a, b := New(42), New(42)
+	a.Set(Faults{DBFailPercent: 50})
+	b.Set(Faults{DBFailPercent: 50})
+
+	first := failures(a, 100)
+	if got := failures(b, 100); !slices.Equal(first, got) {
+		t.Error("injectors with the same seed failed different connections")
+	}
+
+	// Reset replays the same sequence
+	a.Reset()
+	a.Set(Faults{DBFailPercent: 50})
+	if got := failures(a, 100); !slices.Equal(first, got) {
+		t.Error("Reset() did not replay the same failures")
+	}
+}
+
+func TestDBFault_Transient(t *testing.T) {
+	i := New(1)
+	i.Set(Faults{DBFailPercent: 100})
+
+	var pgErr *pgconn.PgError
+	if err := i.DBFault(); !errors.As(err, &pgErr) || pgErr.Code != "57P03" {
+		t.Errorf("DBFault() = %v, want a cannot_connect_now PgError", err)
+	}
+}
+
+func TestDelayAuth(t *testing.T) {
+	i := New(1)
+	i.Set(Faults{AuthDelayMS: 50})
+
+	start := time.Now()
+	i.DelayAuth(context.Background())
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("DelayAuth() returned after %v, want at least 50ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	i.Set(Faults{AuthDelayMS: 10000})
+	start = time.Now()
+	i.DelayAuth(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DelayAuth() ignored a canceled context for %v", elapsed)
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var i *Injector
+	if err := i.DBFault(); err != nil {
+		t.Errorf("nil injector DBFault() = %v", err)
+	}
+	i.DelayAuth(context.Background())
+	i.RecordKill()
+}
+
+func TestFaults_Validate(t *testing.T) {
+	for _, f := range []Faults{{DBFailPercent: -1}, {DBFailPercent: 101}, {AuthDelayMS: -5}} {
+		if err := New(1).Set(f); err == nil {
+			t.Errorf("Set(%+v) should fail", f)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Package components downloads, verifies, and caches the programs supalite
+// runs besides PostgreSQL itself, in the data directory's bin directory.
+// GoTrue has its own cache (see auth.InstallGoTrue). This package holds
+// PostgreSQL's client programs, psql, pg_dump, pg_dumpall, and pg_restore,
+// which the shell, dump, restore, and upgrade commands run.
+//
+// The embedded PostgreSQL distribution doesn't always carry the client
+// programs, and those on PATH may be missing or older than the server, so
+// each PostgreSQL major version's client programs are published for every
+// platform with supalite's releases, as an archive holding bin/ and lib/,
+// with their SHA-256 in the release's checksums.txt:
+//
+//	postgresql-client-16-linux-amd64.tar.gz
+//
+// An archive is unpacked into <data-dir>/bin/postgresql-client/<major>/<os>-<arch>/
+// once its checksum matches. Machines without network access install from
+// a directory holding the archive and checksums.txt, copied from a machine
+// that has it (InstallClientFrom).
+package components
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+var logger = log.Component("components")
+
+// ClientRelease is the supalite release the client programs are published
+// with.
+const ClientRelease = "postgresql-client-v1"
+
+// ClientPrograms are the client programs supalite runs.
+var ClientPrograms = []string{"psql", "pg_dump", "pg_dumpall", "pg_restore"}
+
+// releaseURL is where release assets are published, by release and file
+// name. Tests point it at a local server.
+var releaseURL = "https://github.com/burggraf/supalite/releases/download/%s/%s"
+
+// checksumsFile lists the SHA-256 of every asset of a release, in
+// sha256sum format.
+const checksumsFile = "checksums.txt"
+
+// checksumMarker is written into an unpacked archive's directory, last,
+// holding the archive's SHA-256. A directory without it is incomplete.
+const checksumMarker = ".sha256"
+
+// ClientArchive is the release asset holding a major version's client
+// programs for this platform.
+func ClientArchive(major int) string {
+	return fmt.Sprintf("postgresql-client-%d-%s-%s.tar.gz", major, runtime.GOOS, runtime.GOARCH)
+}
+
+// ClientDir is where a major version's client programs for this platform
+// are unpacked under a data directory.
+func ClientDir(dataDir string, major int) string {
+	return filepath.Join(dataDir, "bin", "postgresql-client", strconv.Itoa(major), runtime.GOOS+"-"+runtime.GOARCH)
+}
+
+// ClientBinDir is the directory holding the programs in ClientDir.
+func ClientBinDir(dataDir string, major int) string {
+	return filepath.Join(ClientDir(dataDir, major), "bin")
+}
+
+// Client is a major version's client programs, installed or not.
+type Client struct {
+	Major     int    `json:"major"`
+	Dir       string `json:"dir"`
+	Installed bool   `json:"installed"`
+	SHA256    string `json:"sha256,omitempty"` // The archive's, when installed
+}
+
+// CachedClient returns the bin directory of a major version's client
+// programs, if they were installed, without touching the network.
+func CachedClient(dataDir string, major int) (string, error) {
+	dir := ClientDir(dataDir, major)
+	if _, err := os.Stat(filepath.Join(dir, checksumMarker)); err != nil {
+		return "", fmt.Errorf("PostgreSQL %d client programs are not installed in %s", major, dir)
+	}
+	return filepath.Join(dir, "bin"), nil
+}
+
+// InstalledClients lists the client programs installed under a data
+// directory for this platform, oldest major version first.
+func InstalledClients(dataDir string) ([]Client, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, "bin", "postgresql-client"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []Client
+	for _, entry := range entries {
+		major, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		c := Client{Major: major, Dir: ClientDir(dataDir, major)}
+		if sum, err := os.ReadFile(filepath.Join(c.Dir, checksumMarker)); err == nil {
+			c.Installed = true
+			c.SHA256 = strings.TrimSpace(string(sum))
+		}
+		clients = append(clients, c)
+	}
+	// Directory entries come sorted by name, which isn't numeric order
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Major < clients[j].Major })
+	return clients, nil
+}
+
+// InstallClient downloads a major version's client programs for this
+// platform into the data directory, verified against the release's
+// published checksums, and returns their bin directory. Programs already
+// installed are kept.
+func InstallClient(dataDir string, major int) (string, error) {
+	if bin, err := CachedClient(dataDir, major); err == nil {
+		return bin, nil
+	}
+
+	checksums, err := download(fmt.Sprintf(releaseURL, ClientRelease, checksumsFile))
+	if err != nil {
+		return "", fmt.Errorf("failed to download the client program checksums: %w", err)
+	}
+	name := ClientArchive(major)
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf(releaseURL, ClientRelease, name)
+	logger.Info("downloading PostgreSQL client programs", "url", url)
+	archive, err := download(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w (to install offline, use supalite components install --from)", name, err)
+	}
+	return installArchive(dataDir, major, archive, want)
+}
+
+// InstallClientFrom installs a major version's client programs from a
+// directory holding the release's archive for this platform and its
+// checksums.txt, for machines without network access.
+func InstallClientFrom(dataDir string, major int, dir string) (string, error) {
+	checksums, err := os.ReadFile(filepath.Join(dir, checksumsFile))
+	if err != nil {
+		return "", err
+	}
+	name := ClientArchive(major)
+	want, err := findChecksum(checksums, name)
+	if err != nil {
+		return "", err
+	}
+	archive, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return installArchive(dataDir, major, archive, want)
+}
+
+// installArchive checks an archive against its checksum and unpacks it
+// into ClientDir, replacing what was there. It is unpacked next to
+// ClientDir and renamed, so an interrupted install never leaves a partial
+// set of programs.
+func installArchive(dataDir string, major int, archive []byte, want string) (string, error) {
+	if got := sha256Hex(archive); got != want {
+		return "", fmt.Errorf("%s is corrupt: SHA-256 %s, want %s", ClientArchive(major), got, want)
+	}
+
+	dir := ClientDir(dataDir, major)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(dir), err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := unpack(archive, tmp); err != nil {
+		return "", fmt.Errorf("failed to unpack %s: %w", ClientArchive(major), err)
+	}
+	for _, program := range ClientPrograms {
+		if _, err := os.Stat(filepath.Join(tmp, "bin", programFile(program))); err != nil {
+			return "", fmt.Errorf("%s has no bin/%s", ClientArchive(major), programFile(program))
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, checksumMarker), []byte(want+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	logger.Info("installed PostgreSQL client programs", "version", major, "path", dir)
+	return filepath.Join(dir, "bin"), nil
+}
+
+// unpack extracts a gzipped tar archive into dir. Entries that would land
+// outside dir are errors.
+func unpack(archive []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, hdr.Name)
+		if !within(dir, path) {
+			return fmt.Errorf("%s is outside the archive", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode)&0755|0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// Shared libraries are linked by version, e.g. libpq.so.5
+			if !within(dir, filepath.Join(filepath.Dir(path), hdr.Linkname)) {
+				return fmt.Errorf("%s links outside the archive", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// within reports whether path is dir or inside it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// programFile is a program's file name on this platform.
+func programFile(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// download fetches url into memory.
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// findChecksum returns the SHA-256 listed for name in a sha256sum-format
+// file ("<hex>  <name>" per line, with "*" before binary-mode names).
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s: no client programs for this platform and version", checksumsFile, name)
+}
+
+// sha256Hex returns the hex SHA-256 of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,158 @@
+package components
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testArchive builds a client archive holding the given files, with
+// names ending in "->" made symlinks to the rest of the name.
+func testArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if link, ok := strings.CutPrefix(content, "->"); ok {
+			hdr = &tar.Header{Name: name, Linkname: link, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func clientFiles() map[string]string {
+	files := map[string]string{
+		"lib/libpq.so.5.16": "lib",
+		"lib/libpq.so.5":    "->libpq.so.5.16",
+	}
+	for _, program := range ClientPrograms {
+		files["bin/"+programFile(program)] = "#!/bin/sh\n"
+	}
+	return files
+}
+
+// serveRelease serves a release with archive as the client archive of
+// major, and returns how many requests it got.
+func serveRelease(t *testing.T, major int, archive []byte, checksum string) *int {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/" + ClientRelease + "/" + checksumsFile:
+			fmt.Fprintf(w, "%s  %s\n", checksum, ClientArchive(major))
+		case "/" + ClientRelease + "/" + ClientArchive(major):
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	old := releaseURL
+	releaseURL = srv.URL + "/%s/%s"
+	t.Cleanup(func() { releaseURL = old })
+	return &requests
+}
+
+func TestInstallClient(t *testing.T) {
+	archive := testArchive(t, clientFiles())
+	requests := serveRelease(t, 16, archive, sha256Hex(archive))
+	dataDir := t.TempDir()
+
+	if _, err := CachedClient(dataDir, 16); err == nil {
+		t.Error("CachedClient() before installing succeeded")
+	}
+	bin, err := InstallClient(dataDir, 16)
+	if err != nil {
+		t.Fatalf("InstallClient() failed: %v", err)
+	}
+	if bin != ClientBinDir(dataDir, 16) {
+		t.Errorf("InstallClient() = %s, want %s", bin, ClientBinDir(dataDir, 16))
+	}
+	if _, err := os.Stat(filepath.Join(bin, programFile("pg_dump"))); err != nil {
+		t.Errorf("pg_dump not unpacked: %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(ClientDir(dataDir, 16), "lib", "libpq.so.5")); err != nil || link != "libpq.so.5.16" {
+		t.Errorf("libpq.so.5 -> %q, %v", link, err)
+	}
+
+	// Installed programs are kept
+	made := *requests
+	if _, err := InstallClient(dataDir, 16); err != nil || *requests != made {
+		t.Errorf("InstallClient() again: %v, %d requests", err, *requests-made)
+	}
+	clients, err := InstalledClients(dataDir)
+	if err != nil || len(clients) != 1 || !clients[0].Installed || clients[0].SHA256 != sha256Hex(archive) {
+		t.Errorf("InstalledClients() = %+v, %v", clients, err)
+	}
+
+	// No archive for this version
+	if _, err := InstallClient(dataDir, 17); err == nil || !strings.Contains(err.Error(), "does not list") {
+		t.Errorf("InstallClient(17) = %v, want an unlisted error", err)
+	}
+}
+
+func TestInstallClient_Corrupt(t *testing.T) {
+	archive := testArchive(t, clientFiles())
+	serveRelease(t, 16, archive, strings.Repeat("0", 64))
+	dataDir := t.TempDir()
+
+	if _, err := InstallClient(dataDir, 16); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("InstallClient() = %v, want a corrupt error", err)
+	}
+	if _, err := os.Stat(ClientDir(dataDir, 16)); !os.IsNotExist(err) {
+		t.Errorf("a corrupt archive left %s behind", ClientDir(dataDir, 16))
+	}
+}
+
+func TestInstallClientFrom(t *testing.T) {
+	dir := t.TempDir()
+	files := clientFiles()
+	delete(files, "bin/"+programFile("psql"))
+	incomplete := testArchive(t, files)
+	os.WriteFile(filepath.Join(dir, ClientArchive(15)), incomplete, 0644)
+	os.WriteFile(filepath.Join(dir, checksumsFile), []byte(sha256Hex(incomplete)+" *"+ClientArchive(15)+"\n"), 0644)
+
+	dataDir := t.TempDir()
+	if _, err := InstallClientFrom(dataDir, 15, dir); err == nil || !strings.Contains(err.Error(), "psql") {
+		t.Errorf("InstallClientFrom(without psql) = %v, want a missing psql error", err)
+	}
+
+	archive := testArchive(t, clientFiles())
+	os.WriteFile(filepath.Join(dir, ClientArchive(15)), archive, 0644)
+	os.WriteFile(filepath.Join(dir, checksumsFile), []byte(sha256Hex(archive)+"  "+ClientArchive(15)+"\n"), 0644)
+	if _, err := InstallClientFrom(dataDir, 15, dir); err != nil {
+		t.Fatalf("InstallClientFrom() failed: %v", err)
+	}
+	if _, err := CachedClient(dataDir, 15); err != nil {
+		t.Errorf("CachedClient() after InstallClientFrom: %v", err)
+	}
+}
+
+func TestUnpack_Outside(t *testing.T) {
+	for _, files := range []map[string]string{
+		{"../evil": "x"},
+		{"lib/libpq.so": "->../../evil"},
+	} {
+		if err := unpack(testArchive(t, files), t.TempDir()); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("unpack(%v) = %v, want an outside error", files, err)
+		}
+	}
+}
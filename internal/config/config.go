@@ -4,40 +4,404 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 )
 
 // EmailConfig holds email configuration for GoTrue
 type EmailConfig struct {
-	SMTPHost            string `json:"smtp_host,omitempty"`
-	SMTPPort            int    `json:"smtp_port,omitempty"`
-	SMTPUser            string `json:"smtp_user,omitempty"`
-	SMTPPass            string `json:"smtp_pass,omitempty"`
-	SMTPAdminEmail      string `json:"smtp_admin_email,omitempty"`
-	MailerAutoconfirm   bool   `json:"mailer_autoconfirm,omitempty"`
-	MailerURLPathsInvite     string `json:"mailer_urlpaths_invite,omitempty"`
+	SMTPHost                   string `json:"smtp_host,omitempty"`
+	SMTPPort                   int    `json:"smtp_port,omitempty"`
+	SMTPUser                   string `json:"smtp_user,omitempty"`
+	SMTPPass                   string `json:"smtp_pass,omitempty"`
+	SMTPAdminEmail             string `json:"smtp_admin_email,omitempty"`
+	MailerAutoconfirm          bool   `json:"mailer_autoconfirm,omitempty"`
+	MailerURLPathsInvite       string `json:"mailer_urlpaths_invite,omitempty"`
 	MailerURLPathsConfirmation string `json:"mailer_urlpaths_confirmation,omitempty"`
-	MailerURLPathsRecovery    string `json:"mailer_urlpaths_recovery,omitempty"`
-	MailerURLPathsEmailChange string `json:"mailer_urlpaths_email_change,omitempty"`
+	MailerURLPathsRecovery     string `json:"mailer_urlpaths_recovery,omitempty"`
+	MailerURLPathsEmailChange  string `json:"mailer_urlpaths_email_change,omitempty"`
 
 	// Capture mode configuration
 	CaptureMode bool `json:"capture_mode,omitempty"`
 	CapturePort int  `json:"capture_port,omitempty"`
+
+	// Where captured emails go: "database" (default), "maildir", or "webhook"
+	CaptureSink       string `json:"capture_sink,omitempty"`
+	CaptureDir        string `json:"capture_dir,omitempty"`         // Maildir location (default: <data_dir>/mail)
+	CaptureWebhookURL string `json:"capture_webhook_url,omitempty"` // URL emails are POSTed to
+	// Secret ("whsec_...") the webhook sink signs requests with; see package webhook
+	CaptureWebhookSecret string `json:"capture_webhook_secret,omitempty"`
+}
+
+// SMSConfig holds phone auth configuration for GoTrue
+type SMSConfig struct {
+	Provider                string `json:"provider,omitempty"` // "twilio", "twilio_verify", or "messagebird"
+	TwilioAccountSID        string `json:"twilio_account_sid,omitempty"`
+	TwilioAuthToken         string `json:"twilio_auth_token,omitempty"`
+	TwilioMessageServiceSID string `json:"twilio_message_service_sid,omitempty"`
+	MessageBirdAccessKey    string `json:"messagebird_access_key,omitempty"`
+	MessageBirdOriginator   string `json:"messagebird_originator,omitempty"`
+	Template                string `json:"template,omitempty"`   // Default: "Your code is {{ .Code }}"
+	OTPExp                  int    `json:"otp_exp,omitempty"`    // Seconds a code stays valid (default: 60)
+	OTPLength               int    `json:"otp_length,omitempty"` // Digits per code (default: 6)
+	Autoconfirm             bool   `json:"autoconfirm,omitempty"`
+
+	// Capture mode stores OTP messages in public.captured_sms instead of sending them
+	CaptureMode bool `json:"capture_mode,omitempty"`
+}
+
+// AuthConfig holds GoTrue hardening settings. Unset values keep GoTrue's
+// defaults.
+type AuthConfig struct {
+	MFA        *MFAConfig       `json:"mfa,omitempty"`
+	Captcha    *CaptchaConfig   `json:"captcha,omitempty"`
+	Password   *PasswordConfig  `json:"password,omitempty"`
+	RateLimits *RateLimitConfig `json:"rate_limits,omitempty"`
+}
+
+// MFAConfig controls multi-factor enrollment and verification
+type MFAConfig struct {
+	TOTPEnrollEnabled      *bool `json:"totp_enroll_enabled,omitempty"` // Default: true
+	TOTPVerifyEnabled      *bool `json:"totp_verify_enabled,omitempty"` // Default: true
+	PhoneEnrollEnabled     bool  `json:"phone_enroll_enabled,omitempty"`
+	PhoneVerifyEnabled     bool  `json:"phone_verify_enabled,omitempty"`
+	MaxEnrolledFactors     int   `json:"max_enrolled_factors,omitempty"`
+	MaxVerifiedFactors     int   `json:"max_verified_factors,omitempty"`
+	ChallengeExpirySeconds int   `json:"challenge_expiry_seconds,omitempty"`
+}
+
+// CaptchaConfig requires a captcha token on sign up, sign in, and recovery
+type CaptchaConfig struct {
+	Provider string `json:"provider,omitempty"` // "hcaptcha" (default) or "turnstile"
+	Secret   string `json:"secret,omitempty"`
+}
+
+// PasswordConfig sets the password requirements
+type PasswordConfig struct {
+	MinLength          int    `json:"min_length,omitempty"`          // Default: 6
+	RequiredCharacters string `json:"required_characters,omitempty"` // Character sets separated by ":"
+}
+
+// RateLimitConfig overrides GoTrue's per-endpoint rate limits
+type RateLimitConfig struct {
+	EmailSent      float64 `json:"email_sent,omitempty"`      // Per hour
+	SMSSent        float64 `json:"sms_sent,omitempty"`        // Per hour
+	Verify         float64 `json:"verify,omitempty"`          // Per 5 minutes per IP
+	TokenRefresh   float64 `json:"token_refresh,omitempty"`   // Per 5 minutes per IP
+	OTP            float64 `json:"otp,omitempty"`             // Per 5 minutes per IP
+	AnonymousUsers float64 `json:"anonymous_users,omitempty"` // Per hour per IP
+	SSO            float64 `json:"sso,omitempty"`             // Per 5 minutes per IP
+	MFA            float64 `json:"mfa,omitempty"`             // Per minute per IP
+	Header         string  `json:"header,omitempty"`          // Header holding the client IP behind a proxy
+}
+
+// localeNamePattern matches locale and time zone names, e.g. "de_DE.UTF-8",
+// "und-u-ks-level2", or "America/Argentina/Buenos_Aires".
+var localeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_@=;.+/-]+$`)
+
+// CaptchaProviders lists the captcha providers GoTrue supports
+var CaptchaProviders = []string{"hcaptcha", "turnstile"}
+
+// SMSProviders lists the SMS providers supalite can configure
+var SMSProviders = []string{"twilio", "twilio_verify", "messagebird"}
+
+// SeedUser describes an auth user created at startup
+type SeedUser struct {
+	Email        string                 `json:"email,omitempty"`
+	Phone        string                 `json:"phone,omitempty"`
+	Password     string                 `json:"password,omitempty"`
+	Confirmed    bool                   `json:"confirmed,omitempty"`
+	UserMetadata map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetadata  map[string]interface{} `json:"app_metadata,omitempty"`
+}
+
+// ExternalProviderConfig configures an OAuth provider for GoTrue
+type ExternalProviderConfig struct {
+	Enabled     bool   `json:"enabled,omitempty"`
+	ClientID    string `json:"client_id,omitempty"`
+	Secret      string `json:"secret,omitempty"`
+	RedirectURI string `json:"redirect_uri,omitempty"` // Default: <public_url>/auth/v1/callback
+	URL         string `json:"url,omitempty"`          // Self-hosted providers only (GitLab, Keycloak, WorkOS, Azure tenants)
+}
+
+// ExternalProviders lists the OAuth providers GoTrue supports
+var ExternalProviders = []string{
+	"apple", "azure", "bitbucket", "discord", "facebook", "figma", "fly",
+	"github", "gitlab", "google", "kakao", "keycloak", "linkedin_oidc",
+	"notion", "slack_oidc", "snapchat", "spotify", "twitch", "twitter",
+	"workos", "zoom",
+}
+
+// RPCConfig restricts which Postgres functions /rest/v1/rpc can call.
+// Function names are bare ("add_numbers") or schema-qualified ("api.add_numbers").
+type RPCConfig struct {
+	Schemas              []string `json:"schemas,omitempty"`                // Schemas searched for functions (default: public)
+	Functions            []string `json:"functions,omitempty"`              // Allowlist; empty allows every function in Schemas
+	ServiceRoleFunctions []string `json:"service_role_functions,omitempty"` // Callable only with the service_role key
+}
+
+// ResponseConfig sets the default shape of REST response bodies. Clients
+// can override it per request with Prefer: nulls=... and Prefer: keys=...
+type ResponseConfig struct {
+	OmitNulls     bool `json:"omit_nulls,omitempty"`      // Drop fields whose value is null
+	CamelCaseKeys bool `json:"camel_case_keys,omitempty"` // Rewrite snake_case keys as camelCase
+}
+
+// RESTConfig selects the REST API version served at /rest and announces
+// when v1 goes away. /rest/v1 and /rest/v2 always serve their version.
+type RESTConfig struct {
+	DefaultVersion int    `json:"default_version,omitempty"` // 1 or 2 (default: 1)
+	V1Sunset       string `json:"v1_sunset,omitempty"`       // Date v1 is retired, YYYY-MM-DD or RFC 3339
+
+	// How table and column names in requests map to identifiers: "exact"
+	// (as written, e.g. Prisma's camelCase columns), "fold" (lowercased,
+	// as PostgreSQL folds unquoted names), or "snake_case" (createdAt is
+	// created_at, and response keys are camelCase). Default: exact.
+	IdentifierCase string `json:"identifier_case,omitempty"`
+
+	// Text and bytea values longer than max_field_size characters or bytes
+	// are returned as {"truncated": true, "length": ..., "value": prefix}.
+	// field_size_limits sets the limit of single columns, keyed
+	// "table.column"; 0 there lifts the limit. Default: no limit.
+	MaxFieldSize    int            `json:"max_field_size,omitempty"`
+	FieldSizeLimits map[string]int `json:"field_size_limits,omitempty"`
+
+	// Cache-Control header of table reads, keyed by table name, with "*"
+	// for the tables not listed, e.g. {"*": "private, no-cache",
+	// "countries": "public, max-age=3600"}. Default: none.
+	CacheControl map[string]string `json:"cache_control,omitempty"`
+}
+
+// IdentifierCases are the values RESTConfig.IdentifierCase accepts.
+var IdentifierCases = []string{"exact", "fold", "snake_case"}
+
+// Sunset parses V1Sunset, returning the zero time when it is unset.
+func (c *RESTConfig) Sunset() (time.Time, error) {
+	if c.V1Sunset == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", c.V1Sunset); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, c.V1Sunset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid v1 sunset %q: use YYYY-MM-DD or RFC 3339", c.V1Sunset)
+	}
+	return t, nil
+}
+
+// LimitsConfig caps the memory and CPU of the Postgres and GoTrue child
+// processes. Zero values mean no limit.
+type LimitsConfig struct {
+	PGMemoryMB      int     `json:"pg_memory_mb,omitempty"`      // Memory ceiling for Postgres
+	PGCPUs          float64 `json:"pg_cpus,omitempty"`           // CPU quota for Postgres, in cores
+	PGSharedBuffers string  `json:"pg_shared_buffers,omitempty"` // shared_buffers (default: sized to the memory limit)
+	AuthMemoryMB    int     `json:"auth_memory_mb,omitempty"`    // Memory ceiling for GoTrue
+	AuthCPUs        float64 `json:"auth_cpus,omitempty"`         // CPU quota for GoTrue, in cores
+}
+
+// WatchdogConfig sets the thresholds of the disk and memory watchdog.
+// Zero values use the watchdog's defaults.
+type WatchdogConfig struct {
+	IntervalSeconds int     `json:"interval_seconds,omitempty"`  // How often to sample (default: 15)
+	WarnFreePercent float64 `json:"warn_free_percent,omitempty"` // Warn below this share of free disk (default: 10)
+	MinFreeMB       int     `json:"min_free_mb,omitempty"`       // Free disk that counts as exhausted (default: 256)
+	RefuseWrites    bool    `json:"refuse_writes,omitempty"`     // Answer writes with 507 below min_free_mb
+	WALWarnMB       int     `json:"wal_warn_mb,omitempty"`       // Warn when pg_wal grows past this (default: 1024)
+}
+
+// ChaosConfig enables fault injection for testing retries and supervision.
+// Never enable it outside of tests.
+type ChaosConfig struct {
+	Enabled       bool    `json:"enabled,omitempty"`
+	Seed          int64   `json:"seed,omitempty"`            // Seeds which requests fail (default: 1)
+	DBFailPercent float64 `json:"db_fail_percent,omitempty"` // Share of database connections that fail, 0-100
+	AuthDelayMS   int     `json:"auth_delay_ms,omitempty"`   // Delay added to each auth API request
+}
+
+// NotificationsConfig sends operational alerts (crashes, failed backups,
+// disk warnings, repeated auth failures) to Slack, a webhook, or email.
+type NotificationsConfig struct {
+	SlackWebhookURL string   `json:"slack_webhook_url,omitempty"` // Slack incoming webhook
+	WebhookURL      string   `json:"webhook_url,omitempty"`       // URL events are POSTed to as JSON
+	WebhookSecret   string   `json:"webhook_secret,omitempty"`    // Secret ("whsec_...") webhook requests are signed with
+	Email           []string `json:"email,omitempty"`             // Recipients, mailed through the email.smtp_* server
+	MinSeverity     string   `json:"min_severity,omitempty"`      // "info" (default), "warning", or "critical"
+	CooldownSeconds int      `json:"cooldown_seconds,omitempty"`  // Suppress repeats of an alert (default: 600)
+	Instance        string   `json:"instance,omitempty"`          // Name alerts are sent under (default: host name)
+
+	// Failed authentications from one client within the window that
+	// trigger an alert (default: 20 in 300 seconds)
+	AuthFailureThreshold     int `json:"auth_failure_threshold,omitempty"`
+	AuthFailureWindowSeconds int `json:"auth_failure_window_seconds,omitempty"`
+}
+
+// TLSConfig serves the API over HTTPS, with a certificate from files, a
+// generated self-signed certificate, or Let's Encrypt. Exactly one source
+// may be set.
+type TLSConfig struct {
+	CertFile         string   `json:"cert_file,omitempty"`          // PEM certificate chain
+	KeyFile          string   `json:"key_file,omitempty"`           // PEM private key
+	SelfSigned       bool     `json:"self_signed,omitempty"`        // Generate a certificate for local development into data_dir/tls
+	ACMEDomains      []string `json:"acme_domains,omitempty"`       // Get certificates for these domains from Let's Encrypt
+	ACMEEmail        string   `json:"acme_email,omitempty"`         // Contact for expiry notices from the ACME CA
+	ACMEDirectoryURL string   `json:"acme_directory_url,omitempty"` // ACME CA (default: Let's Encrypt)
+	HTTPPort         int      `json:"http_port,omitempty"`          // Also serve plain HTTP here: ACME challenges and redirects to HTTPS
+}
+
+// Enabled reports whether a certificate source is set.
+func (t *TLSConfig) Enabled() bool {
+	return t != nil && (t.CertFile != "" || t.KeyFile != "" || t.SelfSigned || len(t.ACMEDomains) > 0)
+}
+
+// HistoryConfig controls row-level change history (the audit schema).
+// Tracking is opt-in per table.
+type HistoryConfig struct {
+	Tables        []string `json:"tables,omitempty"`         // Tables to track, e.g. "todos" or "app.orders"
+	RetentionDays int      `json:"retention_days,omitempty"` // Delete versions older than this (default: keep all)
+	TimeTravel    bool     `json:"time_travel,omitempty"`    // Serve REST reads of tracked tables at a past time (?as_of), for development
+}
+
+// AuditLogConfig controls the log of writes made through the REST API
+// (the audit.api_log table)
+type AuditLogConfig struct {
+	Enabled       bool `json:"enabled,omitempty"`
+	RetentionDays int  `json:"retention_days,omitempty"` // Delete entries older than this (default: keep all)
+}
+
+// RESTRecordConfig controls recording of REST requests and the SQL they
+// ran, for debugging (see package recorder)
+type RESTRecordConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	Size    int  `json:"size,omitempty"` // How many recent requests to keep (default: 200)
+}
+
+// UsageConfig controls usage accounting per API key and quotas (see
+// package usage)
+type UsageConfig struct {
+	Enabled bool         `json:"enabled,omitempty"`
+	Period  string       `json:"period,omitempty"`  // "month" (default) or "day"
+	PerKey  bool         `json:"per_key,omitempty"` // Apply quotas to each API key instead of the project total
+	Soft    *UsageLimits `json:"soft,omitempty"`    // Warn when reached
+	Hard    *UsageLimits `json:"hard,omitempty"`    // Refuse requests when reached
+}
+
+// UsageLimits caps usage per period. Zero is unlimited.
+type UsageLimits struct {
+	Requests     int64 `json:"requests,omitempty"`
+	RowsRead     int64 `json:"rows_read,omitempty"`
+	RowsWritten  int64 `json:"rows_written,omitempty"`
+	EgressBytes  int64 `json:"egress_bytes,omitempty"`
+	StorageBytes int64 `json:"storage_bytes,omitempty"` // Stored objects, for the whole project
+}
+
+func (l *UsageLimits) negative() bool {
+	return l != nil && (l.Requests < 0 || l.RowsRead < 0 || l.RowsWritten < 0 || l.EgressBytes < 0 || l.StorageBytes < 0)
+}
+
+// MigrationsConfig controls SQL migrations (see package migrate)
+type MigrationsConfig struct {
+	Dir       string `json:"dir,omitempty"`        // Migration files (default: ./migrations, or ./supabase/migrations)
+	AutoApply bool   `json:"auto_apply,omitempty"` // Apply pending migrations when the server starts
+}
+
+// StatusConfig controls the public status page at /status
+type StatusConfig struct {
+	Enabled         bool   `json:"enabled,omitempty"`
+	Title           string `json:"title,omitempty"`            // Page heading (default: "Supalite Status")
+	IntervalSeconds int    `json:"interval_seconds,omitempty"` // How often APIs are probed (default: 30)
+}
+
+// APIRateLimitConfig limits each client's requests to the REST and auth
+// APIs, so a runaway client can't exhaust the database. The REST and auth
+// APIs have separate budgets.
+type APIRateLimitConfig struct {
+	PerIP         int `json:"per_ip,omitempty"`         // Requests per window from each client address (default: unlimited)
+	PerKey        int `json:"per_key,omitempty"`        // Requests per window with each API key or session token (default: unlimited)
+	WindowSeconds int `json:"window_seconds,omitempty"` // Default: 60
+}
+
+// TracingConfig exports OpenTelemetry traces over OTLP/HTTP
+type TracingConfig struct {
+	Endpoint    string  `json:"endpoint,omitempty"`     // Collector URL, e.g. "http://localhost:4318" (default: tracing off)
+	ServiceName string  `json:"service_name,omitempty"` // Default: "supalite"
+	SampleRatio float64 `json:"sample_ratio,omitempty"` // Share of new traces recorded, 0-1 (default: 1)
+}
+
+// RouteRule applies headers, CORS, a rate limit, or an access restriction
+// to requests under a path prefix, optionally only for some methods and
+// caller roles. Every matching rule applies, in order.
+type RouteRule struct {
+	Path    string   `json:"path"`              // Path prefix, e.g. "/rest/v1"
+	Methods []string `json:"methods,omitempty"` // Methods matched (default: all)
+	Roles   []string `json:"roles,omitempty"`   // Caller roles matched, e.g. "anon" (default: all)
+
+	Deny         bool              `json:"deny,omitempty"`          // Reject matching requests with 403
+	RequireRoles []string          `json:"require_roles,omitempty"` // Reject callers without one of these roles
+	Headers      map[string]string `json:"headers,omitempty"`       // Response headers to set
+	CORS         *RouteCORSConfig  `json:"cors,omitempty"`          // CORS policy for the path (methods and roles are ignored)
+	RateLimit    *RouteRateLimit   `json:"rate_limit,omitempty"`
+}
+
+// RouteCORSConfig replaces the default allow-all CORS policy for a path
+type RouteCORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins,omitempty"`
+	AllowedMethods   []string `json:"allowed_methods,omitempty"` // Default: GET, POST, PUT, PATCH, DELETE
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"` // Default: all
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAgeSeconds    int      `json:"max_age_seconds,omitempty"`
+}
+
+// RouteRateLimit allows a number of requests per window to each client
+type RouteRateLimit struct {
+	Requests      int    `json:"requests"`
+	WindowSeconds int    `json:"window_seconds,omitempty"` // Default: 60
+	Per           string `json:"per,omitempty"`            // "ip" (default) or "key"
 }
 
 // Config holds the complete Supalite configuration
 type Config struct {
 	// Server settings
-	Host     string `json:"host,omitempty"`
-	Port     int    `json:"port,omitempty"`
-	DataDir  string `json:"data_dir,omitempty"`
-	SiteURL  string `json:"site_url,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	DataDir   string `json:"data_dir,omitempty"`
+	SiteURL   string `json:"site_url,omitempty"`
+	PublicURL string `json:"public_url,omitempty"` // Address other devices reach supalite at, or "lan"
+
+	// HTTPS for the API, dashboard, and realtime
+	TLS *TLSConfig `json:"tls,omitempty"`
 
 	// PostgreSQL settings
-	PGPort     uint16 `json:"pg_port,omitempty"`
-	PGUsername string `json:"pg_username,omitempty"`
-	PGPassword string `json:"pg_password,omitempty"`
-	PGDatabase string `json:"pg_database,omitempty"`
+	//
+	// A postgres:// URL serves the APIs from that PostgreSQL instead of
+	// starting the embedded one; the pg_port, credentials, locale, and
+	// limit settings then don't apply.
+	DatabaseURL string `json:"database_url,omitempty"`
+	PGPort      uint16 `json:"pg_port,omitempty"`
+	PGUsername  string `json:"pg_username,omitempty"`
+	PGPassword  string `json:"pg_password,omitempty"`
+	PGDatabase  string `json:"pg_database,omitempty"`
+	PGVersion   string `json:"pg_version,omitempty"`    // Embedded PostgreSQL version, e.g. "17" or "17.5.0" (default: 16.9.0)
+	PGSocketDir string `json:"pg_socket_dir,omitempty"` // Listen on a unix socket in this directory instead of pg_port's TCP port
+	PGMinConns  int32  `json:"pg_min_conns,omitempty"`  // Connection pool idle minimum
+	PGMaxConns  int32  `json:"pg_max_conns,omitempty"`  // Connection pool size limit
+
+	// Separate connection pools per request role, by size, e.g.
+	// {"anon": 4, "authenticated": 8} (default: sized from pg_max_conns)
+	PGRolePools map[string]int32 `json:"pg_role_pools,omitempty"`
+
+	// Collation and time zone. The locales apply when the data directory
+	// is created; the time zone on every start.
+	PGLocale    string `json:"pg_locale,omitempty"`     // initdb locale, e.g. "de_DE.UTF-8" (default: the environment's)
+	PGICULocale string `json:"pg_icu_locale,omitempty"` // ICU collation, e.g. "de-DE"; overrides pg_locale's collation
+	PGTimezone  string `json:"pg_timezone,omitempty"`   // e.g. "Europe/Berlin" (default: the environment's)
+
+	// Extensions created on every start if missing, e.g. ["pgvector",
+	// "pg_trgm"]. The server must ship them: the embedded one has the
+	// contrib extensions, but not pgvector or PostGIS.
+	Extensions []string `json:"extensions,omitempty"`
 
 	// JWT settings
 	JWTSecret      string `json:"jwt_secret,omitempty"`
@@ -46,6 +410,89 @@ type Config struct {
 
 	// Email settings (for GoTrue)
 	Email *EmailConfig `json:"email,omitempty"`
+
+	// Phone auth settings (for GoTrue)
+	SMS *SMSConfig `json:"sms,omitempty"`
+
+	// Auth users to create at startup (existing users are left untouched)
+	SeedUsers []SeedUser `json:"seed_users,omitempty"`
+
+	// OAuth providers for signInWithOAuth, keyed by name ("google", "github", ...)
+	ExternalProviders map[string]*ExternalProviderConfig `json:"external_providers,omitempty"`
+
+	// RPC endpoint restrictions
+	RPC *RPCConfig `json:"rpc,omitempty"`
+
+	// Response body shaping defaults
+	Response *ResponseConfig `json:"response,omitempty"`
+
+	// REST API versioning
+	REST *RESTConfig `json:"rest,omitempty"`
+
+	// Child process resource limits
+	Limits *LimitsConfig `json:"limits,omitempty"`
+
+	// Disk and memory watchdog thresholds
+	Watchdog *WatchdogConfig `json:"watchdog,omitempty"`
+
+	// Fault injection (for tests)
+	Chaos *ChaosConfig `json:"chaos,omitempty"`
+
+	// Public status page at /status
+	Status *StatusConfig `json:"status,omitempty"`
+
+	// Per-client request budgets for /rest and /auth/v1
+	RateLimit *APIRateLimitConfig `json:"rate_limit,omitempty"`
+
+	// OpenTelemetry trace export
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+
+	// Headers, CORS, rate limits, and access restrictions by route
+	Rules []RouteRule `json:"rules,omitempty"`
+
+	// Operational alerts to Slack, a webhook, or email
+	Notifications *NotificationsConfig `json:"notifications,omitempty"`
+
+	// Row-level change history
+	History *HistoryConfig `json:"history,omitempty"`
+
+	// Log a line per HTTP request; off by default
+	AccessLog bool `json:"access_log,omitempty"`
+
+	// Record who wrote to which table through the REST API
+	AuditLog *AuditLogConfig `json:"audit_log,omitempty"`
+
+	// Record REST requests with their SQL; off by default
+	RESTRecord *RESTRecordConfig `json:"rest_record,omitempty"`
+
+	// Usage accounting per API key, with optional quotas; off by default
+	Usage *UsageConfig `json:"usage,omitempty"`
+
+	// SQL migrations, applied with `supalite migrate` or at startup
+	Migrations *MigrationsConfig `json:"migrations,omitempty"`
+
+	// Serve pREST's API at /prest (service_role only); off by default
+	PREST bool `json:"prest,omitempty"`
+
+	// Serve API docs generated from the live schema at /docs; off by default
+	Docs bool `json:"docs,omitempty"`
+
+	// GoTrue binary to run instead of finding or downloading one
+	GoTrueBinary string `json:"gotrue_binary,omitempty"`
+
+	// Auth implementation: "gotrue" (default) or "native" (in process, no GoTrue)
+	AuthMode string `json:"auth_mode,omitempty"`
+
+	// MFA, captcha, password, and rate limit settings for GoTrue
+	Auth *AuthConfig `json:"auth,omitempty"`
+
+	// Ephemeral mode (for tests): throwaway data directory, no fsync
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// Deterministic mode (for CI): keys derived from a seed, no downloads, autoconfirm
+	Deterministic     bool   `json:"deterministic,omitempty"`
+	DeterministicSeed string `json:"deterministic_seed,omitempty"`
+	ProjectRef        string `json:"project_ref,omitempty"`
 }
 
 // Load loads configuration from supalite.json (if exists) with fallback to environment variables
@@ -66,6 +513,132 @@ func Load() (*Config, error) {
 	// Apply environment variable fallbacks for any unset values
 	applyEnvFallbacks(cfg)
 
+	// Seed users from env need parsing, so they are handled outside applyEnvFallbacks
+	if len(cfg.SeedUsers) == 0 {
+		if val := getEnv("SUPALITE_SEED_USERS", ""); val != "" {
+			users, err := ParseSeedUsers(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SUPALITE_SEED_USERS: %w", err)
+			}
+			cfg.SeedUsers = users
+		}
+	}
+
+	if cfg.Auth != nil && cfg.Auth.Captcha != nil && cfg.Auth.Captcha.Provider != "" {
+		if !slices.Contains(CaptchaProviders, cfg.Auth.Captcha.Provider) {
+			return nil, fmt.Errorf("unknown captcha provider %q (supported: %s)", cfg.Auth.Captcha.Provider, strings.Join(CaptchaProviders, ", "))
+		}
+	}
+	if cfg.REST != nil {
+		if v := cfg.REST.DefaultVersion; v != 0 && v != 1 && v != 2 {
+			return nil, fmt.Errorf("invalid REST default version %d: use 1 or 2", v)
+		}
+		if _, err := cfg.REST.Sunset(); err != nil {
+			return nil, err
+		}
+		if c := cfg.REST.IdentifierCase; c != "" && !slices.Contains(IdentifierCases, c) {
+			return nil, fmt.Errorf("invalid REST identifier_case %q (use %s)", c, strings.Join(IdentifierCases, ", "))
+		}
+		if cfg.REST.MaxFieldSize < 0 {
+			return nil, fmt.Errorf("invalid REST max_field_size %d: must not be negative", cfg.REST.MaxFieldSize)
+		}
+		for column, limit := range cfg.REST.FieldSizeLimits {
+			if limit < 0 {
+				return nil, fmt.Errorf("invalid REST field size limit %d for %s: must not be negative", limit, column)
+			}
+		}
+		for table, value := range cfg.REST.CacheControl {
+			if value == "" || strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("invalid REST cache_control %q for %s: must be one non-empty line", value, table)
+			}
+		}
+	}
+	if w := cfg.Watchdog; w != nil {
+		if w.IntervalSeconds < 0 || w.WarnFreePercent < 0 || w.WarnFreePercent >= 100 || w.MinFreeMB < 0 || w.WALWarnMB < 0 {
+			return nil, fmt.Errorf("invalid watchdog settings: intervals and sizes must not be negative, and warn_free_percent must be below 100")
+		}
+	}
+	if c := cfg.Chaos; c != nil && (c.DBFailPercent < 0 || c.DBFailPercent > 100 || c.AuthDelayMS < 0) {
+		return nil, fmt.Errorf("invalid chaos settings: db_fail_percent must be between 0 and 100, and auth_delay_ms must not be negative")
+	}
+	if n := cfg.Notifications; n != nil {
+		switch strings.ToLower(n.MinSeverity) {
+		case "", "info", "warning", "critical":
+		default:
+			return nil, fmt.Errorf("invalid notifications min_severity %q (use info, warning, or critical)", n.MinSeverity)
+		}
+		if n.CooldownSeconds < 0 || n.AuthFailureThreshold < 0 || n.AuthFailureWindowSeconds < 0 {
+			return nil, fmt.Errorf("invalid notifications settings: cooldown and auth failure settings must not be negative")
+		}
+		if len(n.Email) > 0 && (cfg.Email == nil || cfg.Email.SMTPHost == "") {
+			return nil, fmt.Errorf("email notifications need an SMTP server (email.smtp_host)")
+		}
+	}
+	if t := cfg.TLS; t.Enabled() {
+		sources := 0
+		if t.CertFile != "" || t.KeyFile != "" {
+			if t.CertFile == "" || t.KeyFile == "" {
+				return nil, fmt.Errorf("tls cert_file and key_file must be set together")
+			}
+			sources++
+		}
+		if t.SelfSigned {
+			sources++
+		}
+		if len(t.ACMEDomains) > 0 {
+			sources++
+		}
+		if sources > 1 {
+			return nil, fmt.Errorf("tls: set only one of cert_file/key_file, self_signed, or acme_domains")
+		}
+		if t.HTTPPort < 0 || t.HTTPPort > 65535 || (t.HTTPPort != 0 && t.HTTPPort == cfg.Port) {
+			return nil, fmt.Errorf("invalid tls http_port %d: must be a free port other than port", t.HTTPPort)
+		}
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"pg_locale", cfg.PGLocale},
+		{"pg_icu_locale", cfg.PGICULocale},
+		{"pg_timezone", cfg.PGTimezone},
+	} {
+		if setting.value != "" && !localeNamePattern.MatchString(setting.value) {
+			return nil, fmt.Errorf("invalid %s %q", setting.name, setting.value)
+		}
+	}
+	if st := cfg.Status; st != nil && st.IntervalSeconds < 0 {
+		return nil, fmt.Errorf("invalid status interval_seconds %d: must not be negative", st.IntervalSeconds)
+	}
+	if l := cfg.RateLimit; l != nil && (l.PerIP < 0 || l.PerKey < 0 || l.WindowSeconds < 0) {
+		return nil, fmt.Errorf("invalid rate_limit: per_ip, per_key, and window_seconds must not be negative")
+	}
+	if t := cfg.Tracing; t != nil && (t.SampleRatio < 0 || t.SampleRatio > 1) {
+		return nil, fmt.Errorf("invalid tracing sample_ratio %g: must be between 0 and 1", t.SampleRatio)
+	}
+	if h := cfg.History; h != nil && h.RetentionDays < 0 {
+		return nil, fmt.Errorf("invalid history retention_days %d: must not be negative", h.RetentionDays)
+	}
+	if a := cfg.AuditLog; a != nil && a.RetentionDays < 0 {
+		return nil, fmt.Errorf("invalid audit_log retention_days %d: must not be negative", a.RetentionDays)
+	}
+	if rr := cfg.RESTRecord; rr != nil && rr.Size < 0 {
+		return nil, fmt.Errorf("invalid rest_record size %d: must not be negative", rr.Size)
+	}
+	if u := cfg.Usage; u != nil {
+		if u.Period != "" && u.Period != "month" && u.Period != "day" {
+			return nil, fmt.Errorf("invalid usage period %q: must be month or day", u.Period)
+		}
+		if u.Soft.negative() || u.Hard.negative() {
+			return nil, fmt.Errorf("invalid usage limits: must not be negative")
+		}
+	}
+	if cfg.SMS != nil && cfg.SMS.Provider != "" && !isSMSProvider(cfg.SMS.Provider) {
+		return nil, fmt.Errorf("unknown SMS provider %q (supported: %s)", cfg.SMS.Provider, strings.Join(SMSProviders, ", "))
+	}
+	for name := range cfg.ExternalProviders {
+		if !isExternalProvider(name) {
+			return nil, fmt.Errorf("unknown external provider %q in supalite.json", name)
+		}
+	}
+
 	// Set defaults for values that are still empty
 	setDefaults(cfg)
 
@@ -87,8 +660,50 @@ func applyEnvFallbacks(cfg *Config) {
 	if cfg.SiteURL == "" {
 		cfg.SiteURL = getEnv("SUPALITE_SITE_URL", "")
 	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = getEnv("SUPALITE_PUBLIC_URL", "")
+	}
+	if !cfg.PREST {
+		cfg.PREST = strings.ToLower(getEnv("SUPALITE_PREST", "")) == "true"
+	}
+	if !cfg.Docs {
+		cfg.Docs = strings.ToLower(getEnv("SUPALITE_DOCS", "")) == "true"
+	}
+	if cfg.GoTrueBinary == "" {
+		cfg.GoTrueBinary = getEnv("SUPALITE_GOTRUE_BINARY", "")
+	}
+	if cfg.AuthMode == "" {
+		cfg.AuthMode = getEnv("SUPALITE_AUTH_MODE", "")
+	}
+
+	// OAuth providers: SUPALITE_EXTERNAL_<PROVIDER>_CLIENT_ID and friends,
+	// for providers supalite.json doesn't configure
+	for _, name := range ExternalProviders {
+		if cfg.ExternalProviders[name] != nil {
+			continue
+		}
+		prefix := "SUPALITE_EXTERNAL_" + strings.ToUpper(name) + "_"
+		provider := &ExternalProviderConfig{
+			ClientID:    getEnv(prefix+"CLIENT_ID", ""),
+			Secret:      getEnv(prefix+"SECRET", ""),
+			RedirectURI: getEnv(prefix+"REDIRECT_URI", ""),
+			URL:         getEnv(prefix+"URL", ""),
+		}
+		if provider.ClientID == "" {
+			continue
+		}
+		// Setting a client ID enables the provider unless disabled explicitly
+		provider.Enabled = strings.ToLower(getEnv(prefix+"ENABLED", "true")) == "true"
+		if cfg.ExternalProviders == nil {
+			cfg.ExternalProviders = map[string]*ExternalProviderConfig{}
+		}
+		cfg.ExternalProviders[name] = provider
+	}
 
 	// PostgreSQL settings
+	if cfg.DatabaseURL == "" {
+		cfg.DatabaseURL = getEnv("SUPALITE_DATABASE_URL", "")
+	}
 	if cfg.PGPort == 0 {
 		cfg.PGPort = uint16(getEnvInt("SUPALITE_PG_PORT", 0))
 	}
@@ -101,6 +716,33 @@ func applyEnvFallbacks(cfg *Config) {
 	if cfg.PGDatabase == "" {
 		cfg.PGDatabase = getEnv("SUPALITE_PG_DATABASE", "")
 	}
+	if cfg.PGVersion == "" {
+		cfg.PGVersion = getEnv("SUPALITE_PG_VERSION", "")
+	}
+	if cfg.PGSocketDir == "" {
+		cfg.PGSocketDir = getEnv("SUPALITE_PG_SOCKET_DIR", "")
+	}
+	if cfg.PGMinConns == 0 {
+		cfg.PGMinConns = int32(getEnvInt("SUPALITE_PG_MIN_CONNS", 0))
+	}
+	if cfg.PGMaxConns == 0 {
+		cfg.PGMaxConns = int32(getEnvInt("SUPALITE_PG_MAX_CONNS", 0))
+	}
+	if cfg.PGRolePools == nil {
+		cfg.PGRolePools = getEnvSizes("SUPALITE_PG_ROLE_POOLS")
+	}
+	if cfg.PGLocale == "" {
+		cfg.PGLocale = getEnv("SUPALITE_PG_LOCALE", "")
+	}
+	if cfg.PGICULocale == "" {
+		cfg.PGICULocale = getEnv("SUPALITE_PG_ICU_LOCALE", "")
+	}
+	if cfg.PGTimezone == "" {
+		cfg.PGTimezone = getEnv("SUPALITE_PG_TIMEZONE", "")
+	}
+	if len(cfg.Extensions) == 0 {
+		cfg.Extensions = getEnvList("SUPALITE_EXTENSIONS")
+	}
 
 	// JWT settings
 	if cfg.JWTSecret == "" {
@@ -113,6 +755,296 @@ func applyEnvFallbacks(cfg *Config) {
 		cfg.ServiceRoleKey = getEnv("SUPALITE_SERVICE_ROLE_KEY", "")
 	}
 
+	// Ephemeral mode
+	if !cfg.Ephemeral {
+		cfg.Ephemeral = strings.ToLower(getEnv("SUPALITE_EPHEMERAL", "")) == "true"
+	}
+
+	// Deterministic mode settings
+	if !cfg.Deterministic {
+		cfg.Deterministic = strings.ToLower(getEnv("SUPALITE_DETERMINISTIC", "")) == "true"
+	}
+	if cfg.DeterministicSeed == "" {
+		cfg.DeterministicSeed = getEnv("SUPALITE_DETERMINISTIC_SEED", "")
+	}
+	if cfg.ProjectRef == "" {
+		cfg.ProjectRef = getEnv("SUPALITE_PROJECT_REF", "")
+	}
+
+	// RPC settings - lists are comma-separated in env vars
+	if cfg.RPC == nil {
+		cfg.RPC = &RPCConfig{}
+	}
+	if len(cfg.RPC.Schemas) == 0 {
+		cfg.RPC.Schemas = getEnvList("SUPALITE_RPC_SCHEMAS")
+	}
+	if len(cfg.RPC.Functions) == 0 {
+		cfg.RPC.Functions = getEnvList("SUPALITE_RPC_FUNCTIONS")
+	}
+	if len(cfg.RPC.ServiceRoleFunctions) == 0 {
+		cfg.RPC.ServiceRoleFunctions = getEnvList("SUPALITE_RPC_SERVICE_ROLE_FUNCTIONS")
+	}
+
+	// Response shaping settings
+	if cfg.Response == nil {
+		cfg.Response = &ResponseConfig{}
+	}
+	if !cfg.Response.OmitNulls {
+		cfg.Response.OmitNulls = strings.ToLower(getEnv("SUPALITE_RESPONSE_OMIT_NULLS", "")) == "true"
+	}
+	if !cfg.Response.CamelCaseKeys {
+		cfg.Response.CamelCaseKeys = strings.ToLower(getEnv("SUPALITE_RESPONSE_CAMEL_CASE_KEYS", "")) == "true"
+	}
+
+	// Auth hardening settings
+	applyAuthEnvFallbacks(cfg)
+
+	// REST API versioning settings
+	if cfg.REST == nil {
+		cfg.REST = &RESTConfig{}
+	}
+	if cfg.REST.DefaultVersion == 0 {
+		cfg.REST.DefaultVersion = getEnvInt("SUPALITE_REST_DEFAULT_VERSION", 0)
+	}
+	if cfg.REST.V1Sunset == "" {
+		cfg.REST.V1Sunset = getEnv("SUPALITE_REST_V1_SUNSET", "")
+	}
+	if cfg.REST.IdentifierCase == "" {
+		cfg.REST.IdentifierCase = getEnv("SUPALITE_REST_IDENTIFIER_CASE", "")
+	}
+	if cfg.REST.MaxFieldSize == 0 {
+		cfg.REST.MaxFieldSize = getEnvInt("SUPALITE_REST_MAX_FIELD_SIZE", 0)
+	}
+	if cfg.REST.FieldSizeLimits == nil {
+		if sizes := getEnvSizes("SUPALITE_REST_FIELD_SIZE_LIMITS"); sizes != nil {
+			cfg.REST.FieldSizeLimits = make(map[string]int, len(sizes))
+			for column, size := range sizes {
+				cfg.REST.FieldSizeLimits[column] = int(size)
+			}
+		}
+	}
+
+	// Child process resource limits
+	if cfg.Limits == nil {
+		cfg.Limits = &LimitsConfig{}
+	}
+	if cfg.Limits.PGMemoryMB == 0 {
+		cfg.Limits.PGMemoryMB = getEnvInt("SUPALITE_PG_MEMORY_MB", 0)
+	}
+	if cfg.Limits.PGCPUs == 0 {
+		cfg.Limits.PGCPUs = getEnvFloat("SUPALITE_PG_CPUS", 0)
+	}
+	if cfg.Limits.PGSharedBuffers == "" {
+		cfg.Limits.PGSharedBuffers = getEnv("SUPALITE_PG_SHARED_BUFFERS", "")
+	}
+	if cfg.Limits.AuthMemoryMB == 0 {
+		cfg.Limits.AuthMemoryMB = getEnvInt("SUPALITE_AUTH_MEMORY_MB", 0)
+	}
+	if cfg.Limits.AuthCPUs == 0 {
+		cfg.Limits.AuthCPUs = getEnvFloat("SUPALITE_AUTH_CPUS", 0)
+	}
+
+	// Watchdog settings
+	if cfg.Watchdog == nil {
+		cfg.Watchdog = &WatchdogConfig{}
+	}
+	if cfg.Watchdog.IntervalSeconds == 0 {
+		cfg.Watchdog.IntervalSeconds = getEnvInt("SUPALITE_WATCHDOG_INTERVAL_SECONDS", 0)
+	}
+	if cfg.Watchdog.WarnFreePercent == 0 {
+		cfg.Watchdog.WarnFreePercent = getEnvFloat("SUPALITE_WATCHDOG_WARN_FREE_PERCENT", 0)
+	}
+	if cfg.Watchdog.MinFreeMB == 0 {
+		cfg.Watchdog.MinFreeMB = getEnvInt("SUPALITE_WATCHDOG_MIN_FREE_MB", 0)
+	}
+	if !cfg.Watchdog.RefuseWrites {
+		cfg.Watchdog.RefuseWrites = strings.ToLower(getEnv("SUPALITE_WATCHDOG_REFUSE_WRITES", "")) == "true"
+	}
+	if cfg.Watchdog.WALWarnMB == 0 {
+		cfg.Watchdog.WALWarnMB = getEnvInt("SUPALITE_WATCHDOG_WAL_WARN_MB", 0)
+	}
+
+	// Chaos settings
+	if cfg.Chaos == nil {
+		cfg.Chaos = &ChaosConfig{}
+	}
+	if !cfg.Chaos.Enabled {
+		cfg.Chaos.Enabled = strings.ToLower(getEnv("SUPALITE_CHAOS", "")) == "true"
+	}
+	if cfg.Chaos.Seed == 0 {
+		cfg.Chaos.Seed = int64(getEnvInt("SUPALITE_CHAOS_SEED", 0))
+	}
+	if cfg.Chaos.DBFailPercent == 0 {
+		cfg.Chaos.DBFailPercent = getEnvFloat("SUPALITE_CHAOS_DB_FAIL_PERCENT", 0)
+	}
+	if cfg.Chaos.AuthDelayMS == 0 {
+		cfg.Chaos.AuthDelayMS = getEnvInt("SUPALITE_CHAOS_AUTH_DELAY_MS", 0)
+	}
+
+	// Notification settings
+	if cfg.Notifications == nil {
+		cfg.Notifications = &NotificationsConfig{}
+	}
+	if cfg.Notifications.SlackWebhookURL == "" {
+		cfg.Notifications.SlackWebhookURL = getEnv("SUPALITE_NOTIFY_SLACK_WEBHOOK_URL", "")
+	}
+	if cfg.Notifications.WebhookURL == "" {
+		cfg.Notifications.WebhookURL = getEnv("SUPALITE_NOTIFY_WEBHOOK_URL", "")
+	}
+	if cfg.Notifications.WebhookSecret == "" {
+		cfg.Notifications.WebhookSecret = getEnv("SUPALITE_NOTIFY_WEBHOOK_SECRET", "")
+	}
+	if len(cfg.Notifications.Email) == 0 {
+		cfg.Notifications.Email = getEnvList("SUPALITE_NOTIFY_EMAIL")
+	}
+	if cfg.Notifications.MinSeverity == "" {
+		cfg.Notifications.MinSeverity = getEnv("SUPALITE_NOTIFY_MIN_SEVERITY", "")
+	}
+	if cfg.Notifications.CooldownSeconds == 0 {
+		cfg.Notifications.CooldownSeconds = getEnvInt("SUPALITE_NOTIFY_COOLDOWN_SECONDS", 0)
+	}
+	if cfg.Notifications.Instance == "" {
+		cfg.Notifications.Instance = getEnv("SUPALITE_NOTIFY_INSTANCE", "")
+	}
+	if cfg.Notifications.AuthFailureThreshold == 0 {
+		cfg.Notifications.AuthFailureThreshold = getEnvInt("SUPALITE_NOTIFY_AUTH_FAILURE_THRESHOLD", 0)
+	}
+	if cfg.Notifications.AuthFailureWindowSeconds == 0 {
+		cfg.Notifications.AuthFailureWindowSeconds = getEnvInt("SUPALITE_NOTIFY_AUTH_FAILURE_WINDOW_SECONDS", 0)
+	}
+
+	// TLS settings
+	if cfg.TLS == nil {
+		cfg.TLS = &TLSConfig{}
+	}
+	if cfg.TLS.CertFile == "" {
+		cfg.TLS.CertFile = getEnv("SUPALITE_TLS_CERT_FILE", "")
+	}
+	if cfg.TLS.KeyFile == "" {
+		cfg.TLS.KeyFile = getEnv("SUPALITE_TLS_KEY_FILE", "")
+	}
+	if !cfg.TLS.SelfSigned {
+		cfg.TLS.SelfSigned = strings.ToLower(getEnv("SUPALITE_TLS_SELF_SIGNED", "")) == "true"
+	}
+	if len(cfg.TLS.ACMEDomains) == 0 {
+		cfg.TLS.ACMEDomains = getEnvList("SUPALITE_TLS_ACME_DOMAINS")
+	}
+	if cfg.TLS.ACMEEmail == "" {
+		cfg.TLS.ACMEEmail = getEnv("SUPALITE_TLS_ACME_EMAIL", "")
+	}
+	if cfg.TLS.ACMEDirectoryURL == "" {
+		cfg.TLS.ACMEDirectoryURL = getEnv("SUPALITE_TLS_ACME_DIRECTORY_URL", "")
+	}
+	if cfg.TLS.HTTPPort == 0 {
+		cfg.TLS.HTTPPort = getEnvInt("SUPALITE_TLS_HTTP_PORT", 0)
+	}
+
+	// Status page settings
+	if cfg.Status == nil {
+		cfg.Status = &StatusConfig{}
+	}
+	if !cfg.Status.Enabled {
+		cfg.Status.Enabled = strings.ToLower(getEnv("SUPALITE_STATUS_ENABLED", "")) == "true"
+	}
+	if cfg.Status.Title == "" {
+		cfg.Status.Title = getEnv("SUPALITE_STATUS_TITLE", "")
+	}
+	if cfg.Status.IntervalSeconds == 0 {
+		cfg.Status.IntervalSeconds = getEnvInt("SUPALITE_STATUS_INTERVAL_SECONDS", 0)
+	}
+
+	// API rate limits
+	if cfg.RateLimit == nil {
+		cfg.RateLimit = &APIRateLimitConfig{}
+	}
+	if cfg.RateLimit.PerIP == 0 {
+		cfg.RateLimit.PerIP = getEnvInt("SUPALITE_RATE_LIMIT_PER_IP", 0)
+	}
+	if cfg.RateLimit.PerKey == 0 {
+		cfg.RateLimit.PerKey = getEnvInt("SUPALITE_RATE_LIMIT_PER_KEY", 0)
+	}
+	if cfg.RateLimit.WindowSeconds == 0 {
+		cfg.RateLimit.WindowSeconds = getEnvInt("SUPALITE_RATE_LIMIT_WINDOW_SECONDS", 0)
+	}
+
+	// Tracing settings
+	if cfg.Tracing == nil {
+		cfg.Tracing = &TracingConfig{}
+	}
+	if cfg.Tracing.Endpoint == "" {
+		cfg.Tracing.Endpoint = getEnv("SUPALITE_TRACING_ENDPOINT", "")
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = getEnv("SUPALITE_TRACING_SERVICE_NAME", "")
+	}
+	if cfg.Tracing.SampleRatio == 0 {
+		cfg.Tracing.SampleRatio = getEnvFloat("SUPALITE_TRACING_SAMPLE_RATIO", 0)
+	}
+
+	// Change history settings
+	if cfg.History == nil {
+		cfg.History = &HistoryConfig{}
+	}
+	if len(cfg.History.Tables) == 0 {
+		cfg.History.Tables = getEnvList("SUPALITE_HISTORY_TABLES")
+	}
+	if cfg.History.RetentionDays == 0 {
+		cfg.History.RetentionDays = getEnvInt("SUPALITE_HISTORY_RETENTION_DAYS", 0)
+	}
+	if !cfg.History.TimeTravel {
+		cfg.History.TimeTravel = strings.ToLower(getEnv("SUPALITE_HISTORY_TIME_TRAVEL", "")) == "true"
+	}
+
+	// Request logging settings
+	if !cfg.AccessLog {
+		cfg.AccessLog = strings.ToLower(getEnv("SUPALITE_ACCESS_LOG", "")) == "true"
+	}
+	if cfg.AuditLog == nil {
+		cfg.AuditLog = &AuditLogConfig{}
+	}
+	if !cfg.AuditLog.Enabled {
+		cfg.AuditLog.Enabled = strings.ToLower(getEnv("SUPALITE_AUDIT_LOG_ENABLED", "")) == "true"
+	}
+	if cfg.AuditLog.RetentionDays == 0 {
+		cfg.AuditLog.RetentionDays = getEnvInt("SUPALITE_AUDIT_LOG_RETENTION_DAYS", 0)
+	}
+
+	// Request recording settings
+	if cfg.RESTRecord == nil {
+		cfg.RESTRecord = &RESTRecordConfig{}
+	}
+	if !cfg.RESTRecord.Enabled {
+		cfg.RESTRecord.Enabled = strings.ToLower(getEnv("SUPALITE_REST_RECORD_ENABLED", "")) == "true"
+	}
+	if cfg.RESTRecord.Size == 0 {
+		cfg.RESTRecord.Size = getEnvInt("SUPALITE_REST_RECORD_SIZE", 0)
+	}
+
+	// Usage accounting settings
+	if cfg.Usage == nil {
+		cfg.Usage = &UsageConfig{}
+	}
+	if !cfg.Usage.Enabled {
+		cfg.Usage.Enabled = strings.ToLower(getEnv("SUPALITE_USAGE_ENABLED", "")) == "true"
+	}
+	if cfg.Usage.Period == "" {
+		cfg.Usage.Period = getEnv("SUPALITE_USAGE_PERIOD", "")
+	}
+	if !cfg.Usage.PerKey {
+		cfg.Usage.PerKey = strings.ToLower(getEnv("SUPALITE_USAGE_PER_KEY", "")) == "true"
+	}
+
+	// Migration settings
+	if cfg.Migrations == nil {
+		cfg.Migrations = &MigrationsConfig{}
+	}
+	if cfg.Migrations.Dir == "" {
+		cfg.Migrations.Dir = getEnv("SUPALITE_MIGRATIONS_DIR", "")
+	}
+	if !cfg.Migrations.AutoApply {
+		cfg.Migrations.AutoApply = strings.ToLower(getEnv("SUPALITE_MIGRATIONS_AUTO_APPLY", "")) == "true"
+	}
+
 	// Email settings - initialize Email config if needed
 	if cfg.Email == nil {
 		cfg.Email = &EmailConfig{}
@@ -156,6 +1088,56 @@ func applyEnvFallbacks(cfg *Config) {
 	if cfg.Email.CapturePort == 0 {
 		cfg.Email.CapturePort = getEnvInt("SUPALITE_CAPTURE_PORT", 0)
 	}
+	if cfg.Email.CaptureSink == "" {
+		cfg.Email.CaptureSink = getEnv("SUPALITE_CAPTURE_SINK", "")
+	}
+	if cfg.Email.CaptureDir == "" {
+		cfg.Email.CaptureDir = getEnv("SUPALITE_CAPTURE_DIR", "")
+	}
+	if cfg.Email.CaptureWebhookURL == "" {
+		cfg.Email.CaptureWebhookURL = getEnv("SUPALITE_CAPTURE_WEBHOOK_URL", "")
+	}
+	if cfg.Email.CaptureWebhookSecret == "" {
+		cfg.Email.CaptureWebhookSecret = getEnv("SUPALITE_CAPTURE_WEBHOOK_SECRET", "")
+	}
+
+	// SMS settings - initialize SMS config if needed
+	if cfg.SMS == nil {
+		cfg.SMS = &SMSConfig{}
+	}
+	if cfg.SMS.Provider == "" {
+		cfg.SMS.Provider = getEnv("SUPALITE_SMS_PROVIDER", "")
+	}
+	if cfg.SMS.TwilioAccountSID == "" {
+		cfg.SMS.TwilioAccountSID = getEnv("SUPALITE_SMS_TWILIO_ACCOUNT_SID", "")
+	}
+	if cfg.SMS.TwilioAuthToken == "" {
+		cfg.SMS.TwilioAuthToken = getEnv("SUPALITE_SMS_TWILIO_AUTH_TOKEN", "")
+	}
+	if cfg.SMS.TwilioMessageServiceSID == "" {
+		cfg.SMS.TwilioMessageServiceSID = getEnv("SUPALITE_SMS_TWILIO_MESSAGE_SERVICE_SID", "")
+	}
+	if cfg.SMS.MessageBirdAccessKey == "" {
+		cfg.SMS.MessageBirdAccessKey = getEnv("SUPALITE_SMS_MESSAGEBIRD_ACCESS_KEY", "")
+	}
+	if cfg.SMS.MessageBirdOriginator == "" {
+		cfg.SMS.MessageBirdOriginator = getEnv("SUPALITE_SMS_MESSAGEBIRD_ORIGINATOR", "")
+	}
+	if cfg.SMS.Template == "" {
+		cfg.SMS.Template = getEnv("SUPALITE_SMS_TEMPLATE", "")
+	}
+	if cfg.SMS.OTPExp == 0 {
+		cfg.SMS.OTPExp = getEnvInt("SUPALITE_SMS_OTP_EXP", 0)
+	}
+	if cfg.SMS.OTPLength == 0 {
+		cfg.SMS.OTPLength = getEnvInt("SUPALITE_SMS_OTP_LENGTH", 0)
+	}
+	if !cfg.SMS.Autoconfirm {
+		cfg.SMS.Autoconfirm = strings.ToLower(getEnv("SUPALITE_SMS_AUTOCONFIRM", "")) == "true"
+	}
+	if !cfg.SMS.CaptureMode {
+		cfg.SMS.CaptureMode = strings.ToLower(getEnv("SUPALITE_SMS_CAPTURE_MODE", "")) == "true"
+	}
 }
 
 // setDefaults sets default values for any empty fields
@@ -174,6 +1156,167 @@ func setDefaults(cfg *Config) {
 	}
 }
 
+// ParseExternalProvider parses a name:client_id:secret[:redirect_uri] entry
+// into an enabled OAuth provider
+func ParseExternalProvider(entry string) (string, *ExternalProviderConfig, error) {
+	parts := strings.SplitN(entry, ":", 4)
+	if len(parts) < 3 || parts[1] == "" || parts[2] == "" {
+		return "", nil, fmt.Errorf("external provider %q must be in the form name:client_id:secret[:redirect_uri]", entry)
+	}
+	name := strings.ToLower(strings.TrimSpace(parts[0]))
+	if !isExternalProvider(name) {
+		return "", nil, fmt.Errorf("unknown external provider %q (supported: %s)", name, strings.Join(ExternalProviders, ", "))
+	}
+	provider := &ExternalProviderConfig{Enabled: true, ClientID: parts[1], Secret: parts[2]}
+	if len(parts) == 4 {
+		provider.RedirectURI = parts[3]
+	}
+	return name, provider, nil
+}
+
+func isSMSProvider(name string) bool {
+	for _, known := range SMSProviders {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+func isExternalProvider(name string) bool {
+	for _, known := range ExternalProviders {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSeedUsers parses a comma-separated list of email:password pairs
+// (e.g. "alice@example.com:secret,bob@example.com:secret") into confirmed seed users
+func ParseSeedUsers(val string) ([]SeedUser, error) {
+	var users []SeedUser
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		user, err := ParseSeedUser(entry)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// ParseSeedUser parses a single email:password pair into a confirmed seed user
+func ParseSeedUser(entry string) (SeedUser, error) {
+	email, password, ok := strings.Cut(entry, ":")
+	email = strings.TrimSpace(email)
+	if !ok || email == "" || password == "" {
+		return SeedUser{}, fmt.Errorf("seed user %q must be in the form email:password", entry)
+	}
+	return SeedUser{Email: email, Password: password, Confirmed: true}, nil
+}
+
+// applyAuthEnvFallbacks fills unset auth hardening settings from
+// SUPALITE_AUTH_* environment variables
+func applyAuthEnvFallbacks(cfg *Config) {
+	if cfg.Auth == nil {
+		cfg.Auth = &AuthConfig{}
+	}
+
+	// MFA
+	if cfg.Auth.MFA == nil {
+		cfg.Auth.MFA = &MFAConfig{}
+	}
+	mfa := cfg.Auth.MFA
+	if mfa.TOTPEnrollEnabled == nil {
+		mfa.TOTPEnrollEnabled = getEnvBool("SUPALITE_AUTH_MFA_TOTP_ENROLL_ENABLED")
+	}
+	if mfa.TOTPVerifyEnabled == nil {
+		mfa.TOTPVerifyEnabled = getEnvBool("SUPALITE_AUTH_MFA_TOTP_VERIFY_ENABLED")
+	}
+	if !mfa.PhoneEnrollEnabled {
+		mfa.PhoneEnrollEnabled = strings.ToLower(getEnv("SUPALITE_AUTH_MFA_PHONE_ENROLL_ENABLED", "")) == "true"
+	}
+	if !mfa.PhoneVerifyEnabled {
+		mfa.PhoneVerifyEnabled = strings.ToLower(getEnv("SUPALITE_AUTH_MFA_PHONE_VERIFY_ENABLED", "")) == "true"
+	}
+	if mfa.MaxEnrolledFactors == 0 {
+		mfa.MaxEnrolledFactors = getEnvInt("SUPALITE_AUTH_MFA_MAX_ENROLLED_FACTORS", 0)
+	}
+	if mfa.MaxVerifiedFactors == 0 {
+		mfa.MaxVerifiedFactors = getEnvInt("SUPALITE_AUTH_MFA_MAX_VERIFIED_FACTORS", 0)
+	}
+	if mfa.ChallengeExpirySeconds == 0 {
+		mfa.ChallengeExpirySeconds = getEnvInt("SUPALITE_AUTH_MFA_CHALLENGE_EXPIRY_SECONDS", 0)
+	}
+
+	// Captcha
+	if cfg.Auth.Captcha == nil {
+		cfg.Auth.Captcha = &CaptchaConfig{}
+	}
+	if cfg.Auth.Captcha.Provider == "" {
+		cfg.Auth.Captcha.Provider = getEnv("SUPALITE_AUTH_CAPTCHA_PROVIDER", "")
+	}
+	if cfg.Auth.Captcha.Secret == "" {
+		cfg.Auth.Captcha.Secret = getEnv("SUPALITE_AUTH_CAPTCHA_SECRET", "")
+	}
+
+	// Password requirements
+	if cfg.Auth.Password == nil {
+		cfg.Auth.Password = &PasswordConfig{}
+	}
+	if cfg.Auth.Password.MinLength == 0 {
+		cfg.Auth.Password.MinLength = getEnvInt("SUPALITE_AUTH_PASSWORD_MIN_LENGTH", 0)
+	}
+	if cfg.Auth.Password.RequiredCharacters == "" {
+		cfg.Auth.Password.RequiredCharacters = getEnv("SUPALITE_AUTH_PASSWORD_REQUIRED_CHARACTERS", "")
+	}
+
+	// Rate limits
+	if cfg.Auth.RateLimits == nil {
+		cfg.Auth.RateLimits = &RateLimitConfig{}
+	}
+	limits := cfg.Auth.RateLimits
+	for _, limit := range []struct {
+		value *float64
+		env   string
+	}{
+		{&limits.EmailSent, "SUPALITE_AUTH_RATE_LIMIT_EMAIL_SENT"},
+		{&limits.SMSSent, "SUPALITE_AUTH_RATE_LIMIT_SMS_SENT"},
+		{&limits.Verify, "SUPALITE_AUTH_RATE_LIMIT_VERIFY"},
+		{&limits.TokenRefresh, "SUPALITE_AUTH_RATE_LIMIT_TOKEN_REFRESH"},
+		{&limits.OTP, "SUPALITE_AUTH_RATE_LIMIT_OTP"},
+		{&limits.AnonymousUsers, "SUPALITE_AUTH_RATE_LIMIT_ANONYMOUS_USERS"},
+		{&limits.SSO, "SUPALITE_AUTH_RATE_LIMIT_SSO"},
+		{&limits.MFA, "SUPALITE_AUTH_RATE_LIMIT_MFA"},
+	} {
+		if *limit.value == 0 {
+			*limit.value = getEnvFloat(limit.env, 0)
+		}
+	}
+	if limits.Header == "" {
+		limits.Header = getEnv("SUPALITE_AUTH_RATE_LIMIT_HEADER", "")
+	}
+}
+
+// getEnvBool gets an environment variable as a bool, returning nil if it
+// is unset or not "true" or "false"
+func getEnvBool(key string) *bool {
+	switch strings.ToLower(getEnv(key, "")) {
+	case "true":
+		v := true
+		return &v
+	case "false":
+		v := false
+		return &v
+	}
+	return nil
+}
+
 // getEnv gets an environment variable or returns the default value
 func getEnv(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
@@ -182,6 +1325,37 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+// getEnvList gets a comma-separated environment variable as a list,
+// skipping empty entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvSizes gets a comma-separated list of name=size pairs, such as
+// "anon=4,authenticated=8", skipping malformed entries. It returns nil if
+// the variable is unset.
+func getEnvSizes(key string) map[string]int32 {
+	items := getEnvList(key)
+	if len(items) == 0 {
+		return nil
+	}
+	sizes := make(map[string]int32, len(items))
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		var size int32
+		if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d", &size); ok && err == nil {
+			sizes[strings.TrimSpace(name)] = size
+		}
+	}
+	return sizes
+}
+
 // getEnvInt gets an environment variable as an integer or returns the default value
 func getEnvInt(key string, defaultVal int) int {
 	if val := os.Getenv(key); val != "" {
@@ -192,3 +1366,14 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// getEnvFloat gets an environment variable as a float or returns the default value
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		var floatVal float64
+		if _, err := fmt.Sscanf(val, "%g", &floatVal); err == nil {
+			return floatVal
+		}
+	}
+	return defaultVal
+}
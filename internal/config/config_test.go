@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestEmailConfig_CaptureMode(t *testing.T) {
@@ -53,3 +54,524 @@ func TestEmailConfig_CaptureMode_EnvFallback(t *testing.T) {
 		t.Errorf("CapturePort = %d, want 3025", cfg.Email.CapturePort)
 	}
 }
+
+func TestSeedUsers_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_SEED_USERS", "alice@example.com:secret1, bob@example.com:p:ss")
+	defer os.Unsetenv("SUPALITE_SEED_USERS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if len(cfg.SeedUsers) != 2 {
+		t.Fatalf("len(SeedUsers) = %d, want 2", len(cfg.SeedUsers))
+	}
+	if cfg.SeedUsers[0].Email != "alice@example.com" || cfg.SeedUsers[0].Password != "secret1" {
+		t.Errorf("SeedUsers[0] = %+v", cfg.SeedUsers[0])
+	}
+	if cfg.SeedUsers[1].Password != "p:ss" {
+		t.Errorf("SeedUsers[1].Password = %q, want %q", cfg.SeedUsers[1].Password, "p:ss")
+	}
+	if !cfg.SeedUsers[0].Confirmed {
+		t.Error("seed users from env should be confirmed")
+	}
+}
+
+func TestSeedUsers_Invalid(t *testing.T) {
+	if _, err := ParseSeedUsers("alice@example.com"); err == nil {
+		t.Error("expected error for entry without password")
+	}
+}
+
+func TestDeterministic_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_DETERMINISTIC", "true")
+	os.Setenv("SUPALITE_DETERMINISTIC_SEED", "ci")
+	defer os.Unsetenv("SUPALITE_DETERMINISTIC")
+	defer os.Unsetenv("SUPALITE_DETERMINISTIC_SEED")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if !cfg.Deterministic {
+		t.Error("Deterministic should be true from env var")
+	}
+	if cfg.DeterministicSeed != "ci" {
+		t.Errorf("DeterministicSeed = %q, want %q", cfg.DeterministicSeed, "ci")
+	}
+}
+
+func TestRPC_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_RPC_SCHEMAS", "api, public")
+	os.Setenv("SUPALITE_RPC_SERVICE_ROLE_FUNCTIONS", "purge_data,")
+	defer os.Unsetenv("SUPALITE_RPC_SCHEMAS")
+	defer os.Unsetenv("SUPALITE_RPC_SERVICE_ROLE_FUNCTIONS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if len(cfg.RPC.Schemas) != 2 || cfg.RPC.Schemas[0] != "api" || cfg.RPC.Schemas[1] != "public" {
+		t.Errorf("RPC.Schemas = %v, want [api public]", cfg.RPC.Schemas)
+	}
+	if len(cfg.RPC.Functions) != 0 {
+		t.Errorf("RPC.Functions = %v, want empty", cfg.RPC.Functions)
+	}
+	if len(cfg.RPC.ServiceRoleFunctions) != 1 || cfg.RPC.ServiceRoleFunctions[0] != "purge_data" {
+		t.Errorf("RPC.ServiceRoleFunctions = %v, want [purge_data]", cfg.RPC.ServiceRoleFunctions)
+	}
+}
+
+func TestPGPool_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_PG_MIN_CONNS", "2")
+	os.Setenv("SUPALITE_PG_MAX_CONNS", "16")
+	defer os.Unsetenv("SUPALITE_PG_MIN_CONNS")
+	defer os.Unsetenv("SUPALITE_PG_MAX_CONNS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if cfg.PGMinConns != 2 || cfg.PGMaxConns != 16 {
+		t.Errorf("PGMinConns, PGMaxConns = %d, %d, want 2, 16", cfg.PGMinConns, cfg.PGMaxConns)
+	}
+}
+
+func TestPGRolePools_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_PG_ROLE_POOLS", "anon=4, authenticated=8,broken")
+	defer os.Unsetenv("SUPALITE_PG_ROLE_POOLS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if len(cfg.PGRolePools) != 2 || cfg.PGRolePools["anon"] != 4 || cfg.PGRolePools["authenticated"] != 8 {
+		t.Errorf("PGRolePools = %v, want anon=4, authenticated=8", cfg.PGRolePools)
+	}
+}
+
+func TestResponse_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_RESPONSE_OMIT_NULLS", "true")
+	defer os.Unsetenv("SUPALITE_RESPONSE_OMIT_NULLS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if !cfg.Response.OmitNulls {
+		t.Error("Response.OmitNulls = false, want true")
+	}
+	if cfg.Response.CamelCaseKeys {
+		t.Error("Response.CamelCaseKeys = true, want false")
+	}
+}
+
+func TestExternalProviders_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_EXTERNAL_GITHUB_CLIENT_ID", "gh-id")
+	os.Setenv("SUPALITE_EXTERNAL_GITHUB_SECRET", "gh-secret")
+	os.Setenv("SUPALITE_EXTERNAL_GOOGLE_CLIENT_ID", "g-id")
+	os.Setenv("SUPALITE_EXTERNAL_GOOGLE_ENABLED", "false")
+	defer os.Unsetenv("SUPALITE_EXTERNAL_GITHUB_CLIENT_ID")
+	defer os.Unsetenv("SUPALITE_EXTERNAL_GITHUB_SECRET")
+	defer os.Unsetenv("SUPALITE_EXTERNAL_GOOGLE_CLIENT_ID")
+	defer os.Unsetenv("SUPALITE_EXTERNAL_GOOGLE_ENABLED")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	github := cfg.ExternalProviders["github"]
+	if github == nil || !github.Enabled || github.ClientID != "gh-id" || github.Secret != "gh-secret" {
+		t.Errorf("ExternalProviders[github] = %+v", github)
+	}
+	if google := cfg.ExternalProviders["google"]; google == nil || google.Enabled {
+		t.Errorf("ExternalProviders[google] = %+v, want disabled", google)
+	}
+	if len(cfg.ExternalProviders) != 2 {
+		t.Errorf("len(ExternalProviders) = %d, want 2", len(cfg.ExternalProviders))
+	}
+}
+
+func TestParseExternalProvider(t *testing.T) {
+	name, provider, err := ParseExternalProvider("GitHub:id:secret:https://example.com/auth/v1/callback")
+	if err != nil {
+		t.Fatalf("ParseExternalProvider() failed: %v", err)
+	}
+	if name != "github" || !provider.Enabled || provider.ClientID != "id" || provider.Secret != "secret" ||
+		provider.RedirectURI != "https://example.com/auth/v1/callback" {
+		t.Errorf("ParseExternalProvider() = %q, %+v", name, provider)
+	}
+
+	if _, _, err := ParseExternalProvider("github:id"); err == nil {
+		t.Error("expected error for entry without secret")
+	}
+	if _, _, err := ParseExternalProvider("myspace:id:secret"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestSMS_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_SMS_PROVIDER", "twilio")
+	os.Setenv("SUPALITE_SMS_TWILIO_ACCOUNT_SID", "AC123")
+	os.Setenv("SUPALITE_SMS_OTP_LENGTH", "8")
+	os.Setenv("SUPALITE_SMS_CAPTURE_MODE", "true")
+	defer os.Unsetenv("SUPALITE_SMS_PROVIDER")
+	defer os.Unsetenv("SUPALITE_SMS_TWILIO_ACCOUNT_SID")
+	defer os.Unsetenv("SUPALITE_SMS_OTP_LENGTH")
+	defer os.Unsetenv("SUPALITE_SMS_CAPTURE_MODE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.SMS.Provider != "twilio" || cfg.SMS.TwilioAccountSID != "AC123" || cfg.SMS.OTPLength != 8 || !cfg.SMS.CaptureMode {
+		t.Errorf("SMS = %+v", cfg.SMS)
+	}
+
+	os.Setenv("SUPALITE_SMS_PROVIDER", "carrier-pigeon")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for unknown SMS provider")
+	}
+}
+
+func TestREST_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_REST_DEFAULT_VERSION", "2")
+	os.Setenv("SUPALITE_REST_V1_SUNSET", "2027-01-01")
+	defer os.Unsetenv("SUPALITE_REST_DEFAULT_VERSION")
+	defer os.Unsetenv("SUPALITE_REST_V1_SUNSET")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.REST.DefaultVersion != 2 {
+		t.Errorf("REST.DefaultVersion = %d, want 2", cfg.REST.DefaultVersion)
+	}
+	if sunset, err := cfg.REST.Sunset(); err != nil || !sunset.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("REST.Sunset() = %v, %v", sunset, err)
+	}
+
+	os.Setenv("SUPALITE_REST_DEFAULT_VERSION", "3")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for unknown REST version")
+	}
+	os.Setenv("SUPALITE_REST_DEFAULT_VERSION", "1")
+	os.Setenv("SUPALITE_REST_V1_SUNSET", "next year")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for invalid sunset date")
+	}
+}
+
+func TestWatchdog_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_WATCHDOG_MIN_FREE_MB", "512")
+	os.Setenv("SUPALITE_WATCHDOG_REFUSE_WRITES", "true")
+	os.Setenv("SUPALITE_WATCHDOG_WARN_FREE_PERCENT", "20")
+	defer os.Unsetenv("SUPALITE_WATCHDOG_MIN_FREE_MB")
+	defer os.Unsetenv("SUPALITE_WATCHDOG_REFUSE_WRITES")
+	defer os.Unsetenv("SUPALITE_WATCHDOG_WARN_FREE_PERCENT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Watchdog.MinFreeMB != 512 || !cfg.Watchdog.RefuseWrites || cfg.Watchdog.WarnFreePercent != 20 {
+		t.Errorf("Watchdog = %+v, want min_free_mb 512, refuse_writes, warn_free_percent 20", cfg.Watchdog)
+	}
+
+	os.Setenv("SUPALITE_WATCHDOG_WARN_FREE_PERCENT", "100")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for warn_free_percent of 100")
+	}
+}
+
+func TestChaos_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_CHAOS", "true")
+	os.Setenv("SUPALITE_CHAOS_SEED", "42")
+	os.Setenv("SUPALITE_CHAOS_DB_FAIL_PERCENT", "12.5")
+	defer os.Unsetenv("SUPALITE_CHAOS")
+	defer os.Unsetenv("SUPALITE_CHAOS_SEED")
+	defer os.Unsetenv("SUPALITE_CHAOS_DB_FAIL_PERCENT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.Chaos.Enabled || cfg.Chaos.Seed != 42 || cfg.Chaos.DBFailPercent != 12.5 {
+		t.Errorf("Chaos = %+v, want enabled, seed 42, db_fail_percent 12.5", cfg.Chaos)
+	}
+
+	os.Setenv("SUPALITE_CHAOS_DB_FAIL_PERCENT", "150")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for db_fail_percent of 150")
+	}
+}
+
+func TestNotifications_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_NOTIFY_SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/X")
+	os.Setenv("SUPALITE_NOTIFY_EMAIL", "ops@example.com, oncall@example.com")
+	os.Setenv("SUPALITE_NOTIFY_MIN_SEVERITY", "warning")
+	defer os.Unsetenv("SUPALITE_NOTIFY_SLACK_WEBHOOK_URL")
+	defer os.Unsetenv("SUPALITE_NOTIFY_EMAIL")
+	defer os.Unsetenv("SUPALITE_NOTIFY_MIN_SEVERITY")
+
+	// Email alerts need an SMTP server
+	if _, err := Load(); err == nil {
+		t.Error("expected error for email notifications without smtp_host")
+	}
+
+	os.Setenv("SUPALITE_SMTP_HOST", "smtp.example.com")
+	defer os.Unsetenv("SUPALITE_SMTP_HOST")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	n := cfg.Notifications
+	if n.SlackWebhookURL != "https://hooks.slack.com/services/T/B/X" || n.MinSeverity != "warning" || len(n.Email) != 2 || n.Email[1] != "oncall@example.com" {
+		t.Errorf("Notifications = %+v", n)
+	}
+
+	os.Setenv("SUPALITE_NOTIFY_MIN_SEVERITY", "loud")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for min_severity loud")
+	}
+}
+
+func TestTLS_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_TLS_ACME_DOMAINS", "api.example.com, www.example.com")
+	os.Setenv("SUPALITE_TLS_HTTP_PORT", "80")
+	defer os.Unsetenv("SUPALITE_TLS_ACME_DOMAINS")
+	defer os.Unsetenv("SUPALITE_TLS_HTTP_PORT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.TLS.Enabled() || len(cfg.TLS.ACMEDomains) != 2 || cfg.TLS.HTTPPort != 80 {
+		t.Errorf("TLS = %+v", cfg.TLS)
+	}
+
+	// Only one certificate source
+	os.Setenv("SUPALITE_TLS_SELF_SIGNED", "true")
+	defer os.Unsetenv("SUPALITE_TLS_SELF_SIGNED")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for self_signed with acme_domains")
+	}
+	os.Unsetenv("SUPALITE_TLS_ACME_DOMAINS")
+	os.Setenv("SUPALITE_TLS_KEY_FILE", "tls.key")
+	defer os.Unsetenv("SUPALITE_TLS_KEY_FILE")
+	os.Unsetenv("SUPALITE_TLS_SELF_SIGNED")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for key_file without cert_file")
+	}
+}
+
+func TestHistory_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_HISTORY_TABLES", "todos, app.orders")
+	os.Setenv("SUPALITE_HISTORY_RETENTION_DAYS", "30")
+	os.Setenv("SUPALITE_HISTORY_TIME_TRAVEL", "true")
+	defer os.Unsetenv("SUPALITE_HISTORY_TABLES")
+	defer os.Unsetenv("SUPALITE_HISTORY_RETENTION_DAYS")
+	defer os.Unsetenv("SUPALITE_HISTORY_TIME_TRAVEL")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	h := cfg.History
+	if len(h.Tables) != 2 || h.Tables[1] != "app.orders" || h.RetentionDays != 30 || !h.TimeTravel {
+		t.Errorf("History = %+v", h)
+	}
+
+	os.Setenv("SUPALITE_HISTORY_RETENTION_DAYS", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for negative retention_days")
+	}
+}
+
+func TestPGLocale_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_PG_LOCALE", "de_DE.UTF-8")
+	os.Setenv("SUPALITE_PG_ICU_LOCALE", "de-DE")
+	os.Setenv("SUPALITE_PG_TIMEZONE", "Europe/Berlin")
+	defer os.Unsetenv("SUPALITE_PG_LOCALE")
+	defer os.Unsetenv("SUPALITE_PG_ICU_LOCALE")
+	defer os.Unsetenv("SUPALITE_PG_TIMEZONE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.PGLocale != "de_DE.UTF-8" || cfg.PGICULocale != "de-DE" || cfg.PGTimezone != "Europe/Berlin" {
+		t.Errorf("locale = %q, %q, %q", cfg.PGLocale, cfg.PGICULocale, cfg.PGTimezone)
+	}
+
+	os.Setenv("SUPALITE_PG_ICU_LOCALE", "de' ; DROP")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for an invalid ICU locale")
+	}
+}
+
+func TestStatus_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_STATUS_ENABLED", "true")
+	os.Setenv("SUPALITE_STATUS_TITLE", "Acme API")
+	defer os.Unsetenv("SUPALITE_STATUS_ENABLED")
+	defer os.Unsetenv("SUPALITE_STATUS_TITLE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if st := cfg.Status; !st.Enabled || st.Title != "Acme API" || st.IntervalSeconds != 0 {
+		t.Errorf("Status = %+v", st)
+	}
+}
+
+func TestRateLimit_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_RATE_LIMIT_PER_IP", "600")
+	os.Setenv("SUPALITE_RATE_LIMIT_PER_KEY", "300")
+	defer os.Unsetenv("SUPALITE_RATE_LIMIT_PER_IP")
+	defer os.Unsetenv("SUPALITE_RATE_LIMIT_PER_KEY")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if l := cfg.RateLimit; l.PerIP != 600 || l.PerKey != 300 || l.WindowSeconds != 0 {
+		t.Errorf("RateLimit = %+v", l)
+	}
+
+	os.Setenv("SUPALITE_RATE_LIMIT_PER_KEY", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for negative per_key")
+	}
+}
+
+func TestAuditLog_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_ACCESS_LOG", "true")
+	os.Setenv("SUPALITE_AUDIT_LOG_ENABLED", "true")
+	os.Setenv("SUPALITE_AUDIT_LOG_RETENTION_DAYS", "90")
+	defer os.Unsetenv("SUPALITE_ACCESS_LOG")
+	defer os.Unsetenv("SUPALITE_AUDIT_LOG_ENABLED")
+	defer os.Unsetenv("SUPALITE_AUDIT_LOG_RETENTION_DAYS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.AccessLog || !cfg.AuditLog.Enabled || cfg.AuditLog.RetentionDays != 90 {
+		t.Errorf("AccessLog = %v, AuditLog = %+v", cfg.AccessLog, cfg.AuditLog)
+	}
+
+	os.Setenv("SUPALITE_AUDIT_LOG_RETENTION_DAYS", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for negative retention_days")
+	}
+}
+
+func TestMigrations_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_MIGRATIONS_DIR", "supabase/migrations")
+	os.Setenv("SUPALITE_MIGRATIONS_AUTO_APPLY", "true")
+	defer os.Unsetenv("SUPALITE_MIGRATIONS_DIR")
+	defer os.Unsetenv("SUPALITE_MIGRATIONS_AUTO_APPLY")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Migrations.Dir != "supabase/migrations" || !cfg.Migrations.AutoApply {
+		t.Errorf("Migrations = %+v", cfg.Migrations)
+	}
+}
+
+func TestRESTRecord_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_REST_RECORD_ENABLED", "true")
+	os.Setenv("SUPALITE_REST_RECORD_SIZE", "50")
+	defer os.Unsetenv("SUPALITE_REST_RECORD_ENABLED")
+	defer os.Unsetenv("SUPALITE_REST_RECORD_SIZE")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.RESTRecord.Enabled || cfg.RESTRecord.Size != 50 {
+		t.Errorf("RESTRecord = %+v", cfg.RESTRecord)
+	}
+}
+
+func TestUsage_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_USAGE_ENABLED", "true")
+	os.Setenv("SUPALITE_USAGE_PERIOD", "day")
+	os.Setenv("SUPALITE_USAGE_PER_KEY", "true")
+	defer os.Unsetenv("SUPALITE_USAGE_ENABLED")
+	defer os.Unsetenv("SUPALITE_USAGE_PERIOD")
+	defer os.Unsetenv("SUPALITE_USAGE_PER_KEY")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !cfg.Usage.Enabled || cfg.Usage.Period != "day" || !cfg.Usage.PerKey {
+		t.Errorf("Usage = %+v", cfg.Usage)
+	}
+}
+
+func TestTracing_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_TRACING_ENDPOINT", "http://localhost:4318")
+	os.Setenv("SUPALITE_TRACING_SAMPLE_RATIO", "0.25")
+	defer os.Unsetenv("SUPALITE_TRACING_ENDPOINT")
+	defer os.Unsetenv("SUPALITE_TRACING_SAMPLE_RATIO")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if tr := cfg.Tracing; tr.Endpoint != "http://localhost:4318" || tr.SampleRatio != 0.25 || tr.ServiceName != "" {
+		t.Errorf("Tracing = %+v", tr)
+	}
+
+	os.Setenv("SUPALITE_TRACING_SAMPLE_RATIO", "2")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for a sample_ratio above 1")
+	}
+}
+
+func TestAuth_EnvFallback(t *testing.T) {
+	os.Setenv("SUPALITE_AUTH_MFA_TOTP_ENROLL_ENABLED", "false")
+	os.Setenv("SUPALITE_AUTH_CAPTCHA_PROVIDER", "turnstile")
+	os.Setenv("SUPALITE_AUTH_CAPTCHA_SECRET", "secret")
+	os.Setenv("SUPALITE_AUTH_PASSWORD_MIN_LENGTH", "12")
+	os.Setenv("SUPALITE_AUTH_RATE_LIMIT_EMAIL_SENT", "30")
+	defer os.Unsetenv("SUPALITE_AUTH_MFA_TOTP_ENROLL_ENABLED")
+	defer os.Unsetenv("SUPALITE_AUTH_CAPTCHA_PROVIDER")
+	defer os.Unsetenv("SUPALITE_AUTH_CAPTCHA_SECRET")
+	defer os.Unsetenv("SUPALITE_AUTH_PASSWORD_MIN_LENGTH")
+	defer os.Unsetenv("SUPALITE_AUTH_RATE_LIMIT_EMAIL_SENT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if enroll := cfg.Auth.MFA.TOTPEnrollEnabled; enroll == nil || *enroll {
+		t.Errorf("Auth.MFA.TOTPEnrollEnabled = %v, want false", enroll)
+	}
+	if cfg.Auth.MFA.TOTPVerifyEnabled != nil {
+		t.Error("Auth.MFA.TOTPVerifyEnabled should stay unset")
+	}
+	if cfg.Auth.Captcha.Provider != "turnstile" || cfg.Auth.Captcha.Secret != "secret" {
+		t.Errorf("Auth.Captcha = %+v", cfg.Auth.Captcha)
+	}
+	if cfg.Auth.Password.MinLength != 12 || cfg.Auth.RateLimits.EmailSent != 30 {
+		t.Errorf("Auth.Password = %+v, Auth.RateLimits = %+v", cfg.Auth.Password, cfg.Auth.RateLimits)
+	}
+
+	os.Setenv("SUPALITE_AUTH_CAPTCHA_PROVIDER", "recaptcha")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for unknown captcha provider")
+	}
+}
@@ -1,17 +1,22 @@
 package dashboard
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	"io/fs"
+	"mime"
 	"net/http"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/pg"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -53,16 +58,21 @@ type statusResponse struct {
 	Timestamp time.Time `json:"timestamp"`
 	Uptime    string    `json:"uptime"`
 	Version   string    `json:"version"`
+
+	// Collation, encoding, and time zone of the database; omitted when
+	// the database can't be reached
+	Database *pg.LocaleInfo `json:"database,omitempty"`
 }
 
 // tableInfo represents information about a database table.
 //
 // Used in the /api/tables response to list available tables.
 type tableInfo struct {
-	Name      string `json:"name"`
-	Schema    string `json:"schema"`
-	Rows      int64  `json:"rows,omitempty"`
-	SizeBytes string `json:"size_bytes,omitempty"`
+	Name          string `json:"name"`
+	Schema        string `json:"schema"`
+	Rows          int64  `json:"rows,omitempty"`
+	RowsEstimated bool   `json:"rows_estimated,omitempty"` // Rows is a planner estimate, not COUNT(*)
+	SizeBytes     string `json:"size_bytes,omitempty"`
 }
 
 // tablesResponse represents the response for /api/tables endpoint.
@@ -72,21 +82,6 @@ type tablesResponse struct {
 	Tables []tableInfo `json:"tables"`
 }
 
-// columnInfo represents information about a table column.
-type columnInfo struct {
-	Name     string `json:"name"`
-	Type     string `json:"type"`
-	Nullable bool   `json:"nullable"`
-	Key      string `json:"key,omitempty"`
-}
-
-// tableSchemaResponse represents the response for /api/tables/{name}/schema endpoint.
-type tableSchemaResponse struct {
-	TableName string       `json:"table_name"`
-	Schema    string       `json:"schema"`
-	Columns   []columnInfo `json:"columns"`
-}
-
 // handleLogin processes admin login requests.
 //
 // POST /api/login
@@ -127,13 +122,13 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Connect to database
 	ctx := r.Context()
-	conn, err := s.pgConnector.Connect(ctx)
+	conn, err := s.pgConnector.Acquire(ctx)
 	if err != nil {
 		log.Error("dashboard login: database connection failed", "error", err)
 		http.Error(w, "database connection failed", http.StatusInternalServerError)
 		return
 	}
-	defer conn.Close(ctx)
+	defer conn.Release()
 
 	// Query user from admin.users table
 	var userID string
@@ -202,13 +197,13 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 
 	// Connect to database
 	ctx := r.Context()
-	conn, err := s.pgConnector.Connect(ctx)
+	conn, err := s.pgConnector.Acquire(ctx)
 	if err != nil {
 		log.Error("dashboard me: database connection failed", "error", err)
 		http.Error(w, "database connection failed", http.StatusInternalServerError)
 		return
 	}
-	defer conn.Close(ctx)
+	defer conn.Release()
 
 	// Query user from admin.users table
 	var userID string
@@ -246,7 +241,15 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 //     "status": "healthy",
 //     "timestamp": "2026-01-29T12:00:00Z",
 //     "uptime": "2h30m45s",
-//     "version": "dev"
+//     "version": "dev",
+//     "database": {
+//       "encoding": "UTF8",
+//       "provider": "icu",
+//       "collate": "C",
+//       "ctype": "C",
+//       "icu_locale": "de-DE",
+//       "timezone": "Europe/Berlin"
+//     }
 //   }
 //
 // Returns 401 if not authenticated.
@@ -261,6 +264,18 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		Version:   "dev", // Would be injected from build vars
 	}
 
+	if conn, err := s.pgConnector.Acquire(r.Context()); err != nil {
+		log.Warn("dashboard status: database connection failed", "error", err)
+	} else {
+		locale, err := pg.ReadLocale(r.Context(), conn)
+		conn.Release()
+		if err != nil {
+			log.Warn("dashboard status: failed to read locale", "error", err)
+		} else {
+			response.Database = &locale
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
@@ -273,7 +288,9 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 // Requires valid JWT token in Authorization header.
 //
 // Returns a list of tables with metadata including row counts
-// and sizes where available.
+// and sizes where available. Row counts are planner estimates unless
+// the exact=true query parameter is given, which runs COUNT(*) on
+// every table.
 //
 // Response (200 OK):
 //   {
@@ -282,32 +299,45 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 //         "name": "users",
 //         "schema": "public",
 //         "rows": 42,
-//         "size_bytes": "8192"
+//         "rows_estimated": true,
+//         "size_bytes": "8192 bytes"
 //       }
 //     ]
 //   }
 //
 // Returns 401 if not authenticated or 500 for server errors.
 func (s *Server) handleListTables(w http.ResponseWriter, r *http.Request) {
+	exact := r.URL.Query().Get("exact") == "true"
+
 	// Connect to database
 	ctx := r.Context()
-	conn, err := s.pgConnector.Connect(ctx)
+	conn, err := s.pgConnector.Acquire(ctx)
 	if err != nil {
 		log.Error("dashboard tables: database connection failed", "error", err)
 		http.Error(w, "database connection failed", http.StatusInternalServerError)
 		return
 	}
-	defer conn.Close(ctx)
+	defer conn.Release()
 
-	// Query all tables in public schema
+	// List tables with estimated row counts and sizes in one catalog query.
+	// reltuples is -1 until a table is first vacuumed or analyzed, so fall
+	// back to the statistics collector's live tuple count.
 	query := `
 		SELECT
-			table_name,
-			table_schema
-		FROM information_schema.tables
-		WHERE table_schema IN ('public', 'admin')
-		AND table_type = 'BASE TABLE'
-		ORDER BY table_schema, table_name
+			c.relname,
+			n.nspname,
+			CASE
+				WHEN c.reltuples >= 0 THEN c.reltuples::bigint
+				ELSE coalesce(st.n_live_tup, 0)
+			END,
+			pg_size_pretty(pg_total_relation_size(c.oid))
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_user_tables st ON st.relid = c.oid
+		WHERE n.nspname IN ('public', 'admin')
+		AND c.relkind IN ('r', 'p')
+		AND NOT c.relispartition
+		ORDER BY n.nspname, c.relname
 	`
 
 	rows, err := conn.Query(ctx, query)
@@ -316,53 +346,37 @@ func (s *Server) handleListTables(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "database query failed", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	// Collect table names
-	var tables []tableInfo
-	var tableNames []struct {
-		Name   string
-		Schema string
-	}
 
+	tables := []tableInfo{}
 	for rows.Next() {
-		var t struct {
-			Name   string
-			Schema string
-		}
-		if err := rows.Scan(&t.Name, &t.Schema); err != nil {
+		var t tableInfo
+		var size sql.NullString
+		if err := rows.Scan(&t.Name, &t.Schema, &t.Rows, &size); err != nil {
 			log.Error("dashboard tables: row scan failed", "error", err)
 			continue
 		}
-		tableNames = append(tableNames, t)
+		t.SizeBytes = size.String
+		t.RowsEstimated = true
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error("dashboard tables: query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
 	}
 
-	// Get row counts and sizes for each table
-	for _, t := range tableNames {
-		tableInfo := tableInfo{
-			Name:   t.Name,
-			Schema: t.Schema,
-		}
-
-		// Get row count
-		var rowCount sql.NullInt64
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s.%s", t.Schema, t.Name)
-		err = conn.QueryRow(ctx, countQuery).Scan(&rowCount)
-		if err == nil && rowCount.Valid {
-			tableInfo.Rows = rowCount.Int64
+	// Exact counts scan every table, so they are only run on request
+	if exact {
+		for i := range tables {
+			t := &tables[i]
+			countQuery := "SELECT COUNT(*) FROM " + pgx.Identifier{t.Schema, t.Name}.Sanitize()
+			if err := conn.QueryRow(ctx, countQuery).Scan(&t.Rows); err != nil {
+				log.Error("dashboard tables: row count failed", "table", t.Schema+"."+t.Name, "error", err)
+				continue
+			}
+			t.RowsEstimated = false
 		}
-
-		// Get table size (optional, may fail due to permissions)
-		var size sql.NullString
-		sizeQuery := `
-			SELECT pg_size_pretty(pg_total_relation_size(quote_ident($1) || '.' || quote_ident($2)))
-		`
-		err = conn.QueryRow(ctx, sizeQuery, t.Schema, t.Name).Scan(&size)
-		if err == nil && size.Valid {
-			tableInfo.SizeBytes = size.String
-		}
-
-		tables = append(tables, tableInfo)
 	}
 
 	response := tablesResponse{
@@ -374,13 +388,46 @@ func (s *Server) handleListTables(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// staticTypes covers dashboard asset types missing from Go's built-in MIME
+// table, which mime.TypeByExtension falls back to when the system has no
+// mime.types file.
+var staticTypes = map[string]string{
+	".map":         "application/json",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".ico":         "image/x-icon",
+	".webmanifest": "application/manifest+json",
+	".txt":         "text/plain; charset=utf-8",
+}
+
+// hashedAssetPattern matches the content-hashed files Vite writes to
+// dist/assets, such as assets/index-B3x9_kQa.js.
+var hashedAssetPattern = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8,}\.[A-Za-z0-9.]+$`)
+
+// staticContentType returns the Content-Type for a dashboard file, or ""
+// to let http.ServeContent sniff it.
+func staticContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return staticTypes[ext]
+}
+
 // handleStatic serves static files for the dashboard web UI.
 //
 // GET /*
 //
-// Serves the embedded React dashboard files using http.FileServer.
+// Serves the embedded React dashboard files with http.ServeContent, so
+// range requests and conditional requests (If-None-Match) work.
 // For requests to the root path, serves index.html to support client-side routing.
 //
+// Content-hashed files under assets/ never change, so they are marked
+// immutable. Everything else, notably index.html, must be revalidated so a
+// new build is picked up.
+//
 // The dashboard is embedded in the binary using Go's embed.FS directive,
 // which packages the entire dashboard/dist directory at compile time.
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
@@ -404,136 +451,19 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine content type
-	contentType := "text/html; charset=utf-8"
-	if strings.HasSuffix(requestPath, ".js") {
-		contentType = "application/javascript; charset=utf-8"
-	} else if strings.HasSuffix(requestPath, ".css") {
-		contentType = "text/css; charset=utf-8"
-	}
-
-	w.Header().Set("Content-Type", contentType)
-	w.Write(data)
-}
-
-// handleGetTableSchema returns the schema for a specific table.
-//
-// GET /api/tables/{name}/schema
-//
-// Requires valid JWT token in Authorization header.
-//
-// Returns detailed information about the table's columns including:
-//   - Column name
-//   - Data type
-//   - Nullable status
-//   - Key information (PRIMARY KEY, FOREIGN KEY, etc.)
-//
-// Response (200 OK):
-//   {
-//     "table_name": "users",
-//     "schema": "public",
-//     "columns": [
-//       {
-//         "name": "id",
-//         "type": "uuid",
-//         "nullable": false,
-//         "key": "PRIMARY KEY"
-//       }
-//     ]
-//   }
-//
-// Returns 401 if not authenticated, 404 if table not found,
-// or 500 for server errors.
-func (s *Server) handleGetTableSchema(w http.ResponseWriter, r *http.Request) {
-	// Extract table name from URL path
-	// URL format: /api/tables/{tableName}/schema
-	tableName := chi.URLParam(r, "tableName")
-	if tableName == "" {
-		http.Error(w, "table name is required", http.StatusBadRequest)
-		return
-	}
-
-	// Connect to database
-	ctx := r.Context()
-	conn, err := s.pgConnector.Connect(ctx)
-	if err != nil {
-		log.Error("dashboard table schema: database connection failed", "error", err)
-		http.Error(w, "database connection failed", http.StatusInternalServerError)
-		return
+	if contentType := staticContentType(requestPath); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
 	}
-	defer conn.Close(ctx)
-
-	// Query column information from information_schema
-	query := `
-		SELECT
-			column_name,
-			data_type,
-			is_nullable,
-			column_default
-		FROM information_schema.columns
-		WHERE table_name = $1
-		AND table_schema IN ('public', 'admin', 'auth', 'storage')
-		ORDER BY ordinal_position
-	`
-
-	rows, err := conn.Query(ctx, query, tableName)
-	if err != nil {
-		log.Error("dashboard table schema: query failed", "error", err)
-		http.Error(w, "database query failed", http.StatusInternalServerError)
-		return
+	if hashedAssetPattern.MatchString(requestPath) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
 	}
-	defer rows.Close()
 
-	var columns []columnInfo
-	var schemaName string
+	// Embedded files have no modification time, so validate with a
+	// content hash instead
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
 
-	for rows.Next() {
-		var col columnInfo
-		var nullable string
-		var defaultValue sql.NullString
-
-		if err := rows.Scan(&col.Name, &col.Type, &nullable, &defaultValue); err != nil {
-			log.Error("dashboard table schema: row scan failed", "error", err)
-			continue
-		}
-
-		col.Nullable = (nullable == "YES")
-
-		// Set key information (simplified - would need additional queries for full key info)
-		if col.Name == "id" {
-			col.Key = "PRIMARY KEY"
-		}
-
-		columns = append(columns, col)
-	}
-
-	// Get schema name by checking which schema has this table
-	schemaQuery := `
-		SELECT table_schema
-		FROM information_schema.tables
-		WHERE table_name = $1
-		AND table_schema IN ('public', 'admin', 'auth', 'storage')
-		LIMIT 1
-	`
-	err = conn.QueryRow(ctx, schemaQuery, tableName).Scan(&schemaName)
-	if err != nil {
-		log.Error("dashboard table schema: schema lookup failed", "error", err)
-		schemaName = "public" // fallback
-	}
-
-	// Check if we found any columns
-	if len(columns) == 0 {
-		http.Error(w, "table not found", http.StatusNotFound)
-		return
-	}
-
-	response := tableSchemaResponse{
-		TableName: tableName,
-		Schema:    schemaName,
-		Columns:   columns,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	http.ServeContent(w, r, requestPath, time.Time{}, bytes.NewReader(data))
 }
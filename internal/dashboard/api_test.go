@@ -0,0 +1,83 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHandleStatic(t *testing.T) {
+	s := &Server{embedFS: fstest.MapFS{
+		"index.html":                   {Data: []byte("<!doctype html><title>dashboard</title>")},
+		"assets/index-B3x9_kQa.js":     {Data: []byte("console.log('hi')")},
+		"assets/index-B3x9_kQa.js.map": {Data: []byte(`{"version":3}`)},
+		"assets/logo-Cw2fS0aP.svg":     {Data: []byte("<svg></svg>")},
+		"assets/inter-D9kW1xZ2.woff2":  {Data: []byte("wOF2")},
+		"favicon.ico":                  {Data: []byte{0, 0, 1, 0}},
+	}}
+
+	tests := []struct {
+		path        string
+		wantType    string
+		wantCaching string
+	}{
+		{"/", "text/html; charset=utf-8", "no-cache"},
+		{"/tables", "text/html; charset=utf-8", "no-cache"},
+		{"/assets/index-B3x9_kQa.js", "text/javascript; charset=utf-8", "public, max-age=31536000, immutable"},
+		{"/assets/index-B3x9_kQa.js.map", "application/json", "public, max-age=31536000, immutable"},
+		{"/assets/logo-Cw2fS0aP.svg", "image/svg+xml", "public, max-age=31536000, immutable"},
+		{"/assets/inter-D9kW1xZ2.woff2", "font/woff2", "public, max-age=31536000, immutable"},
+		{"/favicon.ico", "image/", "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleStatic(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); len(got) < len(tt.wantType) || got[:len(tt.wantType)] != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCaching {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCaching)
+			}
+		})
+	}
+
+	t.Run("missing asset", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.handleStatic(rec, httptest.NewRequest(http.MethodGet, "/assets/missing-AAAAAAAA.js", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("conditional and range requests", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.handleStatic(rec, httptest.NewRequest(http.MethodGet, "/assets/index-B3x9_kQa.js", nil))
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("no ETag set")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/assets/index-B3x9_kQa.js", nil)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		s.handleStatic(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match status = %d, want 304", rec.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/assets/index-B3x9_kQa.js", nil)
+		req.Header.Set("Range", "bytes=0-6")
+		rec = httptest.NewRecorder()
+		s.handleStatic(rec, req)
+		if rec.Code != http.StatusPartialContent || rec.Body.String() != "console" {
+			t.Errorf("Range status = %d, body %q; want 206 \"console\"", rec.Code, rec.Body.String())
+		}
+	})
+}
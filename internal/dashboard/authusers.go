@@ -0,0 +1,277 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/log"
+)
+
+// defaultBanDuration bans a user for good, as far as anyone can tell (the
+// Supabase dashboard uses the same).
+const defaultBanDuration = "876000h"
+
+// banRequest represents the JSON body for POST /api/auth/users/{id}/ban.
+type banRequest struct {
+	Duration string `json:"duration,omitempty"` // A Go duration, e.g. "24h" (default: 876000h)
+}
+
+// handleListAuthUsers lists the application's auth users.
+//
+// GET /api/auth/users[?page=1][&per_page=50]
+//
+// Requires valid JWT token in Authorization header.
+//
+// Relays GoTrue's GET /admin/users, with its query parameters and response:
+//
+//	{
+//	  "users": [{"id": "uuid", "email": "user@example.com", "banned_until": null, ...}],
+//	  "aud": "authenticated"
+//	}
+//
+// Returns 501 if auth isn't served by GoTrue, or GoTrue's error otherwise.
+func (s *Server) handleListAuthUsers(w http.ResponseWriter, r *http.Request) {
+	s.callAuthAdmin(w, r, http.MethodGet, "/admin/users?"+r.URL.RawQuery, nil)
+}
+
+// handleCreateAuthUser creates an auth user.
+//
+// POST /api/auth/users
+//
+// Requires valid JWT token in Authorization header.
+//
+// Relays GoTrue's POST /admin/users. The body is GoTrue's too; without
+// "email_confirm", the user must confirm their email before signing in.
+//
+// Request body:
+//
+//	{
+//	  "email": "user@example.com",
+//	  "password": "secret",
+//	  "email_confirm": true,
+//	  "user_metadata": {"name": "User"}
+//	}
+//
+// Response (200 OK): the user.
+//
+// Returns 400 for invalid input, 501 if auth isn't served by GoTrue, or
+// GoTrue's error otherwise, e.g. 422 if the email is taken.
+func (s *Server) handleCreateAuthUser(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if s.callAuthAdmin(w, r, http.MethodPost, "/admin/users", body) {
+		adminEmail, _ := r.Context().Value("user_email").(string)
+		log.Info("dashboard created auth user", "admin", adminEmail, "email", body["email"], "phone", body["phone"])
+	}
+}
+
+// handleDeleteAuthUser deletes an auth user.
+//
+// DELETE /api/auth/users/{id}
+//
+// Requires valid JWT token in Authorization header.
+//
+// Returns 200 on success, 400 for an invalid ID, 501 if auth isn't served
+// by GoTrue, or GoTrue's error otherwise, e.g. 404 for an unknown user.
+func (s *Server) handleDeleteAuthUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := authUserID(w, r)
+	if !ok {
+		return
+	}
+	if s.callAuthAdmin(w, r, http.MethodDelete, "/admin/users/"+id, nil) {
+		adminEmail, _ := r.Context().Value("user_email").(string)
+		log.Info("dashboard deleted auth user", "admin", adminEmail, "user_id", id)
+	}
+}
+
+// handleBanAuthUser bans (POST) or unbans (DELETE) an auth user. A banned
+// user can't sign in or refresh their session.
+//
+// POST   /api/auth/users/{id}/ban
+// DELETE /api/auth/users/{id}/ban
+//
+// Requires valid JWT token in Authorization header.
+//
+// Request body (POST, optional):
+//
+//	{"duration": "24h"}
+//
+// Response (200 OK): the user, with "banned_until" set or cleared.
+//
+// Returns 400 for an invalid ID or duration, 501 if auth isn't served by
+// GoTrue, or GoTrue's error otherwise.
+func (s *Server) handleBanAuthUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	duration := "none"
+	if r.Method == http.MethodPost {
+		var req banRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		duration = req.Duration
+		if duration == "" {
+			duration = defaultBanDuration
+		}
+		if d, err := time.ParseDuration(duration); err != nil || d <= 0 {
+			http.Error(w, "duration must be a positive duration, e.g. 24h", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if s.callAuthAdmin(w, r, http.MethodPut, "/admin/users/"+id, map[string]interface{}{"ban_duration": duration}) {
+		adminEmail, _ := r.Context().Value("user_email").(string)
+		log.Info("dashboard changed auth user ban", "admin", adminEmail, "user_id", id, "ban_duration", duration)
+	}
+}
+
+// handleConfirmAuthUser confirms an auth user's email address, so they can
+// sign in without following the confirmation link.
+//
+// POST /api/auth/users/{id}/confirm
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK): the user, with "email_confirmed_at" set.
+//
+// Returns 400 for an invalid ID, 501 if auth isn't served by GoTrue, or
+// GoTrue's error otherwise.
+func (s *Server) handleConfirmAuthUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := authUserID(w, r)
+	if !ok {
+		return
+	}
+	if s.callAuthAdmin(w, r, http.MethodPut, "/admin/users/"+id, map[string]interface{}{"email_confirm": true}) {
+		adminEmail, _ := r.Context().Value("user_email").(string)
+		log.Info("dashboard confirmed auth user", "admin", adminEmail, "user_id", id)
+	}
+}
+
+// handleRecoverAuthUser sends an auth user the password reset email, as if
+// they had asked for it.
+//
+// POST /api/auth/users/{id}/recover
+//
+// Requires valid JWT token in Authorization header.
+//
+// Relays GoTrue's POST /recover for the user's email address. With mail
+// capture on, the email shows up there.
+//
+// Returns 200 when the email was sent, 400 for an invalid ID or a user
+// without an email address, 404 if the user does not exist, 501 if auth
+// isn't served by GoTrue, or GoTrue's error otherwise, e.g. 429 when the
+// user was sent one moments ago.
+func (s *Server) handleRecoverAuthUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := authUserID(w, r)
+	if !ok {
+		return
+	}
+	if s.authAdmin == nil {
+		http.Error(w, authAdminUnavailable, http.StatusNotImplemented)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard auth users: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	var email *string
+	err = conn.QueryRow(ctx, "SELECT email FROM auth.users WHERE id = $1", id).Scan(&email)
+	conn.Release()
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("dashboard auth users: user query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	if email == nil || *email == "" {
+		http.Error(w, "user has no email address", http.StatusBadRequest)
+		return
+	}
+
+	if s.callAuthAdmin(w, r, http.MethodPost, "/recover", map[string]interface{}{"email": *email}) {
+		adminEmail, _ := r.Context().Value("user_email").(string)
+		log.Info("dashboard sent password reset", "admin", adminEmail, "user_id", id, "email", *email)
+	}
+}
+
+// authAdminUnavailable explains why auth users can't be managed.
+const authAdminUnavailable = "auth user management needs GoTrue, which isn't serving /auth/v1"
+
+// authUserID reads and checks the user ID in a request path. It answers
+// the request itself if the ID isn't a UUID.
+func authUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return "", false
+	}
+	return id.String(), true
+}
+
+// callAuthAdmin sends a request to GoTrue's API, with body as JSON, and
+// relays its response. It reports whether GoTrue succeeded.
+func (s *Server) callAuthAdmin(w http.ResponseWriter, r *http.Request, method, target string, body interface{}) bool {
+	if s.authAdmin == nil {
+		http.Error(w, authAdminUnavailable, http.StatusNotImplemented)
+		return false
+	}
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return false
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), method, target, reader)
+	if err != nil {
+		log.Error("dashboard auth users: building request failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	req.RemoteAddr = r.RemoteAddr
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.authAdmin.ServeHTTP(rec, req)
+	return rec.status < 300
+}
+
+// statusRecorder remembers the status of a response it passes on.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets the proxy flush through the recorder.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
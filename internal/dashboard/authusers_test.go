@@ -0,0 +1,77 @@
+package dashboard
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAuthUsers(t *testing.T) {
+	type call struct{ method, uri, body string }
+	var calls []call
+	gotrue := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls = append(calls, call{r.Method, r.URL.RequestURI(), string(body)})
+		if strings.HasSuffix(r.URL.Path, "/00000000-0000-0000-0000-000000000000") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"error_code":"user_not_found","msg":"User not found"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	s := &Server{authAdmin: gotrue}
+	router := chi.NewRouter()
+	router.Get("/api/auth/users", s.handleListAuthUsers)
+	router.Post("/api/auth/users", s.handleCreateAuthUser)
+	router.Delete("/api/auth/users/{id}", s.handleDeleteAuthUser)
+	router.Post("/api/auth/users/{id}/ban", s.handleBanAuthUser)
+	router.Delete("/api/auth/users/{id}/ban", s.handleBanAuthUser)
+	router.Post("/api/auth/users/{id}/confirm", s.handleConfirmAuthUser)
+
+	const id = "8d3b2f6a-2c4e-4a7b-9f1e-6b5c4d3a2e10"
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               *call
+	}{
+		{"GET", "/api/auth/users?page=2&per_page=10", "", 200, &call{"GET", "/admin/users?page=2&per_page=10", ""}},
+		{"POST", "/api/auth/users", `{"email":"a@example.com","email_confirm":true}`, 200,
+			&call{"POST", "/admin/users", `{"email":"a@example.com","email_confirm":true}`}},
+		{"POST", "/api/auth/users", `not json`, 400, nil},
+		{"DELETE", "/api/auth/users/" + id, "", 200, &call{"DELETE", "/admin/users/" + id, ""}},
+		{"DELETE", "/api/auth/users/..%2Fsettings", "", 400, nil},
+		{"POST", "/api/auth/users/" + id + "/ban", "", 200, &call{"PUT", "/admin/users/" + id, `{"ban_duration":"876000h"}`}},
+		{"POST", "/api/auth/users/" + id + "/ban", `{"duration":"24h"}`, 200, &call{"PUT", "/admin/users/" + id, `{"ban_duration":"24h"}`}},
+		{"POST", "/api/auth/users/" + id + "/ban", `{"duration":"forever"}`, 400, nil},
+		{"DELETE", "/api/auth/users/" + id + "/ban", "", 200, &call{"PUT", "/admin/users/" + id, `{"ban_duration":"none"}`}},
+		{"POST", "/api/auth/users/" + id + "/confirm", "", 200, &call{"PUT", "/admin/users/" + id, `{"email_confirm":true}`}},
+		{"POST", "/api/auth/users/00000000-0000-0000-0000-000000000000/confirm", "", 404,
+			&call{"PUT", "/admin/users/00000000-0000-0000-0000-000000000000", `{"email_confirm":true}`}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, rec.Code, rec.Body, tt.status)
+		}
+		switch {
+		case tt.want == nil && len(calls) > 0:
+			t.Errorf("%s %s called GoTrue: %+v", tt.method, tt.path, calls)
+		case tt.want != nil && (len(calls) != 1 || calls[0] != *tt.want):
+			t.Errorf("%s %s called GoTrue with %+v, want %+v", tt.method, tt.path, calls, *tt.want)
+		}
+	}
+
+	// Without GoTrue, users can't be managed
+	rec := httptest.NewRecorder()
+	(&Server{}).handleListAuthUsers(rec, httptest.NewRequest("GET", "/api/auth/users", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("without GoTrue: status %d, want 501", rec.Code)
+	}
+}
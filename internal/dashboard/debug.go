@@ -0,0 +1,146 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// debugJWTRequest represents the JSON body for /api/debug/jwt.
+type debugJWTRequest struct {
+	Token string `json:"token"`
+}
+
+// debugJWTResponse describes a decoded token and how the REST layer would
+// treat it.
+type debugJWTResponse struct {
+	Valid             bool                   `json:"valid"`
+	VerificationError string                 `json:"verification_error,omitempty"`
+	Algorithm         string                 `json:"algorithm"`
+	Header            map[string]interface{} `json:"header"`
+	Claims            map[string]interface{} `json:"claims"`
+	Role              string                 `json:"role"`
+	Subject           string                 `json:"subject,omitempty"`
+	IssuedAt          *time.Time             `json:"issued_at,omitempty"`
+	ExpiresAt         *time.Time             `json:"expires_at,omitempty"`
+	Expired           bool                   `json:"expired"`
+	ExpiresIn         string                 `json:"expires_in,omitempty"`
+	PostgresRole      string                 `json:"postgres_role"`
+	BypassesRLS       bool                   `json:"bypasses_rls"`
+	Settings          []rls.Setting          `json:"settings"`
+}
+
+// handleDebugJWT decodes a pasted token and reports how it would be treated.
+//
+// POST /api/debug/jwt
+//
+// Requires valid JWT token in Authorization header.
+//
+// Decodes the token without trusting it, verifies it against the active
+// project keys, and reports the role, claims, expiry, and the Postgres role
+// and request settings (GUCs) RLS policies would see for it.
+//
+// Request body:
+//   {
+//     "token": "eyJhbGciOiJFUzI1NiIsInR5cCI6IkpXVCJ9..."
+//   }
+//
+// Response (200 OK):
+//   {
+//     "valid": true,
+//     "algorithm": "ES256",
+//     "header": {"alg": "ES256", "typ": "JWT"},
+//     "claims": {"role": "authenticated", "sub": "uuid", ...},
+//     "role": "authenticated",
+//     "subject": "uuid",
+//     "expires_at": "2026-01-29T13:00:00Z",
+//     "expired": false,
+//     "expires_in": "59m12s",
+//     "postgres_role": "authenticated",
+//     "bypasses_rls": false,
+//     "settings": [
+//       {"name": "request.jwt.claims", "value": "{...}"},
+//       {"name": "request.jwt.claim.sub", "value": "uuid"}
+//     ]
+//   }
+//
+// Returns 400 if the token is missing or malformed, or 501 if token
+// inspection is not configured.
+func (s *Server) handleDebugJWT(w http.ResponseWriter, r *http.Request) {
+	if s.tokenInspector == nil {
+		http.Error(w, "token inspection is not available", http.StatusNotImplemented)
+		return
+	}
+
+	var req debugJWTRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Accept tokens pasted together with their header prefix
+	token := strings.TrimSpace(req.Token)
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	info, err := s.tokenInspector.InspectToken(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	settings, err := rls.Settings(info.Claims)
+	if err != nil {
+		log.Error("dashboard debug jwt: failed to build settings", "error", err)
+		http.Error(w, "failed to build request settings", http.StatusInternalServerError)
+		return
+	}
+
+	postgresRole := rls.RoleForClaims(info.Claims)
+	role, _ := info.Claims["role"].(string)
+	subject, _ := info.Claims["sub"].(string)
+
+	response := debugJWTResponse{
+		Valid:             info.Valid,
+		VerificationError: info.VerificationError,
+		Algorithm:         info.Algorithm,
+		Header:            info.Header,
+		Claims:            info.Claims,
+		Role:              role,
+		Subject:           subject,
+		IssuedAt:          claimTime(info.Claims, "iat"),
+		ExpiresAt:         claimTime(info.Claims, "exp"),
+		PostgresRole:      postgresRole,
+		BypassesRLS:       postgresRole == rls.RoleServiceRole,
+		Settings:          append([]rls.Setting{{Name: "role", Value: postgresRole}}, settings...),
+	}
+
+	if response.ExpiresAt != nil {
+		remaining := time.Until(*response.ExpiresAt)
+		response.Expired = remaining <= 0
+		if !response.Expired {
+			response.ExpiresIn = remaining.Round(time.Second).String()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// claimTime converts a NumericDate claim (seconds since epoch) to a time.
+func claimTime(claims map[string]interface{}, name string) *time.Time {
+	seconds, ok := claims[name].(float64)
+	if !ok {
+		return nil
+	}
+	t := time.Unix(int64(seconds), 0).UTC()
+	return &t
+}
@@ -0,0 +1,143 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/history"
+	"github.com/markb/supalite/internal/log"
+)
+
+// historyTablesResponse represents the response for /api/history.
+type historyTablesResponse struct {
+	Tables []history.Table `json:"tables"`
+}
+
+// historyResponse represents the response for /api/history/{table}.
+type historyResponse struct {
+	Versions []history.Version `json:"versions"`
+}
+
+// handleListHistoryTables lists the tables whose changes are tracked.
+//
+// GET /api/history
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK):
+//
+//	{"tables": [{"schema": "public", "name": "todos", "versions": 12, "oldest": "...", "newest": "..."}]}
+func (s *Server) handleListHistoryTables(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard history: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	tables, err := history.Tracked(ctx, conn.Conn())
+	if err != nil {
+		log.Error("dashboard history: listing tracked tables failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	if tables == nil {
+		tables = []history.Table{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historyTablesResponse{Tables: tables})
+}
+
+// handleGetHistory returns the change history of a table, newest first.
+//
+// GET /api/history/{table}[?<primary key column>=<value>...][&limit=100][&before=<id>]
+//
+// Requires valid JWT token in Authorization header.
+//
+// Primary key parameters select one row's history, e.g. ?id=42.
+//
+// Returns 400 if the key doesn't name the primary key columns, 404 if the
+// table doesn't exist, or 500 for server errors.
+func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := history.ParseQuery(chi.URLParam(r, "table"), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard history: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	versions, err := history.Versions(ctx, conn.Conn(), q)
+	switch {
+	case errors.Is(err, history.ErrTableNotFound):
+		http.Error(w, "table not found", http.StatusNotFound)
+		return
+	case errors.Is(err, history.ErrInvalidKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error("dashboard history: query failed", "table", q.Table, "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []history.Version{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historyResponse{Versions: versions})
+}
+
+// handleSetHistoryTracking starts (PUT) or stops (DELETE) tracking the
+// changes to a table.
+//
+// PUT    /api/history/{table}
+// DELETE /api/history/{table}
+//
+// Requires valid JWT token in Authorization header.
+//
+// Stopping keeps the history recorded so far. Returns 200 with the
+// table's name, 404 if the table doesn't exist, or 500 for server errors.
+func (s *Server) handleSetHistoryTracking(w http.ResponseWriter, r *http.Request) {
+	table := chi.URLParam(r, "table")
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard history: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	setTracking := history.Enable
+	if r.Method == http.MethodDelete {
+		setTracking = history.Disable
+	}
+	name, err := setTracking(ctx, conn.Conn(), table)
+	if errors.Is(err, history.ErrTableNotFound) {
+		http.Error(w, "table not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("dashboard history: changing tracking failed", "table", table, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Info("dashboard changed history tracking", "table", name, "tracked", r.Method == http.MethodPut)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"table": name, "tracked": r.Method == http.MethodPut})
+}
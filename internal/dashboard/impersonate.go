@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/log"
+)
+
+// handleImpersonate mints a short-lived API token for an existing auth user.
+//
+// POST /api/auth/impersonate
+//
+// Requires valid JWT token in Authorization header.
+//
+// Lets developers test RLS policies "as user X" without knowing the user's
+// password. The returned token is signed with the project keys, so it can be
+// used directly as the Authorization bearer token against /rest/v1.
+//
+// Request body:
+//   {
+//     "email": "user@example.com",
+//     "expires_in": 3600
+//   }
+//
+// Response (200 OK):
+//   {
+//     "access_token": "eyJhbGciOiJFUzI1NiIsInR5cCI6IkpXVCJ9...",
+//     "token_type": "bearer",
+//     "expires_in": 3600,
+//     "expires_at": "2026-01-29T13:00:00Z",
+//     "user_id": "uuid",
+//     "email": "user@example.com",
+//     "role": "authenticated"
+//   }
+//
+// Returns 400 for invalid input, 404 if the user does not exist, 403 if
+// the user is banned, 501 if token signing is not configured, or 500 for server errors.
+func (s *Server) handleImpersonate(w http.ResponseWriter, r *http.Request) {
+	if s.tokenSigner == nil {
+		http.Error(w, "impersonation is not available", http.StatusNotImplemented)
+		return
+	}
+
+	var req auth.ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" && req.Email == "" {
+		http.Error(w, "user_id or email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Connect to database
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard impersonate: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	result, err := auth.Impersonate(ctx, conn.Conn(), s.tokenSigner, req)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, auth.ErrUserBanned) {
+			http.Error(w, "user is banned", http.StatusForbidden)
+			return
+		}
+		log.Error("dashboard impersonate: token generation failed", "error", err)
+		http.Error(w, "token generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	adminEmail, _ := r.Context().Value("user_email").(string)
+	log.Warn("dashboard impersonation token minted", "admin", adminEmail, "user_id", result.UserID, "email", result.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,86 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+// defaultLogLimit is how many entries handleLogs returns without a limit.
+const defaultLogLimit = 200
+
+// logsResponse is the response body for GET /api/logs.
+type logsResponse struct {
+	Entries []log.Entry `json:"entries"`
+}
+
+// handleLogs returns recent log entries, newest last.
+//
+// GET /api/logs?component=gotrue&level=warn&limit=200
+//
+// Requires valid JWT token in Authorization header.
+//
+// All parameters are optional. component keeps entries from one component
+// (gotrue for the auth server's output), level keeps entries at or above a
+// level (debug, info, warn, error), and limit caps the number of entries
+// returned, keeping the newest.
+//
+// Response (200 OK):
+//   {
+//     "entries": [
+//       {
+//         "time": "2026-01-29T12:00:00Z",
+//         "level": "info",
+//         "component": "gotrue",
+//         "message": "request completed",
+//         "fields": {"method": "POST", "path": "/token", "status": "200"}
+//       }
+//     ]
+//   }
+//
+// Returns 400 for an unknown level or invalid limit, or 401 if not
+// authenticated.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	minLevel := log.LevelDebug
+	if name := query.Get("level"); name != "" {
+		level, ok := log.ParseLevel(name)
+		if !ok {
+			http.Error(w, "level must be debug, info, warn, or error", http.StatusBadRequest)
+			return
+		}
+		minLevel = level
+	}
+
+	limit := defaultLogLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	component := query.Get("component")
+	entries := make([]log.Entry, 0)
+	for _, entry := range log.Recent() {
+		if component != "" && entry.Component != component {
+			continue
+		}
+		if level, _ := log.ParseLevel(entry.Level); level < minLevel {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(logsResponse{Entries: entries})
+}
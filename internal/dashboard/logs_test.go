@@ -0,0 +1,53 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+func TestHandleLogs(t *testing.T) {
+	log.Log(log.LevelInfo, "request completed", "component", "gotrue", "path", "/token")
+	log.Log(log.LevelWarn, "smtp not configured", "component", "gotrue")
+	log.Log(log.LevelWarn, "slow query", "component", "rest")
+
+	s := &Server{}
+	get := func(query string) (int, []log.Entry) {
+		rec := httptest.NewRecorder()
+		s.handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/logs?"+query, nil))
+		var resp logsResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec.Code, resp.Entries
+	}
+
+	_, entries := get("component=gotrue")
+	if len(entries) != 2 || entries[0].Message != "request completed" || entries[1].Message != "smtp not configured" {
+		t.Fatalf("component=gotrue returned %+v", entries)
+	}
+	if entries[0].Fields["path"] != "/token" {
+		t.Errorf("fields = %v, want path=/token", entries[0].Fields)
+	}
+
+	_, entries = get("component=gotrue&level=warn")
+	if len(entries) != 1 || entries[0].Message != "smtp not configured" {
+		t.Errorf("level=warn returned %+v", entries)
+	}
+
+	_, entries = get("level=warn&limit=1")
+	if len(entries) != 1 || entries[0].Message != "slow query" {
+		t.Errorf("limit=1 returned %+v, want only the newest", entries)
+	}
+
+	for _, query := range []string{"level=loud", "limit=0", "limit=x"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markb/supalite/internal/recorder"
+)
+
+// restRequestsResponse represents the response for /api/rest/requests.
+type restRequestsResponse struct {
+	Enabled  bool               `json:"enabled"`
+	Requests []recorder.Request `json:"requests"`
+}
+
+// handleListRESTRequests returns the recorded REST requests, newest first,
+// with the SQL each one ran.
+//
+// GET /api/rest/requests
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK):
+//
+//	{
+//	  "enabled": true,
+//	  "requests": [
+//	    {
+//	      "id": 7, "time": "...", "method": "GET", "path": "/rest/v1/todos",
+//	      "query": "select=*&done=eq.false", "role": "anon", "status": 200,
+//	      "duration_ms": 1.9,
+//	      "statements": [{"sql": "SELECT * FROM \"public\".\"todos\" WHERE \"done\" = $1", "args": ["false"], "rows": 3, "duration_ms": 0.4}]
+//	    }
+//	  ]
+//	}
+//
+// enabled is false, with no requests, when the server doesn't record them.
+func (s *Server) handleListRESTRequests(w http.ResponseWriter, r *http.Request) {
+	resp := restRequestsResponse{Requests: []recorder.Request{}}
+	if s.restRequests != nil {
+		resp.Enabled = true
+		resp.Requests = s.restRequests.Recent()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleClearRESTRequests drops the recorded REST requests.
+//
+// DELETE /api/rest/requests
+//
+// Requires valid JWT token in Authorization header.
+//
+// Returns 204, or 404 when the server doesn't record requests.
+func (s *Server) handleClearRESTRequests(w http.ResponseWriter, r *http.Request) {
+	if s.restRequests == nil {
+		http.Error(w, "REST request recording is off", http.StatusNotFound)
+		return
+	}
+	s.restRequests.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// simulateRLSRequest represents the JSON body for /api/debug/rls.
+//
+// Either Token or Role/Claims identifies who the operation runs as.
+type simulateRLSRequest struct {
+	rls.SimulationRequest
+	Token string `json:"token,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
+
+// handleSimulateRLS tests whether RLS would permit an operation on a table.
+//
+// POST /api/debug/rls
+//
+// Requires valid JWT token in Authorization header.
+//
+// Runs the operation as the requested role inside a transaction that is
+// always rolled back, and reports whether it was permitted, how many rows
+// were visible or affected, and which policies apply and match.
+//
+// Request body:
+//   {
+//     "table": "todos",
+//     "operation": "select",
+//     "token": "eyJhbGciOiJFUzI1NiIsInR5cCI6IkpXVCJ9...",
+//     "match": {"id": 1}
+//   }
+//
+// Instead of a token, "role" and "claims" may be given directly:
+//   {
+//     "table": "todos",
+//     "operation": "insert",
+//     "role": "authenticated",
+//     "claims": {"sub": "uuid"},
+//     "row": {"title": "hello", "user_id": "uuid"}
+//   }
+//
+// Response (200 OK):
+//   {
+//     "schema": "public",
+//     "table": "todos",
+//     "operation": "select",
+//     "role": "authenticated",
+//     "rls_enabled": true,
+//     "permitted": true,
+//     "reason": "3 of 10 rows visible; applicable policies: own todos",
+//     "rows_total": 10,
+//     "rows_visible": 3,
+//     "plan": ["Seq Scan on todos ..."],
+//     "policies": [
+//       {"name": "own todos", "command": "SELECT", "applies": true, "matched_rows": 3, ...}
+//     ]
+//   }
+//
+// Returns 400 for invalid input or tokens that fail verification,
+// 404 if the table does not exist, or 500 for server errors.
+func (s *Server) handleSimulateRLS(w http.ResponseWriter, r *http.Request) {
+	var req simulateRLSRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	simulation := req.SimulationRequest
+	token := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(req.Token), "Bearer "))
+	if token != "" {
+		if s.tokenInspector == nil {
+			http.Error(w, "token inspection is not available", http.StatusNotImplemented)
+			return
+		}
+		info, err := s.tokenInspector.InspectToken(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !info.Valid {
+			http.Error(w, "token failed verification: "+info.VerificationError, http.StatusBadRequest)
+			return
+		}
+		simulation.Claims = info.Claims
+	} else {
+		if simulation.Claims == nil {
+			simulation.Claims = map[string]interface{}{}
+		}
+		if req.Role != "" {
+			simulation.Claims["role"] = req.Role
+		}
+	}
+
+	// Connect to database
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard rls simulate: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	result, err := rls.Simulate(ctx, conn.Conn(), simulation)
+	if err != nil {
+		if errors.Is(err, rls.ErrTableNotFound) {
+			http.Error(w, "table not found", http.StatusNotFound)
+			return
+		}
+		log.Error("dashboard rls simulate: simulation failed", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,638 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+// Page sizes of /api/tables/{name}/rows.
+const (
+	rowsDefaultLimit = 100
+	rowsMaxLimit     = 1000
+)
+
+// rowFilterOps are the comparisons a filter can make, as SQL operators.
+var rowFilterOps = map[string]string{
+	"eq":    "=",
+	"neq":   "<>",
+	"gt":    ">",
+	"gte":   ">=",
+	"lt":    "<",
+	"lte":   "<=",
+	"like":  "LIKE",
+	"ilike": "ILIKE",
+	"is":    "IS",
+}
+
+// rowsParams are the query parameters of /api/tables/{name}/rows that
+// aren't filters.
+var rowsParams = map[string]bool{"schema": true, "limit": true, "offset": true, "order": true}
+
+// rowsTable is a table whose rows are browsed or edited, with the columns
+// and primary key requests are checked against.
+type rowsTable struct {
+	Schema     string
+	Name       string
+	Columns    []columnInfo
+	PrimaryKey []string
+}
+
+// rowFilter compares a column to a value, e.g. title=ilike.%milk%.
+type rowFilter struct {
+	Column string
+	Op     string // A key of rowFilterOps
+	Value  string
+}
+
+// rowOrder sorts rows by a column.
+type rowOrder struct {
+	Column string
+	Desc   bool
+}
+
+// rowsQuery is the page of rows a GET /api/tables/{name}/rows asks for.
+type rowsQuery struct {
+	Limit   int
+	Offset  int
+	Order   []rowOrder
+	Filters []rowFilter
+}
+
+// rowsResponse represents the response for GET /api/tables/{name}/rows.
+type rowsResponse struct {
+	TableName  string            `json:"table_name"`
+	Schema     string            `json:"schema"`
+	Columns    []columnInfo      `json:"columns"`
+	PrimaryKey []string          `json:"primary_key"`
+	Rows       []json.RawMessage `json:"rows"`
+	Total      int64             `json:"total"` // Rows that pass the filters
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
+
+// rowResponse represents the response for writes to /api/tables/{name}/rows.
+type rowResponse struct {
+	Row json.RawMessage `json:"row"`
+}
+
+// handleGetRows returns a page of a table's rows.
+//
+// GET /api/tables/{name}/rows[?schema=public][&limit=100][&offset=0][&order=<column>[.asc|.desc],...][&<column>=<op>.<value>...]
+//
+// Requires valid JWT token in Authorization header.
+//
+// Rows are sorted by the order parameter, then by the primary key, so
+// pages don't overlap. Filters compare a column to a value with eq, neq,
+// gt, gte, lt, or lte, after converting the value to the column's type;
+// like and ilike match the column's text against a pattern with % and _;
+// and is checks for null, true, or false. Several filters must all match.
+//
+// Response (200 OK):
+//
+//	{
+//	  "table_name": "todos",
+//	  "schema": "public",
+//	  "columns": [{"name": "id", "type": "bigint", ...}, {"name": "title", "type": "text", ...}],
+//	  "primary_key": ["id"],
+//	  "rows": [{"id": 1, "title": "Buy milk"}],
+//	  "total": 1,
+//	  "limit": 100,
+//	  "offset": 0
+//	}
+//
+// Returns 400 for invalid parameters or a query PostgreSQL rejects, 404
+// if the table doesn't exist, or 500 for server errors.
+func (s *Server) handleGetRows(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard rows: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	t, ok := s.lookupRowsTable(w, r, conn)
+	if !ok {
+		return
+	}
+	q, err := parseRowsQuery(t, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query, countQuery, args := selectRowsSQL(t, q)
+	response := rowsResponse{
+		TableName:  t.Name,
+		Schema:     t.Schema,
+		Columns:    t.Columns,
+		PrimaryKey: t.PrimaryKey,
+		Rows:       []json.RawMessage{},
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+	}
+	if err := conn.QueryRow(ctx, countQuery, args...).Scan(&response.Total); err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row json.RawMessage
+		if err := rows.Scan(&row); err != nil {
+			writeSQLError(w, err)
+			return
+		}
+		response.Rows = append(response.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		writeSQLError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleInsertRow inserts a row into a table.
+//
+// POST /api/tables/{name}/rows[?schema=public]
+//
+// Requires valid JWT token in Authorization header.
+//
+// The body is a JSON object of column values; columns left out get their
+// defaults. Values are converted to the columns' types the way
+// json_populate_record does, so "2026-01-29" fills a date column and
+// {"a": 1} a jsonb one.
+//
+// Request body:
+//
+//	{"title": "Buy milk", "done": false}
+//
+// Response (201 Created):
+//
+//	{"row": {"id": 2, "title": "Buy milk", "done": false}}
+//
+// Returns 400 for unknown columns or a row PostgreSQL rejects, with its
+// error as /api/sql reports it, 404 if the table doesn't exist, or 500 for
+// server errors.
+func (s *Server) handleInsertRow(w http.ResponseWriter, r *http.Request) {
+	values, ok := decodeRowValues(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard rows: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	t, ok := s.lookupRowsTable(w, r, conn)
+	if !ok {
+		return
+	}
+	columns, err := rowColumns(t, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var args []interface{}
+	if len(columns) > 0 {
+		args = append(args, string(values))
+	}
+	var row json.RawMessage
+	if err := conn.QueryRow(ctx, insertRowSQL(t, columns), args...).Scan(&row); err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	adminEmail, _ := r.Context().Value("user_email").(string)
+	log.Info("dashboard inserted row", "admin", adminEmail, "table", t.Schema+"."+t.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rowResponse{Row: row})
+}
+
+// handleUpdateRow changes columns of a row, identified by its primary key.
+//
+// PATCH /api/tables/{name}/rows?<primary key column>=<value>...[&schema=public]
+//
+// Requires valid JWT token in Authorization header.
+//
+// The body is a JSON object of the columns to change, converted like
+// POST's.
+//
+// Request body:
+//
+//	{"done": true}
+//
+// Response (200 OK):
+//
+//	{"row": {"id": 2, "title": "Buy milk", "done": true}}
+//
+// Returns 400 if the table has no primary key, the key is incomplete, or
+// PostgreSQL rejects the change, 404 if the table or row doesn't exist, or
+// 500 for server errors.
+func (s *Server) handleUpdateRow(w http.ResponseWriter, r *http.Request) {
+	values, ok := decodeRowValues(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard rows: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	t, ok := s.lookupRowsTable(w, r, conn)
+	if !ok {
+		return
+	}
+	key, err := rowKey(t, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	columns, err := rowColumns(t, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(columns) == 0 {
+		http.Error(w, "nothing to change", http.StatusBadRequest)
+		return
+	}
+
+	var row json.RawMessage
+	err = conn.QueryRow(ctx, updateRowSQL(t, columns), string(values), key).Scan(&row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "row not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	adminEmail, _ := r.Context().Value("user_email").(string)
+	log.Info("dashboard updated row", "admin", adminEmail, "table", t.Schema+"."+t.Name, "key", key, "columns", columns)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rowResponse{Row: row})
+}
+
+// handleDeleteRow deletes a row, identified by its primary key.
+//
+// DELETE /api/tables/{name}/rows?<primary key column>=<value>...[&schema=public]
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK), with the deleted row:
+//
+//	{"row": {"id": 2, "title": "Buy milk", "done": true}}
+//
+// Returns 400 if the table has no primary key, the key is incomplete, or
+// PostgreSQL refuses, e.g. because other rows reference the row, 404 if
+// the table or row doesn't exist, or 500 for server errors.
+func (s *Server) handleDeleteRow(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard rows: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	t, ok := s.lookupRowsTable(w, r, conn)
+	if !ok {
+		return
+	}
+	key, err := rowKey(t, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var row json.RawMessage
+	err = conn.QueryRow(ctx, deleteRowSQL(t), key).Scan(&row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "row not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	adminEmail, _ := r.Context().Value("user_email").(string)
+	log.Info("dashboard deleted row", "admin", adminEmail, "table", t.Schema+"."+t.Name, "key", key)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rowResponse{Row: row})
+}
+
+// lookupRowsTable finds the table a rows request names, and reads its
+// columns and primary key. It answers the request itself if that fails.
+func (s *Server) lookupRowsTable(w http.ResponseWriter, r *http.Request, conn *pgxpool.Conn) (rowsTable, bool) {
+	ctx := r.Context()
+	schemas := schemaTableSchemas
+	if schema := r.URL.Query().Get("schema"); schema != "" {
+		schemas = []string{schema}
+	}
+
+	schemaName, tableOID, err := findTable(ctx, conn, chi.URLParam(r, "tableName"), schemas)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "table not found", http.StatusNotFound)
+		return rowsTable{}, false
+	}
+	if err != nil {
+		log.Error("dashboard rows: table lookup failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return rowsTable{}, false
+	}
+	columns, err := tableColumns(ctx, conn, tableOID)
+	if err != nil {
+		log.Error("dashboard rows: column query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return rowsTable{}, false
+	}
+	constraints, err := tableConstraints(ctx, conn, tableOID)
+	if err != nil {
+		log.Error("dashboard rows: constraint query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return rowsTable{}, false
+	}
+
+	t := rowsTable{
+		Schema:     schemaName,
+		Name:       chi.URLParam(r, "tableName"),
+		Columns:    applyConstraints(columns, constraints),
+		PrimaryKey: []string{},
+	}
+	for _, c := range constraints {
+		if c.Type == "PRIMARY KEY" {
+			t.PrimaryKey = c.Columns
+		}
+	}
+	return t, true
+}
+
+// decodeRowValues reads the JSON object of column values in a request
+// body. It answers the request itself if the body isn't one.
+func decodeRowValues(w http.ResponseWriter, r *http.Request) (json.RawMessage, bool) {
+	var values json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil || !strings.HasPrefix(string(values), "{") {
+		http.Error(w, "invalid request body: want a JSON object of column values", http.StatusBadRequest)
+		return nil, false
+	}
+	return values, true
+}
+
+// column returns the column of t with the given name.
+func (t rowsTable) column(name string) (columnInfo, bool) {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return columnInfo{}, false
+}
+
+// identifier returns the quoted, schema-qualified name of t.
+func (t rowsTable) identifier() string {
+	return pgx.Identifier{t.Schema, t.Name}.Sanitize()
+}
+
+// parseRowsQuery reads the page, order, and filters of a GET
+// /api/tables/{name}/rows from its query parameters.
+func parseRowsQuery(t rowsTable, params url.Values) (rowsQuery, error) {
+	q := rowsQuery{Limit: rowsDefaultLimit}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > rowsMaxLimit {
+			return rowsQuery{}, fmt.Errorf("limit must be between 1 and %d", rowsMaxLimit)
+		}
+		q.Limit = n
+	}
+	if v := params.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return rowsQuery{}, fmt.Errorf("offset must be a number of rows")
+		}
+		q.Offset = n
+	}
+
+	ordered := make(map[string]bool)
+	if v := params.Get("order"); v != "" {
+		for _, term := range strings.Split(v, ",") {
+			order := rowOrder{Column: term}
+			if name, dir, ok := cutLast(term, "."); ok && (dir == "asc" || dir == "desc") {
+				order = rowOrder{Column: name, Desc: dir == "desc"}
+			}
+			if _, ok := t.column(order.Column); !ok {
+				return rowsQuery{}, fmt.Errorf("order: unknown column %q", order.Column)
+			}
+			q.Order = append(q.Order, order)
+			ordered[order.Column] = true
+		}
+	}
+	// Break ties by the primary key, so pages don't overlap
+	for _, name := range t.PrimaryKey {
+		if !ordered[name] {
+			q.Order = append(q.Order, rowOrder{Column: name})
+		}
+	}
+
+	// Filters in column order, so the query doesn't depend on map order
+	var names []string
+	for name := range params {
+		if !rowsParams[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := t.column(name); !ok {
+			return rowsQuery{}, fmt.Errorf("unknown column %q", name)
+		}
+		for _, v := range params[name] {
+			op, value, _ := strings.Cut(v, ".")
+			if _, ok := rowFilterOps[op]; !ok {
+				return rowsQuery{}, fmt.Errorf("filter %s=%s: want <op>.<value>, with op one of eq, neq, gt, gte, lt, lte, like, ilike, or is", name, v)
+			}
+			if op == "is" && value != "null" && value != "true" && value != "false" {
+				return rowsQuery{}, fmt.Errorf("filter %s=%s: is takes null, true, or false", name, v)
+			}
+			q.Filters = append(q.Filters, rowFilter{Column: name, Op: op, Value: value})
+		}
+	}
+	return q, nil
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// selectRowsSQL builds the query for a page of rows, each as a JSON
+// object, and the query counting the rows that pass the filters. Both take
+// args.
+func selectRowsSQL(t rowsTable, q rowsQuery) (query, countQuery string, args []interface{}) {
+	var conditions []string
+	for _, f := range q.Filters {
+		column := "t." + pgx.Identifier{f.Column}.Sanitize()
+		switch f.Op {
+		case "is":
+			conditions = append(conditions, fmt.Sprintf("%s IS %s", column, strings.ToUpper(f.Value)))
+		case "like", "ilike":
+			args = append(args, f.Value)
+			conditions = append(conditions, fmt.Sprintf("%s::text %s $%d", column, rowFilterOps[f.Op], len(args)))
+		default:
+			// Compare as the column's type, so 9 < 10 for numbers
+			col, _ := t.column(f.Column)
+			args = append(args, f.Value)
+			conditions = append(conditions, fmt.Sprintf("%s %s CAST($%d::text AS %s)", column, rowFilterOps[f.Op], len(args), col.Type))
+		}
+	}
+
+	from := " FROM " + t.identifier() + " AS t"
+	if len(conditions) > 0 {
+		from += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	countQuery = "SELECT count(*)" + from
+	query = "SELECT row_to_json(t)" + from
+	if len(q.Order) > 0 {
+		terms := make([]string, len(q.Order))
+		for i, o := range q.Order {
+			terms[i] = "t." + pgx.Identifier{o.Column}.Sanitize()
+			if o.Desc {
+				terms[i] += " DESC"
+			}
+		}
+		query += " ORDER BY " + strings.Join(terms, ", ")
+	}
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", q.Limit, q.Offset)
+	return query, countQuery, args
+}
+
+// rowKey reads the primary key of the row a PATCH or DELETE names from
+// its query parameters.
+func rowKey(t rowsTable, params url.Values) (map[string]string, error) {
+	if len(t.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("table has no primary key, so its rows can't be told apart")
+	}
+	key := make(map[string]string, len(t.PrimaryKey))
+	for name, values := range params {
+		if name == "schema" {
+			continue
+		}
+		isKey := false
+		for _, k := range t.PrimaryKey {
+			isKey = isKey || k == name
+		}
+		if !isKey || len(values) != 1 {
+			return nil, fmt.Errorf("rows are identified by one value of each primary key column: %s", strings.Join(t.PrimaryKey, ", "))
+		}
+		key[name] = values[0]
+	}
+	if len(key) != len(t.PrimaryKey) {
+		return nil, fmt.Errorf("rows are identified by one value of each primary key column: %s", strings.Join(t.PrimaryKey, ", "))
+	}
+	return key, nil
+}
+
+// rowColumns returns the columns a JSON object of values sets, sorted,
+// checking that t has them.
+func rowColumns(t rowsTable, values json.RawMessage) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(values, &fields); err != nil {
+		return nil, fmt.Errorf("invalid row: %w", err)
+	}
+	columns := make([]string, 0, len(fields))
+	for name := range fields {
+		if _, ok := t.column(name); !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+// populateRecord is the row of t a JSON object of values, in $n, makes.
+func populateRecord(t rowsTable, n int) string {
+	return fmt.Sprintf("json_populate_record(NULL::%s, $%d::json)", t.identifier(), n)
+}
+
+// insertRowSQL builds the INSERT of a row whose columns' values are in $1.
+func insertRowSQL(t rowsTable, columns []string) string {
+	if len(columns) == 0 {
+		return "INSERT INTO " + t.identifier() + " AS t DEFAULT VALUES RETURNING row_to_json(t)"
+	}
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, name := range columns {
+		names[i] = pgx.Identifier{name}.Sanitize()
+		values[i] = "r." + names[i]
+	}
+	return fmt.Sprintf("INSERT INTO %s AS t (%s) SELECT %s FROM %s AS r RETURNING row_to_json(t)",
+		t.identifier(), strings.Join(names, ", "), strings.Join(values, ", "), populateRecord(t, 1))
+}
+
+// updateRowSQL builds the UPDATE of columns, whose values are in $1, of
+// the row whose primary key is in $2.
+func updateRowSQL(t rowsTable, columns []string) string {
+	set := make([]string, len(columns))
+	for i, name := range columns {
+		column := pgx.Identifier{name}.Sanitize()
+		set[i] = column + " = r." + column
+	}
+	return fmt.Sprintf("UPDATE %s AS t SET %s FROM %s AS r, %s AS k WHERE %s RETURNING row_to_json(t)",
+		t.identifier(), strings.Join(set, ", "), populateRecord(t, 1), populateRecord(t, 2), keyCondition(t))
+}
+
+// deleteRowSQL builds the DELETE of the row whose primary key is in $1.
+func deleteRowSQL(t rowsTable) string {
+	return fmt.Sprintf("DELETE FROM %s AS t USING %s AS k WHERE %s RETURNING row_to_json(t)",
+		t.identifier(), populateRecord(t, 1), keyCondition(t))
+}
+
+// keyCondition matches the row of t whose primary key is k's.
+func keyCondition(t rowsTable) string {
+	conditions := make([]string, len(t.PrimaryKey))
+	for i, name := range t.PrimaryKey {
+		column := pgx.Identifier{name}.Sanitize()
+		conditions[i] = "t." + column + " = k." + column
+	}
+	return strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,159 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var todosTable = rowsTable{
+	Schema: "public",
+	Name:   "todos",
+	Columns: []columnInfo{
+		{Name: "id", Type: "bigint"},
+		{Name: "title", Type: "text"},
+		{Name: "due", Type: "date"},
+		{Name: "done", Type: "boolean"},
+	},
+	PrimaryKey: []string{"id"},
+}
+
+func TestParseRowsQuery(t *testing.T) {
+	params, _ := url.ParseQuery("limit=20&offset=40&order=due.desc,title&title=ilike.%25milk%25&due=gte.2026-01-01&due=lt.2026-02-01&done=is.false&schema=public")
+	q, err := parseRowsQuery(todosTable, params)
+	if err != nil {
+		t.Fatalf("parseRowsQuery() failed: %v", err)
+	}
+	want := rowsQuery{
+		Limit:  20,
+		Offset: 40,
+		Order:  []rowOrder{{Column: "due", Desc: true}, {Column: "title"}, {Column: "id"}},
+		Filters: []rowFilter{
+			{Column: "done", Op: "is", Value: "false"},
+			{Column: "due", Op: "gte", Value: "2026-01-01"},
+			{Column: "due", Op: "lt", Value: "2026-02-01"},
+			{Column: "title", Op: "ilike", Value: "%milk%"},
+		},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("parseRowsQuery() = %+v, want %+v", q, want)
+	}
+
+	q, err = parseRowsQuery(todosTable, url.Values{})
+	if err != nil || q.Limit != rowsDefaultLimit || !reflect.DeepEqual(q.Order, []rowOrder{{Column: "id"}}) {
+		t.Errorf("parseRowsQuery() without parameters = %+v, %v; want the default limit, ordered by the primary key", q, err)
+	}
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=1001",
+		"offset=-1",
+		"order=missing.desc",
+		"missing=eq.1",
+		"title=milk",
+		"title=contains.milk",
+		"done=is.maybe",
+	} {
+		params, _ := url.ParseQuery(query)
+		if _, err := parseRowsQuery(todosTable, params); err == nil {
+			t.Errorf("parseRowsQuery(%s) succeeded, want error", query)
+		}
+	}
+}
+
+func TestSelectRowsSQL(t *testing.T) {
+	q := rowsQuery{
+		Limit:  20,
+		Offset: 40,
+		Order:  []rowOrder{{Column: "due", Desc: true}, {Column: "id"}},
+		Filters: []rowFilter{
+			{Column: "done", Op: "is", Value: "false"},
+			{Column: "due", Op: "gte", Value: "2026-01-01"},
+			{Column: "title", Op: "ilike", Value: "%milk%"},
+		},
+	}
+	query, countQuery, args := selectRowsSQL(todosTable, q)
+
+	where := ` FROM "public"."todos" AS t WHERE t."done" IS FALSE AND t."due" >= CAST($1::text AS date) AND t."title"::text ILIKE $2`
+	if want := `SELECT row_to_json(t)` + where + ` ORDER BY t."due" DESC, t."id" LIMIT 20 OFFSET 40`; query != want {
+		t.Errorf("query = %s, want %s", query, want)
+	}
+	if want := `SELECT count(*)` + where; countQuery != want {
+		t.Errorf("count query = %s, want %s", countQuery, want)
+	}
+	if want := []interface{}{"2026-01-01", "%milk%"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestRowKey(t *testing.T) {
+	composite := todosTable
+	composite.PrimaryKey = []string{"id", "due"}
+
+	key, err := rowKey(composite, url.Values{"id": {"7"}, "due": {"2026-01-29"}, "schema": {"public"}})
+	if want := map[string]string{"id": "7", "due": "2026-01-29"}; err != nil || !reflect.DeepEqual(key, want) {
+		t.Errorf("rowKey() = %v, %v; want %v", key, err, want)
+	}
+
+	for _, params := range []url.Values{
+		{"id": {"7"}},
+		{"id": {"7", "8"}, "due": {"2026-01-29"}},
+		{"id": {"7"}, "due": {"2026-01-29"}, "title": {"x"}},
+	} {
+		if _, err := rowKey(composite, params); err == nil {
+			t.Errorf("rowKey(%v) succeeded, want error", params)
+		}
+	}
+
+	keyless := todosTable
+	keyless.PrimaryKey = []string{}
+	if _, err := rowKey(keyless, url.Values{"id": {"7"}}); err == nil {
+		t.Error("rowKey() on a table without a primary key succeeded, want error")
+	}
+}
+
+func TestRowColumns(t *testing.T) {
+	columns, err := rowColumns(todosTable, json.RawMessage(`{"title": "Buy milk", "done": false}`))
+	if want := []string{"done", "title"}; err != nil || !reflect.DeepEqual(columns, want) {
+		t.Errorf("rowColumns() = %v, %v; want %v", columns, err, want)
+	}
+	if _, err := rowColumns(todosTable, json.RawMessage(`{"priority": 1}`)); err == nil {
+		t.Error("rowColumns() with an unknown column succeeded, want error")
+	}
+}
+
+func TestRowWriteSQL(t *testing.T) {
+	record := func(n string) string { return `json_populate_record(NULL::"public"."todos", $` + n + `::json)` }
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			"insert",
+			insertRowSQL(todosTable, []string{"done", "title"}),
+			`INSERT INTO "public"."todos" AS t ("done", "title") SELECT r."done", r."title" FROM ` + record("1") + ` AS r RETURNING row_to_json(t)`,
+		},
+		{
+			"insert defaults",
+			insertRowSQL(todosTable, nil),
+			`INSERT INTO "public"."todos" AS t DEFAULT VALUES RETURNING row_to_json(t)`,
+		},
+		{
+			"update",
+			updateRowSQL(todosTable, []string{"done"}),
+			`UPDATE "public"."todos" AS t SET "done" = r."done" FROM ` + record("1") + ` AS r, ` + record("2") + ` AS k WHERE t."id" = k."id" RETURNING row_to_json(t)`,
+		},
+		{
+			"delete",
+			deleteRowSQL(todosTable),
+			`DELETE FROM "public"."todos" AS t USING ` + record("1") + ` AS k WHERE t."id" = k."id" RETURNING row_to_json(t)`,
+		},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/schemacache"
+)
+
+// schemaResponse represents the response for /api/schema.
+type schemaResponse struct {
+	*schemacache.Schema
+	Listening bool `json:"listening"` // Schema changes reload the cache
+}
+
+// handleGetSchema returns the cached tables, columns, primary keys, and
+// foreign keys of every schema, as embedding in the REST API sees them.
+//
+// GET /api/schema
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK):
+//
+//	{
+//	  "loaded_at": "...",
+//	  "listening": true,
+//	  "tables": [{"schema": "public", "name": "todos", "kind": "table", "rls_enabled": true, "primary_key": ["id"], "columns": [...]}],
+//	  "foreign_keys": [{"name": "todos_user_id_fkey", "schema": "public", "table": "todos", "columns": ["user_id"], "ref_schema": "auth", "ref_table": "users", "ref_columns": ["id"]}]
+//	}
+//
+// listening is false when schema changes don't reach the cache, e.g. on
+// an external database where supalite couldn't create the event trigger;
+// POST /api/schema/reload then brings it up to date.
+func (s *Server) handleGetSchema(w http.ResponseWriter, r *http.Request) {
+	if s.schemaCache == nil {
+		http.Error(w, "schema cache is not available", http.StatusNotFound)
+		return
+	}
+	schema, err := s.schemaCache.Schema(r.Context())
+	if err != nil {
+		log.Error("failed to load schema", "error", err)
+		http.Error(w, "failed to load schema", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schemaResponse{Schema: schema, Listening: s.schemaCache.Listening()})
+}
+
+// handleReloadSchema reloads the schema cache from the database and
+// returns it as GET /api/schema does.
+//
+// POST /api/schema/reload
+//
+// Requires valid JWT token in Authorization header.
+func (s *Server) handleReloadSchema(w http.ResponseWriter, r *http.Request) {
+	if s.schemaCache == nil {
+		http.Error(w, "schema cache is not available", http.StatusNotFound)
+		return
+	}
+	schema, err := s.schemaCache.Reload(r.Context())
+	if err != nil {
+		log.Error("failed to reload schema", "error", err)
+		http.Error(w, "failed to reload schema", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schemaResponse{Schema: schema, Listening: s.schemaCache.Listening()})
+}
@@ -7,8 +7,12 @@ import (
 	"context"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/keys"
 	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/schemacache"
 )
 
 //go:embed dist
@@ -19,19 +23,32 @@ var dashboardFS embed.FS
 // The server provides JWT-authenticated endpoints for dashboard functionality
 // and serves static files for the web UI.
 type Server struct {
-	router       *chi.Mux
-	jwtManager   *JWTManager
-	pgConnector  PostgresConnector
-	staticFS     http.FileSystem  // HTTP-compatible filesystem
-	embedFS      fs.FS            // Original embedded filesystem for fs.ReadFile
+	router         *chi.Mux
+	jwtManager     *JWTManager
+	pgConnector    PostgresConnector
+	tokenSigner    auth.TokenSigner
+	tokenInspector TokenInspector
+	authAdmin      http.Handler
+	webhookSecret  string
+	restRequests   *recorder.Recorder
+	schemaCache    *schemacache.Cache
+	staticFS       http.FileSystem // HTTP-compatible filesystem
+	embedFS        fs.FS           // Original embedded filesystem for fs.ReadFile
 }
 
 // PostgresConnector defines the interface for connecting to PostgreSQL.
 //
-// This interface allows the dashboard to connect to the database without
+// This interface allows the dashboard to borrow pooled connections without
 // depending on the specific PostgreSQL implementation.
 type PostgresConnector interface {
-	Connect(ctx context.Context) (*pgx.Conn, error)
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// TokenInspector decodes API tokens and checks them against the active keys.
+//
+// keys.Manager satisfies this interface.
+type TokenInspector interface {
+	InspectToken(tokenString string) (*keys.TokenInfo, error)
 }
 
 // Config holds the configuration for the dashboard server.
@@ -39,8 +56,21 @@ type PostgresConnector interface {
 // Configuration includes the JWT secret for authentication and the
 // PostgreSQL connector for database access.
 type Config struct {
-	JWTSecret  string             // Secret key for JWT signing (32+ bytes recommended)
-	PGDatabase PostgresConnector  // Database connector for admin operations
+	JWTSecret      string            // Secret key for JWT signing (32+ bytes recommended)
+	PGDatabase     PostgresConnector // Database connector for admin operations
+	TokenSigner    auth.TokenSigner  // Optional: signs API tokens (user impersonation)
+	TokenInspector TokenInspector    // Optional: decodes API tokens (JWT debugging)
+	WebhookSecret  string            // Optional: default secret for webhook signature checks
+
+	// Optional: recorded REST requests and their SQL (see package recorder)
+	RESTRequests *recorder.Recorder
+
+	// Optional: the tables and relationships the REST API embeds through
+	Schema *schemacache.Cache
+
+	// Optional: GoTrue's API, with admin rights (auth user management).
+	// auth.Server.AdminHandler provides it.
+	AuthAdmin http.Handler
 }
 
 // NewServer creates a new dashboard server.
@@ -68,11 +98,17 @@ func NewServer(cfg Config) *Server {
 
 	router := chi.NewRouter()
 	s := &Server{
-		router:      router,
-		jwtManager:  jwtManager,
-		pgConnector: cfg.PGDatabase,
-		staticFS:    http.FS(distFS),
-		embedFS:     distFS,  // Store the original fs.FS for fs.ReadFile
+		router:         router,
+		jwtManager:     jwtManager,
+		pgConnector:    cfg.PGDatabase,
+		tokenSigner:    cfg.TokenSigner,
+		tokenInspector: cfg.TokenInspector,
+		authAdmin:      cfg.AuthAdmin,
+		webhookSecret:  cfg.WebhookSecret,
+		restRequests:   cfg.RESTRequests,
+		schemaCache:    cfg.Schema,
+		staticFS:       http.FS(distFS),
+		embedFS:        distFS, // Store the original fs.FS for fs.ReadFile
 	}
 
 	// Setup routes immediately after creating the server
@@ -89,6 +125,36 @@ func NewServer(cfg Config) *Server {
 //   - GET  /api/status - Protected: returns server status
 //   - GET  /api/tables - Protected: lists database tables
 //   - GET  /api/tables/{name}/schema - Protected: returns table schema
+//   - GET  /api/tables/{name}/rows - Protected: returns a page of a table's rows
+//   - POST /api/tables/{name}/rows - Protected: inserts a row
+//   - PATCH /api/tables/{name}/rows - Protected: changes a row, by primary key
+//   - DELETE /api/tables/{name}/rows - Protected: deletes a row, by primary key
+//   - POST /api/auth/impersonate - Protected: mints a token for an auth user
+//   - GET  /api/auth/users - Protected: lists auth users
+//   - POST /api/auth/users - Protected: creates an auth user
+//   - DELETE /api/auth/users/{id} - Protected: deletes an auth user
+//   - POST /api/auth/users/{id}/ban - Protected: bans an auth user
+//   - DELETE /api/auth/users/{id}/ban - Protected: lifts an auth user's ban
+//   - POST /api/auth/users/{id}/confirm - Protected: confirms an auth user's email
+//   - POST /api/auth/users/{id}/recover - Protected: sends an auth user a password reset email
+//   - POST /api/debug/jwt - Protected: decodes and verifies an API token
+//   - POST /api/debug/rls - Protected: simulates an operation under RLS
+//   - POST /api/webhooks/verify - Protected: checks a webhook signature
+//   - GET  /api/types - Protected: lists enum and domain types
+//   - POST /api/types/enums - Protected: creates an enum type
+//   - PATCH /api/types/enums/{schema}/{name} - Protected: adds or renames enum values
+//   - POST /api/types/domains - Protected: creates a domain type
+//   - PATCH /api/types/domains/{schema}/{name} - Protected: alters a domain type
+//   - GET  /api/logs - Protected: returns recent log entries
+//   - GET  /api/history - Protected: lists tables whose changes are tracked
+//   - GET  /api/history/{table} - Protected: returns a table's or row's change history
+//   - PUT  /api/history/{table} - Protected: starts tracking a table's changes
+//   - DELETE /api/history/{table} - Protected: stops tracking a table's changes
+//   - GET  /api/rest/requests - Protected: returns recorded REST requests and their SQL
+//   - DELETE /api/rest/requests - Protected: drops the recorded REST requests
+//   - GET  /api/schema - Protected: returns the cached tables and relationships
+//   - POST /api/schema/reload - Protected: reloads the schema cache
+//   - POST /api/sql - Protected: runs SQL from the SQL editor
 //   - /* - Static file serving
 func (s *Server) setupRoutes() {
 	// Public routes
@@ -101,6 +167,36 @@ func (s *Server) setupRoutes() {
 		r.Get("/api/status", s.handleStatus)
 		r.Get("/api/tables", s.handleListTables)
 		r.Get("/api/tables/{tableName}/schema", s.handleGetTableSchema)
+		r.Get("/api/tables/{tableName}/rows", s.handleGetRows)
+		r.Post("/api/tables/{tableName}/rows", s.handleInsertRow)
+		r.Patch("/api/tables/{tableName}/rows", s.handleUpdateRow)
+		r.Delete("/api/tables/{tableName}/rows", s.handleDeleteRow)
+		r.Post("/api/auth/impersonate", s.handleImpersonate)
+		r.Get("/api/auth/users", s.handleListAuthUsers)
+		r.Post("/api/auth/users", s.handleCreateAuthUser)
+		r.Delete("/api/auth/users/{id}", s.handleDeleteAuthUser)
+		r.Post("/api/auth/users/{id}/ban", s.handleBanAuthUser)
+		r.Delete("/api/auth/users/{id}/ban", s.handleBanAuthUser)
+		r.Post("/api/auth/users/{id}/confirm", s.handleConfirmAuthUser)
+		r.Post("/api/auth/users/{id}/recover", s.handleRecoverAuthUser)
+		r.Post("/api/debug/jwt", s.handleDebugJWT)
+		r.Post("/api/debug/rls", s.handleSimulateRLS)
+		r.Post("/api/webhooks/verify", s.handleVerifyWebhook)
+		r.Get("/api/types", s.handleListTypes)
+		r.Post("/api/types/enums", s.handleCreateEnum)
+		r.Patch("/api/types/enums/{schema}/{name}", s.handleAlterEnum)
+		r.Post("/api/types/domains", s.handleCreateDomain)
+		r.Patch("/api/types/domains/{schema}/{name}", s.handleAlterDomain)
+		r.Get("/api/logs", s.handleLogs)
+		r.Get("/api/history", s.handleListHistoryTables)
+		r.Get("/api/history/{table}", s.handleGetHistory)
+		r.Put("/api/history/{table}", s.handleSetHistoryTracking)
+		r.Delete("/api/history/{table}", s.handleSetHistoryTracking)
+		r.Get("/api/rest/requests", s.handleListRESTRequests)
+		r.Delete("/api/rest/requests", s.handleClearRESTRequests)
+		r.Get("/api/schema", s.handleGetSchema)
+		r.Post("/api/schema/reload", s.handleReloadSchema)
+		r.Post("/api/sql", s.handleSQL)
 	})
 
 	// Static file serving - handle both root and all other paths
@@ -0,0 +1,295 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/markb/supalite/internal/log"
+)
+
+// Limits of /api/sql requests. A request may lower them or raise them up
+// to the maximums.
+const (
+	sqlDefaultTimeout = 30 * time.Second
+	sqlMaxTimeout     = 5 * time.Minute
+	sqlDefaultMaxRows = 1000
+	sqlMaxRows        = 50000
+)
+
+// sqlRequest represents the JSON body for /api/sql.
+type sqlRequest struct {
+	Query     string `json:"query"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+	TimeoutMS int    `json:"timeout_ms,omitempty"` // Statement timeout (default: 30000)
+	MaxRows   int    `json:"max_rows,omitempty"`   // Rows returned per result (default: 1000)
+}
+
+// sqlColumn describes a column of a result.
+type sqlColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // As format_type prints it, e.g. "character varying(40)"
+}
+
+// sqlResult is the outcome of one statement.
+type sqlResult struct {
+	Command string      `json:"command"` // The command tag, e.g. "SELECT 3" or "INSERT 0 1"
+	Columns []sqlColumn `json:"columns"` // null for statements that return no rows
+	// Values in PostgreSQL's text format, or null
+	Rows      [][]interface{} `json:"rows"`
+	RowCount  int64           `json:"row_count"`           // Rows returned or affected
+	Truncated bool            `json:"truncated,omitempty"` // More than max_rows rows were returned
+}
+
+// sqlResponse represents the response for /api/sql.
+type sqlResponse struct {
+	Results    []sqlResult `json:"results"`
+	DurationMS int64       `json:"duration_ms"`
+}
+
+// limits returns the statement timeout and row limit a request asks for.
+func (req sqlRequest) limits() (time.Duration, int, error) {
+	timeout := sqlDefaultTimeout
+	switch {
+	case req.TimeoutMS < 0 || time.Duration(req.TimeoutMS)*time.Millisecond > sqlMaxTimeout:
+		return 0, 0, fmt.Errorf("timeout_ms must be between 1 and %d", sqlMaxTimeout.Milliseconds())
+	case req.TimeoutMS > 0:
+		timeout = time.Duration(req.TimeoutMS) * time.Millisecond
+	}
+
+	maxRows := sqlDefaultMaxRows
+	switch {
+	case req.MaxRows < 0 || req.MaxRows > sqlMaxRows:
+		return 0, 0, fmt.Errorf("max_rows must be between 1 and %d", sqlMaxRows)
+	case req.MaxRows > 0:
+		maxRows = req.MaxRows
+	}
+	return timeout, maxRows, nil
+}
+
+// handleSQL runs SQL typed into the dashboard's SQL editor.
+//
+// POST /api/sql
+//
+// Requires valid JWT token in Authorization header.
+//
+// Runs the query as the database superuser. Several statements separated
+// by semicolons run in one transaction, unless they contain their own
+// BEGIN and COMMIT, and return one result each. The query runs on a
+// connection of its own that is closed afterwards, so SET, temporary
+// tables, and transactions left open don't outlive the request.
+//
+// With "read_only", the query runs in read-only transactions, to guard
+// against accidental writes; it is not a security boundary. Statements are
+// canceled after "timeout_ms", and each result holds at most "max_rows"
+// rows, with "row_count" the number there were.
+//
+// Request body:
+//   {
+//     "query": "SELECT id, title FROM todos LIMIT 2; UPDATE todos SET done = true WHERE id = 1",
+//     "read_only": false,
+//     "timeout_ms": 30000,
+//     "max_rows": 1000
+//   }
+//
+// Response (200 OK):
+//   {
+//     "results": [
+//       {
+//         "command": "SELECT 2",
+//         "columns": [{"name": "id", "type": "bigint"}, {"name": "title", "type": "text"}],
+//         "rows": [["1", "Buy milk"], ["2", null]],
+//         "row_count": 2
+//       },
+//       {"command": "UPDATE 1", "columns": null, "rows": null, "row_count": 1}
+//     ],
+//     "duration_ms": 4
+//   }
+//
+// A statement that fails rolls back the transaction it ran in, and is
+// answered 400 with the error as PostgreSQL reports it:
+//   {
+//     "code": "42P01",
+//     "message": "relation \"todo\" does not exist",
+//     "details": null,
+//     "hint": null,
+//     "position": 15
+//   }
+//
+// Returns 400 for invalid input, or 500 for server errors.
+func (s *Server) handleSQL(w http.ResponseWriter, r *http.Request) {
+	var req sqlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+	timeout, maxRows, err := req.limits()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Connect to database
+	ctx := r.Context()
+	pooled, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard sql: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	// The query may change the session in any way, so the connection
+	// never goes back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	readOnly := "off"
+	if req.ReadOnly {
+		readOnly = "on"
+	}
+	if _, err := conn.Exec(ctx, "SELECT set_config('statement_timeout', $1, false), set_config('default_transaction_read_only', $2, false)",
+		strconv.FormatInt(timeout.Milliseconds(), 10), readOnly); err != nil {
+		log.Error("dashboard sql: session setup failed", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	adminEmail, _ := r.Context().Value("user_email").(string)
+	log.Info("dashboard sql", "admin", adminEmail, "read_only", req.ReadOnly, "query", req.Query)
+	start := time.Now()
+	results, err := runSQL(ctx, conn, req.Query, maxRows)
+	duration := time.Since(start)
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sqlResponse{Results: results, DurationMS: duration.Milliseconds()})
+}
+
+// runSQL runs query over the simple protocol, which accepts several
+// statements, and collects a result per statement, keeping at most maxRows
+// rows of each. Column types are named afterwards, in one query.
+func runSQL(ctx context.Context, conn *pgx.Conn, query string, maxRows int) ([]sqlResult, error) {
+	type columnType struct {
+		oid    uint32
+		typmod int32
+	}
+	var types []columnType
+	var typeIndexes [][]int // index in types of each result's columns
+
+	results := make([]sqlResult, 0)
+	mrr := conn.PgConn().Exec(ctx, query)
+	for mrr.NextResult() {
+		rr := mrr.ResultReader()
+		var result sqlResult
+		var indexes []int
+		if fields := rr.FieldDescriptions(); len(fields) > 0 {
+			result.Columns = make([]sqlColumn, len(fields))
+			result.Rows = make([][]interface{}, 0)
+			for i, field := range fields {
+				result.Columns[i].Name = field.Name
+				indexes = append(indexes, len(types))
+				types = append(types, columnType{field.DataTypeOID, field.TypeModifier})
+			}
+		}
+		var returned int64
+		for rr.NextRow() {
+			returned++
+			if len(result.Rows) == maxRows {
+				result.Truncated = true
+				continue
+			}
+			values := rr.Values()
+			row := make([]interface{}, len(values))
+			for i, value := range values {
+				if value != nil {
+					row[i] = string(value)
+				}
+			}
+			result.Rows = append(result.Rows, row)
+		}
+		tag, err := rr.Close()
+		if err != nil {
+			mrr.Close()
+			return nil, err
+		}
+		result.Command = tag.String()
+		result.RowCount = tag.RowsAffected()
+		if result.Columns != nil {
+			// SHOW and EXPLAIN tags carry no count
+			result.RowCount = returned
+		}
+		results = append(results, result)
+		typeIndexes = append(typeIndexes, indexes)
+	}
+	if err := mrr.Close(); err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return results, nil
+	}
+
+	oids := make([]uint32, len(types))
+	typmods := make([]int32, len(types))
+	for i, t := range types {
+		oids[i], typmods[i] = t.oid, t.typmod
+	}
+	var names []string
+	err := conn.QueryRow(ctx, `
+		SELECT array_agg(pg_catalog.format_type(t.oid, t.typmod) ORDER BY t.n)
+		FROM unnest($1::oid[], $2::int4[]) WITH ORDINALITY AS t(oid, typmod, n)`,
+		oids, typmods).Scan(&names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to name column types: %w", err)
+	}
+	for i, indexes := range typeIndexes {
+		for j, index := range indexes {
+			results[i].Columns[j].Type = names[index]
+		}
+	}
+	return results, nil
+}
+
+// writeSQLError reports a failed query. Errors PostgreSQL raised are the
+// query's fault and carry what it reported about them.
+func writeSQLError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		log.Error("dashboard sql: failed", "error", err)
+		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body := map[string]interface{}{
+		"code":     pgErr.Code,
+		"message":  pgErr.Message,
+		"details":  nil,
+		"hint":     nil,
+		"position": nil,
+	}
+	if pgErr.Detail != "" {
+		body["details"] = pgErr.Detail
+	}
+	if pgErr.Hint != "" {
+		body["hint"] = pgErr.Hint
+	}
+	if pgErr.Position > 0 {
+		body["position"] = pgErr.Position
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(body)
+}
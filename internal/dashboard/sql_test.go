@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLRequestLimits(t *testing.T) {
+	timeout, maxRows, err := sqlRequest{}.limits()
+	if err != nil || timeout != sqlDefaultTimeout || maxRows != sqlDefaultMaxRows {
+		t.Errorf("limits() = %v, %d, %v; want the defaults", timeout, maxRows, err)
+	}
+	timeout, maxRows, err = sqlRequest{TimeoutMS: 1500, MaxRows: 10}.limits()
+	if err != nil || timeout != 1500*time.Millisecond || maxRows != 10 {
+		t.Errorf("limits() = %v, %d, %v; want 1.5s, 10", timeout, maxRows, err)
+	}
+
+	for _, req := range []sqlRequest{
+		{TimeoutMS: -1},
+		{TimeoutMS: int(sqlMaxTimeout.Milliseconds()) + 1},
+		{MaxRows: -1},
+		{MaxRows: sqlMaxRows + 1},
+	} {
+		if _, _, err := req.limits(); err == nil {
+			t.Errorf("limits(%+v) succeeded, want error", req)
+		}
+	}
+}
+
+func TestHandleSQL_Invalid(t *testing.T) {
+	s := &Server{}
+	for _, body := range []string{
+		`not json`,
+		`{"query": "  "}`,
+		`{"query": "SELECT 1", "max_rows": 1000000}`,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleSQL(rec, httptest.NewRequest(http.MethodPost, "/api/sql", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /api/sql %s = %d, want 400", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+// schemaTableSchemas are the schemas /api/tables/{name}/schema looks in,
+// in the order a table name is resolved.
+var schemaTableSchemas = []string{"public", "admin", "auth", "storage"}
+
+// foreignKeyRef is the target of a foreign key.
+type foreignKeyRef struct {
+	Schema   string   `json:"schema"`
+	Table    string   `json:"table"`
+	Columns  []string `json:"columns"`
+	OnUpdate string   `json:"on_update"`
+	OnDelete string   `json:"on_delete"`
+}
+
+// constraintInfo describes a table constraint. Unique indexes that aren't
+// backed by a constraint are reported as UNIQUE too, since they enforce
+// the same thing.
+type constraintInfo struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"` // PRIMARY KEY, UNIQUE, FOREIGN KEY, or CHECK
+	Columns    []string       `json:"columns"`
+	Definition string         `json:"definition"`
+	References *foreignKeyRef `json:"references,omitempty"`
+}
+
+// columnInfo represents information about a table column.
+type columnInfo struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Nullable   bool           `json:"nullable"`
+	Default    *string        `json:"default"`
+	Identity   string         `json:"identity,omitempty"`  // ALWAYS or BY DEFAULT
+	Generated  string         `json:"generated,omitempty"` // Expression of a generated column
+	PrimaryKey bool           `json:"primary_key"`
+	Unique     bool           `json:"unique"`
+	References *foreignKeyRef `json:"references,omitempty"`
+	Checks     []string       `json:"checks,omitempty"`
+	Key        string         `json:"key,omitempty"` // The column's most significant constraint type
+}
+
+// tableSchemaResponse represents the response for /api/tables/{name}/schema endpoint.
+type tableSchemaResponse struct {
+	TableName   string           `json:"table_name"`
+	Schema      string           `json:"schema"`
+	Columns     []columnInfo     `json:"columns"`
+	PrimaryKey  []string         `json:"primary_key"`
+	Constraints []constraintInfo `json:"constraints"`
+}
+
+// handleGetTableSchema returns the schema for a specific table.
+//
+// GET /api/tables/{name}/schema[?schema=public]
+//
+// Requires valid JWT token in Authorization header.
+//
+// Reads columns and constraints from pg_catalog. Without a schema
+// parameter, the table is looked up in public, admin, auth, and storage,
+// in that order.
+//
+// Response (200 OK):
+//
+//	{
+//	  "table_name": "posts",
+//	  "schema": "public",
+//	  "columns": [
+//	    {
+//	      "name": "id",
+//	      "type": "bigint",
+//	      "nullable": false,
+//	      "default": null,
+//	      "identity": "BY DEFAULT",
+//	      "primary_key": true,
+//	      "unique": false,
+//	      "key": "PRIMARY KEY"
+//	    },
+//	    {
+//	      "name": "author_id",
+//	      "type": "uuid",
+//	      "nullable": false,
+//	      "default": null,
+//	      "primary_key": false,
+//	      "unique": false,
+//	      "references": {"schema": "public", "table": "profiles", "columns": ["id"], "on_update": "NO ACTION", "on_delete": "CASCADE"},
+//	      "key": "FOREIGN KEY"
+//	    }
+//	  ],
+//	  "primary_key": ["id"],
+//	  "constraints": [
+//	    {"name": "posts_pkey", "type": "PRIMARY KEY", "columns": ["id"], "definition": "PRIMARY KEY (id)"}
+//	  ]
+//	}
+//
+// Returns 401 if not authenticated, 404 if table not found,
+// or 500 for server errors.
+func (s *Server) handleGetTableSchema(w http.ResponseWriter, r *http.Request) {
+	tableName := chi.URLParam(r, "tableName")
+	if tableName == "" {
+		http.Error(w, "table name is required", http.StatusBadRequest)
+		return
+	}
+	schemas := schemaTableSchemas
+	if schema := r.URL.Query().Get("schema"); schema != "" {
+		schemas = []string{schema}
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard table schema: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	schemaName, tableOID, err := findTable(ctx, conn, tableName, schemas)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "table not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("dashboard table schema: table lookup failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+
+	columns, err := tableColumns(ctx, conn, tableOID)
+	if err != nil {
+		log.Error("dashboard table schema: column query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	constraints, err := tableConstraints(ctx, conn, tableOID)
+	if err != nil {
+		log.Error("dashboard table schema: constraint query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+
+	response := tableSchemaResponse{
+		TableName:   tableName,
+		Schema:      schemaName,
+		Columns:     applyConstraints(columns, constraints),
+		PrimaryKey:  []string{},
+		Constraints: constraints,
+	}
+	for _, c := range constraints {
+		if c.Type == "PRIMARY KEY" {
+			response.PrimaryKey = c.Columns
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// findTable resolves a table name to its schema and OID, looking in
+// schemas in order. Returns pgx.ErrNoRows if there's no such table.
+func findTable(ctx context.Context, conn *pgxpool.Conn, name string, schemas []string) (string, uint32, error) {
+	var schemaName string
+	var tableOID uint32
+	err := conn.QueryRow(ctx, `
+		SELECT n.nspname, c.oid
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = $1
+		AND n.nspname = ANY($2)
+		AND c.relkind IN ('r', 'p', 'v', 'm', 'f')
+		ORDER BY array_position($2, n.nspname::text)
+		LIMIT 1
+	`, name, schemas).Scan(&schemaName, &tableOID)
+	return schemaName, tableOID, err
+}
+
+// tableColumns reads the columns of a table, in definition order.
+func tableColumns(ctx context.Context, conn *pgxpool.Conn, tableOID uint32) ([]columnInfo, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			a.attname,
+			format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull,
+			pg_get_expr(d.adbin, d.adrelid),
+			a.attidentity::text,
+			a.attgenerated::text
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1
+		AND a.attnum > 0
+		AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`, tableOID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []columnInfo{}
+	for rows.Next() {
+		var col columnInfo
+		var identity, generated string
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default, &identity, &generated); err != nil {
+			return nil, err
+		}
+		switch identity {
+		case "a":
+			col.Identity = "ALWAYS"
+		case "d":
+			col.Identity = "BY DEFAULT"
+		}
+		// The "default" of a generated column is its generation expression
+		if generated == "s" && col.Default != nil {
+			col.Generated = *col.Default
+			col.Default = nil
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// tableConstraints reads the primary key, unique, foreign key, and check
+// constraints of a table, and its unique indexes on plain columns.
+func tableConstraints(ctx context.Context, conn *pgxpool.Conn, tableOID uint32) ([]constraintInfo, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			c.conname,
+			c.contype::text,
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			),
+			pg_get_constraintdef(c.oid),
+			COALESCE(fn.nspname, ''),
+			COALESCE(ft.relname, ''),
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			),
+			c.confupdtype::text,
+			c.confdeltype::text
+		FROM pg_constraint c
+		LEFT JOIN pg_class ft ON ft.oid = c.confrelid
+		LEFT JOIN pg_namespace fn ON fn.oid = ft.relnamespace
+		WHERE c.conrelid = $1
+		AND c.contype IN ('p', 'u', 'f', 'c')
+
+		UNION ALL
+
+		SELECT
+			i.relname,
+			'i',
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(x.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			),
+			pg_get_indexdef(x.indexrelid),
+			'', '', '{}', ' ', ' '
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = $1
+		AND x.indisunique
+		AND NOT x.indisprimary
+		AND x.indpred IS NULL
+		AND x.indexprs IS NULL
+		AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = x.indexrelid)
+
+		ORDER BY 2, 1
+	`, tableOID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constraints := []constraintInfo{}
+	for rows.Next() {
+		var c constraintInfo
+		var kind, refSchema, refTable, onUpdate, onDelete string
+		var refColumns []string
+		if err := rows.Scan(&c.Name, &kind, &c.Columns, &c.Definition,
+			&refSchema, &refTable, &refColumns, &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+		c.Type = constraintTypes[kind]
+		if kind == "f" {
+			c.References = &foreignKeyRef{
+				Schema:   refSchema,
+				Table:    refTable,
+				Columns:  refColumns,
+				OnUpdate: foreignKeyActions[onUpdate],
+				OnDelete: foreignKeyActions[onDelete],
+			}
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, rows.Err()
+}
+
+// constraintTypes names pg_constraint.contype values ("i" marks a unique
+// index without a constraint).
+var constraintTypes = map[string]string{
+	"p": "PRIMARY KEY",
+	"u": "UNIQUE",
+	"i": "UNIQUE",
+	"f": "FOREIGN KEY",
+	"c": "CHECK",
+}
+
+// foreignKeyActions names pg_constraint.confupdtype and confdeltype values.
+var foreignKeyActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// applyConstraints fills in the per-column constraint fields. Constraints
+// on several columns only show up on a column when they decide something
+// about it alone: every column of a composite primary key is part of the
+// key, but a composite unique constraint doesn't make any one column unique.
+func applyConstraints(columns []columnInfo, constraints []constraintInfo) []columnInfo {
+	byName := make(map[string]*columnInfo, len(columns))
+	for i := range columns {
+		byName[columns[i].Name] = &columns[i]
+	}
+
+	for _, c := range constraints {
+		if c.Type == "PRIMARY KEY" {
+			for _, name := range c.Columns {
+				if col := byName[name]; col != nil {
+					col.PrimaryKey = true
+				}
+			}
+			continue
+		}
+		if len(c.Columns) != 1 {
+			continue
+		}
+		col := byName[c.Columns[0]]
+		if col == nil {
+			continue
+		}
+		switch c.Type {
+		case "UNIQUE":
+			col.Unique = true
+		case "FOREIGN KEY":
+			col.References = c.References
+		case "CHECK":
+			col.Checks = append(col.Checks, c.Definition)
+		}
+	}
+
+	for i := range columns {
+		col := &columns[i]
+		switch {
+		case col.PrimaryKey:
+			col.Key = "PRIMARY KEY"
+		case col.References != nil:
+			col.Key = "FOREIGN KEY"
+		case col.Unique:
+			col.Key = "UNIQUE"
+		}
+	}
+	return columns
+}
@@ -0,0 +1,53 @@
+package dashboard
+
+import "testing"
+
+func TestApplyConstraints(t *testing.T) {
+	authors := &foreignKeyRef{Schema: "public", Table: "profiles", Columns: []string{"id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"}
+	columns := []columnInfo{
+		{Name: "id"},
+		{Name: "tenant_id"},
+		{Name: "author_id"},
+		{Name: "slug"},
+		{Name: "title"},
+		{Name: "rating"},
+	}
+	constraints := []constraintInfo{
+		{Name: "posts_pkey", Type: "PRIMARY KEY", Columns: []string{"id", "tenant_id"}},
+		{Name: "posts_author_id_fkey", Type: "FOREIGN KEY", Columns: []string{"author_id"}, References: authors},
+		{Name: "posts_slug_key", Type: "UNIQUE", Columns: []string{"slug"}},
+		{Name: "posts_title_tenant_key", Type: "UNIQUE", Columns: []string{"title", "tenant_id"}},
+		{Name: "posts_rating_check", Type: "CHECK", Columns: []string{"rating"}, Definition: "CHECK ((rating >= 0))"},
+	}
+
+	got := map[string]columnInfo{}
+	for _, col := range applyConstraints(columns, constraints) {
+		got[col.Name] = col
+	}
+
+	tests := []struct {
+		name       string
+		primaryKey bool
+		unique     bool
+		references bool
+		checks     int
+		key        string
+	}{
+		{"id", true, false, false, 0, "PRIMARY KEY"},
+		{"tenant_id", true, false, false, 0, "PRIMARY KEY"},
+		{"author_id", false, false, true, 0, "FOREIGN KEY"},
+		{"slug", false, true, false, 0, "UNIQUE"},
+		{"title", false, false, false, 0, ""},
+		{"rating", false, false, false, 1, ""},
+	}
+	for _, tt := range tests {
+		col := got[tt.name]
+		if col.PrimaryKey != tt.primaryKey || col.Unique != tt.unique || (col.References != nil) != tt.references ||
+			len(col.Checks) != tt.checks || col.Key != tt.key {
+			t.Errorf("column %s = %+v", tt.name, col)
+		}
+	}
+	if got["author_id"].References != authors {
+		t.Errorf("author_id references = %+v, want %+v", got["author_id"].References, authors)
+	}
+}
@@ -0,0 +1,551 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/markb/supalite/internal/log"
+)
+
+// enumInfo describes an enum type.
+type enumInfo struct {
+	Schema string   `json:"schema"`
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// domainConstraint is a CHECK constraint on a domain.
+type domainConstraint struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// domainInfo describes a domain type.
+type domainInfo struct {
+	Schema      string             `json:"schema"`
+	Name        string             `json:"name"`
+	BaseType    string             `json:"base_type"`
+	NotNull     bool               `json:"not_null"`
+	Default     *string            `json:"default"`
+	Constraints []domainConstraint `json:"constraints"`
+}
+
+// typesResponse represents the response for /api/types.
+type typesResponse struct {
+	Enums   []enumInfo   `json:"enums"`
+	Domains []domainInfo `json:"domains"`
+}
+
+// createEnumRequest represents the JSON body for POST /api/types/enums.
+type createEnumRequest struct {
+	Schema string   `json:"schema,omitempty"`
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// alterEnumRequest represents the JSON body for PATCH
+// /api/types/enums/{schema}/{name}. AddValue may be positioned with Before
+// or After; RenameValue renames an existing label.
+type alterEnumRequest struct {
+	AddValue    string      `json:"add_value,omitempty"`
+	Before      string      `json:"before,omitempty"`
+	After       string      `json:"after,omitempty"`
+	RenameValue *enumRename `json:"rename_value,omitempty"`
+}
+
+// enumRename renames an enum value.
+type enumRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// createDomainRequest represents the JSON body for POST /api/types/domains.
+// Default and Check are SQL expressions; Check may refer to VALUE.
+type createDomainRequest struct {
+	Schema   string `json:"schema,omitempty"`
+	Name     string `json:"name"`
+	BaseType string `json:"base_type"`
+	NotNull  bool   `json:"not_null,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Check    string `json:"check,omitempty"`
+}
+
+// alterDomainRequest represents the JSON body for PATCH
+// /api/types/domains/{schema}/{name}. Each field that is set becomes one
+// ALTER DOMAIN statement; they're applied together in a transaction.
+type alterDomainRequest struct {
+	Default        *string      `json:"default,omitempty"`
+	DropDefault    bool         `json:"drop_default,omitempty"`
+	NotNull        *bool        `json:"not_null,omitempty"`
+	AddCheck       *domainCheck `json:"add_check,omitempty"`
+	DropConstraint string       `json:"drop_constraint,omitempty"`
+}
+
+// domainCheck is a CHECK constraint to add to a domain. Without a name,
+// PostgreSQL picks one.
+type domainCheck struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+}
+
+// userSchemaFilter excludes the system schemas from type listings.
+const userSchemaFilter = `n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'`
+
+// handleListTypes lists user-defined enum and domain types.
+//
+// GET /api/types
+//
+// Requires valid JWT token in Authorization header.
+//
+// Response (200 OK):
+//   {
+//     "enums": [
+//       {"schema": "public", "name": "mood", "values": ["sad", "ok", "happy"]}
+//     ],
+//     "domains": [
+//       {
+//         "schema": "public",
+//         "name": "email",
+//         "base_type": "text",
+//         "not_null": false,
+//         "default": null,
+//         "constraints": [{"name": "email_check", "definition": "CHECK ((VALUE ~~ '%@%'::text))"}]
+//       }
+//     ]
+//   }
+//
+// Returns 401 if not authenticated or 500 for server errors.
+func (s *Server) handleListTypes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard types: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	response := typesResponse{Enums: []enumInfo{}, Domains: []domainInfo{}}
+
+	rows, err := conn.Query(ctx, `
+		SELECT n.nspname, t.typname, array_agg(e.enumlabel::text ORDER BY e.enumsortorder)
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE `+userSchemaFilter+`
+		GROUP BY n.nspname, t.typname
+		ORDER BY n.nspname, t.typname
+	`)
+	if err != nil {
+		log.Error("dashboard types: enum query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var enum enumInfo
+		if err := rows.Scan(&enum.Schema, &enum.Name, &enum.Values); err != nil {
+			log.Error("dashboard types: enum row scan failed", "error", err)
+			continue
+		}
+		response.Enums = append(response.Enums, enum)
+	}
+	rows.Close()
+
+	rows, err = conn.Query(ctx, `
+		SELECT n.nspname, t.typname, format_type(t.typbasetype, t.typtypmod), t.typnotnull, t.typdefault,
+			COALESCE((
+				SELECT json_agg(json_build_object('name', c.conname, 'definition', pg_get_constraintdef(c.oid)) ORDER BY c.conname)
+				FROM pg_constraint c
+				WHERE c.contypid = t.oid
+			), '[]')
+		FROM pg_type t
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typtype = 'd' AND `+userSchemaFilter+`
+		ORDER BY n.nspname, t.typname
+	`)
+	if err != nil {
+		log.Error("dashboard types: domain query failed", "error", err)
+		http.Error(w, "database query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var domain domainInfo
+		if err := rows.Scan(&domain.Schema, &domain.Name, &domain.BaseType, &domain.NotNull, &domain.Default, &domain.Constraints); err != nil {
+			log.Error("dashboard types: domain row scan failed", "error", err)
+			continue
+		}
+		response.Domains = append(response.Domains, domain)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCreateEnum creates an enum type.
+//
+// POST /api/types/enums
+//
+// Requires valid JWT token in Authorization header.
+//
+// Request body:
+//   {"schema": "public", "name": "mood", "values": ["sad", "ok", "happy"]}
+//
+// Returns 201 with the statement that was run, 400 for invalid input, 409
+// if the type already exists, or 500 for server errors.
+func (s *Server) handleCreateEnum(w http.ResponseWriter, r *http.Request) {
+	var req createEnumRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	stmt, err := createEnumSQL(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runTypeStatements(w, r, "create enum", http.StatusCreated, []string{stmt})
+}
+
+// handleAlterEnum adds or renames values of an enum type.
+//
+// PATCH /api/types/enums/{schema}/{name}
+//
+// Requires valid JWT token in Authorization header.
+//
+// Request body (add a value, optionally positioned):
+//   {"add_value": "meh", "after": "sad"}
+//
+// Request body (rename a value):
+//   {"rename_value": {"from": "ok", "to": "fine"}}
+//
+// Existing values can't be removed; PostgreSQL doesn't support it.
+//
+// Returns 200 with the statements that were run, 400 for invalid input,
+// 404 if the type or value doesn't exist, 409 if the new value already
+// exists, or 500 for server errors.
+func (s *Server) handleAlterEnum(w http.ResponseWriter, r *http.Request) {
+	var req alterEnumRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	stmts, err := alterEnumSQL(chi.URLParam(r, "schema"), chi.URLParam(r, "name"), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runTypeStatements(w, r, "alter enum", http.StatusOK, stmts)
+}
+
+// handleCreateDomain creates a domain type.
+//
+// POST /api/types/domains
+//
+// Requires valid JWT token in Authorization header.
+//
+// Request body:
+//   {
+//     "schema": "public",
+//     "name": "email",
+//     "base_type": "text",
+//     "not_null": true,
+//     "check": "VALUE ~ '^[^@]+@[^@]+$'"
+//   }
+//
+// default and check are SQL expressions, as in CREATE DOMAIN. Each is run
+// as a single statement, so they can't smuggle in others.
+//
+// Returns 201 with the statement that was run, 400 for invalid input or an
+// unknown base type, 409 if the type already exists, or 500 for server
+// errors.
+func (s *Server) handleCreateDomain(w http.ResponseWriter, r *http.Request) {
+	var req createDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.BaseType) == "" {
+		http.Error(w, "base_type is required", http.StatusBadRequest)
+		return
+	}
+
+	// Resolve the base type to its canonical name, which also rejects
+	// anything that isn't a type
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard create domain: database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	var baseType string
+	err = conn.QueryRow(ctx, "SELECT format_type($1::regtype, NULL)", req.BaseType).Scan(&baseType)
+	conn.Release()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unknown base type %q", req.BaseType), http.StatusBadRequest)
+		return
+	}
+	req.BaseType = baseType
+
+	stmt, err := createDomainSQL(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runTypeStatements(w, r, "create domain", http.StatusCreated, []string{stmt})
+}
+
+// handleAlterDomain changes the default, NOT NULL, or CHECK constraints of
+// a domain type.
+//
+// PATCH /api/types/domains/{schema}/{name}
+//
+// Requires valid JWT token in Authorization header.
+//
+// Request body (any combination):
+//   {
+//     "default": "'unknown'",
+//     "not_null": false,
+//     "add_check": {"name": "email_lower", "expression": "VALUE = lower(VALUE)"},
+//     "drop_constraint": "email_check"
+//   }
+//
+// Use "drop_default": true to remove the default. Changes are applied in one
+// transaction; adding NOT NULL or a CHECK fails if existing data violates it.
+//
+// Returns 200 with the statements that were run, 400 for invalid input or
+// constraints existing data violates, 404 if the domain or constraint
+// doesn't exist, or 500 for server errors.
+func (s *Server) handleAlterDomain(w http.ResponseWriter, r *http.Request) {
+	var req alterDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	stmts, err := alterDomainSQL(chi.URLParam(r, "schema"), chi.URLParam(r, "name"), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.runTypeStatements(w, r, "alter domain", http.StatusOK, stmts)
+}
+
+// runTypeStatements runs DDL statements in a transaction and reports them.
+func (s *Server) runTypeStatements(w http.ResponseWriter, r *http.Request, op string, status int, stmts []string) {
+	ctx := r.Context()
+	conn, err := s.pgConnector.Acquire(ctx)
+	if err != nil {
+		log.Error("dashboard "+op+": database connection failed", "error", err)
+		http.Error(w, "database connection failed", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error("dashboard "+op+": begin failed", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range stmts {
+		if err := execSingleStatement(ctx, tx, stmt); err != nil {
+			writeTypeError(w, op, err)
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeTypeError(w, op, err)
+		return
+	}
+
+	log.Info("dashboard "+op, "statements", stmts)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"statements": stmts})
+}
+
+// execSingleStatement runs stmt over the extended protocol, which rejects
+// more than one statement. pgx would use the simple protocol for a query
+// without arguments, and that accepts several separated by semicolons.
+func execSingleStatement(ctx context.Context, tx pgx.Tx, stmt string) error {
+	_, err := tx.Conn().PgConn().ExecParams(ctx, stmt, nil, nil, nil, nil).Close()
+	return err
+}
+
+// writeTypeError maps a failed type change to an HTTP status.
+func writeTypeError(w http.ResponseWriter, op string, err error) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		log.Error("dashboard "+op+": failed", "error", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch class := pgErr.Code[:2]; {
+	case pgErr.Code == "42710": // duplicate_object
+		status = http.StatusConflict
+	case pgErr.Code == "42704": // undefined_object
+		status = http.StatusNotFound
+	case class == "0A" || class == "22" || class == "23" || class == "42":
+		// Unsupported, bad data, existing rows violating a new constraint,
+		// or syntax and naming errors in the request's expressions
+		status = http.StatusBadRequest
+	}
+	if status == http.StatusInternalServerError {
+		log.Error("dashboard "+op+": failed", "error", err)
+	}
+	http.Error(w, pgErr.Message, status)
+}
+
+// createEnumSQL builds a CREATE TYPE ... AS ENUM statement.
+func createEnumSQL(req createEnumRequest) (string, error) {
+	name, err := qualifiedTypeName(req.Schema, req.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(req.Values) == 0 {
+		return "", fmt.Errorf("values are required")
+	}
+	labels := make([]string, len(req.Values))
+	for i, value := range req.Values {
+		if value == "" {
+			return "", fmt.Errorf("enum values can't be empty")
+		}
+		labels[i] = quoteLiteral(value)
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s)", name, strings.Join(labels, ", ")), nil
+}
+
+// alterEnumSQL builds the ALTER TYPE statements for an enum change.
+func alterEnumSQL(schema, typeName string, req alterEnumRequest) ([]string, error) {
+	name, err := qualifiedTypeName(schema, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var stmts []string
+	if req.AddValue != "" {
+		stmt := fmt.Sprintf("ALTER TYPE %s ADD VALUE %s", name, quoteLiteral(req.AddValue))
+		switch {
+		case req.Before != "" && req.After != "":
+			return nil, fmt.Errorf("before and after can't both be set")
+		case req.Before != "":
+			stmt += " BEFORE " + quoteLiteral(req.Before)
+		case req.After != "":
+			stmt += " AFTER " + quoteLiteral(req.After)
+		}
+		stmts = append(stmts, stmt)
+	}
+	if rv := req.RenameValue; rv != nil {
+		if rv.From == "" || rv.To == "" {
+			return nil, fmt.Errorf("rename_value needs from and to")
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TYPE %s RENAME VALUE %s TO %s", name, quoteLiteral(rv.From), quoteLiteral(rv.To)))
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("nothing to change: set add_value or rename_value")
+	}
+	return stmts, nil
+}
+
+// createDomainSQL builds a CREATE DOMAIN statement. BaseType must already
+// be canonical, as returned by format_type.
+func createDomainSQL(req createDomainRequest) (string, error) {
+	name, err := qualifiedTypeName(req.Schema, req.Name)
+	if err != nil {
+		return "", err
+	}
+	stmt := fmt.Sprintf("CREATE DOMAIN %s AS %s", name, req.BaseType)
+	if req.Default != "" {
+		stmt += " DEFAULT " + req.Default
+	}
+	if req.NotNull {
+		stmt += " NOT NULL"
+	}
+	if req.Check != "" {
+		stmt += " CHECK (" + req.Check + ")"
+	}
+	return stmt, nil
+}
+
+// alterDomainSQL builds the ALTER DOMAIN statements for a domain change.
+func alterDomainSQL(schema, typeName string, req alterDomainRequest) ([]string, error) {
+	name, err := qualifiedTypeName(schema, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var stmts []string
+	switch {
+	case req.Default != nil && req.DropDefault:
+		return nil, fmt.Errorf("default and drop_default can't both be set")
+	case req.Default != nil:
+		if *req.Default == "" {
+			return nil, fmt.Errorf("default can't be empty; use drop_default")
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER DOMAIN %s SET DEFAULT %s", name, *req.Default))
+	case req.DropDefault:
+		stmts = append(stmts, fmt.Sprintf("ALTER DOMAIN %s DROP DEFAULT", name))
+	}
+	if req.NotNull != nil {
+		if *req.NotNull {
+			stmts = append(stmts, fmt.Sprintf("ALTER DOMAIN %s SET NOT NULL", name))
+		} else {
+			stmts = append(stmts, fmt.Sprintf("ALTER DOMAIN %s DROP NOT NULL", name))
+		}
+	}
+	if req.DropConstraint != "" {
+		stmts = append(stmts, fmt.Sprintf("ALTER DOMAIN %s DROP CONSTRAINT %s", name, pgx.Identifier{req.DropConstraint}.Sanitize()))
+	}
+	if c := req.AddCheck; c != nil {
+		if strings.TrimSpace(c.Expression) == "" {
+			return nil, fmt.Errorf("add_check needs an expression")
+		}
+		stmt := fmt.Sprintf("ALTER DOMAIN %s ADD", name)
+		if c.Name != "" {
+			stmt += " CONSTRAINT " + pgx.Identifier{c.Name}.Sanitize()
+		}
+		stmts = append(stmts, stmt+" CHECK ("+c.Expression+")")
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("nothing to change")
+	}
+	return stmts, nil
+}
+
+// qualifiedTypeName validates and quotes a schema-qualified type name. The
+// schema defaults to public; system schemas are refused.
+func qualifiedTypeName(schema, name string) (string, error) {
+	if schema == "" {
+		schema = "public"
+	}
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if len(name) > 63 || len(schema) > 63 {
+		return "", fmt.Errorf("names are limited to 63 bytes")
+	}
+	if schema == "information_schema" || strings.HasPrefix(schema, "pg_") {
+		return "", fmt.Errorf("types can't be changed in system schema %q", schema)
+	}
+	return pgx.Identifier{schema, name}.Sanitize(), nil
+}
+
+// quoteLiteral quotes a string constant for DDL, where bind parameters
+// can't be used. standard_conforming_strings is on, so only quotes need
+// escaping.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,98 @@
+package dashboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreateEnumSQL(t *testing.T) {
+	got, err := createEnumSQL(createEnumRequest{Name: "mood", Values: []string{"sad", "it's ok", "happy"}})
+	if err != nil {
+		t.Fatalf("createEnumSQL() failed: %v", err)
+	}
+	if want := `CREATE TYPE "public"."mood" AS ENUM ('sad', 'it''s ok', 'happy')`; got != want {
+		t.Errorf("createEnumSQL() = %s, want %s", got, want)
+	}
+
+	for _, req := range []createEnumRequest{
+		{Name: "mood"},
+		{Name: "mood", Values: []string{""}},
+		{Values: []string{"a"}},
+		{Schema: "pg_catalog", Name: "mood", Values: []string{"a"}},
+	} {
+		if _, err := createEnumSQL(req); err == nil {
+			t.Errorf("createEnumSQL(%+v) succeeded, want error", req)
+		}
+	}
+}
+
+func TestAlterEnumSQL(t *testing.T) {
+	req := alterEnumRequest{AddValue: "meh", After: "sad", RenameValue: &enumRename{From: "ok", To: "fine"}}
+
+	got, err := alterEnumSQL("app", `my"enum`, req)
+	if err != nil {
+		t.Fatalf("alterEnumSQL() failed: %v", err)
+	}
+	want := []string{
+		`ALTER TYPE "app"."my""enum" ADD VALUE 'meh' AFTER 'sad'`,
+		`ALTER TYPE "app"."my""enum" RENAME VALUE 'ok' TO 'fine'`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alterEnumSQL() = %q, want %q", got, want)
+	}
+
+	if _, err := alterEnumSQL("public", "mood", alterEnumRequest{AddValue: "x", Before: "a", After: "b"}); err == nil {
+		t.Error("alterEnumSQL() with before and after succeeded, want error")
+	}
+	if _, err := alterEnumSQL("public", "mood", alterEnumRequest{}); err == nil {
+		t.Error("alterEnumSQL() with no change succeeded, want error")
+	}
+}
+
+func TestCreateDomainSQL(t *testing.T) {
+	got, err := createDomainSQL(createDomainRequest{
+		Name:     "email",
+		BaseType: "character varying(320)",
+		NotNull:  true,
+		Default:  "''",
+		Check:    "VALUE ~ '@'",
+	})
+	if err != nil {
+		t.Fatalf("createDomainSQL() failed: %v", err)
+	}
+	if want := `CREATE DOMAIN "public"."email" AS character varying(320) DEFAULT '' NOT NULL CHECK (VALUE ~ '@')`; got != want {
+		t.Errorf("createDomainSQL() = %s, want %s", got, want)
+	}
+}
+
+func TestAlterDomainSQL(t *testing.T) {
+	notNull := false
+	def := "'n/a'"
+	req := alterDomainRequest{
+		Default:        &def,
+		NotNull:        &notNull,
+		AddCheck:       &domainCheck{Name: "email_lower", Expression: "VALUE = lower(VALUE)"},
+		DropConstraint: "email_check",
+	}
+
+	got, err := alterDomainSQL("", "email", req)
+	if err != nil {
+		t.Fatalf("alterDomainSQL() failed: %v", err)
+	}
+	want := []string{
+		`ALTER DOMAIN "public"."email" SET DEFAULT 'n/a'`,
+		`ALTER DOMAIN "public"."email" DROP NOT NULL`,
+		`ALTER DOMAIN "public"."email" DROP CONSTRAINT "email_check"`,
+		`ALTER DOMAIN "public"."email" ADD CONSTRAINT "email_lower" CHECK (VALUE = lower(VALUE))`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alterDomainSQL() = %q, want %q", got, want)
+	}
+
+	if _, err := alterDomainSQL("public", "email", alterDomainRequest{Default: &def, DropDefault: true}); err == nil {
+		t.Error("alterDomainSQL() with default and drop_default succeeded, want error")
+	}
+	if _, err := alterDomainSQL("public", "email", alterDomainRequest{}); err == nil {
+		t.Error("alterDomainSQL() with no change succeeded, want error")
+	}
+}
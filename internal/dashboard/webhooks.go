@@ -0,0 +1,104 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/markb/supalite/webhook"
+)
+
+// verifyWebhookRequest represents the JSON body for /api/webhooks/verify.
+type verifyWebhookRequest struct {
+	Secret    string `json:"secret"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Signature string `json:"signature"`
+	Payload   string `json:"payload"`
+}
+
+// verifyWebhookResponse reports whether a webhook signature checks out and,
+// when it doesn't, what the receiver should have seen.
+type verifyWebhookResponse struct {
+	Valid             bool   `json:"valid"`
+	Error             string `json:"error,omitempty"`
+	ExpectedSignature string `json:"expected_signature,omitempty"`
+	TimestampAge      string `json:"timestamp_age,omitempty"`
+	SignedContent     string `json:"signed_content,omitempty"`
+}
+
+// handleVerifyWebhook checks a delivered webhook's signature.
+//
+// POST /api/webhooks/verify
+//
+// Requires valid JWT token in Authorization header.
+//
+// Takes the values of the webhook-id, webhook-timestamp, and
+// webhook-signature headers and the raw request body, and verifies them the
+// way webhook.Verify does. The secret defaults to the configured capture
+// webhook secret. On a mismatch the response carries the signature supalite
+// would have sent and the exact string it signed, so a receiver can compare
+// against what it computed.
+//
+// Request body:
+//   {
+//     "secret": "whsec_...",
+//     "id": "msg_2b8e...",
+//     "timestamp": "1767225600",
+//     "signature": "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=",
+//     "payload": "{\"to\":\"user@example.com\",...}"
+//   }
+//
+// Response (200 OK):
+//   {
+//     "valid": false,
+//     "error": "no matching webhook signature",
+//     "expected_signature": "v1,K5oZfzN95Z9UVu1EsfQmfVNQhnkZ2pj9o9NDN/H/pI4=",
+//     "timestamp_age": "2m14s",
+//     "signed_content": "msg_2b8e....1767225600.{\"to\":...}"
+//   }
+//
+// Returns 400 if the body is malformed or no secret is given or configured.
+func (s *Server) handleVerifyWebhook(w http.ResponseWriter, r *http.Request) {
+	var req verifyWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = s.webhookSecret
+	}
+	if secret == "" {
+		http.Error(w, "secret is required (no capture webhook secret is configured)", http.StatusBadRequest)
+		return
+	}
+
+	header := http.Header{}
+	header.Set(webhook.HeaderID, req.ID)
+	header.Set(webhook.HeaderTimestamp, req.Timestamp)
+	header.Set(webhook.HeaderSignature, req.Signature)
+
+	now := time.Now()
+	var response verifyWebhookResponse
+	if err := webhook.VerifyAt(secret, header, []byte(req.Payload), now, webhook.DefaultTolerance); err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Valid = true
+	}
+
+	if seconds, err := strconv.ParseInt(req.Timestamp, 10, 64); err == nil {
+		sent := time.Unix(seconds, 0)
+		response.TimestampAge = now.Sub(sent).Round(time.Second).String()
+		if !response.Valid {
+			response.ExpectedSignature = webhook.Sign(secret, req.ID, sent, []byte(req.Payload))
+			response.SignedContent = req.ID + "." + req.Timestamp + "." + req.Payload
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,90 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/webhook"
+)
+
+func TestHandleVerifyWebhook(t *testing.T) {
+	const secret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	now := time.Now()
+	payload := `{"to":"user@example.com"}`
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := webhook.Sign(secret, "msg_1", now, []byte(payload))
+
+	tests := []struct {
+		name         string
+		serverSecret string
+		body         map[string]string
+		wantStatus   int
+		wantValid    bool
+		wantError    string
+		wantExpected bool
+	}{
+		{
+			name:       "valid with given secret",
+			body:       map[string]string{"secret": secret, "id": "msg_1", "timestamp": timestamp, "signature": signature, "payload": payload},
+			wantStatus: http.StatusOK,
+			wantValid:  true,
+		},
+		{
+			name:         "valid with configured secret",
+			serverSecret: secret,
+			body:         map[string]string{"id": "msg_1", "timestamp": timestamp, "signature": signature, "payload": payload},
+			wantStatus:   http.StatusOK,
+			wantValid:    true,
+		},
+		{
+			name:         "payload mismatch",
+			body:         map[string]string{"secret": secret, "id": "msg_1", "timestamp": timestamp, "signature": signature, "payload": payload + " "},
+			wantStatus:   http.StatusOK,
+			wantError:    webhook.ErrInvalidSignature.Error(),
+			wantExpected: true,
+		},
+		{
+			name:       "missing signature",
+			body:       map[string]string{"secret": secret, "id": "msg_1", "timestamp": timestamp, "payload": payload},
+			wantStatus: http.StatusOK,
+			wantError:  webhook.ErrMissingHeaders.Error(),
+		},
+		{
+			name:       "no secret",
+			body:       map[string]string{"id": "msg_1", "timestamp": timestamp, "signature": signature, "payload": payload},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{webhookSecret: tt.serverSecret}
+			body, _ := json.Marshal(tt.body)
+			rec := httptest.NewRecorder()
+			s.handleVerifyWebhook(rec, httptest.NewRequest(http.MethodPost, "/api/webhooks/verify", strings.NewReader(string(body))))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp verifyWebhookResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Valid != tt.wantValid || resp.Error != tt.wantError {
+				t.Errorf("got valid=%v error=%q, want valid=%v error=%q", resp.Valid, resp.Error, tt.wantValid, tt.wantError)
+			}
+			if tt.wantExpected && resp.ExpectedSignature == "" {
+				t.Error("expected_signature should be set on a mismatch")
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package dump
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Masking strategies
+const (
+	StrategyKeep   = "keep"   // Leave the value alone
+	StrategyNull   = "null"   // Replace with NULL
+	StrategyEmail  = "email"  // user_<hash>@example.invalid
+	StrategyName   = "name"   // A made-up "First Last" name
+	StrategyPhone  = "phone"  // Same shape, digits replaced
+	StrategyToken  = "token"  // Hex string of the same length
+	StrategyHash   = "hash"   // HMAC-SHA256 of the value, in hex
+	StrategyRedact = "redact" // The text "[redacted]"
+	StrategySet    = "set"    // A fixed value, written {set: <value>}
+)
+
+// Rules mask columns while dumping. They are read from YAML:
+//
+//	seed: some-secret          # optional: same seed, same fake values
+//	exclude:                   # tables whose rows are left out
+//	  - auth.sessions
+//	tables:
+//	  auth.users:
+//	    email: email
+//	    phone: phone
+//	    encrypted_password: null
+//	    raw_user_meta_data: {set: "{}"}
+//	  profiles:                # unqualified names are in public
+//	    full_name: name
+//
+// Fake values are derived from the original with an HMAC keyed by the
+// seed, so a value maps to the same fake everywhere in a dump: unique
+// constraints hold, and a user's email in one table still matches it in
+// another. Without a seed a random one is used, so separate dumps don't
+// match. NULLs stay NULL.
+type Rules struct {
+	Seed    string                          `yaml:"seed"`
+	Exclude []string                        `yaml:"exclude"`
+	Tables  map[string]map[string]*Strategy `yaml:"tables"`
+
+	key []byte // HMAC key derived from Seed
+}
+
+// Strategy is how one column is masked.
+type Strategy struct {
+	Name  string
+	Value *string // For StrategySet; nil sets NULL
+}
+
+// UnmarshalYAML reads a strategy name, null, or {set: value}.
+func (s *Strategy) UnmarshalYAML(node *yaml.Node) error {
+	switch {
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!null":
+		s.Name = StrategyNull
+		return nil
+	case node.Kind == yaml.ScalarNode:
+		switch node.Value {
+		case StrategyKeep, StrategyNull, StrategyEmail, StrategyName, StrategyPhone, StrategyToken, StrategyHash, StrategyRedact:
+			s.Name = node.Value
+			return nil
+		}
+		return fmt.Errorf("line %d: unknown strategy %q (use keep, null, email, name, phone, token, hash, redact, or {set: value})", node.Line, node.Value)
+	case node.Kind == yaml.MappingNode:
+		var m map[string]*string
+		if err := node.Decode(&m); err != nil {
+			return err
+		}
+		value, ok := m[StrategySet]
+		if !ok || len(m) != 1 {
+			return fmt.Errorf("line %d: expected {set: value}", node.Line)
+		}
+		s.Name, s.Value = StrategySet, value
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a strategy name or {set: value}", node.Line)
+}
+
+// LoadRules reads rules from a YAML file.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(data)
+}
+
+// ParseRules parses YAML rules.
+func ParseRules(data []byte) (*Rules, error) {
+	var r Rules
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid anonymization rules: %w", err)
+	}
+
+	// Qualify table names, so lookups needn't
+	tables := make(map[string]map[string]*Strategy, len(r.Tables))
+	for table, columns := range r.Tables {
+		name := qualify(table)
+		if _, dup := tables[name]; dup {
+			return nil, fmt.Errorf("invalid anonymization rules: table %s is listed twice", name)
+		}
+		for column, s := range columns {
+			if s == nil { // a bare "column:" is YAML null
+				columns[column] = &Strategy{Name: StrategyNull}
+			}
+		}
+		tables[name] = columns
+	}
+	r.Tables = tables
+	for i, table := range r.Exclude {
+		r.Exclude[i] = qualify(table)
+	}
+
+	if r.Seed != "" {
+		sum := sha256.Sum256([]byte("supalite-anonymize:" + r.Seed))
+		r.key = sum[:]
+	} else {
+		r.key = make([]byte, 32)
+		rand.Read(r.key)
+	}
+	return &r, nil
+}
+
+// qualify prefixes a table name without a schema with "public.".
+func qualify(table string) string {
+	if strings.Contains(table, ".") {
+		return table
+	}
+	return "public." + table
+}
+
+// strategies returns the masking strategy of each column of a table, in
+// order; nil entries are kept as they are.
+func (r *Rules) strategies(table string, columns []string) []*Strategy {
+	rules := r.Tables[table]
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]*Strategy, len(columns))
+	for i, column := range columns {
+		if s := rules[column]; s != nil && s.Name != StrategyKeep {
+			out[i] = s
+		}
+	}
+	return out
+}
+
+// mask applies a strategy to a value in its text form.
+func (r *Rules) mask(s *Strategy, value *string) *string {
+	if value == nil && s.Name != StrategySet {
+		return nil
+	}
+
+	var masked string
+	switch s.Name {
+	case StrategyNull:
+		return nil
+	case StrategySet:
+		return s.Value
+	case StrategyEmail:
+		masked = "user_" + r.digest(*value)[:12] + "@example.invalid"
+	case StrategyName:
+		sum := r.sum(*value)
+		first := firstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(firstNames))]
+		last := lastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(lastNames))]
+		masked = first + " " + last
+	case StrategyPhone:
+		masked = r.phone(*value)
+	case StrategyToken:
+		masked = r.hexOfLength(*value, len(*value))
+	case StrategyHash:
+		masked = r.digest(*value)
+	case StrategyRedact:
+		masked = "[redacted]"
+	default:
+		return value
+	}
+	return &masked
+}
+
+// sum returns the keyed hash of a value.
+func (r *Rules) sum(value string) []byte {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// digest returns the keyed hash of a value in hex.
+func (r *Rules) digest(value string) string {
+	return hex.EncodeToString(r.sum(value))
+}
+
+// hexOfLength returns n hex digits derived from value.
+func (r *Rules) hexOfLength(value string, n int) string {
+	var b strings.Builder
+	for block := 0; b.Len() < n; block++ {
+		b.WriteString(r.digest(fmt.Sprintf("%d:%s", block, value)))
+	}
+	return b.String()[:n]
+}
+
+// phone replaces the digits of a phone number, keeping its shape and any
+// leading "+".
+func (r *Rules) phone(value string) string {
+	digits := r.hexOfLength("phone:"+value, 2*len(value))
+	out := []byte(value)
+	for i, c := range out {
+		if c >= '0' && c <= '9' {
+			d, _ := hex.DecodeString(digits[2*i : 2*i+2])
+			out[i] = '0' + d[0]%10
+		}
+	}
+	return string(out)
+}
+
+var firstNames = []string{
+	"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie",
+	"Avery", "Quinn", "Robin", "Drew", "Charlie", "Frankie", "Hayden", "Kai",
+}
+
+var lastNames = []string{
+	"Smith", "Garcia", "Chen", "Okafor", "Novak", "Silva", "Kim", "Müller",
+	"Rossi", "Dubois", "Nakamura", "Larsen", "Haddad", "Kowalski", "Reyes", "Walker",
+}
@@ -0,0 +1,146 @@
+package dump
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testRules = `
+seed: test
+exclude: [auth.sessions]
+tables:
+  auth.users:
+    email: email
+    phone: phone
+    encrypted_password: null
+    raw_user_meta_data: {set: "{}"}
+    confirmation_token: token
+  profiles:
+    full_name: name
+    bio: redact
+    username: keep
+`
+
+func strp(s string) *string { return &s }
+
+func TestParseRules(t *testing.T) {
+	r, err := ParseRules([]byte(testRules))
+	if err != nil {
+		t.Fatalf("ParseRules() failed: %v", err)
+	}
+	if r.Exclude[0] != "auth.sessions" {
+		t.Errorf("Exclude = %v", r.Exclude)
+	}
+	users := r.Tables["auth.users"]
+	if users["encrypted_password"].Name != StrategyNull {
+		t.Errorf("encrypted_password = %+v, want null", users["encrypted_password"])
+	}
+	if s := users["raw_user_meta_data"]; s.Name != StrategySet || *s.Value != "{}" {
+		t.Errorf("raw_user_meta_data = %+v, want set {}", s)
+	}
+	if _, ok := r.Tables["public.profiles"]; !ok {
+		t.Error("unqualified table should be in public")
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"unknown strategy": "tables:\n  users:\n    email: scramble\n",
+		"bad mapping":      "tables:\n  users:\n    email: {replace: x}\n",
+		"duplicate table":  "tables:\n  users:\n    email: email\n  public.users:\n    phone: phone\n",
+	} {
+		if _, err := ParseRules([]byte(yaml)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	r, err := ParseRules([]byte(testRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask := func(strategy string, value *string) *string {
+		return r.mask(&Strategy{Name: strategy}, value)
+	}
+
+	email := mask(StrategyEmail, strp("alice@example.com"))
+	if !regexp.MustCompile(`^user_[0-9a-f]{12}@example\.invalid$`).MatchString(*email) {
+		t.Errorf("email = %q", *email)
+	}
+	if again := mask(StrategyEmail, strp("alice@example.com")); *again != *email {
+		t.Error("the same value should mask to the same fake")
+	}
+	if other := mask(StrategyEmail, strp("bob@example.com")); *other == *email {
+		t.Error("different values should mask to different fakes")
+	}
+
+	phone := mask(StrategyPhone, strp("+1 (555) 123-4567"))
+	if !regexp.MustCompile(`^\+\d \(\d{3}\) \d{3}-\d{4}$`).MatchString(*phone) || *phone == "+1 (555) 123-4567" {
+		t.Errorf("phone = %q, want the same shape with other digits", *phone)
+	}
+
+	token := mask(StrategyToken, strp(strings.Repeat("a", 100)))
+	if len(*token) != 100 || !regexp.MustCompile(`^[0-9a-f]+$`).MatchString(*token) {
+		t.Errorf("token = %q, want 100 hex digits", *token)
+	}
+
+	if name := mask(StrategyName, strp("Alice Liddell")); !strings.Contains(*name, " ") {
+		t.Errorf("name = %q", *name)
+	}
+	if v := mask(StrategyRedact, strp("secret")); *v != "[redacted]" {
+		t.Errorf("redact = %q", *v)
+	}
+	if v := mask(StrategyNull, strp("secret")); v != nil {
+		t.Errorf("null = %q", *v)
+	}
+	if v := mask(StrategyEmail, nil); v != nil {
+		t.Error("NULL should stay NULL")
+	}
+	if v := r.mask(&Strategy{Name: StrategySet, Value: strp("{}")}, nil); v == nil || *v != "{}" {
+		t.Error("set should replace NULL too")
+	}
+}
+
+func TestMask_Seed(t *testing.T) {
+	a, _ := ParseRules([]byte("seed: one"))
+	b, _ := ParseRules([]byte("seed: one"))
+	c, _ := ParseRules([]byte("seed: two"))
+	s := &Strategy{Name: StrategyHash}
+	if *a.mask(s, strp("x")) != *b.mask(s, strp("x")) {
+		t.Error("the same seed should give the same fakes")
+	}
+	if *a.mask(s, strp("x")) == *c.mask(s, strp("x")) {
+		t.Error("different seeds should give different fakes")
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	r, _ := ParseRules([]byte("tables:\n  profiles:\n    full_nam: name\n  auth.users:\n    email: email\n"))
+	tables := []*table{
+		{name: "public.profiles", columns: []string{"id", "full_name"}},
+		{name: "auth.users", columns: []string{"id", "email"}},
+	}
+	err := checkRules(r, tables)
+	if err == nil || !strings.Contains(err.Error(), "public.profiles.full_nam") {
+		t.Errorf("checkRules() = %v, want an error naming public.profiles.full_nam", err)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		value *string
+		want  string
+	}{
+		{nil, "NULL"},
+		{strp("plain"), "'plain'"},
+		{strp("it's"), "'it''s'"},
+		{strp(`back\slash`), `'back\slash'`},
+	}
+	for _, tt := range tests {
+		if got := literal(tt.value); got != tt.want {
+			t.Errorf("literal() = %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package dump exports the rows of a supalite database as SQL, optionally
+// masking columns on the way out (see Rules), so production-shaped data
+// can be shared with developers.
+//
+// The output is data only: INSERT statements and sequence positions, to
+// be loaded into a database that already has the schema, such as another
+// supalite instance with the same migrations. It needs no client tools:
+// any SQL runner can load it.
+package dump
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSchemas are dumped when Options.Schemas is empty.
+var DefaultSchemas = []string{"public", "auth", "storage"}
+
+// DefaultExclude are tables never dumped: GoTrue and storage track their
+// own migrations, and the target database has them already.
+var DefaultExclude = []string{"auth.schema_migrations", "storage.migrations"}
+
+// DefaultBatchSize is the number of rows per INSERT statement.
+const DefaultBatchSize = 100
+
+// Options configures a dump.
+type Options struct {
+	Schemas   []string // Optional: schemas to dump (default: DefaultSchemas)
+	Exclude   []string // Optional: "schema.table" names to leave out, on top of DefaultExclude
+	Rules     *Rules   // Optional: columns to mask
+	BatchSize int      // Optional: rows per INSERT (default: 100)
+}
+
+// Result summarizes a dump.
+type Result struct {
+	Tables    int
+	Rows      int64
+	Sequences int
+	Masked    int // Columns masked
+}
+
+// table is a table to dump.
+type table struct {
+	name     string // schema.table
+	ident    pgx.Identifier
+	columns  []string
+	identity bool // Has a GENERATED ALWAYS identity column
+}
+
+// Dump writes the rows of every table in opts.Schemas to w. It reads in
+// one repeatable read transaction, so the dump is consistent even while
+// the database is in use.
+//
+// Every column a rule names must exist in a dumped table, so a typo in
+// the rules fails the dump instead of leaking the column.
+func Dump(ctx context.Context, conn *pgx.Conn, w io.Writer, opts Options) (*Result, error) {
+	if len(opts.Schemas) == 0 {
+		opts.Schemas = DefaultSchemas
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	exclude := append(slices.Clone(DefaultExclude), opts.Exclude...)
+	if opts.Rules != nil {
+		exclude = append(exclude, opts.Rules.Exclude...)
+	}
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tables, err := listTables(ctx, tx, opts.Schemas, exclude)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Rules != nil {
+		if err := checkRules(opts.Rules, tables); err != nil {
+			return nil, err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	result := &Result{}
+
+	fmt.Fprintf(bw, "-- supalite data dump\n-- Created: %s\n-- Schemas: %s\n", time.Now().UTC().Format(time.RFC3339), strings.Join(opts.Schemas, ", "))
+	if opts.Rules != nil {
+		bw.WriteString("-- Anonymized: masked columns don't hold their original values\n")
+	}
+	// Triggers and foreign keys are skipped while loading, so tables can be
+	// loaded in any order and triggers don't fire twice
+	bw.WriteString("\nSET standard_conforming_strings = on;\nSET session_replication_role = replica;\n\nBEGIN;\n")
+
+	for _, t := range tables {
+		var strategies []*Strategy
+		if opts.Rules != nil {
+			strategies = opts.Rules.strategies(t.name, t.columns)
+			for _, s := range strategies {
+				if s != nil {
+					result.Masked++
+				}
+			}
+		}
+		rows, err := dumpTable(ctx, tx, bw, t, opts, strategies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dump %s: %w", t.name, err)
+		}
+		result.Tables++
+		result.Rows += rows
+	}
+
+	sequences, err := dumpSequences(ctx, tx, bw, opts.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	result.Sequences = sequences
+
+	bw.WriteString("\nCOMMIT;\n\nRESET session_replication_role;\n")
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// listTables returns the ordinary tables of the schemas, less those
+// excluded and those belonging to extensions.
+func listTables(ctx context.Context, tx pgx.Tx, schemas, exclude []string) ([]*table, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT n.nspname, c.relname,
+		       array_agg(a.attname::text ORDER BY a.attnum) FILTER (WHERE a.attname IS NOT NULL),
+		       bool_or(a.attidentity = 'a')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0
+		     AND NOT a.attisdropped AND a.attgenerated = ''
+		WHERE c.relkind = 'r' AND n.nspname = ANY($1)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM pg_depend d
+		      WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype = 'e')
+		GROUP BY n.nspname, c.relname
+		ORDER BY n.nspname, c.relname`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []*table
+	for rows.Next() {
+		var schema, name string
+		var columns []string
+		var identity *bool
+		if err := rows.Scan(&schema, &name, &columns, &identity); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		qualified := schema + "." + name
+		if slices.Contains(exclude, qualified) || len(columns) == 0 {
+			continue
+		}
+		tables = append(tables, &table{
+			name:     qualified,
+			ident:    pgx.Identifier{schema, name},
+			columns:  columns,
+			identity: identity != nil && *identity,
+		})
+	}
+	return tables, rows.Err()
+}
+
+// checkRules reports rules naming a table or column that isn't dumped.
+func checkRules(r *Rules, tables []*table) error {
+	byName := make(map[string]*table, len(tables))
+	for _, t := range tables {
+		byName[t.name] = t
+	}
+	var missing []string
+	for name, columns := range r.Tables {
+		t, ok := byName[name]
+		for column := range columns {
+			if !ok || !slices.Contains(t.columns, column) {
+				missing = append(missing, name+"."+column)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("anonymization rules name columns that are not dumped: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// dumpTable writes the rows of t as INSERT statements of up to
+// opts.BatchSize rows, masking columns with a strategy.
+func dumpTable(ctx context.Context, tx pgx.Tx, w *bufio.Writer, t *table, opts Options, strategies []*Strategy) (int64, error) {
+	selects := make([]string, len(t.columns))
+	quoted := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+		selects[i] = quoted[i] + "::text"
+	}
+
+	rows, err := tx.Query(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), t.ident.Sanitize()))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s)", t.ident.Sanitize(), strings.Join(quoted, ", "))
+	if t.identity {
+		insert += " OVERRIDING SYSTEM VALUE"
+	}
+
+	values := make([]*string, len(t.columns))
+	dest := make([]any, len(t.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		if count%int64(opts.BatchSize) == 0 {
+			if count > 0 {
+				w.WriteString(";\n")
+			} else {
+				fmt.Fprintf(w, "\n-- %s\n", t.name)
+			}
+			w.WriteString(insert)
+			w.WriteString(" VALUES\n(")
+		} else {
+			w.WriteString(",\n(")
+		}
+		for i, value := range values {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			if strategies != nil && strategies[i] != nil {
+				value = opts.Rules.mask(strategies[i], value)
+			}
+			w.WriteString(literal(value))
+		}
+		w.WriteString(")")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		w.WriteString(";\n")
+	}
+	return count, nil
+}
+
+// dumpSequences writes the position of every sequence in the schemas, so
+// inserts after a load don't collide with loaded rows.
+func dumpSequences(ctx context.Context, tx pgx.Tx, w *bufio.Writer, schemas []string) (int, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT schemaname, sequencename, last_value
+		FROM pg_sequences
+		WHERE schemaname = ANY($1) AND last_value IS NOT NULL
+		ORDER BY schemaname, sequencename`, schemas)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sequences: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var schema, name string
+		var lastValue int64
+		if err := rows.Scan(&schema, &name, &lastValue); err != nil {
+			return 0, fmt.Errorf("failed to list sequences: %w", err)
+		}
+		if count == 0 {
+			w.WriteString("\n-- Sequences\n")
+		}
+		seq := pgx.Identifier{schema, name}.Sanitize()
+		fmt.Fprintf(w, "SELECT pg_catalog.setval(%s, %d, true);\n", literal(&seq), lastValue)
+		count++
+	}
+	return count, rows.Err()
+}
+
+// literal quotes a value in its text form as an SQL string literal, which
+// Postgres converts to the column's type. nil is NULL.
+func literal(value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(*value, "'", "''") + "'"
+}
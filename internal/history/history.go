@@ -0,0 +1,364 @@
+// Package history records row-level change history (an audit trail) for
+// the tables it is enabled on.
+//
+// Tracking is opt-in per table. audit.enable_tracking adds triggers that
+// write each inserted, updated, and deleted row, old and new, to
+// audit.record_version, as Supabase's supa_audit extension does:
+//
+//	SELECT audit.enable_tracking('public.todos');
+//
+// A Worker creates the audit schema and deletes versions older than the
+// retention period. Versions reads a row's history for the admin API, the
+// dashboard, and the CLI.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	DefaultInterval = time.Hour // How often old versions are pruned
+	DefaultLimit    = 100       // Versions returned when no limit is given
+	MaxLimit        = 1000      // Most versions returned at once
+)
+
+var (
+	// ErrTableNotFound is returned for tables that don't exist.
+	ErrTableNotFound = errors.New("table not found")
+
+	// ErrInvalidKey is returned when a row key doesn't name exactly the
+	// table's primary key columns.
+	ErrInvalidKey = errors.New("invalid row key")
+)
+
+// PostgresAcquirer lends the worker pooled connections, on which it
+// installs the audit schema, enables tracking, and prunes old versions.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Config holds the configuration for the worker.
+type Config struct {
+	Database  PostgresAcquirer
+	Tables    []string      // Optional: tables to start tracking
+	Retention time.Duration // Optional: how long versions are kept (default: forever)
+	Interval  time.Duration // Optional: how often old versions are pruned
+}
+
+// Worker creates the audit schema and prunes old versions.
+type Worker struct {
+	config Config
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Table is a tracked table.
+type Table struct {
+	Schema   string     `json:"schema"`
+	Name     string     `json:"name"`
+	Versions int64      `json:"versions"`
+	Oldest   *time.Time `json:"oldest,omitempty"`
+	Newest   *time.Time `json:"newest,omitempty"`
+}
+
+// Version is a row of audit.record_version: one change to one row, or a
+// TRUNCATE of the table.
+type Version struct {
+	ID          int64           `json:"id"`
+	Op          string          `json:"op"` // INSERT, UPDATE, DELETE, or TRUNCATE
+	Time        time.Time       `json:"ts"`
+	Schema      string          `json:"table_schema"`
+	Table       string          `json:"table_name"`
+	RecordID    *string         `json:"record_id"`
+	OldRecordID *string         `json:"old_record_id"`
+	Record      json.RawMessage `json:"record"`
+	OldRecord   json.RawMessage `json:"old_record"`
+	Role        *string         `json:"auth_role"`
+	UserID      *string         `json:"auth_uid"`
+}
+
+// Query selects versions of a table, newest first.
+type Query struct {
+	Table  string            // Table name, optionally schema-qualified
+	Key    map[string]string // Optional: primary key values of one row
+	Limit  int               // Optional: most versions returned (default: 100)
+	Before int64             // Optional: only versions with a lower id, for paging
+}
+
+// ParseQuery reads a query from URL parameters: limit and before, with
+// every other parameter a primary key column of the row, e.g.
+// ?id=42&limit=20.
+func ParseQuery(table string, params url.Values) (Query, error) {
+	q := Query{Table: table}
+	for name, values := range params {
+		value := values[len(values)-1]
+		switch name {
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return q, fmt.Errorf("invalid limit %q", value)
+			}
+			q.Limit = limit
+		case "before":
+			before, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || before <= 0 {
+				return q, fmt.Errorf("invalid before %q", value)
+			}
+			q.Before = before
+		default:
+			if q.Key == nil {
+				q.Key = map[string]string{}
+			}
+			q.Key[name] = value
+		}
+	}
+	return q, nil
+}
+
+// NewWorker creates a new worker.
+func NewWorker(cfg Config) *Worker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	return &Worker{config: cfg}
+}
+
+// Start creates the audit schema, enables tracking on the configured
+// tables, and begins pruning if a retention period is set. Tables that
+// can't be tracked (for instance because they don't exist yet) are
+// logged and skipped.
+func (w *Worker) Start(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = Install(ctx, conn.Conn())
+	if err == nil {
+		for _, table := range w.config.Tables {
+			if _, err := Enable(ctx, conn.Conn(), table); err != nil {
+				log.Warn("failed to track change history", "table", table, "error", err)
+			}
+		}
+	}
+	conn.Release()
+	if err != nil {
+		return err
+	}
+
+	if w.config.Retention <= 0 {
+		return nil
+	}
+
+	// The worker outlives the startup context
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(runCtx)
+	}()
+
+	return nil
+}
+
+// Stop stops pruning.
+func (w *Worker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.prune(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("failed to prune change history", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) prune(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	deleted, err := Prune(ctx, conn.Conn(), w.config.Retention, "")
+	if err == nil && deleted > 0 {
+		log.Info("pruned change history", "versions", deleted)
+	}
+	return err
+}
+
+// Install creates the audit schema. It is safe to run more than once.
+func Install(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("failed to create audit schema: %w", err)
+	}
+	return nil
+}
+
+// Enable starts tracking changes to a table, and returns its name as
+// Postgres prints it. Names are resolved the way SQL resolves them, so
+// quoted names ("My Table") work too.
+func Enable(ctx context.Context, conn *pgx.Conn, table string) (string, error) {
+	var name string
+	err := conn.QueryRow(ctx, "SELECT audit.enable_tracking($1::regclass), $1::regclass::text", table).Scan(nil, &name)
+	return name, tableError(table, err)
+}
+
+// Disable stops tracking changes to a table. Its recorded history is kept.
+func Disable(ctx context.Context, conn *pgx.Conn, table string) (string, error) {
+	var name string
+	err := conn.QueryRow(ctx, "SELECT audit.disable_tracking($1::regclass), $1::regclass::text", table).Scan(nil, &name)
+	return name, tableError(table, err)
+}
+
+// Tracked lists the tracked tables.
+func Tracked(ctx context.Context, conn *pgx.Conn) ([]Table, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT n.nspname, c.relname, v.versions, v.oldest, v.newest
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL (
+			SELECT count(*) AS versions, min(ts) AS oldest, max(ts) AS newest
+			FROM audit.record_version WHERE table_oid = c.oid
+		) v
+		WHERE t.tgname = 'audit_i_u_d'
+		  AND t.tgfoid = 'audit.insert_update_delete_trigger'::regproc
+		ORDER BY n.nspname, c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracked tables: %w", err)
+	}
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Table, error) {
+		var t Table
+		err := row.Scan(&t.Schema, &t.Name, &t.Versions, &t.Oldest, &t.Newest)
+		return t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracked tables: %w", err)
+	}
+	return tables, nil
+}
+
+// Versions returns versions of a table, newest first. With a key, only
+// the versions of that row are returned, along with TRUNCATEs of the
+// table. Key values are compared in their JSON text form: 42 for numbers,
+// true for booleans, and strings as they are.
+func Versions(ctx context.Context, conn *pgx.Conn, q Query) ([]Version, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	q.Limit = min(q.Limit, MaxLimit)
+
+	var recordID *string
+	if len(q.Key) > 0 {
+		var columns []string
+		err := conn.QueryRow(ctx, "SELECT audit.primary_key_columns($1::regclass)", q.Table).Scan(&columns)
+		if err != nil {
+			return nil, tableError(q.Table, err)
+		}
+		if err := checkKey(q.Key, columns); err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(q.Key)
+		err = conn.QueryRow(ctx, "SELECT audit.to_record_id($1::regclass, $2, $3::jsonb)::text", q.Table, columns, string(key)).Scan(&recordID)
+		if err != nil {
+			return nil, tableError(q.Table, err)
+		}
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, op, ts, table_schema, table_name, record_id::text, old_record_id::text,
+		       record, old_record, auth_role, auth_uid
+		FROM audit.record_version
+		WHERE table_oid = $1::regclass
+		  AND ($2::uuid IS NULL OR record_id = $2 OR old_record_id = $2 OR op = 'TRUNCATE')
+		  AND ($3::bigint = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4`, q.Table, recordID, q.Before, q.Limit)
+	if err != nil {
+		return nil, tableError(q.Table, err)
+	}
+	versions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Version, error) {
+		var v Version
+		err := row.Scan(&v.ID, &v.Op, &v.Time, &v.Schema, &v.Table, &v.RecordID, &v.OldRecordID,
+			&v.Record, &v.OldRecord, &v.Role, &v.UserID)
+		return v, err
+	})
+	if err != nil {
+		return nil, tableError(q.Table, err)
+	}
+	return versions, nil
+}
+
+// Prune deletes versions older than the given age, of one table or, if
+// table is empty, of every table. It returns the number deleted.
+func Prune(ctx context.Context, conn *pgx.Conn, olderThan time.Duration, table string) (int64, error) {
+	interval := fmt.Sprintf("%d milliseconds", olderThan.Milliseconds())
+	var tag pgconn.CommandTag
+	var err error
+	if table == "" {
+		tag, err = conn.Exec(ctx, `DELETE FROM audit.record_version WHERE ts < now() - $1::interval`, interval)
+	} else {
+		tag, err = conn.Exec(ctx, `DELETE FROM audit.record_version WHERE ts < now() - $1::interval AND table_oid = $2::regclass`, interval, table)
+	}
+	if err != nil {
+		return 0, tableError(table, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// checkKey reports a key that doesn't name exactly the primary key
+// columns, since a partial key would silently match nothing.
+func checkKey(key map[string]string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("%w: the table has no primary key", ErrInvalidKey)
+	}
+	for _, column := range columns {
+		if _, ok := key[column]; !ok {
+			return fmt.Errorf("%w: missing primary key column %q", ErrInvalidKey, column)
+		}
+	}
+	for name := range key {
+		if !slices.Contains(columns, name) {
+			return fmt.Errorf("%w: %q is not a primary key column", ErrInvalidKey, name)
+		}
+	}
+	return nil
+}
+
+// tableError turns Postgres's undefined table error into ErrTableNotFound.
+func tableError(table string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, table)
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/pg"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery("todos", url.Values{"id": {"42"}, "tenant": {"acme"}, "limit": {"20"}, "before": {"900"}})
+	if err != nil {
+		t.Fatalf("ParseQuery() failed: %v", err)
+	}
+	if q.Table != "todos" || q.Limit != 20 || q.Before != 900 {
+		t.Errorf("ParseQuery() = %+v", q)
+	}
+	if len(q.Key) != 2 || q.Key["id"] != "42" || q.Key["tenant"] != "acme" {
+		t.Errorf("Key = %v, want id and tenant", q.Key)
+	}
+
+	q, err = ParseQuery("todos", url.Values{})
+	if err != nil || q.Key != nil || q.Limit != 0 {
+		t.Errorf("ParseQuery() without parameters = %+v, %v", q, err)
+	}
+
+	for _, params := range []url.Values{{"limit": {"0"}}, {"limit": {"many"}}, {"before": {"-1"}}} {
+		if _, err := ParseQuery("todos", params); err == nil {
+			t.Errorf("ParseQuery(%v): expected error", params)
+		}
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key     map[string]string
+		columns []string
+		valid   bool
+	}{
+		{map[string]string{"id": "1"}, []string{"id"}, true},
+		{map[string]string{"id": "1", "tenant": "a"}, []string{"tenant", "id"}, true},
+		{map[string]string{"id": "1"}, []string{"tenant", "id"}, false},
+		{map[string]string{"id": "1", "title": "x"}, []string{"id"}, false},
+		{map[string]string{"id": "1"}, nil, false},
+	}
+	for _, tt := range tests {
+		err := checkKey(tt.key, tt.columns)
+		if (err == nil) != tt.valid {
+			t.Errorf("checkKey(%v, %v) = %v, want valid %v", tt.key, tt.columns, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("checkKey() error %v is not ErrInvalidKey", err)
+		}
+	}
+}
+
+func TestRowsAsOf(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15441,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-history",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if err := Install(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	exec := func(sql string) {
+		t.Helper()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	now := func() time.Time {
+		t.Helper()
+		var ts time.Time
+		if err := conn.QueryRow(ctx, "SELECT clock_timestamp()").Scan(&ts); err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	rowsAsOf := func(ts time.Time) map[int]string {
+		t.Helper()
+		rows, err := conn.Query(ctx, "SELECT (rec->>'id')::int, rec->>'title' FROM audit.rows_as_of('todos', $1) AS rec", ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles := map[int]string{}
+		for rows.Next() {
+			var id int
+			var title string
+			if err := rows.Scan(&id, &title); err != nil {
+				t.Fatal(err)
+			}
+			titles[id] = title
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return titles
+	}
+
+	exec("CREATE TABLE todos (id int PRIMARY KEY, title text)")
+	exec("INSERT INTO todos VALUES (1, 'a')") // before tracking: no versions
+	exec("SELECT audit.enable_tracking('todos')")
+	beforeInsert := now()
+	exec("INSERT INTO todos VALUES (2, 'b')")
+	afterInsert := now()
+	exec("UPDATE todos SET title = 'a2' WHERE id = 1")
+	exec("DELETE FROM todos WHERE id = 2")
+	exec("INSERT INTO todos VALUES (3, 'c')")
+	exec("UPDATE todos SET id = 4 WHERE id = 3") // a new primary key
+	afterChanges := now()
+	exec("TRUNCATE todos")
+	exec("INSERT INTO todos VALUES (5, 'e')")
+
+	tests := []struct {
+		at   time.Time
+		want map[int]string
+	}{
+		{beforeInsert, map[int]string{1: "a"}},
+		{afterInsert, map[int]string{1: "a", 2: "b"}},
+		{afterChanges, map[int]string{1: "a2", 4: "c"}},
+		{now(), map[int]string{5: "e"}},
+	}
+	for i, tt := range tests {
+		if got := rowsAsOf(tt.at); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rows_as_of(#%d) = %v, want %v", i, got, tt.want)
+		}
+	}
+
+	exec("CREATE TABLE untracked (id int PRIMARY KEY)")
+	if _, err := conn.Exec(ctx, "SELECT audit.rows_as_of('untracked', now())"); err == nil || !strings.Contains(err.Error(), "not tracked") {
+		t.Errorf("rows_as_of(untracked) = %v, want a not tracked error", err)
+	}
+}
@@ -0,0 +1,199 @@
+package history
+
+// schemaSQL creates the audit schema with the same table and functions as
+// Supabase's supa_audit extension, so trail queries written for it run
+// unchanged. Each row version records the role and user (the JWT "sub") of
+// the request that made the change; both are NULL for direct database
+// access.
+//
+// A version's record_id identifies the row by its table and primary key,
+// so a row's history can be found after its other columns change. Tables
+// without a primary key are tracked, but their versions have no record_id.
+//
+// The trigger functions are SECURITY DEFINER so writes by API roles are
+// recorded without those roles being able to read or rewrite the trail.
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS audit;
+
+CREATE TABLE IF NOT EXISTS audit.record_version (
+	id bigserial PRIMARY KEY,
+	record_id uuid,
+	old_record_id uuid,
+	op text NOT NULL CHECK (op IN ('INSERT', 'UPDATE', 'DELETE', 'TRUNCATE')),
+	ts timestamptz NOT NULL DEFAULT now(),
+	table_oid oid NOT NULL,
+	table_schema name NOT NULL,
+	table_name name NOT NULL,
+	record jsonb,
+	old_record jsonb,
+	auth_role text,
+	auth_uid text
+);
+
+CREATE INDEX IF NOT EXISTS record_version_record_id ON audit.record_version (record_id) WHERE record_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS record_version_old_record_id ON audit.record_version (old_record_id) WHERE old_record_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS record_version_table_oid ON audit.record_version (table_oid, id);
+CREATE INDEX IF NOT EXISTS record_version_ts ON audit.record_version USING brin (ts);
+
+-- The primary key columns of a table, in key order
+CREATE OR REPLACE FUNCTION audit.primary_key_columns(entity_oid oid) RETURNS text[]
+LANGUAGE sql STABLE SET search_path = '' AS $$
+	SELECT coalesce(array_agg(a.attname::text ORDER BY array_position(i.indkey::int2[], a.attnum)), '{}')
+	FROM pg_catalog.pg_index i
+	JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+	WHERE i.indrelid = entity_oid AND i.indisprimary
+$$;
+
+-- Identifies a row by table and primary key values in their JSON text
+-- form, so the id can be computed from a key given in a URL
+CREATE OR REPLACE FUNCTION audit.to_record_id(entity_oid oid, pkey_cols text[], rec jsonb) RETURNS uuid
+LANGUAGE sql IMMUTABLE SET search_path = '' AS $$
+	SELECT CASE
+		WHEN rec IS NULL OR cardinality(pkey_cols) = 0 THEN NULL
+		ELSE pg_catalog.md5(pg_catalog.jsonb_build_array(
+			entity_oid::text,
+			(SELECT array_agg(rec ->> k.col ORDER BY k.ord) FROM unnest(pkey_cols) WITH ORDINALITY AS k(col, ord))
+		)::text)::uuid
+	END
+$$;
+
+-- A claim of the current request, read the way auth.uid() reads it
+CREATE OR REPLACE FUNCTION audit.request_claim(claim text) RETURNS text
+LANGUAGE sql STABLE SET search_path = '' AS $$
+	SELECT coalesce(
+		nullif(current_setting('request.jwt.claim.' || claim, true), ''),
+		(nullif(current_setting('request.jwt.claims', true), '')::jsonb ->> claim)
+	)
+$$;
+
+CREATE OR REPLACE FUNCTION audit.insert_update_delete_trigger() RETURNS trigger
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = '' AS $$
+DECLARE
+	pkey_cols text[] := audit.primary_key_columns(TG_RELID);
+	rec jsonb;
+	old_rec jsonb;
+BEGIN
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		rec := to_jsonb(NEW);
+	END IF;
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		old_rec := to_jsonb(OLD);
+	END IF;
+
+	INSERT INTO audit.record_version (
+		record_id, old_record_id, op, table_oid, table_schema, table_name,
+		record, old_record, auth_role, auth_uid
+	) VALUES (
+		audit.to_record_id(TG_RELID, pkey_cols, rec),
+		audit.to_record_id(TG_RELID, pkey_cols, old_rec),
+		TG_OP, TG_RELID, TG_TABLE_SCHEMA, TG_TABLE_NAME,
+		rec, old_rec, audit.request_claim('role'), audit.request_claim('sub')
+	);
+	RETURN coalesce(NEW, OLD);
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit.truncate_trigger() RETURNS trigger
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = '' AS $$
+BEGIN
+	INSERT INTO audit.record_version (op, table_oid, table_schema, table_name, auth_role, auth_uid)
+	VALUES (TG_OP, TG_RELID, TG_TABLE_SCHEMA, TG_TABLE_NAME, audit.request_claim('role'), audit.request_claim('sub'));
+	RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit.enable_tracking(target regclass) RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+	EXECUTE format('CREATE OR REPLACE TRIGGER audit_i_u_d
+		AFTER INSERT OR UPDATE OR DELETE ON %s
+		FOR EACH ROW EXECUTE FUNCTION audit.insert_update_delete_trigger()', target);
+	EXECUTE format('CREATE OR REPLACE TRIGGER audit_t
+		AFTER TRUNCATE ON %s
+		FOR EACH STATEMENT EXECUTE FUNCTION audit.truncate_trigger()', target);
+END
+$$;
+
+CREATE OR REPLACE FUNCTION audit.disable_tracking(target regclass) RETURNS void
+LANGUAGE plpgsql AS $$
+BEGIN
+	EXECUTE format('DROP TRIGGER IF EXISTS audit_i_u_d ON %s', target);
+	EXECUTE format('DROP TRIGGER IF EXISTS audit_t ON %s', target);
+END
+$$;
+
+-- The rows of a tracked table as they were at a point in time, as JSON,
+-- rebuilt from the history: each row recorded as changed is taken from the
+-- last version before then or, failing that, from the old record of the
+-- first version after; the rest are the table's current rows. A TRUNCATE
+-- records no rows, so the rows it removed that had no versions since
+-- tracking began are missing from earlier points in time.
+CREATE OR REPLACE FUNCTION audit.rows_as_of(target regclass, as_of timestamptz) RETURNS SETOF jsonb
+LANGUAGE plpgsql STABLE SECURITY DEFINER SET search_path = '' AS $$
+DECLARE
+	pkey_cols text[] := audit.primary_key_columns(target);
+BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_catalog.pg_trigger
+		WHERE tgrelid = target AND tgname = 'audit_i_u_d'
+		  AND tgfoid = 'audit.insert_update_delete_trigger'::regproc
+	) THEN
+		RAISE EXCEPTION 'changes to % are not tracked', target
+			USING ERRCODE = 'object_not_in_prerequisite_state',
+			      HINT = format('Track them with SELECT audit.enable_tracking(%L)', target::text);
+	END IF;
+	IF cardinality(pkey_cols) = 0 THEN
+		RAISE EXCEPTION '% has no primary key to tell its rows apart by', target
+			USING ERRCODE = 'object_not_in_prerequisite_state';
+	END IF;
+
+	-- Each version is an event for the row ids it touches, with the row
+	-- before and after it (NULL when the row didn't exist). Versions of one
+	-- transaction share a timestamp, so ids order them.
+	RETURN QUERY EXECUTE format($q$
+		WITH events AS (
+			SELECT record_id AS key, id, ts,
+			       CASE WHEN old_record_id = record_id THEN old_record END AS before,
+			       record AS after
+			FROM audit.record_version
+			WHERE table_oid = $1 AND record_id IS NOT NULL
+			UNION ALL
+			SELECT old_record_id, id, ts, old_record, NULL
+			FROM audit.record_version
+			WHERE table_oid = $1 AND old_record_id IS NOT NULL AND old_record_id IS DISTINCT FROM record_id
+		),
+		state AS (
+			SELECT DISTINCT ON (key) key, id, ts <= $2 AS past,
+			       CASE WHEN ts <= $2 THEN after ELSE before END AS rec
+			FROM events
+			ORDER BY key, ts <= $2 DESC, CASE WHEN ts <= $2 THEN -id ELSE id END
+		)
+		SELECT s.rec FROM state s
+		WHERE s.rec IS NOT NULL
+		  AND NOT (s.past AND EXISTS (
+			SELECT 1 FROM audit.record_version t
+			WHERE t.table_oid = $1 AND t.op = 'TRUNCATE' AND t.id > s.id AND t.ts <= $2
+		  ))
+		UNION ALL
+		SELECT pg_catalog.to_jsonb(c) FROM %s c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM events e WHERE e.key = audit.to_record_id($1, $3, pg_catalog.to_jsonb(c))
+		)
+	$q$, target) USING target, as_of, pkey_cols;
+END
+$$;
+
+-- Schema changes are for the database owner, not API roles
+REVOKE ALL ON FUNCTION audit.enable_tracking(regclass), audit.disable_tracking(regclass) FROM PUBLIC;
+
+-- Past rows skip RLS, so only the service role reads them (REST ?as_of)
+REVOKE ALL ON FUNCTION audit.rows_as_of(regclass, timestamptz) FROM PUBLIC;
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'service_role') THEN
+		GRANT USAGE ON SCHEMA audit TO service_role;
+		GRANT EXECUTE ON FUNCTION audit.rows_as_of(regclass, timestamptz) TO service_role;
+	END IF;
+END;
+$$;
+`
@@ -0,0 +1,80 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"math/big"
+	"strings"
+)
+
+// Supabase's newer API keys are opaque strings instead of JWTs. They are
+// never sent past the API gateway: the gateway swaps them for the JWT of
+// their role, which is what supalite does too (see ResolveAPIKey).
+const (
+	// PublishableKeyPrefix starts a publishable key, the replacement for
+	// the anon key.
+	PublishableKeyPrefix = "sb_publishable_"
+
+	// SecretKeyPrefix starts a secret key, the replacement for the
+	// service_role key.
+	SecretKeyPrefix = "sb_secret_"
+)
+
+// opaqueKeyLength is the number of random characters after the prefix.
+const opaqueKeyLength = 32
+
+// IsOpaqueKey reports whether key is in the sb_publishable_/sb_secret_
+// format rather than a JWT.
+func IsOpaqueKey(key string) bool {
+	return strings.HasPrefix(key, PublishableKeyPrefix) || strings.HasPrefix(key, SecretKeyPrefix)
+}
+
+// GetPublishableKey returns the publishable key (sb_publishable_...), which
+// acts as the anon key.
+func (m *Manager) GetPublishableKey() string {
+	return m.publishableKey
+}
+
+// GetSecretKey returns the secret key (sb_secret_...), which acts as the
+// service_role key. Keep this key secret!
+func (m *Manager) GetSecretKey() string {
+	return m.secretKey
+}
+
+// ResolveAPIKey returns the JWT standing in for an opaque API key: the anon
+// key for the publishable key and the service_role key for the secret key.
+// Keys superseded by Regenerate without revoking resolve too. ok is false
+// for any other key.
+func (m *Manager) ResolveAPIKey(key string) (token string, ok bool) {
+	switch {
+	case m.publishableKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(m.publishableKey)) == 1:
+		return m.anonKey, true
+	case m.secretKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(m.secretKey)) == 1:
+		return m.serviceKey, true
+	}
+	return m.resolveSuperseded(key)
+}
+
+// generateOpaqueKeys creates random publishable and secret keys.
+func (m *Manager) generateOpaqueKeys() {
+	m.publishableKey = newOpaqueKey(PublishableKeyPrefix)
+	m.secretKey = newOpaqueKey(SecretKeyPrefix)
+}
+
+// deriveOpaqueKeys derives the publishable and secret keys from seed, for
+// managers whose keys are never persisted.
+func (m *Manager) deriveOpaqueKeys(seed string) {
+	m.publishableKey = PublishableKeyPrefix + DeriveSecret(seed, "publishable-key")[:opaqueKeyLength]
+	m.secretKey = SecretKeyPrefix + DeriveSecret(seed, "secret-key")[:opaqueKeyLength]
+}
+
+// newOpaqueKey returns prefix followed by random letters and digits.
+func newOpaqueKey(prefix string) string {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, opaqueKeyLength)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		b[i] = charset[n.Int64()]
+	}
+	return prefix + string(b)
+}
@@ -0,0 +1,102 @@
+package keys
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpaqueKeys(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+	publishable, secret := m.GetPublishableKey(), m.GetSecretKey()
+	if !strings.HasPrefix(publishable, PublishableKeyPrefix) || !strings.HasPrefix(secret, SecretKeyPrefix) {
+		t.Fatalf("keys %q, %q lack the sb_ prefixes", publishable, secret)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"publishable key", publishable, m.GetAnonKey(), true},
+		{"secret key", secret, m.GetServiceKey(), true},
+		{"unknown secret key", SecretKeyPrefix + "nope", "", false},
+		{"anon JWT", m.GetAnonKey(), "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.ResolveAPIKey(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ResolveAPIKey() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// Reloaded from keys.json, and kept across a rotation
+	m, err = NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() reload failed: %v", err)
+	}
+	if _, err := m.Rotate(0); err != nil {
+		t.Fatalf("Rotate() failed: %v", err)
+	}
+	if m.GetPublishableKey() != publishable || m.GetSecretKey() != secret {
+		t.Error("publishable and secret keys changed across reload and rotation")
+	}
+	if token, _ := m.ResolveAPIKey(secret); token != m.GetServiceKey() {
+		t.Error("secret key does not resolve to the re-minted service_role key")
+	}
+}
+
+func TestOpaqueKeys_Upgrade(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewManager(dir, ""); err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+
+	// Strip the keys, as in a keys.json written before they existed
+	path := filepath.Join(dir, "keys.json")
+	data, _ := os.ReadFile(path)
+	var stored StoredKeys
+	json.Unmarshal(data, &stored)
+	stored.PublishableKey, stored.SecretKey = "", ""
+	data, _ = json.Marshal(stored)
+	os.WriteFile(path, data, 0600)
+
+	m, err := NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+	if m.GetPublishableKey() == "" || m.GetSecretKey() == "" {
+		t.Fatal("keys were not generated for an old keys.json")
+	}
+	reloaded, _ := NewManager(dir, "")
+	if reloaded.GetSecretKey() != m.GetSecretKey() {
+		t.Error("generated keys were not saved to keys.json")
+	}
+}
+
+func TestOpaqueKeys_Derived(t *testing.T) {
+	a, _ := NewDeterministicManager("seed", "", "")
+	b, _ := NewDeterministicManager("seed", "", "")
+	if a.GetPublishableKey() != b.GetPublishableKey() || a.GetSecretKey() != b.GetSecretKey() {
+		t.Error("deterministic managers with the same seed issued different keys")
+	}
+
+	legacy, _ := NewManager(t.TempDir(), "legacy-secret")
+	again, _ := NewManager(t.TempDir(), "legacy-secret")
+	if legacy.GetSecretKey() == "" || legacy.GetSecretKey() != again.GetSecretKey() {
+		t.Error("legacy mode keys should be derived from the JWT secret")
+	}
+	if token, ok := legacy.ResolveAPIKey(legacy.GetPublishableKey()); !ok || token != legacy.GetAnonKey() {
+		t.Error("legacy publishable key does not resolve to the anon key")
+	}
+}
@@ -0,0 +1,120 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// DeterministicIssuedAt is the iat claim of API keys created by
+// NewDeterministicManager, so the keys don't depend on the wall clock.
+var DeterministicIssuedAt = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// NewDeterministicManager creates a key manager whose keys and API tokens
+// are derived entirely from seed.
+//
+// The same seed always yields the same signing key, project ref, anon key,
+// service_role key, and publishable and secret keys, which makes CI runs and snapshots reproducible.
+// Nothing is read from or written to keys.json.
+//
+// Parameters:
+//   - seed: Secret seed the keys are derived from (must not be empty)
+//   - projectRef: Optional project reference (empty = derived from seed)
+//   - jwtSecret: Optional JWT secret for legacy HS256 mode (empty = ES256 mode)
+//
+// Deterministic keys are only as secret as the seed; never use a
+// well-known seed outside of tests.
+func NewDeterministicManager(seed, projectRef, jwtSecret string) (*Manager, error) {
+	if seed == "" {
+		return nil, fmt.Errorf("deterministic seed not provided")
+	}
+
+	m := &Manager{
+		projectRef:    projectRef,
+		issuedAt:      DeterministicIssuedAt,
+		deterministic: true,
+	}
+	if m.projectRef == "" {
+		m.projectRef = deriveProjectRef(seed)
+	}
+	m.deriveOpaqueKeys(seed)
+
+	// Legacy mode: JWT_SECRET provided (HS256 is deterministic already)
+	if jwtSecret != "" {
+		m.useLegacy = true
+		m.jwtSecret = []byte(jwtSecret)
+		if err := m.generateLegacyTokens(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	privateKey, err := derivePrivateKey(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive private key: %w", err)
+	}
+	m.privateKey = privateKey
+	m.publicKey = &privateKey.PublicKey
+	m.keyID = KeyID
+
+	if m.anonKey, err = m.generateToken("anon"); err != nil {
+		return nil, fmt.Errorf("failed to generate anon token: %w", err)
+	}
+	if m.serviceKey, err = m.generateToken("service_role"); err != nil {
+		return nil, fmt.Errorf("failed to generate service token: %w", err)
+	}
+
+	return m, nil
+}
+
+// DeriveSecret derives a hex-encoded secret for the given purpose from seed.
+//
+// It is used for secrets outside the key manager (such as GoTrue's JWT
+// secret) that must also be stable in deterministic mode.
+func DeriveSecret(seed, purpose string) string {
+	sum := sha256.Sum256([]byte("supalite:" + purpose + ":" + seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// derivePrivateKey maps seed onto a valid P-256 private scalar in [1, N-1].
+func derivePrivateKey(seed string) (*ecdsa.PrivateKey, error) {
+	sum := sha256.Sum256([]byte("supalite:es256:" + seed))
+
+	n := new(big.Int).Sub(elliptic.P256().Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(sum[:])
+	d.Mod(d, n).Add(d, big.NewInt(1))
+
+	return ecdsa.ParseRawPrivateKey(elliptic.P256(), d.FillBytes(make([]byte, 32)))
+}
+
+// deriveProjectRef derives a 20-character project reference from seed.
+func deriveProjectRef(seed string) string {
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	sum := sha256.Sum256([]byte("supalite:ref:" + seed))
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = charset[int(sum[i])%len(charset)]
+	}
+	return string(b)
+}
+
+// deterministicSigner signs with RFC 6979 deterministic ECDSA, so the same
+// key and payload always produce the same signature.
+type deterministicSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s deterministicSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	// A nil random source selects RFC 6979
+	return s.key.Sign(nil, digest, opts)
+}
@@ -0,0 +1,99 @@
+package keys
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewDeterministicManager(t *testing.T) {
+	newManager := func(seed string) *Manager {
+		t.Helper()
+		m, err := NewDeterministicManager(seed, "", "")
+		if err != nil {
+			t.Fatalf("NewDeterministicManager(%q) failed: %v", seed, err)
+		}
+		return m
+	}
+	jwks := func(m *Manager) string {
+		t.Helper()
+		set, err := m.GetJWKS()
+		if err != nil {
+			t.Fatalf("GetJWKS() failed: %v", err)
+		}
+		data, err := json.Marshal(set)
+		if err != nil {
+			t.Fatalf("marshaling the JWKS failed: %v", err)
+		}
+		return string(data)
+	}
+
+	a, b := newManager("ci-seed"), newManager("ci-seed")
+	same := []struct {
+		name string
+		a, b string
+	}{
+		{"anon key", a.GetAnonKey(), b.GetAnonKey()},
+		{"service_role key", a.GetServiceKey(), b.GetServiceKey()},
+		{"publishable key", a.GetPublishableKey(), b.GetPublishableKey()},
+		{"secret key", a.GetSecretKey(), b.GetSecretKey()},
+		{"project ref", a.GetProjectRef(), b.GetProjectRef()},
+		{"JWKS", jwks(a), jwks(b)},
+	}
+	for _, tt := range same {
+		if tt.a != tt.b {
+			t.Errorf("same seed, different %s: %q and %q", tt.name, tt.a, tt.b)
+		}
+	}
+
+	c := newManager("other-seed")
+	different := []struct {
+		name string
+		a, c string
+	}{
+		{"anon key", a.GetAnonKey(), c.GetAnonKey()},
+		{"service_role key", a.GetServiceKey(), c.GetServiceKey()},
+		{"secret key", a.GetSecretKey(), c.GetSecretKey()},
+		{"project ref", a.GetProjectRef(), c.GetProjectRef()},
+		{"JWKS", jwks(a), jwks(c)},
+	}
+	for _, tt := range different {
+		if tt.a == tt.c {
+			t.Errorf("different seeds, same %s %q", tt.name, tt.a)
+		}
+	}
+	if _, err := c.VerifyToken(a.GetAnonKey()); err == nil {
+		t.Error("a key from another seed verified")
+	}
+
+	// User tokens with the same claims, times included, are byte-identical
+	now := time.Now().Unix()
+	claims := map[string]interface{}{
+		"sub":  "6f7d1c2e-0000-4000-8000-000000000001",
+		"role": "authenticated",
+		"iat":  now,
+		"exp":  now + 3600,
+	}
+	first, err := a.GenerateUserToken(claims, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUserToken() failed: %v", err)
+	}
+	second, err := b.GenerateUserToken(claims, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUserToken() failed: %v", err)
+	}
+	if first != second {
+		t.Errorf("same seed and claims, different user tokens:\n%s\n%s", first, second)
+	}
+	token, err := b.VerifyToken(first)
+	if err != nil {
+		t.Fatalf("VerifyToken() failed: %v", err)
+	}
+	if token.Subject() != claims["sub"] {
+		t.Errorf("sub = %q, want %q", token.Subject(), claims["sub"])
+	}
+
+	if _, err := NewDeterministicManager("", "", ""); err == nil {
+		t.Error("NewDeterministicManager() without a seed succeeded")
+	}
+}
@@ -0,0 +1,150 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrKeyRevoked is returned by VerifyToken for an API key revoked with
+// Regenerate.
+var ErrKeyRevoked = errors.New("API key has been revoked")
+
+// SupersededKey is an API key replaced by Regenerate without revoking it.
+// It keeps working: the JWT still verifies, and the opaque key still
+// resolves, to the role's current JWT.
+type SupersededKey struct {
+	Role         string    `json:"role"`                 // "anon" or "service_role"
+	TokenSHA256  string    `json:"token_sha256"`         // SHA-256 of the replaced JWT, for revoking it later
+	OpaqueKey    string    `json:"opaque_key,omitempty"` // Replaced publishable or secret key
+	SupersededAt time.Time `json:"superseded_at"`
+}
+
+// RevokedKey is an API key that no longer verifies although its signature
+// is valid. Only a hash of the JWT is kept.
+type RevokedKey struct {
+	Role        string    `json:"role"`
+	TokenSHA256 string    `json:"token_sha256"`
+	RevokedAt   time.Time `json:"revoked_at"`
+}
+
+// Regeneration reports the outcome of Regenerate.
+type Regeneration struct {
+	Role    string // Role whose keys were replaced
+	Revoked int    // Old keys revoked (0 unless revoke was set)
+}
+
+// Regenerate replaces the API keys of role ("anon" or "service_role"):
+// the JWT is re-minted with the active signing key and the matching
+// publishable or secret key is replaced. keys.json is updated.
+//
+// Without revoke the old keys keep working, so clients can move to the
+// new ones at their own pace. With revoke the old keys, and any earlier
+// ones of the role superseded without revoking, stop working at once:
+// VerifyToken rejects the JWTs with ErrKeyRevoked, and the opaque keys no
+// longer resolve.
+//
+// A running server reads keys.json at startup, so it must be restarted
+// to pick up the change.
+//
+// Returns an error in legacy HS256 mode and for deterministic keys, which
+// are derived from their secret or seed and never persisted.
+func (m *Manager) Regenerate(role string, revoke bool) (*Regeneration, error) {
+	if m.useLegacy {
+		return nil, fmt.Errorf("key regeneration is not available in legacy mode (JWT_SECRET)")
+	}
+	if m.deterministic {
+		return nil, fmt.Errorf("key regeneration is not available for deterministic keys")
+	}
+
+	var token, opaque *string
+	var prefix string
+	switch role {
+	case "anon":
+		token, opaque, prefix = &m.anonKey, &m.publishableKey, PublishableKeyPrefix
+	case "service_role":
+		token, opaque, prefix = &m.serviceKey, &m.secretKey, SecretKeyPrefix
+	default:
+		return nil, fmt.Errorf("unknown role %q (want anon or service_role)", role)
+	}
+
+	newToken, err := m.generateToken(role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s token: %w", role, err)
+	}
+
+	now := time.Now()
+	old := SupersededKey{Role: role, TokenSHA256: tokenHash(*token), OpaqueKey: *opaque, SupersededAt: now}
+	result := &Regeneration{Role: role}
+
+	if revoke {
+		kept := m.superseded[:0:0]
+		for _, k := range append(m.superseded, old) {
+			if k.Role != role {
+				kept = append(kept, k)
+				continue
+			}
+			m.revoked = append(m.revoked, RevokedKey{Role: role, TokenSHA256: k.TokenSHA256, RevokedAt: now})
+			result.Revoked++
+		}
+		m.superseded = kept
+	} else {
+		m.superseded = append(m.superseded, old)
+	}
+	m.revokedHashes = revokedHashes(m.revoked)
+
+	*token = newToken
+	*opaque = newOpaqueKey(prefix)
+
+	if err := m.saveKeys(); err != nil {
+		return nil, fmt.Errorf("failed to save keys: %w", err)
+	}
+	return result, nil
+}
+
+// SupersededKeys returns the replaced API keys that still work.
+func (m *Manager) SupersededKeys() []SupersededKey {
+	return m.superseded
+}
+
+// RevokedKeys returns the revoked API keys.
+func (m *Manager) RevokedKeys() []RevokedKey {
+	return m.revoked
+}
+
+// isRevoked reports whether a JWT was revoked.
+func (m *Manager) isRevoked(token string) bool {
+	return len(m.revokedHashes) > 0 && m.revokedHashes[tokenHash(token)]
+}
+
+// resolveSuperseded returns the current JWT for a superseded opaque key.
+func (m *Manager) resolveSuperseded(key string) (string, bool) {
+	for _, k := range m.superseded {
+		if k.OpaqueKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(k.OpaqueKey)) != 1 {
+			continue
+		}
+		if k.Role == "service_role" {
+			return m.serviceKey, true
+		}
+		return m.anonKey, true
+	}
+	return "", false
+}
+
+// revokedHashes indexes revoked keys by hash.
+func revokedHashes(revoked []RevokedKey) map[string]bool {
+	hashes := make(map[string]bool, len(revoked))
+	for _, k := range revoked {
+		hashes[k.TokenSHA256] = true
+	}
+	return hashes
+}
+
+// tokenHash returns the hex SHA-256 of a token.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package keys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegenerate(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+	oldService, oldSecret := m.GetServiceKey(), m.GetSecretKey()
+	anon := m.GetAnonKey()
+
+	result, err := m.Regenerate("service_role", false)
+	if err != nil {
+		t.Fatalf("Regenerate() failed: %v", err)
+	}
+	if result.Revoked != 0 || m.GetServiceKey() == oldService || m.GetSecretKey() == oldSecret {
+		t.Fatalf("Regenerate() = %+v, want new service keys and nothing revoked", result)
+	}
+	if m.GetAnonKey() != anon {
+		t.Error("Regenerate(service_role) changed the anon key")
+	}
+
+	// The old keys keep working, also after a reload
+	m, err = NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() reload failed: %v", err)
+	}
+	if _, err := m.VerifyToken(oldService); err != nil {
+		t.Errorf("superseded service key should verify: %v", err)
+	}
+	if token, ok := m.ResolveAPIKey(oldSecret); !ok || token != m.GetServiceKey() {
+		t.Error("superseded secret key should resolve to the current service_role key")
+	}
+
+	// Revoking cuts off both generations of old keys
+	middleService, middleSecret := m.GetServiceKey(), m.GetSecretKey()
+	result, err = m.Regenerate("service_role", true)
+	if err != nil {
+		t.Fatalf("Regenerate(revoke) failed: %v", err)
+	}
+	if result.Revoked != 2 {
+		t.Errorf("Revoked = %d, want 2", result.Revoked)
+	}
+
+	m, err = NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() reload failed: %v", err)
+	}
+	for name, token := range map[string]string{"first": oldService, "second": middleService} {
+		if _, err := m.VerifyToken(token); !errors.Is(err, ErrKeyRevoked) {
+			t.Errorf("VerifyToken(%s service key) = %v, want ErrKeyRevoked", name, err)
+		}
+	}
+	for _, key := range []string{oldSecret, middleSecret} {
+		if _, ok := m.ResolveAPIKey(key); ok {
+			t.Errorf("revoked secret key %q still resolves", key)
+		}
+	}
+	for name, token := range map[string]string{"anon": anon, "new service": m.GetServiceKey()} {
+		if _, err := m.VerifyToken(token); err != nil {
+			t.Errorf("VerifyToken(%s key) failed: %v", name, err)
+		}
+	}
+	if len(m.SupersededKeys()) != 0 || len(m.RevokedKeys()) != 2 {
+		t.Errorf("%d superseded and %d revoked keys, want 0 and 2", len(m.SupersededKeys()), len(m.RevokedKeys()))
+	}
+}
+
+func TestRegenerate_Unavailable(t *testing.T) {
+	m, _ := NewManager(t.TempDir(), "")
+	if _, err := m.Regenerate("authenticated", false); err == nil {
+		t.Error("Regenerate() should reject an unknown role")
+	}
+
+	legacy, _ := NewManager(t.TempDir(), "secret")
+	if _, err := legacy.Regenerate("anon", false); err == nil {
+		t.Error("Regenerate() should fail in legacy mode")
+	}
+
+	deterministic, _ := NewDeterministicManager("seed", "", "")
+	if _, err := deterministic.Regenerate("anon", false); err == nil {
+		t.Error("Regenerate() should fail for deterministic keys")
+	}
+}
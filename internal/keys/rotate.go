@@ -0,0 +1,185 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGracePeriod is how long a rotated-out key keeps verifying tokens,
+// so clients holding the old anon key, or sessions signed with the old
+// key, have time to pick up the new one.
+const DefaultGracePeriod = 7 * 24 * time.Hour
+
+// RetiredKey is the public half of a rotated-out signing key. It stays in
+// the JWKS and keeps verifying tokens until ExpiresAt.
+type RetiredKey struct {
+	KeyID        string    `json:"key_id"`         // kid the key signed with
+	PublicKeyPEM string    `json:"public_key_pem"` // PEM-encoded public key
+	RetiredAt    time.Time `json:"retired_at"`     // When it stopped signing
+	ExpiresAt    time.Time `json:"expires_at"`     // When it stops verifying
+}
+
+// Rotation records one key rotation in keys.json.
+type Rotation struct {
+	RotatedAt  time.Time `json:"rotated_at"`
+	OldKeyID   string    `json:"old_key_id"`
+	NewKeyID   string    `json:"new_key_id"`
+	GraceUntil time.Time `json:"grace_until"` // When the old key stops verifying
+}
+
+// retiredKey is a RetiredKey with its public key parsed.
+type retiredKey struct {
+	RetiredKey
+	publicKey *ecdsa.PublicKey
+}
+
+// Rotate replaces the signing key with a new ES256 key pair.
+//
+// The old public key stays in the JWKS and keeps verifying tokens for
+// grace (zero drops it at once). The anon and service_role keys are
+// re-minted with the new key, keeping the project ref, and the rotation
+// is appended to the history in keys.json. Retired keys whose grace
+// period has passed are dropped.
+//
+// A running server reads keys.json at startup, so it must be restarted
+// to sign with the new key.
+//
+// Returns an error in legacy HS256 mode and for deterministic keys, which
+// are derived from their seed and never persisted.
+func (m *Manager) Rotate(grace time.Duration) (*Rotation, error) {
+	if m.useLegacy {
+		return nil, fmt.Errorf("key rotation is not available in legacy mode (JWT_SECRET)")
+	}
+	if m.deterministic {
+		return nil, fmt.Errorf("key rotation is not available for deterministic keys")
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	now := time.Now()
+	publicKeyPEM, err := encodePublicKey(m.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode old public key: %w", err)
+	}
+
+	retired := m.liveRetiredKeys(now)
+	if grace > 0 {
+		retired = append(retired, retiredKey{
+			RetiredKey: RetiredKey{
+				KeyID:        m.keyID,
+				PublicKeyPEM: publicKeyPEM,
+				RetiredAt:    now,
+				ExpiresAt:    now.Add(grace),
+			},
+			publicKey: m.publicKey,
+		})
+	}
+
+	rotation := Rotation{
+		RotatedAt:  now,
+		OldKeyID:   m.keyID,
+		NewKeyID:   nextKeyID(m.keyID),
+		GraceUntil: now.Add(grace),
+	}
+
+	m.privateKey = privateKey
+	m.publicKey = &privateKey.PublicKey
+	m.keyID = rotation.NewKeyID
+	m.createdAt = now
+	m.retiredKeys = retired
+	m.rotations = append(m.rotations, rotation)
+
+	if m.anonKey, err = m.generateToken("anon"); err != nil {
+		return nil, fmt.Errorf("failed to generate anon token: %w", err)
+	}
+	if m.serviceKey, err = m.generateToken("service_role"); err != nil {
+		return nil, fmt.Errorf("failed to generate service token: %w", err)
+	}
+
+	if err := m.saveKeys(); err != nil {
+		return nil, fmt.Errorf("failed to save keys: %w", err)
+	}
+	return &rotation, nil
+}
+
+// KeyID returns the kid of the active signing key.
+func (m *Manager) KeyID() string {
+	return m.keyID
+}
+
+// RetiredKeys returns the rotated-out keys that still verify tokens.
+func (m *Manager) RetiredKeys() []RetiredKey {
+	var keys []RetiredKey
+	for _, k := range m.liveRetiredKeys(time.Now()) {
+		keys = append(keys, k.RetiredKey)
+	}
+	return keys
+}
+
+// Rotations returns the rotation history, oldest first.
+func (m *Manager) Rotations() []Rotation {
+	return m.rotations
+}
+
+// liveRetiredKeys returns the retired keys still within their grace period.
+func (m *Manager) liveRetiredKeys(now time.Time) []retiredKey {
+	var live []retiredKey
+	for _, k := range m.retiredKeys {
+		if now.Before(k.ExpiresAt) {
+			live = append(live, k)
+		}
+	}
+	return live
+}
+
+// loadRetiredKeys parses the retired keys stored in keys.json, skipping
+// any that are malformed.
+func loadRetiredKeys(stored []RetiredKey) []retiredKey {
+	var keys []retiredKey
+	for _, k := range stored {
+		block, _ := pem.Decode([]byte(k.PublicKeyPEM))
+		if block == nil {
+			continue
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			continue
+		}
+		publicKey, ok := parsed.(*ecdsa.PublicKey)
+		if !ok {
+			continue
+		}
+		keys = append(keys, retiredKey{RetiredKey: k, publicKey: publicKey})
+	}
+	return keys
+}
+
+// encodePublicKey PEM-encodes an ECDSA public key.
+func encodePublicKey(key *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// nextKeyID numbers key IDs: supalite-key-1 is followed by supalite-key-2.
+func nextKeyID(keyID string) string {
+	prefix, n, ok := strings.Cut(keyID, "-key-")
+	if ok {
+		if i, err := strconv.Atoi(n); err == nil {
+			return fmt.Sprintf("%s-key-%d", prefix, i+1)
+		}
+	}
+	return fmt.Sprintf("supalite-key-%d", time.Now().Unix())
+}
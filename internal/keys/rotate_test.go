@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+	oldAnon := m.GetAnonKey()
+	projectRef := m.GetProjectRef()
+
+	rotation, err := m.Rotate(time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate() failed: %v", err)
+	}
+	if rotation.OldKeyID != KeyID || rotation.NewKeyID != "supalite-key-2" || m.KeyID() != "supalite-key-2" {
+		t.Errorf("rotation = %+v, active kid %q, want supalite-key-1 -> supalite-key-2", rotation, m.KeyID())
+	}
+	if m.GetAnonKey() == oldAnon || m.GetProjectRef() != projectRef {
+		t.Error("Rotate() should re-mint the anon key and keep the project ref")
+	}
+
+	// Reload from keys.json, as the restarted server would
+	m, err = NewManager(dir, "")
+	if err != nil {
+		t.Fatalf("NewManager() after rotation failed: %v", err)
+	}
+	if m.KeyID() != "supalite-key-2" || len(m.Rotations()) != 1 {
+		t.Errorf("reloaded kid %q with %d rotations, want supalite-key-2 with 1", m.KeyID(), len(m.Rotations()))
+	}
+
+	for name, token := range map[string]string{"old anon key": oldAnon, "new anon key": m.GetAnonKey()} {
+		if _, err := m.VerifyToken(token); err != nil {
+			t.Errorf("VerifyToken(%s) failed during grace period: %v", name, err)
+		}
+	}
+
+	jwks, err := m.GetJWKS()
+	if err != nil {
+		t.Fatalf("GetJWKS() failed: %v", err)
+	}
+	keys := jwks["keys"].([]map[string]interface{})
+	if len(keys) != 2 || keys[0]["kid"] != "supalite-key-2" || keys[1]["kid"] != KeyID {
+		t.Errorf("JWKS keys = %v, want supalite-key-2 then supalite-key-1", keys)
+	}
+
+	// Without a grace period, tokens from the old key stop verifying at once
+	secondAnon := m.GetAnonKey()
+	if _, err := m.Rotate(0); err != nil {
+		t.Fatalf("Rotate(0) failed: %v", err)
+	}
+	if _, err := m.VerifyToken(secondAnon); err == nil {
+		t.Error("VerifyToken() should reject a token from a key rotated out without grace")
+	}
+	if _, err := m.VerifyToken(oldAnon); err != nil {
+		t.Errorf("VerifyToken() should still accept the first key within its grace: %v", err)
+	}
+}
+
+func TestRotate_Unavailable(t *testing.T) {
+	legacy, err := NewManager(t.TempDir(), "super-secret-jwt-token-with-at-least-32-characters")
+	if err != nil {
+		t.Fatalf("NewManager() failed: %v", err)
+	}
+	if _, err := legacy.Rotate(time.Hour); err == nil {
+		t.Error("Rotate() should fail in legacy mode")
+	}
+
+	deterministic, err := NewDeterministicManager("seed", "", "")
+	if err != nil {
+		t.Fatalf("NewDeterministicManager() failed: %v", err)
+	}
+	if _, err := deterministic.Rotate(time.Hour); err == nil {
+		t.Error("Rotate() should fail for deterministic keys")
+	}
+}
@@ -0,0 +1,101 @@
+// Package limits caps the memory and CPU of supalite's child processes
+// (Postgres and GoTrue) and detects how much memory the surrounding
+// container allows, so Postgres can be sized to fit.
+package limits
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Limits caps a child process. Zero values mean no limit.
+type Limits struct {
+	MemoryMB int     // Memory ceiling in megabytes
+	CPUs     float64 // CPU quota in cores, e.g. 0.5 for half a core
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l.MemoryMB <= 0 && l.CPUs <= 0
+}
+
+// unlimitedMemory is the threshold above which a cgroup v1 memory limit
+// means "no limit": the kernel reports a page-aligned math.MaxInt64.
+const unlimitedMemory = 1 << 60
+
+// Files holding the memory limit of the cgroup supalite runs in: cgroup v2
+// first, then cgroup v1. Inside a container these are the container's.
+var memoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// ContainerMemory returns the memory limit of the container supalite runs
+// in, in bytes, or 0 if there is none or it can't be determined.
+func ContainerMemory() int64 {
+	return readMemoryLimit(memoryLimitFiles...)
+}
+
+// readMemoryLimit returns the first limit found in files, in bytes.
+func readMemoryLimit(files ...string) int64 {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		return parseMemoryLimit(string(data))
+	}
+	return 0
+}
+
+// parseMemoryLimit parses the contents of memory.max or
+// memory.limit_in_bytes. "max" and huge values mean unlimited (0).
+func parseMemoryLimit(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "max" {
+		return 0
+	}
+	limit, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || limit <= 0 || limit >= unlimitedMemory {
+		return 0
+	}
+	return limit
+}
+
+// shared_buffers bounds. Postgres defaults to 128MB, which is a lot for a
+// container with 256MB, so sizing only ever lowers it.
+const (
+	minSharedBuffers = 16 << 20
+	maxSharedBuffers = 128 << 20
+)
+
+// SharedBuffers returns a shared_buffers setting for Postgres given the
+// memory available to it in bytes: a quarter of it, between 16MB and
+// Postgres' 128MB default. It returns "" when memory is unknown (0).
+func SharedBuffers(memory int64) string {
+	if memory <= 0 {
+		return ""
+	}
+	buffers := memory / 4
+	if buffers < minSharedBuffers {
+		buffers = minSharedBuffers
+	}
+	if buffers > maxSharedBuffers {
+		buffers = maxSharedBuffers
+	}
+	return fmt.Sprintf("%dMB", buffers>>20)
+}
+
+// PostgresMemory returns the memory Postgres can use, in bytes: its own
+// limit if one is set, otherwise the container's, whichever is smaller.
+func PostgresMemory(l Limits) int64 {
+	memory := ContainerMemory()
+	if l.MemoryMB > 0 {
+		if own := int64(l.MemoryMB) << 20; memory == 0 || own < memory {
+			memory = own
+		}
+	}
+	return memory
+}
@@ -0,0 +1,107 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cpuPeriod is the cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// Apply caps the process pid, named name in logs and cgroup paths.
+//
+// It moves the process into a cgroup v2 group of its own next to
+// supalite's, which caps the process and everything it forks. That needs a
+// writable, delegated cgroup (root in a container, or a systemd unit with
+// Delegate=yes). Otherwise it falls back to an address-space rlimit, which
+// caps each process separately and cannot limit CPU.
+func Apply(name string, pid int, l Limits) error {
+	if l.IsZero() {
+		return nil
+	}
+	cgroupErr := applyCgroup(name, pid, l)
+	if cgroupErr == nil {
+		return nil
+	}
+
+	if l.MemoryMB > 0 {
+		limit := uint64(l.MemoryMB) << 20
+		rlimit := unix.Rlimit{Cur: limit, Max: limit}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &rlimit, nil); err != nil {
+			return fmt.Errorf("cgroup: %v; rlimit: %w", cgroupErr, err)
+		}
+	}
+	if l.CPUs > 0 {
+		return fmt.Errorf("CPU limit needs cgroup v2: %w", cgroupErr)
+	}
+	return nil
+}
+
+// applyCgroup creates (or reuses) the cgroup supalite-<name> as a sibling
+// of supalite's own cgroup and moves pid into it.
+func applyCgroup(name string, pid int, l Limits) error {
+	self, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return err
+	}
+	current, err := cgroupV2Path(string(self))
+	if err != nil {
+		return err
+	}
+
+	// A cgroup holding processes can't delegate controllers to children,
+	// so the group goes next to supalite's rather than under it
+	parent := filepath.Join(cgroupRoot, filepath.Dir(current))
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(controllers(l)), 0644); err != nil {
+		return fmt.Errorf("enable controllers: %w", err)
+	}
+
+	dir := filepath.Join(parent, "supalite-"+name)
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	if l.MemoryMB > 0 {
+		if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(fmt.Sprint(int64(l.MemoryMB)<<20)), 0644); err != nil {
+			return err
+		}
+	}
+	if l.CPUs > 0 {
+		quota := fmt.Sprintf("%d %d", int64(l.CPUs*cpuPeriod), cpuPeriod)
+		if err := os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(quota), 0644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(fmt.Sprint(pid)), 0644)
+}
+
+// cgroupV2Path returns the cgroup v2 path from /proc/self/cgroup, whose
+// unified hierarchy entry reads "0::/path".
+func cgroupV2Path(procCgroup string) (string, error) {
+	for _, line := range strings.Split(procCgroup, "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not in use")
+}
+
+// controllers returns the cgroup.subtree_control line enabling the
+// controllers l needs.
+func controllers(l Limits) string {
+	var enable []string
+	if l.MemoryMB > 0 {
+		enable = append(enable, "+memory")
+	}
+	if l.CPUs > 0 {
+		enable = append(enable, "+cpu")
+	}
+	return strings.Join(enable, " ")
+}
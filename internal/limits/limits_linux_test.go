@@ -0,0 +1,13 @@
+package limits
+
+import "testing"
+
+func TestCgroupV2Path(t *testing.T) {
+	path, err := cgroupV2Path("12:memory:/docker/abc\n0::/user.slice/supalite.service\n")
+	if err != nil || path != "/user.slice/supalite.service" {
+		t.Errorf("cgroupV2Path() = %q, %v", path, err)
+	}
+	if _, err := cgroupV2Path("12:memory:/docker/abc\n"); err == nil {
+		t.Error("cgroupV2Path() should fail without a unified hierarchy entry")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package limits
+
+import "errors"
+
+// Apply caps the process pid. Limits are only supported on Linux.
+func Apply(name string, pid int, l Limits) error {
+	if l.IsZero() {
+		return nil
+	}
+	return errors.New("process limits are only supported on Linux")
+}
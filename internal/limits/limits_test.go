@@ -0,0 +1,64 @@
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := map[string]int64{
+		"536870912\n":           512 << 20,
+		"max\n":                 0,
+		"9223372036854771712\n": 0, // cgroup v1 "unlimited"
+		"":                      0,
+		"garbage":               0,
+	}
+	for in, want := range tests {
+		if got := parseMemoryLimit(in); got != want {
+			t.Errorf("parseMemoryLimit(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestReadMemoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "memory.limit_in_bytes")
+	if err := os.WriteFile(v1, []byte("268435456\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing cgroup v2 file falls through to cgroup v1
+	if got := readMemoryLimit(filepath.Join(dir, "memory.max"), v1); got != 256<<20 {
+		t.Errorf("readMemoryLimit() = %d, want %d", got, 256<<20)
+	}
+	if got := readMemoryLimit(filepath.Join(dir, "missing")); got != 0 {
+		t.Errorf("readMemoryLimit() with no files = %d, want 0", got)
+	}
+}
+
+func TestSharedBuffers(t *testing.T) {
+	tests := []struct {
+		memory int64
+		want   string
+	}{
+		{0, ""},
+		{32 << 20, "16MB"},
+		{256 << 20, "64MB"},
+		{8 << 30, "128MB"},
+	}
+	for _, tt := range tests {
+		if got := SharedBuffers(tt.memory); got != tt.want {
+			t.Errorf("SharedBuffers(%d) = %q, want %q", tt.memory, got, tt.want)
+		}
+	}
+}
+
+func TestLimitsIsZero(t *testing.T) {
+	if !(Limits{}).IsZero() {
+		t.Error("empty Limits should be zero")
+	}
+	if (Limits{CPUs: 0.5}).IsZero() {
+		t.Error("Limits with a CPU quota should not be zero")
+	}
+}
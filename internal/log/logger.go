@@ -1,11 +1,19 @@
+// Package log is supalite's logger. Messages carry key/value arguments
+// and are written through log/slog, as text or JSON, with a timestamp and
+// level. Each component (server, auth, pg, ...) logs through a logger of
+// its own (see Component), and can be given a level of its own.
 package log
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Level int
@@ -17,82 +25,264 @@ const (
 	LevelError
 )
 
-type logger struct {
-	mu     sync.Mutex
-	level  Level
-	writer io.Writer
+// String returns the level's lowercase name.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// slogLevel returns the equivalent slog level.
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
 
-var (
-	globalLogger *logger
-	once         sync.Once
+// ParseLevel parses a level name. It accepts the names other loggers use
+// too: trace maps to debug, warning to warn, and fatal and panic to error.
+func ParseLevel(name string) (Level, bool) {
+	switch strings.ToLower(name) {
+	case "trace", "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error", "fatal", "panic":
+		return LevelError, true
+	default:
+		return LevelInfo, false
+	}
+}
+
+// ParseLevels parses a level specification: a default level, followed by
+// component=level overrides, separated by commas, e.g. "info,pg=debug".
+// Either part may be left out: "pg=debug" keeps the default at info.
+func ParseLevels(spec string) (Level, map[string]Level, error) {
+	level := LevelInfo
+	components := make(map[string]Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, name, isOverride := strings.Cut(part, "=")
+		if !isOverride {
+			name = component
+		}
+		parsed, ok := ParseLevel(strings.TrimSpace(name))
+		if !ok {
+			return LevelInfo, nil, fmt.Errorf("unknown log level %q (use debug, info, warn, or error)", name)
+		}
+		if isOverride {
+			components[strings.TrimSpace(component)] = parsed
+		} else {
+			level = parsed
+		}
+	}
+	return level, components, nil
+}
+
+// Output formats
+const (
+	FormatText = "text" // key=value pairs (default)
+	FormatJSON = "json" // One JSON object per line
 )
 
-func init() {
-	globalLogger = &logger{
+type logger struct {
+	mu      sync.RWMutex
+	level   Level
+	levels  map[string]Level // Per-component levels, overriding level
+	format  string
+	writer  io.Writer
+	handler slog.Handler
+}
+
+var globalLogger = newLogger()
+
+func newLogger() *logger {
+	l := &logger{
 		level:  LevelInfo,
-		writer: os.Stdout,
+		format: FormatText,
+		writer: os.Stderr,
 	}
+	l.handler = l.newHandler()
+	return l
+}
+
+// newHandler builds the slog handler for the current format and writer.
+// Levels are checked before records reach it, so it accepts every level.
+func (l *logger) newHandler() slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if l.format == FormatJSON {
+		return slog.NewJSONHandler(l.writer, opts)
+	}
+	return slog.NewTextHandler(l.writer, opts)
 }
 
 func Debug(msg string, args ...interface{}) {
-	globalLogger.log(LevelDebug, msg, args...)
+	globalLogger.log(LevelDebug, msg, args)
 }
 
 func Info(msg string, args ...interface{}) {
-	globalLogger.log(LevelInfo, msg, args...)
+	globalLogger.log(LevelInfo, msg, args)
 }
 
 func Warn(msg string, args ...interface{}) {
-	globalLogger.log(LevelWarn, msg, args...)
+	globalLogger.log(LevelWarn, msg, args)
 }
 
 func Error(msg string, args ...interface{}) {
-	globalLogger.log(LevelError, msg, args...)
+	globalLogger.log(LevelError, msg, args)
+}
+
+// Log logs at the given level, for messages whose level is only known at
+// run time, such as those relayed from a subprocess.
+func Log(level Level, msg string, args ...interface{}) {
+	globalLogger.log(level, msg, args)
 }
 
+// SetLevel sets the level of components without a level of their own.
 func SetLevel(level Level) {
 	globalLogger.mu.Lock()
 	defer globalLogger.mu.Unlock()
 	globalLogger.level = level
 }
 
+// SetLevels applies a level specification (see ParseLevels), replacing
+// all component levels.
+func SetLevels(spec string) error {
+	level, components, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+	globalLogger.mu.Lock()
+	defer globalLogger.mu.Unlock()
+	globalLogger.level = level
+	globalLogger.levels = components
+	return nil
+}
+
+// ComponentLevel returns the level a component logs at.
+func ComponentLevel(component string) Level {
+	globalLogger.mu.RLock()
+	defer globalLogger.mu.RUnlock()
+	return globalLogger.levelFor(component)
+}
+
+// SetFormat sets the output format: FormatText or FormatJSON.
+func SetFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q (use %s or %s)", format, FormatText, FormatJSON)
+	}
+	globalLogger.mu.Lock()
+	defer globalLogger.mu.Unlock()
+	globalLogger.format = format
+	globalLogger.handler = globalLogger.newHandler()
+	return nil
+}
+
+// SetWriter sets where messages are written (default: stderr).
 func SetWriter(w io.Writer) {
 	globalLogger.mu.Lock()
 	defer globalLogger.mu.Unlock()
 	globalLogger.writer = w
+	globalLogger.handler = globalLogger.newHandler()
 }
 
+// Logger returns a standard library logger writing through this one at
+// info level, for packages that need a *log.Logger.
 func Logger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags)
+	globalLogger.mu.RLock()
+	defer globalLogger.mu.RUnlock()
+	return slog.NewLogLogger(globalLogger.handler, slog.LevelInfo)
+}
+
+// levelFor returns a component's level. l.mu must be held.
+func (l *logger) levelFor(component string) Level {
+	if level, ok := l.levels[component]; ok && component != "" {
+		return level
+	}
+	return l.level
 }
 
-func (l *logger) log(level Level, msg string, args ...interface{}) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+func (l *logger) log(level Level, msg string, args []interface{}) {
+	l.mu.RLock()
+	minLevel := l.levelFor(componentOf(args))
+	handler := l.handler
+	l.mu.RUnlock()
 
-	if level < l.level {
+	if level < minLevel {
 		return
 	}
 
-	prefix := ""
-	switch level {
-	case LevelDebug:
-		prefix = "DEBUG"
-	case LevelInfo:
-		prefix = "INFO"
-	case LevelWarn:
-		prefix = "WARN"
-	case LevelError:
-		prefix = "ERROR"
-	}
+	record := slog.NewRecord(time.Now(), level.slogLevel(), msg, 0)
+	record.Add(args...)
+	_ = handler.Handle(context.Background(), record)
+	recent.add(newEntry(level, msg, args))
+}
 
-	logMsg := "[" + prefix + "] " + msg
-	if len(args) > 0 {
-		for _, arg := range args {
-			logMsg += " " + fmt.Sprint(arg)
+// componentOf returns the value of the component argument, if any.
+func componentOf(args []interface{}) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok && key == "component" {
+			component, _ := args[i+1].(string)
+			return component
 		}
 	}
+	return ""
+}
+
+// ComponentLogger logs for one component: each message carries a
+// component argument, and is filtered by the component's level.
+type ComponentLogger struct {
+	component string
+}
+
+// Component returns the logger for a component, e.g. "pg".
+func Component(name string) *ComponentLogger {
+	return &ComponentLogger{component: name}
+}
+
+func (c *ComponentLogger) Debug(msg string, args ...interface{}) {
+	globalLogger.log(LevelDebug, msg, c.args(args))
+}
+
+func (c *ComponentLogger) Info(msg string, args ...interface{}) {
+	globalLogger.log(LevelInfo, msg, c.args(args))
+}
+
+func (c *ComponentLogger) Warn(msg string, args ...interface{}) {
+	globalLogger.log(LevelWarn, msg, c.args(args))
+}
+
+func (c *ComponentLogger) Error(msg string, args ...interface{}) {
+	globalLogger.log(LevelError, msg, c.args(args))
+}
+
+// Log logs at the given level.
+func (c *ComponentLogger) Log(level Level, msg string, args ...interface{}) {
+	globalLogger.log(level, msg, c.args(args))
+}
 
-	log.Println(logMsg)
+// args puts the component first, so it leads each text line.
+func (c *ComponentLogger) args(args []interface{}) []interface{} {
+	return append([]interface{}{"component", c.component}, args...)
 }
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	level, components, err := ParseLevels("warn, pg=debug,auth=error")
+	if err != nil {
+		t.Fatalf("ParseLevels() failed: %v", err)
+	}
+	if level != LevelWarn || components["pg"] != LevelDebug || components["auth"] != LevelError {
+		t.Errorf("ParseLevels() = %v, %v", level, components)
+	}
+
+	if level, _, _ := ParseLevels("pg=debug"); level != LevelInfo {
+		t.Errorf("default level = %v, want info", level)
+	}
+	if _, _, err := ParseLevels("info,pg=loud"); err == nil {
+		t.Error("expected error for an unknown level")
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	SetWriter(&buf)
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLevels("warn,pg=debug"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { globalLogger = newLogger() }()
+
+	Component("pg").Debug("connection opened", "port", 5432)
+	Component("server").Info("request served")
+	Info("not logged at warn")
+	Warn("disk low", "component", "pg", "free_mb", 100)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if entry["msg"] != "connection opened" || entry["level"] != "DEBUG" || entry["component"] != "pg" || entry["port"] != float64(5432) || entry["time"] == nil {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestSetFormat_Invalid(t *testing.T) {
+	if err := SetFormat("xml"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,76 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// recentCapacity is how many entries are kept for the dashboard log viewer.
+const recentCapacity = 1000
+
+// Entry is a logged message, kept in memory for the dashboard log viewer.
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	Level     string            `json:"level"`
+	Component string            `json:"component,omitempty"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// newEntry builds an entry from a message and its key/value arguments. The
+// component argument, if any, becomes the entry's Component.
+func newEntry(level Level, msg string, args []interface{}) Entry {
+	entry := Entry{
+		Time:    time.Now(),
+		Level:   level.String(),
+		Message: msg,
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, value := "arg", fmt.Sprint(args[i])
+		if i+1 < len(args) {
+			key, value = fmt.Sprint(args[i]), fmt.Sprint(args[i+1])
+		}
+		if key == "component" {
+			entry.Component = value
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]string)
+		}
+		entry.Fields[key] = value
+	}
+	return entry
+}
+
+// ring holds the most recent entries, overwriting the oldest when full.
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+var recent = &ring{entries: make([]Entry, recentCapacity)}
+
+func (r *ring) add(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent returns the most recently logged entries, oldest first.
+func Recent() []Entry {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	if !recent.full {
+		return append([]Entry(nil), recent.entries[:recent.next]...)
+	}
+	out := make([]Entry, 0, len(recent.entries))
+	out = append(out, recent.entries[recent.next:]...)
+	return append(out, recent.entries[:recent.next]...)
+}
@@ -1,9 +1,5 @@
 package mailcapture
 
-import (
-	"github.com/markb/supalite/internal/pg"
-)
-
 // Config holds configuration for the mail capture server
 type Config struct {
 	// Port is the port to listen on for SMTP connections
@@ -12,8 +8,14 @@ type Config struct {
 	// Host is the hostname to listen on (default: localhost)
 	Host string
 
-	// Database is the PostgreSQL connection for storing emails
-	Database *pg.EmbeddedDatabase
+	// Store is where captured emails are saved (see NewPostgresStore,
+	// NewMaildirStore, and NewWebhookStore)
+	Store Store
+
+	// PublicURL replaces LocalOrigins in links of captured emails, so they
+	// open on other devices (default: links are left alone)
+	PublicURL    string
+	LocalOrigins []string
 }
 
 // DefaultConfig returns configuration with sensible defaults
@@ -0,0 +1,49 @@
+package mailcapture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// MaildirStore saves emails as .eml files in a Maildir, which mail clients
+// like mutt can open directly. Each file is the raw message with a
+// Delivered-To header naming its recipient.
+type MaildirStore struct {
+	dir string
+	seq atomic.Uint64
+}
+
+// NewMaildirStore creates a store writing to the Maildir at dir, creating
+// its tmp, new, and cur subdirectories.
+func NewMaildirStore(dir string) (*MaildirStore, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("mailcapture: failed to create maildir: %w", err)
+		}
+	}
+	return &MaildirStore{dir: dir}, nil
+}
+
+// Save writes the email to tmp and then moves it to new, so readers never
+// see a partial file.
+func (s *MaildirStore) Save(ctx context.Context, email Email) error {
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	name := fmt.Sprintf("%d.%d_%d.%s.eml", email.ReceivedAt.UnixNano(), os.Getpid(), s.seq.Add(1), hostname)
+
+	data := append([]byte("Delivered-To: "+email.To+"\r\n"), email.Raw...)
+	tmpPath := filepath.Join(s.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, "new", name)); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
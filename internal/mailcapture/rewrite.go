@@ -0,0 +1,19 @@
+package mailcapture
+
+import (
+	"net/url"
+	"strings"
+)
+
+// newLinkRewriter returns a replacer pointing links at localOrigins to
+// publicURL instead, including URL-encoded ones such as redirect_to values.
+func newLinkRewriter(publicURL string, localOrigins []string) *strings.Replacer {
+	var pairs []string
+	for _, origin := range localOrigins {
+		pairs = append(pairs,
+			origin, publicURL,
+			url.QueryEscape(origin), url.QueryEscape(publicURL),
+		)
+	}
+	return strings.NewReplacer(pairs...)
+}
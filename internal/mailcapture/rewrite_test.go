@@ -0,0 +1,13 @@
+package mailcapture
+
+import "testing"
+
+func TestLinkRewriter(t *testing.T) {
+	rewriter := newLinkRewriter("http://192.168.1.20:8080", []string{"http://localhost:8080", "http://127.0.0.1:8080"})
+
+	body := `<a href="http://localhost:8080/auth/v1/verify?token=abc&redirect_to=http%3A%2F%2F127.0.0.1%3A8080">Confirm</a> http://localhost:3000/`
+	want := `<a href="http://192.168.1.20:8080/auth/v1/verify?token=abc&redirect_to=http%3A%2F%2F192.168.1.20%3A8080">Confirm</a> http://localhost:3000/`
+	if got := rewriter.Replace(body); got != want {
+		t.Errorf("rewritten body =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/markb/supalite/internal/log"
 )
 
-// Server is a mail capture SMTP server that stores emails to a database
+// logger logs for the mailcapture component.
+var logger = log.Component("mailcapture")
+
+// Server is a mail capture SMTP server that saves emails to a Store
 type Server struct {
 	config   Config
 	smtpSrv  *smtp.Server
@@ -21,8 +24,8 @@ type Server struct {
 
 // NewServer creates a new mail capture server
 func NewServer(cfg Config) (*Server, error) {
-	if cfg.Database == nil {
-		return nil, fmt.Errorf("mailcapture: database cannot be nil")
+	if cfg.Store == nil {
+		return nil, fmt.Errorf("mailcapture: store cannot be nil")
 	}
 	if cfg.Port == 0 {
 		cfg.Port = 1025
@@ -46,7 +49,10 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Create SMTP backend
 	backend := &smtpBackend{
-		database: s.config.Database,
+		store: s.config.Store,
+	}
+	if s.config.PublicURL != "" {
+		backend.rewriter = newLinkRewriter(s.config.PublicURL, s.config.LocalOrigins)
 	}
 
 	// Create SMTP server
@@ -65,12 +71,12 @@ func (s *Server) Start(ctx context.Context) error {
 	// Start serving in goroutine
 	go func() {
 		if err := s.smtpSrv.Serve(listener); err != nil {
-			log.Warn("mail capture server stopped", "error", err)
+			logger.Warn("mail capture server stopped", "error", err)
 		}
 	}()
 
 	s.running = true
-	log.Info("mail capture server started", "addr", s.smtpSrv.Addr)
+	logger.Info("mail capture server started", "addr", s.smtpSrv.Addr)
 	return nil
 }
 
@@ -91,7 +97,7 @@ func (s *Server) Stop() error {
 	}
 
 	s.running = false
-	log.Info("mail capture server stopped")
+	logger.Info("mail capture server stopped")
 	return nil
 }
 
@@ -60,9 +60,9 @@ func TestMailCaptureServer_CapturesEmail(t *testing.T) {
 
 	// Start mail capture server
 	srv, err := NewServer(Config{
-		Port:     2525,
-		Host:     "localhost",
-		Database: db,
+		Port:  2525,
+		Host:  "localhost",
+		Store: NewPostgresStore(db.Pool()),
 	})
 	if err != nil {
 		t.Fatalf("Failed to create server: %v", err)
@@ -160,9 +160,9 @@ func TestMailCaptureServer_CapturesMultipartEmail(t *testing.T) {
 
 	// Start mail capture server
 	srv, err := NewServer(Config{
-		Port:     2526,
-		Host:     "localhost",
-		Database: db,
+		Port:  2526,
+		Host:  "localhost",
+		Store: NewPostgresStore(db.Pool()),
 	})
 	if err != nil {
 		t.Fatalf("Failed to create server: %v", err)
@@ -263,9 +263,9 @@ func TestMailCaptureServer_MultipleRecipients(t *testing.T) {
 
 	// Start mail capture server
 	srv, err := NewServer(Config{
-		Port:     2527,
-		Host:     "localhost",
-		Database: db,
+		Port:  2527,
+		Host:  "localhost",
+		Store: NewPostgresStore(db.Pool()),
 	})
 	if err != nil {
 		t.Fatalf("Failed to create server: %v", err)
@@ -12,22 +12,22 @@ import (
 	"time"
 
 	"github.com/emersion/go-smtp"
-	"github.com/markb/supalite/internal/log"
-	"github.com/markb/supalite/internal/pg"
 )
 
 // smtpBackend implements smtp.Backend
 type smtpBackend struct {
-	database *pg.EmbeddedDatabase
+	store    Store
+	rewriter *strings.Replacer // Optional: rewrites links to the public URL
 }
 
 func (b *smtpBackend) NewSession(_ *smtp.Conn) (smtp.Session, error) {
-	return &smtpSession{database: b.database}, nil
+	return &smtpSession{store: b.store, rewriter: b.rewriter}, nil
 }
 
 // smtpSession handles a single SMTP session
 type smtpSession struct {
-	database *pg.EmbeddedDatabase
+	store    Store
+	rewriter *strings.Replacer
 	from     string
 	to       []string
 }
@@ -53,11 +53,14 @@ func (s *smtpSession) Data(r io.Reader) error {
 	if err != nil {
 		return err
 	}
+	if s.rewriter != nil {
+		rawMessage = []byte(s.rewriter.Replace(string(rawMessage)))
+	}
 
 	// Parse the message
 	msg, err := mail.ReadMessage(bytes.NewReader(rawMessage))
 	if err != nil {
-		log.Warn("failed to parse email", "error", err)
+		logger.Warn("failed to parse email", "error", err)
 		// Still store it even if parsing fails
 		return s.storeEmail("", "", "", "", rawMessage)
 	}
@@ -76,7 +79,7 @@ func (s *smtpSession) Data(r io.Reader) error {
 	var failedRecipients []string
 	for _, to := range s.to {
 		if err := s.storeEmail(subject, textBody, htmlBody, to, rawMessage); err != nil {
-			log.Warn("failed to store email", "error", err, "to", to)
+			logger.Warn("failed to store email", "error", err, "to", to)
 			failedRecipients = append(failedRecipients, to)
 		}
 	}
@@ -85,7 +88,7 @@ func (s *smtpSession) Data(r io.Reader) error {
 		return fmt.Errorf("failed to store email for %d recipient(s): %v", len(failedRecipients), failedRecipients)
 	}
 
-	log.Info("captured email", "from", s.from, "to", s.to, "subject", subject)
+	logger.Info("captured email", "from", s.from, "to", s.to, "subject", subject)
 	return nil
 }
 
@@ -102,19 +105,15 @@ func (s *smtpSession) storeEmail(subject, textBody, htmlBody, to string, rawMess
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	conn, err := s.database.Connect(ctx)
-	if err != nil {
-		return err
-	}
-	defer conn.Close(ctx)
-
-	_, err = conn.Exec(ctx, `
-		INSERT INTO public.captured_emails
-			(from_addr, to_addr, subject, text_body, html_body, raw_message)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, s.from, to, subject, textBody, htmlBody, rawMessage)
-
-	return err
+	return s.store.Save(ctx, Email{
+		From:       s.from,
+		To:         to,
+		Subject:    subject,
+		TextBody:   textBody,
+		HTMLBody:   htmlBody,
+		Raw:        rawMessage,
+		ReceivedAt: time.Now().UTC(),
+	})
 }
 
 // decodeRFC2047 decodes MIME encoded-word strings
@@ -0,0 +1,52 @@
+package mailcapture
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Email is a captured message for one recipient. A message sent to several
+// recipients is stored once for each.
+type Email struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Subject    string    `json:"subject"`
+	TextBody   string    `json:"text_body"`
+	HTMLBody   string    `json:"html_body"`
+	Raw        []byte    `json:"raw_message"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Store is where captured emails go.
+type Store interface {
+	Save(ctx context.Context, email Email) error
+}
+
+// Execer runs a statement. *pgxpool.Pool and *pgx.Conn implement it, so
+// the Postgres store works with the embedded database or an external one.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// PostgresStore saves emails to the public.captured_emails table, where the
+// dashboard and the REST API read them.
+type PostgresStore struct {
+	db Execer
+}
+
+// NewPostgresStore creates a store writing to db.
+func NewPostgresStore(db Execer) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Save inserts the email into captured_emails.
+func (s *PostgresStore) Save(ctx context.Context, email Email) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO public.captured_emails
+			(from_addr, to_addr, subject, text_body, html_body, raw_message)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, email.From, email.To, email.Subject, email.TextBody, email.HTMLBody, email.Raw)
+	return err
+}
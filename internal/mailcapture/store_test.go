@@ -0,0 +1,105 @@
+package mailcapture
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/webhook"
+)
+
+func testEmail() Email {
+	return Email{
+		From:       "noreply@example.com",
+		To:         "user@example.com",
+		Subject:    "Confirm your signup",
+		TextBody:   "Follow this link",
+		Raw:        []byte("Subject: Confirm your signup\r\n\r\nFollow this link\r\n"),
+		ReceivedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestMaildirStore_Save(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewMaildirStore(dir)
+	if err != nil {
+		t.Fatalf("NewMaildirStore() error = %v", err)
+	}
+	if err := store.Save(context.Background(), testEmail()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "new", "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file in new/, got %d", len(files))
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("tmp/ should be empty after Save, has %d entries", len(tmp))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "Delivered-To: user@example.com\r\nSubject: Confirm your signup") {
+		t.Errorf("unexpected message file:\n%s", data)
+	}
+}
+
+func TestWebhookStore_Save(t *testing.T) {
+	var got Email
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	email := testEmail()
+	if err := NewWebhookStore(srv.URL, "").Save(context.Background(), email); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got.To != email.To || got.Subject != email.Subject || string(got.Raw) != string(email.Raw) {
+		t.Errorf("webhook received %+v, want %+v", got, email)
+	}
+}
+
+func TestWebhookStore_Signed(t *testing.T) {
+	const secret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = webhook.Verify(secret, r.Header, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookStore(srv.URL, secret).Save(context.Background(), testEmail()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if verifyErr != nil {
+		t.Errorf("webhook.Verify() = %v, want a valid signature", verifyErr)
+	}
+}
+
+func TestWebhookStore_SaveFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookStore(srv.URL, "").Save(context.Background(), testEmail()); err == nil {
+		t.Error("Save() should fail when the webhook returns 500")
+	}
+}
@@ -0,0 +1,60 @@
+package mailcapture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markb/supalite/webhook"
+)
+
+// WebhookStore POSTs each email as JSON to a URL, for test harnesses that
+// want to assert on emails as they arrive. raw_message is base64-encoded.
+// With a secret, each request is signed with the webhook package's
+// webhook-id, webhook-timestamp, and webhook-signature headers.
+type WebhookStore struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookStore creates a store posting to url, signing requests with
+// secret unless it is empty.
+func NewWebhookStore(url, secret string) *WebhookStore {
+	return &WebhookStore{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Save posts the email. Any non-2xx response is an error, so the SMTP
+// client sees the delivery fail.
+func (s *WebhookStore) Save(ctx context.Context, email Email) error {
+	body, err := json.Marshal(email)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != "" {
+		webhook.SetHeaders(req.Header, s.secret, "msg_"+uuid.NewString(), time.Now(), body)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
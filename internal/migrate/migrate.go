@@ -0,0 +1,312 @@
+// Package migrate applies versioned SQL migrations from a directory.
+//
+// The layout is the Supabase CLI's, so a project's supabase/migrations
+// folder works unchanged: one file per migration, named
+// <version>_<name>.sql, applied in version order. Versions are timestamps
+// (20240115093000) when created with New, but any number works.
+//
+// Applied versions are recorded in supabase_migrations.schema_migrations,
+// the table the Supabase CLI uses, so a database migrated by either tool
+// is seen as up to date by the other. Each migration runs in its own
+// transaction together with its record, so a failing one leaves nothing
+// behind.
+//
+// The Supabase CLI has no down migrations. Here a migration may have one
+// in the down/ subdirectory under the same file name, which the CLI
+// ignores, and Down reverts the newest applied migrations with them.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultDir is where migrations are kept, relative to the working
+// directory.
+const DefaultDir = "migrations"
+
+// supabaseDir is where a Supabase CLI project keeps its migrations.
+var supabaseDir = filepath.Join("supabase", "migrations")
+
+// lockKey serializes migration runs across processes, e.g. a server
+// starting while `supalite migrate up` runs.
+const lockKey = 0x5375706d6967 // "Supmig"
+
+// fileName matches migration files, as the Supabase CLI does.
+var fileName = regexp.MustCompile(`^([0-9]+)_(.*)\.sql$`)
+
+const tableSQL = `
+CREATE SCHEMA IF NOT EXISTS supabase_migrations;
+CREATE TABLE IF NOT EXISTS supabase_migrations.schema_migrations (
+	version text NOT NULL PRIMARY KEY,
+	statements text[],
+	name text
+);`
+
+// ErrNoDown is returned when reverting a migration without a down file.
+var ErrNoDown = errors.New("no down migration")
+
+// Migration is a migration file, or a version the database has applied
+// whose file is gone.
+type Migration struct {
+	Version  string `json:"version"`
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	DownPath string `json:"down_path,omitempty"` // Optional: reverts the migration
+	Applied  bool   `json:"applied"`
+}
+
+// Missing reports whether the migration was applied but has no file.
+func (m Migration) Missing() bool {
+	return m.Path == ""
+}
+
+// FindDir returns the directory migrations are read from when none is
+// configured: ./migrations, or a Supabase CLI project's
+// ./supabase/migrations when only that exists.
+func FindDir() string {
+	if _, err := os.Stat(DefaultDir); err != nil {
+		if info, err := os.Stat(supabaseDir); err == nil && info.IsDir() {
+			return supabaseDir
+		}
+	}
+	return DefaultDir
+}
+
+// Load reads the migrations in dir, in version order. A missing
+// directory has none. Files that aren't named like migrations are
+// ignored.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[string]string{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, name := match[1], match[2]
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		m := Migration{Version: version, Name: name, Path: filepath.Join(dir, entry.Name())}
+		if down := filepath.Join(dir, "down", entry.Name()); fileExists(down) {
+			m.DownPath = down
+		}
+		migrations = append(migrations, m)
+	}
+	sortByVersion(migrations)
+	return migrations, nil
+}
+
+// New creates an empty migration named name in dir, versioned by now, and
+// an empty down migration for it. It returns the new migration.
+func New(dir, name string, now time.Time) (Migration, error) {
+	name = strings.Join(strings.Fields(strings.ToLower(name)), "_")
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return Migration{}, fmt.Errorf("invalid migration name %q", name)
+	}
+	m := Migration{Version: now.UTC().Format("20060102150405"), Name: name}
+	file := m.Version + "_" + name + ".sql"
+	m.Path = filepath.Join(dir, file)
+	m.DownPath = filepath.Join(dir, "down", file)
+
+	existing, err := Load(dir)
+	if err != nil {
+		return Migration{}, err
+	}
+	for _, other := range existing {
+		if other.Version == m.Version {
+			return Migration{}, fmt.Errorf("migration %s already has version %s", filepath.Base(other.Path), m.Version)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.DownPath), 0755); err != nil {
+		return Migration{}, err
+	}
+	if err := os.WriteFile(m.Path, []byte("-- "+name+"\n"), 0644); err != nil {
+		return Migration{}, err
+	}
+	if err := os.WriteFile(m.DownPath, []byte("-- Revert "+name+"\n"), 0644); err != nil {
+		return Migration{}, err
+	}
+	return m, nil
+}
+
+// Status returns the migrations in dir and the versions the database has
+// applied without a file in dir, in version order.
+func Status(ctx context.Context, conn *pgx.Conn, dir string) ([]Migration, error) {
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, tableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create the migrations table: %w", err)
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if _, ok := applied[migrations[i].Version]; ok {
+			migrations[i].Applied = true
+			delete(applied, migrations[i].Version)
+		}
+	}
+	for version, name := range applied {
+		migrations = append(migrations, Migration{Version: version, Name: name, Applied: true})
+	}
+	sortByVersion(migrations)
+	return migrations, nil
+}
+
+// Up applies the pending migrations in dir, oldest first, or the first n
+// of them when n > 0, and returns those it applied. It stops at the first
+// that fails, keeping those applied before it.
+func Up(ctx context.Context, conn *pgx.Conn, dir string, n int) ([]Migration, error) {
+	migrations, err := Status(ctx, conn, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if m.Applied {
+			continue
+		}
+		if n > 0 && len(done) == n {
+			break
+		}
+		sql, err := os.ReadFile(m.Path)
+		if err != nil {
+			return done, err
+		}
+		var applied bool
+		err = inTx(ctx, conn, func(tx pgx.Tx) error {
+			// Another process may have applied it since Status
+			if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM supabase_migrations.schema_migrations WHERE version = $1)", m.Version).Scan(&applied); err != nil || applied {
+				return err
+			}
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO supabase_migrations.schema_migrations (version, statements, name) VALUES ($1, $2, $3)",
+				m.Version, []string{string(sql)}, m.Name)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s failed: %w", filepath.Base(m.Path), err)
+		}
+		if applied {
+			continue
+		}
+		m.Applied = true
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down reverts the newest n applied migrations (at least one), newest
+// first, with their down files, and returns those it reverted. A
+// migration without a down file stops it with ErrNoDown.
+func Down(ctx context.Context, conn *pgx.Conn, dir string, n int) ([]Migration, error) {
+	if n < 1 {
+		n = 1
+	}
+	migrations, err := Status(ctx, conn, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < n; i-- {
+		m := migrations[i]
+		if !m.Applied {
+			continue
+		}
+		if m.DownPath == "" {
+			return done, fmt.Errorf("%w for %s_%s", ErrNoDown, m.Version, m.Name)
+		}
+		sql, err := os.ReadFile(m.DownPath)
+		if err != nil {
+			return done, err
+		}
+		err = inTx(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM supabase_migrations.schema_migrations WHERE version = $1", m.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("down migration %s failed: %w", filepath.Base(m.DownPath), err)
+		}
+		m.Applied = false
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// inTx runs fn in a transaction holding the migration lock.
+func inTx(ctx context.Context, conn *pgx.Conn, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", int64(lockKey)); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// appliedVersions returns the names of the applied versions, by version.
+func appliedVersions(ctx context.Context, conn *pgx.Conn) (map[string]string, error) {
+	rows, err := conn.Query(ctx, "SELECT version, coalesce(name, '') FROM supabase_migrations.schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := map[string]string{}
+	for rows.Next() {
+		var version, name string
+		if err := rows.Scan(&version, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[version] = name
+	}
+	return applied, rows.Err()
+}
+
+// sortByVersion sorts numerically: shorter versions first, as their
+// digits are fewer.
+func sortByVersion(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		a, b := migrations[i].Version, migrations[j].Version
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20240201000000_add_posts.sql",
+		"20240101000000_create_profiles.sql",
+		"README.md",
+		"seed.sql",
+		"down/20240201000000_add_posts.sql",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("loaded %d migrations, want 2: %+v", len(migrations), migrations)
+	}
+	if m := migrations[0]; m.Version != "20240101000000" || m.Name != "create_profiles" || m.DownPath != "" {
+		t.Errorf("first = %+v", m)
+	}
+	if m := migrations[1]; m.Name != "add_posts" || m.DownPath != filepath.Join(dir, "down", "20240201000000_add_posts.sql") {
+		t.Errorf("second = %+v", m)
+	}
+
+	if migrations, err := Load(filepath.Join(dir, "missing")); err != nil || migrations != nil {
+		t.Errorf("Load(missing) = %v, %v", migrations, err)
+	}
+}
+
+func TestLoad_DuplicateVersion(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "1_a.sql"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "1_b.sql"), nil, 0644)
+
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() succeeded with two migrations of version 1")
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	now := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+
+	m, err := New(dir, "Create Todos", now)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if m.Path != filepath.Join(dir, "20240305143000_create_todos.sql") {
+		t.Errorf("Path = %s", m.Path)
+	}
+	migrations, err := Load(dir)
+	if err != nil || len(migrations) != 1 || migrations[0].DownPath != m.DownPath {
+		t.Errorf("Load() = %+v, %v, want the new migration with its down file", migrations, err)
+	}
+
+	if _, err := New(dir, "other", now); err == nil {
+		t.Error("New() succeeded with a version already in use")
+	}
+	if _, err := New(dir, "../escape", now.Add(time.Second)); err == nil {
+		t.Error("New() accepted a name with a slash")
+	}
+}
+
+func TestSortByVersion(t *testing.T) {
+	migrations := []Migration{{Version: "10"}, {Version: "9"}, {Version: "100"}}
+	sortByVersion(migrations)
+	if migrations[0].Version != "9" || migrations[1].Version != "10" || migrations[2].Version != "100" {
+		t.Errorf("sorted = %+v", migrations)
+	}
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markb/supalite/webhook"
+)
+
+// httpClient posts to webhooks. Each send is bounded by its context too.
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// SlackChannel posts events to a Slack incoming webhook.
+type SlackChannel struct {
+	URL string
+}
+
+func (c *SlackChannel) Name() string { return "slack" }
+
+// Send posts the event as a Slack message.
+func (c *SlackChannel) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(map[string]string{"text": e.Text()})
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.URL, body, nil)
+}
+
+// WebhookChannel posts events as JSON to a URL. Requests are signed with
+// Secret like supalite's other webhooks (see package webhook) when it is
+// set.
+type WebhookChannel struct {
+	URL    string
+	Secret string // Optional: "whsec_..." secret
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+// Send posts the event.
+func (c *WebhookChannel) Send(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return post(ctx, c.URL, body, func(h http.Header) {
+		if c.Secret != "" {
+			webhook.SetHeaders(h, c.Secret, "msg_"+uuid.NewString(), time.Now(), body)
+		}
+	})
+}
+
+// post sends a JSON body. Any non-2xx response is an error.
+func post(ctx context.Context, url string, body []byte, headers func(http.Header)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if headers != nil {
+		headers(req.Header)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailChannel mails events through an SMTP server.
+type EmailChannel struct {
+	Host string
+	Port int    // Default: 587
+	User string // Optional: SMTP auth
+	Pass string
+	From string
+	To   []string
+}
+
+func (c *EmailChannel) Name() string { return "email" }
+
+// Send mails the event to every recipient.
+func (c *EmailChannel) Send(ctx context.Context, e Event) error {
+	port := c.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if c.User != "" {
+		auth = smtp.PlainAuth("", c.User, c.Pass, c.Host)
+	}
+
+	// net/smtp has no context support; run it aside and give up on cancel
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(addr, auth, c.From, c.To, c.message(e)) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message formats the event as an RFC 5322 message.
+func (c *EmailChannel) message(e Event) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", e.Title())
+	fmt.Fprintf(&b, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(e.Text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,215 @@
+// Package notify alerts the people running supalite about operational
+// problems: a component that crashed or could not be restarted, a failed
+// backup, a disk running full, or a burst of failed authentication.
+//
+// Events go to every configured channel (a Slack incoming webhook, a
+// signed generic webhook, or email). Delivery happens in the background and
+// never blocks the caller, and an event that keeps recurring is only sent
+// once per cooldown, so a flapping component doesn't flood a channel.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+// Severity ranks events.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// rank orders severities from least to most severe.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// ParseSeverity parses "info", "warning", or "critical".
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(s)); sev {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (use info, warning, or critical)", s)
+}
+
+// Event kinds
+const (
+	KindComponentRestarted = "component_restarted"      // A child process crashed and was restarted
+	KindRestartFailed      = "component_restart_failed" // A child process crashed and stayed down
+	KindBackupFailed       = "backup_failed"
+	KindDiskLow            = "disk_low"
+	KindDiskFull           = "disk_full"
+	KindDiskRecovered      = "disk_recovered"
+	KindAuthFailures       = "auth_failures"  // Repeated failed authentication from one client
+	KindQuotaWarning       = "quota_warning"  // A usage soft limit was reached
+	KindQuotaExceeded      = "quota_exceeded" // A usage hard limit was reached; requests are refused
+)
+
+// DefaultCooldown is how long an event is suppressed after it was sent.
+const DefaultCooldown = 10 * time.Minute
+
+// sendTimeout bounds the delivery of one event to one channel.
+const sendTimeout = 10 * time.Second
+
+// Event is something worth telling an operator about.
+type Event struct {
+	Kind      string            `json:"kind"`
+	Severity  Severity          `json:"severity"`
+	Component string            `json:"component,omitempty"` // e.g. "gotrue", "disk", "auth"
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Instance  string            `json:"instance"` // Which supalite sent it (default: the host name)
+	Time      time.Time         `json:"time"`
+}
+
+// Title returns a one-line summary of the event.
+func (e Event) Title() string {
+	return fmt.Sprintf("[supalite %s] %s: %s", e.Instance, strings.ToUpper(string(e.Severity)), e.Message)
+}
+
+// Text returns the event as plain text, the title followed by its details.
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Title())
+	names := make([]string, 0, len(e.Details))
+	for name := range e.Details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n%s: %s", name, e.Details[name])
+	}
+	fmt.Fprintf(&b, "\ntime: %s", e.Time.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// Channel delivers events somewhere.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, e Event) error
+}
+
+// Config holds the configuration for a Notifier.
+type Config struct {
+	Channels    []Channel
+	MinSeverity Severity      // Optional: drop less severe events (default: info)
+	Cooldown    time.Duration // Optional: suppress repeats of an event (default: 10m)
+	Instance    string        // Optional: name of this instance (default: the host name)
+}
+
+// Notifier sends events to channels.
+type Notifier struct {
+	config Config
+	now    func() time.Time
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	sent map[string]time.Time // when each kind/component was last sent
+}
+
+// New creates a notifier. It returns nil when no channel is configured;
+// a nil notifier drops all events, so callers needn't check.
+func New(cfg Config) *Notifier {
+	if len(cfg.Channels) == 0 {
+		return nil
+	}
+	if cfg.MinSeverity == "" {
+		cfg.MinSeverity = SeverityInfo
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultCooldown
+	}
+	if cfg.Instance == "" {
+		cfg.Instance, _ = os.Hostname()
+	}
+	return &Notifier{config: cfg, now: time.Now, sent: map[string]time.Time{}}
+}
+
+// Notify sends an event to every channel in the background. Events below
+// the minimum severity, and repeats within the cooldown, are dropped.
+func (n *Notifier) Notify(e Event) {
+	if n == nil || !n.admit(&e) {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		n.deliver(ctx, e)
+	}()
+}
+
+// Send sends an event to every channel and waits for delivery, for short
+// lived commands that exit right after. It returns the first delivery
+// error.
+func (n *Notifier) Send(ctx context.Context, e Event) error {
+	if n == nil || !n.admit(&e) {
+		return nil
+	}
+	return n.deliver(ctx, e)
+}
+
+// Wait blocks until events sent with Notify have been delivered.
+func (n *Notifier) Wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+// admit fills in defaults and applies the severity filter and cooldown.
+func (n *Notifier) admit(e *Event) bool {
+	if e.Severity == "" {
+		e.Severity = SeverityInfo
+	}
+	if e.Severity.rank() < n.config.MinSeverity.rank() {
+		return false
+	}
+	if e.Time.IsZero() {
+		e.Time = n.now()
+	}
+	if e.Instance == "" {
+		e.Instance = n.config.Instance
+	}
+
+	key := e.Kind + "/" + e.Component
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if last, ok := n.sent[key]; ok && e.Time.Sub(last) < n.config.Cooldown {
+		log.Debug("notification suppressed", "kind", e.Kind, "component", e.Component)
+		return false
+	}
+	n.sent[key] = e.Time
+	return true
+}
+
+// deliver sends e to every channel, logging failures.
+func (n *Notifier) deliver(ctx context.Context, e Event) error {
+	var first error
+	for _, ch := range n.config.Channels {
+		if err := ch.Send(ctx, e); err != nil {
+			log.Warn("failed to send notification", "channel", ch.Name(), "kind", e.Kind, "error", err)
+			if first == nil {
+				first = fmt.Errorf("%s: %w", ch.Name(), err)
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/webhook"
+)
+
+// recordChannel keeps the events sent to it.
+type recordChannel struct {
+	events []Event
+	err    error
+}
+
+func (c *recordChannel) Name() string { return "record" }
+
+func (c *recordChannel) Send(ctx context.Context, e Event) error {
+	c.events = append(c.events, e)
+	return c.err
+}
+
+func TestNew_NoChannels(t *testing.T) {
+	n := New(Config{})
+	if n != nil {
+		t.Fatal("New without channels should return nil")
+	}
+	// A nil notifier drops events
+	n.Notify(Event{Kind: KindDiskLow})
+	n.Wait()
+	if err := n.Send(context.Background(), Event{Kind: KindDiskLow}); err != nil {
+		t.Errorf("Send on nil notifier: %v", err)
+	}
+}
+
+func TestSend_SeverityAndCooldown(t *testing.T) {
+	ch := &recordChannel{}
+	n := New(Config{Channels: []Channel{ch}, MinSeverity: SeverityWarning, Cooldown: time.Minute, Instance: "prod"})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	n.Send(ctx, Event{Kind: KindDiskRecovered, Severity: SeverityInfo, Component: "disk"})
+	n.Send(ctx, Event{Kind: KindDiskLow, Severity: SeverityWarning, Component: "disk"})
+	n.Send(ctx, Event{Kind: KindDiskLow, Severity: SeverityWarning, Component: "disk"}) // within cooldown
+	n.Send(ctx, Event{Kind: KindDiskFull, Severity: SeverityCritical, Component: "disk"})
+
+	now = now.Add(2 * time.Minute)
+	n.Send(ctx, Event{Kind: KindDiskLow, Severity: SeverityWarning, Component: "disk"})
+
+	var kinds []string
+	for _, e := range ch.events {
+		kinds = append(kinds, e.Kind)
+		if e.Instance != "prod" || e.Time.IsZero() {
+			t.Errorf("event %s: instance %q, time %v", e.Kind, e.Instance, e.Time)
+		}
+	}
+	want := []string{KindDiskLow, KindDiskFull, KindDiskLow}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", kinds, want)
+	}
+}
+
+func TestSend_ChannelError(t *testing.T) {
+	failing := &recordChannel{err: errors.New("boom")}
+	ok := &recordChannel{}
+	n := New(Config{Channels: []Channel{failing, ok}})
+
+	err := n.Send(context.Background(), Event{Kind: KindBackupFailed, Severity: SeverityCritical})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Send error = %v, want boom", err)
+	}
+	if len(ok.events) != 1 {
+		t.Error("a failing channel should not stop delivery to the others")
+	}
+}
+
+func TestNotify_Async(t *testing.T) {
+	ch := &recordChannel{}
+	n := New(Config{Channels: []Channel{ch}})
+	n.Notify(Event{Kind: KindAuthFailures, Severity: SeverityWarning})
+	n.Wait()
+	if len(ch.events) != 1 {
+		t.Errorf("got %d events, want 1", len(ch.events))
+	}
+}
+
+func TestWebhookChannel(t *testing.T) {
+	const secret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	ch := &WebhookChannel{URL: srv.URL, Secret: secret}
+	e := Event{Kind: KindBackupFailed, Severity: SeverityCritical, Message: "snapshot nightly failed", Time: time.Now()}
+	if err := ch.Send(context.Background(), e); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Kind != KindBackupFailed || got.Message != "snapshot nightly failed" {
+		t.Errorf("received %+v", got)
+	}
+}
+
+func TestSlackChannel(t *testing.T) {
+	var payload map[string]string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	ch := &SlackChannel{URL: srv.URL}
+	e := Event{
+		Kind:     KindRestartFailed,
+		Severity: SeverityCritical,
+		Message:  "GoTrue crashed",
+		Details:  map[string]string{"exit": "signal: killed"},
+		Instance: "prod",
+		Time:     time.Now(),
+	}
+	if err := ch.Send(context.Background(), e); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for _, want := range []string{"[supalite prod] CRITICAL: GoTrue crashed", "exit: signal: killed"} {
+		if !strings.Contains(payload["text"], want) {
+			t.Errorf("text %q missing %q", payload["text"], want)
+		}
+	}
+
+	status = http.StatusNotFound
+	if err := ch.Send(context.Background(), e); err == nil {
+		t.Error("expected error for a 404 from the webhook")
+	}
+}
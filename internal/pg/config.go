@@ -2,7 +2,11 @@ package pg
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/limits"
 )
 
 // Config holds the configuration for the embedded PostgreSQL database
@@ -14,6 +18,41 @@ type Config struct {
 	DataDir     string
 	Version     string
 	RuntimePath string // Optional: unique runtime path to avoid conflicts
+	Offline     bool   // Optional: fail instead of downloading uncached binaries
+	NoSync      bool   // Optional: turn off fsync, for data that needn't survive a crash
+
+	// Listen only on a unix socket in this directory, with no TCP port.
+	// The socket is named after Port, e.g. .s.PGSQL.5432, and the
+	// directory is made private to the user running supalite.
+	SocketDir string // Optional: default TCP on localhost:Port
+
+	// Shared connection pool used by request handlers (see Acquire)
+	MinConns int32 // Optional: idle connections kept open (default 0)
+	MaxConns int32 // Optional: limit of all pools together (default: pgxpool's, max(4, NumCPU))
+
+	// Separate pools for requests running as these roles, by size (see
+	// AcquireAs), carved out of MaxConns. A role sized 0 uses the shared
+	// pool.
+	RolePools map[string]int32 // Optional: default anon MaxConns/4, authenticated MaxConns/2 from 8 MaxConns
+
+	// Tracer observes the queries run on the pools, e.g. to record spans
+	Tracer pgx.QueryTracer // Optional: default none
+
+	// Fresh data directories are copied from a cached initdb template
+	// instead of running initdb (see initcache.go)
+	DisableInitCache bool   // Optional: always run initdb
+	InitCacheDir     string // Optional: template cache location (default: user cache dir)
+
+	// Collation and time zone. The locales are fixed when the cluster is
+	// created, and setting either makes the cluster UTF8. Timezone applies
+	// on every start.
+	Locale    string // Optional: initdb --locale, e.g. "de_DE.UTF-8" (default: the environment's)
+	ICULocale string // Optional: ICU collation for new clusters, e.g. "de-DE" (overrides Locale's collation)
+	Timezone  string // Optional: TimeZone, e.g. "Europe/Berlin" (default: the environment's)
+
+	// Resource limits for the postmaster and its backends (see limits.Apply)
+	Limits        limits.Limits // Optional: memory/CPU caps (default: none)
+	SharedBuffers string        // Optional: shared_buffers (default: sized to the memory limit, if any)
 }
 
 // DefaultConfig returns the default configuration for supalite
@@ -51,7 +90,7 @@ func DefaultConfig() Config {
 		Password: password,
 		Database: database,
 		DataDir:  dataDir,
-		Version:  "16.9.0",
+		Version:  DefaultVersion,
 	}
 }
 
@@ -62,3 +101,12 @@ func parsePort(s string) uint16 {
 	}
 	return 0
 }
+
+// ConnectionURL returns the URL of a database on this machine: on
+// localhost:port, or on the unix socket in socketDir when it is set.
+func ConnectionURL(username, password, database, socketDir string, port int) string {
+	if socketDir == "" {
+		return fmt.Sprintf("postgres://%s:%s@localhost:%d/%s", username, password, port, database)
+	}
+	return fmt.Sprintf("postgres://%s:%s@/%s?host=%s&port=%d", username, password, database, url.QueryEscape(socketDir), port)
+}
@@ -10,14 +10,28 @@ import (
 
 	"github.com/fergusstrange/embedded-postgres"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/limits"
+	"github.com/markb/supalite/internal/log"
 )
 
+// logger logs for the pg component.
+var logger = log.Component("pg")
+
 type EmbeddedDatabase struct {
-	postgres   *embeddedpostgres.EmbeddedPostgres
-	config     Config
-	connString string
-	mu         sync.RWMutex
-	started    bool
+	postgres     *embeddedpostgres.EmbeddedPostgres
+	config       Config
+	connString   string
+	pool         *pgxpool.Pool
+	rolePools    map[string]*pgxpool.Pool // per-role pools (see AcquireAs)
+	mu           sync.RWMutex
+	started      bool
+	tempDataPath string // data directory to remove on Stop (no DataDir configured)
+
+	// pg_ctl and the data directory of a cluster listening on a unix
+	// socket, which embedded-postgres can't stop (see restartOnSocket)
+	pgCtl    string
+	dataPath string
 }
 
 func NewEmbeddedDatabase(cfg Config) *EmbeddedDatabase {
@@ -35,13 +49,12 @@ func NewEmbeddedDatabase(cfg Config) *EmbeddedDatabase {
 		cfg.Database = "postgres"
 	}
 	if cfg.Version == "" {
-		cfg.Version = "16.9.0"
+		cfg.Version = DefaultVersion
 	}
 
 	return &EmbeddedDatabase{
-		config: cfg,
-		connString: fmt.Sprintf("postgres://%s:%s@localhost:%d/%s",
-			cfg.Username, cfg.Password, cfg.Port, cfg.Database),
+		config:     cfg,
+		connString: ConnectionURL(cfg.Username, cfg.Password, cfg.Database, cfg.SocketDir, int(cfg.Port)),
 	}
 }
 
@@ -53,6 +66,14 @@ func (db *EmbeddedDatabase) Start(ctx context.Context) error {
 		return nil
 	}
 
+	// Don't leak a temporary data directory if startup fails
+	defer func() {
+		if !db.started && db.tempDataPath != "" {
+			os.RemoveAll(db.tempDataPath)
+			db.tempDataPath = ""
+		}
+	}()
+
 	config := embeddedpostgres.DefaultConfig().
 		Port(uint32(db.config.Port)).
 		Username(db.config.Username).
@@ -61,16 +82,103 @@ func (db *EmbeddedDatabase) Start(ctx context.Context) error {
 		Version(embeddedpostgres.PostgresVersion(db.config.Version)).
 		StartTimeout(60 * time.Second)
 
+	// The locale is baked into the cluster by initdb (and into the cached
+	// template, see initTemplateKey)
+	if db.config.Locale != "" {
+		config = config.Locale(db.config.Locale)
+	}
+	if db.config.Locale != "" || db.config.ICULocale != "" {
+		config = config.Encoding("UTF8")
+	}
+
 	// Set RuntimePath if provided (for test isolation)
-	if db.config.RuntimePath != "" {
-		config = config.RuntimePath(db.config.RuntimePath)
+	runtimePath := db.config.RuntimePath
+	if runtimePath != "" {
+		config = config.RuntimePath(runtimePath)
 	}
 
+	// embedded-postgres needs a TCP port to create and check the cluster,
+	// so a socket-only cluster starts on a free one (see socket.go). The
+	// binaries are run from the runtime path afterwards, so it must be known.
+	if db.config.SocketDir != "" {
+		socketDir, err := prepareSocketDir(db.config.SocketDir, db.config.Port)
+		if err != nil {
+			return err
+		}
+		db.config.SocketDir = socketDir
+		db.connString = ConnectionURL(db.config.Username, db.config.Password, db.config.Database, socketDir, int(db.config.Port))
+
+		port, err := freeLoopbackPort()
+		if err != nil {
+			return err
+		}
+		config = config.Port(uint32(port))
+		if runtimePath == "" {
+			runtimePath = defaultRuntimePath()
+			config = config.RuntimePath(runtimePath)
+		}
+	}
+
+	var dataPath string
 	if db.config.DataDir != "" {
 		if err := os.MkdirAll(db.config.DataDir, 0755); err != nil {
 			return fmt.Errorf("failed to create data directory: %w", err)
 		}
-		config = config.DataPath(filepath.Join(db.config.DataDir, "data"))
+		dataPath = ClusterPath(db.config.DataDir)
+	}
+
+	// A new cluster gets the ICU collation once it is running
+	fresh := dataPath == "" || !isClusterDir(dataPath)
+
+	if db.config.Offline {
+		if err := checkCachedBinaries(db.config.Version); err != nil {
+			return err
+		}
+	}
+
+	if !db.config.DisableInitCache {
+		// Without a DataDir the cluster would live inside RuntimePath, which
+		// embedded-postgres wipes before starting, so use a temporary one
+		if dataPath == "" {
+			if tmp, err := os.MkdirTemp("", "supalite-pgdata-"); err == nil {
+				dataPath = tmp
+				db.tempDataPath = tmp
+			}
+		}
+		if dataPath != "" {
+			if err := db.seedDataDir(config, dataPath); err != nil {
+				logger.Warn("initdb template unavailable, running initdb", "error", err)
+			}
+		}
+	}
+
+	if dataPath == "" && db.config.SocketDir != "" {
+		dataPath = filepath.Join(runtimePath, "data")
+	}
+	if dataPath != "" {
+		config = config.DataPath(dataPath)
+	}
+
+	params := make(map[string]string)
+	sharedBuffers := db.config.SharedBuffers
+	if sharedBuffers == "" {
+		sharedBuffers = limits.SharedBuffers(limits.PostgresMemory(db.config.Limits))
+	}
+	if sharedBuffers != "" {
+		logger.Info("setting PostgreSQL shared_buffers", "shared_buffers", sharedBuffers)
+		params["shared_buffers"] = sharedBuffers
+	}
+	if db.config.Timezone != "" {
+		params["timezone"] = db.config.Timezone
+	}
+	if db.config.NoSync {
+		// Nothing survives a crash anyway, so skip flushing to disk
+		params["fsync"] = "off"
+		params["synchronous_commit"] = "off"
+		params["full_page_writes"] = "off"
+	}
+	if len(params) > 0 {
+		config = config.StartParameters(params)
 	}
 
 	db.postgres = embeddedpostgres.NewDatabase(config)
@@ -89,14 +197,81 @@ func (db *EmbeddedDatabase) Start(ctx context.Context) error {
 		return fmt.Errorf("postgres start timed out: %w", ctx.Err())
 	}
 
+	if db.config.SocketDir != "" {
+		if err := db.restartOnSocket(ctx, runtimePath, dataPath, params); err != nil {
+			return err
+		}
+	}
+
 	if err := db.waitReady(ctx); err != nil {
 		return fmt.Errorf("postgres not ready: %w", err)
 	}
 
+	if fresh && db.config.ICULocale != "" {
+		if err := db.applyICULocale(ctx); err != nil {
+			db.stopPostgres()
+			return err
+		}
+	}
+
+	pool, roleSizes, err := newPool(ctx, db.connString, db.config)
+	if err != nil {
+		db.stopPostgres()
+		return fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	db.pool = pool
+
+	rolePools, err := newRolePools(ctx, db.connString, db.config, roleSizes)
+	if err != nil {
+		pool.Close()
+		db.stopPostgres()
+		return fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	db.rolePools = rolePools
+
+	if !fresh {
+		db.checkLocale(ctx)
+	}
+
+	if !db.config.Limits.IsZero() {
+		if err := db.applyLimits(ctx); err != nil {
+			logger.Warn("could not apply PostgreSQL resource limits", "error", err)
+		}
+	}
+
 	db.started = true
 	return nil
 }
 
+// applyLimits caps the running postmaster, found through its
+// postmaster.pid.
+func (db *EmbeddedDatabase) applyLimits(ctx context.Context) error {
+	var dataDir string
+	if err := db.pool.QueryRow(ctx, "SHOW data_directory").Scan(&dataDir); err != nil {
+		return err
+	}
+	postmaster, err := ReadPostmaster(dataDir)
+	if err != nil {
+		return err
+	}
+	return limits.Apply("postgres", postmaster.PID, db.config.Limits)
+}
+
+// checkCachedBinaries verifies that PostgreSQL binaries for version are in
+// embedded-postgres' download cache, so starting won't hit the network
+func checkCachedBinaries(version string) error {
+	cacheDir := ".embedded-postgres-go"
+	if home, err := os.UserHomeDir(); err == nil {
+		cacheDir = filepath.Join(home, ".embedded-postgres-go")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(cacheDir, "embedded-postgres-binaries-*-"+version+".txz"))
+	if len(matches) == 0 {
+		return fmt.Errorf("PostgreSQL %s binaries are not cached in %s and downloads are disabled", version, cacheDir)
+	}
+	return nil
+}
+
 func (db *EmbeddedDatabase) Stop() {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -105,8 +280,17 @@ func (db *EmbeddedDatabase) Stop() {
 		return
 	}
 
-	if db.postgres != nil {
-		db.postgres.Stop()
+	// Close pooled connections before the server goes away
+	closePools(db.rolePools)
+	db.rolePools = nil
+	if db.pool != nil {
+		db.pool.Close()
+		db.pool = nil
+	}
+	db.stopPostgres()
+	if db.tempDataPath != "" {
+		os.RemoveAll(db.tempDataPath)
+		db.tempDataPath = ""
 	}
 	db.started = false
 }
@@ -115,10 +299,64 @@ func (db *EmbeddedDatabase) ConnectionString() string {
 	return db.connString
 }
 
+// Connect opens a dedicated connection, for work that holds a connection
+// for a long time (LISTEN) or changes session state. Request handlers
+// should use Acquire instead.
 func (db *EmbeddedDatabase) Connect(ctx context.Context) (*pgx.Conn, error) {
 	return pgx.Connect(ctx, db.connString)
 }
 
+// Acquire borrows a connection from the shared pool. Release it when done;
+// anything set with SET LOCAL or set_config(..., true) ends with its
+// transaction, so per-request roles and claims don't leak between users.
+func (db *EmbeddedDatabase) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	db.mu.RLock()
+	pool := db.pool
+	db.mu.RUnlock()
+
+	if pool == nil {
+		return nil, fmt.Errorf("database is not started")
+	}
+	return pool.Acquire(ctx)
+}
+
+// Pool returns the shared connection pool, or nil before Start.
+func (db *EmbeddedDatabase) Pool() *pgxpool.Pool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.pool
+}
+
+// newPool creates the shared connection pool to connString from the
+// configured limits, less the connections of the role pools, whose sizes
+// it returns.
+func newPool(ctx context.Context, connString string, cfg Config) (*pgxpool.Pool, map[string]int32, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	roleSizes, shared, err := splitPools(cfg.RolePools, poolConfig.MaxConns)
+	if err != nil {
+		return nil, nil, err
+	}
+	poolConfig.MaxConns = shared
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		poolConfig.MinConns = poolConfig.MaxConns
+	}
+	poolConfig.ConnConfig.Tracer = cfg.Tracer
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pool, roleSizes, nil
+}
+
 func (db *EmbeddedDatabase) waitReady(ctx context.Context) error {
 	const maxRetries = 60
 	const retryDelay = 500 * time.Millisecond
@@ -40,3 +40,66 @@ func TestEmbeddedDatabase_Start(t *testing.T) {
 		t.Errorf("Expected 1, got %d", result)
 	}
 }
+
+func TestEmbeddedDatabase_CloneDatabase(t *testing.T) {
+	db := NewEmbeddedDatabase(Config{
+		Port:        15433,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-pg-clone",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer db.Stop()
+
+	err := db.CreateTemplate(ctx, "fixture", func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "CREATE TABLE items (id int); INSERT INTO items VALUES (1), (2)")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+	defer db.DropDatabase(ctx, "fixture")
+
+	// Two clones must be independent of each other
+	first, err := db.CloneDatabase(ctx, "fixture")
+	if err != nil {
+		t.Fatalf("CloneDatabase() failed: %v", err)
+	}
+	defer db.DropDatabase(ctx, first)
+
+	second, err := db.CloneDatabase(ctx, "fixture")
+	if err != nil {
+		t.Fatalf("CloneDatabase() failed: %v", err)
+	}
+	defer db.DropDatabase(ctx, second)
+
+	conn, err := db.ConnectDatabase(ctx, first)
+	if err != nil {
+		t.Fatalf("ConnectDatabase() failed: %v", err)
+	}
+	if _, err := conn.Exec(ctx, "DELETE FROM items"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	conn.Close(ctx)
+
+	conn, err = db.ConnectDatabase(ctx, second)
+	if err != nil {
+		t.Fatalf("ConnectDatabase() failed: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	var count int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM items").Scan(&count); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("second clone has %d rows, want 2", count)
+	}
+}
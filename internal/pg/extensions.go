@@ -0,0 +1,57 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// extensionAliases maps the names extensions are known by to the names
+// CREATE EXTENSION takes.
+var extensionAliases = map[string]string{
+	"pgvector":  "vector",
+	"uuid_ossp": "uuid-ossp",
+}
+
+// ExtensionName returns the name CREATE EXTENSION takes for an extension,
+// so pgvector can be asked for by its project name.
+func ExtensionName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := extensionAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// EnableExtensions creates the named extensions that aren't installed yet.
+// An extension the server has no files for is reported with the ones it
+// has, rather than PostgreSQL's "could not open extension control file".
+func EnableExtensions(ctx context.Context, conn *pgx.Conn, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	rows, err := conn.Query(ctx, "SELECT name FROM pg_available_extensions")
+	if err != nil {
+		return fmt.Errorf("failed to list available extensions: %w", err)
+	}
+	available, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to list available extensions: %w", err)
+	}
+	slices.Sort(available)
+
+	for _, name := range names {
+		ext := ExtensionName(name)
+		if !slices.Contains(available, ext) {
+			return fmt.Errorf("extension %q is not available in this PostgreSQL (available: %s)", name, strings.Join(available, ", "))
+		}
+		if _, err := conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS "+pgx.Identifier{ext}.Sanitize()+" CASCADE"); err != nil {
+			return fmt.Errorf("failed to create extension %q: %w", ext, err)
+		}
+	}
+	return nil
+}
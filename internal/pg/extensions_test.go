@@ -0,0 +1,19 @@
+package pg
+
+import "testing"
+
+func TestExtensionName(t *testing.T) {
+	tests := map[string]string{
+		"pgvector":  "vector",
+		"vector":    "vector",
+		" PG_TRGM ": "pg_trgm",
+		"uuid-ossp": "uuid-ossp",
+		"uuid_ossp": "uuid-ossp",
+		"postgis":   "postgis",
+	}
+	for in, want := range tests {
+		if got := ExtensionName(in); got != want {
+			t.Errorf("ExtensionName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ExternalDatabase is a PostgreSQL server supalite doesn't run, such as a
+// managed database, given by a connection string. It offers the same
+// connections as EmbeddedDatabase, so the APIs are served the same way.
+type ExternalDatabase struct {
+	config     Config
+	connString string
+	pool       *pgxpool.Pool
+	rolePools  map[string]*pgxpool.Pool
+	mu         sync.RWMutex
+}
+
+// NewExternalDatabase returns the database at connString, a postgres://
+// URL. Of cfg, only the pool settings and Tracer apply: the server's
+// data directory, version, locale, and limits are its own.
+func NewExternalDatabase(connString string, cfg Config) *ExternalDatabase {
+	return &ExternalDatabase{config: cfg, connString: connString}
+}
+
+// Start opens the connection pools, once the server answers.
+func (db *ExternalDatabase) Start(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.pool != nil {
+		return nil
+	}
+
+	pool, roleSizes, err := newPool(ctx, db.connString, db.config)
+	if err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := pool.Ping(pingCtx); err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to connect to %s: %w", db.describe(), err)
+	}
+
+	rolePools, err := newRolePools(ctx, db.connString, db.config, roleSizes)
+	if err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	db.pool = pool
+	db.rolePools = rolePools
+	return nil
+}
+
+// Stop closes the pools. The server itself keeps running.
+func (db *ExternalDatabase) Stop() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	closePools(db.rolePools)
+	db.rolePools = nil
+	if db.pool != nil {
+		db.pool.Close()
+		db.pool = nil
+	}
+}
+
+func (db *ExternalDatabase) ConnectionString() string {
+	return db.connString
+}
+
+// Connect opens a dedicated connection, like EmbeddedDatabase.Connect.
+func (db *ExternalDatabase) Connect(ctx context.Context) (*pgx.Conn, error) {
+	return pgx.Connect(ctx, db.connString)
+}
+
+// Acquire borrows a connection from the shared pool, like
+// EmbeddedDatabase.Acquire.
+func (db *ExternalDatabase) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	db.mu.RLock()
+	pool := db.pool
+	db.mu.RUnlock()
+
+	if pool == nil {
+		return nil, fmt.Errorf("database is not started")
+	}
+	return pool.Acquire(ctx)
+}
+
+// AcquireAs borrows a connection for a request that will run as role, like
+// EmbeddedDatabase.AcquireAs.
+func (db *ExternalDatabase) AcquireAs(ctx context.Context, role string) (*pgxpool.Conn, error) {
+	db.mu.RLock()
+	pool, ok := db.rolePools[role]
+	db.mu.RUnlock()
+
+	if !ok {
+		return db.Acquire(ctx)
+	}
+	return pool.Acquire(ctx)
+}
+
+// Pool returns the shared connection pool, or nil before Start.
+func (db *ExternalDatabase) Pool() *pgxpool.Pool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.pool
+}
+
+// describe names the server for messages, without the password.
+func (db *ExternalDatabase) describe() string {
+	cfg, err := pgx.ParseConfig(db.connString)
+	if err != nil {
+		return "the database"
+	}
+	return fmt.Sprintf("PostgreSQL at %s:%d/%s", cfg.Host, cfg.Port, cfg.Database)
+}
@@ -0,0 +1,176 @@
+package pg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fergusstrange/embedded-postgres"
+)
+
+// Running initdb dominates cold start. Instead of running it for every fresh
+// data directory, an initialized and cleanly stopped cluster is kept per
+// PostgreSQL version and credentials, and copied into new data directories.
+// embedded-postgres then sees a valid data directory and skips initdb.
+
+// defaultInitCacheDir returns the directory holding cached initdb templates.
+func defaultInitCacheDir() string {
+	if dir := os.Getenv("SUPALITE_INITDB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "supalite", "initdb")
+	}
+	return filepath.Join(os.TempDir(), "supalite-initdb")
+}
+
+// initTemplateKey identifies a template. The superuser, its password, the
+// initial database, and the locale are baked into the cluster, so they are
+// part of the key.
+func (db *EmbeddedDatabase) initTemplateKey() string {
+	baked := db.config.Username + "\x00" + db.config.Password + "\x00" + db.config.Database
+	if db.config.Locale != "" || db.config.ICULocale != "" {
+		// UTF8, and the libc locale if set (the ICU collation is applied
+		// to each copy)
+		baked += "\x00UTF8\x00" + db.config.Locale
+	}
+	sum := sha256.Sum256([]byte(baked))
+	return db.config.Version + "-" + hex.EncodeToString(sum[:8])
+}
+
+// seedDataDir copies a cached initdb template into dataPath unless it
+// already holds a cluster. The template is built on first use.
+func (db *EmbeddedDatabase) seedDataDir(base embeddedpostgres.Config, dataPath string) error {
+	if isClusterDir(dataPath) {
+		return nil
+	}
+
+	template, err := db.initTemplate(base)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(dataPath); err != nil {
+		return fmt.Errorf("failed to clear data directory: %w", err)
+	}
+	if err := CopyDir(template, dataPath); err != nil {
+		os.RemoveAll(dataPath)
+		return fmt.Errorf("failed to copy initdb template: %w", err)
+	}
+	return nil
+}
+
+// initTemplate returns the cached template directory, building it if needed
+// by starting and cleanly stopping a throwaway cluster.
+func (db *EmbeddedDatabase) initTemplate(base embeddedpostgres.Config) (string, error) {
+	cacheDir := db.config.InitCacheDir
+	if cacheDir == "" {
+		cacheDir = defaultInitCacheDir()
+	}
+	template := filepath.Join(cacheDir, db.initTemplateKey())
+	if isClusterDir(template) {
+		return template, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create initdb cache: %w", err)
+	}
+
+	// Build next to the final location and rename, so concurrent builders
+	// never observe a half-written template
+	building, err := os.MkdirTemp(cacheDir, db.initTemplateKey()+".building-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create template directory: %w", err)
+	}
+	defer os.RemoveAll(building)
+
+	runtimePath, err := os.MkdirTemp("", "supalite-initdb-runtime-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+	defer os.RemoveAll(runtimePath)
+
+	dataPath := filepath.Join(building, "data")
+	postgres := embeddedpostgres.NewDatabase(base.DataPath(dataPath).RuntimePath(runtimePath))
+	if err := postgres.Start(); err != nil {
+		return "", fmt.Errorf("failed to initialize template cluster: %w", err)
+	}
+	if err := postgres.Stop(); err != nil {
+		return "", fmt.Errorf("failed to stop template cluster: %w", err)
+	}
+
+	if err := os.Rename(dataPath, template); err != nil {
+		// Another process may have won the race
+		if isClusterDir(template) {
+			return template, nil
+		}
+		return "", fmt.Errorf("failed to store initdb template: %w", err)
+	}
+	return template, nil
+}
+
+// isClusterDir reports whether dir contains an initialized cluster.
+func isClusterDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "PG_VERSION"))
+	return err == nil
+}
+
+// CopyDir recursively copies src to dst, preserving permissions.
+// PostgreSQL refuses to start if the data directory isn't private.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case strings.HasSuffix(path, "postmaster.pid"):
+			// Never carry over a stale lock file
+			return nil
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,87 @@
+package pg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyDir_PreservesPermissions(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "base", "1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "base", "1", "1259"), []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "postmaster.pid"), []byte("123"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "data")
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatalf("CopyDir() failed: %v", err)
+	}
+
+	if !isClusterDir(dst) {
+		t.Error("copied directory should contain PG_VERSION")
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "base", "1", "1259"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("nested file not copied: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "postmaster.pid")); !os.IsNotExist(err) {
+		t.Error("postmaster.pid should not be copied")
+	}
+	info, err := os.Stat(filepath.Join(dst, "base"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("directory permissions = %v, want 0700", info.Mode().Perm())
+	}
+}
+
+func TestInitTemplateKey_DependsOnCredentials(t *testing.T) {
+	a := NewEmbeddedDatabase(Config{Username: "postgres", Password: "one"})
+	b := NewEmbeddedDatabase(Config{Username: "postgres", Password: "two"})
+
+	if a.initTemplateKey() == b.initTemplateKey() {
+		t.Error("templates with different passwords must not share a key")
+	}
+}
+
+func TestInitTemplateKey_DependsOnLocale(t *testing.T) {
+	keys := make(map[string]bool)
+	for _, cfg := range []Config{
+		{},
+		{Locale: "de_DE.UTF-8"},
+		{Locale: "sv_SE.UTF-8"},
+		{ICULocale: "de-DE"}, // UTF8, with the ICU collation applied to each copy
+	} {
+		key := NewEmbeddedDatabase(cfg).initTemplateKey()
+		if keys[key] {
+			t.Errorf("template key for %+v is shared with another locale", cfg)
+		}
+		keys[key] = true
+	}
+
+	// The ICU locale itself isn't baked into the template
+	a := NewEmbeddedDatabase(Config{ICULocale: "de-DE"})
+	b := NewEmbeddedDatabase(Config{ICULocale: "sv-SE"})
+	if a.initTemplateKey() != b.initTemplateKey() {
+		t.Error("templates for different ICU locales should be shared")
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	if normalizeLocale("en_US.UTF-8") != normalizeLocale("en_US.utf8") {
+		t.Error("en_US.UTF-8 and en_US.utf8 are the same locale")
+	}
+	if normalizeLocale("en_US.UTF-8") == normalizeLocale("de_DE.UTF-8") {
+		t.Error("different locales must not match")
+	}
+}
@@ -0,0 +1,134 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier runs a query returning one row. *pgx.Conn and *pgxpool.Conn
+// satisfy it.
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// LocaleInfo is the collation, encoding, and time zone the current
+// database uses.
+type LocaleInfo struct {
+	Encoding  string `json:"encoding"`             // e.g. "UTF8"
+	Provider  string `json:"provider"`             // Collation provider: "libc", "icu", or "builtin"
+	Collate   string `json:"collate"`              // libc LC_COLLATE, e.g. "en_US.UTF-8"
+	Ctype     string `json:"ctype"`                // libc LC_CTYPE
+	ICULocale string `json:"icu_locale,omitempty"` // ICU locale, when Provider is "icu"
+	Timezone  string `json:"timezone"`             // TimeZone setting, e.g. "Etc/UTC"
+}
+
+// ReadLocale returns the locale settings of the database q is connected to.
+func ReadLocale(ctx context.Context, q Querier) (LocaleInfo, error) {
+	// pg_database's locale columns differ between versions (daticulocale
+	// became datlocale in 17, and 14 has neither), so read the row as JSON
+	var row []byte
+	var info LocaleInfo
+	err := q.QueryRow(ctx, `
+		SELECT to_jsonb(d), pg_encoding_to_char(d.encoding), current_setting('TimeZone')
+		FROM pg_database d
+		WHERE d.datname = current_database()
+	`).Scan(&row, &info.Encoding, &info.Timezone)
+	if err != nil {
+		return LocaleInfo{}, fmt.Errorf("failed to read locale: %w", err)
+	}
+
+	var d struct {
+		Collate      string `json:"datcollate"`
+		Ctype        string `json:"datctype"`
+		Provider     string `json:"datlocprovider"`
+		ICULocale    string `json:"daticulocale"`
+		BuiltinOrICU string `json:"datlocale"`
+	}
+	if err := json.Unmarshal(row, &d); err != nil {
+		return LocaleInfo{}, fmt.Errorf("failed to read locale: %w", err)
+	}
+	info.Collate, info.Ctype = d.Collate, d.Ctype
+	switch d.Provider {
+	case "i":
+		info.Provider = "icu"
+		info.ICULocale = d.ICULocale
+		if info.ICULocale == "" {
+			info.ICULocale = d.BuiltinOrICU
+		}
+	case "b":
+		info.Provider = "builtin"
+	default:
+		info.Provider = "libc"
+	}
+	return info, nil
+}
+
+// applyICULocale gives template1 and the configured database an ICU
+// default collation. embedded-postgres can't pass the ICU options to
+// initdb, and a database's collation is fixed when it is created, so both
+// are recreated from template0. This only runs on a cluster that was just
+// created and holds no data yet.
+func (db *EmbeddedDatabase) applyICULocale(ctx context.Context) error {
+	options := fmt.Sprintf("TEMPLATE template0 ENCODING 'UTF8' LOCALE_PROVIDER icu ICU_LOCALE '%s'",
+		strings.ReplaceAll(db.config.ICULocale, "'", "''"))
+
+	recreate := func(connectTo, name string, isTemplate bool) error {
+		conn, err := db.ConnectDatabase(ctx, connectTo)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(context.Background())
+
+		ident := pgx.Identifier{name}.Sanitize()
+		stmts := []string{
+			fmt.Sprintf("ALTER DATABASE %s IS_TEMPLATE false", ident),
+			fmt.Sprintf("DROP DATABASE %s", ident),
+			fmt.Sprintf("CREATE DATABASE %s %s IS_TEMPLATE %t", ident, options, isTemplate),
+		}
+		for _, stmt := range stmts {
+			if _, err := conn.Exec(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to recreate database %s with ICU locale %q: %w", name, db.config.ICULocale, err)
+			}
+		}
+		return nil
+	}
+
+	// template1 first, so databases created later inherit the collation
+	if err := recreate(db.config.Database, "template1", true); err != nil {
+		return err
+	}
+	if err := recreate("template1", db.config.Database, false); err != nil {
+		return err
+	}
+	logger.Info("PostgreSQL collation set", "icu_locale", db.config.ICULocale)
+	return nil
+}
+
+// checkLocale warns when an existing cluster's locale differs from the
+// configured one, which only applies to clusters created after the change.
+func (db *EmbeddedDatabase) checkLocale(ctx context.Context) {
+	if db.config.Locale == "" && db.config.ICULocale == "" {
+		return
+	}
+	info, err := ReadLocale(ctx, db.pool)
+	if err != nil {
+		logger.Warn("could not check PostgreSQL locale", "error", err)
+		return
+	}
+	if db.config.ICULocale != "" && (info.Provider != "icu" || info.ICULocale != db.config.ICULocale) ||
+		db.config.ICULocale == "" && normalizeLocale(db.config.Locale) != normalizeLocale(info.Collate) {
+		logger.Warn("PostgreSQL locale differs from the configured one, which only applies to new data directories",
+			"configured_locale", db.config.Locale, "configured_icu_locale", db.config.ICULocale,
+			"collate", info.Collate, "provider", info.Provider, "icu_locale", info.ICULocale)
+	}
+}
+
+// normalizeLocale folds spellings of the same libc locale together, e.g.
+// "en_US.UTF-8" and "en_US.utf8".
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(locale), "-", "")
+}
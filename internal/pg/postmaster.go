@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Postmaster describes a Postgres server from the postmaster.pid file it
+// keeps in its data directory while running.
+type Postmaster struct {
+	PID        int
+	Port       int
+	SocketDir  string // Directory of its unix socket, if any
+	ListenAddr string // First TCP address it listens on; "" when it listens on the socket only
+}
+
+// ClusterPath returns the Postgres data directory inside a supalite data
+// directory, as Start lays it out.
+func ClusterPath(dataDir string) string {
+	return filepath.Join(dataDir, "data")
+}
+
+// ReadPostmaster reads postmaster.pid in the Postgres data directory
+// clusterPath. Its first line is the postmaster's pid, its fourth the
+// port it listens on, and its fifth and sixth its socket directory and
+// TCP address.
+func ReadPostmaster(clusterPath string) (Postmaster, error) {
+	data, err := os.ReadFile(filepath.Join(clusterPath, "postmaster.pid"))
+	if err != nil {
+		return Postmaster{}, err
+	}
+	return parsePostmaster(string(data))
+}
+
+func parsePostmaster(data string) (Postmaster, error) {
+	lines := strings.Split(data, "\n")
+	if len(lines) < 4 {
+		return Postmaster{}, fmt.Errorf("postmaster.pid is incomplete")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return Postmaster{}, fmt.Errorf("invalid pid in postmaster.pid: %w", err)
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return Postmaster{}, fmt.Errorf("invalid port in postmaster.pid: %w", err)
+	}
+	postmaster := Postmaster{PID: pid, Port: port}
+	if len(lines) > 5 {
+		postmaster.SocketDir = strings.TrimSpace(lines[4])
+		postmaster.ListenAddr = strings.TrimSpace(lines[5])
+	}
+	return postmaster, nil
+}
+
+// SocketOnly reports whether the postmaster listens on its unix socket
+// and not on TCP.
+func (p Postmaster) SocketOnly() bool {
+	return p.SocketDir != "" && p.ListenAddr == ""
+}
+
+// Running reports whether the postmaster process is still alive. A server
+// that crashed leaves a stale postmaster.pid behind.
+func (p Postmaster) Running() bool {
+	process, err := os.FindProcess(p.PID)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
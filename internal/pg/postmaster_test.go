@@ -0,0 +1,48 @@
+package pg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPostmaster(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := "4242\n/var/lib/supalite/data\n1700000000\n5433\n/tmp\nlocalhost\n  5433001    131072\nready   \n"
+	if err := os.WriteFile(filepath.Join(dir, "postmaster.pid"), []byte(pidFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pm, err := ReadPostmaster(dir)
+	if err != nil {
+		t.Fatalf("ReadPostmaster() error = %v", err)
+	}
+	if pm.PID != 4242 || pm.Port != 5433 || pm.SocketDir != "/tmp" || pm.SocketOnly() {
+		t.Errorf("ReadPostmaster() = %+v, want pid 4242, port 5433, socket in /tmp, and TCP", pm)
+	}
+}
+
+func TestReadPostmaster_SocketOnly(t *testing.T) {
+	pm, err := parsePostmaster("4242\n/var/lib/supalite/data\n1700000000\n5432\n/run/supalite\n\n  5432001    131072\nready   \n")
+	if err != nil {
+		t.Fatalf("parsePostmaster() error = %v", err)
+	}
+	if !pm.SocketOnly() || pm.SocketDir != "/run/supalite" {
+		t.Errorf("parsePostmaster() = %+v, want the socket in /run/supalite only", pm)
+	}
+}
+
+func TestReadPostmaster_Invalid(t *testing.T) {
+	if _, err := ReadPostmaster(t.TempDir()); err == nil {
+		t.Error("ReadPostmaster() should fail without postmaster.pid")
+	}
+	if _, err := parsePostmaster("4242\n/data\n"); err == nil {
+		t.Error("parsePostmaster() should fail on a truncated file")
+	}
+}
+
+func TestPostmasterRunning(t *testing.T) {
+	if !(Postmaster{PID: os.Getpid()}).Running() {
+		t.Error("Running() should be true for the current process")
+	}
+}
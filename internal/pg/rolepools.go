@@ -0,0 +1,103 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Requests made with the anon key or a user's token run as the anon and
+// authenticated roles. Giving those roles pools of their own means a flood
+// of public traffic can only exhaust its own connections, never the ones
+// the dashboard, service_role requests, and background work borrow from
+// the shared pool. Each backend also only builds up catalog caches for the
+// tables its role touches.
+
+// minSharedConns is the fewest connections the shared pool is left with
+// once the role pools are carved out of it.
+const minSharedConns = 2
+
+// defaultRolePools returns the pool size per role used when none are
+// configured, out of maxConns connections in all: a quarter for anon and
+// half for authenticated, leaving the rest to the shared pool. Below 8
+// connections there is too little to split, and every role shares the
+// pool.
+func defaultRolePools(maxConns int32) map[string]int32 {
+	if maxConns < 8 {
+		return nil
+	}
+	return map[string]int32{
+		"anon":          maxConns / 4,
+		"authenticated": maxConns / 2,
+	}
+}
+
+// splitPools carves the role pools out of maxConns connections, so all
+// pools together never hold more than maxConns. It returns the size of each
+// role pool and what is left for the shared pool, or an error when the
+// configured role pools leave the shared pool fewer than minSharedConns.
+func splitPools(configured map[string]int32, maxConns int32) (map[string]int32, int32, error) {
+	sizes := configured
+	if sizes == nil {
+		sizes = defaultRolePools(maxConns)
+	}
+	var total int32
+	for _, size := range sizes {
+		if size > 0 {
+			total += size
+		}
+	}
+	shared := maxConns - total
+	if total > 0 && shared < minSharedConns {
+		return nil, 0, fmt.Errorf("role pools take %d of %d connections, leaving fewer than %d for the shared pool: raise pg_max_conns or shrink pg_role_pools",
+			total, maxConns, minSharedConns)
+	}
+	return sizes, shared, nil
+}
+
+// newRolePools creates a pool to connString for each role with a positive
+// size. Their connections are opened on first use.
+func newRolePools(ctx context.Context, connString string, cfg Config, sizes map[string]int32) (map[string]*pgxpool.Pool, error) {
+	pools := make(map[string]*pgxpool.Pool, len(sizes))
+	for role, size := range sizes {
+		if size <= 0 {
+			continue
+		}
+		poolConfig, err := pgxpool.ParseConfig(connString)
+		if err != nil {
+			closePools(pools)
+			return nil, err
+		}
+		poolConfig.MaxConns = size
+		poolConfig.ConnConfig.Tracer = cfg.Tracer
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			closePools(pools)
+			return nil, fmt.Errorf("failed to create %s pool: %w", role, err)
+		}
+		pools[role] = pool
+	}
+	return pools, nil
+}
+
+// AcquireAs borrows a connection for a request that will run as role:
+// from the role's own pool if it has one, otherwise from the shared pool.
+// The connection still logs in as the configured user; the caller switches
+// roles inside its transaction as usual.
+func (db *EmbeddedDatabase) AcquireAs(ctx context.Context, role string) (*pgxpool.Conn, error) {
+	db.mu.RLock()
+	pool, ok := db.rolePools[role]
+	db.mu.RUnlock()
+
+	if !ok {
+		return db.Acquire(ctx)
+	}
+	return pool.Acquire(ctx)
+}
+
+func closePools(pools map[string]*pgxpool.Pool) {
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
@@ -0,0 +1,57 @@
+package pg
+
+import "testing"
+
+func TestDefaultRolePools(t *testing.T) {
+	tests := []struct {
+		maxConns            int32
+		anon, authenticated int32
+	}{
+		{4, 0, 0},
+		{8, 2, 4},
+		{16, 4, 8},
+		{40, 10, 20},
+	}
+	for _, tt := range tests {
+		got := defaultRolePools(tt.maxConns)
+		if got["anon"] != tt.anon || got["authenticated"] != tt.authenticated {
+			t.Errorf("defaultRolePools(%d) = %v, want anon %d, authenticated %d", tt.maxConns, got, tt.anon, tt.authenticated)
+		}
+	}
+}
+
+func TestSplitPools(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured map[string]int32
+		maxConns   int32
+		wantShared int32
+		wantErr    bool
+	}{
+		{"defaults", nil, 80, 20, false},
+		{"defaults, too small to split", nil, 4, 4, false},
+		{"configured", map[string]int32{"anon": 4, "authenticated": 8}, 16, 4, false},
+		{"role sharing the pool", map[string]int32{"anon": 0, "authenticated": 8}, 16, 8, false},
+		{"none", map[string]int32{}, 16, 16, false},
+		{"leaves too few", map[string]int32{"anon": 4, "authenticated": 11}, 16, 0, true},
+		{"more than the limit", map[string]int32{"anon": 20}, 16, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizes, shared, err := splitPools(tt.configured, tt.maxConns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPools() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			total := shared
+			for _, size := range sizes {
+				total += size
+			}
+			if shared != tt.wantShared || total != tt.maxConns {
+				t.Errorf("splitPools() = %v, shared %d; want shared %d and %d connections in all", sizes, shared, tt.wantShared, tt.maxConns)
+			}
+		})
+	}
+}
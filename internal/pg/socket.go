@@ -0,0 +1,114 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// With Config.SocketDir set, PostgreSQL listens on a unix socket only, so
+// it can't collide with another server's port and other users on the
+// machine can't reach it. embedded-postgres creates and health-checks the
+// cluster over TCP, though, so it starts the cluster on a free loopback
+// port first, and restartOnSocket then moves it to the socket with pg_ctl.
+
+// maxSocketPath is the longest socket path PostgreSQL accepts: the size of
+// sun_path, less the terminating NUL, on macOS (Linux allows one more).
+const maxSocketPath = 103
+
+// SocketPath returns the path of the socket a server on port listens on
+// in socketDir.
+func SocketPath(socketDir string, port uint16) string {
+	return filepath.Join(socketDir, fmt.Sprintf(".s.PGSQL.%d", port))
+}
+
+// prepareSocketDir returns dir as an absolute path, since the postmaster
+// resolves a relative one against its data directory, after creating it
+// private and checking that the socket's path isn't too long.
+func prepareSocketDir(dir string, port uint16) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if path := SocketPath(dir, port); len(path) > maxSocketPath {
+		return "", fmt.Errorf("socket path %s is longer than %d bytes; use a shorter socket directory", path, maxSocketPath)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	return dir, nil
+}
+
+// freeLoopbackPort returns a TCP port nothing listens on.
+func freeLoopbackPort() (uint16, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// defaultRuntimePath is where embedded-postgres extracts its binaries when
+// no runtime path is given.
+func defaultRuntimePath() string {
+	cacheDir := ".embedded-postgres-go"
+	if home, err := os.UserHomeDir(); err == nil {
+		cacheDir = filepath.Join(home, ".embedded-postgres-go")
+	}
+	return filepath.Join(cacheDir, "extracted")
+}
+
+// restartOnSocket stops the cluster embedded-postgres started and starts
+// it again with pg_ctl from runtimePath, with TCP turned off and the socket
+// in db.config.SocketDir, and with params.
+func (db *EmbeddedDatabase) restartOnSocket(ctx context.Context, runtimePath, dataPath string, params map[string]string) error {
+	if err := db.postgres.Stop(); err != nil {
+		return fmt.Errorf("failed to stop postgres: %w", err)
+	}
+
+	options := []string{
+		fmt.Sprintf("-p %d", db.config.Port),
+		`-c listen_addresses=""`,
+		fmt.Sprintf(`-c unix_socket_directories="%s"`, db.config.SocketDir),
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options = append(options, fmt.Sprintf(`-c %s="%s"`, name, params[name]))
+	}
+
+	pgCtl := filepath.Join(runtimePath, "bin", "pg_ctl")
+	logFile := filepath.Join(runtimePath, "postgres.log")
+	cmd := exec.CommandContext(ctx, pgCtl, "start", "-w", "-D", dataPath, "-l", logFile, "-o", strings.Join(options, " "))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log, _ := os.ReadFile(logFile)
+		return fmt.Errorf("failed to start postgres on %s: %w\n%s%s", SocketPath(db.config.SocketDir, db.config.Port), err, out, log)
+	}
+	db.pgCtl = pgCtl
+	db.dataPath = dataPath
+	return nil
+}
+
+// stopPostgres stops the postmaster, whether embedded-postgres or
+// restartOnSocket started it.
+func (db *EmbeddedDatabase) stopPostgres() {
+	if db.pgCtl != "" {
+		if out, err := exec.Command(db.pgCtl, "stop", "-w", "-D", db.dataPath).CombinedOutput(); err != nil {
+			logger.Warn("failed to stop PostgreSQL", "error", err, "output", strings.TrimSpace(string(out)))
+		}
+		db.pgCtl = ""
+		return
+	}
+	if db.postgres != nil {
+		db.postgres.Stop()
+	}
+}
@@ -0,0 +1,108 @@
+package pg
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestConnectionURL(t *testing.T) {
+	if got, want := ConnectionURL("postgres", "pw", "app", "", 5433), "postgres://postgres:pw@localhost:5433/app"; got != want {
+		t.Errorf("ConnectionURL() = %s, want %s", got, want)
+	}
+
+	cfg, err := pgx.ParseConfig(ConnectionURL("postgres", "pw", "app", "/run/supa lite", 5432))
+	if err != nil {
+		t.Fatalf("ParseConfig() failed: %v", err)
+	}
+	if cfg.Host != "/run/supa lite" || cfg.Port != 5432 || cfg.Database != "app" || cfg.User != "postgres" {
+		t.Errorf("socket URL parses as host %q, port %d, database %q, user %q", cfg.Host, cfg.Port, cfg.Database, cfg.User)
+	}
+}
+
+func TestPrepareSocketDir(t *testing.T) {
+	base := t.TempDir()
+	t.Chdir(base)
+
+	dir, err := prepareSocketDir("run", 5432)
+	if err != nil {
+		t.Fatalf("prepareSocketDir() failed: %v", err)
+	}
+	if want := filepath.Join(base, "run"); dir != want {
+		t.Errorf("prepareSocketDir() = %s, want %s", dir, want)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("socket directory: %v, %v, want mode 0700", info, err)
+	}
+
+	if _, err := prepareSocketDir(filepath.Join(base, strings.Repeat("x", maxSocketPath)), 5432); err == nil {
+		t.Error("prepareSocketDir() accepted a socket path that is too long")
+	}
+}
+
+func TestEmbeddedDatabase_SocketOnly(t *testing.T) {
+	// The configured port is taken, which doesn't matter without TCP
+	l, err := net.Listen("tcp", "localhost:15438")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	dataDir := t.TempDir()
+	socketDir, err := os.MkdirTemp("", "supalite-sock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(socketDir)
+
+	db := NewEmbeddedDatabase(Config{
+		Port:        15438,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		DataDir:     dataDir,
+		RuntimePath: "/tmp/supalite-test-pg-socket",
+		SocketDir:   socketDir,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer db.Stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect on the socket: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	var listen string
+	if err := conn.QueryRow(ctx, "SHOW listen_addresses").Scan(&listen); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if listen != "" {
+		t.Errorf("listen_addresses = %q, want none", listen)
+	}
+
+	postmaster, err := ReadPostmaster(ClusterPath(dataDir))
+	if err != nil {
+		t.Fatalf("ReadPostmaster() failed: %v", err)
+	}
+	if !postmaster.SocketOnly() || postmaster.SocketDir != socketDir || postmaster.Port != 15438 {
+		t.Errorf("postmaster = %+v, want the socket in %s only", postmaster, socketDir)
+	}
+
+	db.Stop()
+	if _, err := os.Stat(SocketPath(socketDir, 15438)); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after Stop: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultVersion is the PostgreSQL version new data directories are
+// created with.
+const DefaultVersion = "16.9.0"
+
+// versions are embedded-postgres' default release of each major version,
+// newest first, used when only the major version is given.
+var versions = []string{"18.0.0", "17.5.0", "16.9.0", "15.13.0", "14.18.0"}
+
+// ResolveVersion returns the full PostgreSQL version for version, which
+// is a major version ("17"), resolved to the release embedded-postgres
+// uses by default, or a full one ("17.5.0"), used as it is.
+func ResolveVersion(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	invalid := fmt.Errorf("invalid PostgreSQL version %q: want a major version (e.g. 17) or a full one (e.g. 17.5.0)", version)
+	parts := strings.Split(version, ".")
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err != nil {
+			return "", invalid
+		}
+	}
+	if len(parts) == 3 {
+		return version, nil
+	}
+	if len(parts) == 1 {
+		for _, v := range versions {
+			if MajorVersion(v) == version {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("no default release of PostgreSQL %s: give a full version, e.g. %s.0.0 (known: %s)", version, version, strings.Join(versions, ", "))
+	}
+	return "", invalid
+}
+
+// MajorVersion returns the major version of a full version, e.g. "16" for
+// "16.9.0". A data directory is tied to its major version.
+func MajorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// ClusterVersion returns the major version of the cluster in a supalite
+// data directory, or "" when it hasn't been initialized.
+func ClusterVersion(dataDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(ClusterPath(dataDir), "PG_VERSION"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the cluster's PostgreSQL version: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"17", "17.5.0", false},
+		{"16", "16.9.0", false},
+		{" 17.2.0 ", "17.2.0", false},
+		{"9", "", true},
+		{"17.5", "", true},
+		{"latest", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveVersion(tt.version)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ResolveVersion(%q) = %q, %v; want %q", tt.version, got, err, tt.want)
+		}
+	}
+}
+
+func TestClusterVersion(t *testing.T) {
+	dataDir := t.TempDir()
+	if v, err := ClusterVersion(dataDir); err != nil || v != "" {
+		t.Errorf("ClusterVersion(uninitialized) = %q, %v", v, err)
+	}
+
+	if err := os.MkdirAll(ClusterPath(dataDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ClusterPath(dataDir), "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ClusterVersion(dataDir); err != nil || v != "16" {
+		t.Errorf("ClusterVersion() = %q, %v; want 16", v, err)
+	}
+}
@@ -0,0 +1,101 @@
+package pgnet
+
+// schemaSQL creates the net schema with the same tables and functions as
+// Supabase's pg_net extension. The functions only queue the request; the
+// worker performs it once the calling transaction commits, and stores the
+// result in net._http_response under the returned id. They are SECURITY
+// DEFINER so triggers fired by any role can queue requests without access
+// to the queue itself.
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS net;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS net.http_request_queue (
+	id bigserial PRIMARY KEY,
+	method text NOT NULL,
+	url text NOT NULL,
+	headers jsonb NOT NULL,
+	body bytea,
+	timeout_milliseconds integer NOT NULL
+);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS net._http_response (
+	id bigint,
+	status_code integer,
+	content_type text,
+	headers jsonb,
+	content text,
+	timed_out boolean,
+	error_msg text,
+	created timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS _http_response_created_idx ON net._http_response (created);
+
+-- Appends params as a query string, as pg_net does
+CREATE OR REPLACE FUNCTION net._encode_url_with_params_array(url text, params jsonb) RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT CASE
+		WHEN params IS NULL OR params = '{}'::jsonb THEN url
+		ELSE url || CASE WHEN strpos(url, '?') > 0 THEN '&' ELSE '?' END || (
+			SELECT string_agg(format('%s=%s', key, value), '&')
+			FROM jsonb_each_text(params)
+		)
+	END
+$$;
+
+CREATE OR REPLACE FUNCTION net.http_get(
+	url text,
+	params jsonb DEFAULT '{}'::jsonb,
+	headers jsonb DEFAULT '{}'::jsonb,
+	timeout_milliseconds integer DEFAULT 5000
+) RETURNS bigint
+LANGUAGE sql VOLATILE SECURITY DEFINER SET search_path = net, pg_catalog AS $$
+	INSERT INTO net.http_request_queue (method, url, headers, timeout_milliseconds)
+	VALUES ('GET', net._encode_url_with_params_array(url, params), headers, timeout_milliseconds)
+	RETURNING id
+$$;
+
+CREATE OR REPLACE FUNCTION net.http_post(
+	url text,
+	body jsonb DEFAULT '{}'::jsonb,
+	params jsonb DEFAULT '{}'::jsonb,
+	headers jsonb DEFAULT '{"Content-Type": "application/json"}'::jsonb,
+	timeout_milliseconds integer DEFAULT 5000
+) RETURNS bigint
+LANGUAGE sql VOLATILE SECURITY DEFINER SET search_path = net, pg_catalog AS $$
+	INSERT INTO net.http_request_queue (method, url, headers, body, timeout_milliseconds)
+	VALUES ('POST', net._encode_url_with_params_array(url, params), headers,
+		convert_to(body::text, 'UTF8'), timeout_milliseconds)
+	RETURNING id
+$$;
+
+CREATE OR REPLACE FUNCTION net.http_delete(
+	url text,
+	params jsonb DEFAULT '{}'::jsonb,
+	headers jsonb DEFAULT '{}'::jsonb,
+	timeout_milliseconds integer DEFAULT 5000
+) RETURNS bigint
+LANGUAGE sql VOLATILE SECURITY DEFINER SET search_path = net, pg_catalog AS $$
+	INSERT INTO net.http_request_queue (method, url, headers, timeout_milliseconds)
+	VALUES ('DELETE', net._encode_url_with_params_array(url, params), headers, timeout_milliseconds)
+	RETURNING id
+$$;
+
+-- Let the Supabase API roles queue requests from triggers and functions,
+-- as on Supabase
+DO $$
+DECLARE
+	r text;
+BEGIN
+	FOREACH r IN ARRAY ARRAY['anon', 'authenticated', 'service_role'] LOOP
+		IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = r) THEN
+			EXECUTE format('GRANT USAGE ON SCHEMA net TO %I', r);
+			EXECUTE format('GRANT EXECUTE ON FUNCTION net.http_get(text, jsonb, jsonb, integer), net.http_post(text, jsonb, jsonb, jsonb, integer), net.http_delete(text, jsonb, jsonb, integer) TO %I', r);
+		END IF;
+	END LOOP;
+	IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'service_role') THEN
+		GRANT SELECT ON net._http_response TO service_role;
+	END IF;
+END;
+$$;
+`
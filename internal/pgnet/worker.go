@@ -0,0 +1,260 @@
+// Package pgnet provides the SQL interface of Supabase's pg_net extension.
+//
+// net.http_get, net.http_post, and net.http_delete queue a request in
+// net.http_request_queue and return its id. A background worker polls the
+// queue, performs the requests, and records each result in
+// net._http_response, so triggers and cron jobs written for pg_net run
+// unchanged:
+//
+//	SELECT net.http_post(
+//		url := 'https://example.com/hook',
+//		body := jsonb_build_object('id', NEW.id)
+//	);
+package pgnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	DefaultInterval  = time.Second
+	DefaultBatchSize = 200
+	DefaultTTL       = 6 * time.Hour // pg_net's default pg_net.ttl
+)
+
+// PostgresAcquirer lends the worker pooled connections, on which it
+// creates the net schema, drains net.http_request_queue, and records
+// responses.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Config holds the configuration for the worker.
+type Config struct {
+	Database  PostgresAcquirer
+	Client    *http.Client  // Optional: defaults to a client without a timeout
+	Interval  time.Duration // Optional: how often the queue is polled
+	BatchSize int           // Optional: requests taken from the queue per poll
+	TTL       time.Duration // Optional: how long responses are kept
+}
+
+// Worker performs queued requests.
+type Worker struct {
+	config Config
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// request is a row of net.http_request_queue.
+type request struct {
+	ID      int64
+	Method  string
+	URL     string
+	Headers map[string]interface{}
+	Body    []byte
+	Timeout time.Duration
+}
+
+// response is a row of net._http_response.
+type response struct {
+	ID          int64
+	StatusCode  *int
+	ContentType *string
+	Headers     map[string]string
+	Content     *string
+	TimedOut    bool
+	Error       *string
+}
+
+// NewWorker creates a new worker.
+func NewWorker(cfg Config) *Worker {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{}
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	return &Worker{config: cfg}
+}
+
+// Start creates the net schema and begins polling the queue.
+func (w *Worker) Start(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, schemaSQL)
+	conn.Release()
+	if err != nil {
+		return fmt.Errorf("failed to create net schema: %w", err)
+	}
+
+	// The worker outlives the startup context
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(runCtx)
+	}()
+
+	return nil
+}
+
+// Stop stops polling and waits for requests in flight.
+func (w *Worker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("pg_net worker failed to process the queue", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll takes a batch from the queue, performs the requests concurrently,
+// and records their responses. Requests are removed from the queue before
+// they are sent, so a crash loses them rather than sending them twice.
+func (w *Worker) poll(ctx context.Context) error {
+	conn, err := w.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `DELETE FROM net._http_response WHERE created < now() - $1::interval`,
+		fmt.Sprintf("%d milliseconds", w.config.TTL.Milliseconds())); err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(ctx, `
+		DELETE FROM net.http_request_queue
+		WHERE id IN (
+			SELECT id FROM net.http_request_queue
+			ORDER BY id LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, method, url, headers, body, timeout_milliseconds
+	`, w.config.BatchSize)
+	if err != nil {
+		return err
+	}
+	requests, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (request, error) {
+		var req request
+		var timeoutMS int32
+		err := row.Scan(&req.ID, &req.Method, &req.URL, &req.Headers, &req.Body, &timeoutMS)
+		req.Timeout = time.Duration(timeoutMS) * time.Millisecond
+		return req, err
+	})
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	responses := make([]response, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req request) {
+			defer wg.Done()
+			responses[i] = w.do(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	batch := &pgx.Batch{}
+	for _, resp := range responses {
+		batch.Queue(`
+			INSERT INTO net._http_response (id, status_code, content_type, headers, content, timed_out, error_msg)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, resp.ID, resp.StatusCode, resp.ContentType, resp.Headers, resp.Content, resp.TimedOut, resp.Error)
+	}
+	// Record responses even if the server is stopping mid-batch
+	return conn.SendBatch(context.WithoutCancel(ctx), batch).Close()
+}
+
+// do performs one request. Failures are reported in the response rather
+// than returned, as pg_net does.
+func (w *Worker) do(ctx context.Context, req request) response {
+	resp := response{ID: req.ID}
+	fail := func(err error) response {
+		msg := err.Error()
+		resp.Error = &msg
+		resp.TimedOut = errors.Is(err, context.DeadlineExceeded)
+		return resp
+	}
+
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, body)
+	if err != nil {
+		return fail(err)
+	}
+	for name, value := range req.Headers {
+		httpReq.Header.Set(name, fmt.Sprint(value))
+	}
+
+	httpResp, err := w.config.Client.Do(httpReq)
+	if err != nil {
+		return fail(err)
+	}
+	defer httpResp.Body.Close()
+
+	content, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fail(err)
+	}
+
+	status := httpResp.StatusCode
+	resp.StatusCode = &status
+	if ct := httpResp.Header.Get("Content-Type"); ct != "" {
+		resp.ContentType = &ct
+	}
+	resp.Headers = make(map[string]string, len(httpResp.Header))
+	for name := range httpResp.Header {
+		resp.Headers[name] = httpResp.Header.Get(name)
+	}
+	text := string(content)
+	resp.Content = &text
+	return resp
+}
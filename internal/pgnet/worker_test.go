@@ -0,0 +1,75 @@
+package pgnet
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorkerDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" || string(body) != `{"id": 1}` {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	w := NewWorker(Config{})
+	resp := w.do(context.Background(), request{
+		ID:      7,
+		Method:  http.MethodPost,
+		URL:     srv.URL,
+		Headers: map[string]interface{}{"Authorization": "Bearer token"},
+		Body:    []byte(`{"id": 1}`),
+		Timeout: 5 * time.Second,
+	})
+
+	if resp.Error != nil {
+		t.Fatalf("do() error = %s", *resp.Error)
+	}
+	if resp.ID != 7 || resp.StatusCode == nil || *resp.StatusCode != http.StatusCreated {
+		t.Errorf("do() = id %d status %v, want id 7 status 201", resp.ID, resp.StatusCode)
+	}
+	if resp.Content == nil || *resp.Content != `{"ok":true}` {
+		t.Errorf("do() content = %v, want the response body", resp.Content)
+	}
+	if resp.ContentType == nil || *resp.ContentType != "application/json" {
+		t.Errorf("do() content type = %v, want application/json", resp.ContentType)
+	}
+}
+
+func TestWorkerDo_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	w := NewWorker(Config{})
+	resp := w.do(context.Background(), request{ID: 1, Method: http.MethodGet, URL: srv.URL, Timeout: 10 * time.Millisecond})
+
+	if !resp.TimedOut || resp.Error == nil {
+		t.Errorf("do() timed_out = %v, error = %v, want a timeout", resp.TimedOut, resp.Error)
+	}
+	if resp.StatusCode != nil {
+		t.Errorf("do() status = %d, want none", *resp.StatusCode)
+	}
+}
+
+func TestWorkerDo_InvalidURL(t *testing.T) {
+	w := NewWorker(Config{})
+	resp := w.do(context.Background(), request{ID: 1, Method: http.MethodGet, URL: "://missing-scheme"})
+	if resp.Error == nil || resp.TimedOut {
+		t.Errorf("do() error = %v, timed_out = %v, want an error without timeout", resp.Error, resp.TimedOut)
+	}
+}
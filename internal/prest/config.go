@@ -8,7 +8,7 @@ import (
 // Config holds the configuration for the pREST server
 type Config struct {
 	ConnString string // PostgreSQL connection string
-	Port       int    // Port to run pREST on (default: 3000)
+	Port       int    // Port to run pREST on, or 0 to only serve Handler (default: 3000)
 }
 
 // DefaultConfig returns default pREST configuration
@@ -45,6 +45,12 @@ func (s *Server) Start(ctx context.Context) error {
 	prestRouter := router.Routes()
 	s.handler = prestRouter
 
+	// Without a port, pREST is only reached through Handler
+	if s.config.Port == 0 {
+		s.running = true
+		return nil
+	}
+
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.config.Port),
 		Handler:      prestRouter,
@@ -134,8 +140,17 @@ func (s *Server) configurePrest() error {
 		pgDatabase = parsedURL.Path[1:]
 	}
 
-	// Extract host from URL
+	// Extract host from URL. A unix socket's directory is given as the
+	// host parameter instead, with the port.
 	pgHost := parsedURL.Hostname()
+	if host := parsedURL.Query().Get("host"); host != "" {
+		pgHost = host
+		if port := parsedURL.Query().Get("port"); port != "" {
+			if pgPort, err = strconv.Atoi(port); err != nil {
+				return fmt.Errorf("invalid port in connection string: %w", err)
+			}
+		}
+	}
 	if pgHost == "" {
 		pgHost = "localhost"
 	}
@@ -0,0 +1,233 @@
+// Package provision reads the declarations supalite provision applies to
+// a data directory: the database, admin users, storage buckets, and the
+// schema to load, as infrastructure-as-code tools write them:
+//
+//	data_dir: /var/lib/supalite
+//	url: https://api.example.com
+//	pg_port: 5432
+//	pg_password_env: PG_PASSWORD
+//	extensions: [pg_trgm]
+//	admins:
+//	  - email: ops@example.com
+//	    password_env: SUPALITE_ADMIN_PASSWORD
+//	buckets:
+//	  - id: avatars
+//	    public: true
+//	    file_size_limit: 5MB
+//	    allowed_mime_types: [image/*]
+//	migrations_dir: supabase/migrations
+//	seed: [supabase/seed.sql]
+//
+// Every step leaves what already matches alone, so provisioning again
+// with the same file changes nothing, and with an edited one changes only
+// what was edited. JSON is YAML, so the file may be JSON too.
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultURL is where the server is reported to be when the spec doesn't
+// say: serve's default address.
+const DefaultURL = "http://localhost:8080"
+
+// Spec is what supalite provision sets up.
+type Spec struct {
+	DataDir       string   `yaml:"data_dir"`
+	URL           string   `yaml:"url"` // Where clients reach the server, for the URLs reported
+	PGPort        uint16   `yaml:"pg_port"`
+	PGUsername    string   `yaml:"pg_username"`
+	PGPassword    string   `yaml:"pg_password"`
+	PGPasswordEnv string   `yaml:"pg_password_env"` // Env var holding pg_password
+	PGDatabase    string   `yaml:"pg_database"`
+	PGVersion     string   `yaml:"pg_version"`
+	Extensions    []string `yaml:"extensions"`
+	Admins        []Admin  `yaml:"admins"`
+	Buckets       []Bucket `yaml:"buckets"`
+	MigrationsDir string   `yaml:"migrations_dir"` // Applied on every run; only new migrations run
+	Seed          []string `yaml:"seed"`           // Loaded once, when the database is created
+}
+
+// Admin is a dashboard admin user. Its password is set to the given one
+// when it doesn't match.
+type Admin struct {
+	Email       string `yaml:"email"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"` // Env var holding the password
+}
+
+// Bucket is a storage bucket. An existing bucket's settings are changed
+// to these.
+type Bucket struct {
+	ID               string      `yaml:"id"`
+	Public           bool        `yaml:"public"`
+	FileSizeLimit    interface{} `yaml:"file_size_limit"` // Bytes, or a size such as "5MB"
+	AllowedMimeTypes []string    `yaml:"allowed_mime_types"`
+}
+
+// Load reads a spec from a YAML or JSON file.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Parse parses a spec, fills in defaults, and resolves the passwords held
+// in env vars. Unknown keys are errors, so a typo isn't silently skipped.
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid provisioning spec: %w", err)
+	}
+
+	if spec.DataDir == "" {
+		spec.DataDir = "./data"
+	}
+	if spec.URL == "" {
+		spec.URL = DefaultURL
+	}
+	spec.URL = strings.TrimSuffix(spec.URL, "/")
+	if spec.PGPort == 0 {
+		spec.PGPort = 5432
+	}
+	if spec.PGUsername == "" {
+		spec.PGUsername = "postgres"
+	}
+	if spec.PGDatabase == "" {
+		spec.PGDatabase = "postgres"
+	}
+
+	password, err := secret("pg_password", spec.PGPassword, spec.PGPasswordEnv)
+	if err != nil {
+		return nil, err
+	}
+	if spec.PGPassword = password; spec.PGPassword == "" {
+		spec.PGPassword = "postgres"
+	}
+
+	emails := make(map[string]bool, len(spec.Admins))
+	for i := range spec.Admins {
+		a := &spec.Admins[i]
+		if a.Email == "" {
+			return nil, fmt.Errorf("admins[%d]: email is required", i)
+		}
+		if emails[a.Email] {
+			return nil, fmt.Errorf("admins[%d]: %s is listed twice", i, a.Email)
+		}
+		emails[a.Email] = true
+		if a.Password, err = secret(a.Email+": password", a.Password, a.PasswordEnv); err != nil {
+			return nil, err
+		}
+		if a.Password == "" {
+			return nil, fmt.Errorf("admins[%d]: %s needs a password or password_env", i, a.Email)
+		}
+	}
+
+	ids := make(map[string]bool, len(spec.Buckets))
+	for i, b := range spec.Buckets {
+		if b.ID == "" {
+			return nil, fmt.Errorf("buckets[%d]: id is required", i)
+		}
+		if ids[b.ID] {
+			return nil, fmt.Errorf("buckets[%d]: %s is listed twice", i, b.ID)
+		}
+		ids[b.ID] = true
+	}
+	return &spec, nil
+}
+
+// secret returns value, or the value of the env var env names. Giving
+// both is an error, as is naming an env var that isn't set.
+func secret(name, value, env string) (string, error) {
+	if env == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s: give the value or the env var, not both", name)
+	}
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return "", fmt.Errorf("%s: env var %s is not set", name, env)
+	}
+	return value, nil
+}
+
+// Result is what supalite provision reports, as JSON on stdout.
+type Result struct {
+	DataDir    string       `json:"data_dir"`
+	Created    bool         `json:"created"` // The database was created by this run
+	ProjectRef string       `json:"project_ref"`
+	URLs       URLs         `json:"urls"`
+	Keys       Keys         `json:"keys"`
+	Admins     []ItemResult `json:"admins"`
+	Buckets    []ItemResult `json:"buckets"`
+	Migrations []string     `json:"migrations"` // Applied by this run
+	Seeded     []string     `json:"seeded"`     // Loaded by this run
+	Extensions []string     `json:"extensions"`
+	Config     string       `json:"config"`  // The supalite.json serve reads
+	Changed    bool         `json:"changed"` // Anything was created, updated, applied, or seeded
+}
+
+// URLs are where the provisioned instance's APIs are served, once
+// supalite serve runs.
+type URLs struct {
+	API         string `json:"api"`
+	REST        string `json:"rest"`
+	Auth        string `json:"auth"`
+	Storage     string `json:"storage"`
+	Realtime    string `json:"realtime"`
+	Dashboard   string `json:"dashboard"`
+	DatabaseURL string `json:"database_url"`
+}
+
+// Keys are the instance's API keys.
+type Keys struct {
+	Anon        string `json:"anon"`
+	ServiceRole string `json:"service_role"`
+	Publishable string `json:"publishable"`
+	Secret      string `json:"secret"`
+}
+
+// Statuses of an admin or bucket.
+const (
+	StatusCreated   = "created"
+	StatusUpdated   = "updated"
+	StatusUnchanged = "unchanged"
+)
+
+// ItemResult is what provisioning did to an admin user or bucket.
+type ItemResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// NewURLs returns the URLs of an instance clients reach at base.
+func NewURLs(base string) URLs {
+	realtime := base
+	if rest, ok := strings.CutPrefix(base, "https://"); ok {
+		realtime = "wss://" + rest
+	} else if rest, ok := strings.CutPrefix(base, "http://"); ok {
+		realtime = "ws://" + rest
+	}
+	return URLs{
+		API:       base,
+		REST:      base + "/rest/v1",
+		Auth:      base + "/auth/v1",
+		Storage:   base + "/storage/v1",
+		Realtime:  realtime + "/realtime/v1",
+		Dashboard: base + "/_/",
+	}
+}
@@ -0,0 +1,75 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("TEST_ADMIN_PASSWORD", "s3cret")
+
+	spec, err := Parse([]byte(`
+url: https://api.example.com/
+admins:
+  - email: ops@example.com
+    password_env: TEST_ADMIN_PASSWORD
+buckets:
+  - id: avatars
+    public: true
+    file_size_limit: 5MB
+  - id: exports
+    file_size_limit: 1024
+`))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if spec.DataDir != "./data" || spec.PGPort != 5432 || spec.PGUsername != "postgres" || spec.PGPassword != "postgres" || spec.PGDatabase != "postgres" {
+		t.Errorf("defaults = %+v", spec)
+	}
+	if spec.URL != "https://api.example.com" {
+		t.Errorf("URL = %q, want the trailing slash trimmed", spec.URL)
+	}
+	if spec.Admins[0].Password != "s3cret" {
+		t.Errorf("admin password = %q, want the env var's value", spec.Admins[0].Password)
+	}
+	if spec.Buckets[0].FileSizeLimit != "5MB" || spec.Buckets[1].FileSizeLimit != 1024 {
+		t.Errorf("file size limits = %v, %v", spec.Buckets[0].FileSizeLimit, spec.Buckets[1].FileSizeLimit)
+	}
+
+	// JSON works too
+	spec, err = Parse([]byte(`{"data_dir": "/srv/supalite", "pg_port": 5433}`))
+	if err != nil || spec.DataDir != "/srv/supalite" || spec.PGPort != 5433 {
+		t.Errorf("Parse(JSON) = %+v, %v", spec, err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"data_dri: ./data", "field data_dri not found"},
+		{"admins: [{password: x}]", "email is required"},
+		{"admins: [{email: a@example.com}]", "needs a password"},
+		{"admins: [{email: a@example.com, password: x}, {email: a@example.com, password: y}]", "listed twice"},
+		{"admins: [{email: a@example.com, password: x, password_env: HOME}]", "not both"},
+		{"admins: [{email: a@example.com, password_env: SUPALITE_TEST_UNSET}]", "is not set"},
+		{"buckets: [{public: true}]", "id is required"},
+		{"buckets: [{id: a}, {id: a}]", "listed twice"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.spec)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestNewURLs(t *testing.T) {
+	urls := NewURLs("https://api.example.com")
+	if urls.REST != "https://api.example.com/rest/v1" || urls.Realtime != "wss://api.example.com/realtime/v1" || urls.Dashboard != "https://api.example.com/_/" {
+		t.Errorf("NewURLs(https) = %+v", urls)
+	}
+	if urls := NewURLs(DefaultURL); urls.Realtime != "ws://localhost:8080/realtime/v1" {
+		t.Errorf("NewURLs(http) realtime = %s", urls.Realtime)
+	}
+}
@@ -0,0 +1,433 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// notifyChannel is the LISTEN/NOTIFY channel change events arrive on.
+const notifyChannel = "supalite_realtime"
+
+// Supabase streams changes with logical replication (wal2json). Embedded
+// Postgres has no replication slot to spare, so row triggers on every table
+// in the supabase_realtime publication send each change with pg_notify
+// instead. An event trigger keeps the row triggers in sync as tables are
+// added to or removed from the publication.
+const changeCaptureSQL = `
+CREATE SCHEMA IF NOT EXISTS realtime;
+
+CREATE OR REPLACE FUNCTION realtime.supalite_notify_change() RETURNS trigger
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog AS $$
+DECLARE
+	pk text[];
+	full_identity boolean;
+	rec jsonb;
+	old_rec jsonb;
+	payload jsonb;
+BEGIN
+	SELECT coalesce(array_agg(a.attname::text), '{}') INTO pk
+	FROM pg_index i
+	JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+	WHERE i.indrelid = TG_RELID AND i.indisprimary;
+
+	SELECT c.relreplident = 'f' INTO full_identity FROM pg_class c WHERE c.oid = TG_RELID;
+
+	IF TG_OP <> 'DELETE' THEN
+		rec := to_jsonb(NEW);
+	END IF;
+	IF TG_OP <> 'INSERT' THEN
+		old_rec := to_jsonb(OLD);
+		-- Like Supabase, old records carry only the primary key unless the
+		-- table has REPLICA IDENTITY FULL
+		IF NOT full_identity AND cardinality(pk) > 0 THEN
+			SELECT jsonb_object_agg(k, v) INTO old_rec FROM jsonb_each(old_rec) AS e(k, v) WHERE k = ANY(pk);
+		END IF;
+	END IF;
+
+	payload := jsonb_build_object(
+		'schema', TG_TABLE_SCHEMA,
+		'table', TG_TABLE_NAME,
+		'type', TG_OP,
+		'commit_timestamp', to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+		'record', coalesce(rec, '{}'::jsonb),
+		'old_record', coalesce(old_rec, '{}'::jsonb)
+	);
+
+	-- NOTIFY payloads are limited to 8000 bytes
+	IF octet_length(payload::text) > 7900 THEN
+		IF rec IS NOT NULL AND cardinality(pk) > 0 THEN
+			SELECT jsonb_object_agg(k, v) INTO rec FROM jsonb_each(rec) AS e(k, v) WHERE k = ANY(pk);
+		ELSIF rec IS NOT NULL THEN
+			rec := '{}'::jsonb;
+		END IF;
+		IF old_rec IS NOT NULL AND cardinality(pk) > 0 THEN
+			SELECT jsonb_object_agg(k, v) INTO old_rec FROM jsonb_each(old_rec) AS e(k, v) WHERE k = ANY(pk);
+		ELSIF old_rec IS NOT NULL THEN
+			old_rec := '{}'::jsonb;
+		END IF;
+		payload := payload
+			|| jsonb_build_object('record', coalesce(rec, '{}'::jsonb), 'old_record', coalesce(old_rec, '{}'::jsonb))
+			|| jsonb_build_object('errors', jsonb_build_array('Error 413: Payload Too Large'));
+	END IF;
+
+	PERFORM pg_notify('` + notifyChannel + `', payload::text);
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION realtime.supalite_sync_triggers() RETURNS void
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog AS $$
+DECLARE
+	r record;
+BEGIN
+	-- Add triggers to published tables that lack them
+	FOR r IN
+		SELECT c.oid::regclass AS rel
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'realtime')
+		  AND EXISTS (SELECT 1 FROM pg_publication_tables p
+		              WHERE p.pubname = 'supabase_realtime' AND p.schemaname = n.nspname AND p.tablename = c.relname)
+		  AND NOT EXISTS (SELECT 1 FROM pg_trigger t WHERE t.tgrelid = c.oid AND t.tgname = 'supalite_realtime')
+	LOOP
+		EXECUTE format('CREATE TRIGGER supalite_realtime AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION realtime.supalite_notify_change()', r.rel);
+	END LOOP;
+
+	-- Drop triggers from tables no longer published
+	FOR r IN
+		SELECT t.tgrelid::regclass AS rel
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE t.tgname = 'supalite_realtime'
+		  AND NOT EXISTS (SELECT 1 FROM pg_publication_tables p
+		                  WHERE p.pubname = 'supabase_realtime' AND p.schemaname = n.nspname AND p.tablename = c.relname)
+	LOOP
+		EXECUTE format('DROP TRIGGER supalite_realtime ON %s', r.rel);
+	END LOOP;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION realtime.supalite_sync_triggers_event() RETURNS event_trigger
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog AS $$
+BEGIN
+	PERFORM realtime.supalite_sync_triggers();
+END;
+$$;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = 'supabase_realtime') THEN
+		CREATE PUBLICATION supabase_realtime;
+	END IF;
+END;
+$$;
+
+DROP EVENT TRIGGER IF EXISTS supalite_realtime_sync;
+CREATE EVENT TRIGGER supalite_realtime_sync ON ddl_command_end
+	WHEN TAG IN ('CREATE PUBLICATION', 'ALTER PUBLICATION', 'DROP PUBLICATION', 'CREATE TABLE')
+	EXECUTE FUNCTION realtime.supalite_sync_triggers_event();
+
+SELECT realtime.supalite_sync_triggers();
+`
+
+// installChangeCapture creates the realtime schema, the supabase_realtime
+// publication, and the triggers that feed change events.
+func installChangeCapture(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, changeCaptureSQL); err != nil {
+		return fmt.Errorf("failed to install realtime triggers: %w", err)
+	}
+	return nil
+}
+
+// changeEvent is a row change as sent by the notify trigger.
+type changeEvent struct {
+	Schema          string                 `json:"schema"`
+	Table           string                 `json:"table"`
+	Type            string                 `json:"type"`
+	CommitTimestamp string                 `json:"commit_timestamp"`
+	Record          map[string]interface{} `json:"record"`
+	OldRecord       map[string]interface{} `json:"old_record"`
+	Errors          []string               `json:"errors"`
+}
+
+// column describes a table column in a postgres_changes payload.
+type column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// tableInfo is the cached metadata for a published table.
+type tableInfo struct {
+	columns    []column
+	primaryKey []string
+	rls        bool
+}
+
+// columnCache caches table metadata by "schema.table". Entries expire so
+// schema changes are picked up without tracking DDL.
+type columnCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedTable
+}
+
+type cachedTable struct {
+	info    *tableInfo
+	expires time.Time
+}
+
+const columnCacheTTL = 30 * time.Second
+
+func newColumnCache() *columnCache {
+	return &columnCache{entries: make(map[string]cachedTable)}
+}
+
+// get returns metadata for a table, loading it with conn on a miss.
+func (c *columnCache) get(ctx context.Context, conn *pgx.Conn, schema, table string) (*tableInfo, error) {
+	key := schema + "." + table
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.info, nil
+	}
+
+	info := &tableInfo{}
+	rows, err := conn.Query(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod),
+		       coalesce(i.indisprimary, false)
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_index i ON i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)
+		WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var col column
+		var primary bool
+		if err := rows.Scan(&col.Name, &col.Type, &primary); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		info.columns = append(info.columns, col)
+		if primary {
+			info.primaryKey = append(info.primaryKey, col.Name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = conn.QueryRow(ctx, `
+		SELECT c.relrowsecurity
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2`, schema, table).Scan(&info.rls)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cachedTable{info: info, expires: time.Now().Add(columnCacheTTL)}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// listen receives change notifications until ctx is cancelled,
+// reconnecting with backoff when the connection drops.
+func (s *Server) listen(ctx context.Context) {
+	backoff := time.Second
+	for {
+		err := s.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("realtime change listener disconnected", "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// listenOnce holds one LISTEN connection. A second connection is used to
+// look up table metadata and check RLS while dispatching.
+func (s *Server) listenOnce(ctx context.Context) error {
+	conn, err := s.config.Database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	queryConn, err := s.config.Database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer queryConn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	s.listening.Store(true)
+	defer s.listening.Store(false)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event changeEvent
+		decoder := json.NewDecoder(strings.NewReader(notification.Payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&event); err != nil {
+			log.Warn("realtime: invalid change notification", "error", err)
+			continue
+		}
+		s.dispatchChange(ctx, queryConn, &event)
+	}
+}
+
+// postgresChangesPayload is the payload of a postgres_changes push.
+type postgresChangesPayload struct {
+	IDs  []int              `json:"ids"`
+	Data postgresChangeData `json:"data"`
+}
+
+type postgresChangeData struct {
+	Schema          string                 `json:"schema"`
+	Table           string                 `json:"table"`
+	CommitTimestamp string                 `json:"commit_timestamp"`
+	Type            string                 `json:"type"`
+	Record          map[string]interface{} `json:"record,omitempty"`
+	OldRecord       map[string]interface{} `json:"old_record,omitempty"`
+	Columns         []column               `json:"columns"`
+	Errors          []string               `json:"errors"`
+}
+
+// dispatchChange sends a change to every subscription with a matching
+// postgres_changes binding that is allowed to see the row.
+func (s *Server) dispatchChange(ctx context.Context, conn *pgx.Conn, event *changeEvent) {
+	info, err := s.columns.get(ctx, conn, event.Schema, event.Table)
+	if err != nil {
+		log.Warn("realtime: failed to load table metadata", "table", event.Schema+"."+event.Table, "error", err)
+		return
+	}
+
+	data := postgresChangeData{
+		Schema:          event.Schema,
+		Table:           event.Table,
+		CommitTimestamp: event.CommitTimestamp,
+		Type:            event.Type,
+		Columns:         info.columns,
+		Errors:          event.Errors,
+	}
+	if event.Type != "DELETE" {
+		data.Record = event.Record
+	}
+	if event.Type != "INSERT" {
+		data.OldRecord = event.OldRecord
+	}
+
+	for _, sub := range s.allSubscriptions() {
+		ids := sub.matchingChanges(event)
+		if len(ids) == 0 {
+			continue
+		}
+		if !s.canSee(ctx, conn, sub, info, event) {
+			continue
+		}
+
+		msg, err := newMessage(sub.topic, eventPostgresChanges, postgresChangesPayload{IDs: ids, Data: data})
+		if err != nil {
+			continue
+		}
+		sub.client.push(msg)
+	}
+}
+
+// canSee reports whether a subscriber may see a changed row, by checking
+// that the row is visible to its role under the table's RLS policies.
+//
+// Deletes can't be checked (the row is gone) and, as on Supabase, are sent
+// to every subscriber.
+func (s *Server) canSee(ctx context.Context, conn *pgx.Conn, sub *subscription, info *tableInfo, event *changeEvent) bool {
+	claims := sub.client.currentClaims()
+	role := rls.RoleForClaims(claims)
+	if !needsVisibilityCheck(info, role, event.Type) {
+		return true
+	}
+
+	matchJSON, err := json.Marshal(rowIdentity(info, event.Record))
+	if err != nil {
+		return false
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false
+	}
+	defer tx.Rollback(context.Background())
+
+	settings, err := rls.Settings(claims)
+	if err != nil {
+		return false
+	}
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", setting.Name, setting.Value); err != nil {
+			return false
+		}
+	}
+
+	// A role that doesn't exist in this database sees nothing, as the REST
+	// API would reject its token outright
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize()); err != nil {
+		return false
+	}
+
+	var visible bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s t WHERE to_jsonb(t) @> $1::jsonb)",
+		pgx.Identifier{event.Schema, event.Table}.Sanitize())
+	if err := tx.QueryRow(ctx, query, string(matchJSON)).Scan(&visible); err != nil {
+		return false
+	}
+	return visible
+}
+
+// needsVisibilityCheck reports whether a change must be checked against
+// the table's RLS policies before role may see it. Tables without RLS and
+// service_role see every change, and deletes can't be checked.
+func needsVisibilityCheck(info *tableInfo, role, eventType string) bool {
+	return info.rls && role != rls.RoleServiceRole && eventType != "DELETE"
+}
+
+// rowIdentity returns the columns that identify a changed row: its primary
+// key, or the whole record without one.
+func rowIdentity(info *tableInfo, record map[string]interface{}) map[string]interface{} {
+	if len(info.primaryKey) == 0 {
+		return record
+	}
+	match := make(map[string]interface{}, len(info.primaryKey))
+	for _, name := range info.primaryKey {
+		match[name] = record[name]
+	}
+	return match
+}
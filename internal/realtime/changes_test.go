@@ -0,0 +1,77 @@
+package realtime
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/markb/supalite/internal/rls"
+)
+
+func TestNeedsVisibilityCheck(t *testing.T) {
+	withRLS := &tableInfo{rls: true}
+	withoutRLS := &tableInfo{}
+
+	tests := []struct {
+		name      string
+		info      *tableInfo
+		role      string
+		eventType string
+		want      bool
+	}{
+		{"insert as anon", withRLS, rls.RoleAnon, "INSERT", true},
+		{"update as authenticated", withRLS, rls.RoleAuthenticated, "UPDATE", true},
+		{"custom role", withRLS, "editor", "INSERT", true},
+		{"service_role", withRLS, rls.RoleServiceRole, "UPDATE", false},
+		{"delete", withRLS, rls.RoleAnon, "DELETE", false},
+		{"table without RLS", withoutRLS, rls.RoleAnon, "INSERT", false},
+		{"table without RLS, delete", withoutRLS, rls.RoleAuthenticated, "DELETE", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsVisibilityCheck(tt.info, tt.role, tt.eventType); got != tt.want {
+				t.Errorf("needsVisibilityCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanSee_Unchecked(t *testing.T) {
+	// Changes that need no check are sent without touching the database
+	s := &Server{}
+	anon := &subscription{client: &client{}}
+	service := &subscription{client: &client{claims: map[string]interface{}{"role": rls.RoleServiceRole}}}
+	insert := &changeEvent{Type: "INSERT", Record: map[string]interface{}{"id": 1}}
+	del := &changeEvent{Type: "DELETE", OldRecord: map[string]interface{}{"id": 1}}
+
+	tests := []struct {
+		name  string
+		sub   *subscription
+		info  *tableInfo
+		event *changeEvent
+	}{
+		{"delete", anon, &tableInfo{rls: true}, del},
+		{"table without RLS", anon, &tableInfo{}, insert},
+		{"service_role", service, &tableInfo{rls: true}, insert},
+	}
+	for _, tt := range tests {
+		if !s.canSee(context.Background(), nil, tt.sub, tt.info, tt.event) {
+			t.Errorf("%s: canSee() = false, want true", tt.name)
+		}
+	}
+}
+
+func TestRowIdentity(t *testing.T) {
+	record := map[string]interface{}{"id": 1, "tenant": "a", "title": "hello"}
+
+	if got := rowIdentity(&tableInfo{primaryKey: []string{"id"}}, record); !reflect.DeepEqual(got, map[string]interface{}{"id": 1}) {
+		t.Errorf("rowIdentity() by primary key = %v", got)
+	}
+	composite := rowIdentity(&tableInfo{primaryKey: []string{"tenant", "id"}}, record)
+	if !reflect.DeepEqual(composite, map[string]interface{}{"id": 1, "tenant": "a"}) {
+		t.Errorf("rowIdentity() by composite key = %v", composite)
+	}
+	if got := rowIdentity(&tableInfo{}, record); !reflect.DeepEqual(got, record) {
+		t.Errorf("rowIdentity() without a primary key = %v, want the whole record", got)
+	}
+}
@@ -0,0 +1,448 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	// sendBuffer is the number of queued messages after which a client is
+	// considered too slow and disconnected.
+	sendBuffer = 256
+
+	// readTimeout closes connections that stop sending heartbeats.
+	// supabase-js sends one every 25 seconds.
+	readTimeout = 60 * time.Second
+
+	// writeTimeout bounds a single frame write.
+	writeTimeout = 10 * time.Second
+
+	// maxMessageSize is the largest frame accepted from a client.
+	maxMessageSize = 1 << 20
+)
+
+// client is a single WebSocket connection, which may join many channels.
+type client struct {
+	server *Server
+	conn   *websocket.Conn
+	vsn    string
+
+	mu     sync.Mutex
+	claims map[string]interface{}   // claims of the latest access token
+	subs   map[string]*subscription // topic -> joined subscription
+
+	send      chan *message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// subscription is a client's membership in one channel.
+type subscription struct {
+	client  *client
+	topic   string
+	joinRef *string
+
+	broadcastSelf bool
+	presenceKey   string
+	changes       []changeBinding
+}
+
+// changeBinding is a postgres_changes listener requested on join.
+type changeBinding struct {
+	ID     int    `json:"id"`
+	Event  string `json:"event"`
+	Schema string `json:"schema"`
+	Table  string `json:"table,omitempty"`
+	Filter string `json:"filter,omitempty"`
+
+	filter *changeFilter
+}
+
+// matches reports whether a change event satisfies the binding.
+func (b *changeBinding) matches(event *changeEvent) bool {
+	if b.Event != "*" && !strings.EqualFold(b.Event, event.Type) {
+		return false
+	}
+	if b.Schema != "*" && b.Schema != event.Schema {
+		return false
+	}
+	if b.Table != "" && b.Table != "*" && b.Table != event.Table {
+		return false
+	}
+	record := event.Record
+	if event.Type == "DELETE" {
+		record = event.OldRecord
+	}
+	return b.filter.matches(record)
+}
+
+// matchingChanges returns the IDs of the bindings a change event matches.
+func (sub *subscription) matchingChanges(event *changeEvent) []int {
+	var ids []int
+	for i := range sub.changes {
+		if sub.changes[i].matches(event) {
+			ids = append(ids, sub.changes[i].ID)
+		}
+	}
+	return ids
+}
+
+func newClient(s *Server, conn *websocket.Conn, vsn string, claims map[string]interface{}) *client {
+	conn.SetReadLimit(maxMessageSize)
+	return &client{
+		server: s,
+		conn:   conn,
+		vsn:    vsn,
+		claims: claims,
+		subs:   make(map[string]*subscription),
+		send:   make(chan *message, sendBuffer),
+		done:   make(chan struct{}),
+	}
+}
+
+// run serves the connection until the client disconnects.
+func (c *client) run(ctx context.Context) {
+	go c.writeLoop(ctx)
+	defer c.close(websocket.StatusNormalClosure, "")
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, readTimeout)
+		typ, data, err := c.conn.Read(readCtx)
+		cancel()
+		if err != nil {
+			if status := websocket.CloseStatus(err); status == -1 && !errors.Is(err, context.Canceled) {
+				log.Debug("realtime client disconnected", "error", err)
+			}
+			return
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+
+		msg, err := decodeMessage(data, c.vsn)
+		if err != nil {
+			log.Debug("realtime: invalid client message", "error", err)
+			continue
+		}
+		c.handle(msg)
+	}
+}
+
+// writeLoop sends queued messages until the connection closes.
+func (c *client) writeLoop(ctx context.Context) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			data, err := encodeMessage(msg, c.vsn)
+			if err != nil {
+				continue
+			}
+			writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+			err = c.conn.Write(writeCtx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				c.close(websocket.StatusGoingAway, "write failed")
+				return
+			}
+		}
+	}
+}
+
+// push queues a message for the client. Clients that fall too far behind
+// are disconnected rather than allowed to block everyone else.
+func (c *client) push(msg *message) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		log.Warn("realtime client too slow, disconnecting")
+		go c.close(websocket.StatusPolicyViolation, "too many pending messages")
+	}
+}
+
+// close leaves every channel and closes the connection.
+func (c *client) close(code websocket.StatusCode, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		subs := make([]*subscription, 0, len(c.subs))
+		for _, sub := range c.subs {
+			subs = append(subs, sub)
+		}
+		c.subs = map[string]*subscription{}
+		c.mu.Unlock()
+
+		for _, sub := range subs {
+			c.server.unsubscribe(sub)
+		}
+		c.conn.Close(code, reason)
+	})
+}
+
+// currentClaims returns the claims used to authorize postgres_changes.
+func (c *client) currentClaims() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.claims
+}
+
+// reply answers a client message.
+func (c *client) reply(msg *message, status string, response interface{}) {
+	if response == nil {
+		response = struct{}{}
+	}
+	payload, err := json.Marshal(replyPayload{Status: status, Response: response})
+	if err != nil {
+		return
+	}
+	c.push(&message{JoinRef: msg.JoinRef, Ref: msg.Ref, Topic: msg.Topic, Event: eventReply, Payload: payload})
+}
+
+// replyError answers a client message with an error reason.
+func (c *client) replyError(msg *message, reason string) {
+	c.reply(msg, "error", map[string]string{"reason": reason})
+}
+
+// subscription returns the client's subscription to topic, if joined.
+func (c *client) subscription(topic string) *subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[topic]
+}
+
+// handle processes one client message.
+func (c *client) handle(msg *message) {
+	if msg.Topic == phoenixTopic {
+		if msg.Event == eventHeartbeat {
+			c.reply(msg, "ok", nil)
+		}
+		return
+	}
+
+	if msg.Event == eventJoin {
+		c.handleJoin(msg)
+		return
+	}
+
+	sub := c.subscription(msg.Topic)
+	if sub == nil {
+		c.replyError(msg, "unmatched topic")
+		return
+	}
+
+	switch msg.Event {
+	case eventLeave:
+		c.handleLeave(msg, sub)
+	case eventAccessToken:
+		c.handleAccessToken(msg)
+	case eventBroadcast:
+		c.handleBroadcast(msg, sub)
+	case eventPresence:
+		c.handlePresence(msg, sub)
+	default:
+		c.replyError(msg, "unsupported event "+msg.Event)
+	}
+}
+
+// joinPayload is the payload of phx_join.
+type joinPayload struct {
+	Config struct {
+		Broadcast struct {
+			Ack  bool `json:"ack"`
+			Self bool `json:"self"`
+		} `json:"broadcast"`
+		Presence struct {
+			Key string `json:"key"`
+		} `json:"presence"`
+		PostgresChanges []changeBinding `json:"postgres_changes"`
+	} `json:"config"`
+	AccessToken string `json:"access_token"`
+}
+
+// handleJoin subscribes the client to a channel.
+func (c *client) handleJoin(msg *message) {
+	var payload joinPayload
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			c.replyError(msg, "invalid join payload")
+			return
+		}
+	}
+
+	if payload.AccessToken != "" {
+		claims, err := c.server.verify(payload.AccessToken)
+		if err != nil {
+			c.replyError(msg, "Invalid token")
+			return
+		}
+		c.mu.Lock()
+		c.claims = claims
+		c.mu.Unlock()
+	}
+
+	sub := &subscription{
+		client:        c,
+		topic:         msg.Topic,
+		joinRef:       msg.JoinRef,
+		broadcastSelf: payload.Config.Broadcast.Self,
+		presenceKey:   payload.Config.Presence.Key,
+	}
+	if sub.joinRef == nil {
+		sub.joinRef = msg.Ref
+	}
+	if sub.presenceKey == "" {
+		sub.presenceKey = uuid.NewString()
+	}
+
+	for i, binding := range payload.Config.PostgresChanges {
+		filter, err := parseChangeFilter(binding.Filter)
+		if err != nil {
+			c.replyError(msg, err.Error())
+			return
+		}
+		binding.ID = i + 1
+		binding.filter = filter
+		if binding.Event == "" {
+			binding.Event = "*"
+		}
+		if binding.Schema == "" {
+			binding.Schema = "public"
+		}
+		sub.changes = append(sub.changes, binding)
+	}
+
+	// Joining a topic twice replaces the earlier subscription
+	c.mu.Lock()
+	previous := c.subs[msg.Topic]
+	c.subs[msg.Topic] = sub
+	c.mu.Unlock()
+	if previous != nil {
+		c.server.unsubscribe(previous)
+	}
+	c.server.subscribe(sub)
+
+	// The client matches bindings by event, schema, table, and filter, so
+	// they are echoed as requested
+	bindings := make([]map[string]interface{}, 0, len(payload.Config.PostgresChanges))
+	for i, requested := range payload.Config.PostgresChanges {
+		binding := map[string]interface{}{"id": sub.changes[i].ID, "event": requested.Event, "schema": requested.Schema}
+		if requested.Table != "" {
+			binding["table"] = requested.Table
+		}
+		if requested.Filter != "" {
+			binding["filter"] = requested.Filter
+		}
+		bindings = append(bindings, binding)
+	}
+	c.reply(msg, "ok", map[string]interface{}{"postgres_changes": bindings})
+
+	if len(sub.changes) > 0 {
+		c.pushToSubscription(sub, eventSystem, map[string]string{
+			"message":   "Subscribed to PostgreSQL",
+			"status":    "ok",
+			"extension": "postgres_changes",
+			"channel":   strings.TrimPrefix(msg.Topic, "realtime:"),
+		})
+	}
+	c.pushToSubscription(sub, eventPresenceState, c.server.presenceState(msg.Topic))
+}
+
+// handleLeave unsubscribes the client from a channel.
+func (c *client) handleLeave(msg *message, sub *subscription) {
+	c.mu.Lock()
+	if c.subs[msg.Topic] == sub {
+		delete(c.subs, msg.Topic)
+	}
+	c.mu.Unlock()
+	c.server.unsubscribe(sub)
+
+	c.reply(msg, "ok", nil)
+	c.pushToSubscription(sub, eventClose, nil)
+}
+
+// handleAccessToken replaces the token used to authorize postgres_changes.
+func (c *client) handleAccessToken(msg *message) {
+	var payload struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.AccessToken == "" {
+		c.replyError(msg, "missing access_token")
+		return
+	}
+	claims, err := c.server.verify(payload.AccessToken)
+	if err != nil {
+		c.replyError(msg, "Invalid token")
+		return
+	}
+
+	c.mu.Lock()
+	c.claims = claims
+	c.mu.Unlock()
+	c.reply(msg, "ok", nil)
+}
+
+// handleBroadcast relays a broadcast to the channel.
+func (c *client) handleBroadcast(msg *message, sub *subscription) {
+	var payload broadcastPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.replyError(msg, "invalid broadcast payload")
+		return
+	}
+	if payload.Type == "" {
+		payload.Type = "broadcast"
+	}
+	c.server.broadcast(sub.topic, sub, payload)
+
+	// Replying unconditionally is harmless: clients without broadcast.ack
+	// resolve their send immediately and ignore the reply
+	if msg.Ref != nil {
+		c.reply(msg, "ok", nil)
+	}
+}
+
+// handlePresence tracks or untracks the client's presence state.
+func (c *client) handlePresence(msg *message, sub *subscription) {
+	var payload struct {
+		Event   string                 `json:"event"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		c.replyError(msg, "invalid presence payload")
+		return
+	}
+
+	switch payload.Event {
+	case "track":
+		c.server.track(sub, payload.Payload)
+	case "untrack":
+		c.server.untrack(sub)
+	default:
+		c.replyError(msg, "unsupported presence event "+payload.Event)
+		return
+	}
+	c.reply(msg, "ok", nil)
+}
+
+// pushToSubscription sends a server push on a joined channel.
+func (c *client) pushToSubscription(sub *subscription, event string, payload interface{}) {
+	if payload == nil {
+		payload = struct{}{}
+	}
+	msg, err := newMessage(sub.topic, event, payload)
+	if err != nil {
+		return
+	}
+	msg.JoinRef = sub.joinRef
+	c.push(msg)
+}
@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// changeFilter is a postgres_changes row filter such as "id=eq.1" or
+// "status=in.(open,pending)".
+type changeFilter struct {
+	column   string
+	operator string
+	values   []string
+}
+
+// parseChangeFilter parses a filter in the Supabase realtime syntax.
+// An empty string means no filter.
+func parseChangeFilter(filter string) (*changeFilter, error) {
+	if filter == "" {
+		return nil, nil
+	}
+
+	column, rest, ok := strings.Cut(filter, "=")
+	if !ok || column == "" {
+		return nil, fmt.Errorf("invalid filter %q: expected column=operator.value", filter)
+	}
+	operator, value, ok := strings.Cut(rest, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid filter %q: expected column=operator.value", filter)
+	}
+
+	f := &changeFilter{column: column, operator: operator}
+	switch operator {
+	case "eq", "neq", "lt", "lte", "gt", "gte":
+		f.values = []string{value}
+	case "in":
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+		for _, v := range strings.Split(value, ",") {
+			f.values = append(f.values, strings.Trim(strings.TrimSpace(v), `"`))
+		}
+	default:
+		return nil, fmt.Errorf("invalid filter %q: unsupported operator %q", filter, operator)
+	}
+	return f, nil
+}
+
+// matches reports whether a changed record passes the filter.
+func (f *changeFilter) matches(record map[string]interface{}) bool {
+	if f == nil {
+		return true
+	}
+	value, ok := record[f.column]
+	if !ok || value == nil {
+		return false
+	}
+	actual := filterString(value)
+
+	switch f.operator {
+	case "eq":
+		return actual == f.values[0]
+	case "neq":
+		return actual != f.values[0]
+	case "in":
+		for _, v := range f.values {
+			if actual == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Ordering comparisons: numeric when both sides are numbers
+	cmp := strings.Compare(actual, f.values[0])
+	if a, err := strconv.ParseFloat(actual, 64); err == nil {
+		if b, err := strconv.ParseFloat(f.values[0], 64); err == nil {
+			switch {
+			case a < b:
+				cmp = -1
+			case a > b:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+
+	switch f.operator {
+	case "lt":
+		return cmp < 0
+	case "lte":
+		return cmp <= 0
+	case "gt":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	}
+	return false
+}
+
+// filterString renders a decoded JSON value the way it appears in a filter.
+func filterString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseChangeFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{"id", "=eq.1", "id=eq", "id=like.a%"} {
+		if _, err := parseChangeFilter(filter); err == nil {
+			t.Errorf("parseChangeFilter(%q) succeeded, want error", filter)
+		}
+	}
+
+	f, err := parseChangeFilter("")
+	if err != nil || f != nil {
+		t.Errorf("parseChangeFilter(\"\") = %v, %v, want nil, nil", f, err)
+	}
+}
+
+func TestChangeFilter_Matches(t *testing.T) {
+	record := map[string]interface{}{
+		"id":     json.Number("10"),
+		"status": "open",
+		"done":   false,
+		"note":   nil,
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"id=eq.10", true},
+		{"id=eq.11", false},
+		{"id=neq.11", true},
+		{"id=gt.9", true},
+		{"id=gt.10", false},
+		{"id=gte.10", true},
+		{"id=lt.100", true}, // numeric, not lexical, comparison
+		{"id=lte.9", false},
+		{"status=in.(pending,open)", true},
+		{"status=in.(closed)", false},
+		{"done=eq.false", true},
+		{"note=eq.null", false},
+		{"missing=eq.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseChangeFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parseChangeFilter() failed: %v", err)
+			}
+			if got := f.matches(record); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeBinding_Matches(t *testing.T) {
+	insert := &changeEvent{Schema: "public", Table: "messages", Type: "INSERT",
+		Record: map[string]interface{}{"room": "a"}}
+	del := &changeEvent{Schema: "public", Table: "messages", Type: "DELETE",
+		OldRecord: map[string]interface{}{"room": "a"}}
+
+	roomFilter, _ := parseChangeFilter("room=eq.a")
+
+	tests := []struct {
+		name    string
+		binding changeBinding
+		event   *changeEvent
+		want    bool
+	}{
+		{"any event", changeBinding{Event: "*", Schema: "public", Table: "messages"}, insert, true},
+		{"event case-insensitive", changeBinding{Event: "insert", Schema: "public", Table: "messages"}, insert, true},
+		{"other event", changeBinding{Event: "UPDATE", Schema: "public", Table: "messages"}, insert, false},
+		{"whole schema", changeBinding{Event: "*", Schema: "public"}, insert, true},
+		{"other table", changeBinding{Event: "*", Schema: "public", Table: "rooms"}, insert, false},
+		{"other schema", changeBinding{Event: "*", Schema: "private", Table: "messages"}, insert, false},
+		{"filter on delete uses old record", changeBinding{Event: "DELETE", Schema: "public", Table: "messages", filter: roomFilter}, del, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.binding.matches(tt.event); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
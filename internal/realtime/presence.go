@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// presenceEntry holds the state tracked under one presence key. Several
+// connections may share a key (for example one user in two tabs), so each
+// subscription contributes its own meta.
+type presenceEntry struct {
+	metas map[*subscription]map[string]interface{}
+}
+
+// presenceRef numbers tracked metas so clients can tell updates apart.
+var presenceRef atomic.Uint64
+
+// presenceMetas is the wire format of a presence key: {"metas": [...]}.
+type presenceMetas struct {
+	Metas []map[string]interface{} `json:"metas"`
+}
+
+// presenceDiff is the payload of a presence_diff push.
+type presenceDiff struct {
+	Joins  map[string]presenceMetas `json:"joins"`
+	Leaves map[string]presenceMetas `json:"leaves"`
+}
+
+// presenceState returns the full presence state of a topic.
+func (s *Server) presenceState(topic string) map[string]presenceMetas {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := make(map[string]presenceMetas)
+	for key, entry := range s.presence[topic] {
+		metas := presenceMetas{}
+		for _, meta := range entry.metas {
+			metas.Metas = append(metas.Metas, meta)
+		}
+		state[key] = metas
+	}
+	return state
+}
+
+// track sets a subscription's presence state and announces the change.
+func (s *Server) track(sub *subscription, state map[string]interface{}) {
+	meta := make(map[string]interface{}, len(state)+1)
+	for k, v := range state {
+		meta[k] = v
+	}
+	meta["phx_ref"] = strconv.FormatUint(presenceRef.Add(1), 10)
+
+	diff := presenceDiff{Joins: map[string]presenceMetas{}, Leaves: map[string]presenceMetas{}}
+
+	s.mu.Lock()
+	entries, ok := s.presence[sub.topic]
+	if !ok {
+		entries = make(map[string]presenceEntry)
+		s.presence[sub.topic] = entries
+	}
+	entry, ok := entries[sub.presenceKey]
+	if !ok {
+		entry = presenceEntry{metas: make(map[*subscription]map[string]interface{})}
+		entries[sub.presenceKey] = entry
+	}
+	if previous, ok := entry.metas[sub]; ok {
+		meta["phx_ref_prev"] = previous["phx_ref"]
+		diff.Leaves[sub.presenceKey] = presenceMetas{Metas: []map[string]interface{}{previous}}
+	}
+	entry.metas[sub] = meta
+	diff.Joins[sub.presenceKey] = presenceMetas{Metas: []map[string]interface{}{meta}}
+	s.mu.Unlock()
+
+	s.pushPresenceDiff(sub.topic, diff)
+}
+
+// untrack removes a subscription's presence state, if any, and announces
+// the leave.
+func (s *Server) untrack(sub *subscription) {
+	s.mu.Lock()
+	entry, ok := s.presence[sub.topic][sub.presenceKey]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	meta, ok := entry.metas[sub]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	delete(entry.metas, sub)
+	if len(entry.metas) == 0 {
+		delete(s.presence[sub.topic], sub.presenceKey)
+		if len(s.presence[sub.topic]) == 0 {
+			delete(s.presence, sub.topic)
+		}
+	}
+	s.mu.Unlock()
+
+	s.pushPresenceDiff(sub.topic, presenceDiff{
+		Joins:  map[string]presenceMetas{},
+		Leaves: map[string]presenceMetas{sub.presenceKey: {Metas: []map[string]interface{}{meta}}},
+	})
+}
+
+// pushPresenceDiff sends a presence diff to every subscription on topic.
+func (s *Server) pushPresenceDiff(topic string, diff presenceDiff) {
+	msg, err := newMessage(topic, eventPresenceDiff, diff)
+	if err != nil {
+		return
+	}
+	for _, sub := range s.subscribers(topic) {
+		sub.client.push(msg)
+	}
+}
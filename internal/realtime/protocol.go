@@ -0,0 +1,103 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Phoenix channel events used by the Supabase realtime protocol.
+const (
+	eventJoin            = "phx_join"
+	eventLeave           = "phx_leave"
+	eventReply           = "phx_reply"
+	eventError           = "phx_error"
+	eventClose           = "phx_close"
+	eventHeartbeat       = "heartbeat"
+	eventAccessToken     = "access_token"
+	eventBroadcast       = "broadcast"
+	eventPresence        = "presence"
+	eventPresenceState   = "presence_state"
+	eventPresenceDiff    = "presence_diff"
+	eventPostgresChanges = "postgres_changes"
+	eventSystem          = "system"
+
+	// phoenixTopic carries connection-level messages such as heartbeats.
+	phoenixTopic = "phoenix"
+)
+
+// Serializer versions negotiated with the vsn query parameter.
+const (
+	vsn1 = "1.0.0" // messages are JSON objects
+	vsn2 = "2.0.0" // messages are JSON arrays [join_ref, ref, topic, event, payload]
+)
+
+// message is a single Phoenix channel message.
+//
+// Ref and JoinRef are nil for server pushes that don't answer a client
+// message.
+type message struct {
+	JoinRef *string         `json:"join_ref"`
+	Ref     *string         `json:"ref"`
+	Topic   string          `json:"topic"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// decodeMessage parses a text frame in the given serializer version.
+func decodeMessage(data []byte, vsn string) (*message, error) {
+	var msg message
+	if vsn == vsn2 {
+		var parts []json.RawMessage
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return nil, err
+		}
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("expected 5 message elements, got %d", len(parts))
+		}
+		if err := json.Unmarshal(parts[0], &msg.JoinRef); err != nil {
+			return nil, fmt.Errorf("invalid join_ref: %w", err)
+		}
+		if err := json.Unmarshal(parts[1], &msg.Ref); err != nil {
+			return nil, fmt.Errorf("invalid ref: %w", err)
+		}
+		if err := json.Unmarshal(parts[2], &msg.Topic); err != nil {
+			return nil, fmt.Errorf("invalid topic: %w", err)
+		}
+		if err := json.Unmarshal(parts[3], &msg.Event); err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+		msg.Payload = parts[4]
+		return &msg, nil
+	}
+
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// encodeMessage serializes msg in the given serializer version.
+func encodeMessage(msg *message, vsn string) ([]byte, error) {
+	if msg.Payload == nil {
+		msg.Payload = json.RawMessage("{}")
+	}
+	if vsn == vsn2 {
+		return json.Marshal([]interface{}{msg.JoinRef, msg.Ref, msg.Topic, msg.Event, msg.Payload})
+	}
+	return json.Marshal(msg)
+}
+
+// newMessage builds a server push with a JSON-encoded payload.
+func newMessage(topic, event string, payload interface{}) (*message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &message{Topic: topic, Event: event, Payload: data}, nil
+}
+
+// replyPayload is the payload of a phx_reply.
+type replyPayload struct {
+	Status   string      `json:"status"`
+	Response interface{} `json:"response"`
+}
@@ -0,0 +1,56 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeMessage_V1(t *testing.T) {
+	data := []byte(`{"topic":"realtime:room1","event":"phx_join","payload":{"config":{}},"ref":"1","join_ref":"1"}`)
+
+	msg, err := decodeMessage(data, vsn1)
+	if err != nil {
+		t.Fatalf("decodeMessage() failed: %v", err)
+	}
+	if msg.Topic != "realtime:room1" || msg.Event != eventJoin {
+		t.Errorf("decoded %q/%q, want realtime:room1/phx_join", msg.Topic, msg.Event)
+	}
+	if msg.Ref == nil || *msg.Ref != "1" {
+		t.Errorf("ref = %v, want 1", msg.Ref)
+	}
+}
+
+func TestDecodeMessage_V2(t *testing.T) {
+	data := []byte(`["3","4","realtime:room1","broadcast",{"type":"broadcast","event":"cursor","payload":{"x":1}}]`)
+
+	msg, err := decodeMessage(data, vsn2)
+	if err != nil {
+		t.Fatalf("decodeMessage() failed: %v", err)
+	}
+	if *msg.JoinRef != "3" || *msg.Ref != "4" || msg.Event != eventBroadcast {
+		t.Errorf("decoded %+v", msg)
+	}
+
+	if _, err := decodeMessage([]byte(`["1","2","topic"]`), vsn2); err == nil {
+		t.Error("decodeMessage() accepted a short array")
+	}
+}
+
+func TestEncodeMessage_RoundTrip(t *testing.T) {
+	ref := "7"
+	original := &message{Ref: &ref, Topic: "phoenix", Event: eventReply, Payload: json.RawMessage(`{"status":"ok","response":{}}`)}
+
+	for _, vsn := range []string{vsn1, vsn2} {
+		data, err := encodeMessage(original, vsn)
+		if err != nil {
+			t.Fatalf("encodeMessage(%s) failed: %v", vsn, err)
+		}
+		decoded, err := decodeMessage(data, vsn)
+		if err != nil {
+			t.Fatalf("decodeMessage(%s) failed: %v", vsn, err)
+		}
+		if decoded.JoinRef != nil || *decoded.Ref != ref || decoded.Topic != original.Topic {
+			t.Errorf("%s round trip = %+v", vsn, decoded)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package realtime implements a Supabase-compatible Realtime server.
+//
+// Clients connect to /realtime/v1/websocket and speak the Phoenix channel
+// protocol used by supabase-js (serializer versions 1.0.0 and 2.0.0).
+// Three features are supported on a channel:
+//
+//   - broadcast: messages relayed between clients of the same channel
+//   - presence: shared, per-client state with join/leave diffs
+//   - postgres_changes: row changes on tables in the supabase_realtime
+//     publication, delivered via LISTEN/NOTIFY triggers
+//
+// As on Supabase, tables opt in to change events with:
+//
+//	ALTER PUBLICATION supabase_realtime ADD TABLE messages;
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/markb/supalite/internal/log"
+)
+
+// PostgresConnector opens connections to the database.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresConnector interface {
+	Connect(ctx context.Context) (*pgx.Conn, error)
+}
+
+// TokenVerifier verifies API keys and user access tokens.
+//
+// keys.Manager satisfies this interface.
+type TokenVerifier interface {
+	VerifyToken(tokenString string) (jwt.Token, error)
+}
+
+// Config holds the configuration for the realtime server.
+type Config struct {
+	Database      PostgresConnector // Database to stream changes from
+	Verifier      TokenVerifier     // Verifies apikey and access_token values
+	UserJWTSecret string            // GoTrue's HS256 secret, for user access tokens
+}
+
+// Server relays broadcast, presence, and postgres_changes messages to
+// connected clients.
+type Server struct {
+	config Config
+	router *chi.Mux
+
+	mu       sync.RWMutex
+	topics   map[string]map[*subscription]struct{} // topic -> joined subscriptions
+	presence map[string]map[string]presenceEntry   // topic -> presence key -> state
+
+	columns   *columnCache
+	listening atomic.Bool // Holding the LISTEN connection for row changes
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a new realtime server.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		config:   cfg,
+		router:   chi.NewRouter(),
+		topics:   make(map[string]map[*subscription]struct{}),
+		presence: make(map[string]map[string]presenceEntry),
+		columns:  newColumnCache(),
+	}
+
+	s.router.Get("/websocket", s.handleWebSocket)
+	s.router.Post("/api/broadcast", s.handleBroadcastAPI)
+
+	return s
+}
+
+// Start installs the change-capture triggers and begins listening for
+// row changes. Broadcast and presence work even if this fails.
+func (s *Server) Start(ctx context.Context) error {
+	conn, err := s.config.Database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	err = installChangeCapture(ctx, conn)
+	conn.Close(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The listener outlives the startup context
+	listenCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.listen(listenCtx)
+	}()
+
+	return nil
+}
+
+// Stop stops listening for changes. Connected clients are left to the
+// HTTP server's shutdown.
+func (s *Server) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// Listening reports whether row changes are being received, i.e.
+// postgres_changes subscriptions work.
+func (s *Server) Listening() bool {
+	return s.listening.Load()
+}
+
+// Handler returns the HTTP handler for the realtime endpoints, relative to
+// /realtime/v1.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// handleWebSocket upgrades a client connection.
+//
+// GET /websocket?apikey=<key>&vsn=1.0.0
+//
+// The apikey query parameter (or header) must be a valid project key.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	apikey := r.URL.Query().Get("apikey")
+	if apikey == "" {
+		apikey = r.Header.Get("apikey")
+	}
+	claims, err := s.verify(apikey)
+	if err != nil {
+		http.Error(w, "invalid or missing apikey", http.StatusUnauthorized)
+		return
+	}
+
+	vsn := r.URL.Query().Get("vsn")
+	if vsn != vsn2 {
+		vsn = vsn1
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		// CORS is permissive for the whole API; browsers connect from any origin
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		log.Warn("realtime websocket upgrade failed", "error", err)
+		return
+	}
+
+	c := newClient(s, conn, vsn, claims)
+	c.run(r.Context())
+}
+
+// broadcastRequest is the body of POST /api/broadcast.
+type broadcastRequest struct {
+	Messages []struct {
+		Topic   string          `json:"topic"`
+		Event   string          `json:"event"`
+		Payload json.RawMessage `json:"payload"`
+	} `json:"messages"`
+}
+
+// handleBroadcastAPI sends broadcast messages without a WebSocket.
+//
+// POST /api/broadcast
+//
+// Used by supabase-js when sending on a channel that isn't subscribed.
+// Request body:
+//
+//	{"messages": [{"topic": "room1", "event": "cursor", "payload": {...}}]}
+func (s *Server) handleBroadcastAPI(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("apikey")
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		token = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if _, err := s.verify(token); err != nil {
+		http.Error(w, "invalid or missing apikey", http.StatusUnauthorized)
+		return
+	}
+
+	var req broadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, m := range req.Messages {
+		topic := m.Topic
+		if !strings.HasPrefix(topic, "realtime:") {
+			topic = "realtime:" + topic
+		}
+		s.broadcast(topic, nil, broadcastPayload{Type: "broadcast", Event: m.Event, Payload: m.Payload})
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify checks a token and returns its claims. Project keys are checked
+// with the key manager; user sessions issued by GoTrue are checked with
+// GoTrue's secret, which differs from the project key in ES256 mode.
+func (s *Server) verify(token string) (map[string]interface{}, error) {
+	_, err := s.config.Verifier.VerifyToken(token)
+	if err != nil && s.config.UserJWTSecret != "" {
+		_, err = jwt.ParseString(token, jwt.WithKey(jwa.HS256, []byte(s.config.UserJWTSecret)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the payload as sent, so numeric claims such as exp reach
+	// request.jwt.claims as numbers, like on PostgREST
+	parts := strings.Split(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// subscribe registers a joined subscription on its topic.
+func (s *Server) subscribe(sub *subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs, ok := s.topics[sub.topic]
+	if !ok {
+		subs = make(map[*subscription]struct{})
+		s.topics[sub.topic] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+// unsubscribe removes a subscription and its presence.
+func (s *Server) unsubscribe(sub *subscription) {
+	s.untrack(sub)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subs, ok := s.topics[sub.topic]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(s.topics, sub.topic)
+		}
+	}
+}
+
+// subscribers returns a snapshot of the subscriptions on a topic.
+func (s *Server) subscribers(topic string) []*subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*subscription, 0, len(s.topics[topic]))
+	for sub := range s.topics[topic] {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// allSubscriptions returns a snapshot of every joined subscription.
+func (s *Server) allSubscriptions() []*subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var subs []*subscription
+	for _, topicSubs := range s.topics {
+		for sub := range topicSubs {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// broadcastPayload is the payload of a broadcast event.
+type broadcastPayload struct {
+	Type    string          `json:"type"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// broadcast relays a broadcast to every subscription on topic except
+// sender, unless the sender asked to receive its own messages.
+func (s *Server) broadcast(topic string, sender *subscription, payload broadcastPayload) {
+	msg, err := newMessage(topic, eventBroadcast, payload)
+	if err != nil {
+		return
+	}
+	for _, sub := range s.subscribers(topic) {
+		if sub == sender && !sub.broadcastSelf {
+			continue
+		}
+		sub.client.push(msg)
+	}
+}
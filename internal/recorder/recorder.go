@@ -0,0 +1,207 @@
+// Package recorder keeps recent REST requests together with the SQL each
+// one ran, for debugging how a PostgREST-style query was translated.
+//
+// A Recording is attached to a request's context, and Tracer, installed
+// as the connection pools' query tracer, appends every statement run with
+// that context to it: the SQL, its bind parameters, the rows it returned
+// or changed, and its error. Finished requests go into a Recorder, a ring
+// buffer of the most recent ones.
+//
+// A Recording marked DryRun tells the REST handlers to roll back instead
+// of committing, so a request can be explained without changing anything.
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSize is how many requests a Recorder keeps by default.
+const DefaultSize = 200
+
+// Statement is one SQL statement a request ran.
+type Statement struct {
+	SQL        string  `json:"sql"`
+	Args       []any   `json:"args"`
+	Rows       int64   `json:"rows"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Request is a recorded request and the statements it ran, in order.
+type Request struct {
+	ID         int64       `json:"id"`
+	Time       time.Time   `json:"time"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query,omitempty"`
+	Role       string      `json:"role,omitempty"`
+	Status     int         `json:"status"`
+	DurationMS float64     `json:"duration_ms"`
+	Statements []Statement `json:"statements"`
+}
+
+// Recording collects the statements of one request.
+type Recording struct {
+	DryRun bool // Roll back instead of committing
+
+	mu         sync.Mutex
+	statements []Statement
+}
+
+type contextKey struct{}
+
+// WithRecording returns a context whose statements are recorded in rec.
+func WithRecording(ctx context.Context, rec *Recording) context.Context {
+	return context.WithValue(ctx, contextKey{}, rec)
+}
+
+// FromContext returns the context's recording, or nil.
+func FromContext(ctx context.Context) *Recording {
+	rec, _ := ctx.Value(contextKey{}).(*Recording)
+	return rec
+}
+
+// IsDryRun reports whether ctx belongs to a request being explained.
+func IsDryRun(ctx context.Context) bool {
+	rec := FromContext(ctx)
+	return rec != nil && rec.DryRun
+}
+
+// Statements returns the statements recorded so far.
+func (rec *Recording) Statements() []Statement {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Statement(nil), rec.statements...)
+}
+
+func (rec *Recording) add(st Statement) {
+	rec.mu.Lock()
+	rec.statements = append(rec.statements, st)
+	rec.mu.Unlock()
+}
+
+// Recorder keeps the most recent requests.
+type Recorder struct {
+	mu       sync.Mutex
+	requests []Request // Ring buffer, oldest at next once full
+	next     int
+	full     bool
+	lastID   int64
+}
+
+// New creates a recorder keeping the last size requests (default:
+// DefaultSize).
+func New(size int) *Recorder {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Recorder{requests: make([]Request, size)}
+}
+
+// Add records a finished request, assigning its ID, and evicts the oldest
+// one when the buffer is full.
+func (r *Recorder) Add(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	req.ID = r.lastID
+	r.requests[r.next] = req
+	r.next = (r.next + 1) % len(r.requests)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Recent returns the recorded requests, newest first.
+func (r *Recorder) Recent() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.requests)
+	}
+	recent := make([]Request, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, r.requests[(r.next-i+len(r.requests))%len(r.requests)])
+	}
+	return recent
+}
+
+// Clear drops every recorded request.
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.requests)
+	r.next = 0
+	r.full = false
+}
+
+// Tracer is a pgx.QueryTracer appending each statement to the recording
+// in its context, if any, and passing it on to Next.
+type Tracer struct {
+	Next pgx.QueryTracer // Optional: e.g. tracing.QueryTracer
+}
+
+var _ pgx.QueryTracer = Tracer{}
+
+type startKey struct{}
+
+// started is what TraceQueryStart passes on to TraceQueryEnd.
+type started struct {
+	at   time.Time
+	sql  string
+	args []any
+}
+
+func (t Tracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if t.Next != nil {
+		ctx = t.Next.TraceQueryStart(ctx, conn, data)
+	}
+	if FromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, startKey{}, started{at: time.Now(), sql: data.SQL, args: data.Args})
+}
+
+func (t Tracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if t.Next != nil {
+		t.Next.TraceQueryEnd(ctx, conn, data)
+	}
+	rec := FromContext(ctx)
+	start, ok := ctx.Value(startKey{}).(started)
+	if rec == nil || !ok {
+		return
+	}
+
+	st := Statement{
+		SQL:        start.sql,
+		Args:       make([]any, len(start.args)),
+		Rows:       data.CommandTag.RowsAffected(),
+		DurationMS: float64(time.Since(start.at).Microseconds()) / 1000,
+	}
+	for i, arg := range start.args {
+		st.Args[i] = jsonValue(arg)
+	}
+	if data.Err != nil {
+		st.Error = data.Err.Error()
+	}
+	rec.add(st)
+}
+
+// jsonValue returns a bind parameter as it can be shown in JSON: bytes as
+// text, and anything JSON can't encode formatted with fmt.
+func jsonValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
@@ -0,0 +1,56 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRecorder_Ring(t *testing.T) {
+	r := New(2)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		r.Add(Request{Path: path})
+	}
+
+	recent := r.Recent()
+	if len(recent) != 2 || recent[0].Path != "/c" || recent[1].Path != "/b" {
+		t.Fatalf("Recent() = %+v, want /c then /b", recent)
+	}
+	if recent[0].ID != 3 {
+		t.Errorf("ID = %d, want 3", recent[0].ID)
+	}
+
+	r.Clear()
+	if recent := r.Recent(); len(recent) != 0 {
+		t.Errorf("Recent() after Clear = %+v", recent)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var tracer Tracer
+	rec := &Recording{}
+	ctx := WithRecording(context.Background(), rec)
+
+	qctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT $1, $2", Args: []any{[]byte("a"), 5}})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	qctx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "DELETE FROM t"})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("permission denied")})
+
+	// Queries outside a recorded request are left alone
+	qctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{})
+
+	statements := rec.Statements()
+	if len(statements) != 2 {
+		t.Fatalf("recorded %d statements, want 2", len(statements))
+	}
+	if st := statements[0]; st.SQL != "SELECT $1, $2" || st.Args[0] != "a" || st.Args[1] != 5 || st.Rows != 1 {
+		t.Errorf("first = %+v", st)
+	}
+	if st := statements[1]; st.Error != "permission denied" {
+		t.Errorf("second = %+v", st)
+	}
+}
@@ -0,0 +1,83 @@
+// Package rls maps JWT claims to the Postgres role and request settings
+// that Row Level Security policies written for Supabase rely on.
+//
+// PostgREST runs each request as the database role named by the JWT "role"
+// claim and exposes the claims through the request.jwt.claims setting, which
+// is what auth.uid(), auth.role(), and auth.jwt() read. This package holds
+// that mapping so the REST layer and the dashboard debugging tools agree on it.
+package rls
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	// RoleAnon is the role used for requests without a user session.
+	RoleAnon = "anon"
+
+	// RoleAuthenticated is the role used for signed-in users.
+	RoleAuthenticated = "authenticated"
+
+	// RoleServiceRole is the administrative role that bypasses RLS.
+	RoleServiceRole = "service_role"
+
+	// ClaimsSetting is the GUC holding the full JSON claims object.
+	ClaimsSetting = "request.jwt.claims"
+
+	// claimSettingPrefix is the legacy per-claim GUC prefix
+	// (request.jwt.claim.sub, request.jwt.claim.role, ...).
+	claimSettingPrefix = "request.jwt.claim."
+)
+
+// RoleForClaims returns the Postgres role a request carrying the given
+// claims runs as. Requests without a role claim run as anon.
+func RoleForClaims(claims map[string]interface{}) string {
+	if role, ok := claims["role"].(string); ok && role != "" {
+		return role
+	}
+	return RoleAnon
+}
+
+// Setting is a single Postgres configuration parameter set for a request.
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Settings returns the request settings (GUCs) for the given claims, in a
+// stable order: the full claims JSON first, followed by the legacy
+// per-claim settings for scalar claims sorted by name.
+func Settings(claims map[string]interface{}) ([]Setting, error) {
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	settings := []Setting{{Name: ClaimsSetting, Value: string(claimsJSON)}}
+
+	names := make([]string, 0, len(claims))
+	for name := range claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch v := claims[name].(type) {
+		case string:
+			settings = append(settings, Setting{Name: claimSettingPrefix + name, Value: v})
+		case float64:
+			settings = append(settings, Setting{Name: claimSettingPrefix + name, Value: strconv.FormatFloat(v, 'f', -1, 64)})
+		case bool, int, int64:
+			settings = append(settings, Setting{Name: claimSettingPrefix + name, Value: fmt.Sprint(v)})
+		}
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,69 @@
+package rls
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoleForClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string
+	}{
+		{"no claims", nil, RoleAnon},
+		{"empty role", map[string]interface{}{"role": ""}, RoleAnon},
+		{"anon", map[string]interface{}{"role": "anon"}, RoleAnon},
+		{"authenticated", map[string]interface{}{"role": "authenticated", "sub": "123"}, RoleAuthenticated},
+		{"service role", map[string]interface{}{"role": "service_role"}, RoleServiceRole},
+		{"custom role", map[string]interface{}{"role": "editor"}, "editor"},
+		{"non-string role", map[string]interface{}{"role": 42}, RoleAnon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoleForClaims(tt.claims); got != tt.want {
+				t.Errorf("RoleForClaims() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":           "user-1",
+		"role":          "authenticated",
+		"exp":           float64(1700000000),
+		"user_metadata": map[string]interface{}{"name": "Bob"},
+	}
+
+	settings, err := Settings(claims)
+	if err != nil {
+		t.Fatalf("Settings() failed: %v", err)
+	}
+
+	if settings[0].Name != ClaimsSetting {
+		t.Fatalf("first setting = %q, want %q", settings[0].Name, ClaimsSetting)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(settings[0].Value), &decoded); err != nil {
+		t.Fatalf("claims setting is not valid JSON: %v", err)
+	}
+	if decoded["sub"] != "user-1" {
+		t.Errorf("claims JSON sub = %v, want user-1", decoded["sub"])
+	}
+
+	want := []Setting{
+		{Name: "request.jwt.claim.exp", Value: "1700000000"},
+		{Name: "request.jwt.claim.role", Value: "authenticated"},
+		{Name: "request.jwt.claim.sub", Value: "user-1"},
+	}
+	if len(settings)-1 != len(want) {
+		t.Fatalf("got %d per-claim settings, want %d: %+v", len(settings)-1, len(want), settings)
+	}
+	for i, w := range want {
+		if settings[i+1] != w {
+			t.Errorf("setting[%d] = %+v, want %+v", i+1, settings[i+1], w)
+		}
+	}
+}
@@ -0,0 +1,417 @@
+package rls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SimulationRequest describes an operation to test against a table's policies.
+type SimulationRequest struct {
+	// Schema and Table identify the target table (Schema defaults to public).
+	Schema string `json:"schema,omitempty"`
+	Table  string `json:"table"`
+
+	// Operation is one of select, insert, update, or delete.
+	Operation string `json:"operation"`
+
+	// Claims are the JWT claims the request runs with. The Postgres role is
+	// derived from the "role" claim (see RoleForClaims).
+	Claims map[string]interface{} `json:"claims,omitempty"`
+
+	// Row holds column values for insert and update.
+	Row map[string]interface{} `json:"row,omitempty"`
+
+	// Match restricts update and delete (and select) to rows whose columns
+	// equal the given values.
+	Match map[string]interface{} `json:"match,omitempty"`
+}
+
+// PolicyResult describes a single policy on the simulated table.
+type PolicyResult struct {
+	Name        string   `json:"name"`
+	Command     string   `json:"command"`
+	Permissive  bool     `json:"permissive"`
+	Roles       []string `json:"roles"`
+	Using       string   `json:"using,omitempty"`
+	WithCheck   string   `json:"with_check,omitempty"`
+	Applies     bool     `json:"applies"`
+	MatchedRows *int64   `json:"matched_rows,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// SimulationResult reports whether RLS would permit an operation.
+type SimulationResult struct {
+	Schema       string         `json:"schema"`
+	Table        string         `json:"table"`
+	Operation    string         `json:"operation"`
+	Role         string         `json:"role"`
+	RLSEnabled   bool           `json:"rls_enabled"`
+	RLSForced    bool           `json:"rls_forced"`
+	Permitted    bool           `json:"permitted"`
+	Reason       string         `json:"reason"`
+	RowsTotal    int64          `json:"rows_total"`
+	RowsVisible  *int64         `json:"rows_visible,omitempty"`
+	RowsAffected *int64         `json:"rows_affected,omitempty"`
+	Plan         []string       `json:"plan,omitempty"`
+	Policies     []PolicyResult `json:"policies"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// ErrTableNotFound is returned when the simulated table does not exist.
+var ErrTableNotFound = errors.New("table not found")
+
+// Simulate runs an operation as the role derived from req.Claims inside a
+// transaction that is always rolled back, and reports whether Row Level
+// Security permits it and which policies match.
+//
+// conn must belong to a role that can SET ROLE to the simulated role
+// (the embedded superuser can). No changes are ever committed.
+func Simulate(ctx context.Context, conn *pgx.Conn, req SimulationRequest) (*SimulationResult, error) {
+	if req.Table == "" {
+		return nil, fmt.Errorf("table is required")
+	}
+	if req.Schema == "" {
+		req.Schema = "public"
+	}
+	operation := strings.ToLower(req.Operation)
+	switch operation {
+	case "select", "insert", "update", "delete":
+	default:
+		return nil, fmt.Errorf("unsupported operation %q (expected select, insert, update, or delete)", req.Operation)
+	}
+	if (operation == "insert" || operation == "update") && len(req.Row) == 0 {
+		return nil, fmt.Errorf("row is required for %s", operation)
+	}
+
+	role := RoleForClaims(req.Claims)
+	result := &SimulationResult{
+		Schema:    req.Schema,
+		Table:     req.Table,
+		Operation: operation,
+		Role:      role,
+		Policies:  []PolicyResult{},
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Simulations never commit
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = '5s'"); err != nil {
+		return nil, fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT c.relrowsecurity, c.relforcerowsecurity
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'v')
+	`, req.Schema, req.Table).Scan(&result.RLSEnabled, &result.RLSForced)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrTableNotFound
+		}
+		return nil, fmt.Errorf("failed to look up table: %w", err)
+	}
+
+	// Make the claims visible to auth.uid()/auth.jwt() before evaluating anything
+	settings, err := Settings(req.Claims)
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", setting.Name, setting.Value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", setting.Name, err)
+		}
+	}
+
+	table := pgx.Identifier{req.Schema, req.Table}.Sanitize()
+	matchClause, matchArgs := buildMatchClause(req.Match, 0)
+
+	// Count rows as the connecting (privileged) role for context
+	countQuery := "SELECT count(*) FROM " + table
+	if matchClause != "" {
+		countQuery += " WHERE " + matchClause
+	}
+	if err := tx.QueryRow(ctx, countQuery, matchArgs...).Scan(&result.RowsTotal); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	if err := loadPolicies(ctx, tx, result, req, table, matchClause, matchArgs); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize()); err != nil {
+		return nil, fmt.Errorf("failed to switch to role %s: %w", role, err)
+	}
+
+	var opErr error
+	switch operation {
+	case "select":
+		opErr = simulateSelect(ctx, tx, result, table, matchClause, matchArgs)
+	case "insert":
+		opErr = simulateInsert(ctx, tx, result, table, req.Row)
+	case "update":
+		opErr = simulateUpdate(ctx, tx, result, table, req.Row, req.Match)
+	case "delete":
+		opErr = simulateDelete(ctx, tx, result, table, matchClause, matchArgs)
+	}
+
+	explain(result, opErr)
+	return result, nil
+}
+
+// loadPolicies collects the table's policies and, for the ones that apply to
+// the simulated role and operation, counts the rows their USING expression
+// matches.
+func loadPolicies(ctx context.Context, tx pgx.Tx, result *SimulationResult, req SimulationRequest, table, matchClause string, matchArgs []interface{}) error {
+	rows, err := tx.Query(ctx, `
+		SELECT policyname, permissive = 'PERMISSIVE', roles::text[], cmd,
+			COALESCE(qual, ''), COALESCE(with_check, '')
+		FROM pg_policies
+		WHERE schemaname = $1 AND tablename = $2
+		ORDER BY policyname
+	`, req.Schema, req.Table)
+	if err != nil {
+		return fmt.Errorf("failed to list policies: %w", err)
+	}
+	for rows.Next() {
+		var p PolicyResult
+		if err := rows.Scan(&p.Name, &p.Permissive, &p.Roles, &p.Command, &p.Using, &p.WithCheck); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan policy: %w", err)
+		}
+		p.Applies = policyApplies(p, result.Role, result.Operation)
+		result.Policies = append(result.Policies, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list policies: %w", err)
+	}
+
+	for i := range result.Policies {
+		p := &result.Policies[i]
+		if !p.Applies || p.Using == "" {
+			continue
+		}
+
+		query := fmt.Sprintf("SELECT count(*) FROM %s WHERE (%s)", table, p.Using)
+		if matchClause != "" {
+			query += " AND " + matchClause
+		}
+
+		// Evaluate each expression in a savepoint so a broken policy
+		// doesn't abort the whole simulation
+		if _, err := tx.Exec(ctx, "SAVEPOINT policy_eval"); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		var matched int64
+		if err := tx.QueryRow(ctx, query, matchArgs...).Scan(&matched); err != nil {
+			p.Error = err.Error()
+			if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT policy_eval"); err != nil {
+				return fmt.Errorf("failed to roll back savepoint: %w", err)
+			}
+			continue
+		}
+		p.MatchedRows = &matched
+		if _, err := tx.Exec(ctx, "RELEASE SAVEPOINT policy_eval"); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// policyApplies reports whether a policy is considered for the role and operation.
+func policyApplies(p PolicyResult, role, operation string) bool {
+	if p.Command != "ALL" && !strings.EqualFold(p.Command, operation) {
+		return false
+	}
+	for _, r := range p.Roles {
+		if r == "public" || r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func simulateSelect(ctx context.Context, tx pgx.Tx, result *SimulationResult, table, matchClause string, matchArgs []interface{}) error {
+	query := "SELECT * FROM " + table
+	if matchClause != "" {
+		query += " WHERE " + matchClause
+	}
+
+	rows, err := tx.Query(ctx, "EXPLAIN "+query, matchArgs...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return err
+		}
+		result.Plan = append(result.Plan, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var visible int64
+	if err := tx.QueryRow(ctx, "SELECT count(*) FROM ("+query+") AS visible", matchArgs...).Scan(&visible); err != nil {
+		return err
+	}
+	result.RowsVisible = &visible
+	return nil
+}
+
+func simulateInsert(ctx context.Context, tx pgx.Tx, result *SimulationResult, table string, row map[string]interface{}) error {
+	columns := sortedKeys(row)
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		quoted[i] = pgx.Identifier{col}.Sanitize()
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = row[col]
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected := tag.RowsAffected()
+	result.RowsAffected = &affected
+	return nil
+}
+
+func simulateUpdate(ctx context.Context, tx pgx.Tx, result *SimulationResult, table string, row, match map[string]interface{}) error {
+	columns := sortedKeys(row)
+	sets := make([]string, len(columns))
+	args := make([]interface{}, 0, len(columns)+len(match))
+	for i, col := range columns {
+		sets[i] = fmt.Sprintf("%s = $%d", pgx.Identifier{col}.Sanitize(), i+1)
+		args = append(args, row[col])
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s", table, strings.Join(sets, ", "))
+	matchClause, matchArgs := buildMatchClause(match, len(args))
+	if matchClause != "" {
+		query += " WHERE " + matchClause
+		args = append(args, matchArgs...)
+	}
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected := tag.RowsAffected()
+	result.RowsAffected = &affected
+	return nil
+}
+
+func simulateDelete(ctx context.Context, tx pgx.Tx, result *SimulationResult, table, matchClause string, matchArgs []interface{}) error {
+	query := "DELETE FROM " + table
+	if matchClause != "" {
+		query += " WHERE " + matchClause
+	}
+
+	tag, err := tx.Exec(ctx, query, matchArgs...)
+	if err != nil {
+		return err
+	}
+	affected := tag.RowsAffected()
+	result.RowsAffected = &affected
+	return nil
+}
+
+// explain fills in Permitted and a human-readable Reason from the outcome.
+func explain(result *SimulationResult, opErr error) {
+	if opErr != nil {
+		result.Error = opErr.Error()
+		var pgErr *pgconn.PgError
+		if errors.As(opErr, &pgErr) && pgErr.Code == "42501" {
+			// insufficient_privilege: missing grant or WITH CHECK violation
+			result.Reason = "denied: " + pgErr.Message
+		} else {
+			result.Reason = "operation failed: " + opErr.Error()
+		}
+		return
+	}
+
+	if !result.RLSEnabled {
+		result.Permitted = true
+		result.Reason = "row level security is disabled on this table; access is governed by grants only"
+		return
+	}
+	if result.Role == RoleServiceRole {
+		result.Permitted = true
+		result.Reason = "service_role bypasses row level security"
+		return
+	}
+
+	var applicable []string
+	for _, p := range result.Policies {
+		if p.Applies {
+			applicable = append(applicable, p.Name)
+		}
+	}
+
+	switch {
+	case result.Operation != "insert" && result.RowsTotal == 0:
+		// Nothing to see or change, which says nothing about the policies
+		result.Permitted = true
+		result.Reason = "no rows matched"
+	case result.RowsVisible != nil:
+		result.Permitted = *result.RowsVisible > 0
+		result.Reason = fmt.Sprintf("%d of %d rows visible", *result.RowsVisible, result.RowsTotal)
+	case result.Operation == "insert":
+		result.Permitted = true
+		result.Reason = "row passes WITH CHECK"
+	case result.RowsAffected != nil:
+		result.Permitted = *result.RowsAffected > 0
+		result.Reason = fmt.Sprintf("%d of %d matching rows affected", *result.RowsAffected, result.RowsTotal)
+	}
+
+	if len(applicable) == 0 {
+		result.Permitted = false
+		result.Reason = "no policy applies to role " + result.Role + " for " + result.Operation + " (default deny)"
+		return
+	}
+	result.Reason += "; applicable policies: " + strings.Join(applicable, ", ")
+}
+
+// buildMatchClause builds an equality WHERE clause from column values.
+// offset is the number of parameters already used by the statement.
+func buildMatchClause(match map[string]interface{}, offset int) (string, []interface{}) {
+	if len(match) == 0 {
+		return "", nil
+	}
+	columns := sortedKeys(match)
+	clauses := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, col := range columns {
+		clauses[i] = fmt.Sprintf("%s = $%d", pgx.Identifier{col}.Sanitize(), offset+i+1)
+		args[i] = match[col]
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
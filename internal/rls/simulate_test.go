@@ -0,0 +1,176 @@
+package rls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPolicyApplies(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		roles     []string
+		role      string
+		operation string
+		want      bool
+	}{
+		{"public applies to anon", "SELECT", []string{"public"}, RoleAnon, "select", true},
+		{"public applies to any role", "SELECT", []string{"public"}, "editor", "select", true},
+		{"listed role", "SELECT", []string{"authenticated"}, RoleAuthenticated, "select", true},
+		{"one of several roles", "SELECT", []string{"anon", "authenticated"}, RoleAuthenticated, "select", true},
+		{"unlisted role", "SELECT", []string{"authenticated"}, RoleAnon, "select", false},
+		{"no roles", "SELECT", nil, RoleAnon, "select", false},
+		{"ALL covers select", "ALL", []string{"public"}, RoleAnon, "select", true},
+		{"ALL covers insert", "ALL", []string{"public"}, RoleAnon, "insert", true},
+		{"ALL covers delete", "ALL", []string{"authenticated"}, RoleAuthenticated, "delete", true},
+		{"ALL still checks the role", "ALL", []string{"authenticated"}, RoleAnon, "update", false},
+		{"other command", "SELECT", []string{"public"}, RoleAnon, "update", false},
+		{"INSERT is not UPDATE", "INSERT", []string{"public"}, RoleAnon, "update", false},
+		{"command is case-insensitive", "DELETE", []string{"public"}, RoleAnon, "delete", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PolicyResult{Command: tt.command, Roles: tt.roles}
+			if got := policyApplies(p, tt.role, tt.operation); got != tt.want {
+				t.Errorf("policyApplies(%s %v, %s, %s) = %v, want %v", tt.command, tt.roles, tt.role, tt.operation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	count := func(n int64) *int64 { return &n }
+	applying := []PolicyResult{
+		{Name: "own rows", Applies: true},
+		{Name: "admins", Applies: false},
+		{Name: "shared rows", Applies: true},
+	}
+	notApplying := []PolicyResult{{Name: "admins", Applies: false}}
+
+	tests := []struct {
+		name          string
+		result        SimulationResult
+		opErr         error
+		wantPermitted bool
+		wantReason    string
+		wantError     bool
+	}{
+		{
+			name:       "permission denied",
+			result:     SimulationResult{Operation: "insert", RLSEnabled: true, Role: RoleAnon},
+			opErr:      &pgconn.PgError{Code: "42501", Message: `new row violates row-level security policy for table "todos"`},
+			wantReason: `denied: new row violates row-level security policy for table "todos"`,
+			wantError:  true,
+		},
+		{
+			name:       "other failure",
+			result:     SimulationResult{Operation: "insert", RLSEnabled: true, Role: RoleAnon},
+			opErr:      errors.New("column \"nope\" does not exist"),
+			wantReason: `operation failed: column "nope" does not exist`,
+			wantError:  true,
+		},
+		{
+			name:          "RLS disabled",
+			result:        SimulationResult{Operation: "select", Role: RoleAnon, RowsTotal: 3, RowsVisible: count(3)},
+			wantPermitted: true,
+			wantReason:    "row level security is disabled on this table; access is governed by grants only",
+		},
+		{
+			name:          "service role",
+			result:        SimulationResult{Operation: "delete", RLSEnabled: true, Role: RoleServiceRole, RowsTotal: 2, RowsAffected: count(2)},
+			wantPermitted: true,
+			wantReason:    "service_role bypasses row level security",
+		},
+		{
+			name:          "rows visible",
+			result:        SimulationResult{Operation: "select", RLSEnabled: true, Role: RoleAuthenticated, RowsTotal: 10, RowsVisible: count(3), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "3 of 10 rows visible; applicable policies: own rows, shared rows",
+		},
+		{
+			name:       "no rows visible",
+			result:     SimulationResult{Operation: "select", RLSEnabled: true, Role: RoleAuthenticated, RowsTotal: 10, RowsVisible: count(0), Policies: applying},
+			wantReason: "0 of 10 rows visible; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "select matching no rows",
+			result:        SimulationResult{Operation: "select", RLSEnabled: true, Role: RoleAuthenticated, RowsVisible: count(0), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "no rows matched; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "insert",
+			result:        SimulationResult{Operation: "insert", RLSEnabled: true, Role: RoleAuthenticated, RowsTotal: 10, RowsAffected: count(1), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "row passes WITH CHECK; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "insert into an empty table",
+			result:        SimulationResult{Operation: "insert", RLSEnabled: true, Role: RoleAuthenticated, RowsAffected: count(1), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "row passes WITH CHECK; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "rows affected",
+			result:        SimulationResult{Operation: "update", RLSEnabled: true, Role: RoleAuthenticated, RowsTotal: 4, RowsAffected: count(1), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "1 of 4 matching rows affected; applicable policies: own rows, shared rows",
+		},
+		{
+			name:       "no rows affected",
+			result:     SimulationResult{Operation: "delete", RLSEnabled: true, Role: RoleAuthenticated, RowsTotal: 4, RowsAffected: count(0), Policies: applying},
+			wantReason: "0 of 4 matching rows affected; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "update matching no rows",
+			result:        SimulationResult{Operation: "update", RLSEnabled: true, Role: RoleAuthenticated, RowsAffected: count(0), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "no rows matched; applicable policies: own rows, shared rows",
+		},
+		{
+			name:          "delete matching no rows",
+			result:        SimulationResult{Operation: "delete", RLSEnabled: true, Role: RoleAuthenticated, RowsAffected: count(0), Policies: applying},
+			wantPermitted: true,
+			wantReason:    "no rows matched; applicable policies: own rows, shared rows",
+		},
+		{
+			name:       "default deny",
+			result:     SimulationResult{Operation: "select", RLSEnabled: true, Role: RoleAnon, RowsTotal: 10, RowsVisible: count(0), Policies: notApplying},
+			wantReason: "no policy applies to role anon for select (default deny)",
+		},
+		{
+			name:       "default deny without rows",
+			result:     SimulationResult{Operation: "delete", RLSEnabled: true, Role: RoleAnon, RowsAffected: count(0)},
+			wantReason: "no policy applies to role anon for delete (default deny)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			explain(&result, tt.opErr)
+			if result.Permitted != tt.wantPermitted || result.Reason != tt.wantReason {
+				t.Errorf("explain() = %v, %q; want %v, %q", result.Permitted, result.Reason, tt.wantPermitted, tt.wantReason)
+			}
+			if (result.Error != "") != tt.wantError {
+				t.Errorf("explain() error = %q, want error: %v", result.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestBuildMatchClause(t *testing.T) {
+	clause, args := buildMatchClause(map[string]interface{}{"user_id": "u1", "id": 7}, 2)
+	if clause != `"id" = $3 AND "user_id" = $4` || len(args) != 2 || args[0] != 7 || args[1] != "u1" {
+		t.Errorf("buildMatchClause() = %q, %v", clause, args)
+	}
+	if clause, args := buildMatchClause(nil, 0); clause != "" || args != nil {
+		t.Errorf("buildMatchClause(nil) = %q, %v; want nothing", clause, args)
+	}
+	// Column names are quoted, so they can't inject SQL
+	if clause, _ := buildMatchClause(map[string]interface{}{`a" = 1 OR "1`: 1}, 0); !strings.HasPrefix(clause, `"a"" = 1 OR ""1" = $1`) {
+		t.Errorf("buildMatchClause() = %q, want the column quoted", clause)
+	}
+}
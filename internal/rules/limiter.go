@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"sync"
+	"time"
+)
+
+// maxBuckets bounds the buckets a limiter keeps before pruning full ones.
+const maxBuckets = 10000
+
+// limiter is a token bucket per key: each key may burst up to capacity
+// requests, and regains capacity tokens per window.
+type limiter struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // tokens per second
+	buckets  map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(requests int, window time.Duration) *limiter {
+	return &limiter{
+		capacity: float64(requests),
+		rate:     float64(requests) / window.Seconds(),
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until the next token.
+func (l *limiter) allow(key string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(l.capacity, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// prune drops buckets that have refilled, which behave like new ones.
+func (l *limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
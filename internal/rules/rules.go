@@ -0,0 +1,256 @@
+// Package rules applies operator-defined rules to HTTP requests by route,
+// so the public surface can be tightened from supalite.json without code
+// changes.
+//
+// A rule matches requests by path prefix, and optionally by method and by
+// the caller's role (the "role" claim of the API key or session token).
+// Every matching rule applies, in order:
+//
+//	[
+//	  {"path": "/rest/v1", "methods": ["DELETE"], "roles": ["anon"], "deny": true},
+//	  {"path": "/rest/v1", "rate_limit": {"requests": 100, "window_seconds": 60}},
+//	  {"path": "/storage/v1", "headers": {"Cache-Control": "no-store"}},
+//	  {"path": "/auth/v1", "cors": {"allowed_origins": ["https://app.example.com"]}}
+//	]
+//
+// CORS is decided before a request is authenticated (preflight requests
+// carry no credentials), so CORS rules match by path alone, and the first
+// matching one wins.
+package rules
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// Rate limit keys.
+const (
+	PerIP  = "ip"  // Each client address gets its own budget (default)
+	PerKey = "key" // Each API key or session token gets its own budget
+)
+
+// DefaultWindow is the rate limit window when none is set.
+const DefaultWindow = time.Minute
+
+// Rule is a single route rule.
+type Rule struct {
+	Path    string   // Path prefix matched, e.g. "/rest/v1"
+	Methods []string // Methods matched (empty = all)
+	Roles   []string // Caller roles matched, e.g. "anon" (empty = all, including unauthenticated)
+
+	Deny         bool              // Reject matching requests with 403
+	RequireRoles []string          // Reject callers whose role is not listed
+	Headers      map[string]string // Response headers to set
+	CORS         *CORS             // CORS policy replacing the default one
+	RateLimit    *RateLimit        // Request budget
+}
+
+// RateLimit allows Requests per Window, refilled continuously.
+type RateLimit struct {
+	Requests int
+	Window   time.Duration // Default: DefaultWindow
+	Per      string        // PerIP (default) or PerKey
+}
+
+// CORS is the CORS policy of a rule.
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string // Default: GET, POST, PUT, PATCH, DELETE
+	AllowedHeaders   []string // Default: all
+	AllowCredentials bool
+	MaxAge           time.Duration // How long browsers may cache a preflight response
+}
+
+// options returns the policy as rs/cors options.
+func (c *CORS) options() cors.Options {
+	opts := cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
+	}
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	if len(opts.AllowedHeaders) == 0 {
+		opts.AllowedHeaders = []string{"*"}
+	}
+	return opts
+}
+
+// RoleFunc returns the role of the caller, or "" when the request carries
+// no valid API key or token.
+type RoleFunc func(r *http.Request) string
+
+// Engine applies rules to requests.
+type Engine struct {
+	rules []*rule
+	role  RoleFunc
+	now   func() time.Time
+}
+
+// rule is a validated Rule with its CORS policy and limiter built.
+type rule struct {
+	Rule
+	cors    *cors.Cors
+	limiter *limiter
+}
+
+// New validates rules and builds the engine. role is only called for
+// requests that a rule with Roles or RequireRoles could apply to.
+func New(rules []Rule, role RoleFunc) (*Engine, error) {
+	e := &Engine{role: role, now: time.Now}
+	for i, r := range rules {
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("rule %d: path must start with /, got %q", i+1, r.Path)
+		}
+		compiled := &rule{Rule: r}
+		compiled.Methods = make([]string, len(r.Methods))
+		for j, m := range r.Methods {
+			compiled.Methods[j] = strings.ToUpper(m)
+		}
+		if r.CORS != nil {
+			compiled.cors = cors.New(r.CORS.options())
+		}
+		if rl := r.RateLimit; rl != nil {
+			if rl.Requests <= 0 {
+				return nil, fmt.Errorf("rule %d: rate limit requests must be positive", i+1)
+			}
+			if rl.Window < 0 {
+				return nil, fmt.Errorf("rule %d: rate limit window must not be negative", i+1)
+			}
+			if rl.Per != "" && rl.Per != PerIP && rl.Per != PerKey {
+				return nil, fmt.Errorf("rule %d: rate limit per must be %q or %q, got %q", i+1, PerIP, PerKey, rl.Per)
+			}
+			window := rl.Window
+			if window == 0 {
+				window = DefaultWindow
+			}
+			compiled.limiter = newLimiter(rl.Requests, window)
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// Middleware enforces the rules: denials, required roles, rate limits,
+// and response headers.
+func (e *Engine) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := cleanPath(r.URL.Path)
+
+		// The role is looked up at most once, and only when needed
+		role, roleKnown := "", false
+		callerRole := func() string {
+			if !roleKnown {
+				role, roleKnown = e.role(r), true
+			}
+			return role
+		}
+
+		for _, rl := range e.rules {
+			if !matchPath(rl.Path, urlPath) || (len(rl.Methods) > 0 && !slices.Contains(rl.Methods, r.Method)) {
+				continue
+			}
+			if len(rl.Roles) > 0 && !slices.Contains(rl.Roles, callerRole()) {
+				continue
+			}
+
+			if rl.Deny {
+				http.Error(w, fmt.Sprintf("%s %s is not allowed", r.Method, r.URL.Path), http.StatusForbidden)
+				return
+			}
+			if len(rl.RequireRoles) > 0 && !slices.Contains(rl.RequireRoles, callerRole()) {
+				if callerRole() == "" {
+					http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+				} else {
+					http.Error(w, fmt.Sprintf("role %s is not allowed", callerRole()), http.StatusForbidden)
+				}
+				return
+			}
+			if rl.limiter != nil {
+				if wait, ok := rl.limiter.allow(rateKey(r, rl.RateLimit.Per), e.now()); !ok {
+					w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.999)))
+					http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+					return
+				}
+			}
+			for name, value := range rl.Headers {
+				w.Header().Set(name, value)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORS wraps next in the CORS policy of the first CORS rule matching each
+// request's path, or in fallback when none matches.
+func (e *Engine) CORS(next http.Handler, fallback *cors.Cors) http.Handler {
+	handlers := make(map[*rule]http.Handler)
+	for _, rl := range e.rules {
+		if rl.cors != nil {
+			handlers[rl] = rl.cors.Handler(next)
+		}
+	}
+	fallbackHandler := fallback.Handler(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := cleanPath(r.URL.Path)
+		for _, rl := range e.rules {
+			if rl.cors != nil && matchPath(rl.Path, urlPath) {
+				handlers[rl].ServeHTTP(w, r)
+				return
+			}
+		}
+		fallbackHandler.ServeHTTP(w, r)
+	})
+}
+
+// cleanPath normalizes a request path, so /rest//v1 and /rest/./v1 can't
+// slip past a rule for /rest/v1.
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// matchPath reports whether urlPath is prefix or lies below it. A prefix
+// matches whole segments: /rest/v1 matches /rest/v1/todos, not /rest/v10.
+func matchPath(prefix, urlPath string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// rateKey returns the key a request's rate limit budget is kept under.
+// Requests without a key share their address's budget.
+func rateKey(r *http.Request, per string) string {
+	if per == PerKey {
+		if key := callerKey(r); key != "" {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// callerKey returns the session token or API key a request carries.
+func callerKey(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	if key := strings.TrimSpace(r.Header.Get("apikey")); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("apikey")
+}
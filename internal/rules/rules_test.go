@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// roleFromHeader reads the caller's role from a test header.
+func roleFromHeader(r *http.Request) string {
+	return r.Header.Get("X-Test-Role")
+}
+
+func serve(t *testing.T, e *Engine, method, target, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := e.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(method, target, nil)
+	if role != "" {
+		req.Header.Set("X-Test-Role", role)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	e, err := New([]Rule{
+		{Path: "/rest/v1", Methods: []string{"delete"}, Roles: []string{"anon"}, Deny: true},
+		{Path: "/admin", RequireRoles: []string{"service_role"}},
+		{Path: "/storage/v1", Headers: map[string]string{"Cache-Control": "no-store"}},
+	}, roleFromHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		role     string
+		wantCode int
+	}{
+		{"anon delete denied", http.MethodDelete, "/rest/v1/todos?id=eq.1", "anon", http.StatusForbidden},
+		{"anon delete with dot segment denied", http.MethodDelete, "/rest/./v1//todos", "anon", http.StatusForbidden},
+		{"service_role delete allowed", http.MethodDelete, "/rest/v1/todos", "service_role", http.StatusOK},
+		{"anon read allowed", http.MethodGet, "/rest/v1/todos", "anon", http.StatusOK},
+		{"prefix matches whole segments", http.MethodDelete, "/rest/v10/todos", "anon", http.StatusOK},
+		{"required role missing", http.MethodGet, "/admin/v1/users", "", http.StatusUnauthorized},
+		{"required role wrong", http.MethodGet, "/admin/v1/users", "anon", http.StatusForbidden},
+		{"required role present", http.MethodGet, "/admin/v1/users", "service_role", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, e, tt.method, tt.target, tt.role); rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+
+	if got := serve(t, e, http.MethodGet, "/storage/v1/object/a", "").Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+}
+
+func TestMiddleware_RoleLookedUpOnlyWhenNeeded(t *testing.T) {
+	calls := 0
+	e, err := New([]Rule{
+		{Path: "/rest/v1", Roles: []string{"anon"}, Deny: true},
+		{Path: "/rest/v1", RequireRoles: []string{"anon", "service_role"}},
+	}, func(r *http.Request) string {
+		calls++
+		return "service_role"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, e, http.MethodGet, "/health", "")
+	if calls != 0 {
+		t.Errorf("role looked up %d times for an unmatched path, want 0", calls)
+	}
+	serve(t, e, http.MethodGet, "/rest/v1/todos", "")
+	if calls != 1 {
+		t.Errorf("role looked up %d times, want 1", calls)
+	}
+}
+
+func TestMiddleware_RateLimit(t *testing.T) {
+	e, err := New([]Rule{
+		{Path: "/rest/v1", RateLimit: &RateLimit{Requests: 2, Window: time.Minute}},
+	}, roleFromHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	e.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(t, e, http.MethodGet, "/rest/v1/todos", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := serve(t, e, http.MethodGet, "/rest/v1/todos", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if rec := serve(t, e, http.MethodGet, "/auth/v1/user", ""); rec.Code != http.StatusOK {
+		t.Errorf("other paths should not be limited, got %d", rec.Code)
+	}
+
+	// Half a window refills one request
+	now = now.Add(30 * time.Second)
+	if rec := serve(t, e, http.MethodGet, "/rest/v1/todos", ""); rec.Code != http.StatusOK {
+		t.Errorf("status after refill = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if got := rateKey(req, PerKey); got != "ip:192.0.2.1" {
+		t.Errorf("rateKey() without a key = %q, want the address", got)
+	}
+	req.Header.Set("apikey", "anon-key")
+	if got := rateKey(req, PerKey); got != "key:anon-key" {
+		t.Errorf("rateKey(PerKey) = %q", got)
+	}
+	req.Header.Set("Authorization", "Bearer session")
+	if got := rateKey(req, PerKey); got != "key:session" {
+		t.Errorf("rateKey(PerKey) with a session = %q", got)
+	}
+	if got := rateKey(req, PerIP); got != "ip:192.0.2.1" {
+		t.Errorf("rateKey(PerIP) = %q", got)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	e, err := New([]Rule{
+		{Path: "/auth/v1", CORS: &CORS{AllowedOrigins: []string{"https://app.example.com"}}},
+	}, roleFromHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := e.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		cors.New(cors.Options{AllowedOrigins: []string{"*"}}))
+
+	tests := []struct {
+		name   string
+		target string
+		origin string
+		want   string
+	}{
+		{"rule allows origin", "/auth/v1/token", "https://app.example.com", "https://app.example.com"},
+		{"rule rejects origin", "/auth/v1/token", "https://evil.example.com", ""},
+		{"fallback allows all", "/rest/v1/todos", "https://evil.example.com", "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, r := range []Rule{
+		{Path: "rest/v1"},
+		{Path: "/rest/v1", RateLimit: &RateLimit{}},
+		{Path: "/rest/v1", RateLimit: &RateLimit{Requests: 1, Per: "role"}},
+	} {
+		if _, err := New([]Rule{r}, roleFromHeader); err == nil {
+			t.Errorf("New(%+v) should fail", r)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+// Package schemacache keeps the database's tables, columns, keys, and
+// relationships in memory, so the REST API, the dashboard, and the docs
+// don't read the catalog on every request.
+//
+// The cache is loaded when the server starts. An event trigger sends a
+// notification on the supalite_schema channel at the end of every DDL
+// command, and the cache reloads when one arrives. NOTIFY supalite_schema
+// by hand, or Reload, refreshes it on demand, e.g. where event triggers
+// can't be created because the user isn't a superuser.
+package schemacache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/log"
+)
+
+// Channel is the LISTEN/NOTIFY channel schema changes are announced on.
+const Channel = "supalite_schema"
+
+// reloadDelay lets a burst of DDL, such as a migration, settle before the
+// cache reloads, so it reloads once for the lot.
+const reloadDelay = 100 * time.Millisecond
+
+// notifySQL creates the event trigger announcing schema changes. Dropped
+// objects are announced by sql_drop, everything else by ddl_command_end.
+const notifySQL = `
+CREATE SCHEMA IF NOT EXISTS admin;
+
+CREATE OR REPLACE FUNCTION admin.supalite_notify_schema_change() RETURNS event_trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM pg_notify('` + Channel + `', tg_tag);
+END;
+$$;
+
+DROP EVENT TRIGGER IF EXISTS supalite_schema_change;
+CREATE EVENT TRIGGER supalite_schema_change ON ddl_command_end
+	EXECUTE FUNCTION admin.supalite_notify_schema_change();
+
+DROP EVENT TRIGGER IF EXISTS supalite_schema_drop;
+CREATE EVENT TRIGGER supalite_schema_drop ON sql_drop
+	EXECUTE FUNCTION admin.supalite_notify_schema_change();
+`
+
+// PostgresConnector opens connections to the database.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresConnector interface {
+	Connect(ctx context.Context) (*pgx.Conn, error)
+}
+
+// Column is a column of a table or view.
+type Column struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`     // As format_type writes it, e.g. "character varying(80)"
+	TypeName   string `json:"udt_name"` // The type's name in pg_type, e.g. "varchar"
+	Nullable   bool   `json:"nullable"`
+	HasDefault bool   `json:"has_default"` // Default, identity, or generated
+	Comment    string `json:"comment,omitempty"`
+}
+
+// Table is a table, view, materialized view, or foreign table.
+type Table struct {
+	Schema     string   `json:"schema"`
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"` // "table" or "view"
+	Comment    string   `json:"comment,omitempty"`
+	RLSEnabled bool     `json:"rls_enabled"`
+	Columns    []Column `json:"columns"`
+	PrimaryKey []string `json:"primary_key"`
+}
+
+// Column returns the named column, or nil.
+func (t *Table) Column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// ForeignKey is a foreign key constraint. Columns and RefColumns pair up
+// in order.
+type ForeignKey struct {
+	Name       string   `json:"name"`
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	RefSchema  string   `json:"ref_schema"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+}
+
+// Junction is a table joining two others in a many-to-many relationship:
+// it has a foreign key to each.
+type Junction struct {
+	Schema string     `json:"schema"`
+	Table  string     `json:"table"`
+	From   ForeignKey `json:"from"` // To the first table
+	To     ForeignKey `json:"to"`   // To the second table
+}
+
+// Schema is a snapshot of the database's tables and foreign keys. It is
+// never changed once loaded; a reload makes a new one.
+type Schema struct {
+	LoadedAt    time.Time    `json:"loaded_at"`
+	Tables      []Table      `json:"tables"` // By schema, then name
+	ForeignKeys []ForeignKey `json:"foreign_keys"`
+
+	tables map[string]*Table
+}
+
+// Table returns the table or view schema.name, or nil.
+func (s *Schema) Table(schema, name string) *Table {
+	return s.tables[schema+"."+name]
+}
+
+// ForeignKeysBetween returns the foreign keys of table that reference
+// refTable, both in schema.
+func (s *Schema) ForeignKeysBetween(schema, table, refTable string) []ForeignKey {
+	var keys []ForeignKey
+	for _, fk := range s.ForeignKeys {
+		if fk.Schema == schema && fk.Table == table && fk.RefSchema == schema && fk.RefTable == refTable {
+			keys = append(keys, fk)
+		}
+	}
+	return keys
+}
+
+// Junctions returns the tables in schema with a foreign key to from and
+// another to to.
+func (s *Schema) Junctions(schema, from, to string) []Junction {
+	var junctions []Junction
+	for _, t := range s.Tables {
+		if t.Schema != schema {
+			continue
+		}
+		for _, a := range s.ForeignKeysBetween(schema, t.Name, from) {
+			for _, b := range s.ForeignKeysBetween(schema, t.Name, to) {
+				if a.Name != b.Name {
+					junctions = append(junctions, Junction{Schema: schema, Table: t.Name, From: a, To: b})
+				}
+			}
+		}
+	}
+	return junctions
+}
+
+// Cache holds the current Schema and reloads it when the database's schema
+// changes.
+type Cache struct {
+	db PostgresConnector
+
+	mu     sync.RWMutex
+	schema *Schema
+	load   sync.Mutex // One load at a time
+
+	changed   chan struct{} // A change was announced and not loaded yet
+	listening atomic.Bool
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// New returns a cache of db's schema. It is empty until Start or the
+// first call to Schema.
+func New(db PostgresConnector) *Cache {
+	return &Cache{db: db, changed: make(chan struct{}, 1)}
+}
+
+// Start creates the event trigger announcing schema changes, loads the
+// schema, and starts listening for changes. Without the event trigger,
+// which only a superuser can create, the cache only reloads on demand.
+func (c *Cache) Start(ctx context.Context) error {
+	conn, err := c.db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, notifySQL); err != nil {
+		log.Warn("schema changes won't reload the schema cache", "error", err)
+	}
+	conn.Close(ctx)
+
+	if _, err := c.Reload(ctx); err != nil {
+		return err
+	}
+
+	// The listener outlives the startup context
+	listenCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(2)
+	go func() {
+		defer c.wg.Done()
+		c.listen(listenCtx)
+	}()
+	go func() {
+		defer c.wg.Done()
+		c.reloadOnChange(listenCtx)
+	}()
+	return nil
+}
+
+// Stop stops listening for schema changes.
+func (c *Cache) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Listening reports whether schema changes are being received.
+func (c *Cache) Listening() bool {
+	return c.listening.Load()
+}
+
+// Schema returns the cached schema, loading it if it hasn't been yet.
+func (c *Cache) Schema(ctx context.Context) (*Schema, error) {
+	c.mu.RLock()
+	schema := c.schema
+	c.mu.RUnlock()
+	if schema != nil {
+		return schema, nil
+	}
+	return c.Refresh(ctx, time.Hour)
+}
+
+// Reload loads the schema from the database now.
+func (c *Cache) Reload(ctx context.Context) (*Schema, error) {
+	c.load.Lock()
+	defer c.load.Unlock()
+	return c.reload(ctx)
+}
+
+// Refresh reloads the schema unless it was loaded within maxAge. Lookups
+// that miss call it, so a table created a moment ago is found even if its
+// change notification hasn't arrived yet, without a miss reloading every
+// time.
+func (c *Cache) Refresh(ctx context.Context, maxAge time.Duration) (*Schema, error) {
+	c.load.Lock()
+	defer c.load.Unlock()
+
+	c.mu.RLock()
+	schema := c.schema
+	c.mu.RUnlock()
+	if schema != nil && time.Since(schema.LoadedAt) < maxAge {
+		return schema, nil
+	}
+	return c.reload(ctx)
+}
+
+// reload loads the schema; c.load must be held.
+func (c *Cache) reload(ctx context.Context) (*Schema, error) {
+	conn, err := c.db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	schema, err := Load(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the schema: %w", err)
+	}
+	c.mu.Lock()
+	c.schema = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// listen receives schema change notifications until ctx is cancelled,
+// reconnecting with backoff when the connection drops.
+func (c *Cache) listen(ctx context.Context) {
+	backoff := time.Second
+	for first := true; ; first = false {
+		err := c.listenOnce(ctx, !first)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("schema change listener disconnected", "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// listenOnce holds one LISTEN connection. Changes may have been missed
+// while reconnecting, so a reconnect counts as a change.
+func (c *Cache) listenOnce(ctx context.Context, reconnect bool) error {
+	conn, err := c.db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	c.listening.Store(true)
+	defer c.listening.Store(false)
+	if reconnect {
+		c.markChanged()
+	}
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		c.markChanged()
+	}
+}
+
+// markChanged asks reloadOnChange for a reload.
+func (c *Cache) markChanged() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// reloadOnChange reloads the schema after changes, once per burst.
+func (c *Cache) reloadOnChange(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.changed:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reloadDelay):
+		}
+		if _, err := c.Reload(ctx); err != nil && ctx.Err() == nil {
+			log.Warn("failed to reload the schema cache", "error", err)
+		}
+	}
+}
+
+// userSchemas leaves out PostgreSQL's own schemas.
+const userSchemas = `n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'`
+
+// Querier runs queries; *pgx.Conn and pgx.Tx satisfy it.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Load reads the tables and foreign keys of every schema but PostgreSQL's
+// own.
+func Load(ctx context.Context, conn Querier) (*Schema, error) {
+	schema := &Schema{LoadedAt: time.Now(), Tables: []Table{}, ForeignKeys: []ForeignKey{}}
+
+	rows, err := conn.Query(ctx, `
+		SELECT
+			n.nspname,
+			c.relname,
+			CASE WHEN c.relkind IN ('v', 'm') THEN 'view' ELSE 'table' END,
+			coalesce(obj_description(c.oid, 'pg_class'), ''),
+			c.relrowsecurity,
+			coalesce(json_agg(json_build_object(
+				'name', a.attname,
+				'type', format_type(a.atttypid, a.atttypmod),
+				'udt_name', t.typname,
+				'nullable', NOT a.attnotnull,
+				'has_default', a.atthasdef OR a.attidentity <> '' OR a.attgenerated <> '',
+				'comment', coalesce(col_description(c.oid, a.attnum), '')
+			) ORDER BY a.attnum) FILTER (WHERE a.attnum IS NOT NULL), '[]'),
+			ARRAY(
+				SELECT pa.attname
+				FROM pg_index i, unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute pa ON pa.attrelid = c.oid AND pa.attnum = k.attnum
+				WHERE i.indrelid = c.oid AND i.indisprimary
+				ORDER BY k.ord
+			)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		LEFT JOIN pg_type t ON t.oid = a.atttypid
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f') AND `+userSchemas+`
+		GROUP BY n.nspname, c.oid
+		ORDER BY n.nspname, c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t Table
+		var columns []byte
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Kind, &t.Comment, &t.RLSEnabled, &columns, &t.PrimaryKey); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if err := json.Unmarshal(columns, &t.Columns); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schema.Tables = append(schema.Tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT
+			c.conname,
+			n.nspname,
+			t.relname,
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			),
+			fn.nspname,
+			ft.relname,
+			ARRAY(
+				SELECT a.attname
+				FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			)
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_class ft ON ft.oid = c.confrelid
+		JOIN pg_namespace fn ON fn.oid = ft.relnamespace
+		WHERE c.contype = 'f' AND `+userSchemas+`
+		ORDER BY n.nspname, t.relname, c.conname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fk ForeignKey
+		if err := rows.Scan(&fk.Name, &fk.Schema, &fk.Table, &fk.Columns, &fk.RefSchema, &fk.RefTable, &fk.RefColumns); err != nil {
+			return nil, err
+		}
+		schema.ForeignKeys = append(schema.ForeignKeys, fk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	schema.index()
+	return schema, nil
+}
+
+// index fills in the lookup maps.
+func (s *Schema) index() {
+	s.tables = make(map[string]*Table, len(s.Tables))
+	for i := range s.Tables {
+		t := &s.Tables[i]
+		if t.PrimaryKey == nil {
+			t.PrimaryKey = []string{}
+		}
+		s.tables[t.Schema+"."+t.Name] = t
+	}
+}
@@ -0,0 +1,167 @@
+package schemacache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/pg"
+)
+
+func testSchema() *Schema {
+	s := &Schema{
+		Tables: []Table{
+			{Schema: "public", Name: "posts", Kind: "table", PrimaryKey: []string{"id"}},
+			{Schema: "public", Name: "post_tags", Kind: "table", PrimaryKey: []string{"post_id", "tag_id"}},
+			{Schema: "public", Name: "tags", Kind: "table", PrimaryKey: []string{"id"}},
+			{Schema: "other", Name: "post_tags", Kind: "table"},
+		},
+		ForeignKeys: []ForeignKey{
+			{Name: "post_tags_post_id_fkey", Schema: "public", Table: "post_tags", Columns: []string{"post_id"}, RefSchema: "public", RefTable: "posts", RefColumns: []string{"id"}},
+			{Name: "post_tags_tag_id_fkey", Schema: "public", Table: "post_tags", Columns: []string{"tag_id"}, RefSchema: "public", RefTable: "tags", RefColumns: []string{"id"}},
+			{Name: "post_tags_post_id_fkey", Schema: "other", Table: "post_tags", Columns: []string{"post_id"}, RefSchema: "public", RefTable: "posts", RefColumns: []string{"id"}},
+		},
+	}
+	s.index()
+	return s
+}
+
+func TestSchema_Table(t *testing.T) {
+	s := testSchema()
+	if tbl := s.Table("public", "post_tags"); tbl == nil || !reflect.DeepEqual(tbl.PrimaryKey, []string{"post_id", "tag_id"}) {
+		t.Errorf("Table(public, post_tags) = %+v", tbl)
+	}
+	if tbl := s.Table("other", "post_tags"); tbl == nil || tbl.PrimaryKey == nil {
+		t.Errorf("Table(other, post_tags) = %+v, want an empty primary key", tbl)
+	}
+	if tbl := s.Table("public", "nope"); tbl != nil {
+		t.Errorf("Table(public, nope) = %+v, want nil", tbl)
+	}
+}
+
+func TestSchema_ForeignKeysBetween(t *testing.T) {
+	s := testSchema()
+	keys := s.ForeignKeysBetween("public", "post_tags", "posts")
+	if len(keys) != 1 || keys[0].Schema != "public" {
+		t.Errorf("ForeignKeysBetween(public, post_tags, posts) = %+v", keys)
+	}
+	if keys := s.ForeignKeysBetween("public", "posts", "post_tags"); len(keys) != 0 {
+		t.Errorf("ForeignKeysBetween(public, posts, post_tags) = %+v, want none", keys)
+	}
+	// A foreign key into another schema doesn't count
+	if keys := s.ForeignKeysBetween("other", "post_tags", "posts"); len(keys) != 0 {
+		t.Errorf("ForeignKeysBetween(other, post_tags, posts) = %+v, want none", keys)
+	}
+}
+
+func TestSchema_Junctions(t *testing.T) {
+	s := testSchema()
+	junctions := s.Junctions("public", "posts", "tags")
+	if len(junctions) != 1 {
+		t.Fatalf("Junctions(public, posts, tags) = %+v, want one", junctions)
+	}
+	j := junctions[0]
+	if j.Table != "post_tags" || j.From.Columns[0] != "post_id" || j.To.Columns[0] != "tag_id" {
+		t.Errorf("Junctions(public, posts, tags) = %+v", j)
+	}
+	if junctions := s.Junctions("public", "posts", "posts"); len(junctions) != 0 {
+		t.Errorf("Junctions(public, posts, posts) = %+v, want none", junctions)
+	}
+}
+
+func TestCache(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15439,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-schemacache",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE public.orders (
+			region text,
+			number int,
+			note varchar(80) NOT NULL DEFAULT '',
+			PRIMARY KEY (number, region)
+		);
+		CREATE TABLE public.shipments (
+			id serial PRIMARY KEY,
+			order_region text,
+			order_number int,
+			FOREIGN KEY (order_number, order_region) REFERENCES public.orders (number, region)
+		);
+		COMMENT ON TABLE public.orders IS 'Customer orders';
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	cache := New(db)
+	if err := cache.Start(ctx); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer cache.Stop()
+
+	schema, err := cache.Schema(ctx)
+	if err != nil {
+		t.Fatalf("Schema() failed: %v", err)
+	}
+	orders := schema.Table("public", "orders")
+	if orders == nil {
+		t.Fatal("orders not loaded")
+	}
+	if orders.Comment != "Customer orders" || !reflect.DeepEqual(orders.PrimaryKey, []string{"number", "region"}) {
+		t.Errorf("orders = %+v", orders)
+	}
+	if note := orders.Column("note"); note == nil || note.Type != "character varying(80)" || note.TypeName != "varchar" || note.Nullable || !note.HasDefault {
+		t.Errorf("orders.note = %+v", note)
+	}
+	keys := schema.ForeignKeysBetween("public", "shipments", "orders")
+	if len(keys) != 1 || !reflect.DeepEqual(keys[0].Columns, []string{"order_number", "order_region"}) || !reflect.DeepEqual(keys[0].RefColumns, []string{"number", "region"}) {
+		t.Errorf("shipments foreign keys = %+v", keys)
+	}
+
+	// DDL reloads the cache
+	if _, err := conn.Exec(ctx, `CREATE TABLE public.returns (id int)`); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		schema, _ := cache.Schema(ctx)
+		if schema.Table("public", "returns") != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the cache didn't reload after CREATE TABLE")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !cache.Listening() {
+		t.Error("Listening() = false")
+	}
+
+	// Refresh doesn't reload a schema loaded within maxAge
+	loaded, _ := cache.Schema(ctx)
+	if refreshed, err := cache.Refresh(ctx, time.Hour); err != nil || refreshed != loaded {
+		t.Errorf("Refresh(1h) reloaded: %v", err)
+	}
+	if refreshed, err := cache.Refresh(ctx, 0); err != nil || refreshed == loaded {
+		t.Errorf("Refresh(0) didn't reload: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+// Package schemacheck checks at startup that a data directory can be used
+// by this supalite, and that the database has the schemas and tables
+// supalite relies on.
+//
+// Checks run in three places. Before PostgreSQL starts, CheckCluster
+// refuses a cluster initialized by another PostgreSQL major version. Once
+// it is running, CheckVersion refuses a database stamped by a newer
+// supalite, whose tables this one may not understand, and Upgrade brings
+// an older one up to date. After the components have created their
+// tables, Verify reports what is still missing. Stamp then records
+// Version in admin.supalite_schema.
+package schemacheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Version is the version of supalite's own schema. Bump it, and add an
+// upgrade step, when a release changes supalite's tables in a way their
+// CREATE ... IF NOT EXISTS statements can't.
+const Version = 1
+
+// upgrades bring a database from the version before their index + 1 up
+// to that version: upgrades[0] from 0 (an unstamped database, from before
+// versions were recorded) to 1.
+var upgrades = []string{
+	// 1: nothing to change; the stamp table is created by Stamp
+	``,
+}
+
+const stampSQL = `
+CREATE SCHEMA IF NOT EXISTS admin;
+
+CREATE TABLE IF NOT EXISTS admin.supalite_schema (
+	id boolean PRIMARY KEY DEFAULT true CHECK (id),
+	version integer NOT NULL,
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+`
+
+// IncompatibleError reports a data directory this supalite can't use.
+type IncompatibleError struct {
+	Problems []string
+	Hint     string // What to do about it
+}
+
+func (e *IncompatibleError) Error() string {
+	var b strings.Builder
+	b.WriteString("the data directory is not compatible with this supalite:")
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	if e.Hint != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Hint)
+	}
+	return b.String()
+}
+
+// CheckCluster checks that the PostgreSQL cluster in clusterDir, if it has
+// been initialized, was initialized by PostgreSQL version (e.g. "16.9.0").
+// PostgreSQL can't open a cluster of another major version.
+func CheckCluster(clusterDir, version string) error {
+	data, err := os.ReadFile(filepath.Join(clusterDir, "PG_VERSION"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the cluster's PostgreSQL version: %w", err)
+	}
+
+	have := strings.TrimSpace(string(data))
+	want, _, _ := strings.Cut(version, ".")
+	if have == want {
+		return nil
+	}
+	hint := fmt.Sprintf("Set pg_version to %s to keep running PostgreSQL %s.", have, have)
+	if h, err := strconv.Atoi(have); err == nil {
+		if w, err := strconv.Atoi(want); err == nil && h < w {
+			hint = fmt.Sprintf("Upgrade it with supalite db upgrade --to %s, or set pg_version to %s to keep running PostgreSQL %s.", version, have, have)
+		}
+	}
+	return &IncompatibleError{
+		Problems: []string{fmt.Sprintf("%s holds a PostgreSQL %s cluster, and this supalite runs PostgreSQL %s", clusterDir, have, want)},
+		Hint:     hint,
+	}
+}
+
+// CheckVersion returns the schema version the database was stamped with,
+// 0 if it never was, and refuses a database from a newer supalite.
+func CheckVersion(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass('admin.supalite_schema') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	err := conn.QueryRow(ctx, "SELECT version FROM admin.supalite_schema").Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+	if version > Version {
+		return version, &IncompatibleError{
+			Problems: []string{fmt.Sprintf("its schema is version %d, written by a newer supalite; this one knows versions up to %d", version, Version)},
+			Hint:     "Start it with the newer supalite, or restore a backup taken before the upgrade.",
+		}
+	}
+	return version, nil
+}
+
+// Upgrade brings supalite's schema from version from up to Version, one
+// step at a time, each in its own transaction.
+func Upgrade(ctx context.Context, conn *pgx.Conn, from int) error {
+	for v := from; v < Version; v++ {
+		if upgrades[v] == "" {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, upgrades[v])
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upgrade the schema to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// Stamp records that the database has Version's schema.
+func Stamp(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, stampSQL); err != nil {
+		return fmt.Errorf("failed to create admin.supalite_schema: %w", err)
+	}
+	_, err := conn.Exec(ctx, `
+		INSERT INTO admin.supalite_schema (version) VALUES ($1)
+		ON CONFLICT (id) DO UPDATE SET version = excluded.version, updated_at = now()`, Version)
+	if err != nil {
+		return fmt.Errorf("failed to record the schema version: %w", err)
+	}
+	return nil
+}
+
+// Requirement is a table supalite needs.
+type Requirement struct {
+	Table     string   // schema.table
+	Columns   []string // Optional: columns it must have
+	Component string   // What needs it, for the report
+	NotEmpty  bool     // It must have rows, e.g. a migrations table
+	Optional  bool     // Missing is a warning: the component runs without it
+}
+
+// Problem is a requirement that isn't met.
+type Problem struct {
+	Requirement
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Component, p.Message, p.Table)
+}
+
+// Verify checks the requirements and returns those that aren't met.
+func Verify(ctx context.Context, conn *pgx.Conn, reqs []Requirement) ([]Problem, error) {
+	var problems []Problem
+	for _, req := range reqs {
+		message, err := check(ctx, conn, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", req.Table, err)
+		}
+		if message != "" {
+			problems = append(problems, Problem{Requirement: req, Message: message})
+		}
+	}
+	return problems, nil
+}
+
+// check returns what is wrong with a requirement, or "" if it is met.
+func check(ctx context.Context, conn *pgx.Conn, req Requirement) (string, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", req.Table).Scan(&exists); err != nil {
+		return "", err
+	}
+	if !exists {
+		return "table is missing", nil
+	}
+
+	if len(req.Columns) > 0 {
+		schema, table, _ := strings.Cut(req.Table, ".")
+		rows, err := conn.Query(ctx, `
+			SELECT c FROM unnest($3::text[]) AS c
+			WHERE NOT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = $1 AND table_name = $2 AND column_name = c
+			)`, schema, table, req.Columns)
+		if err != nil {
+			return "", err
+		}
+		missing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return "", err
+		}
+		if len(missing) > 0 {
+			return "missing column " + strings.Join(missing, ", "), nil
+		}
+	}
+
+	if req.NotEmpty {
+		var empty bool
+		if err := conn.QueryRow(ctx, "SELECT NOT EXISTS (SELECT 1 FROM "+quoteTable(req.Table)+")").Scan(&empty); err != nil {
+			return "", err
+		}
+		if empty {
+			return "table is empty", nil
+		}
+	}
+	return "", nil
+}
+
+// quoteTable quotes a schema.table name.
+func quoteTable(name string) string {
+	schema, table, _ := strings.Cut(name, ".")
+	return pgx.Identifier{schema, table}.Sanitize()
+}
+
+// Report returns an error listing the problems with required tables, or
+// nil when only optional ones are missing.
+func Report(problems []Problem) error {
+	var required []string
+	for _, p := range problems {
+		if !p.Optional {
+			required = append(required, p.String())
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+	return &IncompatibleError{
+		Problems: required,
+		Hint:     "supalite creates these tables at startup, so something prevented it: check the log above, and that the database user owns the schemas. If the data directory was copied from elsewhere, restore a backup into a new one instead (supalite db restore).",
+	}
+}
@@ -0,0 +1,66 @@
+package schemacheck
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCluster(t *testing.T) {
+	dir := t.TempDir()
+
+	// Not initialized yet
+	if err := CheckCluster(dir, "16.9.0"); err != nil {
+		t.Errorf("CheckCluster(empty) = %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckCluster(dir, "16.9.0"); err != nil {
+		t.Errorf("CheckCluster(16) = %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "PG_VERSION"), []byte("15\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckCluster(dir, "16.9.0")
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) || !strings.Contains(err.Error(), "PostgreSQL 15 cluster") {
+		t.Errorf("CheckCluster(15) = %v, want an incompatible cluster", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "supalite db upgrade --to 16.9.0") {
+		t.Errorf("CheckCluster(15) = %v, want a hint to upgrade", err)
+	}
+
+	// A newer cluster can't be upgraded to an older version
+	if err := os.WriteFile(filepath.Join(dir, "PG_VERSION"), []byte("17\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckCluster(dir, "16.9.0"); err == nil || strings.Contains(err.Error(), "upgrade") {
+		t.Errorf("CheckCluster(17) = %v, want an incompatible cluster without an upgrade hint", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	problems := []Problem{
+		{Requirement: Requirement{Table: "auth.users", Component: "auth", Optional: true}, Message: "table is missing"},
+	}
+	if err := Report(problems); err != nil {
+		t.Errorf("Report(optional) = %v", err)
+	}
+
+	problems = append(problems, Problem{
+		Requirement: Requirement{Table: "storage.objects", Component: "storage"},
+		Message:     "missing column metadata",
+	})
+	err := Report(problems)
+	if err == nil {
+		t.Fatal("Report() = nil with a required table missing")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "storage: missing column metadata (storage.objects)") || strings.Contains(msg, "auth.users") {
+		t.Errorf("Report() = %q", msg)
+	}
+}
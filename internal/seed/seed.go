@@ -0,0 +1,135 @@
+// Package seed loads demo data from SQL files, so a fresh database can be
+// filled the same way every time.
+//
+// Seed files are seed.sql and the .sql files in seeds/, in name order,
+// relative to the project directory. A Supabase CLI project's
+// supabase/seed.sql is used when neither exists. All files run in one
+// transaction: a failing statement leaves nothing behind, and the error
+// names the file and line it is on.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// File and Dir are the seed files' names, relative to the project
+// directory.
+const (
+	File = "seed.sql"
+	Dir  = "seeds"
+)
+
+// supabaseFile is where a Supabase CLI project keeps its seed data.
+var supabaseFile = filepath.Join("supabase", "seed.sql")
+
+// Error is a seed file that failed, with the line of the failing
+// statement when the database reported its position.
+type Error struct {
+	File string
+	Line int // 0 when unknown
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var pgErr *pgconn.PgError
+	msg := e.Err.Error()
+	if errors.As(e.Err, &pgErr) {
+		msg = fmt.Sprintf("%s (SQLSTATE %s)", pgErr.Message, pgErr.Code)
+		if pgErr.Detail != "" {
+			msg += "\n  detail: " + pgErr.Detail
+		}
+		if pgErr.Hint != "" {
+			msg += "\n  hint: " + pgErr.Hint
+		}
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, msg)
+	}
+	return fmt.Sprintf("%s: %s", e.File, msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Find returns the seed files in the project directory dir, in the order
+// they run. A project without any has none.
+func Find(dir string) ([]string, error) {
+	var files []string
+	if path := filepath.Join(dir, File); fileExists(path) {
+		files = append(files, path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, Dir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, filepath.Join(dir, Dir, name))
+	}
+
+	if len(files) == 0 {
+		if path := filepath.Join(dir, supabaseFile); fileExists(path) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// Run runs the files in order in one transaction, and stops at the first
+// that fails with an *Error, rolling back all of them.
+func Run(ctx context.Context, conn *pgx.Conn, files []string) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for _, file := range files {
+			sql, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return &Error{File: file, Line: errorLine(string(sql), err), Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// errorLine returns the line of sql that err's position points at, or 0.
+// Postgres counts the position in characters, from 1.
+func errorLine(sql string, err error) int {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Position <= 0 {
+		return 0
+	}
+	line, pos := 1, int32(1)
+	for _, r := range sql {
+		if pos == pgErr.Position {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		pos++
+	}
+	return line
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,77 @@
+package seed
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	if files, err := Find(dir); err != nil || files != nil {
+		t.Fatalf("Find(empty) = %v, %v", files, err)
+	}
+
+	// A Supabase CLI project's seed file is the fallback
+	os.MkdirAll(filepath.Join(dir, "supabase"), 0755)
+	os.WriteFile(filepath.Join(dir, "supabase", "seed.sql"), nil, 0644)
+	if files, _ := Find(dir); !reflect.DeepEqual(files, []string{filepath.Join(dir, "supabase", "seed.sql")}) {
+		t.Errorf("Find(supabase) = %v", files)
+	}
+
+	os.WriteFile(filepath.Join(dir, "seed.sql"), nil, 0644)
+	os.MkdirAll(filepath.Join(dir, "seeds", "old"), 0755)
+	for _, name := range []string{"02_posts.sql", "01_users.sql", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, "seeds", name), nil, 0644)
+	}
+
+	files, err := Find(dir)
+	if err != nil {
+		t.Fatalf("Find() failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "seed.sql"),
+		filepath.Join(dir, "seeds", "01_users.sql"),
+		filepath.Join(dir, "seeds", "02_posts.sql"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("Find() = %v, want %v", files, want)
+	}
+}
+
+func TestErrorLine(t *testing.T) {
+	sql := "INSERT INTO a VALUES (1);\n-- ünïcödé\nINSERT INTO b VALUES (2);\n"
+	pos := int32(len([]rune(sql[:strings.Index(sql, "INSERT INTO b")]))) + 1
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&pgconn.PgError{Position: 1}, 1},
+		{&pgconn.PgError{Position: pos}, 3},
+		{&pgconn.PgError{}, 0},
+		{errors.New("connection reset"), 0},
+	}
+	for _, tt := range tests {
+		if got := errorLine(sql, tt.err); got != tt.want {
+			t.Errorf("errorLine(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{File: "seeds/01_users.sql", Line: 3, Err: &pgconn.PgError{
+		Message: `relation "b" does not exist`,
+		Code:    "42P01",
+		Hint:    "Run the migrations first.",
+	}}
+	want := "seeds/01_users.sql:3: relation \"b\" does not exist (SQLSTATE 42P01)\n  hint: Run the migrations first."
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
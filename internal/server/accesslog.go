@@ -0,0 +1,140 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/auditlog"
+	"github.com/markb/supalite/internal/log"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// accessLogger logs one line per request, under its own component so its
+// level can be set apart from the server's, e.g. --log-level info,access=warn.
+var accessLogger = log.Component("access")
+
+// logRequests logs each request once it has been served: its method,
+// path, status, latency, and the role and user of the key or token it
+// carried.
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		args := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client", clientAddr(r),
+		}
+		if query := redactedQuery(r.URL); query != "" {
+			args = append(args, "query", query)
+		}
+		if claims := s.tokenClaims(r); claims != nil {
+			args = append(args, "role", rls.RoleForClaims(claims))
+			if sub, _ := claims["sub"].(string); sub != "" {
+				args = append(args, "user", sub)
+			}
+		}
+		accessLogger.Info("request", args...)
+	})
+}
+
+// auditWrites records each successful write to a table under prefix, a
+// REST mount such as "/rest/v1", in the audit log. It runs after
+// requireAPIKey, which puts the caller's claims in the context. Function
+// calls under /rpc are not recorded.
+func (s *Server) auditWrites(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			table := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+			if !isWriteMethod(r.Method) || table == "" || strings.Contains(table, "/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= 300 || recorder.IsDryRun(r.Context()) {
+				return
+			}
+
+			// The schema was validated by the handler, or it would have failed
+			schema := r.Header.Get("Content-Profile")
+			if schema == "" {
+				schema = defaultSchema
+			}
+			claims := requestClaims(r)
+			sub, _ := claims["sub"].(string)
+			s.auditLog.Record(auditlog.Entry{
+				Time:       time.Now(),
+				Method:     r.Method,
+				Schema:     schema,
+				Table:      table,
+				Query:      redactedQuery(r.URL),
+				Status:     status,
+				Role:       rls.RoleForClaims(claims),
+				UserID:     sub,
+				ClientAddr: clientAddr(r),
+			})
+		})
+	}
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// redactedQuery returns a request's query string without the apikey
+// parameter, which may hold a secret key. The rest is kept as sent.
+func redactedQuery(u *url.URL) string {
+	if !strings.Contains(u.RawQuery, "apikey") {
+		return u.RawQuery
+	}
+	params := strings.Split(u.RawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		if name, _, _ := strings.Cut(param, "="); name != "apikey" {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// tokenClaims returns the verified claims of the key or token a request
+// carries, resolving opaque API keys, or nil when it carries none or it
+// doesn't verify. It is for middleware running before requireAPIKey.
+func (s *Server) tokenClaims(r *http.Request) map[string]interface{} {
+	token := requestToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("apikey")
+	}
+	if token == "" {
+		return nil
+	}
+	if resolved, ok := s.resolveAPIKey(token); ok {
+		token = resolved
+	}
+	claims, err := s.verifyUserToken(token)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
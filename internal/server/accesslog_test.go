@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/log"
+)
+
+func TestLogRequests(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager}
+
+	var buf bytes.Buffer
+	log.SetWriter(&buf)
+	if err := log.SetFormat(log.FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		log.SetWriter(os.Stderr)
+		log.SetFormat(log.FormatText)
+	}()
+
+	handler := s.logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/rest/v1/todos?apikey="+url.QueryEscape(keyManager.GetPublishableKey()), nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, buf.String())
+	}
+	if entry["component"] != "access" || entry["method"] != "POST" || entry["path"] != "/rest/v1/todos" || entry["status"] != float64(201) {
+		t.Errorf("entry = %v", entry)
+	}
+	if entry["role"] != "anon" {
+		t.Errorf("role = %v, want the publishable key's", entry["role"])
+	}
+	if _, ok := entry["query"]; ok {
+		t.Errorf("query = %v, want the apikey left out", entry["query"])
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("duration_ms = %v", entry["duration_ms"])
+	}
+}
+
+func TestRedactedQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"id=eq.5":                   "id=eq.5",
+		"apikey=secret&id=eq.5":     "id=eq.5",
+		"select=*&apikey=sb_secret": "select=*",
+	}
+	for query, want := range tests {
+		if got := redactedQuery(&url.URL{RawQuery: query}); got != want {
+			t.Errorf("redactedQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/markb/supalite/internal/keys"
+)
+
+// resolveAPIKeys swaps the publishable and secret keys (sb_publishable_...,
+// sb_secret_...) for the anon and service_role JWTs they stand for, as the
+// Supabase API gateway does. It covers the apikey header, the apikey query
+// parameter, and the Authorization bearer token, so everything behind it,
+// including GoTrue, only ever sees JWTs. Unknown sb_ keys are left alone
+// and fail verification like any other invalid key.
+func (s *Server) resolveAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resolved bool
+
+		header := r.Header.Clone()
+		if token, ok := s.resolveAPIKey(strings.TrimSpace(header.Get("apikey"))); ok {
+			header.Set("apikey", token)
+			resolved = true
+		}
+		if authHeader := header.Get("Authorization"); authHeader != "" {
+			const bearerPrefix = "Bearer "
+			if len(authHeader) > len(bearerPrefix) && strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
+				if token, ok := s.resolveAPIKey(strings.TrimSpace(authHeader[len(bearerPrefix):])); ok {
+					header.Set("Authorization", bearerPrefix+token)
+					resolved = true
+				}
+			}
+		}
+
+		query := r.URL.Query()
+		queryResolved := false
+		if token, ok := s.resolveAPIKey(query.Get("apikey")); ok {
+			query.Set("apikey", token)
+			queryResolved = true
+		}
+
+		if !resolved && !queryResolved {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		r.Header = header
+		if queryResolved {
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolveAPIKey returns the JWT for an opaque API key.
+func (s *Server) resolveAPIKey(key string) (string, bool) {
+	if !keys.IsOpaqueKey(key) {
+		return "", false
+	}
+	return s.keyManager.ResolveAPIKey(key)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markb/supalite/internal/keys"
+)
+
+func TestResolveAPIKeys(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		bearer   string
+		query    string
+		wantCode int
+		wantRole string
+	}{
+		{"publishable key", keyManager.GetPublishableKey(), "", "", http.StatusOK, "anon"},
+		{"secret key", keyManager.GetSecretKey(), "", "", http.StatusOK, "service_role"},
+		{"secret key as bearer", keyManager.GetPublishableKey(), keyManager.GetSecretKey(), "", http.StatusOK, "service_role"},
+		{"publishable key query parameter", "", "", "?apikey=" + keyManager.GetPublishableKey(), http.StatusOK, "anon"},
+		{"unknown secret key", keys.SecretKeyPrefix + "leaked", "", "", http.StatusUnauthorized, ""},
+		{"anon JWT still accepted", keyManager.GetAnonKey(), "", "", http.StatusOK, "anon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRole interface{}
+			handler := s.resolveAPIKeys(s.requireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRole = requestClaims(r)["role"]
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos"+tt.query, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("apikey", tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantRole != "" && gotRole != tt.wantRole {
+				t.Errorf("role = %v, want %s", gotRole, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestResolveAPIKeys_AuthAdmin(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager}
+
+	var gotAuth string
+	handler := s.resolveAPIKeys(s.requireAuthAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/v1/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer "+keyManager.GetSecretKey())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body.String())
+	}
+	if gotAuth != "Bearer "+keyManager.GetServiceKey() {
+		t.Errorf("GoTrue got Authorization %q, want the service_role JWT", gotAuth)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// REST API versions. v1 keeps the behavior existing clients rely on. v2
+// carries the compatibility fixes that change responses: every error is a
+// PostgREST-style JSON object, and database errors answer with the status
+// PostgREST uses for them (409 for constraint conflicts, 404 for unknown
+// relations, 403 for missing privileges) instead of a blanket 400.
+const (
+	RESTv1 = 1
+	RESTv2 = 2
+)
+
+// RESTConfig selects the REST API version served at /rest and the
+// retirement date announced on v1 responses, and how request names map to
+// tables and columns.
+type RESTConfig struct {
+	DefaultVersion int            // Version served at the unversioned /rest prefix (default: 1)
+	V1Sunset       time.Time      // Announced in the Sunset header of v1 responses when set
+	IdentifierCase IdentifierCase // Mapping of table and column names (default: exact)
+
+	// Text and bytea values longer than this many characters or bytes are
+	// returned as truncation markers holding a prefix (0: no limit)
+	MaxFieldSize int
+	// Limits of single columns, keyed "table.column", overriding MaxFieldSize
+	FieldSizeLimits map[string]int
+
+	// Reads may ask for a table's rows at a past time with ?as_of, rebuilt
+	// from its change history (see asOfSource)
+	TimeTravel bool
+
+	// Cache-Control header of table reads, keyed by table name, with "*"
+	// for the tables not listed (default: none)
+	CacheControl map[string]string
+}
+
+// restVersionContextKey is the request context key holding the REST API
+// version and the path prefix it was reached at.
+type restVersionContextKey struct{}
+
+type restVersion struct {
+	version int
+	prefix  string
+}
+
+// requestRESTPath returns the part of a REST request's path after its
+// version prefix, e.g. "/todos" for /rest/v2/todos.
+func requestRESTPath(r *http.Request) string {
+	v, ok := r.Context().Value(restVersionContextKey{}).(restVersion)
+	if !ok {
+		return strings.TrimPrefix(r.URL.Path, "/rest/v1")
+	}
+	return strings.TrimPrefix(r.URL.Path, v.prefix)
+}
+
+// withRESTVersion serves the routes below it as the given API version,
+// mounted at prefix. v1 responses carry Deprecation and Link headers
+// pointing at v2, plus a Sunset header when a retirement date is set. v2
+// responses go through a v2ResponseWriter.
+func (s *Server) withRESTVersion(version int, prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(context.WithValue(r.Context(), restVersionContextKey{}, restVersion{version, prefix}))
+			w.Header().Set("Supalite-API-Version", fmt.Sprint(version))
+
+			if version == RESTv1 {
+				w.Header().Set("Deprecation", "true")
+				w.Header().Set("Link", `</rest/v2>; rel="successor-version"`)
+				if !s.config.REST.V1Sunset.IsZero() {
+					w.Header().Set("Sunset", s.config.REST.V1Sunset.UTC().Format(http.TimeFormat))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			vw := &v2ResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(vw, r)
+			vw.finish()
+		})
+	}
+}
+
+// defaultRESTVersion is the version served at /rest.
+func (s *Server) defaultRESTVersion() int {
+	if s.config.REST.DefaultVersion == RESTv2 {
+		return RESTv2
+	}
+	return RESTv1
+}
+
+// v2ResponseWriter rewrites the plain-text errors the REST handlers write
+// with http.Error into PostgREST-style JSON errors.
+type v2ResponseWriter struct {
+	http.ResponseWriter
+	status  int // a held-back error status, written by Write or finish
+	message bytes.Buffer
+}
+
+func (vw *v2ResponseWriter) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
+
+func (vw *v2ResponseWriter) WriteHeader(status int) {
+	if status >= http.StatusBadRequest && strings.HasPrefix(vw.Header().Get("Content-Type"), "text/plain") {
+		vw.status = status
+		return
+	}
+	vw.ResponseWriter.WriteHeader(status)
+}
+
+func (vw *v2ResponseWriter) Write(p []byte) (int, error) {
+	if vw.status != 0 {
+		return vw.message.Write(p)
+	}
+	return vw.ResponseWriter.Write(p)
+}
+
+// finish writes a held-back error as {code, message, details, hint}.
+func (vw *v2ResponseWriter) finish() {
+	if vw.status == 0 {
+		return
+	}
+	writeRESTError(vw.ResponseWriter, vw.status, nil, strings.TrimSpace(vw.message.String()), nil, nil)
+	vw.status = 0
+}
+
+// isRESTv2 reports whether w belongs to a v2 request, looking through the
+// writers wrapped around it.
+func isRESTv2(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *v2ResponseWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// writeRESTError writes a PostgREST-style JSON error. Empty details and
+// hints are sent as null.
+func writeRESTError(w http.ResponseWriter, status int, code interface{}, message string, details, hint interface{}) {
+	if s, ok := details.(string); ok && s == "" {
+		details = nil
+	}
+	if s, ok := hint.(string); ok && s == "" {
+		hint = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"details": details,
+		"hint":    hint,
+	})
+}
+
+// writeDBErrorV2 reports a failed database call the way PostgREST does:
+// the Postgres error's SQLSTATE, message, detail, and hint, with the
+// status PostgREST maps the SQLSTATE to. Errors that don't come from
+// Postgres keep the given status.
+func writeDBErrorV2(w http.ResponseWriter, what string, err error, status int) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		writeRESTError(w, status, nil, fmt.Sprintf("%s: %v", what, err), nil, nil)
+		return
+	}
+	writeRESTError(w, pgErrorStatus(pgErr.Code, status), pgErr.Code, pgErr.Message, pgErr.Detail, pgErr.Hint)
+}
+
+// pgErrorStatus maps a SQLSTATE to the HTTP status PostgREST answers with,
+// falling back to status for codes it has no mapping for.
+func pgErrorStatus(code string, status int) int {
+	switch code {
+	case "23503", // foreign_key_violation
+		"23505": // unique_violation
+		return http.StatusConflict
+	case "42P01", // undefined_table
+		"42883": // undefined_function
+		return http.StatusNotFound
+	case "42501": // insufficient_privilege
+		return http.StatusForbidden
+	case "25006": // read_only_sql_transaction
+		return http.StatusMethodNotAllowed
+	case "P0001": // raise_exception
+		return http.StatusBadRequest
+	}
+	switch {
+	case strings.HasPrefix(code, "08"): // connection_exception
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(code, "22"), // data_exception
+		strings.HasPrefix(code, "23"): // integrity_constraint_violation
+		return http.StatusBadRequest
+	case strings.HasPrefix(code, "53"): // insufficient_resources
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(code, "XX"): // internal_error
+		return http.StatusInternalServerError
+	}
+	return status
+}
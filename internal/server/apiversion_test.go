@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// serveVersioned runs handler as a REST request of the given version.
+func serveVersioned(s *Server, version int, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/rest/v2/todos", nil)
+	s.withRESTVersion(version, "/rest/v2")(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeRESTError(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON error %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+func TestRESTv1Headers(t *testing.T) {
+	s := &Server{config: Config{REST: RESTConfig{V1Sunset: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+	rec := serveVersioned(s, RESTv1, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing filter", http.StatusBadRequest)
+	})
+
+	if rec.Header().Get("Deprecation") != "true" {
+		t.Errorf("Deprecation = %q, want true", rec.Header().Get("Deprecation"))
+	}
+	if got, want := rec.Header().Get("Sunset"), "Fri, 01 Jan 2027 00:00:00 GMT"; got != want {
+		t.Errorf("Sunset = %q, want %q", got, want)
+	}
+	if rec.Body.String() != "missing filter\n" {
+		t.Errorf("v1 body = %q, want the plain-text error", rec.Body.String())
+	}
+
+	rec = serveVersioned(&Server{}, RESTv1, func(w http.ResponseWriter, r *http.Request) {})
+	if rec.Header().Get("Sunset") != "" {
+		t.Error("Sunset should be omitted without a retirement date")
+	}
+}
+
+func TestRESTv2PlainErrors(t *testing.T) {
+	rec := serveVersioned(&Server{}, RESTv2, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing filter", http.StatusBadRequest)
+	})
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if rec.Header().Get("Deprecation") != "" {
+		t.Error("v2 responses should not be marked deprecated")
+	}
+	body := decodeRESTError(t, rec)
+	if body["message"] != "missing filter" || body["code"] != nil || body["hint"] != nil {
+		t.Errorf("error = %v", body)
+	}
+}
+
+func TestRESTv2DBErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   interface{}
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505", Message: "duplicate key", Detail: "Key (id)=(1) already exists."}, http.StatusConflict, "23505"},
+		{"unknown table", &pgconn.PgError{Code: "42P01", Message: "relation does not exist"}, http.StatusNotFound, "42P01"},
+		{"permission denied", &pgconn.PgError{Code: "42501", Message: "permission denied"}, http.StatusForbidden, "42501"},
+		{"serialization failure", &pgconn.PgError{Code: "40001", Message: "could not serialize"}, http.StatusServiceUnavailable, "40001"},
+		{"not from postgres", errors.New("boom"), http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveVersioned(&Server{}, RESTv2, func(w http.ResponseWriter, r *http.Request) {
+				// Errors reach the client through the transaction's buffer
+				txw := newTxResponseWriter(w)
+				writeDBError(txw, "insert error", tt.err, http.StatusBadRequest)
+				txw.send()
+			})
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := decodeRESTError(t, rec); body["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %v", body["code"], tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRESTv2UniqueViolationDetails(t *testing.T) {
+	rec := serveVersioned(&Server{}, RESTv2, func(w http.ResponseWriter, r *http.Request) {
+		writeDBError(w, "insert error", &pgconn.PgError{Code: "23505", Message: "duplicate key", Detail: "Key (id)=(1) already exists."}, http.StatusBadRequest)
+	})
+	body := decodeRESTError(t, rec)
+	if body["message"] != "duplicate key" || body["details"] != "Key (id)=(1) already exists." || body["hint"] != nil {
+		t.Errorf("error = %v", body)
+	}
+}
+
+func TestRequestRESTPath(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   string
+	}{
+		{"/rest/v1", "/rest/v1/todos", "/todos"},
+		{"/rest/v2", "/rest/v2/rpc/add", "/rpc/add"},
+		{"/rest", "/rest/todos", "/todos"},
+	}
+	for _, tt := range tests {
+		var got string
+		req := httptest.NewRequest("GET", tt.path, nil)
+		(&Server{}).withRESTVersion(RESTv1, tt.prefix)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = requestRESTPath(r)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("requestRESTPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
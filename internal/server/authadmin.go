@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// authAdminRoles are the JWT roles allowed to call the auth admin API,
+// GoTrue's default GOTRUE_JWT_ADMIN_ROLES.
+var authAdminRoles = map[string]bool{
+	"service_role":   true,
+	"supabase_admin": true,
+}
+
+// isAuthAdminPath reports whether an /auth/v1 request path reaches GoTrue's
+// admin API. The path is cleaned first, so /auth/v1/./admin/users and
+// /auth/v1//admin/users are caught too.
+func isAuthAdminPath(p string) bool {
+	p = path.Clean("/" + strings.TrimPrefix(p, "/auth/v1"))
+	return p == "/admin" || strings.HasPrefix(p, "/admin/")
+}
+
+// writeGoTrueError writes an error in GoTrue's JSON format, so supabase-js
+// reports it like one GoTrue returned.
+func writeGoTrueError(w http.ResponseWriter, status int, errorCode, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":       status,
+		"error_code": errorCode,
+		"msg":        msg,
+	})
+}
+
+// requireAuthAdmin guards GoTrue's admin API (/auth/v1/admin/*), as the
+// Supabase API gateway does: the bearer token must be a valid JWT whose
+// role is service_role. Requests without a token get 401, and requests
+// with any other role, such as the anon key or a user session, get 403.
+// Other auth requests pass through untouched.
+func (s *Server) requireAuthAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAuthAdminPath(r.URL.Path) || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		const bearerPrefix = "Bearer "
+		if len(authHeader) <= len(bearerPrefix) || !strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
+			writeGoTrueError(w, http.StatusUnauthorized, "no_authorization", "This endpoint requires a Bearer token")
+			return
+		}
+
+		claims, err := s.verifyUserToken(strings.TrimSpace(authHeader[len(bearerPrefix):]))
+		if err != nil {
+			writeGoTrueError(w, http.StatusUnauthorized, "bad_jwt", jwtErrorMessage(err))
+			return
+		}
+
+		role, _ := claims["role"].(string)
+		if !authAdminRoles[role] {
+			logger.Debug("auth admin request rejected", "role", role, "path", r.URL.Path)
+			writeGoTrueError(w, http.StatusForbidden, "not_admin", "User not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
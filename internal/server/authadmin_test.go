@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/keys"
+)
+
+func TestIsAuthAdminPath(t *testing.T) {
+	tests := map[string]bool{
+		"/auth/v1/admin/users":      true,
+		"/auth/v1/admin":            true,
+		"/auth/v1//admin/users":     true,
+		"/auth/v1/./admin/users":    true,
+		"/auth/v1/x/../admin/users": true,
+		"/auth/v1/user":             false,
+		"/auth/v1/administrator":    false,
+		"/auth/v1/token":            false,
+	}
+	for p, want := range tests {
+		if got := isAuthAdminPath(p); got != want {
+			t.Errorf("isAuthAdminPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestRequireAuthAdmin(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager}
+
+	userToken, err := keyManager.GenerateUserToken(map[string]interface{}{"role": "authenticated"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		bearer        string
+		wantCode      int
+		wantErrorCode string
+	}{
+		{"service key", "/auth/v1/admin/users", keyManager.GetServiceKey(), http.StatusOK, ""},
+		{"anon key", "/auth/v1/admin/users", keyManager.GetAnonKey(), http.StatusForbidden, "not_admin"},
+		{"user session", "/auth/v1/admin/users", userToken, http.StatusForbidden, "not_admin"},
+		{"no token", "/auth/v1/admin/users", "", http.StatusUnauthorized, "no_authorization"},
+		{"garbage token", "/auth/v1/admin/users", "not-a-jwt", http.StatusUnauthorized, "bad_jwt"},
+		{"dot segment", "/auth/v1/./admin/users", keyManager.GetAnonKey(), http.StatusForbidden, "not_admin"},
+		{"non-admin endpoint", "/auth/v1/settings", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := s.requireAuthAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantErrorCode != "" {
+				var body struct {
+					ErrorCode string `json:"error_code"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &body)
+				if body.ErrorCode != tt.wantErrorCode {
+					t.Errorf("error_code = %q, want %q", body.ErrorCode, tt.wantErrorCode)
+				}
+			}
+		})
+	}
+}
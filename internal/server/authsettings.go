@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// authSettingsTTL is how long a /auth/v1/settings response is reused.
+// supabase-js fetches it whenever a client is created, and it only
+// changes when supalite restarts.
+const authSettingsTTL = time.Minute
+
+// authSettingsCache holds the last enriched /auth/v1/settings response.
+type authSettingsCache struct {
+	mu      sync.Mutex
+	body    []byte
+	expires time.Time
+}
+
+// supaliteSettings is added to /auth/v1/settings under "supalite", so
+// clients can tell how a local instance behaves.
+type supaliteSettings struct {
+	AuthMode    string `json:"auth_mode"`    // "gotrue", "native", or "custom"
+	ExternalURL string `json:"external_url"` // Address auth links point at
+	MailCapture bool   `json:"mail_capture"` // Emails are captured, not delivered
+	SMSCapture  bool   `json:"sms_capture"`  // SMS are captured, not delivered
+}
+
+// handleAuthSettings serves GET /auth/v1/settings from the cache, fetching
+// it from the auth backend when it is missing or stale. Errors from the
+// backend are passed through and not cached.
+func (s *Server) handleAuthSettings(w http.ResponseWriter, r *http.Request, backend http.Handler) {
+	now := time.Now()
+	s.authSettings.mu.Lock()
+	body := s.authSettings.body
+	if now.After(s.authSettings.expires) {
+		body = nil
+	}
+	s.authSettings.mu.Unlock()
+
+	if body == nil {
+		rec := &settingsRecorder{header: make(http.Header)}
+		backend.ServeHTTP(rec, r)
+		if rec.status != http.StatusOK {
+			for name, values := range rec.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+
+		enriched, err := s.enrichAuthSettings(rec.body.Bytes())
+		if err != nil {
+			http.Error(w, "invalid settings response from auth server", http.StatusBadGateway)
+			return
+		}
+		body = enriched
+
+		s.authSettings.mu.Lock()
+		s.authSettings.body = body
+		s.authSettings.expires = now.Add(authSettingsTTL)
+		s.authSettings.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// enrichAuthSettings adds the supalite section to a settings response.
+func (s *Server) enrichAuthSettings(body []byte) ([]byte, error) {
+	var settings map[string]interface{}
+	if err := json.Unmarshal(body, &settings); err != nil {
+		return nil, err
+	}
+
+	authMode := s.config.AuthMode
+	switch {
+	case s.config.AuthProvider != nil:
+		authMode = AuthModeCustom
+	case authMode == "":
+		authMode = AuthModeGoTrue
+	}
+	externalURL := s.config.PublicURL
+	if externalURL == "" {
+		externalURL = s.config.SiteURL
+	}
+	settings["supalite"] = supaliteSettings{
+		AuthMode:    authMode,
+		ExternalURL: externalURL,
+		MailCapture: s.captureServer != nil && s.captureServer.IsRunning(),
+		SMSCapture:  s.config.SMS != nil && s.config.SMS.CaptureMode,
+	}
+	return json.Marshal(settings)
+}
+
+// settingsRecorder buffers the auth backend's settings response, or
+// another handler's response to be inspected before it is passed on.
+type settingsRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (sr *settingsRecorder) Header() http.Header {
+	return sr.header
+}
+
+func (sr *settingsRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+}
+
+func (sr *settingsRecorder) Write(p []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.body.Write(p)
+}
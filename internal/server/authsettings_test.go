@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markb/supalite/internal/auth"
+)
+
+func TestHandleAuthSettings(t *testing.T) {
+	s := &Server{config: Config{
+		SiteURL:   "http://localhost:3000",
+		PublicURL: "https://demo.example.com",
+		SMS:       &auth.SMSConfig{CaptureMode: true},
+	}}
+
+	calls := 0
+	status := http.StatusServiceUnavailable
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		w.Write([]byte(`{"external":{"email":true},"mailer_autoconfirm":false}`))
+	})
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleAuthSettings(rec, httptest.NewRequest(http.MethodGet, "/settings", nil), backend)
+		return rec
+	}
+
+	// Errors pass through and are not cached
+	if rec := get(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+
+	status = http.StatusOK
+	first := get()
+	second := get()
+	if calls != 2 {
+		t.Errorf("backend called %d times, want 2 (one error, then one cached success)", calls)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Error("cached response differs from the first one")
+	}
+
+	var settings struct {
+		External map[string]bool  `json:"external"`
+		Supalite supaliteSettings `json:"supalite"`
+	}
+	if err := json.Unmarshal(second.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("invalid settings JSON: %v", err)
+	}
+	if !settings.External["email"] {
+		t.Error("GoTrue's settings were not kept")
+	}
+	want := supaliteSettings{AuthMode: AuthModeGoTrue, ExternalURL: "https://demo.example.com", SMSCapture: true}
+	if settings.Supalite != want {
+		t.Errorf("supalite = %+v, want %+v", settings.Supalite, want)
+	}
+}
+
+// stubAuth is an auth.Provider answering every request with its path.
+type stubAuth struct {
+	auth.Provider
+}
+
+func (stubAuth) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	})
+}
+
+func TestHandleAuthRequest_CustomProvider(t *testing.T) {
+	provider := stubAuth{}
+	s := &Server{config: Config{AuthProvider: provider}, authProvider: provider}
+
+	rec := httptest.NewRecorder()
+	s.handleAuthRequest(rec, httptest.NewRequest(http.MethodGet, "/auth/v1/user", nil))
+	if body := rec.Body.String(); body != `{"path":"/user"}` {
+		t.Errorf("body = %s, want the provider's response for /user", body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAuthRequest(rec, httptest.NewRequest(http.MethodGet, "/auth/v1/settings", nil))
+	var settings struct {
+		Supalite supaliteSettings `json:"supalite"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("invalid settings JSON: %v", err)
+	}
+	if settings.Supalite.AuthMode != AuthModeCustom {
+		t.Errorf("auth_mode = %q, want %q", settings.Supalite.AuthMode, AuthModeCustom)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// limitedWriteFilter narrows the WHERE clause of a PATCH or DELETE to the
+// rows picked by the order, limit, and offset parameters, as supabase-js
+// .update().order('id').limit(10) sends. The matching rows are selected in
+// a subquery and targeted by primary key, or by ctid for tables without
+// one. Without limit or offset, whereClause is returned as it is.
+func limitedWriteFilter(ctx context.Context, tx pgx.Tx, table, whereClause string, query url.Values, names IdentifierCase) (string, error) {
+	window, err := paramWindow(query)
+	if err != nil {
+		return "", err
+	}
+	if window.limit < 0 && window.offset == 0 {
+		return whereClause, nil
+	}
+
+	pkColumns, err := primaryKeyColumns(ctx, tx, table)
+	if err != nil {
+		return "", fmt.Errorf("primary key lookup error: %w", err)
+	}
+	keyColumns := "ctid"
+	if len(pkColumns) > 0 {
+		quoted := make([]string, len(pkColumns))
+		for i, col := range pkColumns {
+			quoted[i] = quoteIdentifier(col)
+		}
+		keyColumns = strings.Join(quoted, ", ")
+	}
+
+	subquery := fmt.Sprintf("SELECT %s FROM public.%s WHERE %s", keyColumns, quoteIdentifier(table), whereClause)
+	if orderVals := query["order"]; len(orderVals) > 0 {
+		orderClause, err := buildOrderClause(orderVals[0], names)
+		if err != nil {
+			return "", fmt.Errorf("invalid order: %w", err)
+		}
+		subquery += " ORDER BY " + orderClause
+	}
+	if window.limit >= 0 {
+		subquery += fmt.Sprintf(" LIMIT %d", window.limit)
+	}
+	if window.offset > 0 {
+		subquery += fmt.Sprintf(" OFFSET %d", window.offset)
+	}
+	return fmt.Sprintf("(%s) IN (%s)", keyColumns, subquery), nil
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestLimitedWriteFilter_NoLimit(t *testing.T) {
+	query, _ := url.ParseQuery("status=eq.done&order=id")
+	// Without limit or offset the table isn't looked at, so no transaction is needed
+	got, err := limitedWriteFilter(context.Background(), nil, "todos", `"status" = $1`, query, IdentifierCaseExact)
+	if err != nil {
+		t.Fatalf("limitedWriteFilter() error = %v", err)
+	}
+	if got != `"status" = $1` {
+		t.Errorf("limitedWriteFilter() = %q, want the filter unchanged", got)
+	}
+}
+
+func TestLimitedWriteFilter_InvalidLimit(t *testing.T) {
+	query, _ := url.ParseQuery("status=eq.done&limit=-5")
+	if _, err := limitedWriteFilter(context.Background(), nil, "todos", `"status" = $1`, query, IdentifierCaseExact); err == nil {
+		t.Error("limitedWriteFilter() should reject a negative limit")
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markb/supalite/internal/chaos"
+)
+
+// ChaosConfig enables fault injection (see package chaos). Never enable it
+// outside of tests.
+type ChaosConfig struct {
+	Seed   int64        // Seeds the failures drawn, so runs are reproducible
+	Faults chaos.Faults // Faults active from startup
+}
+
+// chaosStatus is the response of /admin/v1/chaos.
+type chaosStatus struct {
+	Faults chaos.Faults `json:"faults"`
+	Stats  chaos.Stats  `json:"stats"`
+}
+
+// Chaos returns the fault injector, or nil when chaos is disabled. Tests
+// running a server in process use it to change faults between requests.
+func (s *Server) Chaos() *chaos.Injector {
+	return s.chaos
+}
+
+// initChaos creates the fault injector when chaos is enabled.
+func (s *Server) initChaos() error {
+	if s.config.Chaos == nil {
+		return nil
+	}
+	injector := chaos.New(s.config.Chaos.Seed)
+	if err := injector.Set(s.config.Chaos.Faults); err != nil {
+		return err
+	}
+	s.chaos = injector
+	logger.Warn("chaos mode: fault injection is enabled, do not use this instance for real traffic",
+		"seed", s.config.Chaos.Seed,
+		"db_fail_percent", s.config.Chaos.Faults.DBFailPercent,
+		"auth_delay_ms", s.config.Chaos.Faults.AuthDelayMS)
+	return nil
+}
+
+// dbFault returns the database failure to inject into a REST request, or
+// nil. It is always nil when chaos is disabled.
+func (s *Server) dbFault() error {
+	return s.chaos.DBFault()
+}
+
+// handleChaos reports and changes the injected faults.
+//
+// GET    /admin/v1/chaos - active faults and injection counters
+// PUT    /admin/v1/chaos - replace the faults: {"db_fail_percent": 25, "auth_delay_ms": 500}
+// DELETE /admin/v1/chaos - clear the faults and counters, and reseed
+func (s *Server) handleChaos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var faults chaos.Faults
+		if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := s.chaos.Set(faults); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Warn("chaos faults changed", "db_fail_percent", faults.DBFailPercent, "auth_delay_ms", faults.AuthDelayMS)
+	case http.MethodDelete:
+		s.chaos.Reset()
+		logger.Info("chaos faults cleared")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chaosStatus{Faults: s.chaos.Faults(), Stats: s.chaos.Stats()})
+}
+
+// handleChaosKillGoTrue kills the GoTrue process, so tests can check that
+// it is restarted and that /health reports it unavailable meanwhile.
+//
+// POST /admin/v1/chaos/kill-gotrue
+func (s *Server) handleChaosKillGoTrue(w http.ResponseWriter, r *http.Request) {
+	if s.authServer == nil {
+		http.Error(w, "GoTrue is not in use (native auth mode)", http.StatusConflict)
+		return
+	}
+	if err := s.authServer.Kill(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.chaos.RecordKill()
+	logger.Warn("chaos: killed GoTrue")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/markb/supalite/internal/chaos"
+	"github.com/markb/supalite/internal/keys"
+)
+
+func TestChaosDBFaultIsTransient(t *testing.T) {
+	s := &Server{config: Config{Chaos: &ChaosConfig{Seed: 1, Faults: chaos.Faults{DBFailPercent: 100}}}}
+	if err := s.initChaos(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+	_, err := s.acquireRequestConn(req.Context(), req)
+	if !isTransient(err) {
+		t.Fatalf("injected failure %v should be transient", err)
+	}
+
+	rec := httptest.NewRecorder()
+	writeDBError(rec, "database connection error", err, http.StatusInternalServerError)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("writeDBError() = %d with Retry-After %q, want 503 with a hint", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestHandleChaos(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager, config: Config{Chaos: &ChaosConfig{Seed: 1}}}
+	if err := s.initChaos(); err != nil {
+		t.Fatal(err)
+	}
+	handler := s.requireServiceRole(s.handleChaos)
+
+	do := func(method, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/v1/chaos", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "", keyManager.GetAnonKey()); rec.Code != http.StatusForbidden {
+		t.Errorf("anon key: status = %d, want 403", rec.Code)
+	}
+
+	rec := do(http.MethodPut, `{"db_fail_percent": 30, "auth_delay_ms": 200}`, keyManager.GetServiceKey())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var status chaosStatus
+	json.NewDecoder(rec.Body).Decode(&status)
+	if status.Faults != (chaos.Faults{DBFailPercent: 30, AuthDelayMS: 200}) || s.Chaos().Faults() != status.Faults {
+		t.Errorf("faults after PUT = %+v", status.Faults)
+	}
+
+	if rec := do(http.MethodPut, `{"db_fail_percent": 150}`, keyManager.GetServiceKey()); rec.Code != http.StatusBadRequest {
+		t.Errorf("out of range PUT: status = %d, want 400", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "", keyManager.GetServiceKey()); rec.Code != http.StatusOK || s.Chaos().Faults() != (chaos.Faults{}) {
+		t.Errorf("DELETE: status = %d, faults %+v, want 200 and none", rec.Code, s.Chaos().Faults())
+	}
+}
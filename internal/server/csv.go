@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// csvMediaType is the media type of CSV request and response bodies.
+const csvMediaType = "text/csv"
+
+// csvNull is how a CSV request body spells NULL, as on PostgREST. An empty
+// field is an empty string.
+const csvNull = "NULL"
+
+// wantsCSV reports whether the request's Accept header asks for CSV.
+func wantsCSV(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.TrimSpace(mediaType) == csvMediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCSVBody reports whether the request body is CSV.
+func isCSVBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == csvMediaType
+}
+
+// handleCSVGet runs a GET query and writes the rows as CSV, with a header
+// row of column names. Rows are encoded straight from pgx, without building
+// the maps the JSON response uses.
+func (s *Server) handleCSVGet(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, sqlQuery, quotedTable, whereClause string, whereArgs []interface{}, window rowWindow) {
+	rows, err := tx.Query(ctx, sqlQuery, whereArgs...)
+	if err != nil {
+		writeDBError(w, "query error", err, http.StatusBadRequest)
+		return
+	}
+	defer rows.Close()
+
+	var body bytes.Buffer
+	returned, err := writeCSVRows(&body, rows)
+	if err != nil {
+		writeDBError(w, "row scan error", err, http.StatusInternalServerError)
+		return
+	}
+
+	status, ok := s.setContentRange(ctx, tx, w, r, quotedTable, whereClause, whereArgs, window.offset, returned)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", csvMediaType+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// writeCSVRows writes a header row and then each row as CSV, returning the
+// number of data rows. NULLs are written as empty fields.
+func writeCSVRows(w io.Writer, rows pgx.Rows) (int, error) {
+	cw := csv.NewWriter(w)
+
+	desc := rows.FieldDescriptions()
+	header := make([]string, len(desc))
+	for i, col := range desc {
+		header[i] = col.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	record := make([]string, len(desc))
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return count, err
+		}
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// csvValue formats a column value for CSV output.
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case [16]byte:
+		return locationValue(v)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseCSVRecords reads a CSV request body into records for an insert. The
+// first line names the columns. Values are passed to Postgres as text, so
+// they are parsed as their column's type; NULL stands for a null value.
+func parseCSVRecords(body io.Reader) ([]map[string]interface{}, error) {
+	reader := csv.NewReader(body)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(header))
+		for i, col := range header {
+			if fields[i] == csvNull {
+				record[col] = nil
+			} else {
+				record[col] = fields[i]
+			}
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVRecords(t *testing.T) {
+	body := "name,age,bio\nAda,36,\"Mathematician, writer\"\nAlan,NULL,\n"
+	records, err := parseCSVRecords(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parseCSVRecords() error = %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{"name": "Ada", "age": "36", "bio": "Mathematician, writer"},
+		{"name": "Alan", "age": nil, "bio": ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("parseCSVRecords() = %v, want %v", records, want)
+	}
+}
+
+func TestParseCSVRecords_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"ragged row":   "a,b\n1,2,3\n",
+		"broken quote": "a,b\n\"1,2\n",
+	} {
+		if _, err := parseCSVRecords(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: parseCSVRecords() should fail", name)
+		}
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, ""},
+		{"text", "text"},
+		{int64(42), "42"},
+		{true, "true"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+		{[16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}, "123e4567-e89b-12d3-a456-426614174000"},
+		{map[string]interface{}{"a": 1}, `{"a":1}`},
+		{[]interface{}{"x", "y"}, `["x","y"]`},
+	}
+	for _, tt := range tests {
+		if got := csvValue(tt.in); got != tt.want {
+			t.Errorf("csvValue(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCSVNegotiation(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+	if wantsCSV(r) {
+		t.Error("wantsCSV() should be false without an Accept header")
+	}
+	r.Header.Set("Accept", "application/json;q=0.5, text/csv")
+	if !wantsCSV(r) {
+		t.Error("wantsCSV() should be true for Accept: text/csv")
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/rest/v1/todos", nil)
+	r.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	if !isCSVBody(r) {
+		t.Error("isCSVBody() should be true for Content-Type: text/csv")
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if isCSVBody(r) {
+		t.Error("isCSVBody() should be false for JSON")
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// database is the PostgreSQL the APIs are served from: the embedded one
+// (pg.EmbeddedDatabase), or an external server given by DatabaseURL
+// (pg.ExternalDatabase).
+type database interface {
+	Start(ctx context.Context) error
+	Stop()
+	ConnectionString() string
+	Connect(ctx context.Context) (*pgx.Conn, error)
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+	AcquireAs(ctx context.Context, role string) (*pgxpool.Conn, error)
+	Pool() *pgxpool.Pool
+}
+
+// withSearchPath returns a postgres:// URL with search_path set, keeping
+// the parameters it already has, such as sslmode.
+func withSearchPath(connString, searchPath string) (string, error) {
+	u, err := url.Parse(connString)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("search_path", searchPath)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/markb/supalite/internal/schemacache"
+)
+
+// docsHiddenTables are supalite's own tables in the public schema, left
+// out of the docs.
+var docsHiddenTables = map[string]bool{
+	"captured_emails": true,
+}
+
+// docsPage is the content of /docs.
+type docsPage struct {
+	URL     string      `json:"url"`
+	AnonKey string      `json:"anon_key"`
+	Tables  []docsTable `json:"tables"`
+}
+
+// docsTable is a table or view in the public schema.
+type docsTable struct {
+	Name       string        `json:"name"`
+	Kind       string        `json:"kind"` // "table" or "view"
+	Comment    string        `json:"comment,omitempty"`
+	RLSEnabled bool          `json:"rls_enabled"`
+	Columns    []docsColumn  `json:"columns"`
+	TryURL     string        `json:"try_url"` // Opens the first rows in a browser
+	Examples   []docsExample `json:"examples"`
+}
+
+type docsColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable"`
+	HasDefault bool   `json:"has_default"` // Default, identity, or generated
+	PrimaryKey bool   `json:"primary_key"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// docsExample is one operation on a table, as curl and supabase-js.
+type docsExample struct {
+	Title string `json:"title"`
+	Curl  string `json:"curl"`
+	JS    string `json:"js"`
+}
+
+// handleDocs serves documentation generated from the live schema: each
+// table and view in the public schema, with example requests using this
+// instance's URL and anon key.
+//
+// GET /docs
+//
+// Browsers get an HTML page; ?format=json or an Accept header asking for
+// JSON gets the same content as JSON.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	schema, err := s.schemaCache.Schema(r.Context())
+	if err != nil {
+		logger.Error("docs: failed to read schema", "error", err)
+		http.Error(w, "failed to read schema", http.StatusInternalServerError)
+		return
+	}
+
+	page := docsPage{URL: s.docsBaseURL(r), AnonKey: s.keyManager.GetAnonKey(), Tables: docsTables(schema)}
+	for i := range page.Tables {
+		t := &page.Tables[i]
+		t.TryURL = page.URL + "/rest/v1/" + url.PathEscape(t.Name) + "?select=*&limit=10&apikey=" + url.QueryEscape(page.AnonKey)
+		t.Examples = docsExamples(page.URL, page.AnonKey, *t)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := docsTemplate.Execute(w, page); err != nil {
+		logger.Error("docs: failed to render page", "error", err)
+	}
+}
+
+// docsBaseURL returns the URL clients reach this instance at: the public
+// URL when one is set, or else the one this request came in on.
+func (s *Server) docsBaseURL(r *http.Request) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimSuffix(s.config.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// docsTables lists the tables and views in the public schema with their
+// columns.
+func docsTables(schema *schemacache.Schema) []docsTable {
+	tables := []docsTable{}
+	for _, t := range schema.Tables {
+		if t.Schema != defaultSchema || docsHiddenTables[t.Name] {
+			continue
+		}
+		dt := docsTable{Name: t.Name, Kind: t.Kind, Comment: t.Comment, RLSEnabled: t.RLSEnabled, Columns: []docsColumn{}}
+		for _, c := range t.Columns {
+			dt.Columns = append(dt.Columns, docsColumn{
+				Name:       c.Name,
+				Type:       c.Type,
+				Nullable:   c.Nullable,
+				HasDefault: c.HasDefault,
+				PrimaryKey: slices.Contains(t.PrimaryKey, c.Name),
+				Comment:    c.Comment,
+			})
+		}
+		tables = append(tables, dt)
+	}
+	return tables
+}
+
+// docsExamples returns example requests for a table: reading it, and for
+// tables, inserting, updating, and deleting a row.
+func docsExamples(baseURL, anonKey string, t docsTable) []docsExample {
+	endpoint := baseURL + "/rest/v1/" + url.PathEscape(t.Name)
+	headers := fmt.Sprintf(" \\\n  -H \"apikey: %s\" \\\n  -H \"Authorization: Bearer %s\"", anonKey, anonKey)
+	from := fmt.Sprintf("const { data, error } = await supabase\n  .from('%s')", t.Name)
+
+	examples := []docsExample{{
+		Title: "Read rows",
+		Curl:  fmt.Sprintf("curl '%s?select=*&limit=10'%s", endpoint, headers),
+		JS:    from + "\n  .select('*')\n  .limit(10)",
+	}}
+	if t.Kind != "table" || len(t.Columns) == 0 {
+		return examples
+	}
+
+	// Rows are picked by primary key, or the first column without one
+	key := t.Columns[0]
+	for _, c := range t.Columns {
+		if c.PrimaryKey {
+			key = c
+			break
+		}
+	}
+	keyValue := docsSampleValue(key.Type)
+	keyFilter := url.QueryEscape(key.Name) + "=eq." + url.QueryEscape(docsFilterValue(keyValue))
+	keyJS := fmt.Sprintf(".eq('%s', %s)", key.Name, docsJSON(keyValue))
+
+	// Inserts set the columns without a default; updates change the first
+	// column that isn't part of the key
+	var insert, update []docsColumn
+	for _, c := range t.Columns {
+		if !c.HasDefault {
+			insert = append(insert, c)
+		}
+		if !c.PrimaryKey && !c.HasDefault && update == nil {
+			update = []docsColumn{c}
+		}
+	}
+	if update == nil {
+		update = insert
+	}
+
+	body := docsBody(insert)
+	examples = append(examples, docsExample{
+		Title: "Insert a row",
+		Curl:  fmt.Sprintf("curl -X POST '%s'%s \\\n  -H \"Content-Type: application/json\" \\\n  -H \"Prefer: return=representation\" \\\n  -d '%s'", endpoint, headers, body),
+		JS:    fmt.Sprintf("%s\n  .insert(%s)\n  .select()", from, body),
+	})
+	if len(update) > 0 {
+		body := docsBody(update)
+		examples = append(examples, docsExample{
+			Title: "Update a row",
+			Curl:  fmt.Sprintf("curl -X PATCH '%s?%s'%s \\\n  -H \"Content-Type: application/json\" \\\n  -H \"Prefer: return=representation\" \\\n  -d '%s'", endpoint, keyFilter, headers, body),
+			JS:    fmt.Sprintf("%s\n  .update(%s)\n  %s\n  .select()", from, body, keyJS),
+		})
+	}
+	examples = append(examples, docsExample{
+		Title: "Delete a row",
+		Curl:  fmt.Sprintf("curl -X DELETE '%s?%s'%s", endpoint, keyFilter, headers),
+		JS:    fmt.Sprintf("%s\n  .delete()\n  %s", from, keyJS),
+	})
+	return examples
+}
+
+// docsBody returns a JSON object with a sample value for each column, in
+// column order.
+func docsBody(columns []docsColumn) string {
+	fields := make([]string, len(columns))
+	for i, c := range columns {
+		fields[i] = docsJSON(c.Name) + ": " + docsJSON(docsSampleValue(c.Type))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// docsSampleValue returns an example value of a Postgres type, as
+// format_type names it.
+func docsSampleValue(pgType string) any {
+	switch {
+	case strings.HasSuffix(pgType, "[]"):
+		return []any{}
+	case pgType == "smallint" || pgType == "integer" || pgType == "bigint":
+		return 1
+	case pgType == "real" || pgType == "double precision" || strings.HasPrefix(pgType, "numeric"):
+		return 1.5
+	case pgType == "boolean":
+		return true
+	case pgType == "uuid":
+		return "00000000-0000-4000-8000-000000000000"
+	case strings.HasPrefix(pgType, "timestamp"):
+		return "2026-01-01T00:00:00Z"
+	case pgType == "date":
+		return "2026-01-01"
+	case pgType == "json" || pgType == "jsonb":
+		return map[string]any{}
+	default:
+		return "example"
+	}
+}
+
+// docsFilterValue formats a sample value for an eq. filter.
+func docsFilterValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return docsJSON(value)
+}
+
+func docsJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>API Docs</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 56rem; margin: 2rem auto; padding: 0 1rem; color: #1f2937; }
+pre { background: #f3f4f6; padding: .75rem; border-radius: .375rem; overflow-x: auto; font-size: .8125rem; }
+table { border-collapse: collapse; margin: .5rem 0; }
+td, th { text-align: left; padding: .25rem .75rem .25rem 0; border-bottom: 1px solid #e5e7eb; font-size: .875rem; }
+h2 { margin-top: 2.5rem; border-top: 1px solid #e5e7eb; padding-top: 1.5rem; }
+nav a { margin-right: .75rem; }
+.muted { color: #6b7280; font-size: .875rem; }
+.tag { font-size: .75rem; background: #e0e7ff; border-radius: .25rem; padding: .1rem .4rem; margin-left: .5rem; }
+</style>
+</head>
+<body>
+<h1>API Docs</h1>
+<p class="muted">Generated from the live schema of the <code>public</code> schema. Examples use the anon key, so rows are subject to row level security.</p>
+
+<h3>Connect</h3>
+<pre>import { createClient } from '@supabase/supabase-js'
+
+const supabase = createClient('{{.URL}}', '{{.AnonKey}}')</pre>
+
+{{if .Tables}}<nav>{{range .Tables}}<a href="#{{.Name}}">{{.Name}}</a>{{end}}</nav>{{else}}<p>No tables in the public schema yet.</p>{{end}}
+
+{{range .Tables}}
+<h2 id="{{.Name}}">{{.Name}}{{if eq .Kind "view"}}<span class="tag">view</span>{{end}}{{if not .RLSEnabled}}<span class="tag">RLS disabled</span>{{end}}</h2>
+{{if .Comment}}<p>{{.Comment}}</p>{{end}}
+<table>
+<tr><th>Column</th><th>Type</th><th></th><th></th></tr>
+{{range .Columns}}<tr><td><code>{{.Name}}</code></td><td>{{.Type}}</td><td class="muted">{{if .PrimaryKey}}primary key{{else if not .Nullable}}required{{end}}{{if .HasDefault}} has default{{end}}</td><td class="muted">{{.Comment}}</td></tr>
+{{end}}</table>
+<p><a href="{{.TryURL}}">Try it: read the first 10 rows</a></p>
+{{range .Examples}}<h4>{{.Title}}</h4>
+<pre>{{.Curl}}</pre>
+<pre>{{.JS}}</pre>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/markb/supalite/internal/schemacache"
+)
+
+func TestDocsExamples(t *testing.T) {
+	table := docsTable{Name: "todos", Kind: "table", Columns: []docsColumn{
+		{Name: "id", Type: "bigint", HasDefault: true, PrimaryKey: true},
+		{Name: "title", Type: "text"},
+		{Name: "done", Type: "boolean"},
+		{Name: "created_at", Type: "timestamp with time zone", HasDefault: true},
+	}}
+	examples := docsExamples("http://localhost:8080", "anon-key", table)
+	if len(examples) != 4 {
+		t.Fatalf("got %d examples, want read, insert, update, and delete", len(examples))
+	}
+
+	read, insert, update, del := examples[0], examples[1], examples[2], examples[3]
+	if !strings.Contains(read.Curl, "'http://localhost:8080/rest/v1/todos?select=*&limit=10'") || !strings.Contains(read.Curl, `-H "apikey: anon-key"`) {
+		t.Errorf("read curl = %s", read.Curl)
+	}
+	if !strings.Contains(read.JS, ".from('todos')") {
+		t.Errorf("read js = %s", read.JS)
+	}
+	if !strings.Contains(insert.Curl, `-d '{"title": "example", "done": true}'`) || !strings.Contains(insert.JS, `.insert({"title": "example", "done": true})`) {
+		t.Errorf("insert = %+v", insert)
+	}
+	if !strings.Contains(update.Curl, "-X PATCH 'http://localhost:8080/rest/v1/todos?id=eq.1'") || !strings.Contains(update.JS, `.update({"title": "example"})`) || !strings.Contains(update.JS, ".eq('id', 1)") {
+		t.Errorf("update = %+v", update)
+	}
+	if !strings.Contains(del.Curl, "-X DELETE 'http://localhost:8080/rest/v1/todos?id=eq.1'") {
+		t.Errorf("delete curl = %s", del.Curl)
+	}
+
+	// Views are only read
+	view := docsTable{Name: "open_todos", Kind: "view", Columns: table.Columns}
+	if examples := docsExamples("http://localhost:8080", "anon-key", view); len(examples) != 1 {
+		t.Errorf("view got %d examples, want 1", len(examples))
+	}
+}
+
+func TestDocsTables(t *testing.T) {
+	schema := &schemacache.Schema{Tables: []schemacache.Table{
+		{Schema: "auth", Name: "users", Kind: "table"},
+		{Schema: "public", Name: "captured_emails", Kind: "table"},
+		{Schema: "public", Name: "todos", Kind: "table", PrimaryKey: []string{"id"}, Columns: []schemacache.Column{
+			{Name: "id", Type: "bigint", HasDefault: true},
+			{Name: "title", Type: "text", Nullable: true},
+		}},
+	}}
+	tables := docsTables(schema)
+	if len(tables) != 1 || tables[0].Name != "todos" {
+		t.Fatalf("docsTables() = %+v, want todos only", tables)
+	}
+	if cols := tables[0].Columns; !cols[0].PrimaryKey || cols[1].PrimaryKey || !cols[1].Nullable {
+		t.Errorf("todos columns = %+v", cols)
+	}
+}
+
+func TestDocsSampleValue(t *testing.T) {
+	tests := map[string]string{
+		"integer":                     "1",
+		"numeric(10,2)":               "1.5",
+		"boolean":                     "true",
+		"uuid":                        `"00000000-0000-4000-8000-000000000000"`,
+		"timestamp without time zone": `"2026-01-01T00:00:00Z"`,
+		"jsonb":                       "{}",
+		"text[]":                      "[]",
+		"character varying(40)":       `"example"`,
+	}
+	for pgType, want := range tests {
+		if got := docsJSON(docsSampleValue(pgType)); got != want {
+			t.Errorf("docsSampleValue(%q) = %s, want %s", pgType, got, want)
+		}
+	}
+}
+
+func TestDocsBaseURL(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	r.Host = "192.168.1.20:8080"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got := (&Server{}).docsBaseURL(r); got != "https://192.168.1.20:8080" {
+		t.Errorf("docsBaseURL() = %q", got)
+	}
+
+	s := &Server{config: Config{PublicURL: "https://demo.example.com/"}}
+	if got := s.docsBaseURL(r); got != "https://demo.example.com" {
+		t.Errorf("docsBaseURL() with a public URL = %q", got)
+	}
+}
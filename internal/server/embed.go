@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// embedWindow orders and paginates the array of an embedded resource, set
+// with ?comments.order=created_at.desc&comments.limit=3&comments.offset=3.
+// Ordering can also be given in the main order parameter, as
+// ?order=comments(created_at).desc.
+type embedWindow struct {
+	order  []string // Quoted ORDER BY items, e.g. `"created_at" DESC`
+	limit  int      // -1 for no limit
+	offset int
+}
+
+// sql returns the ORDER BY, LIMIT, and OFFSET clauses for the embedded
+// query. qualifier prefixes the order columns, for queries with joins.
+func (ew embedWindow) sql(qualifier string) string {
+	var clause string
+	if len(ew.order) > 0 {
+		items := make([]string, len(ew.order))
+		for i, item := range ew.order {
+			items[i] = qualifier + item
+		}
+		clause += " ORDER BY " + strings.Join(items, ", ")
+	}
+	if ew.limit >= 0 {
+		clause += fmt.Sprintf(" LIMIT %d", ew.limit)
+	}
+	if ew.offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", ew.offset)
+	}
+	return clause
+}
+
+// isEmbedWindowParam reports whether a dotted query parameter such as
+// comments.limit sets an embed's window rather than filtering it.
+func isEmbedWindowParam(key string) bool {
+	param := key[strings.LastIndex(key, ".")+1:]
+	return param == "order" || param == "limit" || param == "offset"
+}
+
+// splitOrderParam separates the items of an order parameter that order an
+// embedded resource, alias(column).dir, from the ones that order the main
+// table. The main table's items are returned joined as they were given.
+func splitOrderParam(order string) (string, map[string][]string, error) {
+	items, err := splitTopLevel(order)
+	if err != nil {
+		return "", nil, err
+	}
+	var main []string
+	embedded := make(map[string][]string)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		open := strings.Index(item, "(")
+		close := strings.Index(item, ")")
+		if open > 0 && close > open {
+			alias := item[:open]
+			embedded[alias] = append(embedded[alias], item[open+1:close]+item[close+1:])
+			continue
+		}
+		main = append(main, item)
+	}
+	return strings.Join(main, ","), embedded, nil
+}
+
+// embedWindows parses the window of each embedded resource from the query.
+// embeddedOrder holds the items taken from the main order parameter.
+func embedWindows(query url.Values, embedded []embeddedResource, embeddedOrder map[string][]string, names IdentifierCase) (map[string]embedWindow, error) {
+	windows := make(map[string]embedWindow, len(embedded))
+	for _, emb := range embedded {
+		ew := embedWindow{limit: -1}
+
+		orderItems := embeddedOrder[emb.alias]
+		if order := query.Get(emb.alias + ".order"); order != "" {
+			items, err := splitTopLevel(order)
+			if err != nil {
+				return nil, fmt.Errorf("invalid order for %s: %w", emb.alias, err)
+			}
+			orderItems = append(orderItems, items...)
+		}
+		for _, item := range orderItems {
+			clause, err := buildOrderClause(strings.TrimSpace(item), names)
+			if err != nil {
+				return nil, fmt.Errorf("invalid order for %s: %w", emb.alias, err)
+			}
+			ew.order = append(ew.order, clause)
+		}
+
+		if v := query.Get(emb.alias + ".limit"); v != "" {
+			limit, err := parseRowCount(emb.alias+".limit", v)
+			if err != nil {
+				return nil, err
+			}
+			ew.limit = limit
+		}
+		if v := query.Get(emb.alias + ".offset"); v != "" {
+			offset, err := parseRowCount(emb.alias+".offset", v)
+			if err != nil {
+				return nil, err
+			}
+			ew.offset = offset
+		}
+		windows[emb.alias] = ew
+	}
+	return windows, nil
+}
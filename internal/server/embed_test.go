@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSplitOrderParam(t *testing.T) {
+	main, embedded, err := splitOrderParam("comments(created_at).desc,name.asc")
+	if err != nil {
+		t.Fatalf("splitOrderParam() error = %v", err)
+	}
+	if main != "name.asc" {
+		t.Errorf("main order = %q, want name.asc", main)
+	}
+	if want := map[string][]string{"comments": {"created_at.desc"}}; !reflect.DeepEqual(embedded, want) {
+		t.Errorf("embedded order = %v, want %v", embedded, want)
+	}
+
+	if _, _, err := splitOrderParam("comments(created_at.desc"); err == nil {
+		t.Error("splitOrderParam() should fail on unbalanced parentheses")
+	}
+}
+
+func TestEmbedWindows(t *testing.T) {
+	query, _ := url.ParseQuery("comments.order=likes.desc,id&comments.limit=3&comments.offset=6")
+	embedded := []embeddedResource{{alias: "comments", table: "comments"}, {alias: "author", table: "users"}}
+
+	windows, err := embedWindows(query, embedded, map[string][]string{"comments": {"created_at.desc"}}, IdentifierCaseExact)
+	if err != nil {
+		t.Fatalf("embedWindows() error = %v", err)
+	}
+
+	want := ` ORDER BY "created_at" DESC, "likes" DESC, "id" LIMIT 3 OFFSET 6`
+	if got := windows["comments"].sql(""); got != want {
+		t.Errorf("comments window = %q, want %q", got, want)
+	}
+	if got := windows["comments"].sql("t."); got != ` ORDER BY t."created_at" DESC, t."likes" DESC, t."id" LIMIT 3 OFFSET 6` {
+		t.Errorf("qualified comments window = %q", got)
+	}
+	if got := windows["author"].sql(""); got != "" {
+		t.Errorf("author window = %q, want none", got)
+	}
+}
+
+func TestEmbedWindows_Invalid(t *testing.T) {
+	embedded := []embeddedResource{{alias: "comments", table: "comments"}}
+	for _, raw := range []string{"comments.limit=-1", "comments.offset=abc", "comments.order=.desc"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := embedWindows(query, embedded, nil, IdentifierCaseExact); err == nil {
+			t.Errorf("embedWindows(%s) should fail", raw)
+		}
+	}
+}
+
+func TestIsEmbedWindowParam(t *testing.T) {
+	for key, want := range map[string]bool{
+		"comments.limit":  true,
+		"comments.order":  true,
+		"comments.offset": true,
+		"comments.status": false,
+		"limit":           true,
+	} {
+		if got := isEmbedWindowParam(key); got != want {
+			t.Errorf("isEmbedWindowParam(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
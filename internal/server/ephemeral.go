@@ -0,0 +1,22 @@
+package server
+
+import (
+	"fmt"
+	"os"
+)
+
+// ephemeralMinFree is the free space a tmpfs needs to hold an ephemeral
+// data directory: a fresh cluster is about 40MB and grows with the tests
+// that use it. Docker's default 64MB /dev/shm is too small.
+const ephemeralMinFree = 256 << 20
+
+// newEphemeralDataDir creates a throwaway data directory, on a tmpfs when
+// one with enough room is available so the database never touches disk.
+func newEphemeralDataDir() (string, error) {
+	parent := tmpfsDir(ephemeralMinFree)
+	dir, err := os.MkdirTemp(parent, "supalite-ephemeral-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create ephemeral data directory: %w", err)
+	}
+	return dir, nil
+}
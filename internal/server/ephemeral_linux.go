@@ -0,0 +1,21 @@
+//go:build linux
+
+package server
+
+import "golang.org/x/sys/unix"
+
+// tmpfsDir returns /dev/shm if it has at least minFree bytes available,
+// or "" to use the default temporary directory.
+func tmpfsDir(minFree uint64) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs("/dev/shm", &st); err != nil {
+		return ""
+	}
+	if st.Bavail*uint64(st.Bsize) < minFree {
+		return ""
+	}
+	if unix.Access("/dev/shm", unix.W_OK) != nil {
+		return ""
+	}
+	return "/dev/shm"
+}
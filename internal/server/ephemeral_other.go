@@ -0,0 +1,9 @@
+//go:build !linux
+
+package server
+
+// tmpfsDir returns "" to use the default temporary directory. Only Linux
+// has a tmpfs at a well-known path.
+func tmpfsDir(minFree uint64) string {
+	return ""
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestNewEphemeralDataDir(t *testing.T) {
+	dir, err := newEphemeralDataDir()
+	if err != nil {
+		t.Fatalf("newEphemeralDataDir() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("newEphemeralDataDir() = %q, which is not a directory", dir)
+	}
+}
+
+func TestTmpfsDir_TooSmall(t *testing.T) {
+	if got := tmpfsDir(math.MaxUint64); got != "" {
+		t.Errorf("tmpfsDir(MaxUint64) = %q, want the default temp dir", got)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/markb/supalite/internal/rls"
+)
+
+// cacheVary lists the request headers a table read depends on. The
+// response differs by who is asking (RLS), its format, schema, count and
+// range, so shared caches must not serve one client's response to another.
+const cacheVary = "Authorization, apikey, Accept, Accept-Profile, Prefer, Range"
+
+// setCaching adds the caching headers to a buffered table read: the
+// Cache-Control configured for the table, and a weak ETag computed from the
+// response. A GET whose If-None-Match names the ETag is answered 304 Not
+// Modified without a body, so clients polling an unchanged result, such as
+// dashboards, don't download it again. The query still runs; only the
+// bandwidth is saved. Either header comes with Vary, and the ETag includes
+// the request's role and claims, so users never share a tag.
+func (s *Server) setCaching(tw *txResponseWriter, r *http.Request, table string) {
+	if tw.status != http.StatusOK && tw.status != http.StatusPartialContent {
+		return
+	}
+	cc := s.config.REST.cacheControl(table)
+	if cc != "" {
+		tw.Header().Set("Cache-Control", cc)
+	}
+	// A HEAD response has no body to tag
+	if r.Method != http.MethodGet {
+		if cc != "" {
+			tw.Header().Add("Vary", cacheVary)
+		}
+		return
+	}
+
+	tag := weakETag(tw.Header(), requestClaims(r), tw.body.Bytes())
+	tw.Header().Add("Vary", cacheVary)
+	tw.Header().Set("ETag", tag)
+	if etagMatches(r.Header.Values("If-None-Match"), tag) {
+		tw.Header().Del("Content-Type")
+		tw.status = http.StatusNotModified
+		tw.body.Reset()
+	}
+}
+
+// cacheControl returns the Cache-Control header of GET and HEAD responses
+// for table: its entry in CacheControl, or the "*" entry.
+func (c RESTConfig) cacheControl(table string) string {
+	if cc, ok := c.CacheControl[table]; ok {
+		return cc
+	}
+	return c.CacheControl["*"]
+}
+
+// weakETag returns a weak entity tag for a response body. The headers that
+// describe the body, its type and the range of rows it holds, are part of
+// it, so the same rows read with a different count or format get another
+// tag. So are the role and claims of the request: rows read through RLS
+// are only valid for the user who read them. It is weak because the JSON encoding of equal results isn't
+// guaranteed to be byte-identical across versions.
+func weakETag(header http.Header, claims map[string]interface{}, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(rls.RoleForClaims(claims)))
+	h.Write([]byte{0})
+	// Map keys are encoded sorted, so equal claims hash the same
+	encoded, _ := json.Marshal(claims)
+	h.Write(encoded)
+	h.Write([]byte{0})
+	for _, name := range []string{"Content-Type", "Content-Range", "Content-Profile"} {
+		h.Write([]byte(header.Get(name)))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names tag, using the
+// weak comparison RFC 9110 prescribes for it. "*" matches any tag.
+func etagMatches(values []string, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tag := `W/"abc"`
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{`W/"abc"`}, true},
+		{[]string{`"abc"`}, true},
+		{[]string{`"xyz", W/"abc"`}, true},
+		{[]string{`"xyz"`, `"abc"`}, true},
+		{[]string{"*"}, true},
+		{[]string{`"xyz"`}, false},
+		{[]string{`W/"abcd"`}, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.values, tag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestWeakETag(t *testing.T) {
+	header := http.Header{"Content-Type": {"application/json"}, "Content-Range": {"0-1/*"}}
+	tag := weakETag(header, nil, []byte(`[{"id":1},{"id":2}]`))
+	if tag != weakETag(header.Clone(), nil, []byte(`[{"id":1},{"id":2}]`)) {
+		t.Error("weakETag() differs for the same response")
+	}
+	if tag == weakETag(header, nil, []byte(`[{"id":1},{"id":3}]`)) {
+		t.Error("weakETag() is the same for other rows")
+	}
+	counted := header.Clone()
+	counted.Set("Content-Range", "0-1/2")
+	if tag == weakETag(counted, nil, []byte(`[{"id":1},{"id":2}]`)) {
+		t.Error("weakETag() is the same for another Content-Range")
+	}
+	alice := map[string]interface{}{"role": "authenticated", "sub": "alice"}
+	if tag == weakETag(header, alice, []byte(`[{"id":1},{"id":2}]`)) {
+		t.Error("weakETag() is the same for another role")
+	}
+	if weakETag(header, alice, []byte(`[]`)) == weakETag(header, map[string]interface{}{"role": "authenticated", "sub": "bob"}, []byte(`[]`)) {
+		t.Error("weakETag() is the same for other claims")
+	}
+}
+
+func TestSetCaching(t *testing.T) {
+	s := &Server{config: Config{REST: RESTConfig{CacheControl: map[string]string{
+		"*":         "private, no-cache",
+		"countries": "public, max-age=3600",
+	}}}}
+	read := func(method, table, ifNoneMatch string, status int) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/rest/v1/"+table, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		tw := newTxResponseWriter(rec)
+		tw.Header().Set("Content-Type", "application/json")
+		tw.WriteHeader(status)
+		tw.Write([]byte(`[{"id":1}]`))
+		s.setCaching(tw, r, table)
+		tw.send()
+		return rec
+	}
+
+	rec := read(http.MethodGet, "todos", "", http.StatusOK)
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || tag == "" || rec.Body.String() != `[{"id":1}]` {
+		t.Fatalf("GET = %d, ETag %q, body %q", rec.Code, tag, rec.Body)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("Cache-Control = %q, want the * entry", cc)
+	}
+
+	rec = read(http.MethodGet, "todos", tag, http.StatusOK)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != tag {
+		t.Errorf("GET with a matching If-None-Match = %d, ETag %q, body %q; want 304 without a body", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+	if rec := read(http.MethodGet, "todos", `W/"other"`, http.StatusOK); rec.Code != http.StatusOK {
+		t.Errorf("GET with another If-None-Match = %d, want 200", rec.Code)
+	}
+
+	rec = read(http.MethodHead, "countries", "", http.StatusOK)
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("HEAD: ETag %q, Cache-Control %q; want no ETag and the table's entry", rec.Header().Get("ETag"), rec.Header().Get("Cache-Control"))
+	}
+
+	rec = read(http.MethodGet, "todos", "*", http.StatusBadRequest)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("ETag") != "" || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("failed GET = %d, ETag %q, Cache-Control %q; want neither header", rec.Code, rec.Header().Get("ETag"), rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestSetCaching_PerUser(t *testing.T) {
+	s := &Server{}
+	read := func(claims map[string]interface{}, ifNoneMatch string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Vary", "Origin") // Set by the CORS handler
+		r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+		r = r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims))
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		tw := newTxResponseWriter(rec)
+		tw.Header().Set("Content-Type", "application/json")
+		tw.WriteHeader(http.StatusOK)
+		tw.Write([]byte(`[]`)) // Both users see no rows
+		s.setCaching(tw, r, "todos")
+		tw.send()
+		return rec
+	}
+
+	alice := read(map[string]interface{}{"role": "authenticated", "sub": "alice"}, "")
+	bob := read(map[string]interface{}{"role": "authenticated", "sub": "bob"}, "")
+	if alice.Header().Get("ETag") == bob.Header().Get("ETag") {
+		t.Errorf("two users got the same ETag %q", alice.Header().Get("ETag"))
+	}
+	if vary := alice.Header().Values("Vary"); len(vary) != 2 || vary[0] != "Origin" || vary[1] != cacheVary {
+		t.Errorf("Vary = %q, want Origin and %q", vary, cacheVary)
+	}
+	if rec := read(map[string]interface{}{"role": "authenticated", "sub": "bob"}, alice.Header().Get("ETag")); rec.Code != http.StatusOK {
+		t.Errorf("GET with another user's ETag = %d, want 200", rec.Code)
+	}
+
+	// Without caching headers there is nothing to vary
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodHead, "/rest/v1/todos", nil)
+	tw := newTxResponseWriter(rec)
+	tw.WriteHeader(http.StatusOK)
+	s.setCaching(tw, r, "todos")
+	tw.send()
+	if vary := rec.Header().Get("Vary"); vary != "" {
+		t.Errorf("HEAD without Cache-Control: Vary = %q, want none", vary)
+	}
+}
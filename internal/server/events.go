@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is a stage in a component's lifecycle.
+type EventType string
+
+// Lifecycle stages reported to Config.OnEvent.
+const (
+	ComponentStarting EventType = "starting"
+	ComponentStarted  EventType = "started"
+	ComponentFailed   EventType = "failed"
+	ComponentStopped  EventType = "stopped"
+)
+
+// Components whose lifecycle is reported. ComponentHTTP started means the
+// server accepts requests, so it is the last event of a successful Start.
+const (
+	ComponentPostgres    = "postgres"
+	ComponentPREST       = "prest"
+	ComponentMailCapture = "mail_capture"
+	ComponentAuth        = "auth"
+	ComponentRealtime    = "realtime"
+	ComponentStorage     = "storage"
+	ComponentPgNet       = "pg_net"
+	ComponentHistory     = "history"
+	ComponentAuditLog    = "audit_log"
+	ComponentUsage       = "usage"
+	ComponentSchemaCache = "schema_cache"
+	ComponentHTTP        = "http"
+)
+
+// Event reports a component starting, started, failing, or stopped, for
+// programs that embed the server: to show startup progress, decide when
+// they consider it ready, or react to a component crashing.
+type Event struct {
+	Type      EventType
+	Component string
+	Time      time.Time
+
+	// For ComponentFailed: what went wrong, and whether Start gives up
+	// because of it. The server keeps running without a component whose
+	// failure isn't fatal, e.g. realtime's change capture, or GoTrue after
+	// a crash it couldn't be restarted from.
+	Err   error
+	Fatal bool
+
+	// Details depending on the component, e.g. "port" or "socket" for
+	// postgres, "mode" for auth, "addr" for http, and "restarted" for auth
+	// after a crash
+	Metadata map[string]string
+}
+
+// emit delivers an event to Config.OnEvent. Events are delivered one at a
+// time, in the order they happen.
+func (s *Server) emit(event Event) {
+	if s.config.OnEvent == nil {
+		return
+	}
+	event.Time = time.Now()
+	s.eventMu.Lock()
+	defer s.eventMu.Unlock()
+	s.config.OnEvent(event)
+}
+
+func (s *Server) componentStarting(component string) {
+	s.emit(Event{Type: ComponentStarting, Component: component})
+}
+
+func (s *Server) componentStarted(component string, metadata map[string]string) {
+	s.emit(Event{Type: ComponentStarted, Component: component, Metadata: metadata})
+}
+
+// componentFailed reports a component that failed to start or crashed,
+// and returns err, so fatal failures can be reported as they're returned.
+func (s *Server) componentFailed(component string, err error, fatal bool) error {
+	s.emit(Event{Type: ComponentFailed, Component: component, Err: err, Fatal: fatal})
+	return err
+}
+
+func (s *Server) componentStopped(component string) {
+	s.emit(Event{Type: ComponentStopped, Component: component})
+}
+
+// goTrueExited reports a GoTrue crash and the outcome of restarting it, as
+// events and, when notifications are on, as an alert.
+func (s *Server) goTrueExited(exitErr, restartErr error) {
+	if restartErr != nil {
+		s.componentFailed(ComponentAuth, fmt.Errorf("GoTrue crashed and could not be restarted: %w", restartErr), false)
+	} else {
+		s.componentFailed(ComponentAuth, fmt.Errorf("GoTrue crashed: %w", exitErr), false)
+		s.componentStarted(ComponentAuth, map[string]string{"mode": AuthModeGoTrue, "restarted": "true"})
+	}
+	if s.config.Notifier != nil {
+		s.notifyGoTrueExit(exitErr, restartErr)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	var events []Event
+	s := &Server{config: Config{OnEvent: func(e Event) { events = append(events, e) }}}
+
+	s.componentStarting(ComponentPostgres)
+	s.componentStarted(ComponentPostgres, map[string]string{"port": "5432"})
+	failure := errors.New("no route")
+	if err := s.componentFailed(ComponentRealtime, failure, false); err != failure {
+		t.Errorf("componentFailed() = %v, want its error", err)
+	}
+	s.componentStopped(ComponentPostgres)
+
+	want := []struct {
+		typ       EventType
+		component string
+	}{
+		{ComponentStarting, ComponentPostgres},
+		{ComponentStarted, ComponentPostgres},
+		{ComponentFailed, ComponentRealtime},
+		{ComponentStopped, ComponentPostgres},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Component != w.component || events[i].Time.IsZero() {
+			t.Errorf("event %d = %+v, want %s %s", i, events[i], w.typ, w.component)
+		}
+	}
+	if events[1].Metadata["port"] != "5432" {
+		t.Errorf("started metadata = %v", events[1].Metadata)
+	}
+	if events[2].Err != failure || events[2].Fatal {
+		t.Errorf("failed event = %+v, want a non-fatal failure", events[2])
+	}
+
+	// Without OnEvent, nothing is reported
+	(&Server{}).componentStarting(ComponentHTTP)
+}
+
+func TestGoTrueExited(t *testing.T) {
+	var events []Event
+	s := &Server{config: Config{OnEvent: func(e Event) { events = append(events, e) }}}
+
+	s.goTrueExited(errors.New("signal: killed"), nil)
+	if len(events) != 2 || events[0].Type != ComponentFailed || events[1].Type != ComponentStarted || events[1].Metadata["restarted"] != "true" {
+		t.Fatalf("restarted GoTrue: events %+v, want failed then started", events)
+	}
+
+	events = nil
+	s.goTrueExited(errors.New("signal: killed"), errors.New("port in use"))
+	if len(events) != 1 || events[0].Type != ComponentFailed || !strings.Contains(events[0].Err.Error(), "port in use") {
+		t.Errorf("GoTrue not restarted: events %+v, want one failure with the restart error", events)
+	}
+}
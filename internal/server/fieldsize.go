@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tableColumn is a column of a table or view, with the name of its type,
+// e.g. "text" or "bytea".
+type tableColumn struct {
+	name    string
+	typName string
+}
+
+// loadTableColumns returns the columns of a table or view, in order.
+func loadTableColumns(ctx context.Context, tx pgx.Tx, schema, table string) ([]tableColumn, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT a.attname, t.typname
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE a.attrelid = to_regclass($1) AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`, qualifiedTable(schema, table))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (tableColumn, error) {
+		var c tableColumn
+		err := row.Scan(&c.name, &c.typName)
+		return c, err
+	})
+}
+
+// splitExcludedColumns separates the !column items of a select list, as in
+// select=*,!raw_message, from the columns to select. A list of exclusions
+// alone selects every other column.
+func splitExcludedColumns(columns []string) (included, excluded []string) {
+	for _, col := range columns {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(col), "!"); ok {
+			excluded = append(excluded, name)
+		} else {
+			included = append(included, col)
+		}
+	}
+	if len(included) == 0 {
+		included = []string{"*"}
+	}
+	return included, excluded
+}
+
+// hasFieldSizeLimits reports whether any column's values are cut short.
+func (c RESTConfig) hasFieldSizeLimits() bool {
+	return c.MaxFieldSize > 0 || len(c.FieldSizeLimits) > 0
+}
+
+// fieldSizeLimit returns the size values of a column are cut to: its
+// entry in FieldSizeLimits, keyed "table.column", or MaxFieldSize. Zero
+// is no limit.
+func (c RESTConfig) fieldSizeLimit(table, column string) int {
+	if limit, ok := c.FieldSizeLimits[table+"."+column]; ok {
+		return limit
+	}
+	return c.MaxFieldSize
+}
+
+// limitedColumn returns the select expression of a text or bytea column
+// whose values are cut to limit characters or bytes, or "" for other
+// types. A value over the limit is replaced by a truncation marker:
+//
+//	{"truncated": true, "length": 1048576, "value": "<the first limit characters or bytes>"}
+//
+// bytea values are base64, as they are when not cut.
+func limitedColumn(col tableColumn, limit int) string {
+	quoted := quoteIdentifier(col.name)
+	var value, prefix string
+	switch col.typName {
+	case "text", "varchar", "bpchar", "citext":
+		value = quoted + "::text"
+		prefix = fmt.Sprintf("left(%s, %d)", value, limit)
+	case "bytea":
+		value = quoted
+		prefix = base64SQL(fmt.Sprintf("substring(%s from 1 for %d)", quoted, limit))
+	default:
+		return ""
+	}
+	full := value
+	if col.typName == "bytea" {
+		full = base64SQL(value)
+	}
+	return fmt.Sprintf(
+		"CASE WHEN length(%[1]s) > %[2]d THEN json_build_object('truncated', true, 'length', length(%[1]s), 'value', %[3]s) ELSE to_json(%[4]s) END AS %[5]s",
+		value, limit, prefix, full, quoted)
+}
+
+// base64SQL encodes a bytea expression as base64 without the line breaks
+// PostgreSQL's encode adds, as encoding/json writes []byte.
+func base64SQL(expr string) string {
+	return fmt.Sprintf("translate(encode(%s, 'base64'), E'\\n', '')", expr)
+}
+
+// expandColumns resolves a select list against the table's columns: *
+// becomes the table's columns, and excluded columns are left out. An
+// excluded column the table doesn't have is an error, so a typo can't
+// let a heavy column through.
+func expandColumns(columns, excluded []string, tableColumns []tableColumn) ([]string, error) {
+	skip := make(map[string]bool, len(excluded))
+	for _, name := range excluded {
+		if !slices.ContainsFunc(tableColumns, func(c tableColumn) bool { return c.name == name }) {
+			return nil, fmt.Errorf("column %q does not exist", name)
+		}
+		skip[name] = true
+	}
+
+	var names []string
+	for _, col := range columns {
+		if col = strings.TrimSpace(col); col == "*" {
+			for _, c := range tableColumns {
+				if !skip[c.name] {
+					names = append(names, c.name)
+				}
+			}
+		} else if !skip[col] {
+			names = append(names, col)
+		}
+	}
+	return names, nil
+}
+
+// selectColumnExpr builds the select expression of a column of table:
+// limitedColumn's when its values have a size limit, and otherwise
+// buildSelectColumn's. tableColumns is nil when no limits apply.
+func (s *Server) selectColumnExpr(col, table string, tableColumns []tableColumn) (string, error) {
+	if i := slices.IndexFunc(tableColumns, func(c tableColumn) bool { return c.name == col }); i >= 0 {
+		if limit := s.config.REST.fieldSizeLimit(table, col); limit > 0 {
+			if expr := limitedColumn(tableColumns[i], limit); expr != "" {
+				return expr, nil
+			}
+		}
+	}
+	return buildSelectColumn(col)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/pg"
+)
+
+func TestSplitExcludedColumns(t *testing.T) {
+	tests := []struct {
+		columns  []string
+		included []string
+		excluded []string
+	}{
+		{[]string{"*"}, []string{"*"}, nil},
+		{[]string{"*", "!raw_message"}, []string{"*"}, []string{"raw_message"}},
+		{[]string{" !raw_message ", "!body"}, []string{"*"}, []string{"raw_message", "body"}},
+		{[]string{"id", "subject"}, []string{"id", "subject"}, nil},
+	}
+
+	for _, tt := range tests {
+		included, excluded := splitExcludedColumns(tt.columns)
+		if !reflect.DeepEqual(included, tt.included) || !reflect.DeepEqual(excluded, tt.excluded) {
+			t.Errorf("splitExcludedColumns(%q) = %q, %q, want %q, %q", tt.columns, included, excluded, tt.included, tt.excluded)
+		}
+	}
+}
+
+func TestExpandColumns(t *testing.T) {
+	tableColumns := []tableColumn{{"id", "int4"}, {"subject", "text"}, {"raw_message", "bytea"}}
+
+	got, err := expandColumns([]string{"*"}, []string{"raw_message"}, tableColumns)
+	if err != nil || !reflect.DeepEqual(got, []string{"id", "subject"}) {
+		t.Errorf("expandColumns(*, !raw_message) = %q, %v", got, err)
+	}
+	got, err = expandColumns([]string{"subject", "raw_message"}, []string{"raw_message"}, tableColumns)
+	if err != nil || !reflect.DeepEqual(got, []string{"subject"}) {
+		t.Errorf("expandColumns(subject, raw_message, !raw_message) = %q, %v", got, err)
+	}
+	if _, err := expandColumns([]string{"*"}, []string{"raw_mesage"}, tableColumns); err == nil {
+		t.Error("excluding an unknown column succeeded, want error")
+	}
+}
+
+func TestFieldSizeLimit(t *testing.T) {
+	c := RESTConfig{MaxFieldSize: 100, FieldSizeLimits: map[string]int{"emails.raw_message": 10, "emails.body": 0}}
+
+	tests := []struct {
+		table, column string
+		want          int
+	}{
+		{"emails", "raw_message", 10},
+		{"emails", "body", 0},
+		{"emails", "subject", 100},
+		{"notes", "raw_message", 100},
+	}
+	for _, tt := range tests {
+		if got := c.fieldSizeLimit(tt.table, tt.column); got != tt.want {
+			t.Errorf("fieldSizeLimit(%s, %s) = %d, want %d", tt.table, tt.column, got, tt.want)
+		}
+	}
+	if (RESTConfig{}).hasFieldSizeLimits() {
+		t.Error("hasFieldSizeLimits() without limits = true")
+	}
+}
+
+func TestSelectColumnExpr(t *testing.T) {
+	s := &Server{config: Config{REST: RESTConfig{FieldSizeLimits: map[string]int{"emails.subject": 5, "emails.id": 5}}}}
+	tableColumns := []tableColumn{{"id", "int4"}, {"subject", "text"}}
+
+	got, err := s.selectColumnExpr("subject", "emails", tableColumns)
+	if err != nil || !strings.Contains(got, `left("subject"::text, 5)`) || !strings.HasSuffix(got, `AS "subject"`) {
+		t.Errorf("limited text column = %s, %v", got, err)
+	}
+	// Columns of other types, and columns without a limit, are selected as they are
+	if got, _ := s.selectColumnExpr("id", "emails", tableColumns); got != `"id"` {
+		t.Errorf("limited int column = %s", got)
+	}
+	if got, _ := s.selectColumnExpr("subject", "notes", tableColumns); got != `"subject"` {
+		t.Errorf("unlimited column = %s", got)
+	}
+	if got, _ := s.selectColumnExpr("subject", "emails", nil); got != `"subject"` {
+		t.Errorf("column without limits = %s", got)
+	}
+}
+
+func TestHandleGET_WideRows(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15437,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-fieldsize",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE public.emails (id int PRIMARY KEY, subject text, raw_message bytea);
+		INSERT INTO public.emails VALUES
+			(1, 'hi', '\x0102'),
+			(2, repeat('x', 20), decode(repeat('ff', 3000), 'hex')),
+			(3, NULL, NULL);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	get := func(rest RESTConfig, query string) []map[string]interface{} {
+		t.Helper()
+		s := &Server{config: Config{REST: rest}}
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+
+		rec := httptest.NewRecorder()
+		s.handleGET(ctx, tx, rec, httptest.NewRequest(http.MethodGet, "/rest/v1/emails?order=id&"+query, nil), "public", "emails")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET ?%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("GET ?%s: %v", query, err)
+		}
+		return rows
+	}
+
+	rows := get(RESTConfig{}, "select=*,!raw_message")
+	if _, ok := rows[0]["raw_message"]; ok || rows[0]["subject"] != "hi" {
+		t.Errorf("select=*,!raw_message returned %v", rows[0])
+	}
+
+	rows = get(RESTConfig{MaxFieldSize: 10}, "")
+	if rows[0]["subject"] != "hi" || rows[0]["raw_message"] != base64.StdEncoding.EncodeToString([]byte{1, 2}) {
+		t.Errorf("short values = %v", rows[0])
+	}
+	subject, _ := rows[1]["subject"].(map[string]interface{})
+	if subject["truncated"] != true || subject["length"] != float64(20) || subject["value"] != strings.Repeat("x", 10) {
+		t.Errorf("truncated subject = %v", rows[1]["subject"])
+	}
+	raw, _ := rows[1]["raw_message"].(map[string]interface{})
+	if raw["length"] != float64(3000) || raw["value"] != base64.StdEncoding.EncodeToString([]byte(strings.Repeat("\xff", 10))) {
+		t.Errorf("truncated raw_message = %v", rows[1]["raw_message"])
+	}
+	if rows[2]["subject"] != nil || rows[2]["raw_message"] != nil {
+		t.Errorf("null values = %v", rows[2])
+	}
+
+	// A limit of 0 for a column lifts max_field_size
+	rows = get(RESTConfig{MaxFieldSize: 10, FieldSizeLimits: map[string]int{"emails.raw_message": 0}}, "select=raw_message")
+	if rows[1]["raw_message"] != base64.StdEncoding.EncodeToString([]byte(strings.Repeat("\xff", 3000))) {
+		t.Errorf("unlimited raw_message = %.40v", rows[1]["raw_message"])
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	rec := httptest.NewRecorder()
+	(&Server{}).handleGET(ctx, tx, rec, httptest.NewRequest(http.MethodGet, "/rest/v1/emails?select=*,!nope", nil), "public", "emails")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("excluding an unknown column: status %d, want 400", rec.Code)
+	}
+}
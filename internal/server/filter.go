@@ -0,0 +1,255 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// filterBuilder turns PostgREST filters into SQL conditions. Values are
+// collected as bind parameters numbered from offset+1, and column names are
+// mapped by names.
+type filterBuilder struct {
+	offset int
+	args   []interface{}
+	names  IdentifierCase
+}
+
+// param adds a bind parameter and returns its placeholder.
+func (b *filterBuilder) param(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", b.offset+len(b.args))
+}
+
+// filter builds the condition for a column filter such as age=gte.18. A
+// not. prefix negates it: age=not.gte.18.
+func (b *filterBuilder) filter(column, value string) (string, error) {
+	colRef := columnRef(b.names.column(column))
+	if rest, ok := strings.CutPrefix(value, "not."); ok {
+		cond, err := b.operator(colRef, rest)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + cond + ")", nil
+	}
+	return b.operator(colRef, value)
+}
+
+// comparisonOperators maps PostgREST operators that take a single value to
+// their SQL operators. The value is bound as a text parameter, so Postgres
+// parses it as the column's type: arrays ({1,2}), ranges ([1,5)), and JSON
+// all work.
+var comparisonOperators = map[string]string{
+	"eq":         "=",
+	"neq":        "<>",
+	"gt":         ">",
+	"gte":        ">=",
+	"lt":         "<",
+	"lte":        "<=",
+	"like":       "LIKE",
+	"ilike":      "ILIKE",
+	"match":      "~",
+	"imatch":     "~*",
+	"isdistinct": "IS DISTINCT FROM",
+	"cs":         "@>",  // contains
+	"cd":         "<@",  // contained in
+	"ov":         "&&",  // overlaps
+	"sl":         "<<",  // strictly left of
+	"sr":         ">>",  // strictly right of
+	"nxr":        "&<",  // does not extend to the right of
+	"nxl":        "&>",  // does not extend to the left of
+	"adj":        "-|-", // adjacent to
+}
+
+// textSearchFunctions maps the full-text search operators to the function
+// that parses their query.
+var textSearchFunctions = map[string]string{
+	"fts":   "to_tsquery",
+	"plfts": "plainto_tsquery",
+	"phfts": "phraseto_tsquery",
+	"wfts":  "websearch_to_tsquery",
+}
+
+// isValues are the values the is operator accepts.
+var isValues = map[string]string{
+	"null":     "NULL",
+	"not_null": "NOT NULL",
+	"true":     "TRUE",
+	"false":    "FALSE",
+	"unknown":  "UNKNOWN",
+}
+
+// operator builds the condition for an operator.value pair. A value without
+// a known operator is compared for equality as a whole.
+func (b *filterBuilder) operator(colRef, value string) (string, error) {
+	operator, argValue, ok := strings.Cut(value, ".")
+	if !ok {
+		return fmt.Sprintf("%s = %s", colRef, b.param(value)), nil
+	}
+
+	if sqlOp, ok := comparisonOperators[operator]; ok {
+		if operator == "like" || operator == "ilike" {
+			// PostgREST accepts * as a wildcard, since % is awkward in URLs
+			argValue = strings.ReplaceAll(argValue, "*", "%")
+		}
+		return fmt.Sprintf("%s %s %s", colRef, sqlOp, b.param(argValue)), nil
+	}
+
+	// Full-text search, with an optional text search configuration:
+	// fts.cat, fts(english).cat
+	name, config, hasConfig := strings.Cut(operator, "(")
+	if fn, ok := textSearchFunctions[name]; ok {
+		if !hasConfig {
+			return fmt.Sprintf("%s @@ %s(%s)", colRef, fn, b.param(argValue)), nil
+		}
+		if !strings.HasSuffix(config, ")") || len(config) == 1 {
+			return "", fmt.Errorf("invalid text search operator %q", operator)
+		}
+		config = strings.TrimSuffix(config, ")")
+		return fmt.Sprintf("%s @@ %s(%s::regconfig, %s)", colRef, fn, b.param(config), b.param(argValue)), nil
+	}
+
+	switch operator {
+	case "is":
+		keyword, ok := isValues[strings.ToLower(argValue)]
+		if !ok {
+			return "", fmt.Errorf("invalid is value %q: want null, not_null, true, false, or unknown", argValue)
+		}
+		return fmt.Sprintf("%s IS %s", colRef, keyword), nil
+	case "in":
+		return b.in(colRef, argValue)
+	default:
+		// Unknown operator, treat as direct equality
+		return fmt.Sprintf("%s = %s", colRef, b.param(value)), nil
+	}
+}
+
+// in builds an IN condition from a list such as (1,2,3) or ("a,b",c). Each
+// value is a text parameter that Postgres parses as the column's type, so
+// text, uuid, enum, and date columns work alike.
+func (b *filterBuilder) in(colRef, list string) (string, error) {
+	if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+		return "", fmt.Errorf("in filter must be wrapped in parentheses: %s", list)
+	}
+	list = list[1 : len(list)-1]
+	if strings.TrimSpace(list) == "" {
+		// Nothing is in an empty list
+		return "FALSE", nil
+	}
+	inValues, err := splitTopLevel(list)
+	if err != nil {
+		return "", fmt.Errorf("invalid in list (%s): %w", list, err)
+	}
+
+	placeholders := make([]string, len(inValues))
+	for i, v := range inValues {
+		placeholders[i] = b.param(unquoteValue(strings.TrimSpace(v)))
+	}
+	return fmt.Sprintf("%s IN (%s)", colRef, strings.Join(placeholders, ", ")), nil
+}
+
+// logic builds the condition for a logic tree such as
+// or=(age.gte.18,name.eq.Bob). Trees nest: and=(a.eq.1,or(b.eq.2,c.eq.3)).
+func (b *filterBuilder) logic(op, tree string, negate bool) (string, error) {
+	if !strings.HasPrefix(tree, "(") || !strings.HasSuffix(tree, ")") {
+		return "", fmt.Errorf("%s filter must be wrapped in parentheses: %s", op, tree)
+	}
+	items, err := splitTopLevel(tree[1 : len(tree)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid %s filter %s: %w", op, tree, err)
+	}
+
+	conds := make([]string, 0, len(items))
+	for _, item := range items {
+		cond, err := b.logicItem(item)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+
+	joined := "(" + strings.Join(conds, " "+strings.ToUpper(op)+" ") + ")"
+	if negate {
+		return "NOT " + joined, nil
+	}
+	return joined, nil
+}
+
+// logicItem builds the condition for one element of a logic tree: a nested
+// tree such as not.and(...), or a filter such as age.not.eq.5. Values may be
+// double-quoted to include commas and parentheses: name.eq."Smith, J.".
+func (b *filterBuilder) logicItem(item string) (string, error) {
+	item = strings.TrimSpace(item)
+	rest, negate := strings.CutPrefix(item, "not.")
+	for _, op := range []string{"and", "or"} {
+		if tree, ok := strings.CutPrefix(rest, op+"("); ok {
+			return b.logic(op, "("+tree, negate)
+		}
+	}
+
+	column, value, ok := strings.Cut(item, ".")
+	if !ok || value == "" {
+		return "", fmt.Errorf("invalid filter %q: want column.operator.value", item)
+	}
+	if err := validateIdentifier(column); err != nil {
+		return "", err
+	}
+
+	prefix := ""
+	if v, ok := strings.CutPrefix(value, "not."); ok {
+		prefix, value = "not.", v
+	}
+	if operator, operand, ok := strings.Cut(value, "."); ok && operator != "in" {
+		value = operator + "." + unquoteValue(operand)
+	}
+	return b.filter(column, prefix+value)
+}
+
+// splitTopLevel splits s at commas that are outside parentheses and double
+// quotes.
+func splitTopLevel(s string) ([]string, error) {
+	var parts []string
+	depth, start := 0, 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+	return append(parts, s[start:]), nil
+}
+
+// unquoteValue strips the double quotes from a quoted filter value,
+// unescaping \" and \\ inside it. Other values are returned as they are.
+func unquoteValue(v string) string {
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return v
+	}
+	var sb strings.Builder
+	inner := v[1 : len(v)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		sb.WriteByte(inner[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestBuildWhereClause(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		want   string
+		args   []interface{}
+		offset int
+	}{
+		{
+			name:  "simple filters",
+			query: "age=gte.18&name=eq.Bob&select=id&order=name",
+			want:  `"age" >= $1 AND "name" = $2`,
+			args:  []interface{}{"18", "Bob"},
+		},
+		{
+			name:  "apikey parameter",
+			query: "name=eq.Bob&apikey=sb_publishable_abc",
+			want:  `"name" = $1`,
+			args:  []interface{}{"Bob"},
+		},
+		{
+			name:  "repeated column",
+			query: "age=gte.18&age=lte.65",
+			want:  `"age" >= $1 AND "age" <= $2`,
+			args:  []interface{}{"18", "65"},
+		},
+		{
+			name:  "not prefix",
+			query: "status=not.eq.archived",
+			want:  `NOT ("status" = $1)`,
+			args:  []interface{}{"archived"},
+		},
+		{
+			name:  "or",
+			query: "or=(age.gte.18,name.eq.Bob)",
+			want:  `("age" >= $1 OR "name" = $2)`,
+			args:  []interface{}{"18", "Bob"},
+		},
+		{
+			name:  "nested and inside or",
+			query: "or=(role.eq.admin,and(age.gte.18,age.lte.65))",
+			want:  `("role" = $1 OR ("age" >= $2 AND "age" <= $3))`,
+			args:  []interface{}{"admin", "18", "65"},
+		},
+		{
+			name:  "not.or with negated items",
+			query: "not.or=(age.not.gt.5,not.and(a.eq.1,b.eq.2))",
+			want:  `NOT (NOT ("age" > $1) OR NOT ("a" = $2 AND "b" = $3))`,
+			args:  []interface{}{"5", "1", "2"},
+		},
+		{
+			name:  "quoted value",
+			query: `or=(name.eq."Smith, J. (Jr)",name.eq."say \"hi\"")`,
+			want:  `("name" = $1 OR "name" = $2)`,
+			args:  []interface{}{"Smith, J. (Jr)", `say "hi"`},
+		},
+		{
+			name:  "in inside or",
+			query: `or=(id.in.(1,2,3),tag.in.("a,b",c))`,
+			want:  `("id" IN ($1, $2, $3) OR "tag" IN ($4, $5))`,
+			args:  []interface{}{"1", "2", "3", "a,b", "c"},
+		},
+		{
+			name:   "combined with column filters and offset",
+			query:  "or=(a.eq.1,b.eq.2)&c=eq.3",
+			want:   `"c" = $3 AND ("a" = $4 OR "b" = $5)`,
+			args:   []interface{}{"3", "1", "2"},
+			offset: 2,
+		},
+		{
+			name:  "embedded logic trees are left for embedding",
+			query: "countries.or=(name.eq.Canada)",
+			want:  "",
+		},
+	}
+
+	s := &Server{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, args, err := s.buildWhereClause(query, tt.offset)
+			if err != nil {
+				t.Fatalf("buildWhereClause() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("clause = %s\nwant     %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestBuildWhereClause_IdentifierCase(t *testing.T) {
+	query := url.Values{
+		"createdAt": {"gte.2024-01-01"},
+		"or":        {"(isActive.is.true,data->>userName.eq.Bob)"},
+	}
+	tests := map[IdentifierCase]string{
+		IdentifierCaseExact: `"createdAt" >= $1 AND ("isActive" IS TRUE OR "data"->>'userName' = $2)`,
+		IdentifierCaseFold:  `"createdat" >= $1 AND ("isactive" IS TRUE OR "data"->>'userName' = $2)`,
+		IdentifierCaseSnake: `"created_at" >= $1 AND ("is_active" IS TRUE OR "data"->>'userName' = $2)`,
+	}
+	for names, want := range tests {
+		s := &Server{config: Config{REST: RESTConfig{IdentifierCase: names}}}
+		got, _, err := s.buildWhereClause(query, 0)
+		if err != nil {
+			t.Fatalf("%s: buildWhereClause() failed: %v", names, err)
+		}
+		if got != want {
+			t.Errorf("%s: clause = %s\nwant %s", names, got, want)
+		}
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		args  []interface{}
+	}{
+		{"neq.1", `"col" <> $1`, []interface{}{"1"}},
+		{"like.*smith*", `"col" LIKE $1`, []interface{}{"%smith%"}},
+		{"ilike.%Smith%", `"col" ILIKE $1`, []interface{}{"%Smith%"}},
+		{"match.^ab+c$", `"col" ~ $1`, []interface{}{"^ab+c$"}},
+		{"imatch.^abc", `"col" ~* $1`, []interface{}{"^abc"}},
+		{"isdistinct.x", `"col" IS DISTINCT FROM $1`, []interface{}{"x"}},
+		{"is.null", `"col" IS NULL`, nil},
+		{"is.not_null", `"col" IS NOT NULL`, nil},
+		{"is.TRUE", `"col" IS TRUE`, nil},
+		{"is.false", `"col" IS FALSE`, nil},
+		{"is.unknown", `"col" IS UNKNOWN`, nil},
+		{"not.is.null", `NOT ("col" IS NULL)`, nil},
+		{"in.(a, b c,\"x,y\")", `"col" IN ($1, $2, $3)`, []interface{}{"a", "b c", "x,y"}},
+		{"in.()", `FALSE`, nil},
+		{"cs.{1,2}", `"col" @> $1`, []interface{}{"{1,2}"}},
+		{`cs.{"a":1}`, `"col" @> $1`, []interface{}{`{"a":1}`}},
+		{"cd.{1,2,3}", `"col" <@ $1`, []interface{}{"{1,2,3}"}},
+		{"ov.[2,5)", `"col" && $1`, []interface{}{"[2,5)"}},
+		{"sl.(1,10)", `"col" << $1`, []interface{}{"(1,10)"}},
+		{"sr.(1,10)", `"col" >> $1`, []interface{}{"(1,10)"}},
+		{"nxr.(1,10)", `"col" &< $1`, []interface{}{"(1,10)"}},
+		{"nxl.(1,10)", `"col" &> $1`, []interface{}{"(1,10)"}},
+		{"adj.(1,10)", `"col" -|- $1`, []interface{}{"(1,10)"}},
+		{"fts.cat & dog", `"col" @@ to_tsquery($1)`, []interface{}{"cat & dog"}},
+		{"plfts.fat cats", `"col" @@ plainto_tsquery($1)`, []interface{}{"fat cats"}},
+		{"phfts(english).fat cats", `"col" @@ phraseto_tsquery($1::regconfig, $2)`, []interface{}{"english", "fat cats"}},
+		{`wfts(french).chat -"chien noir"`, `"col" @@ websearch_to_tsquery($1::regconfig, $2)`, []interface{}{"french", `chat -"chien noir"`}},
+		{"unknown.x", `"col" = $1`, []interface{}{"unknown.x"}},
+	}
+
+	for _, tt := range tests {
+		b := &filterBuilder{}
+		got, err := b.filter("col", tt.value)
+		if err != nil {
+			t.Errorf("filter(col, %s) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want || !reflect.DeepEqual(b.args, tt.args) {
+			t.Errorf("filter(col, %s) = %s %v, want %s %v", tt.value, got, b.args, tt.want, tt.args)
+		}
+	}
+
+	for _, value := range []string{"is.maybe", "in.1,2", `in.("a)`, "fts().cat", "fts(english.cat"} {
+		if _, err := (&filterBuilder{}).filter("col", value); err == nil {
+			t.Errorf("filter(col, %s) succeeded, want error", value)
+		}
+	}
+}
+
+func TestBuildWhereClause_InvalidLogicTree(t *testing.T) {
+	s := &Server{}
+	for _, tree := range []string{
+		"age.gte.18,name.eq.Bob",
+		"(age.gte.18,and(name.eq.Bob)",
+		`(name.eq."unterminated)`,
+		"(age)",
+		"(,)",
+	} {
+		if _, _, err := s.buildWhereClause(url.Values{"or": {tree}}, 0); err == nil {
+			t.Errorf("buildWhereClause(or=%s) succeeded, want error", tree)
+		}
+	}
+}
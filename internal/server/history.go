@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/history"
+)
+
+// handleHistoryTables lists the tables whose changes are tracked.
+//
+// GET /admin/v1/history
+//
+// Requires the service_role key. Response:
+//
+//	{"tables": [{"schema": "public", "name": "todos", "versions": 12, "oldest": "...", "newest": "..."}]}
+func (s *Server) handleHistoryTables(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	tables, err := history.Tracked(ctx, conn.Conn())
+	if err != nil {
+		logger.Error("failed to list tracked tables", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tables == nil {
+		tables = []history.Table{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tables": tables})
+}
+
+// handleHistory returns the change history of a table, newest first.
+//
+// GET /admin/v1/history/{table}[?<primary key column>=<value>...][&limit=100][&before=<id>]
+//
+// Requires the service_role key. The table may be schema-qualified
+// (app.orders). Primary key parameters select one row's history, e.g.
+// /admin/v1/history/todos?id=42; without them, every change to the table
+// is returned. before pages back from the lowest id of the previous page.
+// Response:
+//
+//	{"versions": [{"id": 7, "op": "UPDATE", "ts": "...", "record": {...}, "old_record": {...}, "auth_role": "authenticated", "auth_uid": "..."}]}
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := history.ParseQuery(chi.URLParam(r, "table"), r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	versions, err := history.Versions(ctx, conn.Conn(), q)
+	switch {
+	case errors.Is(err, history.ErrTableNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, history.ErrInvalidKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		logger.Error("failed to read change history", "table", q.Table, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []history.Version{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"versions": versions})
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Every identifier and literal the REST query builder splices into SQL goes
+// through this file. Values are bound as parameters wherever PostgreSQL
+// allows it; names can't be, so they're validated and quoted here instead.
+
+// maxIdentifierLength is PostgreSQL's NAMEDATALEN - 1. Longer names are
+// silently truncated by the server, so they're rejected instead.
+const maxIdentifierLength = 63
+
+// validateIdentifier checks that a table or column name from a request can
+// name a PostgreSQL object. Any other character is allowed, since the name
+// is always quoted.
+func validateIdentifier(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("empty identifier")
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("identifier %q is longer than %d bytes", name, maxIdentifierLength)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("identifier %q is not valid UTF-8", name)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("identifier %q contains a NUL byte", name)
+	}
+	return nil
+}
+
+// IdentifierCase says how the table and column names in REST requests map
+// to PostgreSQL identifiers. The result is always quoted, so it must match
+// the table or column name exactly.
+type IdentifierCase string
+
+const (
+	// IdentifierCaseExact uses names as written, so camelCase columns such
+	// as Prisma's "createdAt" are reached as createdAt.
+	IdentifierCaseExact IdentifierCase = "exact"
+	// IdentifierCaseFold lowercases names, as PostgreSQL folds unquoted
+	// identifiers: createdAt reaches "createdat".
+	IdentifierCaseFold IdentifierCase = "fold"
+	// IdentifierCaseSnake maps camelCase names to snake_case columns:
+	// createdAt reaches "created_at". Response keys are camelCase to match.
+	IdentifierCaseSnake IdentifierCase = "snake_case"
+)
+
+// name maps a table or column name from a request.
+func (c IdentifierCase) name(name string) string {
+	switch c {
+	case IdentifierCaseFold:
+		return strings.ToLower(name)
+	case IdentifierCaseSnake:
+		return snakeCase(name)
+	default:
+		return name
+	}
+}
+
+// column maps a column reference from a request. Only the column of a JSON
+// path is mapped: the keys after the arrow in data->>firstName are JSON.
+func (c IdentifierCase) column(key string) string {
+	if i := strings.Index(key, "->"); i >= 0 {
+		return c.name(key[:i]) + key[i:]
+	}
+	return c.name(key)
+}
+
+// list maps each name of a comma-separated column list.
+func (c IdentifierCase) list(list string) string {
+	items := strings.Split(list, ",")
+	for i, item := range items {
+		items[i] = c.name(strings.TrimSpace(item))
+	}
+	return strings.Join(items, ",")
+}
+
+// snakeCase converts a camelCase name to snake_case: createdAt becomes
+// created_at, and userID and HTTPStatus become user_id and http_status.
+// Names without capitals are returned as they are.
+func snakeCase(name string) string {
+	if strings.IndexFunc(name, unicode.IsUpper) < 0 {
+		return name
+	}
+
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// quoteIdentifier quotes a SQL identifier for PostgreSQL.
+// Identifiers with spaces or special characters need to be double-quoted.
+// Double quotes within the identifier are escaped by doubling them.
+func quoteIdentifier(ident string) string {
+	// Escape existing double quotes by doubling them
+	escaped := strings.ReplaceAll(ident, "\"", "\"\"")
+	// Wrap in double quotes
+	return fmt.Sprintf("\"%s\"", escaped)
+}
+
+// quoteLiteral quotes a string constant, such as a JSON key, for places a
+// bind parameter can't be used. This relies on standard_conforming_strings,
+// which has been on by default since PostgreSQL 9.1, so backslashes are
+// ordinary characters.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteIdentifierList validates and quotes a comma-separated list of column
+// names, as taken by on_conflict.
+func quoteIdentifierList(list string) (string, error) {
+	names := strings.Split(list, ",")
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if err := validateIdentifier(name); err != nil {
+			return "", err
+		}
+		quoted = append(quoted, quoteIdentifier(name))
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// columnRef builds the SQL expression for a column reference from a query
+// string, following JSON arrows: address->>city becomes "address"->>'city'.
+func columnRef(key string) string {
+	if strings.Contains(key, "->>") {
+		parts := strings.SplitN(key, "->>", 2)
+		return quoteIdentifier(parts[0]) + "->>" + quoteLiteral(parts[1])
+	}
+	if strings.Contains(key, "->") {
+		parts := strings.SplitN(key, "->", 2)
+		return quoteIdentifier(parts[0]) + "->" + quoteLiteral(parts[1])
+	}
+	return quoteIdentifier(key)
+}
+
+// parseRowCount parses a limit or offset value.
+func parseRowCount(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
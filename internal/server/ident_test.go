@@ -0,0 +1,359 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// injectionSeeds are fuzz seeds aimed at breaking out of quoting.
+var injectionSeeds = []string{
+	"name",
+	"first name",
+	`a"b`,
+	`"; DROP TABLE users; --`,
+	`x" ASC; DELETE FROM t; --`,
+	"it's",
+	`'; SELECT pg_sleep(10); --`,
+	`\'`,
+	"naïve",
+	"日本語",
+	"emoji😀",
+	"a;b",
+	"col.desc",
+	"col DESC; DROP TABLE t",
+	"data->>key'); --",
+	"data->'",
+	"\x00",
+	"\xff\xfe",
+}
+
+// scanQuoted consumes one PostgreSQL quoted token from the start of sql: an
+// identifier when quote is a double quote, a string constant when it's a
+// single quote. It returns the unescaped contents and whatever follows the
+// closing quote.
+func scanQuoted(sql string, quote byte) (content, rest string, ok bool) {
+	if len(sql) == 0 || sql[0] != quote {
+		return "", "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			b.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), sql[i+1:], true
+	}
+	return "", "", false
+}
+
+func FuzzQuoteIdentifier(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		content, rest, ok := scanQuoted(quoteIdentifier(name), '"')
+		if !ok || rest != "" || content != name {
+			t.Fatalf("quoteIdentifier(%q) = %s, which doesn't round-trip as a single identifier", name, quoteIdentifier(name))
+		}
+	})
+}
+
+func FuzzQuoteLiteral(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		content, rest, ok := scanQuoted(quoteLiteral(s), '\'')
+		if !ok || rest != "" || content != s {
+			t.Fatalf("quoteLiteral(%q) = %s, which doesn't round-trip as a single literal", s, quoteLiteral(s))
+		}
+	})
+}
+
+func FuzzBuildOrderClause(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, order string) {
+		clause, err := buildOrderClause(order, IdentifierCaseExact)
+		if err != nil {
+			return
+		}
+		_, rest, ok := scanQuoted(clause, '"')
+		if !ok || (rest != "" && rest != " ASC" && rest != " DESC") {
+			t.Fatalf("buildOrderClause(%q) = %s, want a quoted column and an optional direction", order, clause)
+		}
+	})
+}
+
+// scanSelectColumn consumes one column expression buildSelectColumn
+// returns from the start of expr: a quoted column, optionally followed by a
+// JSON key literal and a quoted alias. It returns whatever follows.
+func scanSelectColumn(expr string) (rest string, ok bool) {
+	_, rest, ok = scanQuoted(expr, '"')
+	if !ok {
+		return "", false
+	}
+	// A JSON path: "col"->'key' AS "key" or "col"->>'key' AS "key"
+	arrow := ""
+	for _, a := range []string{"->>", "->"} {
+		if strings.HasPrefix(rest, a) {
+			arrow = a
+			break
+		}
+	}
+	if arrow == "" {
+		return rest, true
+	}
+	_, rest, ok = scanQuoted(rest[len(arrow):], '\'')
+	if !ok || !strings.HasPrefix(rest, " AS ") {
+		return "", false
+	}
+	_, rest, ok = scanQuoted(rest[len(" AS "):], '"')
+	return rest, ok
+}
+
+func FuzzBuildSelectColumn(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, col string) {
+		expr, err := buildSelectColumn(col)
+		if err != nil || expr == "*" {
+			return
+		}
+		if rest, ok := scanSelectColumn(expr); !ok || rest != "" {
+			t.Fatalf("buildSelectColumn(%q) = %s, want a quoted column, or a JSON path with a quoted alias", col, expr)
+		}
+	})
+}
+
+func FuzzEmbeddedColumns(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Add(`";drop`)
+	f.Add(`id,";drop table users;--`)
+	f.Fuzz(func(t *testing.T, cols string) {
+		// select=*,author(<cols>)
+		_, embedded := parseSelectClause("*,author(" + cols + ")")
+		if len(embedded) != 1 {
+			return
+		}
+		list, err := embeddedColumns(embedded[0].columns)
+		if err != nil {
+			return
+		}
+		// t.<column>, t.<column>, ...
+		for rest := list; ; {
+			if !strings.HasPrefix(rest, "t.") {
+				t.Fatalf("embeddedColumns(%q) = %s, want t.-qualified columns", embedded[0].columns, list)
+			}
+			rest = rest[len("t."):]
+			if strings.HasPrefix(rest, "*") {
+				rest = rest[1:]
+			} else {
+				var ok bool
+				if rest, ok = scanSelectColumn(rest); !ok {
+					t.Fatalf("embeddedColumns(%q) = %s, want quoted columns", embedded[0].columns, list)
+				}
+			}
+			if rest == "" {
+				break
+			}
+			if !strings.HasPrefix(rest, ", ") {
+				t.Fatalf("embeddedColumns(%q) = %s, want only columns separated by commas", embedded[0].columns, list)
+			}
+			rest = rest[len(", "):]
+		}
+	})
+}
+
+func TestEmbeddedColumns(t *testing.T) {
+	tests := []struct {
+		columns string
+		want    string
+		wantErr bool
+	}{
+		{"", "t.*", false},
+		{"*", "t.*", false},
+		{"id, name", `t."id", t."name"`, false},
+		{`";drop`, `t.""";drop"`, false},
+		{"data->>city", `t."data"->>'city' AS "city"`, false},
+		{"id,", "", true},
+		{strings.Repeat("x", 64), "", true},
+	}
+	for _, tt := range tests {
+		got, err := embeddedColumns(tt.columns)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("embeddedColumns(%q) = %s, %v; want %s, error: %v", tt.columns, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	for _, name := range []string{"users", "first name", `a"b`, "naïve", strings.Repeat("x", 63)} {
+		if err := validateIdentifier(name); err != nil {
+			t.Errorf("validateIdentifier(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", strings.Repeat("x", 64), "a\x00b", "\xff"} {
+		if err := validateIdentifier(name); err == nil {
+			t.Errorf("validateIdentifier(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestBuildOrderClause(t *testing.T) {
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"name", `"name"`},
+		{"name.desc", `"name" DESC`},
+		{"created_at.ASC", `"created_at" ASC`},
+		{"another column.desc", `"another column" DESC`},
+		{"name DESC", `"name" DESC`},
+		{"first name", `"first name"`},
+		{"name.nullsfirst", `"name.nullsfirst"`},
+		{"x ASC; DROP TABLE t", `"x ASC; DROP TABLE t"`},
+	}
+
+	for _, tt := range tests {
+		got, err := buildOrderClause(tt.order, IdentifierCaseExact)
+		if err != nil {
+			t.Errorf("buildOrderClause(%q) failed: %v", tt.order, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("buildOrderClause(%q) = %s, want %s", tt.order, got, tt.want)
+		}
+	}
+
+	if _, err := buildOrderClause(".desc", IdentifierCaseExact); err == nil {
+		t.Error("buildOrderClause(.desc) succeeded, want error")
+	}
+}
+
+func TestBuildSelectColumn(t *testing.T) {
+	tests := []struct {
+		col  string
+		want string
+	}{
+		{"*", "*"},
+		{" name ", `"name"`},
+		{"address->>city", `"address"->>'city' AS "city"`},
+		{"data->it's", `"data"->'it''s' AS "it's"`},
+	}
+
+	for _, tt := range tests {
+		got, err := buildSelectColumn(tt.col)
+		if err != nil {
+			t.Errorf("buildSelectColumn(%q) failed: %v", tt.col, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("buildSelectColumn(%q) = %s, want %s", tt.col, got, tt.want)
+		}
+	}
+
+	if _, err := buildSelectColumn("data->>"); err == nil {
+		t.Error("buildSelectColumn(data->>) succeeded, want error")
+	}
+}
+
+func TestQuoteIdentifierList(t *testing.T) {
+	got, err := quoteIdentifierList("org_id, user id")
+	if err != nil {
+		t.Fatalf("quoteIdentifierList() failed: %v", err)
+	}
+	if want := `"org_id", "user id"`; got != want {
+		t.Errorf("quoteIdentifierList() = %s, want %s", got, want)
+	}
+	if _, err := quoteIdentifierList("id,"); err == nil {
+		t.Error("quoteIdentifierList(id,) succeeded, want error")
+	}
+}
+
+func TestBuildWhereClause_QuotesJSONKeys(t *testing.T) {
+	s := &Server{}
+	clause, args, err := s.buildWhereClause(url.Values{"data->>k'; DROP TABLE t; --": {"eq.1"}}, 0)
+	if err != nil {
+		t.Fatalf("buildWhereClause() failed: %v", err)
+	}
+	if want := `"data"->>'k''; DROP TABLE t; --' = $1`; clause != want {
+		t.Errorf("clause = %s, want %s", clause, want)
+	}
+	if len(args) != 1 || args[0] != "1" {
+		t.Errorf("args = %v, want [1]", args)
+	}
+}
+
+func TestParseRowCount(t *testing.T) {
+	if n, err := parseRowCount("limit", "10"); err != nil || n != 10 {
+		t.Errorf("parseRowCount(10) = %d, %v", n, err)
+	}
+	for _, value := range []string{"-1", "10; DROP TABLE t", "1e3", ""} {
+		if _, err := parseRowCount("limit", value); err == nil {
+			t.Errorf("parseRowCount(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"id":          "id",
+		"created_at":  "created_at",
+		"createdAt":   "created_at",
+		"userID":      "user_id",
+		"HTTPStatus":  "http_status",
+		"address2Zip": "address2_zip",
+		"_createdAt":  "_created_at",
+		"my_ColName":  "my_col_name",
+		"straßeName":  "straße_name",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestIdentifierCase_MixedCaseSchema maps the names of a table with a
+// Prisma-style camelCase column, createdAt, in each mode.
+func TestIdentifierCase_MixedCaseSchema(t *testing.T) {
+	tests := []struct {
+		names  IdentifierCase
+		column string // createdAt
+		path   string // data->>firstName
+		order  string // createdAt.desc
+	}{
+		{IdentifierCaseExact, `"createdAt"`, `"data"->>'firstName' AS "firstName"`, `"createdAt" DESC`},
+		{"", `"createdAt"`, `"data"->>'firstName' AS "firstName"`, `"createdAt" DESC`},
+		{IdentifierCaseFold, `"createdat"`, `"data"->>'firstName' AS "firstName"`, `"createdat" DESC`},
+		{IdentifierCaseSnake, `"created_at"`, `"data"->>'firstName' AS "firstName"`, `"created_at" DESC`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.names), func(t *testing.T) {
+			if got, _ := buildSelectColumn(tt.names.column("createdAt")); got != tt.column {
+				t.Errorf("select createdAt = %s, want %s", got, tt.column)
+			}
+			if got, _ := buildSelectColumn(tt.names.column("data->>firstName")); got != tt.path {
+				t.Errorf("select data->>firstName = %s, want %s", got, tt.path)
+			}
+			if got, _ := buildOrderClause("createdAt.desc", tt.names); got != tt.order {
+				t.Errorf("order createdAt.desc = %s, want %s", got, tt.order)
+			}
+			if got, _ := parseOnConflict("createdAt", tt.names); len(got) != 1 || quoteIdentifier(got[0]) != tt.column {
+				t.Errorf("on_conflict createdAt = %v, want %s", got, tt.column)
+			}
+		})
+	}
+}
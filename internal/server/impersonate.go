@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/markb/supalite/internal/auth"
+)
+
+// requestToken extracts the JWT from the Authorization header, falling back
+// to the apikey header used by Supabase client libraries.
+func requestToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		const bearerPrefix = "Bearer "
+		if len(authHeader) > len(bearerPrefix) && strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(authHeader[len(bearerPrefix):])
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("apikey"))
+}
+
+// requireServiceRole wraps a handler so it only runs for callers presenting
+// a valid token with the service_role claim.
+func (s *Server) requireServiceRole(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenString := requestToken(r)
+		if tokenString == "" {
+			http.Error(w, "missing service_role key", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := s.keyManager.VerifyToken(tokenString)
+		if err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		if role, _ := token.Get("role"); role != "service_role" {
+			http.Error(w, "service_role key required", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleImpersonate mints a short-lived access token for an existing auth user.
+//
+// POST /admin/v1/impersonate
+//
+// Requires the service_role key. Request body:
+//
+//	{"email": "user@example.com", "expires_in": 3600}
+//
+// or {"user_id": "<uuid>"}. The returned access_token can be used as the
+// Authorization bearer token to exercise RLS policies as that user.
+func (s *Server) handleImpersonate(w http.ResponseWriter, r *http.Request) {
+	var req auth.ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" && req.Email == "" {
+		http.Error(w, "user_id or email is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	result, err := auth.Impersonate(ctx, conn.Conn(), s.keyManager, req)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, auth.ErrUserBanned) {
+			http.Error(w, "user is banned", http.StatusForbidden)
+			return
+		}
+		logger.Error("impersonation failed", "error", err)
+		http.Error(w, "failed to mint token", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Warn("minted impersonation token", "user_id", result.UserID, "email", result.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
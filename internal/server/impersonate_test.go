@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/keys"
+)
+
+func TestRequireServiceRole(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	userToken, err := keyManager.GenerateUserToken(map[string]interface{}{
+		"sub":  "6f7d1c2e-0000-4000-8000-000000000001",
+		"role": "authenticated",
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateUserToken() failed: %v", err)
+	}
+	other, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+
+	s := &Server{keyManager: keyManager}
+	handler := s.requireServiceRole(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name     string
+		header   string
+		token    string
+		wantCode int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"malformed token", "Authorization", "not-a-jwt", http.StatusUnauthorized},
+		{"another project's service key", "Authorization", other.GetServiceKey(), http.StatusUnauthorized},
+		{"anon key", "apikey", keyManager.GetAnonKey(), http.StatusForbidden},
+		{"anon bearer", "Authorization", keyManager.GetAnonKey(), http.StatusForbidden},
+		{"authenticated user", "Authorization", userToken, http.StatusForbidden},
+		{"service key", "Authorization", keyManager.GetServiceKey(), http.StatusOK},
+		{"service apikey", "apikey", keyManager.GetServiceKey(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/v1/impersonate", nil)
+			switch tt.header {
+			case "Authorization":
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			case "apikey":
+				req.Header.Set("apikey", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/admin"
+	"github.com/markb/supalite/internal/backup"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/migrate"
+	"github.com/markb/supalite/internal/pg"
+)
+
+// managementOpenAPI describes the management API, for generating clients
+// in other languages.
+//
+//go:embed manage_openapi.json
+var managementOpenAPI []byte
+
+// backupName matches the backups the management API makes, so a name from
+// a request can't reach outside the backups directory.
+var backupName = regexp.MustCompile(`^supalite-[0-9]{8}-[0-9]{6}\.dump$`)
+
+// mountManagementAPI registers the management API: the operations the
+// CLI offers on a stopped server (status, backups, migrations, keys, and
+// dashboard admins), for a running one. Every route needs the
+// service_role key. Auth users are managed with GoTrue's admin API at
+// /auth/v1/admin/users.
+func (s *Server) mountManagementAPI(r chi.Router) {
+	r.Get("/admin/v1/openapi.json", s.requireServiceRole(s.handleManagementOpenAPI))
+	r.Get("/admin/v1/status", s.requireServiceRole(s.handleManagementStatus))
+
+	r.Get("/admin/v1/backups", s.requireServiceRole(s.handleListBackups))
+	r.Post("/admin/v1/backups", s.requireServiceRole(s.handleCreateBackup))
+	r.Get("/admin/v1/backups/{name}", s.requireServiceRole(s.handleDownloadBackup))
+
+	r.Get("/admin/v1/migrations", s.requireServiceRole(s.handleListMigrations))
+	r.Post("/admin/v1/migrations/up", s.requireServiceRole(s.handleMigrationsUp))
+
+	r.Post("/admin/v1/keys/rotate", s.requireServiceRole(s.handleRotateKeys))
+	r.Post("/admin/v1/keys/regenerate", s.requireServiceRole(s.handleRegenerateKeys))
+
+	r.Get("/admin/v1/admins", s.requireServiceRole(s.handleListAdmins))
+	r.Post("/admin/v1/admins", s.requireServiceRole(s.handleCreateAdmin))
+	r.Delete("/admin/v1/admins/{email}", s.requireServiceRole(s.handleDeleteAdmin))
+}
+
+// handleManagementOpenAPI serves the OpenAPI 3 description of the
+// management API.
+//
+// GET /admin/v1/openapi.json
+func (s *Server) handleManagementOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(managementOpenAPI)
+}
+
+// managementStatus is the response of GET /admin/v1/status.
+type managementStatus struct {
+	StartedAt     time.Time          `json:"started_at"`
+	UptimeSeconds int64              `json:"uptime_seconds"`
+	Components    map[string]string  `json:"components"` // "ok", or what is wrong
+	Migrations    *migrationCounts   `json:"migrations,omitempty"`
+	Database      managementDatabase `json:"database"`
+}
+
+// managementDatabase tells where the PostgreSQL the APIs use listens.
+type managementDatabase struct {
+	External bool   `json:"external,omitempty"` // Given by database_url
+	Port     uint16 `json:"port,omitempty"`     // TCP port on localhost
+	Socket   string `json:"socket,omitempty"`   // Unix socket, when it listens on that only
+}
+
+type migrationCounts struct {
+	Applied int `json:"applied"`
+	Pending int `json:"pending"`
+}
+
+// handleManagementStatus checks each API as the status page does, and
+// counts the migrations applied and pending.
+//
+// GET /admin/v1/status
+//
+// Requires the service_role key. Response:
+//
+//	{"started_at": "...", "uptime_seconds": 3600,
+//	 "components": {"rest": "ok", "auth": "auth server is not running", ...},
+//	 "migrations": {"applied": 4, "pending": 0},
+//	 "database": {"socket": "/run/supalite/.s.PGSQL.5432"}}
+func (s *Server) handleManagementStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := managementStatus{
+		StartedAt:     s.startedAt,
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+		Components:    map[string]string{},
+	}
+	switch {
+	case s.config.DatabaseURL != "":
+		status.Database.External = true
+	case s.config.PGSocketDir != "":
+		status.Database.Socket = pg.SocketPath(s.config.PGSocketDir, s.config.PGPort)
+	default:
+		status.Database.Port = s.config.PGPort
+	}
+	for _, check := range s.statusChecks() {
+		if err := check.Probe(ctx); err != nil {
+			status.Components[check.Name] = err.Error()
+		} else {
+			status.Components[check.Name] = "ok"
+		}
+	}
+
+	if dir := s.migrationsDir(); dir != "" {
+		if migrations, err := s.migrationStatus(r, dir); err == nil {
+			status.Migrations = &migrationCounts{}
+			for _, m := range migrations {
+				if m.Applied {
+					status.Migrations.Applied++
+				} else {
+					status.Migrations.Pending++
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// backupInfo is a backup made with POST /admin/v1/backups.
+type backupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backupsDir is where the management API keeps backups.
+func (s *Server) backupsDir() string {
+	return filepath.Join(s.config.DataDir, "backups")
+}
+
+// handleListBackups lists the backups made with POST /admin/v1/backups,
+// newest first.
+//
+// GET /admin/v1/backups
+//
+// Requires the service_role key. Response:
+//
+//	[{"name": "supalite-20260101-120000.dump", "size": 48213, "created_at": "..."}]
+func (s *Server) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	entries, err := os.ReadDir(s.backupsDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("failed to list backups: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	backups := []backupInfo{}
+	for _, entry := range entries {
+		if !backupName.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupInfo{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backups)
+}
+
+// handleCreateBackup makes a full backup with pg_dump, in its custom
+// format, as supalite db dump --format custom does. It can be restored
+// with supalite db restore.
+//
+// POST /admin/v1/backups
+//
+// Requires the service_role key. Responds 201 with the backup, as listed
+// by GET /admin/v1/backups.
+func (s *Server) handleCreateBackup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	target, err := backup.TargetFor(ctx, conn.Conn(), s.config.DataDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.MkdirAll(s.backupsDir(), 0700); err != nil {
+		http.Error(w, fmt.Sprintf("failed to create backups directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+	name := "supalite-" + time.Now().UTC().Format("20060102-150405") + ".dump"
+	path := filepath.Join(s.backupsDir(), name)
+
+	// The backup holds password hashes and keys, so keep it private.
+	// pg_dump writes into the file as it finds it.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			http.Error(w, "a backup was made this second, try again", http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("failed to create %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+
+	if err := backup.Dump(ctx, target, backup.DumpOptions{Format: backup.FormatCustom, Out: path}); err != nil {
+		os.Remove(path)
+		logger.Error("backup failed", "error", err)
+		http.Error(w, fmt.Sprintf("backup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("backup made", "file", path, "size", info.Size())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(backupInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+}
+
+// handleDownloadBackup sends a backup made with POST /admin/v1/backups.
+//
+// GET /admin/v1/backups/{name}
+//
+// Requires the service_role key.
+func (s *Server) handleDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !backupName.MatchString(name) {
+		http.Error(w, "backup not found", http.StatusNotFound)
+		return
+	}
+	path := filepath.Join(s.backupsDir(), name)
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "backup not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}
+
+// migrationsDir returns the directory the management API reads
+// migrations from.
+func (s *Server) migrationsDir() string {
+	if s.config.ManagedMigrationsDir != "" {
+		return s.config.ManagedMigrationsDir
+	}
+	if s.config.MigrationsDir != "" {
+		return s.config.MigrationsDir
+	}
+	return migrate.FindDir()
+}
+
+// migrationStatus returns the migrations in dir and whether each is
+// applied.
+func (s *Server) migrationStatus(r *http.Request, dir string) ([]migrate.Migration, error) {
+	conn, err := s.pgDatabase.Acquire(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	return migrate.Status(r.Context(), conn.Conn(), dir)
+}
+
+// handleListMigrations lists the migrations and whether each is applied,
+// as supalite migrate status does.
+//
+// GET /admin/v1/migrations
+//
+// Requires the service_role key. Response:
+//
+//	[{"version": "20260101120000", "name": "create_todos", "applied": true}]
+func (s *Server) handleListMigrations(w http.ResponseWriter, r *http.Request) {
+	migrations, err := s.migrationStatus(r, s.migrationsDir())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read migrations: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if migrations == nil {
+		migrations = []migrate.Migration{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(migrations)
+}
+
+// handleMigrationsUp applies the pending migrations, oldest first, as
+// supalite migrate up does.
+//
+// POST /admin/v1/migrations/up
+//
+// Requires the service_role key. Optional body:
+//
+//	{"count": 1}    // Apply only the first pending migrations
+//
+// Response: the migrations applied. When one fails, the response is 500
+// and the error; those applied before it are kept.
+func (s *Server) handleMigrationsUp(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Count int `json:"count"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Count < 0 {
+		http.Error(w, "count must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	applied, err := migrate.Up(ctx, conn.Conn(), s.migrationsDir(), req.Count)
+	for _, m := range applied {
+		logger.Info("applied migration", "file", filepath.Base(m.Path))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if applied == nil {
+		applied = []migrate.Migration{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applied)
+}
+
+// storedKeys loads the keys in keys.json into a manager of their own,
+// for rotating or regenerating them: the server keeps signing and
+// verifying with the keys it started with until it is restarted.
+func (s *Server) storedKeys(operation string) (*keys.Manager, error) {
+	if s.config.JWTSecret != "" {
+		return nil, fmt.Errorf("%s is not available in legacy mode (--jwt-secret)", operation)
+	}
+	if s.config.Deterministic {
+		return nil, fmt.Errorf("%s is not available in deterministic mode", operation)
+	}
+	if s.config.Ephemeral {
+		return nil, fmt.Errorf("%s is not available in ephemeral mode", operation)
+	}
+	return keys.NewManager(s.config.DataDir, "")
+}
+
+// keysResponse is the response of the key endpoints.
+type keysResponse struct {
+	AnonKey         string         `json:"anon_key"`
+	ServiceRoleKey  string         `json:"service_role_key"`
+	PublishableKey  string         `json:"publishable_key"`
+	SecretKey       string         `json:"secret_key"`
+	Rotation        *keys.Rotation `json:"rotation,omitempty"`
+	Revoked         int            `json:"revoked,omitempty"`
+	RestartRequired bool           `json:"restart_required"`
+}
+
+func newKeysResponse(m *keys.Manager) keysResponse {
+	return keysResponse{
+		AnonKey:         m.GetAnonKey(),
+		ServiceRoleKey:  m.GetServiceKey(),
+		PublishableKey:  m.GetPublishableKey(),
+		SecretKey:       m.GetSecretKey(),
+		RestartRequired: true,
+	}
+}
+
+// handleRotateKeys replaces the signing key, as supalite keys rotate
+// does. The server signs with the new key once restarted.
+//
+// POST /admin/v1/keys/rotate
+//
+// Requires the service_role key. Optional body:
+//
+//	{"grace_period_seconds": 86400}    // How long the old key keeps verifying (default: keys.DefaultGracePeriod)
+//
+// Response: the new API keys and the rotation.
+func (s *Server) handleRotateKeys(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		GracePeriodSeconds *int64 `json:"grace_period_seconds"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	grace := keys.DefaultGracePeriod
+	if req.GracePeriodSeconds != nil {
+		if *req.GracePeriodSeconds < 0 {
+			http.Error(w, "grace_period_seconds must not be negative", http.StatusBadRequest)
+			return
+		}
+		grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	manager, err := s.storedKeys("key rotation")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	rotation, err := manager.Rotate(grace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Warn("signing key rotated, restart to sign with it", "old_key_id", rotation.OldKeyID, "new_key_id", rotation.NewKeyID)
+
+	resp := newKeysResponse(manager)
+	resp.Rotation = rotation
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRegenerateKeys replaces a role's API keys, as supalite keys
+// regenerate does. The server hands out the new keys once restarted.
+//
+// POST /admin/v1/keys/regenerate
+//
+// Requires the service_role key. Body:
+//
+//	{"role": "anon", "revoke": false}    // role: anon or service_role; revoke: old keys stop working
+//
+// Response: the API keys, with the role's new ones.
+func (s *Server) handleRegenerateKeys(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role   string `json:"role"`
+		Revoke bool   `json:"revoke"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Role != "anon" && req.Role != "service_role" {
+		http.Error(w, `role must be "anon" or "service_role"`, http.StatusBadRequest)
+		return
+	}
+
+	manager, err := s.storedKeys("key regeneration")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	regeneration, err := manager.Regenerate(req.Role, req.Revoke)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Warn("API keys regenerated, restart to use them", "role", regeneration.Role, "revoked", regeneration.Revoked)
+
+	resp := newKeysResponse(manager)
+	resp.Revoked = regeneration.Revoked
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleListAdmins lists the dashboard admins.
+//
+// GET /admin/v1/admins
+//
+// Requires the service_role key. Response:
+//
+//	[{"id": "...", "email": "admin@example.com", "created_at": "...", "updated_at": "..."}]
+func (s *Server) handleListAdmins(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	users, err := admin.List(ctx, conn.Conn())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// handleCreateAdmin adds a dashboard admin, as supalite admin add does.
+//
+// POST /admin/v1/admins
+//
+// Requires the service_role key. Body:
+//
+//	{"email": "admin@example.com", "password": "..."}
+//
+// Responds 201 with the admin.
+func (s *Server) handleCreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" || req.Password == "" {
+		http.Error(w, "email and password are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	user, err := admin.Create(ctx, conn.Conn(), req.Email, req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleDeleteAdmin removes a dashboard admin.
+//
+// DELETE /admin/v1/admins/{email}
+//
+// Requires the service_role key. Responds 204.
+func (s *Server) handleDeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Release()
+
+	if err := admin.Delete(ctx, conn.Conn(), chi.URLParam(r, "email")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
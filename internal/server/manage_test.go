@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/keys"
+)
+
+// newManagementRouter returns a router serving the management API of a
+// server with cfg, and the server's keys.
+func newManagementRouter(t *testing.T, cfg Config) (chi.Router, *keys.Manager) {
+	t.Helper()
+	keyManager, err := keys.NewManager(cfg.DataDir, "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{config: cfg, keyManager: keyManager}
+	r := chi.NewRouter()
+	s.mountManagementAPI(r)
+	return r, keyManager
+}
+
+func TestManagementOpenAPI_DescribesEveryRoute(t *testing.T) {
+	r, _ := newManagementRouter(t, Config{DataDir: t.TempDir()})
+
+	var routes []string
+	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(managementOpenAPI, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	var described []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			described = append(described, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(described)
+	if strings.Join(routes, "\n") != strings.Join(described, "\n") {
+		t.Errorf("routes:\n%s\n\ndescribed:\n%s", strings.Join(routes, "\n"), strings.Join(described, "\n"))
+	}
+}
+
+func TestManagementAPI_RequiresServiceRole(t *testing.T) {
+	r, keyManager := newManagementRouter(t, Config{DataDir: t.TempDir()})
+
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{keyManager.GetAnonKey(), http.StatusForbidden},
+		{keyManager.GetServiceKey(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/v1/openapi.json", nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("key %.10q: status %d, want %d", tt.key, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestManagementAPI_Backups(t *testing.T) {
+	dataDir := t.TempDir()
+	r, keyManager := newManagementRouter(t, Config{DataDir: dataDir})
+	if err := os.MkdirAll(filepath.Join(dataDir, "backups"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"supalite-20260101-120000.dump", "supalite-20260102-120000.dump", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dataDir, "backups", name), []byte("PGDMP"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+keyManager.GetServiceKey())
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/admin/v1/backups")
+	var backups []backupInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &backups); err != nil {
+		t.Fatalf("list: %v (%s)", err, rec.Body)
+	}
+	if len(backups) != 2 || backups[0].Name != "supalite-20260102-120000.dump" || backups[0].Size != 5 {
+		t.Errorf("backups = %+v, want the two dumps, newest first", backups)
+	}
+
+	if rec := get("/admin/v1/backups/supalite-20260101-120000.dump"); rec.Code != http.StatusOK || rec.Body.String() != "PGDMP" {
+		t.Errorf("download: status %d, body %q", rec.Code, rec.Body)
+	}
+	for _, name := range []string{"notes.txt", "..%2Fsecret.txt", "supalite-20260103-120000.dump"} {
+		if rec := get("/admin/v1/backups/" + name); rec.Code != http.StatusNotFound {
+			t.Errorf("download %s: status %d, want 404", name, rec.Code)
+		}
+	}
+}
+
+func TestManagementAPI_Keys(t *testing.T) {
+	dataDir := t.TempDir()
+	r, keyManager := newManagementRouter(t, Config{DataDir: dataDir})
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+keyManager.GetServiceKey())
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/admin/v1/keys/regenerate", `{"role":"admin"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("regenerate unknown role: status %d", rec.Code)
+	}
+	if rec := post("/admin/v1/keys/rotate", `{"grace_period_seconds":-1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("rotate with negative grace: status %d", rec.Code)
+	}
+
+	rec := post("/admin/v1/keys/rotate", `{"grace_period_seconds":60}`)
+	var resp keysResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("rotate: status %d, %v (%s)", rec.Code, err, rec.Body)
+	}
+	if resp.Rotation == nil || resp.AnonKey == keyManager.GetAnonKey() || !resp.RestartRequired {
+		t.Errorf("rotate response = %+v", resp)
+	}
+	// The running server keeps its keys until restarted
+	if _, err := keyManager.VerifyToken(keyManager.GetServiceKey()); err != nil {
+		t.Errorf("current key no longer verifies: %v", err)
+	}
+	stored, err := keys.NewManager(dataDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetAnonKey() != resp.AnonKey {
+		t.Error("rotated keys were not saved to keys.json")
+	}
+
+	legacy := &Server{config: Config{DataDir: t.TempDir(), JWTSecret: "super-secret-jwt-token-with-at-least-32-characters"}}
+	if _, err := legacy.storedKeys("key rotation"); err == nil || !strings.Contains(err.Error(), "legacy mode") {
+		t.Errorf("storedKeys() in legacy mode: %v", err)
+	}
+}
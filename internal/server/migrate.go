@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/markb/supalite/internal/migrate"
+)
+
+// applyMigrations applies the pending migrations in MigrationsDir. A
+// failing migration stops startup: serving a schema the application
+// doesn't expect would only move the failure to its requests.
+func (s *Server) applyMigrations(ctx context.Context) error {
+	conn, err := s.pgDatabase.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	defer conn.Release()
+
+	applied, err := migrate.Up(ctx, conn.Conn(), s.config.MigrationsDir, 0)
+	for _, m := range applied {
+		logger.Info("applied migration", "file", filepath.Base(m.Path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations from %s: %w", s.config.MigrationsDir, err)
+	}
+	logger.Info("migrations up to date", "dir", s.config.MigrationsDir, "applied", len(applied))
+	return nil
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markb/supalite/internal/notify"
+	"github.com/markb/supalite/internal/watchdog"
+)
+
+// Defaults for the repeated auth failure alert.
+const (
+	DefaultAuthFailureThreshold = 20
+	DefaultAuthFailureWindow    = 5 * time.Minute
+)
+
+// maxFailureClients bounds the clients an authFailureCounter tracks.
+const maxFailureClients = 10000
+
+// notifyGoTrueExit reports a GoTrue crash and the outcome of restarting it.
+func (s *Server) notifyGoTrueExit(exitErr, restartErr error) {
+	details := map[string]string{"exit": exitErr.Error()}
+	if restartErr != nil {
+		details["restart_error"] = restartErr.Error()
+		s.config.Notifier.Notify(notify.Event{
+			Kind:      notify.KindRestartFailed,
+			Severity:  notify.SeverityCritical,
+			Component: "gotrue",
+			Message:   "GoTrue crashed and could not be restarted; the auth API is down",
+			Details:   details,
+		})
+		return
+	}
+	s.config.Notifier.Notify(notify.Event{
+		Kind:      notify.KindComponentRestarted,
+		Severity:  notify.SeverityWarning,
+		Component: "gotrue",
+		Message:   "GoTrue crashed and was restarted",
+		Details:   details,
+	})
+}
+
+// notifyWatchdogAlert reports the watchdog's disk threshold crossings.
+// Memory and WAL warnings stay in the log.
+func (s *Server) notifyWatchdogAlert(a watchdog.Alert) {
+	event := notify.Event{Component: "disk", Message: a.Message, Details: map[string]string{
+		"free_mb":  strconv.FormatUint(a.Stats.DiskFree>>20, 10),
+		"total_mb": strconv.FormatUint(a.Stats.DiskTotal>>20, 10),
+	}}
+	switch a.Kind {
+	case watchdog.AlertDiskLow:
+		event.Kind, event.Severity = notify.KindDiskLow, notify.SeverityWarning
+	case watchdog.AlertDiskExhausted:
+		event.Kind, event.Severity = notify.KindDiskFull, notify.SeverityCritical
+	case watchdog.AlertDiskRecovered:
+		event.Kind, event.Severity = notify.KindDiskRecovered, notify.SeverityInfo
+	default:
+		return
+	}
+	s.config.Notifier.Notify(event)
+}
+
+// trackAuthFailures counts responses that reject a client's credentials,
+// and alerts when one client collects AuthFailureThreshold of them within
+// AuthFailureWindow: likely a leaked key being probed or a password being
+// guessed.
+func (s *Server) trackAuthFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		if !isAuthFailure(r, sw.status) {
+			return
+		}
+		client := clientAddr(r)
+		if count, tripped := s.authFailures.add(client, time.Now()); tripped {
+			s.config.Notifier.Notify(notify.Event{
+				Kind:      notify.KindAuthFailures,
+				Severity:  notify.SeverityWarning,
+				Component: "auth",
+				Message:   fmt.Sprintf("%d failed authentications from %s", count, client),
+				Details: map[string]string{
+					"client": client,
+					"window": s.authFailures.window.String(),
+					"path":   r.URL.Path,
+				},
+			})
+		}
+	})
+}
+
+// isAuthFailure reports whether a response rejected credentials: a 401
+// anywhere, or a failed sign-in (GoTrue answers bad passwords with 400).
+func isAuthFailure(r *http.Request, status int) bool {
+	if status == http.StatusUnauthorized {
+		return true
+	}
+	return status == http.StatusBadRequest && r.Method == http.MethodPost &&
+		strings.TrimSuffix(r.URL.Path, "/") == "/auth/v1/token"
+}
+
+// clientAddr returns the host part of the request's remote address.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authFailureCounter counts failures per client in fixed windows.
+type authFailureCounter struct {
+	threshold int
+	window    time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*failureWindow
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+func newAuthFailureCounter(threshold int, window time.Duration) *authFailureCounter {
+	if threshold <= 0 {
+		threshold = DefaultAuthFailureThreshold
+	}
+	if window <= 0 {
+		window = DefaultAuthFailureWindow
+	}
+	return &authFailureCounter{threshold: threshold, window: window, clients: map[string]*failureWindow{}}
+}
+
+// add records a failure. tripped is true when it brings the client's
+// count in the current window to the threshold.
+func (c *authFailureCounter) add(client string, now time.Time) (count int, tripped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fw, ok := c.clients[client]
+	if !ok || now.Sub(fw.start) >= c.window {
+		if !ok && len(c.clients) >= maxFailureClients {
+			c.prune(now)
+		}
+		fw = &failureWindow{start: now}
+		c.clients[client] = fw
+	}
+	fw.count++
+	return fw.count, fw.count == c.threshold
+}
+
+// prune drops clients whose window has ended.
+func (c *authFailureCounter) prune(now time.Time) {
+	for client, fw := range c.clients {
+		if now.Sub(fw.start) >= c.window {
+			delete(c.clients, client)
+		}
+	}
+}
+
+// statusWriter records the status of a response. It passes Flush and
+// Hijack through, so streaming and WebSocket upgrades keep working.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64 // Body bytes written
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/notify"
+)
+
+// eventRecorder is a notify.Channel that keeps the events sent to it.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (c *eventRecorder) Name() string { return "recorder" }
+
+func (c *eventRecorder) Send(ctx context.Context, e notify.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, e)
+	return nil
+}
+
+func TestTrackAuthFailures(t *testing.T) {
+	rec := &eventRecorder{}
+	notifier := notify.New(notify.Config{Channels: []notify.Channel{rec}})
+	s := &Server{
+		config:       Config{Notifier: notifier},
+		authFailures: newAuthFailureCounter(3, time.Minute),
+	}
+	handler := s.trackAuthFailures(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apikey") != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	send := func(addr, key string) {
+		req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("apikey", key)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send("10.0.0.1:1000", "bad")
+	send("10.0.0.1:1001", "bad")
+	send("10.0.0.2:1000", "bad") // another client
+	send("10.0.0.1:1002", "good")
+	notifier.Wait()
+	if len(rec.events) != 0 {
+		t.Fatalf("alert sent before the threshold: %+v", rec.events)
+	}
+
+	send("10.0.0.1:1003", "bad")
+	notifier.Wait()
+	if len(rec.events) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(rec.events))
+	}
+	if e := rec.events[0]; e.Kind != notify.KindAuthFailures || e.Details["client"] != "10.0.0.1" {
+		t.Errorf("alert = %+v", e)
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		status int
+		want   bool
+	}{
+		{http.MethodGet, "/rest/v1/todos", http.StatusUnauthorized, true},
+		{http.MethodGet, "/rest/v1/todos", http.StatusForbidden, false},
+		{http.MethodPost, "/auth/v1/token", http.StatusBadRequest, true},
+		{http.MethodPost, "/auth/v1/signup", http.StatusBadRequest, false},
+		{http.MethodPost, "/auth/v1/token", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		if got := isAuthFailure(httptest.NewRequest(tt.method, tt.path, nil), tt.status); got != tt.want {
+			t.Errorf("isAuthFailure(%s %s, %d) = %v, want %v", tt.method, tt.path, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestAuthFailureCounter_Window(t *testing.T) {
+	c := newAuthFailureCounter(2, time.Minute)
+	now := time.Now()
+	if _, tripped := c.add("a", now); tripped {
+		t.Fatal("tripped after one failure")
+	}
+	// The window has ended, so the count starts over
+	if _, tripped := c.add("a", now.Add(2*time.Minute)); tripped {
+		t.Fatal("tripped across windows")
+	}
+	if count, tripped := c.add("a", now.Add(2*time.Minute+time.Second)); !tripped || count != 2 {
+		t.Errorf("count = %d, tripped = %v, want 2, true", count, tripped)
+	}
+}
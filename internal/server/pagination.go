@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// estimatedCountThreshold is where Prefer: count=estimated stops counting
+// rows exactly and switches to the planner's estimate.
+const estimatedCountThreshold = 1000
+
+// rowWindow is the slice of rows a GET returns.
+type rowWindow struct {
+	offset int
+	limit  int // -1 for no limit
+}
+
+// errRangeNotSatisfiable is returned for a Range header whose first row is
+// past its last, such as 5-2.
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// requestWindow resolves the rows to return from the limit and offset
+// parameters and the Range header, as sent by supabase-js .range(). When
+// both are present, the rows in both are returned.
+func requestWindow(r *http.Request, query url.Values) (rowWindow, error) {
+	window, err := paramWindow(query)
+	if err != nil {
+		return window, err
+	}
+
+	from, to, ok, err := parseRangeHeader(r)
+	if err != nil || !ok {
+		return window, err
+	}
+
+	// Intersect [from, to] with the parameters' window
+	end := to
+	if window.limit >= 0 && (end < 0 || window.offset+window.limit-1 < end) {
+		end = window.offset + window.limit - 1
+	}
+	window.offset = max(window.offset, from)
+	if end >= 0 {
+		window.limit = max(end-window.offset+1, 0)
+	}
+	return window, nil
+}
+
+// paramWindow resolves the rows picked by the limit and offset parameters.
+func paramWindow(query url.Values) (rowWindow, error) {
+	window := rowWindow{limit: -1}
+	if limitVals := query["limit"]; len(limitVals) > 0 {
+		limit, err := parseRowCount("limit", limitVals[0])
+		if err != nil {
+			return window, err
+		}
+		window.limit = limit
+	}
+	if offsetVals := query["offset"]; len(offsetVals) > 0 {
+		offset, err := parseRowCount("offset", offsetVals[0])
+		if err != nil {
+			return window, err
+		}
+		window.offset = offset
+	}
+	return window, nil
+}
+
+// parseRangeHeader parses a Range header in items, such as 0-9 or 10- for
+// every row from the eleventh. to is -1 for an open range. Ranges in other
+// units, and malformed ones, are ignored, as HTTP requires.
+func parseRangeHeader(r *http.Request) (from, to int, ok bool, err error) {
+	header := strings.TrimSpace(r.Header.Get("Range"))
+	if header == "" {
+		return 0, 0, false, nil
+	}
+	if unit := r.Header.Get("Range-Unit"); unit != "" && unit != "items" {
+		return 0, 0, false, nil
+	}
+	header = strings.TrimPrefix(header, "items=")
+
+	start, end, found := strings.Cut(header, "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+	from, err = strconv.Atoi(start)
+	if err != nil || from < 0 {
+		return 0, 0, false, nil
+	}
+	if end == "" {
+		return from, -1, true, nil
+	}
+	to, err = strconv.Atoi(end)
+	if err != nil || to < 0 {
+		return 0, 0, false, nil
+	}
+	if to < from {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+	return from, to, true, nil
+}
+
+// countMode returns the Prefer: count= preference: exact, planned,
+// estimated, or empty if the request didn't ask for a count.
+func countMode(r *http.Request) string {
+	switch mode := preferences(r)["count"]; mode {
+	case "exact", "planned", "estimated":
+		return mode
+	default:
+		return ""
+	}
+}
+
+// countRows counts the rows of a table, given by its qualified name, that
+// match a WHERE clause. exact
+// runs COUNT(*); planned reads the planner's estimate, which is cheap but
+// approximate; estimated counts exactly up to estimatedCountThreshold rows
+// and uses the estimate beyond it.
+func countRows(ctx context.Context, tx pgx.Tx, quotedTable, whereClause string, args []interface{}, mode string) (int64, error) {
+	from := quotedTable
+	if whereClause != "" {
+		from += " WHERE " + whereClause
+	}
+
+	var count int64
+	switch mode {
+	case "planned":
+		return plannedRows(ctx, tx, "SELECT 1 FROM "+from, args)
+	case "estimated":
+		capped := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM %s LIMIT %d) AS capped", from, estimatedCountThreshold+1)
+		if err := tx.QueryRow(ctx, capped, args...).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count <= estimatedCountThreshold {
+			return count, nil
+		}
+		planned, err := plannedRows(ctx, tx, "SELECT 1 FROM "+from, args)
+		if err != nil {
+			return 0, err
+		}
+		// The estimate can't be below what was already counted
+		return max(planned, count), nil
+	default:
+		err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM "+from, args...).Scan(&count)
+		return count, err
+	}
+}
+
+// plannedRows returns the planner's estimate of the rows a query returns.
+func plannedRows(ctx context.Context, tx pgx.Tx, sqlQuery string, args []interface{}) (int64, error) {
+	var plan []byte
+	if err := tx.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+sqlQuery, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explained); err != nil || len(explained) == 0 {
+		return 0, fmt.Errorf("unexpected EXPLAIN output: %s", plan)
+	}
+	return int64(explained[0].Plan.Rows), nil
+}
+
+// contentRange formats the Content-Range of a GET response: 0-9/100 for
+// the first ten of 100 rows, */0 when none were returned, and * for the
+// total when it wasn't counted (total < 0).
+func contentRange(offset, returned int, total int64) string {
+	totalStr := "*"
+	if total >= 0 {
+		totalStr = strconv.FormatInt(total, 10)
+	}
+	if returned == 0 {
+		return "*/" + totalStr
+	}
+	return fmt.Sprintf("%d-%d/%s", offset, offset+returned-1, totalStr)
+}
+
+// rangeStatus picks the status of a GET response: 206 Partial Content when
+// the count shows rows were left out, 416 when the window starts past the
+// last row, and 200 otherwise.
+func rangeStatus(offset, returned int, total int64) int {
+	switch {
+	case total < 0:
+		return http.StatusOK
+	case returned == 0 && offset > 0 && int64(offset) >= total:
+		return http.StatusRequestedRangeNotSatisfiable
+	case offset > 0 || int64(offset+returned) < total:
+		return http.StatusPartialContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// setContentRange counts the matching rows if the request asked for a count
+// and sets Content-Range, returning the status for the response. If the
+// count fails or the window starts past the last row, it writes the error
+// and returns false.
+func (s *Server) setContentRange(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, quotedTable, whereClause string, whereArgs []interface{}, offset, returned int) (int, bool) {
+	total := int64(-1)
+	if mode := countMode(r); mode != "" {
+		var err error
+		total, err = countRows(ctx, tx, quotedTable, whereClause, whereArgs, mode)
+		if err != nil {
+			writeDBError(w, "count error", err, http.StatusBadRequest)
+			return 0, false
+		}
+	}
+	w.Header().Set("Content-Range", contentRange(offset, returned, total))
+
+	status := rangeStatus(offset, returned, total)
+	if status == http.StatusRequestedRangeNotSatisfiable {
+		http.Error(w, errRangeNotSatisfiable.Error(), status)
+		return 0, false
+	}
+	return status, true
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		rangeHdr  string
+		rangeUnit string
+		want      rowWindow
+		wantErr   error
+	}{
+		{name: "none", want: rowWindow{0, -1}},
+		{name: "limit and offset", query: "limit=10&offset=20", want: rowWindow{20, 10}},
+		{name: "range", rangeHdr: "0-9", want: rowWindow{0, 10}},
+		{name: "range with unit", rangeHdr: "10-19", rangeUnit: "items", want: rowWindow{10, 10}},
+		{name: "range with items prefix", rangeHdr: "items=5-5", want: rowWindow{5, 1}},
+		{name: "open range", rangeHdr: "10-", want: rowWindow{10, -1}},
+		{name: "range within limit", query: "limit=5", rangeHdr: "2-20", want: rowWindow{2, 3}},
+		{name: "limit within range", query: "offset=3&limit=2", rangeHdr: "0-9", want: rowWindow{3, 2}},
+		{name: "disjoint", query: "limit=5", rangeHdr: "10-20", want: rowWindow{10, 0}},
+		{name: "other unit ignored", rangeHdr: "0-9", rangeUnit: "bytes", want: rowWindow{0, -1}},
+		{name: "malformed range ignored", rangeHdr: "first-ten", want: rowWindow{0, -1}},
+		{name: "reversed range", rangeHdr: "9-0", wantErr: errRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos?"+tt.query, nil)
+			if tt.rangeHdr != "" {
+				r.Header.Set("Range", tt.rangeHdr)
+			}
+			if tt.rangeUnit != "" {
+				r.Header.Set("Range-Unit", tt.rangeUnit)
+			}
+
+			got, err := requestWindow(r, r.URL.Query())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("requestWindow() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("requestWindow() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("requestWindow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestWindow_InvalidLimit(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos?limit=ten", nil)
+	if _, err := requestWindow(r, r.URL.Query()); err == nil {
+		t.Error("requestWindow() should reject a non-numeric limit")
+	}
+}
+
+func TestContentRangeAndStatus(t *testing.T) {
+	tests := []struct {
+		offset, returned int
+		total            int64
+		wantRange        string
+		wantStatus       int
+	}{
+		{0, 10, -1, "0-9/*", http.StatusOK},
+		{0, 0, -1, "*/*", http.StatusOK},
+		{0, 10, 10, "0-9/10", http.StatusOK},
+		{0, 10, 100, "0-9/100", http.StatusPartialContent},
+		{90, 10, 100, "90-99/100", http.StatusPartialContent},
+		{0, 0, 0, "*/0", http.StatusOK},
+		{100, 0, 100, "*/100", http.StatusRequestedRangeNotSatisfiable},
+	}
+	for _, tt := range tests {
+		if got := contentRange(tt.offset, tt.returned, tt.total); got != tt.wantRange {
+			t.Errorf("contentRange(%d, %d, %d) = %q, want %q", tt.offset, tt.returned, tt.total, got, tt.wantRange)
+		}
+		if got := rangeStatus(tt.offset, tt.returned, tt.total); got != tt.wantStatus {
+			t.Errorf("rangeStatus(%d, %d, %d) = %d, want %d", tt.offset, tt.returned, tt.total, got, tt.wantStatus)
+		}
+	}
+}
+
+func TestCountMode(t *testing.T) {
+	tests := map[string]string{
+		"":                                "",
+		"count=exact":                     "exact",
+		"count=planned":                   "planned",
+		"return=minimal, count=estimated": "estimated",
+		"count=bogus":                     "",
+	}
+	for prefer, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+		r.Header.Set("Prefer", prefer)
+		if got := countMode(r); got != want {
+			t.Errorf("countMode(%q) = %q, want %q", prefer, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/markb/supalite/internal/rls"
+)
+
+// defaultSchema is the schema REST tables live in unless a request picks
+// another with a profile header.
+const defaultSchema = "public"
+
+// privilegedSchemas can be read through /rest/v1 with the service_role key,
+// so admin scripts can query auth.users or storage.objects the way they do
+// on Supabase. They are read-only.
+var privilegedSchemas = map[string]bool{
+	"auth":    true,
+	"storage": true,
+}
+
+// profileError rejects the schema a request asked for.
+type profileError struct {
+	status  int
+	code    string
+	message string
+	hint    string
+}
+
+// requestSchema resolves the schema a table request targets, from
+// Accept-Profile for reads and Content-Profile for writes, as PostgREST
+// does. Requests without either use public.
+func requestSchema(r *http.Request) (string, *profileError) {
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	header := "Content-Profile"
+	if read {
+		header = "Accept-Profile"
+	}
+	schema := r.Header.Get(header)
+	if schema == "" || schema == defaultSchema {
+		return defaultSchema, nil
+	}
+
+	if !privilegedSchemas[schema] {
+		return "", &profileError{
+			status:  http.StatusNotAcceptable,
+			code:    "PGRST106",
+			message: "The schema must be one of the following: public, auth, storage",
+		}
+	}
+	if rls.RoleForClaims(requestClaims(r)) != rls.RoleServiceRole {
+		return "", &profileError{
+			status:  http.StatusForbidden,
+			code:    "42501",
+			message: fmt.Sprintf("permission denied for schema %s", schema),
+			hint:    "The auth and storage schemas require the service_role key",
+		}
+	}
+	if !read {
+		return "", &profileError{
+			status:  http.StatusMethodNotAllowed,
+			code:    "PGRST106",
+			message: fmt.Sprintf("The %s schema is read-only", schema),
+		}
+	}
+	return schema, nil
+}
+
+// writeProfileError writes a rejected profile as a PostgREST-style error.
+func writeProfileError(w http.ResponseWriter, err *profileError) {
+	body := map[string]interface{}{
+		"code":    err.code,
+		"message": err.message,
+		"details": nil,
+		"hint":    nil,
+	}
+	if err.hint != "" {
+		body["hint"] = err.hint
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// qualifiedTable returns the quoted, schema-qualified name of a table.
+func qualifiedTable(schema, table string) string {
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func profileRequest(method, header, schema, role string) *http.Request {
+	r := httptest.NewRequest(method, "/rest/v1/users", nil)
+	if schema != "" {
+		r.Header.Set(header, schema)
+	}
+	claims := map[string]interface{}{"role": role}
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims))
+}
+
+func TestRequestSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		r          *http.Request
+		wantSchema string
+		wantStatus int
+	}{
+		{"default", profileRequest(http.MethodGet, "Accept-Profile", "", "anon"), "public", 0},
+		{"explicit public", profileRequest(http.MethodPost, "Content-Profile", "public", "anon"), "public", 0},
+		{"service_role reads auth", profileRequest(http.MethodGet, "Accept-Profile", "auth", "service_role"), "auth", 0},
+		{"service_role counts storage", profileRequest(http.MethodHead, "Accept-Profile", "storage", "service_role"), "storage", 0},
+		{"anon reads auth", profileRequest(http.MethodGet, "Accept-Profile", "auth", "anon"), "", http.StatusForbidden},
+		{"unknown schema", profileRequest(http.MethodGet, "Accept-Profile", "pg_catalog", "service_role"), "", http.StatusNotAcceptable},
+		{"write to auth", profileRequest(http.MethodPatch, "Content-Profile", "auth", "service_role"), "", http.StatusMethodNotAllowed},
+		{"Accept-Profile ignored on writes", profileRequest(http.MethodPost, "Accept-Profile", "auth", "anon"), "public", 0},
+	}
+	for _, tt := range tests {
+		schema, err := requestSchema(tt.r)
+		if tt.wantStatus != 0 {
+			if err == nil || err.status != tt.wantStatus {
+				t.Errorf("%s: requestSchema() error = %+v, want status %d", tt.name, err, tt.wantStatus)
+			}
+			continue
+		}
+		if err != nil || schema != tt.wantSchema {
+			t.Errorf("%s: requestSchema() = %q, %+v, want %q", tt.name, schema, err, tt.wantSchema)
+		}
+	}
+}
+
+func TestQualifiedTable(t *testing.T) {
+	if got := qualifiedTable("auth", "users"); got != `"auth"."users"` {
+		t.Errorf("qualifiedTable() = %s", got)
+	}
+}
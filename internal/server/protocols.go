@@ -0,0 +1,15 @@
+package server
+
+import "net/http"
+
+// serverProtocols are the protocols the main listener speaks: HTTP/1.1,
+// and HTTP/2 over TLS (negotiated with ALPN) and in the clear (with prior
+// knowledge, as load balancers and service meshes speak it to backends).
+// Clients that only speak HTTP/1.1, and WebSocket upgrades, are unaffected.
+func serverProtocols() *http.Protocols {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return &p
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerProtocols_UnencryptedHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.Config.Protocols = serverProtocols()
+	srv.Start()
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name  string
+		h1    bool
+		h2c   bool
+		proto string
+	}{
+		{"HTTP/1.1", true, false, "HTTP/1.1"},
+		{"HTTP/2 with prior knowledge", false, true, "HTTP/2.0"},
+	} {
+		var protocols http.Protocols
+		protocols.SetHTTP1(tc.h1)
+		protocols.SetUnencryptedHTTP2(tc.h2c)
+		client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Proto != tc.proto || string(body) != tc.proto {
+			t.Errorf("%s: response %s, server saw %s, want %s", tc.name, resp.Proto, body, tc.proto)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PublicURLLAN is the public_url value that picks this machine's address on
+// the local network, so phones on the same Wi-Fi can open auth links.
+const PublicURLLAN = "lan"
+
+// ResolvePublicURL returns the base URL other devices reach supalite at,
+// from the public_url setting: either a URL (e.g. a tunnel's) or "lan",
+// which is served with scheme ("http" or "https").
+func ResolvePublicURL(value string, port int, scheme string) (string, error) {
+	if value == PublicURLLAN {
+		ip, err := lanAddress()
+		if err != nil {
+			return "", err
+		}
+		return scheme + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("public URL %q must be an http(s) URL or %q", value, PublicURLLAN)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// lanAddress returns this machine's IPv4 address on a private network,
+// falling back to any non-loopback IPv4 address.
+func lanAddress() (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip.IsPrivate() {
+			return ip, nil
+		}
+		if fallback == nil {
+			fallback = ip
+		}
+	}
+	if fallback == nil {
+		return nil, errors.New("no network address found for public URL \"lan\"")
+	}
+	return fallback, nil
+}
+
+// localOrigins returns the origins auth emails use for this machine, which
+// captured emails rewrite to the public URL.
+func localOrigins(port int) []string {
+	return []string{
+		fmt.Sprintf("http://localhost:%d", port),
+		fmt.Sprintf("http://127.0.0.1:%d", port),
+		fmt.Sprintf("https://localhost:%d", port),
+		fmt.Sprintf("https://127.0.0.1:%d", port),
+	}
+}
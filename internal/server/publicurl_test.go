@@ -0,0 +1,29 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolvePublicURL(t *testing.T) {
+	got, err := ResolvePublicURL("https://abc123.ngrok.app/", 8080, "http")
+	if err != nil || got != "https://abc123.ngrok.app" {
+		t.Errorf("ResolvePublicURL(tunnel) = %q, %v", got, err)
+	}
+
+	for _, value := range []string{"localhost:8080", "ftp://example.com", "http://"} {
+		if _, err := ResolvePublicURL(value, 8080, "http"); err == nil {
+			t.Errorf("ResolvePublicURL(%q) should fail", value)
+		}
+	}
+}
+
+func TestResolvePublicURL_LAN(t *testing.T) {
+	got, err := ResolvePublicURL(PublicURLLAN, 8080, "http")
+	if err != nil {
+		t.Skipf("no LAN address in this environment: %v", err)
+	}
+	if !strings.HasPrefix(got, "http://") || !strings.HasSuffix(got, ":8080") || strings.Contains(got, "127.0.0.1") {
+		t.Errorf("ResolvePublicURL(lan) = %q, want http://<lan address>:8080", got)
+	}
+}
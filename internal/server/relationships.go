@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/schemacache"
+)
+
+// foreignKeyInfo holds information about a foreign key relationship. Keys
+// may span several columns; columns and foreignColumns pair up in order.
+type foreignKeyInfo struct {
+	name           string   // The !hint that picks it: the foreign key, or for many-to-many, the junction table
+	columns        []string // The join columns in the main table
+	foreignColumns []string // The columns of the embedded table they match
+	isReverse      bool     // true if the FK points from the foreign table to main table
+	isManyToMany   bool     // true if this is a many-to-many through junction table
+
+	junctionTable          string   // The junction table name (for many-to-many)
+	junctionColumns        []string // Junction columns referencing columns
+	junctionForeignColumns []string // Junction columns referencing foreignColumns
+}
+
+// cardinality names the relationship as PostgREST does.
+func (fk *foreignKeyInfo) cardinality() string {
+	switch {
+	case fk.isManyToMany:
+		return "many-to-many"
+	case fk.isReverse:
+		return "one-to-many"
+	}
+	return "many-to-one"
+}
+
+// relationshipError is an embed that can't be resolved: there is no
+// relationship between the tables, or there are several and nothing picks
+// one. It is reported as PostgREST reports it.
+type relationshipError struct {
+	status  int
+	code    string
+	message string
+	details interface{}
+	hint    string
+}
+
+func (e *relationshipError) Error() string {
+	return e.message
+}
+
+// write reports the error in PostgREST's JSON error format.
+func (e *relationshipError) write(w http.ResponseWriter) {
+	writeRESTError(w, e.status, e.code, e.message, e.details, e.hint)
+}
+
+// schemaRefreshInterval is how often a lookup the schema cache can't
+// answer reloads it.
+const schemaRefreshInterval = time.Second
+
+// cachedSchema returns the schema cache's tables and relationships, or
+// reads them through q when the server has no cache.
+func (s *Server) cachedSchema(ctx context.Context, q schemacache.Querier) (*schemacache.Schema, error) {
+	if s.schemaCache != nil {
+		return s.schemaCache.Schema(ctx)
+	}
+	return schemacache.Load(ctx, q)
+}
+
+// findForeignKey finds the foreign key relationship between two tables in
+// the public schema, from the schema cache. A table the cache doesn't know
+// may be newer than it, so a miss refreshes the cache, at most once per
+// schemaRefreshInterval, before giving up.
+func (s *Server) findForeignKey(ctx context.Context, q schemacache.Querier, mainTable, foreignTable, hint string) (*foreignKeyInfo, error) {
+	schema, err := s.cachedSchema(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	fkInfo, err := relationshipBetween(schema, mainTable, foreignTable, hint)
+	if rerr, ok := err.(*relationshipError); ok && rerr.code == "PGRST200" && s.schemaCache != nil {
+		if schema, err = s.schemaCache.Refresh(ctx, schemaRefreshInterval); err != nil {
+			return nil, err
+		}
+		fkInfo, err = relationshipBetween(schema, mainTable, foreignTable, hint)
+	}
+	return fkInfo, err
+}
+
+// relationshipBetween finds how mainTable relates to foreignTable: by a
+// foreign key of either table, or failing that, through a junction table
+// with a foreign key to each. hint, the fk in table!fk, picks a foreign key
+// by name or by one of its columns, or a junction table by name or by one
+// of its foreign keys. More than one match is a PGRST201 error listing
+// them, and none a PGRST200 error.
+func relationshipBetween(schema *schemacache.Schema, mainTable, foreignTable, hint string) (*foreignKeyInfo, error) {
+	var candidates []*foreignKeyInfo
+
+	for _, fk := range schema.ForeignKeysBetween(defaultSchema, mainTable, foreignTable) {
+		if hintMatches(hint, fk) {
+			candidates = append(candidates, &foreignKeyInfo{
+				name:           fk.Name,
+				columns:        fk.Columns,
+				foreignColumns: fk.RefColumns,
+			})
+		}
+	}
+	// A table's foreign key to itself is to-one from the row holding it;
+	// the reverse direction isn't a second candidate
+	if mainTable != foreignTable {
+		for _, fk := range schema.ForeignKeysBetween(defaultSchema, foreignTable, mainTable) {
+			if hintMatches(hint, fk) {
+				candidates = append(candidates, &foreignKeyInfo{
+					name:           fk.Name,
+					columns:        fk.RefColumns,
+					foreignColumns: fk.Columns,
+					isReverse:      true,
+				})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		for _, j := range schema.Junctions(defaultSchema, mainTable, foreignTable) {
+			if j.Table == mainTable || j.Table == foreignTable {
+				continue
+			}
+			name := j.Table
+			matched := hint == "" || hint == j.Table || hintMatches(hint, j.From) || hintMatches(hint, j.To)
+			if mainTable == foreignTable {
+				// A junction between rows of one table, e.g. follows
+				// between users, relates them both ways; the foreign key
+				// to the main row tells the directions apart
+				name = j.From.Name
+				matched = hintMatches(hint, j.From)
+			}
+			if !matched {
+				continue
+			}
+			candidates = append(candidates, &foreignKeyInfo{
+				name:                   name,
+				columns:                j.From.RefColumns,
+				foreignColumns:         j.To.RefColumns,
+				isReverse:              true,
+				isManyToMany:           true,
+				junctionTable:          j.Table,
+				junctionColumns:        j.From.Columns,
+				junctionForeignColumns: j.To.Columns,
+			})
+		}
+	}
+
+	switch len(candidates) {
+	case 1:
+		return candidates[0], nil
+	case 0:
+		err := &relationshipError{
+			status:  http.StatusBadRequest,
+			code:    "PGRST200",
+			message: fmt.Sprintf("Could not find a relationship between '%s' and '%s' in the schema cache", mainTable, foreignTable),
+		}
+		if hint != "" {
+			err.details = fmt.Sprintf("Searched for a foreign key relationship between '%s' and '%s' using the hint '%s' in the schema '%s', but no matches were found.", mainTable, foreignTable, hint, defaultSchema)
+		}
+		return nil, err
+	}
+
+	details := make([]map[string]string, len(candidates))
+	options := make([]string, len(candidates))
+	for i, c := range candidates {
+		details[i] = map[string]string{
+			"cardinality":  c.cardinality(),
+			"embedding":    fmt.Sprintf("%s with %s", mainTable, foreignTable),
+			"relationship": c.describe(mainTable, foreignTable),
+		}
+		options[i] = fmt.Sprintf("'%s!%s'", foreignTable, c.name)
+	}
+	return nil, &relationshipError{
+		status:  http.StatusMultipleChoices,
+		code:    "PGRST201",
+		message: fmt.Sprintf("Could not embed because more than one relationship was found for '%s' and '%s'", mainTable, foreignTable),
+		details: details,
+		hint:    fmt.Sprintf("Try changing '%s' to one of the following: %s. Find the desired relationship in the 'details' key.", foreignTable, strings.Join(options, ", ")),
+	}
+}
+
+// describe writes the relationship as PostgREST's disambiguation details
+// do, e.g. "posts_author_id_fkey using posts(author_id) and users(id)".
+func (fk *foreignKeyInfo) describe(mainTable, foreignTable string) string {
+	if fk.isManyToMany {
+		return fmt.Sprintf("%s using %s(%s) and %s(%s)", fk.junctionTable,
+			mainTable, strings.Join(fk.junctionColumns, ", "), foreignTable, strings.Join(fk.junctionForeignColumns, ", "))
+	}
+	return fmt.Sprintf("%s using %s(%s) and %s(%s)", fk.name,
+		mainTable, strings.Join(fk.columns, ", "), foreignTable, strings.Join(fk.foreignColumns, ", "))
+}
+
+// hintMatches reports whether an embed's !hint picks fk: no hint picks
+// every foreign key, and a hint picks the key it names or the keys with
+// the column it names.
+func hintMatches(hint string, fk schemacache.ForeignKey) bool {
+	return hint == "" || hint == fk.Name || slices.Contains(fk.Columns, hint)
+}
+
+// keyValues returns a row's values of the key columns, or false when one
+// is NULL: a key with a NULL matches no row.
+func keyValues(row map[string]interface{}, columns []string) ([]interface{}, bool) {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		if values[i] = row[col]; values[i] == nil {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// keyCondition matches the key columns, qualified by qualifier (e.g.
+// "j."), to the parameters $1, $2, ... in order.
+func keyCondition(qualifier string, columns []string) string {
+	conds := make([]string, len(columns))
+	for i, col := range columns {
+		conds[i] = fmt.Sprintf("%s%s = $%d", qualifier, quoteIdentifier(col), i+1)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// joinCondition matches columns of one table to those of another, in
+// order, e.g. j."tag_id" = t."id".
+func joinCondition(leftQualifier string, left []string, rightQualifier string, right []string) string {
+	conds := make([]string, len(left))
+	for i := range left {
+		conds[i] = fmt.Sprintf("%s%s = %s%s", leftQualifier, quoteIdentifier(left[i]), rightQualifier, quoteIdentifier(right[i]))
+	}
+	return strings.Join(conds, " AND ")
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/pg"
+	"github.com/markb/supalite/internal/schemacache"
+)
+
+func TestRelationshipBetween(t *testing.T) {
+	fk := func(name, table string, cols []string, refTable string, refCols []string) schemacache.ForeignKey {
+		return schemacache.ForeignKey{Name: name, Schema: "public", Table: table, Columns: cols, RefSchema: "public", RefTable: refTable, RefColumns: refCols}
+	}
+	id := []string{"id"}
+	schema := &schemacache.Schema{
+		Tables: []schemacache.Table{
+			{Schema: "public", Name: "posts"}, {Schema: "public", Name: "users"}, {Schema: "public", Name: "tags"},
+			{Schema: "public", Name: "post_tags"}, {Schema: "public", Name: "orders"}, {Schema: "public", Name: "shipments"},
+			{Schema: "public", Name: "employees"}, {Schema: "public", Name: "follows"},
+		},
+		ForeignKeys: []schemacache.ForeignKey{
+			fk("posts_author_id_fkey", "posts", []string{"author_id"}, "users", id),
+			fk("posts_editor_id_fkey", "posts", []string{"editor_id"}, "users", id),
+			fk("post_tags_post_id_fkey", "post_tags", []string{"post_id"}, "posts", id),
+			fk("post_tags_tag_id_fkey", "post_tags", []string{"tag_id"}, "tags", id),
+			fk("shipments_order_fkey", "shipments", []string{"order_region", "order_number"}, "orders", []string{"region", "number"}),
+			fk("employees_manager_id_fkey", "employees", []string{"manager_id"}, "employees", id),
+			fk("follows_follower_id_fkey", "follows", []string{"follower_id"}, "users", id),
+			fk("follows_followee_id_fkey", "follows", []string{"followee_id"}, "users", id),
+		},
+	}
+
+	tests := []struct {
+		main, foreign, hint string
+		want                *foreignKeyInfo
+	}{
+		{"posts", "users", "editor_id", &foreignKeyInfo{name: "posts_editor_id_fkey", columns: []string{"editor_id"}, foreignColumns: id}},
+		{"posts", "users", "posts_author_id_fkey", &foreignKeyInfo{name: "posts_author_id_fkey", columns: []string{"author_id"}, foreignColumns: id}},
+		{"shipments", "orders", "", &foreignKeyInfo{name: "shipments_order_fkey", columns: []string{"order_region", "order_number"}, foreignColumns: []string{"region", "number"}}},
+		{"orders", "shipments", "", &foreignKeyInfo{name: "shipments_order_fkey", columns: []string{"region", "number"}, foreignColumns: []string{"order_region", "order_number"}, isReverse: true}},
+		{"employees", "employees", "", &foreignKeyInfo{name: "employees_manager_id_fkey", columns: []string{"manager_id"}, foreignColumns: id}},
+		{"posts", "tags", "", &foreignKeyInfo{name: "post_tags", columns: id, foreignColumns: id, isReverse: true, isManyToMany: true,
+			junctionTable: "post_tags", junctionColumns: []string{"post_id"}, junctionForeignColumns: []string{"tag_id"}}},
+		{"tags", "posts", "post_tags", &foreignKeyInfo{name: "post_tags", columns: id, foreignColumns: id, isReverse: true, isManyToMany: true,
+			junctionTable: "post_tags", junctionColumns: []string{"tag_id"}, junctionForeignColumns: []string{"post_id"}}},
+	}
+	for _, tt := range tests {
+		got, err := relationshipBetween(schema, tt.main, tt.foreign, tt.hint)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("relationshipBetween(%s, %s, %q) = %+v, %v, want %+v", tt.main, tt.foreign, tt.hint, got, err, tt.want)
+		}
+	}
+
+	// users has two foreign keys from posts, and two ways through follows
+	// to itself; the hint picks one
+	if got, err := relationshipBetween(schema, "users", "users", "follows_followee_id_fkey"); err != nil || !reflect.DeepEqual(got.junctionColumns, []string{"followee_id"}) {
+		t.Errorf("relationshipBetween(users, users, follows_followee_id_fkey) = %+v, %v", got, err)
+	}
+	ambiguous := []struct{ main, foreign, hint string }{
+		{"posts", "users", ""},
+		{"users", "posts", ""},
+		{"users", "users", ""},
+	}
+	for _, tt := range ambiguous {
+		_, err := relationshipBetween(schema, tt.main, tt.foreign, tt.hint)
+		if rerr, ok := err.(*relationshipError); !ok || rerr.code != "PGRST201" || rerr.status != http.StatusMultipleChoices {
+			t.Errorf("relationshipBetween(%s, %s, %q) = %v, want PGRST201", tt.main, tt.foreign, tt.hint, err)
+		}
+	}
+
+	missing := []struct{ main, foreign, hint string }{
+		{"posts", "users", "reviewer_id"},
+		{"users", "tags", ""},
+		{"posts", "nope", ""},
+	}
+	for _, tt := range missing {
+		_, err := relationshipBetween(schema, tt.main, tt.foreign, tt.hint)
+		if rerr, ok := err.(*relationshipError); !ok || rerr.code != "PGRST200" || rerr.status != http.StatusBadRequest {
+			t.Errorf("relationshipBetween(%s, %s, %q) = %v, want PGRST200", tt.main, tt.foreign, tt.hint, err)
+		}
+	}
+}
+
+func TestRelationshipBetween_Ambiguous(t *testing.T) {
+	schema := &schemacache.Schema{
+		ForeignKeys: []schemacache.ForeignKey{
+			{Name: "posts_author_id_fkey", Schema: "public", Table: "posts", Columns: []string{"author_id"}, RefSchema: "public", RefTable: "users", RefColumns: []string{"id"}},
+			{Name: "posts_editor_id_fkey", Schema: "public", Table: "posts", Columns: []string{"editor_id"}, RefSchema: "public", RefTable: "users", RefColumns: []string{"id"}},
+		},
+	}
+	_, err := relationshipBetween(schema, "posts", "users", "")
+	rerr, ok := err.(*relationshipError)
+	if !ok {
+		t.Fatalf("relationshipBetween() = %v, want a relationshipError", err)
+	}
+	if rerr.message != "Could not embed because more than one relationship was found for 'posts' and 'users'" {
+		t.Errorf("message = %q", rerr.message)
+	}
+	if rerr.hint != "Try changing 'users' to one of the following: 'users!posts_author_id_fkey', 'users!posts_editor_id_fkey'. Find the desired relationship in the 'details' key." {
+		t.Errorf("hint = %q", rerr.hint)
+	}
+	want := []map[string]string{
+		{"cardinality": "many-to-one", "embedding": "posts with users", "relationship": "posts_author_id_fkey using posts(author_id) and users(id)"},
+		{"cardinality": "many-to-one", "embedding": "posts with users", "relationship": "posts_editor_id_fkey using posts(editor_id) and users(id)"},
+	}
+	if !reflect.DeepEqual(rerr.details, want) {
+		t.Errorf("details = %v", rerr.details)
+	}
+}
+
+func TestKeyCondition(t *testing.T) {
+	if got := keyCondition("t.", []string{"region", "number"}); got != `t."region" = $1 AND t."number" = $2` {
+		t.Errorf("keyCondition() = %s", got)
+	}
+	if got := joinCondition("j.", []string{"order_region"}, "t.", []string{"region"}); got != `j."order_region" = t."region"` {
+		t.Errorf("joinCondition() = %s", got)
+	}
+	if _, ok := keyValues(map[string]interface{}{"region": "eu", "number": nil}, []string{"region", "number"}); ok {
+		t.Error("keyValues() with a NULL column = ok")
+	}
+	if values, ok := keyValues(map[string]interface{}{"region": "eu", "number": 7}, []string{"region", "number"}); !ok || !reflect.DeepEqual(values, []interface{}{"eu", 7}) {
+		t.Errorf("keyValues() = %v, %v", values, ok)
+	}
+}
+
+// TestHandleGET_Embedding embeds across a schema of the relationships
+// that trip up id-and-single-column assumptions: composite keys, junction
+// tables with keys and columns of their own, several foreign keys between
+// two tables, and a table referencing itself.
+func TestHandleGET_Embedding(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15440,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-relationships",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE public.orders (
+			region text,
+			number int,
+			total int,
+			PRIMARY KEY (region, number)
+		);
+		CREATE TABLE public.shipments (
+			id int PRIMARY KEY,
+			order_region text,
+			order_number int,
+			carrier text,
+			FOREIGN KEY (order_region, order_number) REFERENCES public.orders (region, number)
+		);
+		CREATE TABLE public.products (sku text PRIMARY KEY, name text);
+		CREATE TABLE public.order_lines (
+			line_id int PRIMARY KEY,
+			region text,
+			number int,
+			product_sku text REFERENCES public.products (sku),
+			quantity int,
+			FOREIGN KEY (region, number) REFERENCES public.orders (region, number)
+		);
+		CREATE TABLE public.users (id int PRIMARY KEY, name text);
+		CREATE TABLE public.posts (
+			id int PRIMARY KEY,
+			title text,
+			author_id int REFERENCES public.users (id),
+			editor_id int REFERENCES public.users (id)
+		);
+		CREATE TABLE public.employees (
+			id int PRIMARY KEY,
+			name text,
+			manager_id int REFERENCES public.employees (id)
+		);
+
+		INSERT INTO public.orders VALUES ('eu', 1, 100), ('us', 1, 200), ('eu', 2, 300);
+		INSERT INTO public.shipments VALUES (10, 'eu', 1, 'dhl'), (11, 'us', 1, 'ups'), (12, 'us', 1, 'fedex'), (13, NULL, NULL, 'none');
+		INSERT INTO public.products VALUES ('A', 'apple'), ('B', 'banana');
+		INSERT INTO public.order_lines VALUES (1, 'eu', 1, 'A', 3), (2, 'us', 1, 'A', 1), (3, 'us', 1, 'B', 2);
+		INSERT INTO public.users VALUES (1, 'ann'), (2, 'bob');
+		INSERT INTO public.posts VALUES (1, 'hello', 1, 2);
+		INSERT INTO public.employees VALUES (1, 'boss', NULL), (2, 'worker', 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	get := func(table, query string) (int, []byte) {
+		t.Helper()
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+
+		rec := httptest.NewRecorder()
+		(&Server{}).handleGET(ctx, tx, rec, httptest.NewRequest(http.MethodGet, "/rest/v1/"+table+"?"+query, nil), "public", table)
+		return rec.Code, rec.Body.Bytes()
+	}
+	rows := func(table, query string) []map[string]interface{} {
+		t.Helper()
+		status, body := get(table, query)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s?%s: status %d: %s", table, query, status, body)
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(body, &rows); err != nil {
+			t.Fatalf("GET %s?%s: %v", table, query, err)
+		}
+		return rows
+	}
+	asJSON := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	// A composite foreign key, both ways, with its columns left out of the select
+	got := rows("shipments", "select=id,orders(total)&order=id")
+	if want := `[{"id":10,"orders":{"total":100}},{"id":11,"orders":{"total":200}},{"id":12,"orders":{"total":200}},{"id":13,"orders":null}]`; asJSON(got) != want {
+		t.Errorf("shipments with orders = %s, want %s", asJSON(got), want)
+	}
+	got = rows("orders", "select=total,shipments(carrier)&order=total&shipments.order=carrier")
+	if want := `[{"shipments":[{"carrier":"dhl"}],"total":100},{"shipments":[{"carrier":"fedex"},{"carrier":"ups"}],"total":200},{"shipments":[],"total":300}]`; asJSON(got) != want {
+		t.Errorf("orders with shipments = %s, want %s", asJSON(got), want)
+	}
+
+	// A junction table keyed by a composite key, with columns of its own
+	got = rows("orders", "select=total,products(name)&order=total&products.order=name")
+	if want := `[{"products":[{"name":"apple"}],"total":100},{"products":[{"name":"apple"},{"name":"banana"}],"total":200},{"products":[],"total":300}]`; asJSON(got) != want {
+		t.Errorf("orders with products = %s, want %s", asJSON(got), want)
+	}
+	got = rows("products", "select=name,orders!inner(region,number)&order=name&orders.region=eq.us")
+	if want := `[{"name":"apple","orders":[{"number":1,"region":"us"}]},{"name":"banana","orders":[{"number":1,"region":"us"}]}]`; asJSON(got) != want {
+		t.Errorf("products with us orders = %s, want %s", asJSON(got), want)
+	}
+
+	// Two foreign keys between posts and users: ambiguous without a hint
+	status, body := get("posts", "select=title,users(name)")
+	if status != http.StatusMultipleChoices || !strings.Contains(string(body), `"code":"PGRST201"`) || !strings.Contains(string(body), "users!posts_editor_id_fkey") {
+		t.Errorf("ambiguous embed: status %d: %s", status, body)
+	}
+	got = rows("posts", "select=title,author:users!author_id(name),editor:users!posts_editor_id_fkey(name)")
+	if want := `[{"author":{"name":"ann"},"editor":{"name":"bob"},"title":"hello"}]`; asJSON(got) != want {
+		t.Errorf("posts with hinted users = %s, want %s", asJSON(got), want)
+	}
+	got = rows("users", "select=name,posts!editor_id(title)&order=id")
+	if want := `[{"name":"ann","posts":[]},{"name":"bob","posts":[{"title":"hello"}]}]`; asJSON(got) != want {
+		t.Errorf("users with edited posts = %s, want %s", asJSON(got), want)
+	}
+
+	// A table referencing itself embeds the row it references
+	got = rows("employees", "select=name,manager:employees(name)&order=id")
+	if want := `[{"manager":null,"name":"boss"},{"manager":{"name":"boss"},"name":"worker"}]`; asJSON(got) != want {
+		t.Errorf("employees with managers = %s, want %s", asJSON(got), want)
+	}
+
+	// No relationship
+	status, body = get("users", "select=name,products(name)")
+	if status != http.StatusBadRequest || !strings.Contains(string(body), `"code":"PGRST200"`) {
+		t.Errorf("unrelated embed: status %d: %s", status, body)
+	}
+	status, body = get("posts", "select=title,users!reviewer_id(name)")
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "using the hint 'reviewer_id'") {
+		t.Errorf("unmatched hint: status %d: %s", status, body)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// claimsContextKey is the request context key holding the verified JWT
+// claims of a REST request.
+type claimsContextKey struct{}
+
+// requestClaims returns the verified JWT claims attached by requireAPIKey.
+func requestClaims(r *http.Request) map[string]interface{} {
+	claims, _ := r.Context().Value(claimsContextKey{}).(map[string]interface{})
+	return claims
+}
+
+// writeAuthError writes a PostgREST-style JSON error for a rejected key.
+func writeAuthError(w http.ResponseWriter, code, message, hint string) {
+	body := map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"details": nil,
+		"hint":    nil,
+	}
+	if hint != "" {
+		body["hint"] = hint
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(body)
+}
+
+// requireAPIKey rejects REST requests that don't carry a valid key, like the
+// Supabase API gateway in front of PostgREST.
+//
+// The apikey header (or apikey query parameter) must be a key signed by the
+// key manager, such as the anon or service_role key. An Authorization bearer
+// token, typically a user session from GoTrue, takes precedence for the
+// request's claims and role when present. The verified claims are stored in
+// the request context for requestClaims.
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := strings.TrimSpace(r.Header.Get("apikey"))
+		if apiKey == "" {
+			apiKey = r.URL.Query().Get("apikey")
+		}
+
+		bearer := ""
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			const bearerPrefix = "Bearer "
+			if len(authHeader) <= len(bearerPrefix) || !strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
+				writeAuthError(w, "PGRST301", "Authorization header must use the Bearer scheme", "")
+				return
+			}
+			bearer = strings.TrimSpace(authHeader[len(bearerPrefix):])
+		}
+
+		if apiKey == "" && bearer == "" {
+			writeAuthError(w, "PGRST301", "No API key found in request", "No `apikey` request header or url param was found.")
+			return
+		}
+
+		var claims map[string]interface{}
+		if apiKey != "" {
+			keyClaims, err := s.verifyAPIKey(apiKey)
+			if errors.Is(err, keys.ErrKeyRevoked) {
+				writeAuthError(w, "PGRST301", "API key has been revoked", "Use the key printed by `supalite keys regenerate`.")
+				return
+			}
+			if err != nil {
+				writeAuthError(w, jwtErrorCode(err), "Invalid API key", "Double check your Supabase `anon` or `service_role` API key.")
+				return
+			}
+			claims = keyClaims
+		}
+		if bearer != "" {
+			tokenClaims, err := s.verifyUserToken(bearer)
+			if err != nil {
+				writeAuthError(w, jwtErrorCode(err), jwtErrorMessage(err), "")
+				return
+			}
+			claims = tokenClaims
+		}
+
+		logger.Debug("rest request authorized", "role", rls.RoleForClaims(claims))
+		ctx := context.WithValue(r.Context(), claimsContextKey{}, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// verifyAPIKey verifies a project API key against the key manager.
+func (s *Server) verifyAPIKey(token string) (map[string]interface{}, error) {
+	if _, err := s.keyManager.VerifyToken(token); err != nil {
+		return nil, err
+	}
+	return decodeClaims(token)
+}
+
+// verifyUserToken verifies a bearer token. Besides project keys, this accepts
+// GoTrue session tokens, which are signed with the GoTrue JWT secret in ES256
+// mode.
+func (s *Server) verifyUserToken(token string) (map[string]interface{}, error) {
+	_, err := s.keyManager.VerifyToken(token)
+	if err != nil && s.userJWTSecret != "" {
+		_, err = jwt.ParseString(token, jwt.WithKey(jwa.HS256, []byte(s.userJWTSecret)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeClaims(token)
+}
+
+// decodeClaims decodes the payload of an already verified token as sent, so
+// numeric claims such as exp stay numbers in request.jwt.claims.
+func decodeClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// jwtErrorCode returns the PostgREST error code for a token verification
+// failure.
+func jwtErrorCode(err error) string {
+	if errors.Is(err, jwt.ErrTokenExpired()) {
+		return "PGRST303"
+	}
+	return "PGRST301"
+}
+
+// jwtErrorMessage returns the message for a rejected bearer token.
+func jwtErrorMessage(err error) string {
+	if errors.Is(err, jwt.ErrTokenExpired()) {
+		return "JWT expired"
+	}
+	return "Invalid JWT: " + err.Error()
+}
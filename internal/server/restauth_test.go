@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/markb/supalite/internal/keys"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	const userSecret = "gotrue-user-secret"
+	s := &Server{keyManager: keyManager, userJWTSecret: userSecret}
+
+	userToken, err := jwt.NewBuilder().
+		Claim("role", "authenticated").
+		Claim("sub", "7c9e6679-7425-40de-944b-e07fc1f90ae7").
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedUser, err := jwt.Sign(userToken, jwt.WithKey(jwa.HS256, []byte(userSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredUser, err := keyManager.GenerateUserToken(map[string]interface{}{"role": "authenticated"}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		bearer   string
+		query    string
+		wantCode int
+		wantRole string
+	}{
+		{"no key", "", "", "", http.StatusUnauthorized, ""},
+		{"anon key", keyManager.GetAnonKey(), "", "", http.StatusOK, "anon"},
+		{"service key", keyManager.GetServiceKey(), "", "", http.StatusOK, "service_role"},
+		{"apikey query parameter", "", "", "?apikey=" + keyManager.GetAnonKey(), http.StatusOK, "anon"},
+		{"garbage key", "not-a-jwt", "", "", http.StatusUnauthorized, ""},
+		{"user session as bearer", keyManager.GetAnonKey(), string(signedUser), "", http.StatusOK, "authenticated"},
+		{"user session as apikey", string(signedUser), "", "", http.StatusUnauthorized, ""},
+		{"expired bearer", keyManager.GetAnonKey(), expiredUser, "", http.StatusUnauthorized, ""},
+		{"bearer only", "", keyManager.GetServiceKey(), "", http.StatusOK, "service_role"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRole interface{}
+			handler := s.requireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRole = requestClaims(r)["role"]
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos"+tt.query, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("apikey", tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantRole != "" && gotRole != tt.wantRole {
+				t.Errorf("role = %v, want %s", gotRole, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKey_Revoked(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	leaked := keyManager.GetServiceKey()
+	if _, err := keyManager.Regenerate("service_role", true); err != nil {
+		t.Fatalf("Regenerate() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager}
+	handler := s.requireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for key, wantCode := range map[string]int{leaked: http.StatusUnauthorized, keyManager.GetServiceKey(): http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+		req.Header.Set("apikey", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != wantCode {
+			t.Errorf("status = %d, want %d (body %s)", rec.Code, wantCode, rec.Body.String())
+		}
+	}
+}
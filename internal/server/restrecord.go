@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// recordREST records each REST request, with the statements it ran, in
+// the request recorder. It runs after requireAPIKey, so the caller's role
+// is known. Requests being explained carry their own recording and are
+// left out.
+func (s *Server) recordREST(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorder.FromContext(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder.Recording{}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(recorder.WithRecording(r.Context(), rec)))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.restRecorder.Add(recorder.Request{
+			Time:       start,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      redactedQuery(r.URL),
+			Role:       rls.RoleForClaims(requestClaims(r)),
+			Status:     status,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Statements: rec.Statements(),
+		})
+	})
+}
+
+// handleRESTRequests returns the recorded REST requests, newest first.
+//
+// GET /admin/v1/rest/requests
+func (s *Server) handleRESTRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.restRecorder.Recent())
+}
+
+// handleClearRESTRequests drops the recorded REST requests.
+//
+// DELETE /admin/v1/rest/requests
+func (s *Server) handleClearRESTRequests(w http.ResponseWriter, r *http.Request) {
+	s.restRecorder.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// explainRequest is the body of POST /admin/v1/rest/explain.
+type explainRequest struct {
+	Method  string            `json:"method"`  // Default: GET
+	Path    string            `json:"path"`    // e.g. /rest/v1/todos?select=*&done=eq.false
+	Headers map[string]string `json:"headers"` // Optional: e.g. Prefer; apikey and Authorization pick the role
+	Body    json.RawMessage   `json:"body"`    // Optional: the request body
+}
+
+// explainResponse is the response of POST /admin/v1/rest/explain.
+type explainResponse struct {
+	Method     string               `json:"method"`
+	Path       string               `json:"path"`
+	Status     int                  `json:"status"`          // What the request would have answered
+	Error      string               `json:"error,omitempty"` // The response body, when it failed
+	Statements []recorder.Statement `json:"statements"`
+}
+
+// handleRESTExplain runs a REST request in a transaction that is rolled
+// back instead of committed, and returns the SQL it ran with the bind
+// parameters. Nothing it writes is kept. The request runs with the
+// caller's service_role key unless its headers carry another key or a
+// user's token.
+//
+// POST /admin/v1/rest/explain
+func (s *Server) handleRESTExplain(w http.ResponseWriter, r *http.Request) {
+	var req explainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if !strings.HasPrefix(req.Path, "/rest/") && req.Path != "/rest" {
+		http.Error(w, "path must be a REST API path, e.g. /rest/v1/todos?select=*", http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader
+	if len(req.Body) > 0 && string(req.Body) != "null" {
+		body = bytes.NewReader(req.Body)
+	}
+
+	// A fresh routing context, so the router routes the request from the
+	// top instead of resuming this one's
+	rec := &recorder.Recording{DryRun: true}
+	ctx := context.WithValue(recorder.WithRecording(r.Context(), rec), chi.RouteCtxKey, nil)
+	explained, err := http.NewRequestWithContext(ctx, req.Method, req.Path, body)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	explained.RemoteAddr = r.RemoteAddr
+	for name, value := range req.Headers {
+		explained.Header.Set(name, value)
+	}
+	if body != nil && explained.Header.Get("Content-Type") == "" {
+		explained.Header.Set("Content-Type", "application/json")
+	}
+	if explained.Header.Get("apikey") == "" && explained.Header.Get("Authorization") == "" {
+		token := requestToken(r)
+		explained.Header.Set("apikey", token)
+		explained.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp := &settingsRecorder{header: http.Header{}}
+	s.router.ServeHTTP(resp, explained)
+
+	result := explainResponse{
+		Method:     req.Method,
+		Path:       req.Path,
+		Status:     resp.status,
+		Statements: rec.Statements(),
+	}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if result.Status >= http.StatusBadRequest {
+		result.Error = strings.TrimSpace(resp.body.String())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
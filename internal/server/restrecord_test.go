@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markb/supalite/internal/recorder"
+)
+
+func TestRecordREST(t *testing.T) {
+	s := &Server{restRecorder: recorder.New(10)}
+
+	handler := s.recordREST(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorder.FromContext(r.Context()) == nil {
+			t.Error("request has no recording")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/rest/v1/todos?select=id", nil))
+
+	recent := s.restRecorder.Recent()
+	if len(recent) != 1 {
+		t.Fatalf("recorded %d requests, want 1", len(recent))
+	}
+	if got := recent[0]; got.Method != http.MethodPost || got.Path != "/rest/v1/todos" || got.Query != "select=id" || got.Status != http.StatusCreated {
+		t.Errorf("recorded %+v", got)
+	}
+}
+
+func TestHandleRESTExplain(t *testing.T) {
+	s := &Server{router: chi.NewRouter()}
+	s.router.Post("/admin/v1/rest/explain", s.handleRESTExplain)
+	s.router.Patch("/rest/v1/{table}", func(w http.ResponseWriter, r *http.Request) {
+		if !recorder.IsDryRun(r.Context()) {
+			t.Error("explained request is not a dry run")
+		}
+		if got := r.Header.Get("apikey"); got != "service-key" {
+			t.Errorf("apikey = %q, want the caller's key", got)
+		}
+		if table := chi.URLParam(r, "table"); table != "todos" {
+			t.Errorf("table = %q", table)
+		}
+		http.Error(w, `{"message":"nope"}`, http.StatusForbidden)
+	})
+
+	explain := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/v1/rest/explain", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer service-key")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := explain(`{"method": "patch", "path": "/rest/v1/todos?id=eq.1", "body": {"done": true}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp explainResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Method != http.MethodPatch || resp.Status != http.StatusForbidden || resp.Error != `{"message":"nope"}` {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if w := explain(`{"path": "/storage/v1/bucket"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-REST path: status = %d, want 400", w.Code)
+	}
+}
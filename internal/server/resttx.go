@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// acquireRequestConn borrows a connection for a REST request from the pool
+// of the caller's role. Injected database failures surface here, before
+// the request touches the database.
+func (s *Server) acquireRequestConn(ctx context.Context, r *http.Request) (*pgxpool.Conn, error) {
+	if err := s.dbFault(); err != nil {
+		return nil, err
+	}
+	return s.pgDatabase.AcquireAs(ctx, rls.RoleForClaims(requestClaims(r)))
+}
+
+// beginRequest starts the transaction a REST request runs in, as PostgREST
+// does: the request's JWT claims are exposed through request.jwt.claims
+// (read by auth.uid(), auth.role(), and auth.jwt()) and the transaction
+// switches to the role named by the claims, so RLS policies apply.
+func (s *Server) beginRequest(ctx context.Context, conn *pgx.Conn, r *http.Request, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := requestClaims(r)
+	settings, err := rls.Settings(claims)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", setting.Name, setting.Value); err != nil {
+			tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set %s: %w", setting.Name, err)
+		}
+	}
+
+	role := rls.RoleForClaims(claims)
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize()); err != nil {
+		tx.Rollback(ctx)
+		return nil, fmt.Errorf("failed to switch to role %s: %w", role, err)
+	}
+	return tx, nil
+}
+
+// writeBeginError reports a failure to set up a request's transaction. A
+// JWT naming a role that doesn't exist is the caller's fault.
+func writeBeginError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22023" { // invalid_parameter_value: unknown role
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	writeDBError(w, "transaction error", err, http.StatusInternalServerError)
+}
+
+// txResponseWriter buffers a REST handler's response so the request's
+// transaction can be committed before anything reaches the client, and
+// rolled back when the handler reports an error. A response marked
+// transient can be discarded so the request runs again.
+type txResponseWriter struct {
+	w         http.ResponseWriter
+	header    http.Header // the response headers before the handler ran
+	status    int
+	body      bytes.Buffer
+	transient bool // failed with an error worth retrying (see writeDBError)
+}
+
+func newTxResponseWriter(w http.ResponseWriter) *txResponseWriter {
+	return &txResponseWriter{w: w, header: w.Header().Clone()}
+}
+
+func (tw *txResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+func (tw *txResponseWriter) Header() http.Header {
+	return tw.w.Header()
+}
+
+func (tw *txResponseWriter) WriteHeader(status int) {
+	if tw.status == 0 {
+		tw.status = status
+	}
+}
+
+func (tw *txResponseWriter) Write(p []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+// commit commits the transaction if the handler succeeded. A failed commit
+// replaces the response with an error. A request being explained is left
+// to be rolled back.
+func (tw *txResponseWriter) commit(ctx context.Context, tx pgx.Tx) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	if tw.status < http.StatusBadRequest && !recorder.IsDryRun(ctx) {
+		if err := tx.Commit(ctx); err != nil {
+			tw.w.Header().Del("Content-Range")
+			tw.w.Header().Del("Location")
+			tw.status = 0
+			tw.body.Reset()
+			writeDBError(tw, "commit error", err, http.StatusBadRequest)
+		}
+	}
+}
+
+// send writes the buffered response to the client.
+func (tw *txResponseWriter) send() {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.status)
+	tw.w.Write(tw.body.Bytes())
+}
+
+// discard drops the buffered response and restores the headers the
+// handler changed, ready for another attempt.
+func (tw *txResponseWriter) discard() {
+	header := tw.w.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	for name, values := range tw.header {
+		header[name] = values
+	}
+	tw.status = 0
+	tw.body.Reset()
+	tw.transient = false
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/pg"
+)
+
+func TestBeginRequest_RLS(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15435,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-rls",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	srv := &Server{pgDatabase: db}
+	if err := srv.initSchema(ctx); err != nil {
+		t.Fatalf("initSchema() failed: %v", err)
+	}
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	// A table created after init is granted through default privileges
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE public.notes (id serial PRIMARY KEY, owner text NOT NULL, body text);
+		ALTER TABLE public.notes ENABLE ROW LEVEL SECURITY;
+		CREATE POLICY own_notes ON public.notes
+			USING (owner = current_setting('request.jwt.claims', true)::jsonb ->> 'sub');
+		INSERT INTO public.notes (owner, body) VALUES ('alice', 'a'), ('alice', 'b'), ('bob', 'c');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   int
+	}{
+		{"anon", map[string]interface{}{"role": "anon"}, 0},
+		{"authenticated", map[string]interface{}{"role": "authenticated", "sub": "alice"}, 2},
+		{"service_role", map[string]interface{}{"role": "service_role"}, 3},
+		{"no claims", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/v1/notes", nil)
+			if tt.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, tt.claims))
+			}
+
+			tx, err := srv.beginRequest(ctx, conn, r, pgx.TxOptions{})
+			if err != nil {
+				t.Fatalf("beginRequest() failed: %v", err)
+			}
+			defer tx.Rollback(ctx)
+
+			var count int
+			if err := tx.QueryRow(ctx, "SELECT count(*) FROM public.notes").Scan(&count); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if count != tt.want {
+				t.Errorf("visible rows = %d, want %d", count, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown role", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/rest/v1/notes", nil)
+		r = r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, map[string]interface{}{"role": "nobody"}))
+		if _, err := srv.beginRequest(ctx, conn, r, pgx.TxOptions{}); err == nil {
+			t.Fatal("beginRequest() succeeded for a role that doesn't exist")
+		}
+
+		rec := httptest.NewRecorder()
+		_, err := srv.beginRequest(ctx, conn, r, pgx.TxOptions{})
+		writeBeginError(rec, err)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Values of the Prefer: return= preference for writes
+const (
+	returnRepresentation = "representation" // Body holds the affected rows
+	returnMinimal        = "minimal"        // Empty body
+	returnHeadersOnly    = "headers-only"   // Empty body, Location for inserts
+)
+
+// returnPreference resolves Prefer: return= for a write. Requests without
+// one, or with an unknown value, get the affected rows back.
+func returnPreference(r *http.Request) string {
+	switch pref := preferences(r)["return"]; pref {
+	case returnMinimal, returnHeadersOnly:
+		return pref
+	default:
+		return returnRepresentation
+	}
+}
+
+// buildReturningClause builds the RETURNING list of a write from its select
+// parameter, as supabase-js sends for .insert().select('id,title'), mapping
+// the column names by names. Without one, every column is returned.
+func buildReturningClause(query url.Values, names IdentifierCase) (string, error) {
+	selectVals := query["select"]
+	if len(selectVals) == 0 {
+		return "*", nil
+	}
+	cols := strings.Split(selectVals[0], ",")
+	quoted := make([]string, 0, len(cols))
+	for _, col := range cols {
+		q, err := buildSelectColumn(names.column(strings.TrimSpace(col)))
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// writeMutation writes the response for a POST, PATCH, or DELETE. Inserts
+// answer 201 Created, updates and deletes 200 OK. When the client asked for
+// no body, inserts still answer 201 with a Location for the new row, while
+// updates and deletes answer 204 No Content.
+//
+// Updates and deletes report the rows they touched in Content-Range, as
+// PostgREST does (0-2/* for three rows, */* for none), so a no-op is visible
+// without a body. With Prefer: count= the total is filled in too: 0-2/3.
+func (s *Server) writeMutation(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, table string, results []map[string]interface{}) {
+	pref := returnPreference(r)
+	if preferences(r)["return"] == pref {
+		w.Header().Add("Preference-Applied", "return="+pref)
+	}
+
+	inserted := r.Method == http.MethodPost
+	if !inserted {
+		// The affected rows are known, so any count mode is exact
+		total := int64(-1)
+		if mode := countMode(r); mode != "" {
+			total = int64(len(results))
+			w.Header().Add("Preference-Applied", "count="+mode)
+		}
+		w.Header().Set("Content-Range", contentRange(0, len(results), total))
+	}
+	if pref == returnRepresentation {
+		status := http.StatusOK
+		if inserted {
+			status = http.StatusCreated
+		}
+		s.writeRows(w, r, status, results)
+		return
+	}
+
+	if !inserted {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(results) == 1 {
+		location, err := insertedLocation(ctx, tx, r, table, results[0])
+		if err != nil {
+			writeDBError(w, "primary key lookup error", err, http.StatusInternalServerError)
+			return
+		}
+		if location != "" {
+			w.Header().Set("Location", location)
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// insertedLocation returns the URL of an inserted row, filtered on its
+// primary key: /rest/v1/todos?id=eq.1. It is empty if the table has no
+// primary key or the row doesn't include it.
+func insertedLocation(ctx context.Context, tx pgx.Tx, r *http.Request, table string, row map[string]interface{}) (string, error) {
+	pkColumns, err := primaryKeyColumns(ctx, tx, table)
+	if err != nil {
+		return "", err
+	}
+	return rowLocation(r.URL.Path, pkColumns, row), nil
+}
+
+// primaryKeyColumns returns the primary key columns of a public table, in
+// key order.
+func primaryKeyColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT a.attname
+		FROM pg_index i
+		CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE i.indrelid = to_regclass($1) AND i.indisprimary
+		ORDER BY k.ord
+	`, "public."+quoteIdentifier(table))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// rowLocation builds the Location of a row from its primary key values.
+func rowLocation(path string, pkColumns []string, row map[string]interface{}) string {
+	if len(pkColumns) == 0 {
+		return ""
+	}
+	filters := make([]string, 0, len(pkColumns))
+	for _, col := range pkColumns {
+		val, ok := row[col]
+		if !ok || val == nil {
+			return ""
+		}
+		filters = append(filters, url.QueryEscape(col)+"=eq."+url.QueryEscape(locationValue(val)))
+	}
+	return path + "?" + strings.Join(filters, "&")
+}
+
+// locationValue formats a key value the way a filter expects it.
+func locationValue(val interface{}) string {
+	switch v := val.(type) {
+	case [16]byte:
+		// uuid columns scan as raw bytes
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReturnPreference(t *testing.T) {
+	tests := map[string]string{
+		"":                      returnRepresentation,
+		"return=representation": returnRepresentation,
+		"return=minimal":        returnMinimal,
+		"return=headers-only":   returnHeadersOnly,
+		"return=bogus":          returnRepresentation,
+		"resolution=merge-duplicates, return=minimal": returnMinimal,
+	}
+	for prefer, want := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/rest/v1/todos", nil)
+		if prefer != "" {
+			r.Header.Set("Prefer", prefer)
+		}
+		if got := returnPreference(r); got != want {
+			t.Errorf("returnPreference(%q) = %q, want %q", prefer, got, want)
+		}
+	}
+}
+
+func TestBuildReturningClause(t *testing.T) {
+	tests := []struct {
+		query string
+		names IdentifierCase
+		want  string
+	}{
+		{"", IdentifierCaseExact, "*"},
+		{"select=*", IdentifierCaseExact, "*"},
+		{"select=id,createdAt", IdentifierCaseExact, `"id", "createdAt"`},
+		{"select=id, createdAt", IdentifierCaseSnake, `"id", "created_at"`},
+		{"select=createdAt,meta->>ownerId", IdentifierCaseFold, `"createdat", "meta"->>'ownerId' AS "ownerId"`},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got, err := buildReturningClause(query, tt.names)
+		if err != nil {
+			t.Errorf("buildReturningClause(%q, %s) failed: %v", tt.query, tt.names, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("buildReturningClause(%q, %s) = %s, want %s", tt.query, tt.names, got, tt.want)
+		}
+	}
+
+	if _, err := buildReturningClause(url.Values{"select": {"id,"}}, IdentifierCaseExact); err == nil {
+		t.Error("buildReturningClause(select=id,) succeeded, want error")
+	}
+}
+
+func TestWriteMutation_Status(t *testing.T) {
+	s := &Server{}
+	results := []map[string]interface{}{{"id": 1}}
+
+	tests := []struct {
+		method  string
+		prefer  string
+		status  int
+		body    bool
+		applied string
+	}{
+		{http.MethodPost, "", http.StatusCreated, true, ""},
+		{http.MethodPost, "return=representation", http.StatusCreated, true, "return=representation"},
+		{http.MethodPatch, "", http.StatusOK, true, ""},
+		{http.MethodPatch, "return=minimal", http.StatusNoContent, false, "return=minimal"},
+		{http.MethodDelete, "return=representation", http.StatusOK, true, "return=representation"},
+		{http.MethodDelete, "return=headers-only", http.StatusNoContent, false, "return=headers-only"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/rest/v1/todos?id=eq.1", nil)
+		if tt.prefer != "" {
+			r.Header.Set("Prefer", tt.prefer)
+		}
+		w := httptest.NewRecorder()
+		s.writeMutation(r.Context(), nil, w, r, "todos", results)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %q: status = %d, want %d", tt.method, tt.prefer, w.Code, tt.status)
+		}
+		if got := w.Body.Len() > 0; got != tt.body {
+			t.Errorf("%s %q: body = %q", tt.method, tt.prefer, w.Body.String())
+		}
+		if got := w.Header().Get("Preference-Applied"); got != tt.applied {
+			t.Errorf("%s %q: Preference-Applied = %q, want %q", tt.method, tt.prefer, got, tt.applied)
+		}
+	}
+}
+
+func TestWriteMutation_ContentRange(t *testing.T) {
+	s := &Server{}
+	three := []map[string]interface{}{{"id": 1}, {"id": 2}, {"id": 3}}
+
+	tests := []struct {
+		method  string
+		prefer  string
+		results []map[string]interface{}
+		want    string
+		applied []string
+	}{
+		{http.MethodPatch, "return=minimal", three, "0-2/*", []string{"return=minimal"}},
+		{http.MethodPatch, "return=minimal, count=exact", three, "0-2/3", []string{"return=minimal", "count=exact"}},
+		{http.MethodDelete, "count=exact", nil, "*/0", []string{"count=exact"}},
+		{http.MethodDelete, "", nil, "*/*", nil},
+		{http.MethodPost, "count=exact", three, "", nil},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/rest/v1/todos?done=eq.true", nil)
+		if tt.prefer != "" {
+			r.Header.Set("Prefer", tt.prefer)
+		}
+		w := httptest.NewRecorder()
+		s.writeMutation(r.Context(), nil, w, r, "todos", tt.results)
+
+		if got := w.Header().Get("Content-Range"); got != tt.want {
+			t.Errorf("%s %q: Content-Range = %q, want %q", tt.method, tt.prefer, got, tt.want)
+		}
+		if got := w.Header().Values("Preference-Applied"); strings.Join(got, ",") != strings.Join(tt.applied, ",") {
+			t.Errorf("%s %q: Preference-Applied = %v, want %v", tt.method, tt.prefer, got, tt.applied)
+		}
+	}
+}
+
+func TestRowLocation(t *testing.T) {
+	id := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		pk   []string
+		row  map[string]interface{}
+		want string
+	}{
+		{"integer key", []string{"id"}, map[string]interface{}{"id": int64(7), "title": "x"}, "/rest/v1/todos?id=eq.7"},
+		{"uuid key", []string{"id"}, map[string]interface{}{"id": id}, "/rest/v1/todos?id=eq.123e4567-e89b-12d3-a456-426614174000"},
+		{"composite key", []string{"org", "at"}, map[string]interface{}{"org": "a&b", "at": created}, "/rest/v1/todos?org=eq.a%26b&at=eq.2024-01-02T03%3A04%3A05Z"},
+		{"no primary key", nil, map[string]interface{}{"id": 1}, ""},
+		{"key not returned", []string{"id"}, map[string]interface{}{"title": "x"}, ""},
+		{"null key", []string{"id"}, map[string]interface{}{"id": nil}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rowLocation("/rest/v1/todos", tt.pk, tt.row); got != tt.want {
+				t.Errorf("rowLocation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,490 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// RPCConfig restricts which Postgres functions /rest/v1/rpc can call.
+//
+// Function names are either bare ("add_numbers"), matching in any allowed
+// schema, or schema-qualified ("api.add_numbers").
+type RPCConfig struct {
+	// Schemas whose functions are callable, searched in order when
+	// resolving a name. Defaults to public.
+	Schemas []string
+	// Functions, when set, is the allowlist of callable functions.
+	Functions []string
+	// ServiceRoleFunctions can only be called with the service_role key.
+	ServiceRoleFunctions []string
+}
+
+// schemas returns the schemas RPC calls may resolve functions in.
+func (c RPCConfig) schemas() []string {
+	if len(c.Schemas) == 0 {
+		return []string{"public"}
+	}
+	return c.Schemas
+}
+
+// allows reports whether a function may be called at all. Service-role-only
+// functions are implicitly on the allowlist.
+func (c RPCConfig) allows(schema, name string) bool {
+	return len(c.Functions) == 0 || matchesFunctionName(c.Functions, schema, name) || c.serviceRoleOnly(schema, name)
+}
+
+// serviceRoleOnly reports whether a function is reserved for service_role.
+func (c RPCConfig) serviceRoleOnly(schema, name string) bool {
+	return matchesFunctionName(c.ServiceRoleFunctions, schema, name)
+}
+
+// matchesFunctionName reports whether a bare or schema-qualified entry in
+// list names the function.
+func matchesFunctionName(list []string, schema, name string) bool {
+	for _, entry := range list {
+		if entry == name || entry == schema+"."+name {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcFunction describes a callable function.
+type rpcFunction struct {
+	schema      string
+	name        string
+	argNames    []string // input argument names, in order
+	argTypes    []string // input argument types (format_type), in order
+	numDefaults int      // trailing input arguments with defaults
+	returnsSet  bool     // RETURNS SETOF / RETURNS TABLE
+	returnsRow  bool     // returns a composite type or record
+	returnsVoid bool
+}
+
+// requiredArgs returns the input arguments that have no default value.
+func (f *rpcFunction) requiredArgs() []string {
+	return f.argNames[:len(f.argNames)-f.numDefaults]
+}
+
+// rpcReservedParams are query parameters that shape the result rather
+// than being passed to the function.
+var rpcReservedParams = map[string]bool{
+	"select": true,
+	"order":  true,
+	"limit":  true,
+	"offset": true,
+	"apikey": true,
+}
+
+// handleRPC calls a Postgres function like PostgREST's /rpc/{function}.
+//
+// POST passes the JSON body's keys as named arguments. GET (and HEAD) pass
+// query parameters as named arguments and run in a read-only transaction,
+// so only functions that don't modify data can be called that way. Query
+// parameters that aren't function arguments filter set-returning results
+// (e.g. ?select=id,name&age=gt.18&order=name&limit=10).
+//
+// A function with a single unnamed json/jsonb argument receives the whole
+// request body.
+func (s *Server) handleRPC(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, fnName string) {
+	if fnName == "" {
+		http.Error(w, "function name required", http.StatusNotFound)
+		return
+	}
+
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if !readOnly && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	// Collect arguments
+	var rawBody []byte
+	args := make(map[string]interface{})
+	if !readOnly {
+		var err error
+		rawBody, err = io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(rawBody)) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(rawBody))
+			decoder.UseNumber() // keep numeric arguments exact
+			var body interface{}
+			if err := decoder.Decode(&body); err != nil {
+				http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+				return
+			}
+			// Non-object bodies can only go to a single json/jsonb argument
+			if obj, ok := body.(map[string]interface{}); ok {
+				args = obj
+			}
+		}
+	}
+
+	candidates, err := lookupRPCFunctions(ctx, conn, s.config.RPC.schemas(), fnName)
+	if err != nil {
+		writeDBError(w, "function lookup error", err, http.StatusInternalServerError)
+		return
+	}
+	// Functions outside the allowlist look the same as missing ones
+	if len(candidates) == 0 || !s.config.RPC.allows(candidates[0].schema, fnName) {
+		http.Error(w, fmt.Sprintf("function %s not found", fnName), http.StatusNotFound)
+		return
+	}
+	schema := candidates[0].schema
+	if readOnly {
+		args = rpcQueryArgs(candidates, query)
+	}
+	if s.config.RPC.serviceRoleOnly(schema, fnName) {
+		if role := rls.RoleForClaims(requestClaims(r)); role != rls.RoleServiceRole {
+			// Like PostgREST's permission errors: 401 without a session, 403 with one
+			status := http.StatusForbidden
+			if role == rls.RoleAnon {
+				status = http.StatusUnauthorized
+			}
+			http.Error(w, fmt.Sprintf("permission denied for function %s.%s", schema, fnName), status)
+			return
+		}
+	}
+
+	fn, singleJSON := resolveRPCFunction(candidates, args, readOnly)
+	if fn == nil {
+		names := make([]string, 0, len(args))
+		for name := range args {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		http.Error(w, fmt.Sprintf("could not find function %s.%s(%s)", schema, fnName, strings.Join(names, ", ")), http.StatusNotFound)
+		return
+	}
+
+	// Build the call with named arguments
+	var callArgs []string
+	var params []interface{}
+	if singleJSON {
+		params = append(params, string(rawBody))
+		callArgs = append(callArgs, fmt.Sprintf("$1::%s", fn.argTypes[0]))
+	} else {
+		for i, name := range fn.argNames {
+			value, ok := args[name]
+			if !ok {
+				continue // use the default
+			}
+			param, err := rpcParam(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid value for argument %s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			params = append(params, param)
+			callArgs = append(callArgs, fmt.Sprintf("%s => %s", quoteIdentifier(name), rpcArgExpr(len(params), fn.argTypes[i], value)))
+		}
+	}
+	call := fmt.Sprintf("%s.%s(%s)", quoteIdentifier(fn.schema), quoteIdentifier(fn.name), strings.Join(callArgs, ", "))
+
+	filters := rpcFilters(fn, query, readOnly)
+
+	var sqlQuery string
+	var whereArgs []interface{}
+	if fn.returnsRow || fn.returnsSet {
+		selectClause := "*"
+		if selectVals := query["select"]; len(selectVals) > 0 && fn.returnsRow {
+			cols, _ := parseSelectClause(selectVals[0])
+			quoted := make([]string, 0, len(cols))
+			for _, col := range cols {
+				q, err := buildSelectColumn(s.config.REST.IdentifierCase.column(col))
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+					return
+				}
+				quoted = append(quoted, q)
+			}
+			selectClause = strings.Join(quoted, ", ")
+		}
+
+		source := call + " AS " + quoteIdentifier(fn.name)
+		if !fn.returnsRow {
+			// SETOF scalar: name the single output column after the function
+			source = fmt.Sprintf("%s AS _rpc(%s)", call, quoteIdentifier(fn.name))
+		}
+		sqlQuery = fmt.Sprintf("SELECT %s FROM %s", selectClause, source)
+
+		var whereClause string
+		whereClause, whereArgs, err = s.buildWhereClause(filters, len(params))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+			return
+		}
+		if whereClause != "" {
+			sqlQuery += " WHERE " + whereClause
+		}
+		if orderVals := query["order"]; len(orderVals) > 0 {
+			orderClause, err := buildOrderClause(orderVals[0], s.config.REST.IdentifierCase)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid order: %v", err), http.StatusBadRequest)
+				return
+			}
+			sqlQuery += " ORDER BY " + orderClause
+		}
+		if limitVals := query["limit"]; len(limitVals) > 0 {
+			limit, err := parseRowCount("limit", limitVals[0])
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sqlQuery += fmt.Sprintf(" LIMIT %d", limit)
+		}
+		if offsetVals := query["offset"]; len(offsetVals) > 0 {
+			offset, err := parseRowCount("offset", offsetVals[0])
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sqlQuery += fmt.Sprintf(" OFFSET %d", offset)
+		}
+	} else {
+		sqlQuery = fmt.Sprintf("SELECT %s AS %s", call, quoteIdentifier(fn.name))
+	}
+
+	accessMode := pgx.ReadWrite
+	if readOnly {
+		accessMode = pgx.ReadOnly
+	}
+	tx, err := s.beginRequest(ctx, conn, r, pgx.TxOptions{AccessMode: accessMode})
+	if err != nil {
+		writeBeginError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, sqlQuery, append(params, whereArgs...)...)
+	if err != nil {
+		writeDBError(w, "query error", err, http.StatusBadRequest)
+		return
+	}
+
+	results := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			rows.Close()
+			writeDBError(w, "row scan error", err, http.StatusInternalServerError)
+			return
+		}
+		result := make(map[string]interface{})
+		for i, col := range rows.FieldDescriptions() {
+			result[col.Name] = values[i]
+		}
+		results = append(results, result)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeDBError(w, "query error", err, http.StatusBadRequest)
+		return
+	}
+
+	// A request being explained is rolled back
+	if !recorder.IsDryRun(ctx) {
+		if err := tx.Commit(ctx); err != nil {
+			writeDBError(w, "commit error", err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if fn.returnsVoid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Shape the response like PostgREST
+	var response interface{}
+	switch {
+	case fn.returnsSet && fn.returnsRow:
+		response = results
+	case fn.returnsSet:
+		values := make([]interface{}, 0, len(results))
+		for _, result := range results {
+			values = append(values, result[fn.name])
+		}
+		response = values
+	case fn.returnsRow:
+		if len(results) > 0 {
+			response = results[0]
+		}
+	default:
+		if len(results) > 0 {
+			response = results[0][fn.name]
+		}
+	}
+
+	s.writeJSON(w, r, http.StatusOK, response)
+}
+
+// lookupRPCFunctions returns all overloads of a function in the first of
+// the given schemas that has one, like a search_path.
+func lookupRPCFunctions(ctx context.Context, conn *pgx.Conn, schemas []string, name string) ([]*rpcFunction, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			n.nspname,
+			COALESCE(p.proargnames, ARRAY[]::text[]),
+			COALESCE(p.proargmodes::text[], ARRAY[]::text[]),
+			ARRAY(
+				SELECT format_type(a.oid, NULL)
+				FROM unnest(p.proargtypes::oid[]) WITH ORDINALITY AS a(oid, ord)
+				ORDER BY a.ord
+			),
+			p.pronargdefaults,
+			p.proretset,
+			t.typtype = 'c' OR p.prorettype = 'record'::regtype,
+			p.prorettype = 'void'::regtype
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		JOIN pg_type t ON t.oid = p.prorettype
+		WHERE n.nspname = ANY($2) AND p.proname = $1 AND p.prokind = 'f'
+		ORDER BY array_position($2, n.nspname::text)
+	`, name, schemas)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var functions []*rpcFunction
+	for rows.Next() {
+		var allNames, modes []string
+		fn := &rpcFunction{name: name}
+		if err := rows.Scan(&fn.schema, &allNames, &modes, &fn.argTypes, &fn.numDefaults, &fn.returnsSet, &fn.returnsRow, &fn.returnsVoid); err != nil {
+			return nil, err
+		}
+
+		// proargnames includes OUT arguments when proargmodes is set;
+		// keep only the input ones, which line up with proargtypes
+		for i, argName := range allNames {
+			if len(modes) == 0 || modes[i] == "i" || modes[i] == "b" || modes[i] == "v" {
+				fn.argNames = append(fn.argNames, argName)
+			}
+		}
+		if len(fn.argNames) != len(fn.argTypes) {
+			// Unnamed arguments (other than a lone json argument) can't be
+			// called by name; keep positions but leave them unnamed
+			fn.argNames = make([]string, len(fn.argTypes))
+			copy(fn.argNames, allNames)
+		}
+		if len(functions) > 0 && functions[0].schema != fn.schema {
+			break // overloads in later schemas are shadowed
+		}
+		functions = append(functions, fn)
+	}
+	return functions, rows.Err()
+}
+
+// resolveRPCFunction picks the overload whose input arguments match the
+// provided names: every provided name must be an argument and every
+// argument without a default must be provided. singleJSON reports that the
+// function takes the whole body as one unnamed json/jsonb argument.
+func resolveRPCFunction(candidates []*rpcFunction, args map[string]interface{}, readOnly bool) (fn *rpcFunction, singleJSON bool) {
+	for _, candidate := range candidates {
+		if matchesRPCArgs(candidate, args) {
+			return candidate, false
+		}
+	}
+	if !readOnly {
+		for _, candidate := range candidates {
+			if len(candidate.argTypes) == 1 && candidate.argNames[0] == "" &&
+				(candidate.argTypes[0] == "json" || candidate.argTypes[0] == "jsonb") {
+				return candidate, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// rpcQueryArgs collects the arguments of a GET call: the query parameters
+// naming an argument of some overload. The rest filter the result.
+func rpcQueryArgs(candidates []*rpcFunction, query url.Values) map[string]interface{} {
+	args := make(map[string]interface{})
+	for key, values := range query {
+		if rpcReservedParams[key] || len(values) == 0 {
+			continue
+		}
+		for _, candidate := range candidates {
+			if containsColumn(candidate.argNames, key) {
+				args[key] = values[0]
+				break
+			}
+		}
+	}
+	return args
+}
+
+// rpcFilters returns the query parameters that filter fn's result:
+// everything that isn't one of its arguments. POST arguments come from the
+// body, so all parameters filter.
+func rpcFilters(fn *rpcFunction, query url.Values, readOnly bool) url.Values {
+	filters := url.Values{}
+	for key, values := range query {
+		if !readOnly || !containsColumn(fn.argNames, key) {
+			filters[key] = values
+		}
+	}
+	return filters
+}
+
+func matchesRPCArgs(fn *rpcFunction, args map[string]interface{}) bool {
+	for name := range args {
+		if name == "" || !containsColumn(fn.argNames, name) {
+			return false
+		}
+	}
+	for _, name := range fn.requiredArgs() {
+		if _, ok := args[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rpcParam converts a decoded JSON value (or query string value) into the
+// text form passed to Postgres, where it is cast to the argument's type.
+func rpcParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		// Objects and arrays travel as JSON text
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+}
+
+// rpcArgExpr returns the SQL expression for parameter $n cast to argType.
+// JSON arrays passed to non-JSON array arguments are unpacked element-wise.
+func rpcArgExpr(n int, argType string, value interface{}) string {
+	if _, isArray := value.([]interface{}); isArray && strings.HasSuffix(argType, "[]") {
+		return fmt.Sprintf("ARRAY(SELECT jsonb_array_elements_text($%d::jsonb))::%s", n, argType)
+	}
+	return fmt.Sprintf("$%d::%s", n, argType)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestResolveRPCFunction(t *testing.T) {
+	add := &rpcFunction{name: "add", argNames: []string{"a", "b"}, argTypes: []string{"integer", "integer"}}
+	addDefault := &rpcFunction{name: "add", argNames: []string{"a", "b", "c"}, argTypes: []string{"integer", "integer", "integer"}, numDefaults: 1}
+	handler := &rpcFunction{name: "handler", argNames: []string{""}, argTypes: []string{"jsonb"}}
+
+	tests := []struct {
+		name       string
+		candidates []*rpcFunction
+		args       map[string]interface{}
+		readOnly   bool
+		want       *rpcFunction
+		wantSingle bool
+	}{
+		{"exact match", []*rpcFunction{add}, map[string]interface{}{"a": 1, "b": 2}, false, add, false},
+		{"default omitted", []*rpcFunction{addDefault}, map[string]interface{}{"a": 1, "b": 2}, false, addDefault, false},
+		{"missing required", []*rpcFunction{add}, map[string]interface{}{"a": 1}, false, nil, false},
+		{"unknown argument", []*rpcFunction{add}, map[string]interface{}{"a": 1, "b": 2, "x": 3}, false, nil, false},
+		{"overload by names", []*rpcFunction{add, addDefault}, map[string]interface{}{"a": 1, "b": 2, "c": 3}, false, addDefault, false},
+		{"single json argument", []*rpcFunction{handler}, map[string]interface{}{"anything": 1}, false, handler, true},
+		{"single json not via GET", []*rpcFunction{handler}, map[string]interface{}{"anything": "1"}, true, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, single := resolveRPCFunction(tt.candidates, tt.args, tt.readOnly)
+			if got != tt.want || single != tt.wantSingle {
+				t.Errorf("resolveRPCFunction() = %v, %v; want %v, %v", got, single, tt.want, tt.wantSingle)
+			}
+		})
+	}
+}
+
+func TestRPCQueryArgs(t *testing.T) {
+	// GET /rpc/adults?min_age=18&name=eq.Ann&select=name: min_age is an
+	// argument, name filters the rows the function returns
+	adults := &rpcFunction{name: "adults", argNames: []string{"min_age"}, argTypes: []string{"integer"}, returnsSet: true}
+	adultsIn := &rpcFunction{name: "adults", argNames: []string{"min_age", "city"}, argTypes: []string{"integer", "text"}, returnsSet: true}
+	query := url.Values{"min_age": {"18"}, "name": {"eq.Ann"}, "select": {"name"}}
+
+	args := rpcQueryArgs([]*rpcFunction{adults, adultsIn}, query)
+	if want := map[string]interface{}{"min_age": "18"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("rpcQueryArgs() = %v, want %v", args, want)
+	}
+	fn, _ := resolveRPCFunction([]*rpcFunction{adults, adultsIn}, args, true)
+	if fn != adults {
+		t.Fatalf("resolveRPCFunction() = %v, want %v", fn, adults)
+	}
+	filters := rpcFilters(fn, query, true)
+	if want := (url.Values{"name": {"eq.Ann"}, "select": {"name"}}); !reflect.DeepEqual(filters, want) {
+		t.Errorf("rpcFilters() = %v, want %v", filters, want)
+	}
+
+	// An argument of another overload picks that overload
+	query.Set("city", "Oslo")
+	args = rpcQueryArgs([]*rpcFunction{adults, adultsIn}, query)
+	if fn, _ := resolveRPCFunction([]*rpcFunction{adults, adultsIn}, args, true); fn != adultsIn {
+		t.Errorf("with city: resolveRPCFunction() = %v, want %v", fn, adultsIn)
+	}
+
+	// POST arguments come from the body, so every parameter filters
+	if filters := rpcFilters(adults, url.Values{"min_age": {"eq.18"}}, false); filters.Get("min_age") != "eq.18" {
+		t.Errorf("POST: rpcFilters() = %v, want min_age kept", filters)
+	}
+}
+
+func TestRPCParam(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{nil, nil},
+		{"text", "text"},
+		{json.Number("12345678901234567890"), "12345678901234567890"},
+		{true, "true"},
+		{[]interface{}{"a", "b"}, `["a","b"]`},
+		{map[string]interface{}{"k": "v"}, `{"k":"v"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := rpcParam(tt.value)
+		if err != nil {
+			t.Fatalf("rpcParam(%v) error: %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("rpcParam(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRPCArgExpr(t *testing.T) {
+	if got := rpcArgExpr(1, "integer[]", []interface{}{1, 2}); got != "ARRAY(SELECT jsonb_array_elements_text($1::jsonb))::integer[]" {
+		t.Errorf("array argument = %q", got)
+	}
+	if got := rpcArgExpr(2, "jsonb", []interface{}{1}); got != "$2::jsonb" {
+		t.Errorf("jsonb argument = %q", got)
+	}
+}
+
+func TestRPCConfig(t *testing.T) {
+	var open RPCConfig
+	if got := open.schemas(); len(got) != 1 || got[0] != "public" {
+		t.Errorf("default schemas = %v, want [public]", got)
+	}
+	if !open.allows("public", "anything") {
+		t.Error("empty allowlist should allow every function")
+	}
+
+	cfg := RPCConfig{
+		Functions:            []string{"search", "api.stats"},
+		ServiceRoleFunctions: []string{"public.purge"},
+	}
+	tests := []struct {
+		schema, name string
+		allowed      bool
+		serviceRole  bool
+	}{
+		{"public", "search", true, false},
+		{"api", "search", true, false},
+		{"api", "stats", true, false},
+		{"public", "stats", false, false},
+		{"public", "purge", true, true},
+		{"api", "purge", false, false},
+	}
+	for _, tt := range tests {
+		if got := cfg.allows(tt.schema, tt.name); got != tt.allowed {
+			t.Errorf("allows(%s, %s) = %v, want %v", tt.schema, tt.name, got, tt.allowed)
+		}
+		if got := cfg.serviceRoleOnly(tt.schema, tt.name); got != tt.serviceRole {
+			t.Errorf("serviceRoleOnly(%s, %s) = %v, want %v", tt.schema, tt.name, got, tt.serviceRole)
+		}
+	}
+}
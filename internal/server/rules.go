@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/markb/supalite/internal/rls"
+	"github.com/markb/supalite/internal/rules"
+)
+
+// apiRateLimitPaths are the APIs Config.APIRateLimits apply to. /rest
+// covers every REST version.
+var apiRateLimitPaths = []string{"/rest", "/auth/v1"}
+
+// initRules builds the route rules engine when rules or API rate limits
+// are configured.
+func (s *Server) initRules() error {
+	routeRules := slices.Clone(s.config.Rules)
+	if s.config.AuthProvider == nil && s.config.AuthMode == AuthModeNative {
+		routeRules = append(routeRules, s.nativeAuthRateLimits()...)
+	}
+	for _, path := range apiRateLimitPaths {
+		for _, limit := range s.config.APIRateLimits {
+			routeRules = append(routeRules, rules.Rule{Path: path, RateLimit: &limit})
+		}
+	}
+	if len(routeRules) == 0 {
+		return nil
+	}
+	engine, err := rules.New(routeRules, s.requestRole)
+	if err != nil {
+		return err
+	}
+	s.rules = engine
+	return nil
+}
+
+// nativeAuthRateLimits are the per-address budgets native auth enforces
+// where GoTrue would: code verification, sign-in and refresh, and recovery
+// mails. Auth rate limit settings override GoTrue's defaults, as they would
+// GoTrue's own.
+func (s *Server) nativeAuthRateLimits() []rules.Rule {
+	verify, tokens, emails := 30.0, 150.0, 30.0
+	if limits := s.config.RateLimits; limits != nil {
+		if limits.Verify > 0 {
+			verify = limits.Verify
+		}
+		if limits.TokenRefresh > 0 {
+			tokens = limits.TokenRefresh
+		}
+		if limits.EmailSent > 0 {
+			emails = limits.EmailSent
+		}
+	}
+	budget := func(path string, requests float64, window time.Duration) rules.Rule {
+		return rules.Rule{Path: path, Methods: []string{http.MethodPost}, RateLimit: &rules.RateLimit{
+			Requests: max(int(requests), 1),
+			Window:   window,
+			Per:      rules.PerIP,
+		}}
+	}
+	return []rules.Rule{
+		budget("/auth/v1/verify", verify, 5*time.Minute),
+		budget("/auth/v1/token", tokens, 5*time.Minute),
+		budget("/auth/v1/recover", emails, time.Hour),
+	}
+}
+
+// requestRole returns the role of the key or token a request carries, or
+// "" when it carries none or it doesn't verify. Route rules match on it.
+func (s *Server) requestRole(r *http.Request) string {
+	claims := s.tokenClaims(r)
+	if claims == nil {
+		return ""
+	}
+	return rls.RoleForClaims(claims)
+}
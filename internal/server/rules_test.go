@@ -0,0 +1,131 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/rules"
+)
+
+func TestRules_CallerRole(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	s := &Server{keyManager: keyManager, config: Config{Rules: []rules.Rule{
+		{Path: "/rest/v1", Methods: []string{http.MethodDelete}, Roles: []string{"anon"}, Deny: true},
+	}}}
+	if err := s.initRules(); err != nil {
+		t.Fatalf("initRules() failed: %v", err)
+	}
+	handler := s.resolveAPIKeys(s.rules.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		wantCode int
+	}{
+		{"anon key", keyManager.GetAnonKey(), http.StatusForbidden},
+		{"publishable key", keyManager.GetPublishableKey(), http.StatusForbidden},
+		{"service key", keyManager.GetServiceKey(), http.StatusOK},
+		{"no key", "", http.StatusOK}, // rejected later by requireAPIKey
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/rest/v1/todos?id=eq.1", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("apikey", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRules_APIRateLimits(t *testing.T) {
+	s := &Server{config: Config{APIRateLimits: []rules.RateLimit{
+		{Requests: 3, Window: time.Minute, Per: rules.PerIP},
+		{Requests: 1, Window: time.Minute, Per: rules.PerKey},
+	}}}
+	if err := s.initRules(); err != nil {
+		t.Fatalf("initRules() failed: %v", err)
+	}
+	handler := s.rules.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/rest/v1/todos", "key-a"); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d", rec.Code)
+	}
+	// key-a has used its budget; key-b has its own, but shares the address
+	rec := get("/rest/v1/todos", "key-a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("over key budget: status = %d, Retry-After = %q, want 429 60", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := get("/rest/v2/todos", "key-b"); rec.Code != http.StatusOK {
+		t.Errorf("other key: status = %d, want 200", rec.Code)
+	}
+	if rec := get("/rest/todos", "key-c"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("over address budget: status = %d, want 429", rec.Code)
+	}
+
+	// The auth API has its own budget, and other routes aren't limited
+	if rec := get("/auth/v1/settings", "key-a"); rec.Code != http.StatusOK {
+		t.Errorf("auth API: status = %d, want 200", rec.Code)
+	}
+	for range 3 {
+		if rec := get("/storage/v1/bucket", "key-a"); rec.Code != http.StatusOK {
+			t.Errorf("storage API: status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestRules_NativeAuthRateLimits(t *testing.T) {
+	s := &Server{config: Config{AuthMode: AuthModeNative, RateLimits: &auth.RateLimits{Verify: 2}}}
+	if err := s.initRules(); err != nil {
+		t.Fatalf("initRules() failed: %v", err)
+	}
+	handler := s.rules.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+
+	for i := range 2 {
+		if code := post("/auth/v1/verify"); code != http.StatusOK {
+			t.Fatalf("verify %d: status = %d, want 200", i+1, code)
+		}
+	}
+	if code := post("/auth/v1/verify"); code != http.StatusTooManyRequests {
+		t.Errorf("verify over budget: status = %d, want 429", code)
+	}
+	// Other endpoints have their own budgets
+	if code := post("/auth/v1/token?grant_type=password"); code != http.StatusOK {
+		t.Errorf("token: status = %d, want 200", code)
+	}
+	if code := post("/auth/v1/recover"); code != http.StatusOK {
+		t.Errorf("recover: status = %d, want 200", code)
+	}
+
+	// GoTrue enforces its own limits
+	s = &Server{config: Config{}}
+	if err := s.initRules(); err != nil || s.rules != nil {
+		t.Errorf("GoTrue mode: rules = %v, %v, want none", s.rules, err)
+	}
+}
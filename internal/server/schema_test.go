@@ -123,3 +123,69 @@ func TestCapturedEmailsTableCreation(t *testing.T) {
 		t.Errorf("Expected 3 indexes on captured_emails (primary key + 2 custom), got %d", indexCount)
 	}
 }
+
+func TestAddTimestamps(t *testing.T) {
+	db := pg.NewEmbeddedDatabase(pg.Config{
+		Port:        15436,
+		Username:    "test",
+		Password:    "test",
+		Database:    "testdb",
+		RuntimePath: "/tmp/supalite-test-timestamps",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if err := db.Start(ctx); err != nil {
+		t.Fatalf("Failed to start database: %v", err)
+	}
+	defer db.Stop()
+
+	srv := &Server{
+		pgDatabase: db,
+	}
+	if err := srv.initSchema(ctx); err != nil {
+		t.Fatalf("initSchema() failed: %v", err)
+	}
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, `
+		DROP TABLE IF EXISTS public.notes;
+		CREATE TABLE public.notes (id int PRIMARY KEY, body text);
+		INSERT INTO public.notes VALUES (1, 'first');
+	`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// Twice, to check it's safe to rerun
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Exec(ctx, "SELECT extensions.add_timestamps('notes')"); err != nil {
+			t.Fatalf("add_timestamps() failed: %v", err)
+		}
+	}
+
+	var createdAt, updatedAt time.Time
+	err = conn.QueryRow(ctx, "SELECT created_at, updated_at FROM public.notes WHERE id = 1").Scan(&createdAt, &updatedAt)
+	if err != nil {
+		t.Fatalf("Failed to read timestamps: %v", err)
+	}
+
+	// now() is fixed within a transaction, so update in a new one
+	time.Sleep(10 * time.Millisecond)
+	var newUpdatedAt, newCreatedAt time.Time
+	err = conn.QueryRow(ctx, "UPDATE public.notes SET body = 'second' WHERE id = 1 RETURNING created_at, updated_at").Scan(&newCreatedAt, &newUpdatedAt)
+	if err != nil {
+		t.Fatalf("Failed to update row: %v", err)
+	}
+	if !newUpdatedAt.After(updatedAt) {
+		t.Errorf("updated_at = %v after UPDATE, want later than %v", newUpdatedAt, updatedAt)
+	}
+	if !newCreatedAt.Equal(createdAt) {
+		t.Errorf("created_at changed on UPDATE: %v, was %v", newCreatedAt, createdAt)
+	}
+}
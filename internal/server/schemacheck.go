@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/markb/supalite/internal/schemacheck"
+)
+
+// upgradeSchema refuses a database written by a newer supalite, and
+// brings one from an older supalite up to date before the components
+// create their tables.
+func (s *Server) upgradeSchema(ctx context.Context) error {
+	conn, err := s.pgDatabase.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	version, err := schemacheck.CheckVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if version < schemacheck.Version {
+		if err := schemacheck.Upgrade(ctx, conn, version); err != nil {
+			return err
+		}
+		if version > 0 {
+			logger.Info("upgraded schema", "from", version, "to", schemacheck.Version)
+		}
+	}
+	return nil
+}
+
+// checkSchema verifies that the tables the running components need exist,
+// once they have all created theirs, and records the schema version. A
+// missing table stops startup with a report; one only an optional
+// component needs is logged.
+func (s *Server) checkSchema(ctx context.Context) error {
+	conn, err := s.pgDatabase.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	problems, err := schemacheck.Verify(ctx, conn, s.schemaRequirements())
+	if err != nil {
+		return fmt.Errorf("failed to check the schema: %w", err)
+	}
+	for _, p := range problems {
+		if p.Optional {
+			logger.Warn("schema check", "component", p.Component, "table", p.Table, "problem", p.Message)
+		}
+	}
+	if err := schemacheck.Report(problems); err != nil {
+		return err
+	}
+	return schemacheck.Stamp(ctx, conn)
+}
+
+// schemaRequirements returns the tables the configured components need.
+func (s *Server) schemaRequirements() []schemacheck.Requirement {
+	reqs := []schemacheck.Requirement{
+		{Table: "admin.users", Columns: []string{"id", "email", "password_hash"}, Component: "dashboard"},
+		{Table: "public.captured_emails", Columns: []string{"from_addr", "to_addr", "subject", "text_body", "html_body", "raw_message"}, Component: "mail capture"},
+		{Table: "public.captured_sms", Columns: []string{"to_phone", "otp", "user_id", "payload"}, Component: "SMS capture"},
+		{Table: "storage.buckets", Columns: []string{"id", "name", "public", "file_size_limit", "allowed_mime_types"}, Component: "storage"},
+		{Table: "storage.objects", Columns: []string{"id", "bucket_id", "name", "owner", "metadata"}, Component: "storage"},
+	}
+	switch {
+	case s.authServer != nil:
+		// GoTrue runs without its tables only to fail its requests, but
+		// supalite already serves without GoTrue when it can't be started
+		reqs = append(reqs,
+			schemacheck.Requirement{Table: "auth.schema_migrations", Component: "auth (GoTrue migrations)", NotEmpty: true, Optional: true},
+			schemacheck.Requirement{Table: "auth.users", Columns: []string{"id", "email"}, Component: "auth", Optional: true},
+		)
+	case s.authProvider != nil:
+		reqs = append(reqs, schemacheck.Requirement{Table: "auth.users", Columns: []string{"id", "email"}, Component: "auth"})
+	}
+	if s.config.MigrationsDir != "" {
+		reqs = append(reqs, schemacheck.Requirement{Table: "supabase_migrations.schema_migrations", Columns: []string{"version"}, Component: "migrations"})
+	}
+	return reqs
+}
@@ -3,58 +3,233 @@ package server
 import (
 	"context"
 	cryptoRand "crypto/rand"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/auditlog"
 	"github.com/markb/supalite/internal/auth"
+	"github.com/markb/supalite/internal/auth/native"
+	"github.com/markb/supalite/internal/chaos"
 	"github.com/markb/supalite/internal/dashboard"
+	"github.com/markb/supalite/internal/history"
 	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/limits"
 	"github.com/markb/supalite/internal/log"
 	"github.com/markb/supalite/internal/mailcapture"
+	"github.com/markb/supalite/internal/notify"
 	"github.com/markb/supalite/internal/pg"
+	"github.com/markb/supalite/internal/pgnet"
 	"github.com/markb/supalite/internal/prest"
+	"github.com/markb/supalite/internal/realtime"
+	"github.com/markb/supalite/internal/recorder"
+	"github.com/markb/supalite/internal/rules"
+	"github.com/markb/supalite/internal/schemacache"
+	"github.com/markb/supalite/internal/schemacheck"
+	"github.com/markb/supalite/internal/status"
+	"github.com/markb/supalite/internal/storage"
+	"github.com/markb/supalite/internal/tracing"
+	"github.com/markb/supalite/internal/usage"
+	"github.com/markb/supalite/internal/watchdog"
 	"github.com/rs/cors"
+	"golang.org/x/crypto/acme/autocert"
 )
 
+// logger logs for the server component.
+var logger = log.Component("server")
+
 type Server struct {
 	config     Config
 	router     *chi.Mux
 	httpServer *http.Server
 
-	pgDatabase    *pg.EmbeddedDatabase
-	prestServer   *prest.Server
-	authServer    *auth.Server
-	keyManager    *keys.Manager
-	captureServer *mailcapture.Server
+	tlsConfig      *tls.Config       // HTTPS settings, nil unless Config.TLS is set
+	acme           *autocert.Manager // ACME certificates, nil unless Config.TLS has ACMEDomains
+	redirectServer *http.Server      // Plain HTTP listener for ACME challenges and redirects
+
+	pgDatabase      database // Embedded PostgreSQL, or the server at Config.DatabaseURL
+	prestServer     *prest.Server
+	authProvider    auth.Provider // Serves /auth/v1: authServer, native auth, or Config.AuthProvider
+	authServer      *auth.Server  // GoTrue, nil unless it is the auth provider
+	keyManager      *keys.Manager
+	captureServer   *mailcapture.Server
 	dashboardServer *dashboard.Server
+	realtimeServer  *realtime.Server
+	schemaCache     *schemacache.Cache // Tables and relationships, for embedding and /docs
+	storageServer   *storage.Server
+	netWorker       *pgnet.Worker
+	historyWorker   *history.Worker
+	watchdog        *watchdog.Watchdog
+	statusMonitor   *status.Monitor     // Public status page, nil unless Config.Status is set
+	auditLog        *auditlog.Writer    // REST write log, nil unless Config.AuditLog is set
+	restRecorder    *recorder.Recorder  // Recent REST requests and their SQL, nil unless Config.RESTRecord is set
+	usageMeter      *usage.Meter        // Usage accounting and quotas, nil unless Config.Usage is set
+	chaos           *chaos.Injector     // Fault injection, nil unless Config.Chaos is set
+	rules           *rules.Engine       // Route rules, nil unless Config.Rules is set
+	authSettings    authSettingsCache   // Cached /auth/v1/settings response
+	authFailures    *authFailureCounter // Failed authentications per client, nil unless Config.Notifier is set
+
+	stopTracing func(context.Context) error // Flushes spans, nil unless Config.Tracing is set
+	eventMu     sync.Mutex                  // Serializes Config.OnEvent calls
+
+	startedAt     time.Time
+	authStarted   bool   // The auth provider was started; /health waits for it to be ready
+	userJWTSecret string // HS256 secret GoTrue signs user sessions with
 }
 
 type Config struct {
-	Host         string
-	Port         int
-	PGPort       uint16
-	DataDir      string
-	JWTSecret    string
-	SiteURL      string
-	PGUsername   string
-	PGPassword   string
-	PGDatabase   string
-	RuntimePath  string // Optional: unique runtime path for test isolation
-	AnonKey      string // Optional: pre-generated anon key
-	ServiceRoleKey string // Optional: pre-generated service_role key
-	Email        *auth.EmailConfig // Optional: email configuration for GoTrue
+	Host           string
+	Port           int
+	PGPort         uint16
+	DataDir        string
+	JWTSecret      string
+	SiteURL        string
+	PublicURL      string // Optional: address auth email links use instead of localhost
+	PGUsername     string
+	PGPassword     string
+	PGDatabase     string
+	DatabaseURL    string                           // Optional: serve from this PostgreSQL instead of the embedded one
+	PGVersion      string                           // Optional: embedded PostgreSQL version (default: pg.DefaultVersion)
+	PGSocketDir    string                           // Optional: embedded PostgreSQL listens on a unix socket here, not on PGPort (see pg.Config.SocketDir)
+	PGMinConns     int32                            // Optional: idle connections kept in the pool
+	PGMaxConns     int32                            // Optional: limit of all connection pools together
+	PGRolePools    map[string]int32                 // Optional: per-role pool sizes (default: see pg.Config.RolePools)
+	PGLocale       string                           // Optional: locale for a new cluster (see pg.Config.Locale)
+	PGICULocale    string                           // Optional: ICU collation for a new cluster
+	PGTimezone     string                           // Optional: PostgreSQL TimeZone
+	Extensions     []string                         // Optional: extensions to create on start, e.g. pgvector
+	RuntimePath    string                           // Optional: unique runtime path for test isolation
+	AnonKey        string                           // Optional: pre-generated anon key
+	ServiceRoleKey string                           // Optional: pre-generated service_role key
+	Email          *auth.EmailConfig                // Optional: email configuration for GoTrue
+	SeedUsers      []auth.SeedUser                  // Optional: auth users to create once GoTrue is ready
+	External       map[string]auth.ExternalProvider // Optional: OAuth providers by GoTrue name
+	SMS            *auth.SMSConfig                  // Optional: phone auth configuration for GoTrue
+	MFA            *auth.MFAConfig                  // Optional: GoTrue MFA settings
+	Captcha        *auth.CaptchaConfig              // Optional: captcha required on auth endpoints
+	Password       *auth.PasswordPolicy             // Optional: password requirements
+	RateLimits     *auth.RateLimits                 // Optional: GoTrue rate limit overrides
+	RPC            RPCConfig                        // Optional: restricts /rest/v1/rpc (default: all of public)
+	REST           RESTConfig                       // Optional: REST API versioning (default: /rest serves v1)
+	Response       ResponseConfig                   // Optional: default response body shaping
+
+	// Resource limits for the child processes (default: none)
+	PGLimits        limits.Limits
+	PGSharedBuffers string // Optional: overrides shared_buffers sizing
+	AuthLimits      limits.Limits
+
+	// Fault injection for tests (see package chaos): nil disables it
+	Chaos *ChaosConfig
+
+	// Route rules: headers, CORS, rate limits, and access restrictions by
+	// path, method, and caller role (see package rules)
+	Rules []rules.Rule
+
+	// Per-client budgets for /rest and /auth/v1, applied after Rules. Each
+	// API has its own budgets.
+	APIRateLimits []rules.RateLimit
+
+	// Operational alerts (see package notify): nil disables them
+	Notifier *notify.Notifier
+
+	// Failed authentications one client may cause within AuthFailureWindow
+	// before an alert is sent (default: 20 in 5 minutes)
+	AuthFailureThreshold int
+	AuthFailureWindow    time.Duration
+
+	// Public status page at /status (see package status): nil disables it.
+	// Checks are filled in by Start.
+	Status *status.Config
+
+	// HTTPS (see TLSConfig): nil serves plain HTTP
+	TLS *TLSConfig
+
+	// OpenTelemetry trace export (see package tracing): nil disables it
+	Tracing *tracing.Config
+
+	// Request logging: a line per request, and a table of writes made
+	// through the REST API (see package auditlog). Database is filled in by
+	// Start. Both are off by default.
+	AccessLog bool
+	AuditLog  *auditlog.Config
+
+	// Recent REST requests kept with the SQL they ran, for debugging (see
+	// package recorder): 0 disables recording
+	RESTRecord int
+
+	// Usage accounting and quotas per API key (see package usage): nil
+	// disables them. Database and OnLimit are filled in by Start.
+	Usage *usage.Config
+
+	// Disk and memory watchdog (see package watchdog). DataDir, WALDir, and
+	// Processes are filled in by Start.
+	Watchdog watchdog.Config
+
+	// Row-level change history (see package history). Database is filled
+	// in by Start.
+	History history.Config
+
+	// Directory of SQL migrations to apply at startup (see package
+	// migrate): empty applies none
+	MigrationsDir string
+
+	// Directory of migrations the management API lists and applies:
+	// defaults to MigrationsDir, or migrate.FindDir() when that is empty
+	ManagedMigrationsDir string
+
+	PREST        bool   // Serve pREST's API at /prest (service_role only); off by default
+	Docs         bool   // Serve API docs generated from the schema at /docs; off by default
+	GoTrueBinary string // Optional: GoTrue binary to run instead of finding or downloading one
+	AuthMode     string // Optional: AuthModeGoTrue (default) or AuthModeNative
+
+	// Serves /auth/v1 instead of GoTrue or native auth, ignoring AuthMode.
+	// It is started and stopped with the server and must accept sessions
+	// signed with JWTSecret, as REST, storage, and realtime verify them.
+	AuthProvider auth.Provider
+
+	// Ephemeral mode (for tests): DataDir is replaced by a temporary
+	// directory, on a tmpfs when possible, that is deleted on exit, and
+	// PostgreSQL runs without fsync
+	Ephemeral bool
+
+	// Called as components start, fail, and stop (see Event), for programs
+	// that embed the server. Calls are serialized, and come from Start,
+	// from the goroutines watching components, and from shutdown, so it
+	// shouldn't block.
+	OnEvent func(Event)
+
+	// Deterministic mode (for CI): keys derived from DeterministicSeed,
+	// no binary downloads, and email autoconfirm
+	Deterministic     bool
+	DeterministicSeed string // Optional: defaults to DefaultDeterministicSeed
+	ProjectRef        string // Optional: project ref for deterministic keys
 }
 
+// DefaultDeterministicSeed is used in deterministic mode when no seed is configured.
+const DefaultDeterministicSeed = "supalite"
+
+// Auth modes select what serves /auth/v1.
+const (
+	AuthModeGoTrue = "gotrue" // a GoTrue subprocess (default)
+	AuthModeNative = "native" // the core endpoints in process, see package native
+	AuthModeCustom = "custom" // Config.AuthProvider; reported, not selectable
+)
+
 func New(cfg Config) *Server {
 	return &Server{
 		config: cfg,
@@ -62,21 +237,65 @@ func New(cfg Config) *Server {
 	}
 }
 
-// quoteIdentifier quotes a SQL identifier for PostgreSQL.
-// Identifiers with spaces or special characters need to be double-quoted.
-// Double quotes within the identifier are escaped by doubling them.
-func quoteIdentifier(ident string) string {
-	// Escape existing double quotes by doubling them
-	escaped := strings.ReplaceAll(ident, "\"", "\"\"")
-	// Wrap in double quotes
-	return fmt.Sprintf("\"%s\"", escaped)
-}
-
 func (s *Server) Start(ctx context.Context) error {
-	log.Info("starting Supalite server...")
+	logger.Info("starting Supalite server...")
+	s.startedAt = time.Now()
+
+	switch s.config.AuthMode {
+	case "", AuthModeGoTrue, AuthModeNative:
+	default:
+		return fmt.Errorf("unknown auth mode %q (want %q or %q)", s.config.AuthMode, AuthModeGoTrue, AuthModeNative)
+	}
+
+	if err := s.initChaos(); err != nil {
+		return fmt.Errorf("invalid chaos faults: %w", err)
+	}
+	if err := s.initRules(); err != nil {
+		return fmt.Errorf("invalid route rules: %w", err)
+	}
+	// Certificates live in the configured data directory, even in
+	// ephemeral mode, so a self-signed one only needs to be trusted once
+	if err := s.initTLS(); err != nil {
+		return fmt.Errorf("invalid TLS settings: %w", err)
+	}
+
+	// Downloaded binaries are kept in the configured data directory even
+	// in ephemeral mode, so they aren't fetched again on every run
+	binDir := auth.BinDir(s.config.DataDir)
+
+	if s.config.Ephemeral {
+		dataDir, err := newEphemeralDataDir()
+		if err != nil {
+			return err
+		}
+		s.config.DataDir = dataDir
+		logger.Info("ephemeral mode: data will be deleted on exit", "data_dir", dataDir)
+		defer func() {
+			// Stopping is a no-op after a clean shutdown, but startup
+			// errors leave PostgreSQL running in the directory
+			if s.pgDatabase != nil {
+				s.pgDatabase.Stop()
+			}
+			if err := os.RemoveAll(dataDir); err != nil {
+				logger.Warn("failed to remove ephemeral data directory", "data_dir", dataDir, "error", err)
+			}
+		}()
+	}
 
-	// 1. Start embedded PostgreSQL
-	log.Info("starting embedded PostgreSQL...")
+	// 0.5. Set up trace export first, so startup queries are traced too
+	if s.config.Tracing != nil {
+		stop, err := tracing.Setup(ctx, *s.config.Tracing)
+		if err != nil {
+			return fmt.Errorf("failed to set up tracing: %w", err)
+		}
+		s.stopTracing = stop
+		logger.Info("exporting traces", "endpoint", s.config.Tracing.Endpoint)
+	}
+
+	// 1. Start embedded PostgreSQL, or connect to the external server
+	if s.config.DatabaseURL == "" {
+		logger.Info("starting embedded PostgreSQL...")
+	}
 
 	// Set default credentials if not provided
 	pgUsername := s.config.PGUsername
@@ -92,40 +311,130 @@ func (s *Server) Start(ctx context.Context) error {
 		pgDatabase = "postgres"
 	}
 
+	pgVersion := pg.DefaultVersion
+	if s.config.PGVersion != "" {
+		v, err := pg.ResolveVersion(s.config.PGVersion)
+		if err != nil {
+			return err
+		}
+		pgVersion = v
+	}
+
+	// Made absolute once, so the status reports where the socket really is
+	if s.config.PGSocketDir != "" && s.config.DatabaseURL == "" {
+		dir, err := filepath.Abs(s.config.PGSocketDir)
+		if err != nil {
+			return err
+		}
+		s.config.PGSocketDir = dir
+	}
+
 	pgCfg := pg.Config{
 		Port:        s.config.PGPort,
+		SocketDir:   s.config.PGSocketDir,
 		Username:    pgUsername,
 		Password:    pgPassword,
 		Database:    pgDatabase,
 		DataDir:     s.config.DataDir,
-		Version:     "16.9.0",
+		Version:     pgVersion,
 		RuntimePath: s.config.RuntimePath,
+		Offline:     s.config.Deterministic,
+		NoSync:      s.config.Ephemeral,
+		MinConns:    s.config.PGMinConns,
+		MaxConns:    s.config.PGMaxConns,
+		RolePools:   s.config.PGRolePools,
+		Locale:      s.config.PGLocale,
+		ICULocale:   s.config.PGICULocale,
+		Timezone:    s.config.PGTimezone,
+
+		Limits:        s.config.PGLimits,
+		SharedBuffers: s.config.PGSharedBuffers,
+	}
+	// The recorder's tracer is always installed, so REST requests can be
+	// explained even when recording is off
+	var queryTracer pgx.QueryTracer
+	if s.stopTracing != nil {
+		queryTracer = tracing.QueryTracer{}
+	}
+	pgCfg.Tracer = recorder.Tracer{Next: usage.Tracer{Next: queryTracer}}
+
+	s.componentStarting(ComponentPostgres)
+	if s.config.DatabaseURL != "" {
+		s.pgDatabase = pg.NewExternalDatabase(s.config.DatabaseURL, pgCfg)
+		if err := s.pgDatabase.Start(ctx); err != nil {
+			return s.componentFailed(ComponentPostgres, err, true)
+		}
+		logger.Info("connected to external PostgreSQL")
+		s.componentStarted(ComponentPostgres, map[string]string{"external": "true"})
+	} else {
+		// A cluster from another PostgreSQL major version can't be opened;
+		// say so, rather than leave PostgreSQL's own error to explain it
+		if s.config.DataDir != "" {
+			if err := schemacheck.CheckCluster(pg.ClusterPath(s.config.DataDir), pgCfg.Version); err != nil {
+				return s.componentFailed(ComponentPostgres, err, true)
+			}
+		}
+		s.pgDatabase = pg.NewEmbeddedDatabase(pgCfg)
+		if err := s.pgDatabase.Start(ctx); err != nil {
+			return s.componentFailed(ComponentPostgres, fmt.Errorf("failed to start PostgreSQL: %w", err), true)
+		}
+		metadata := map[string]string{"version": pgVersion}
+		if s.config.PGSocketDir != "" {
+			metadata["socket"] = pg.SocketPath(s.config.PGSocketDir, s.config.PGPort)
+			logger.Info("PostgreSQL started", "socket", metadata["socket"])
+		} else {
+			metadata["port"] = strconv.Itoa(int(s.config.PGPort))
+			logger.Info("PostgreSQL started", "port", s.config.PGPort)
+		}
+		s.componentStarted(ComponentPostgres, metadata)
 	}
-	s.pgDatabase = pg.NewEmbeddedDatabase(pgCfg)
 
-	if err := s.pgDatabase.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start PostgreSQL: %w", err)
+	// 2. Initialize database schema, after refusing one from a newer
+	// supalite and upgrading one from an older
+	if err := s.upgradeSchema(ctx); err != nil {
+		return err
 	}
-	log.Info("PostgreSQL started", "port", s.config.PGPort)
-
-	// 2. Initialize database schema
 	if err := s.initSchema(ctx); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	// 2.1. Create the configured extensions, such as pgvector
+	if len(s.config.Extensions) > 0 {
+		conn, err := s.pgDatabase.Connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+		}
+		err = pg.EnableExtensions(ctx, conn, s.config.Extensions)
+		conn.Close(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("extensions enabled", "extensions", s.config.Extensions)
+	}
+
 	// 2.5. Initialize key manager (anon/service_role keys)
-	log.Info("initializing key manager...")
+	logger.Info("initializing key manager...")
 
 	var keyManager *keys.Manager
 	var err error
 
-	if s.config.JWTSecret == "" {
+	deterministicSeed := s.config.DeterministicSeed
+	if s.config.Deterministic && deterministicSeed == "" {
+		logger.Warn("deterministic mode without a seed - using the well-known default seed, do not expose this instance")
+		deterministicSeed = DefaultDeterministicSeed
+	}
+
+	if s.config.Deterministic {
+		// Deterministic mode: keys derived from the seed, nothing persisted
+		logger.Info("using deterministic keys derived from seed")
+		keyManager, err = keys.NewDeterministicManager(deterministicSeed, s.config.ProjectRef, s.config.JWTSecret)
+	} else if s.config.JWTSecret == "" {
 		// ES256 mode (default): use empty string to trigger ES256 mode
-		log.Info("using ES256 mode with auto-generated keys")
+		logger.Info("using ES256 mode with auto-generated keys")
 		keyManager, err = keys.NewManager(s.config.DataDir, "")
 	} else {
 		// Legacy mode: user explicitly provided JWT_SECRET
-		log.Info("using legacy mode (JWT_SECRET)")
+		logger.Info("using legacy mode (JWT_SECRET)")
 		keyManager, err = keys.NewManager(s.config.DataDir, s.config.JWTSecret)
 	}
 
@@ -137,47 +446,70 @@ func (s *Server) Start(ctx context.Context) error {
 	// Set JWT secret for GoTrue (needs it regardless of mode)
 	jwtSecret := s.config.JWTSecret
 	if jwtSecret == "" {
-		jwtSecret = generateRandomSecret(32)
+		if s.config.Deterministic {
+			jwtSecret = keys.DeriveSecret(deterministicSeed, "gotrue")
+		} else {
+			jwtSecret = generateRandomSecret(32)
+		}
 	}
 
+	s.userJWTSecret = jwtSecret
+
 	// Generate separate JWT secret for dashboard authentication
 	dashboardSecret := generateRandomSecret(32)
 
-	if keyManager.IsLegacyMode() {
-		log.Info("keys initialized", "mode", "legacy (JWT_SECRET)")
+	if s.config.Deterministic {
+		logger.Info("keys initialized", "mode", "deterministic", "project_ref", keyManager.GetProjectRef())
+	} else if keyManager.IsLegacyMode() {
+		logger.Info("keys initialized", "mode", "legacy (JWT_SECRET)")
 	} else {
-		log.Info("keys initialized", "mode", "ES256")
+		logger.Info("keys initialized", "mode", "ES256")
 	}
 
 	// Display the keys
-	log.Info("==========================================")
-	log.Info("Project API Keys")
-	log.Info("==========================================")
-	log.Info("Project URL:", s.config.SiteURL)
-	log.Info("")
-	log.Info("anon key (public):")
-	log.Info("  " + s.keyManager.GetAnonKey())
-	log.Info("")
-	log.Warn("service_role key (secret - keep hidden!):")
-	log.Warn("  " + s.keyManager.GetServiceKey())
-	log.Info("")
-	log.Info("Use these keys in your Supabase client libraries:")
-	log.Info(fmt.Sprintf("  const supabase = createClient('%s',", s.config.SiteURL))
-	log.Info(fmt.Sprintf("    '%s',  // anon key", s.keyManager.GetAnonKey()))
-	log.Info(fmt.Sprintf("    '%s'  // service_role key (use with caution!)", s.keyManager.GetServiceKey()))
-	log.Info("  )")
-	log.Info("==========================================")
+	logger.Info("==========================================")
+	logger.Info("Project API Keys")
+	logger.Info("==========================================")
+	logger.Info("Project URL: " + s.config.SiteURL)
+	if s.config.PublicURL != "" && s.config.PublicURL != s.config.SiteURL {
+		logger.Info("Public URL: " + s.config.PublicURL)
+	}
+	logger.Info("")
+	logger.Info("anon key (public):")
+	logger.Info("  " + s.keyManager.GetAnonKey())
+	logger.Info("")
+	logger.Warn("service_role key (secret - keep hidden!):")
+	logger.Warn("  " + s.keyManager.GetServiceKey())
+	logger.Info("")
+	logger.Info("publishable key (replaces the anon key):")
+	logger.Info("  " + s.keyManager.GetPublishableKey())
+	logger.Info("")
+	logger.Warn("secret key (replaces the service_role key - keep hidden!):")
+	logger.Warn("  " + s.keyManager.GetSecretKey())
+	logger.Info("")
+	logger.Info("Use these keys in your Supabase client libraries:")
+	logger.Info(fmt.Sprintf("  const supabase = createClient('%s',", s.config.SiteURL))
+	logger.Info(fmt.Sprintf("    '%s',  // anon key", s.keyManager.GetAnonKey()))
+	logger.Info(fmt.Sprintf("    '%s'  // service_role key (use with caution!)", s.keyManager.GetServiceKey()))
+	logger.Info("  )")
+	logger.Info("==========================================")
 
 	connString := s.pgDatabase.ConnectionString()
 
-	// 3. Start pREST server
-	log.Info("starting pREST server...")
-	prestCfg := prest.DefaultConfig(connString)
-	s.prestServer = prest.NewServer(prestCfg)
-	if err := s.prestServer.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start pREST: %w", err)
+	// 3. Start pREST, if enabled. It is served at /prest rather than on a
+	// port of its own, and opens no database connections otherwise.
+	if s.config.PREST {
+		logger.Info("starting pREST...")
+		s.componentStarting(ComponentPREST)
+		prestCfg := prest.DefaultConfig(connString)
+		prestCfg.Port = 0
+		s.prestServer = prest.NewServer(prestCfg)
+		if err := s.prestServer.Start(ctx); err != nil {
+			return s.componentFailed(ComponentPREST, fmt.Errorf("failed to start pREST: %w", err), true)
+		}
+		logger.Info("pREST started", "path", "/prest")
+		s.componentStarted(ComponentPREST, map[string]string{"path": "/prest"})
 	}
-	log.Info("pREST started", "port", prestCfg.Port)
 
 	// 3.5. Start mail capture server if configured
 	if s.config.Email != nil && s.config.Email.CaptureMode {
@@ -186,45 +518,82 @@ func (s *Server) Start(ctx context.Context) error {
 			capturePort = 1025
 		}
 
-		log.Info("starting mail capture server...")
-		captureServer, err := mailcapture.NewServer(mailcapture.Config{
-			Port:     capturePort,
-			Host:     "localhost",
-			Database: s.pgDatabase,
-		})
+		logger.Info("starting mail capture server...")
+		s.componentStarting(ComponentMailCapture)
+		store, err := s.captureStore()
+		var captureServer *mailcapture.Server
+		if err == nil {
+			captureServer, err = mailcapture.NewServer(mailcapture.Config{
+				Port:  capturePort,
+				Host:  "localhost",
+				Store: store,
+
+				PublicURL:    s.config.PublicURL,
+				LocalOrigins: localOrigins(s.config.Port),
+			})
+		}
 		if err != nil {
-			log.Warn("failed to create mail capture server", "error", err)
-			log.Warn("mail capture mode requested but unavailable - emails will be sent to external SMTP server instead")
+			logger.Warn("failed to create mail capture server", "error", err)
+			logger.Warn("mail capture mode requested but unavailable - emails will be sent to external SMTP server instead")
+			s.componentFailed(ComponentMailCapture, err, false)
 		} else {
 			s.captureServer = captureServer
 			if err := s.captureServer.Start(ctx); err != nil {
-				log.Warn("failed to start mail capture server", "error", err)
-				log.Warn("mail capture mode requested but unavailable - emails will be sent to external SMTP server instead")
+				logger.Warn("failed to start mail capture server", "error", err)
+				logger.Warn("mail capture mode requested but unavailable - emails will be sent to external SMTP server instead")
+				s.componentFailed(ComponentMailCapture, err, false)
 			} else {
-				log.Info("mail capture server started", "port", capturePort)
+				logger.Info("mail capture server started", "port", capturePort)
+				s.componentStarted(ComponentMailCapture, map[string]string{"port": strconv.Itoa(capturePort)})
 			}
 		}
 	}
 
-	// 4. Start GoTrue auth server
-	log.Info("starting GoTrue auth server...")
+	// 4. Start the auth server
 	authCfg := auth.DefaultConfig()
 	// Add search_path for GoTrue to find its tables in the auth schema
-	authCfg.ConnString = connString + "?search_path=auth"
+	authConnString, err := withSearchPath(connString, "auth")
+	if err != nil {
+		return err
+	}
+	authCfg.ConnString = authConnString
 	authCfg.JWTSecret = jwtSecret // Use the JWT secret we set up for the key manager
 	authCfg.SiteURL = s.config.SiteURL
+	authCfg.PublicURL = s.config.PublicURL
+	authCfg.BinaryPath = s.config.GoTrueBinary
+	authCfg.BinDir = binDir
+	authCfg.LogLevel = log.ComponentLevel("gotrue").String() // Relayed lines are filtered at this level anyway
+	authCfg.External = s.config.External
+	authCfg.SMS = s.config.SMS
+	authCfg.MFA = s.config.MFA
+	authCfg.Captcha = s.config.Captcha
+	authCfg.Password = s.config.Password
+	authCfg.RateLimits = s.config.RateLimits
+	authCfg.DisableDownload = s.config.Deterministic
+	authCfg.Limits = s.config.AuthLimits
+	if s.config.Tracing != nil {
+		authCfg.TracingEndpoint = s.config.Tracing.Endpoint
+	}
+
+	// Deterministic mode never waits on confirmation emails
+	if s.config.Deterministic {
+		if s.config.Email == nil {
+			s.config.Email = &auth.EmailConfig{}
+		}
+		s.config.Email.Autoconfirm = true
+	}
 
 	// Handle email configuration
 	if s.config.Email != nil {
 		if s.config.Email.CaptureMode && s.captureServer != nil && s.captureServer.IsRunning() {
 			// Override SMTP settings to point to local capture server
-			log.Info("configuring GoTrue to use mail capture server")
+			logger.Info("configuring GoTrue to use mail capture server")
 			authCfg.Email = &auth.EmailConfig{
-				SMTPHost:   "localhost",
-				SMTPPort:   s.captureServer.Port(),
-				SMTPUser:   "capture",
-				SMTPPass:   "capture",
-				AdminEmail: s.config.Email.AdminEmail,
+				SMTPHost:             "localhost",
+				SMTPPort:             s.captureServer.Port(),
+				SMTPUser:             "capture",
+				SMTPPass:             "capture",
+				AdminEmail:           s.config.Email.AdminEmail,
 				URLPathsInvite:       s.config.Email.URLPathsInvite,
 				URLPathsConfirmation: s.config.Email.URLPathsConfirmation,
 				URLPathsRecovery:     s.config.Email.URLPathsRecovery,
@@ -236,21 +605,233 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}
 
-	s.authServer = auth.NewServer(authCfg)
-	if err := s.authServer.Start(ctx); err != nil {
-		log.Warn("failed to start GoTrue", "error", err)
-		log.Warn("auth API will not be available")
+	s.componentStarting(ComponentAuth)
+	authMetadata := map[string]string{"mode": AuthModeGoTrue}
+	switch {
+	case s.config.AuthProvider != nil:
+		logger.Info("starting custom auth provider...")
+		authMetadata["mode"] = AuthModeCustom
+		s.authProvider = s.config.AuthProvider
+		if err := s.authProvider.Start(ctx); err != nil {
+			return s.componentFailed(ComponentAuth, fmt.Errorf("failed to start auth provider: %w", err), true)
+		}
+		s.authStarted = true
+	case s.config.AuthMode == AuthModeNative:
+		logger.Info("starting native auth server...")
+		authMetadata["mode"] = AuthModeNative
+		nativeCfg := native.Config{
+			Database:  s.pgDatabase,
+			JWTSecret: jwtSecret,
+			SiteURL:   s.config.SiteURL,
+			PublicURL: s.config.PublicURL,
+			Email:     authCfg.Email,
+		}
+		if s.config.Password != nil {
+			nativeCfg.PasswordMinLength = s.config.Password.MinLength
+		}
+		s.authProvider = native.NewServer(nativeCfg)
+		if err := s.authProvider.Start(ctx); err != nil {
+			return s.componentFailed(ComponentAuth, fmt.Errorf("failed to start native auth: %w", err), true)
+		}
+		logger.Info("native auth started")
+		s.authStarted = true
+		if len(s.config.External) > 0 {
+			logger.Warn("external OAuth providers are configured but need GoTrue; native auth ignores them")
+		}
+		if s.config.SMS != nil {
+			logger.Warn("phone auth is configured but needs GoTrue; native auth ignores it")
+		}
+		if s.config.MFA != nil || s.config.Captcha != nil {
+			logger.Warn("MFA and captcha settings need GoTrue; native auth ignores them")
+		}
+	default:
+		logger.Info("starting GoTrue auth server...")
+		authCfg.OnExit = s.goTrueExited
+		s.authServer = auth.NewServer(authCfg)
+		s.authProvider = s.authServer
+
+		// Migrate the auth schema before GoTrue starts, so its own startup
+		// migration doesn't leave /auth/v1 returning 502s on a fresh database
+		if err := s.authServer.Migrate(ctx); err != nil {
+			logger.Warn("failed to pre-migrate auth schema, GoTrue will migrate on startup", "error", err)
+		}
+
+		if err := s.authServer.Start(ctx); err != nil {
+			logger.Warn("failed to start GoTrue", "error", err)
+			logger.Warn("auth API will not be available")
+			s.componentFailed(ComponentAuth, err, false)
+		} else {
+			logger.Info("GoTrue started", "port", authCfg.Port)
+			authMetadata["port"] = strconv.Itoa(authCfg.Port)
+			s.authStarted = true
+		}
+	}
+
+	// Wait for the auth provider before accepting traffic, so /auth/v1
+	// works as soon as /health does and seeded users can log in immediately
+	if s.authStarted {
+		if err := s.authProvider.WaitUntilReady(ctx, 30*time.Second); err != nil {
+			logger.Warn("auth is not ready yet, /health will report unavailable until it is", "error", err)
+			s.componentFailed(ComponentAuth, err, false)
+		} else {
+			s.componentStarted(ComponentAuth, authMetadata)
+			if len(s.config.SeedUsers) > 0 {
+				if err := s.authProvider.SeedUsers(ctx, s.config.SeedUsers); err != nil {
+					logger.Warn("failed to seed auth users", "error", err)
+				}
+			}
+		}
+	}
+
+	// 4.25. Start Realtime (broadcast, presence, postgres_changes)
+	logger.Info("starting realtime server...")
+	s.componentStarting(ComponentRealtime)
+	s.realtimeServer = realtime.NewServer(realtime.Config{
+		Database:      s.pgDatabase,
+		Verifier:      s.keyManager,
+		UserJWTSecret: jwtSecret,
+	})
+	if err := s.realtimeServer.Start(ctx); err != nil {
+		logger.Warn("failed to start realtime change capture", "error", err)
+		logger.Warn("realtime broadcast and presence are available, postgres_changes is not")
+		s.componentFailed(ComponentRealtime, err, false)
 	} else {
-		log.Info("GoTrue started", "port", authCfg.Port)
+		logger.Info("realtime started")
+		s.componentStarted(ComponentRealtime, nil)
+	}
+
+	// 4.3. Initialize Storage (buckets and objects under DataDir/storage)
+	s.componentStarting(ComponentStorage)
+	s.storageServer = storage.NewServer(storage.Config{
+		Database:      s.pgDatabase,
+		Verifier:      s.keyManager,
+		UserJWTSecret: jwtSecret,
+		SigningSecret: jwtSecret,
+		Root:          filepath.Join(s.config.DataDir, "storage"),
+	})
+	if err := s.storageServer.Init(ctx); err != nil {
+		return s.componentFailed(ComponentStorage, fmt.Errorf("failed to initialize storage: %w", err), true)
+	}
+	logger.Info("storage initialized")
+	s.componentStarted(ComponentStorage, map[string]string{"root": filepath.Join(s.config.DataDir, "storage")})
+
+	// 4.4. Start the pg_net worker (net.http_get, net.http_post, net.http_delete)
+	s.componentStarting(ComponentPgNet)
+	s.netWorker = pgnet.NewWorker(pgnet.Config{Database: s.pgDatabase})
+	if err := s.netWorker.Start(ctx); err != nil {
+		logger.Warn("failed to start pg_net worker", "error", err)
+		logger.Warn("net.http_* functions will not be available")
+		s.netWorker = nil
+		s.componentFailed(ComponentPgNet, err, false)
+	} else {
+		logger.Info("pg_net worker started")
+		s.componentStarted(ComponentPgNet, nil)
+	}
+
+	// 4.45. Create the audit schema for change history and prune old versions
+	historyCfg := s.config.History
+	historyCfg.Database = s.pgDatabase
+	s.componentStarting(ComponentHistory)
+	s.historyWorker = history.NewWorker(historyCfg)
+	if err := s.historyWorker.Start(ctx); err != nil {
+		logger.Warn("failed to start change history", "error", err)
+		logger.Warn("audit.enable_tracking will not be available")
+		s.historyWorker = nil
+		s.componentFailed(ComponentHistory, err, false)
+	} else {
+		logger.Info("change history ready", "tracked", len(historyCfg.Tables))
+		s.componentStarted(ComponentHistory, map[string]string{"tracked": strconv.Itoa(len(historyCfg.Tables))})
+	}
+
+	// 4.46. Create the audit log table, if the REST write log is on
+	if s.config.AuditLog != nil {
+		auditCfg := *s.config.AuditLog
+		auditCfg.Database = s.pgDatabase
+		s.componentStarting(ComponentAuditLog)
+		s.auditLog = auditlog.NewWriter(auditCfg)
+		if err := s.auditLog.Start(ctx); err != nil {
+			return s.componentFailed(ComponentAuditLog, fmt.Errorf("failed to start audit log: %w", err), true)
+		}
+		logger.Info("audit log ready", "table", "audit.api_log")
+		s.componentStarted(ComponentAuditLog, map[string]string{"table": "audit.api_log"})
+	}
+
+	// 4.47. Keep recent REST requests and their SQL, if recording is on
+	if s.config.RESTRecord > 0 {
+		s.restRecorder = recorder.New(s.config.RESTRecord)
+		logger.Info("recording REST requests", "kept", s.config.RESTRecord)
+	}
+
+	// 4.48. Count usage per API key and enforce quotas, if configured
+	if s.config.Usage != nil {
+		usageCfg := *s.config.Usage
+		usageCfg.Database = s.pgDatabase
+		usageCfg.OnLimit = s.notifyUsageLimit
+		s.componentStarting(ComponentUsage)
+		s.usageMeter = usage.NewMeter(usageCfg)
+		if err := s.usageMeter.Start(ctx); err != nil {
+			return s.componentFailed(ComponentUsage, fmt.Errorf("failed to start usage accounting: %w", err), true)
+		}
+		logger.Info("usage accounting ready", "period", usageCfg.Period, "table", "admin.api_usage")
+		s.componentStarted(ComponentUsage, map[string]string{"table": "admin.api_usage"})
+	}
+
+	// 4.49. Apply pending migrations, once the auth, storage, and audit
+	// schemas they may refer to exist, and before any request is served
+	if s.config.MigrationsDir != "" {
+		if err := s.applyMigrations(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 4.495. Load the tables and relationships embedding and /docs look
+	// up, after migrations have changed them, and reload them on DDL
+	s.componentStarting(ComponentSchemaCache)
+	s.schemaCache = schemacache.New(s.pgDatabase)
+	if err := s.schemaCache.Start(ctx); err != nil {
+		logger.Warn("failed to load the schema cache; it will load on first use", "error", err)
+		s.componentFailed(ComponentSchemaCache, err, false)
+	} else {
+		s.componentStarted(ComponentSchemaCache, nil)
 	}
 
 	// 4.5. Initialize dashboard server
-	log.Info("initializing dashboard server...")
+	logger.Info("initializing dashboard server...")
+	var webhookSecret string
+	if s.config.Email != nil {
+		webhookSecret = s.config.Email.CaptureWebhookSecret
+	}
+	// Auth users are managed through GoTrue's admin API, which native
+	// and custom auth don't have
+	var authAdmin http.Handler
+	if s.authServer != nil {
+		authAdmin = s.authServer.AdminHandler()
+	}
 	s.dashboardServer = dashboard.NewServer(dashboard.Config{
-		JWTSecret:  dashboardSecret,
-		PGDatabase: s.pgDatabase,
+		JWTSecret:      dashboardSecret,
+		PGDatabase:     s.pgDatabase,
+		TokenSigner:    s.keyManager,
+		TokenInspector: s.keyManager,
+		WebhookSecret:  webhookSecret,
+		RESTRequests:   s.restRecorder,
+		Schema:         s.schemaCache,
+		AuthAdmin:      authAdmin,
 	})
-	log.Info("dashboard initialized")
+	logger.Info("dashboard initialized")
+
+	// 4.6. Start the disk and memory watchdog
+	s.startWatchdog()
+
+	// 4.7. Start probing the APIs for the status page
+	if s.config.Status != nil {
+		s.startStatus(ctx)
+	}
+
+	// 4.8. Check that the tables the components need exist, and record
+	// the schema version
+	if err := s.checkSchema(ctx); err != nil {
+		return err
+	}
 
 	// 5. Setup orchestration routes
 	s.setupRoutes()
@@ -260,40 +841,193 @@ func (s *Server) Start(ctx context.Context) error {
 	s.httpServer = &http.Server{
 		Addr:         addr,
 		Handler:      s.corsHandler(),
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultWriteTimeout,
+		IdleTimeout:  defaultIdleTimeout,
+		TLSConfig:    s.tlsConfig,
+		Protocols:    serverProtocols(),
+	}
+	if s.tlsConfig != nil && s.config.TLS.HTTPPort != 0 {
+		s.startHTTPListener()
 	}
 
-	errCh := make(chan error, 1)
+	// Listening here, rather than in ListenAndServe, reports a port in use
+	// as Start's error, and means the server accepts connections once
+	// ComponentHTTP is reported started
+	s.componentStarting(ComponentHTTP)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return s.componentFailed(ComponentHTTP, fmt.Errorf("failed to listen on %s: %w", addr, err), true)
+	}
 	go func() {
-		log.Info("Supalite listening", "addr", addr)
-		log.Info("APIs available:")
-		log.Info("  Auth:    http://localhost:8080/auth/v1/*")
-		log.Info("  REST:    http://localhost:8080/rest/v1/*")
-		log.Info("  Health:  http://localhost:8080/health")
-		log.Info("  Dashboard: http://localhost:8080/_/")
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
+		logger.Info("Supalite listening", "addr", addr)
+		logger.Info("APIs available:")
+		scheme, wsScheme := "http", "ws"
+		if s.tlsConfig != nil {
+			scheme, wsScheme = "https", "wss"
+		}
+		base := fmt.Sprintf("%s://localhost:%d", scheme, s.config.Port)
+		logger.Info("  Auth:    " + base + "/auth/v1/*")
+		logger.Info("  REST:    " + base + "/rest/v1/*, " + base + "/rest/v2/*")
+		logger.Info(fmt.Sprintf("  Realtime: %s://localhost:%d/realtime/v1/websocket", wsScheme, s.config.Port))
+		logger.Info("  Storage: " + base + "/storage/v1/*")
+		logger.Info("  Health:  " + base + "/health")
+		if s.statusMonitor != nil {
+			logger.Info("  Status:  " + base + "/status")
+		}
+		if s.config.Docs {
+			logger.Info("  Docs:    " + base + "/docs")
+		}
+		logger.Info("  Dashboard: " + base + "/_/")
+		var err error
+		if s.tlsConfig != nil {
+			err = s.httpServer.ServeTLS(ln, "", "")
+		} else {
+			err = s.httpServer.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP server failed", "error", err)
+			s.componentFailed(ComponentHTTP, err, false)
 		}
 	}()
+	s.componentStarted(ComponentHTTP, map[string]string{"addr": ln.Addr().String()})
 
 	// 7. Wait for shutdown signal (use background context to avoid timeout)
 	return s.waitForShutdown(context.Background())
 }
 
 func (s *Server) setupRoutes() {
+	// A span for each request, around everything else, if tracing is on
+	if s.stopTracing != nil {
+		s.router.Use(traceRequests)
+	}
+
+	// A log line for each request, including those refused below
+	if s.config.AccessLog {
+		s.router.Use(s.logRequests)
+	}
+
+	// Alert on repeated failed authentication, if notifications are on
+	if s.config.Notifier != nil {
+		s.authFailures = newAuthFailureCounter(s.config.AuthFailureThreshold, s.config.AuthFailureWindow)
+		s.router.Use(s.trackAuthFailures)
+	}
+
+	// Refuse writes with 507 while the disk is nearly full, if configured
+	if s.watchdog != nil {
+		s.router.Use(s.watchdog.RefuseWrites)
+	}
+
+	// Swap publishable and secret keys for the JWTs they stand for
+	s.router.Use(s.resolveAPIKeys)
+
+	// Operator-defined route rules, which may match on the caller's role
+	if s.rules != nil {
+		s.router.Use(s.rules.Middleware)
+	}
+
+	// Usage per API key, and quotas on it, if configured
+	if s.usageMeter != nil {
+		s.router.Use(s.meterUsage)
+	}
+
 	s.router.Get("/health", s.handleHealth)
 
+	// Public status page: overall health and per-API availability
+	if s.statusMonitor != nil {
+		s.router.Method(http.MethodGet, "/status", s.statusMonitor.Handler())
+	}
+
+	// API docs with example requests for each table, generated per request
+	if s.config.Docs {
+		s.router.Get("/docs", s.handleDocs)
+	}
+
+	// Prometheus metrics from the watchdog, for the service_role key
+	if s.watchdog != nil {
+		s.router.Get("/metrics", s.requireServiceRole(s.watchdog.Handler().ServeHTTP))
+	}
+
 	// JWKS endpoint for public key discovery (ES256 mode)
 	s.router.HandleFunc("/.well-known/jwks.json", s.handleJWKS)
 
 	// Create Supabase-compatible REST API handler
 	// Translates /rest/v1/{table} to /{database}/{schema}/{table} for pREST
-	s.router.HandleFunc("/rest/v1", s.handleSupabaseREST)
-	s.router.HandleFunc("/rest/v1/*", s.handleSupabaseREST)
+	// Requests must carry a valid apikey or Authorization bearer token
+	// v1 and v2 are served side by side (see apiversion.go), and /rest
+	// serves the configured default version
+	for _, mount := range []struct {
+		prefix  string
+		version int
+	}{
+		{"/rest/v1", RESTv1},
+		{"/rest/v2", RESTv2},
+		{"/rest", s.defaultRESTVersion()},
+	} {
+		middlewares := chi.Middlewares{s.withRESTVersion(mount.version, mount.prefix), s.requireAPIKey}
+		if s.restRecorder != nil {
+			middlewares = append(middlewares, s.recordREST)
+		}
+		if s.auditLog != nil {
+			middlewares = append(middlewares, s.auditWrites(mount.prefix))
+		}
+		rest := s.router.With(middlewares...)
+		rest.HandleFunc(mount.prefix, s.handleSupabaseREST)
+		rest.HandleFunc(mount.prefix+"/*", s.handleSupabaseREST)
+	}
+
+	// WebSocket upgrades and event streams outlive the server-wide
+	// timeouts, so they get no read or write deadline
+	streaming := s.router.With(withDeadlines(0, 0, isStreamingRequest))
 
-	// Proxy requests to GoTrue auth server
-	s.router.HandleFunc("/auth/v1/*", s.handleAuthRequest)
+	// Proxy requests to GoTrue auth server. The admin API needs the
+	// service_role key.
+	streaming.With(s.requireAuthAdmin).HandleFunc("/auth/v1/*", s.handleAuthRequest)
+
+	// Realtime WebSocket and broadcast API
+	streaming.HandleFunc("/realtime/v1/*", func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, "/realtime/v1/")
+		s.realtimeServer.Handler().ServeHTTP(w, r)
+	})
+
+	// Storage API (buckets and objects)
+	s.router.Handle("/storage/v1/*", http.StripPrefix("/storage/v1", s.storageServer.Handler()))
+
+	// pREST's own API, which bypasses RLS, so it needs the service_role key
+	if s.prestServer != nil {
+		s.router.HandleFunc("/prest/*", s.requireServiceRole(http.StripPrefix("/prest", s.prestServer.Handler()).ServeHTTP))
+	}
+
+	// Service-role-only helpers for testing RLS as a specific user
+	s.router.Post("/admin/v1/impersonate", s.requireServiceRole(s.handleImpersonate))
+
+	// The SQL a REST request runs, without keeping its writes, and the
+	// recorded requests when recording is on
+	s.router.Post("/admin/v1/rest/explain", s.requireServiceRole(s.handleRESTExplain))
+	if s.restRecorder != nil {
+		s.router.Get("/admin/v1/rest/requests", s.requireServiceRole(s.handleRESTRequests))
+		s.router.Delete("/admin/v1/rest/requests", s.requireServiceRole(s.handleClearRESTRequests))
+	}
+
+	// Usage of the current period, per API key
+	if s.usageMeter != nil {
+		s.router.Get("/admin/v1/usage", s.requireServiceRole(s.handleUsage))
+	}
+
+	// Change history of tracked tables
+	if s.historyWorker != nil {
+		s.router.Get("/admin/v1/history", s.requireServiceRole(s.handleHistoryTables))
+		s.router.Get("/admin/v1/history/{table}", s.requireServiceRole(s.handleHistory))
+	}
+
+	// Management API: status, backups, migrations, keys, and admins
+	s.mountManagementAPI(s.router)
+
+	// Fault injection controls, only when chaos is enabled
+	if s.chaos != nil {
+		s.router.HandleFunc("/admin/v1/chaos", s.requireServiceRole(s.handleChaos))
+		s.router.Post("/admin/v1/chaos/kill-gotrue", s.requireServiceRole(s.handleChaosKillGoTrue))
+	}
 
 	// Redirect /_ to /_/ (trailing slash)
 	s.router.Get("/_", func(w http.ResponseWriter, r *http.Request) {
@@ -302,10 +1036,10 @@ func (s *Server) setupRoutes() {
 
 	// Dashboard routes - handle all /_/ paths by stripping the prefix
 	s.router.HandleFunc("/_/*", func(w http.ResponseWriter, r *http.Request) {
-		log.Info("dashboard request", "path", r.URL.Path)
+		logger.Info("dashboard request", "path", r.URL.Path)
 		// Strip the /_/ prefix
 		r.URL.Path = "/" + strings.TrimPrefix(r.URL.Path, "/_/")
-		log.Info("dashboard request", "stripped_path", r.URL.Path)
+		logger.Info("dashboard request", "stripped_path", r.URL.Path)
 		s.dashboardServer.Handler().ServeHTTP(w, r)
 	})
 }
@@ -321,6 +1055,10 @@ func (s *Server) corsHandler() http.Handler {
 		AllowCredentials: false,         // Must be false when AllowedOrigins is "*"
 		MaxAge:           86400,         // Cache preflight response for 24 hours
 	})
+	// Route rules can replace the policy for their paths
+	if s.rules != nil {
+		return s.rules.CORS(s.router, c)
+	}
 	return c.Handler(s.router)
 }
 
@@ -342,7 +1080,6 @@ func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(jwks)
 }
 
-
 // handleAuthRequest proxies requests to the GoTrue auth server
 func (s *Server) handleAuthRequest(w http.ResponseWriter, r *http.Request) {
 	// Strip /auth/v1 prefix from the path
@@ -363,14 +1100,23 @@ func (s *Server) handleAuthRequest(w http.ResponseWriter, r *http.Request) {
 		r.URL.RawPath = requestPath
 	}
 
-	s.authServer.Handler().ServeHTTP(w, r)
+	s.chaos.DelayAuth(r.Context())
+
+	backend := s.authProvider.Handler()
+
+	// supabase-js fetches the settings on every client init
+	if r.Method == http.MethodGet && r.URL.Path == "/settings" {
+		s.handleAuthSettings(w, r, backend)
+		return
+	}
+	backend.ServeHTTP(w, r)
 }
 
 // handleSupabaseREST implements Supabase/PostgREST-compatible REST API
 // URL format: /rest/v1/{table}?select=*&order=name&limit=10
 func (s *Server) handleSupabaseREST(w http.ResponseWriter, r *http.Request) {
-	// Remove /rest/v1 prefix
-	remainingPath := r.URL.Path[len("/rest/v1"):]
+	// Remove the /rest/v1 or /rest/v2 prefix
+	remainingPath := requestRESTPath(r)
 	if remainingPath == "" || remainingPath == "/" {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
@@ -388,43 +1134,106 @@ func (s *Server) handleSupabaseREST(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tableName := parts[0]
-
-	// Get the HTTP method
-	method := r.Method
+	tableName := s.config.REST.IdentifierCase.name(parts[0])
+	if err := validateIdentifier(tableName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Build and execute query based on method
 	ctx := r.Context()
-	conn, err := s.pgDatabase.Connect(ctx)
+
+	// Function calls: /rest/v1/rpc/{function}
+	if tableName == "rpc" {
+		pooled, err := s.acquireRequestConn(ctx, r)
+		if err != nil {
+			writeDBError(w, "database connection error", err, http.StatusInternalServerError)
+			return
+		}
+		defer pooled.Release()
+
+		fnName := ""
+		if len(parts) > 1 {
+			fnName = parts[1]
+		}
+		s.handleRPC(ctx, pooled.Conn(), w, r, fnName)
+		return
+	}
+
+	// Accept-Profile / Content-Profile pick the table's schema
+	schema, profileErr := requestSchema(r)
+	if profileErr != nil {
+		writeProfileError(w, profileErr)
+		return
+	}
+	if schema != defaultSchema {
+		w.Header().Set("Content-Profile", schema)
+	}
+
+	// Reads change nothing, so one that fails transiently (a serialization
+	// failure, a deadlock, a dropped connection) runs again on a fresh
+	// connection before the error reaches the client. Writes report the
+	// failure with a Retry-After hint and leave retrying to the client.
+	attempts := 1
+	if r.Method == "GET" || r.Method == "HEAD" {
+		attempts = maxReadAttempts
+	}
+	txw := newTxResponseWriter(w)
+	for attempt := 1; ; attempt++ {
+		s.serveTable(ctx, txw, r, schema, tableName)
+		if !txw.transient || attempt == attempts || !sleepContext(ctx, readRetryDelay*time.Duration(attempt)) {
+			break
+		}
+		logger.Debug("retrying rest read after a transient failure", "table", tableName, "attempt", attempt+1)
+		txw.discard()
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		s.setCaching(txw, r, tableName)
+	}
+	txw.send()
+}
+
+// serveTable runs one attempt of a table request in its own connection and
+// transaction. The request runs as the caller's role so RLS policies
+// apply, and its response is held in txw until the transaction commits.
+func (s *Server) serveTable(ctx context.Context, txw *txResponseWriter, r *http.Request, schema, tableName string) {
+	pooled, err := s.acquireRequestConn(ctx, r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("database connection error: %v", err), http.StatusInternalServerError)
+		writeDBError(txw, "database connection error", err, http.StatusInternalServerError)
 		return
 	}
-	defer conn.Close(ctx)
+	defer pooled.Release()
+
+	tx, err := s.beginRequest(ctx, pooled.Conn(), r, pgx.TxOptions{})
+	if err != nil {
+		writeBeginError(txw, err)
+		return
+	}
+	defer tx.Rollback(ctx)
 
-	switch method {
+	switch r.Method {
 	case "GET":
-		s.handleGET(ctx, conn, w, r, tableName)
+		s.handleGET(ctx, tx, txw, r, schema, tableName)
 	case "HEAD":
-		s.handleHEAD(ctx, conn, w, r, tableName)
+		s.handleHEAD(ctx, tx, txw, r, schema, tableName)
 	case "POST":
-		s.handlePOST(ctx, conn, w, r, tableName)
+		s.handlePOST(ctx, tx, txw, r, tableName)
 	case "PATCH", "PUT":
-		s.handlePATCH(ctx, conn, w, r, tableName)
+		s.handlePATCH(ctx, tx, txw, r, tableName)
 	case "DELETE":
-		s.handleDELETE(ctx, conn, w, r, tableName)
+		s.handleDELETE(ctx, tx, txw, r, tableName)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		http.Error(txw, "method not allowed", http.StatusMethodNotAllowed)
 	}
+	txw.commit(ctx, tx)
 }
 
 // embeddedResource represents a foreign key relationship to fetch
 type embeddedResource struct {
-	alias       string // e.g., "sender" in sender:users!sender_id(id,name)
-	table       string // e.g., "users"
-	fkColumn    string // e.g., "sender_id" (if specified with !)
-	columns     string // e.g., "id,name"
-	isInner     bool   // true for !inner modifier
+	alias    string // e.g., "sender" in sender:users!sender_id(id,name)
+	table    string // e.g., "users"
+	fkColumn string // e.g., "sender_id" (if specified with !)
+	columns  string // e.g., "id,name"
+	isInner  bool   // true for !inner modifier
 }
 
 // parseSelectClause parses the PostgREST-style select string and returns:
@@ -526,31 +1335,90 @@ func containsColumn(columns []string, col string) bool {
 }
 
 // buildSelectColumn builds a SQL column expression from a PostgREST column spec
-func buildSelectColumn(col string) string {
+func buildSelectColumn(col string) (string, error) {
 	col = strings.TrimSpace(col)
 	if col == "*" {
-		return "*"
+		return "*", nil
+	}
+
+	// Handle JSON arrow notation: address->city or address->>city, aliased
+	// to the key
+	for _, arrow := range []string{"->>", "->"} {
+		if strings.Contains(col, arrow) {
+			parts := strings.SplitN(col, arrow, 2)
+			if err := validateIdentifier(parts[0]); err != nil {
+				return "", err
+			}
+			if err := validateIdentifier(parts[1]); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s AS %s", columnRef(col), quoteIdentifier(parts[1])), nil
+		}
 	}
 
-	// Handle JSON arrow notation: address->city or address->>city
-	if strings.Contains(col, "->>") {
-		parts := strings.SplitN(col, "->>", 2)
-		return fmt.Sprintf("%s->>'%s' AS %s", quoteIdentifier(parts[0]), parts[1], quoteIdentifier(parts[1]))
+	if err := validateIdentifier(col); err != nil {
+		return "", err
+	}
+	return quoteIdentifier(col), nil
+}
+
+// embeddedColumns builds the select list of an embedded resource's query,
+// whose table is aliased t, validating and quoting each column like the
+// main table's.
+func embeddedColumns(columns string) (string, error) {
+	if columns == "" || columns == "*" {
+		return "t.*", nil
+	}
+	cols := strings.Split(columns, ",")
+	exprs := make([]string, len(cols))
+	for i, col := range cols {
+		expr, err := buildSelectColumn(col)
+		if err != nil {
+			return "", err
+		}
+		exprs[i] = "t." + expr
 	}
-	if strings.Contains(col, "->") {
-		parts := strings.SplitN(col, "->", 2)
-		return fmt.Sprintf("%s->'%s' AS %s", quoteIdentifier(parts[0]), parts[1], quoteIdentifier(parts[1]))
+	return strings.Join(exprs, ", "), nil
+}
+
+// buildOrderClause converts a PostgREST order value (e.g. "name.desc" or
+// "name DESC") into a quoted ORDER BY expression, mapping the column name
+// by names
+func buildOrderClause(orderClause string, names IdentifierCase) (string, error) {
+	column, direction := orderClause, ""
+
+	// Handle order with direction (e.g., "name.desc" or "name ASC"). An
+	// unknown direction is treated as part of the column name.
+	if strings.Contains(orderClause, ".") {
+		parts := strings.SplitN(orderClause, ".", 2)
+		if dir := strings.ToUpper(parts[1]); dir == "ASC" || dir == "DESC" {
+			column, direction = parts[0], dir
+		}
+	} else if lastSpace := strings.LastIndex(orderClause, " "); lastSpace > 0 {
+		// Split by the last space to separate column from direction
+		if dir := strings.ToUpper(orderClause[lastSpace+1:]); dir == "ASC" || dir == "DESC" {
+			column, direction = orderClause[:lastSpace], dir
+		}
 	}
 
-	return quoteIdentifier(col)
+	column = names.name(column)
+	if err := validateIdentifier(column); err != nil {
+		return "", err
+	}
+	// Use space before direction to avoid ambiguity with quoted identifiers
+	// e.g., "another column".desc becomes "another column" DESC
+	if direction == "" {
+		return quoteIdentifier(column), nil
+	}
+	return quoteIdentifier(column) + " " + direction, nil
 }
 
 // handleGET processes SELECT requests
-func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, table string) {
+func (s *Server) handleGET(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, schema, table string) {
 	query := r.URL.Query()
 
 	// Quote table name for SQL
-	quotedTable := quoteIdentifier(table)
+	quotedTable := qualifiedTable(schema, table)
 
 	// Parse select clause
 	var selectStr string
@@ -561,29 +1429,84 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 	}
 
 	mainColumns, embedded := parseSelectClause(selectStr)
+	if len(embedded) > 0 && schema != defaultSchema {
+		http.Error(w, "embedded resources are only supported in the public schema", http.StatusBadRequest)
+		return
+	}
+
+	// Past rows stand in for the table
+	if query.Has(asOfParam) {
+		if len(embedded) > 0 {
+			http.Error(w, "as_of doesn't support embedded resources", http.StatusBadRequest)
+			return
+		}
+		source, status, err := s.asOfSource(r, schema, table, query.Get(asOfParam))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		quotedTable = source
+		query.Del(asOfParam)
+	}
 
-	// Pre-analyze embedded resources to find required join columns
+	mainColumns, excluded := splitExcludedColumns(mainColumns)
+	names := s.config.REST.IdentifierCase
+	for i, col := range mainColumns {
+		mainColumns[i] = names.column(col)
+	}
+	for i, col := range excluded {
+		excluded[i] = names.name(col)
+	}
+	for i := range embedded {
+		embedded[i].table = names.name(embedded[i].table)
+		embedded[i].fkColumn = names.name(embedded[i].fkColumn)
+		embedded[i].columns = names.list(embedded[i].columns)
+	}
+
+	// Excluding columns and cutting values short both need the table's
+	// columns. CSV responses are streamed, so their values aren't cut.
+	var tableColumns []tableColumn
+	limited := s.config.REST.hasFieldSizeLimits() && !wantsCSV(r)
+	if len(excluded) > 0 || limited {
+		var err error
+		tableColumns, err = loadTableColumns(ctx, tx, schema, table)
+		if err != nil {
+			writeDBError(w, "query error", err, http.StatusInternalServerError)
+			return
+		}
+		if len(excluded) > 0 {
+			if mainColumns, err = expandColumns(mainColumns, excluded, tableColumns); err != nil {
+				http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if !limited {
+			tableColumns = nil
+		}
+	}
+
+	// Resolve embedded resources before querying, so an ambiguous or
+	// missing relationship fails the request, and find the join columns
 	extraCols := make(map[string]bool) // columns we need but weren't requested
 	var fkInfoMap = make(map[string]*foreignKeyInfo)
 
-	if len(embedded) > 0 && !containsColumn(mainColumns, "*") {
-		ctx := r.Context()
-		for _, emb := range embedded {
-			fkInfo, err := s.findForeignKey(ctx, conn, table, emb.table, emb.fkColumn)
-			if err == nil {
-				fkInfoMap[emb.alias] = fkInfo
-				// Determine which column we need from main table
-				if fkInfo.isReverse {
-					// Need the referenced column (usually 'id')
-					if !containsColumn(mainColumns, fkInfo.referencedColumn) {
-						extraCols[fkInfo.referencedColumn] = true
-					}
-				} else {
-					// Need the FK column (e.g., 'country_id')
-					if !containsColumn(mainColumns, fkInfo.column) {
-						extraCols[fkInfo.column] = true
-					}
-				}
+	for _, emb := range embedded {
+		fkInfo, err := s.findForeignKey(ctx, tx, table, emb.table, emb.fkColumn)
+		if err != nil {
+			if rerr, ok := err.(*relationshipError); ok {
+				rerr.write(w)
+				return
+			}
+			writeDBError(w, "query error", err, http.StatusInternalServerError)
+			return
+		}
+		fkInfoMap[emb.alias] = fkInfo
+		if containsColumn(mainColumns, "*") {
+			continue
+		}
+		for _, col := range fkInfo.columns {
+			if !containsColumn(mainColumns, col) {
+				extraCols[col] = true
 			}
 		}
 	}
@@ -591,7 +1514,12 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 	// Build SELECT clause with proper quoting
 	quotedCols := make([]string, 0, len(mainColumns)+len(extraCols))
 	for _, col := range mainColumns {
-		quotedCols = append(quotedCols, buildSelectColumn(col))
+		quoted, err := s.selectColumnExpr(col, table, tableColumns)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+			return
+		}
+		quotedCols = append(quotedCols, quoted)
 	}
 
 	// Add extra columns needed for joins
@@ -601,63 +1529,74 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 
 	selectClause := strings.Join(quotedCols, ", ")
 
-	sqlQuery := fmt.Sprintf("SELECT %s FROM public.%s", selectClause, quotedTable)
+	sqlQuery := fmt.Sprintf("SELECT %s FROM %s", selectClause, quotedTable)
 
 	// Add WHERE clause (but filter out embedded table filters for now)
-	whereClause, whereArgs := s.buildWhereClause(query, 0)
+	whereClause, whereArgs, err := s.buildWhereClause(query, 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
 	if whereClause != "" {
 		sqlQuery += " WHERE " + whereClause
 	}
 
-	// Add ORDER BY with proper quoting
+	// Add ORDER BY with proper quoting. Items like comments(created_at).desc
+	// order an embedded resource instead.
+	var embeddedOrder map[string][]string
 	if orderVals := query["order"]; len(orderVals) > 0 {
-		orderClause := orderVals[0]
-		// Handle order with direction (e.g., "name.desc" or "name ASC")
-		if strings.Contains(orderClause, ".") {
-			parts := strings.SplitN(orderClause, ".", 2)
-			if len(parts) == 2 {
-				// Use space before direction to avoid ambiguity with quoted identifiers
-				// e.g., "another column".desc becomes "another column" DESC
-				direction := strings.ToUpper(parts[1])
-				if direction == "ASC" || direction == "DESC" {
-					orderClause = fmt.Sprintf("%s %s", quoteIdentifier(parts[0]), direction)
-				} else {
-					// Unknown direction, treat as part of column name
-					orderClause = quoteIdentifier(orderClause)
-				}
-			} else {
-				orderClause = quoteIdentifier(orderClause)
-			}
-		} else if strings.Contains(strings.ToUpper(orderClause), " ASC") || strings.Contains(strings.ToUpper(orderClause), " DESC") {
-			// Split by the last space to separate column from direction
-			lastSpace := strings.LastIndex(orderClause, " ")
-			if lastSpace > 0 {
-				col := orderClause[:lastSpace]
-				dir := orderClause[lastSpace+1:]
-				orderClause = fmt.Sprintf("%s %s", quoteIdentifier(col), dir)
-			} else {
-				orderClause = quoteIdentifier(orderClause)
+		mainOrder, embOrder, err := splitOrderParam(orderVals[0])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid order: %v", err), http.StatusBadRequest)
+			return
+		}
+		embeddedOrder = embOrder
+		if mainOrder != "" {
+			orderClause, err := buildOrderClause(mainOrder, names)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid order: %v", err), http.StatusBadRequest)
+				return
 			}
-		} else {
-			orderClause = quoteIdentifier(orderClause)
+			sqlQuery += " ORDER BY " + orderClause
 		}
-		sqlQuery += fmt.Sprintf(" ORDER BY %s", orderClause)
 	}
-
-	// Add LIMIT
-	if limitVals := query["limit"]; len(limitVals) > 0 {
-		sqlQuery += fmt.Sprintf(" LIMIT %s", limitVals[0])
+	embWindows, err := embedWindows(query, embedded, embeddedOrder, names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Add LIMIT and OFFSET, from the parameters and the Range header
+	window, err := requestWindow(r, query)
+	if errors.Is(err, errRangeNotSatisfiable) {
+		w.Header().Set("Content-Range", "*/*")
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if window.limit >= 0 {
+		sqlQuery += fmt.Sprintf(" LIMIT %d", window.limit)
+	}
+	if window.offset > 0 {
+		sqlQuery += fmt.Sprintf(" OFFSET %d", window.offset)
 	}
 
-	// Add OFFSET
-	if offsetVals := query["offset"]; len(offsetVals) > 0 {
-		sqlQuery += fmt.Sprintf(" OFFSET %s", offsetVals[0])
+	if wantsCSV(r) {
+		if len(embedded) > 0 {
+			http.Error(w, "embedded resources are not supported in CSV responses", http.StatusNotAcceptable)
+			return
+		}
+		s.handleCSVGet(ctx, tx, w, r, sqlQuery, quotedTable, whereClause, whereArgs, window)
+		return
 	}
 
 	// Execute main query
-	rows, err := conn.Query(ctx, sqlQuery, whereArgs...)
+	rows, err := tx.Query(ctx, sqlQuery, whereArgs...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("query error: %v", err), http.StatusBadRequest)
+		writeDBError(w, "query error", err, http.StatusBadRequest)
 		return
 	}
 	defer rows.Close()
@@ -667,7 +1606,7 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 	for rows.Next() {
 		row, err := rows.Values()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("row scan error: %v", err), http.StatusInternalServerError)
+			writeDBError(w, "row scan error", err, http.StatusInternalServerError)
 			return
 		}
 
@@ -683,9 +1622,9 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 	// Fetch embedded resources if any
 	if len(embedded) > 0 && len(results) > 0 {
 		var err error
-		results, err = s.fetchEmbeddedResourcesWithFKInfo(ctx, conn, table, results, embedded, query, fkInfoMap)
+		results, err = s.fetchEmbeddedResourcesWithFKInfo(ctx, tx, table, results, embedded, query, fkInfoMap, embWindows)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("embedded resource error: %v", err), http.StatusBadRequest)
+			writeDBError(w, "embedded resource error", err, http.StatusBadRequest)
 			return
 		}
 	}
@@ -699,50 +1638,44 @@ func (s *Server) handleGET(ctx context.Context, conn *pgx.Conn, w http.ResponseW
 		}
 	}
 
-	// Check for count header
-	prefer := r.Header.Get("Prefer")
-	if strings.Contains(prefer, "count=exact") {
-		// Execute count query
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM public.%s", quotedTable)
-		if whereClause != "" {
-			countQuery += " WHERE " + whereClause
-		}
-		var count int64
-		err := conn.QueryRow(ctx, countQuery, whereArgs...).Scan(&count)
-		if err == nil {
-			// Set Content-Range header: items 0-N/total
-			rangeEnd := int64(len(results)) - 1
-			if rangeEnd < 0 {
-				rangeEnd = 0
-			}
-			w.Header().Set("Content-Range", fmt.Sprintf("0-%d/%d", rangeEnd, count))
-		}
+	status, ok := s.setContentRange(ctx, tx, w, r, quotedTable, whereClause, whereArgs, window.offset, len(results))
+	if !ok {
+		return
 	}
-
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(results)
+	s.writeRows(w, r, status, results)
 }
 
 // handleHEAD processes HEAD requests (count-only)
-func (s *Server) handleHEAD(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, table string) {
+func (s *Server) handleHEAD(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, schema, table string) {
 	query := r.URL.Query()
-	quotedTable := quoteIdentifier(table)
+	quotedTable := qualifiedTable(schema, table)
 
-	// Build WHERE clause
-	whereClause, whereArgs := s.buildWhereClause(query, 0)
+	// Past rows stand in for the table
+	if query.Has(asOfParam) {
+		source, status, err := s.asOfSource(r, schema, table, query.Get(asOfParam))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		quotedTable = source
+		query.Del(asOfParam)
+	}
 
-	// Execute count query
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM public.%s", quotedTable)
-	if whereClause != "" {
-		countQuery += " WHERE " + whereClause
+	// Build WHERE clause
+	whereClause, whereArgs, err := s.buildWhereClause(query, 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	var count int64
-	err := conn.QueryRow(ctx, countQuery, whereArgs...).Scan(&count)
+	// Execute count query, exact unless the client prefers another mode
+	mode := countMode(r)
+	if mode == "" {
+		mode = "exact"
+	}
+	count, err := countRows(ctx, tx, quotedTable, whereClause, whereArgs, mode)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("count error: %v", err), http.StatusBadRequest)
+		writeDBError(w, "count error", err, http.StatusBadRequest)
 		return
 	}
 
@@ -754,14 +1687,15 @@ func (s *Server) handleHEAD(ctx context.Context, conn *pgx.Conn, w http.Response
 
 // fetchEmbeddedResourcesWithFKInfo fetches related data using pre-computed FK info
 // Returns a possibly filtered results slice (for inner joins that filter out non-matching rows)
-func (s *Server) fetchEmbeddedResourcesWithFKInfo(ctx context.Context, conn *pgx.Conn, mainTable string, results []map[string]interface{}, embedded []embeddedResource, query url.Values, fkInfoMap map[string]*foreignKeyInfo) ([]map[string]interface{}, error) {
+// Embedded arrays are ordered and paginated per windows; to-one embeds ignore them
+func (s *Server) fetchEmbeddedResourcesWithFKInfo(ctx context.Context, tx pgx.Tx, mainTable string, results []map[string]interface{}, embedded []embeddedResource, query url.Values, fkInfoMap map[string]*foreignKeyInfo, windows map[string]embedWindow) ([]map[string]interface{}, error) {
 	for _, emb := range embedded {
 		// Get pre-computed FK info
 		fkInfo, ok := fkInfoMap[emb.alias]
 		if !ok {
 			// Try to find it now (shouldn't happen, but fallback)
 			var err error
-			fkInfo, err = s.findForeignKey(ctx, conn, mainTable, emb.table, emb.fkColumn)
+			fkInfo, err = s.findForeignKey(ctx, tx, mainTable, emb.table, emb.fkColumn)
 			if err != nil {
 				return nil, fmt.Errorf("cannot find relationship for %s: %w", emb.table, err)
 			}
@@ -770,6 +1704,9 @@ func (s *Server) fetchEmbeddedResourcesWithFKInfo(ctx context.Context, conn *pgx
 		// Check if there's a filter on this embedded table
 		embeddedFilter := ""
 		for key, vals := range query {
+			if isEmbedWindowParam(key) {
+				continue
+			}
 			if strings.HasPrefix(key, emb.alias+".") || strings.HasPrefix(key, emb.table+".") {
 				filterCol := strings.TrimPrefix(key, emb.alias+".")
 				filterCol = strings.TrimPrefix(filterCol, emb.table+".")
@@ -777,192 +1714,90 @@ func (s *Server) fetchEmbeddedResourcesWithFKInfo(ctx context.Context, conn *pgx
 					// Parse the filter value
 					filterVal := vals[0]
 					if strings.HasPrefix(filterVal, "eq.") {
-						embeddedFilter = fmt.Sprintf("%s = '%s'", quoteIdentifier(filterCol), filterVal[3:])
+						filterCol = s.config.REST.IdentifierCase.name(filterCol)
+						embeddedFilter = fmt.Sprintf("%s = %s", quoteIdentifier(filterCol), quoteLiteral(filterVal[3:]))
 					}
 				}
 				break
 			}
 		}
 
-		// Fetch related data based on relationship direction
+		// Build column list for embedded query
+		embCols, err := embeddedColumns(emb.columns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid columns for %s: %w", emb.alias, err)
+		}
+
+		// Fetch related data based on relationship direction. Each query
+		// takes the main row's join column values as $1, $2, ...
+		var embQuery string
 		if fkInfo.isManyToMany {
 			// Many-to-many through junction table
 			// e.g., users -> user_teams -> teams
-			for _, result := range results {
-				mainID := result["id"]
-				if mainID == nil {
-					result[emb.alias] = []interface{}{}
-					continue
-				}
-
-				// Build column list for embedded query
-				var embCols string
-				if emb.columns == "" || emb.columns == "*" {
-					embCols = fmt.Sprintf("t.*")
-				} else {
-					cols := strings.Split(emb.columns, ",")
-					quotedCols := make([]string, len(cols))
-					for i, c := range cols {
-						quotedCols[i] = fmt.Sprintf("t.%s", quoteIdentifier(strings.TrimSpace(c)))
-					}
-					embCols = strings.Join(quotedCols, ", ")
-				}
-
-				// Query through junction table
-				embQuery := fmt.Sprintf(`
-					SELECT %s FROM public.%s t
-					INNER JOIN public.%s j ON j.%s = t.id
-					WHERE j.%s = $1`,
-					embCols,
-					quoteIdentifier(emb.table),
-					quoteIdentifier(fkInfo.junctionTable),
-					quoteIdentifier(fkInfo.junctionForeignFK),
-					quoteIdentifier(fkInfo.junctionMainFK))
-				if embeddedFilter != "" {
-					embQuery += " AND " + embeddedFilter
-				}
-
-				embRows, err := conn.Query(ctx, embQuery, mainID)
-				if err != nil {
-					return nil, fmt.Errorf("embedded query error: %w", err)
-				}
-
-				embResults := make([]map[string]interface{}, 0)
-				for embRows.Next() {
-					embRow, err := embRows.Values()
-					if err != nil {
-						embRows.Close()
-						return nil, fmt.Errorf("embedded row error: %w", err)
-					}
-					embDesc := embRows.FieldDescriptions()
-					embResult := make(map[string]interface{})
-					for i, col := range embDesc {
-						embResult[col.Name] = embRow[i]
-					}
-					embResults = append(embResults, embResult)
-				}
-				embRows.Close()
+			embQuery = fmt.Sprintf("SELECT %s FROM public.%s t INNER JOIN public.%s j ON %s WHERE %s",
+				embCols,
+				quoteIdentifier(emb.table),
+				quoteIdentifier(fkInfo.junctionTable),
+				joinCondition("j.", fkInfo.junctionForeignColumns, "t.", fkInfo.foreignColumns),
+				keyCondition("j.", fkInfo.junctionColumns))
+		} else {
+			// e.g., cities.country_id -> countries.id: fetch the country
+			// where id = cities.country_id; or the reverse, fetch the cities
+			// where country_id = countries.id
+			embQuery = fmt.Sprintf("SELECT %s FROM public.%s t WHERE %s",
+				embCols, quoteIdentifier(emb.table), keyCondition("t.", fkInfo.foreignColumns))
+		}
+		if embeddedFilter != "" {
+			embQuery += " AND t." + embeddedFilter
+		}
+		if fkInfo.isReverse {
+			embQuery += windows[emb.alias].sql("t.")
+		}
 
-				// Remove if inner join with no match, or if there's a filter but no matching result
-				if len(embResults) == 0 && (emb.isInner || embeddedFilter != "") {
-					result["__remove__"] = true
-				} else {
-					result[emb.alias] = embResults
-				}
-			}
-		} else if fkInfo.isReverse {
-			// The foreign table has FK pointing to main table
-			// e.g., instruments.section_id -> orchestral_sections.id
-			// When querying orchestral_sections, fetch instruments where section_id = orchestral_sections.id
-			for _, result := range results {
-				mainID := result[fkInfo.referencedColumn]
-				if mainID == nil {
+		for _, result := range results {
+			keys, ok := keyValues(result, fkInfo.columns)
+			if !ok {
+				if fkInfo.isReverse {
 					result[emb.alias] = []interface{}{}
-					continue
-				}
-
-				// Build column list for embedded query
-				var embCols string
-				if emb.columns == "" || emb.columns == "*" {
-					embCols = "*"
-				} else {
-					cols := strings.Split(emb.columns, ",")
-					quotedCols := make([]string, len(cols))
-					for i, c := range cols {
-						quotedCols[i] = quoteIdentifier(strings.TrimSpace(c))
-					}
-					embCols = strings.Join(quotedCols, ", ")
-				}
-
-				embQuery := fmt.Sprintf("SELECT %s FROM public.%s WHERE %s = $1",
-					embCols, quoteIdentifier(emb.table), quoteIdentifier(fkInfo.column))
-				if embeddedFilter != "" {
-					embQuery += " AND " + embeddedFilter
-				}
-
-				embRows, err := conn.Query(ctx, embQuery, mainID)
-				if err != nil {
-					return nil, fmt.Errorf("embedded query error: %w", err)
-				}
-
-				embResults := make([]map[string]interface{}, 0)
-				for embRows.Next() {
-					embRow, err := embRows.Values()
-					if err != nil {
-						embRows.Close()
-						return nil, fmt.Errorf("embedded row error: %w", err)
-					}
-					embDesc := embRows.FieldDescriptions()
-					embResult := make(map[string]interface{})
-					for i, col := range embDesc {
-						embResult[col.Name] = embRow[i]
-					}
-					embResults = append(embResults, embResult)
-				}
-				embRows.Close()
-
-				// Remove if inner join with no match, or if there's a filter but no matching result
-				if len(embResults) == 0 && (emb.isInner || embeddedFilter != "") {
-					result["__remove__"] = true
 				} else {
-					result[emb.alias] = embResults
-				}
-			}
-		} else {
-			// Main table has FK pointing to foreign table
-			// e.g., cities.country_id -> countries.id
-			// When querying cities, fetch the country where id = cities.country_id
-			for _, result := range results {
-				fkValue := result[fkInfo.column]
-				if fkValue == nil {
 					result[emb.alias] = nil
-					continue
-				}
-
-				// Build column list for embedded query
-				var embCols string
-				if emb.columns == "" || emb.columns == "*" {
-					embCols = "*"
-				} else {
-					cols := strings.Split(emb.columns, ",")
-					quotedCols := make([]string, len(cols))
-					for i, c := range cols {
-						quotedCols[i] = quoteIdentifier(strings.TrimSpace(c))
-					}
-					embCols = strings.Join(quotedCols, ", ")
-				}
-
-				embQuery := fmt.Sprintf("SELECT %s FROM public.%s WHERE %s = $1",
-					embCols, quoteIdentifier(emb.table), quoteIdentifier(fkInfo.referencedColumn))
-				if embeddedFilter != "" {
-					embQuery += " AND " + embeddedFilter
 				}
+				continue
+			}
 
-				var embResult map[string]interface{}
-				embRow, err := conn.Query(ctx, embQuery, fkValue)
+			embRows, err := tx.Query(ctx, embQuery, keys...)
+			if err != nil {
+				return nil, fmt.Errorf("embedded query error: %w", err)
+			}
+			embResults := make([]map[string]interface{}, 0)
+			for embRows.Next() {
+				embRow, err := embRows.Values()
 				if err != nil {
-					return nil, fmt.Errorf("embedded query error: %w", err)
+					embRows.Close()
+					return nil, fmt.Errorf("embedded row error: %w", err)
 				}
-				if embRow.Next() {
-					vals, err := embRow.Values()
-					if err != nil {
-						embRow.Close()
-						return nil, fmt.Errorf("embedded row error: %w", err)
-					}
-					embDesc := embRow.FieldDescriptions()
-					embResult = make(map[string]interface{})
-					for i, col := range embDesc {
-						embResult[col.Name] = vals[i]
-					}
+				embDesc := embRows.FieldDescriptions()
+				embResult := make(map[string]interface{})
+				for i, col := range embDesc {
+					embResult[col.Name] = embRow[i]
 				}
-				embRow.Close()
+				embResults = append(embResults, embResult)
+			}
+			embRows.Close()
+			if err := embRows.Err(); err != nil {
+				return nil, fmt.Errorf("embedded query error: %w", err)
+			}
 
-				// Remove if inner join with no match, or if there's a filter but no matching result
-				if embResult == nil && (emb.isInner || embeddedFilter != "") {
-					result["__remove__"] = true
-				} else {
-					result[emb.alias] = embResult
-				}
+			// Remove if inner join with no match, or if there's a filter but no matching result
+			switch {
+			case len(embResults) == 0 && (emb.isInner || embeddedFilter != ""):
+				result["__remove__"] = true
+			case fkInfo.isReverse:
+				result[emb.alias] = embResults
+			case len(embResults) > 0:
+				result[emb.alias] = embResults[0]
+			default:
+				result[emb.alias] = nil
 			}
 		}
 	}
@@ -979,211 +1814,47 @@ func (s *Server) fetchEmbeddedResourcesWithFKInfo(ctx context.Context, conn *pgx
 	return finalResults, nil
 }
 
-// foreignKeyInfo holds information about a foreign key relationship
-type foreignKeyInfo struct {
-	column           string // The column in the "from" table
-	referencedTable  string // The referenced table
-	referencedColumn string // The column in the referenced table
-	isReverse        bool   // true if the FK points from the foreign table to main table
-	isManyToMany     bool   // true if this is a many-to-many through junction table
-	junctionTable    string // The junction table name (for many-to-many)
-	junctionMainFK   string // FK column in junction pointing to main table
-	junctionForeignFK string // FK column in junction pointing to foreign table
-}
-
-// findForeignKey finds the foreign key relationship between two tables
-func (s *Server) findForeignKey(ctx context.Context, conn *pgx.Conn, mainTable, foreignTable, specifiedFK string) (*foreignKeyInfo, error) {
-	// First, check if there's a direct FK from main table to foreign table
-	query := `
-		SELECT
-			kcu.column_name,
-			ccu.table_name AS foreign_table_name,
-			ccu.column_name AS foreign_column_name
-		FROM information_schema.table_constraints AS tc
-		JOIN information_schema.key_column_usage AS kcu
-			ON tc.constraint_name = kcu.constraint_name
-			AND tc.table_schema = kcu.table_schema
-		JOIN information_schema.constraint_column_usage AS ccu
-			ON ccu.constraint_name = tc.constraint_name
-			AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY'
-			AND tc.table_name = $1
-			AND ccu.table_name = $2
-	`
-	if specifiedFK != "" {
-		query += fmt.Sprintf(" AND kcu.column_name = '%s'", specifiedFK)
-	}
-
-	rows, err := conn.Query(ctx, query, mainTable, foreignTable)
-	if err != nil {
-		return nil, err
-	}
-
-	var foundDirect bool
-	var directCol, directRefTable, directRefCol string
-	if rows.Next() {
-		if err := rows.Scan(&directCol, &directRefTable, &directRefCol); err != nil {
-			rows.Close()
-			return nil, err
-		}
-		foundDirect = true
-	}
-	rows.Close() // Close before next query
-
-	if foundDirect {
-		return &foreignKeyInfo{
-			column:           directCol,
-			referencedTable:  directRefTable,
-			referencedColumn: directRefCol,
-			isReverse:        false,
-		}, nil
-	}
-
-	// Check reverse: FK from foreign table to main table
-	query2 := `
-		SELECT
-			kcu.column_name,
-			ccu.table_name AS foreign_table_name,
-			ccu.column_name AS foreign_column_name
-		FROM information_schema.table_constraints AS tc
-		JOIN information_schema.key_column_usage AS kcu
-			ON tc.constraint_name = kcu.constraint_name
-			AND tc.table_schema = kcu.table_schema
-		JOIN information_schema.constraint_column_usage AS ccu
-			ON ccu.constraint_name = tc.constraint_name
-			AND ccu.table_schema = tc.table_schema
-		WHERE tc.constraint_type = 'FOREIGN KEY'
-			AND tc.table_name = $1
-			AND ccu.table_name = $2
-	`
-	if specifiedFK != "" {
-		query2 += fmt.Sprintf(" AND kcu.column_name = '%s'", specifiedFK)
-	}
-
-	rows2, err := conn.Query(ctx, query2, foreignTable, mainTable)
-	if err != nil {
-		return nil, err
-	}
-
-	var foundReverse bool
-	var reverseCol, reverseRefTable, reverseRefCol string
-	if rows2.Next() {
-		if err := rows2.Scan(&reverseCol, &reverseRefTable, &reverseRefCol); err != nil {
-			rows2.Close()
-			return nil, err
-		}
-		foundReverse = true
-	}
-	rows2.Close() // Close before next query
-
-	if foundReverse {
-		return &foreignKeyInfo{
-			column:           reverseCol,
-			referencedTable:  reverseRefTable,
-			referencedColumn: reverseRefCol,
-			isReverse:        true,
-		}, nil
-	}
-
-	// Check for many-to-many through a junction table
-	// Look for a junction table that has FKs to both tables
-	junctionQuery := `
-		SELECT DISTINCT tc.table_name as junction_table
-		FROM information_schema.table_constraints AS tc
-		JOIN information_schema.key_column_usage AS kcu
-			ON tc.constraint_name = kcu.constraint_name
-		JOIN information_schema.constraint_column_usage AS ccu
-			ON ccu.constraint_name = tc.constraint_name
-		WHERE tc.constraint_type = 'FOREIGN KEY'
-			AND ccu.table_name = $1
-		INTERSECT
-		SELECT DISTINCT tc.table_name as junction_table
-		FROM information_schema.table_constraints AS tc
-		JOIN information_schema.key_column_usage AS kcu
-			ON tc.constraint_name = kcu.constraint_name
-		JOIN information_schema.constraint_column_usage AS ccu
-			ON ccu.constraint_name = tc.constraint_name
-		WHERE tc.constraint_type = 'FOREIGN KEY'
-			AND ccu.table_name = $2
-	`
-	jRows, err := conn.Query(ctx, junctionQuery, mainTable, foreignTable)
-	if err != nil {
-		return nil, err
-	}
-	defer jRows.Close()
-
-	if jRows.Next() {
-		var junctionTable string
-		if err := jRows.Scan(&junctionTable); err != nil {
-			return nil, err
-		}
-		jRows.Close()
-
-		// Get the FK column names from the junction table
-		fkQuery := `
-			SELECT kcu.column_name, ccu.table_name
-			FROM information_schema.table_constraints AS tc
-			JOIN information_schema.key_column_usage AS kcu
-				ON tc.constraint_name = kcu.constraint_name
-			JOIN information_schema.constraint_column_usage AS ccu
-				ON ccu.constraint_name = tc.constraint_name
-			WHERE tc.constraint_type = 'FOREIGN KEY'
-				AND tc.table_name = $1
-				AND (ccu.table_name = $2 OR ccu.table_name = $3)
-		`
-		fkRows, err := conn.Query(ctx, fkQuery, junctionTable, mainTable, foreignTable)
-		if err != nil {
-			return nil, err
-		}
-
-		var mainFK, foreignFK string
-		for fkRows.Next() {
-			var col, refTable string
-			if err := fkRows.Scan(&col, &refTable); err != nil {
-				fkRows.Close()
-				return nil, err
-			}
-			if refTable == mainTable {
-				mainFK = col
-			} else if refTable == foreignTable {
-				foreignFK = col
-			}
-		}
-		fkRows.Close()
-
-		return &foreignKeyInfo{
-			column:            "id",
-			referencedTable:   foreignTable,
-			referencedColumn:  "id",
-			isReverse:         true,
-			isManyToMany:      true,
-			junctionTable:     junctionTable,
-			junctionMainFK:    mainFK,
-			junctionForeignFK: foreignFK,
-		}, nil
-	}
-
-	return nil, fmt.Errorf("no foreign key relationship found between %s and %s", mainTable, foreignTable)
-}
-
 // buildWhereClause constructs WHERE clause from query parameters
-// Supabase format: ?column=eq.value ?column=gt.value ?column=lt.value
+// Supabase format: ?column=eq.value ?column=gt.value ?column=not.lt.value,
+// and logic trees: ?or=(age.gte.18,name.eq.Bob) ?not.and=(a.eq.1,b.eq.2)
 // offset is the starting parameter number (for use in UPDATE queries with SET clause)
-func (s *Server) buildWhereClause(query url.Values, offset int) (string, []interface{}) {
+func (s *Server) buildWhereClause(query url.Values, offset int) (string, []interface{}, error) {
+	b := &filterBuilder{offset: offset, names: s.config.REST.IdentifierCase}
 	var clauses []string
-	var args []interface{}
 
-	// Skip non-filter parameters (like select, order, limit, offset)
+	// Skip non-filter parameters (like select, order, limit, offset, and
+	// the apikey, which may be passed as a parameter as with Supabase)
 	// Also skip embedded table filters (e.g., countries.name=eq.Canada) - they're handled separately
 	skipParams := map[string]bool{
 		"select": true,
 		"order":  true,
 		"limit":  true,
 		"offset": true,
+		"apikey": true,
 	}
 
-	for key, values := range query {
-		if skipParams[key] || len(values) == 0 {
+	// Visit keys in order so the generated SQL is stable
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if skipParams[key] {
+			continue
+		}
+
+		// Logic trees: or, and, not.or, not.and
+		op, negate := strings.CutPrefix(key, "not.")
+		if op == "or" || op == "and" {
+			for _, value := range query[key] {
+				cond, err := b.logic(op, value, negate)
+				if err != nil {
+					return "", nil, err
+				}
+				clauses = append(clauses, cond)
+			}
 			continue
 		}
 
@@ -1193,150 +1864,58 @@ func (s *Server) buildWhereClause(query url.Values, offset int) (string, []inter
 			continue
 		}
 
-		value := values[0]
-
-		// Parse operator from value (e.g., "eq.1", "gt.5", "lt.10")
-		if strings.Contains(value, ".") {
-			parts := strings.SplitN(value, ".", 2)
-			if len(parts) == 2 {
-				operator := parts[0]
-				argValue := parts[1]
-
-				// Build the column reference - handle JSON arrow operators
-				var colRef string
-				if strings.Contains(key, "->>") {
-					// JSON arrow operator: address->>postcode becomes "address"->>'postcode'
-					jsonParts := strings.SplitN(key, "->>", 2)
-					colRef = fmt.Sprintf("%s->>'%s'", quoteIdentifier(jsonParts[0]), jsonParts[1])
-				} else if strings.Contains(key, "->") {
-					// JSON arrow operator: address->city becomes "address"->'city'
-					jsonParts := strings.SplitN(key, "->", 2)
-					colRef = fmt.Sprintf("%s->'%s'", quoteIdentifier(jsonParts[0]), jsonParts[1])
-				} else {
-					// Regular column reference
-					colRef = quoteIdentifier(key)
-				}
-
-				switch operator {
-				case "eq":
-					clauses = append(clauses, fmt.Sprintf("%s = $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "neq":
-					clauses = append(clauses, fmt.Sprintf("%s != $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "gt":
-					clauses = append(clauses, fmt.Sprintf("%s > $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "gte":
-					clauses = append(clauses, fmt.Sprintf("%s >= $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "lt":
-					clauses = append(clauses, fmt.Sprintf("%s < $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "lte":
-					clauses = append(clauses, fmt.Sprintf("%s <= $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "like":
-					clauses = append(clauses, fmt.Sprintf("%s LIKE $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "ilike":
-					clauses = append(clauses, fmt.Sprintf("%s ILIKE $%d", colRef, offset+len(args)+1))
-					args = append(args, argValue)
-				case "in":
-					// Handle IN clause: in.(1,2,3) - strip parentheses
-					argValue = strings.TrimPrefix(argValue, "(")
-					argValue = strings.TrimSuffix(argValue, ")")
-					inValues := strings.Split(argValue, ",")
-
-					// Infer data type from the first non-empty value
-					// If all values look like integers, cast to integer, otherwise use text
-					allIntegers := true
-					for _, v := range inValues {
-						trimmed := strings.TrimSpace(v)
-						if trimmed == "" {
-							continue
-						}
-						// Check if the value is a valid integer (optional negative sign)
-						_, err := strconv.ParseInt(trimmed, 10, 64)
-						if err != nil {
-							allIntegers = false
-							break
-						}
-					}
-
-					// Build IN clause with proper casting for each element
-					inClauses := make([]string, len(inValues))
-					baseIdx := offset + len(args) // Calculate base before loop
-					for i, v := range inValues {
-						paramIdx := baseIdx + i + 1
-						if allIntegers {
-							// Cast each parameter to integer using CAST syntax
-							inClauses[i] = fmt.Sprintf("CAST($%d AS integer)", paramIdx)
-						} else {
-							// Cast each parameter to text using CAST syntax
-							inClauses[i] = fmt.Sprintf("CAST($%d AS text)", paramIdx)
-						}
-						args = append(args, v)
-					}
-
-					// Use simple IN clause instead of ANY - this avoids type ambiguity
-					clauses = append(clauses, fmt.Sprintf("%s IN (%s)", colRef, strings.Join(inClauses, ", ")))
-				default:
-					// Unknown operator, treat as direct equality
-					clauses = append(clauses, fmt.Sprintf("%s = $%d", colRef, offset+len(args)+1))
-					args = append(args, value)
-				}
-				continue
+		// Repeated filters on a column all apply: ?age=gte.18&age=lte.65
+		for _, value := range query[key] {
+			cond, err := b.filter(key, value)
+			if err != nil {
+				return "", nil, err
 			}
+			clauses = append(clauses, cond)
 		}
-
-		// No operator specified, use direct equality with JSON support
-		var colRef string
-		if strings.Contains(key, "->>") {
-			jsonParts := strings.SplitN(key, "->>", 2)
-			colRef = fmt.Sprintf("%s->>'%s'", quoteIdentifier(jsonParts[0]), jsonParts[1])
-		} else if strings.Contains(key, "->") {
-			jsonParts := strings.SplitN(key, "->", 2)
-			colRef = fmt.Sprintf("%s->'%s'", quoteIdentifier(jsonParts[0]), jsonParts[1])
-		} else {
-			colRef = quoteIdentifier(key)
-		}
-		clauses = append(clauses, fmt.Sprintf("%s = $%d", colRef, offset+len(args)+1))
-		args = append(args, value)
 	}
 
 	if len(clauses) > 0 {
-		return strings.Join(clauses, " AND "), args
+		return strings.Join(clauses, " AND "), b.args, nil
 	}
-	return "", nil
+	return "", nil, nil
 }
 
 // handlePOST processes INSERT and UPSERT requests
-func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, table string) {
+func (s *Server) handlePOST(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, table string) {
 	// Quote table name for SQL
 	quotedTable := quoteIdentifier(table)
 
-	// Decode JSON body - can be single object or array
-	var rawData interface{}
-	if err := json.NewDecoder(r.Body).Decode(&rawData); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	// Convert to array for uniform processing
 	var records []map[string]interface{}
-	switch v := rawData.(type) {
-	case map[string]interface{}:
-		records = []map[string]interface{}{v}
-	case []interface{}:
-		for _, item := range v {
-			if m, ok := item.(map[string]interface{}); ok {
-				records = append(records, m)
+	if isCSVBody(r) {
+		// CSV body: a header row of column names, then one row per record
+		var err error
+		records, err = parseCSVRecords(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CSV: %v", err), http.StatusBadRequest)
+			return
+		}
+	} else {
+		// Decode JSON body - can be single object or array
+		var rawData interface{}
+		if err := json.NewDecoder(r.Body).Decode(&rawData); err != nil {
+			http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// Convert to array for uniform processing
+		switch v := rawData.(type) {
+		case map[string]interface{}:
+			records = []map[string]interface{}{v}
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					records = append(records, m)
+				}
 			}
+		default:
+			http.Error(w, "invalid JSON format", http.StatusBadRequest)
+			return
 		}
-	default:
-		http.Error(w, "invalid JSON format", http.StatusBadRequest)
-		return
 	}
 
 	if len(records) == 0 {
@@ -1349,19 +1928,40 @@ func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.Response
 	onConflict := query.Get("on_conflict")
 
 	// Check for Prefer header - Supabase uses this to indicate upsert
-	prefer := r.Header.Get("Prefer")
-	isUpsert := strings.Contains(prefer, "resolution=merge-duplicates") || strings.Contains(prefer, "resolution=ignore-duplicates")
-	ignoreDuplicates := strings.Contains(prefer, "resolution=ignore-duplicates")
-
-	// Get all unique columns from all records
-	colMap := make(map[string]bool)
+	prefs := preferences(r)
+	resolution := prefs["resolution"]
+	isUpsert := onConflict != "" || resolution == resolutionMerge || resolution == resolutionIgnore
+	ignoreDuplicates := resolution == resolutionIgnore
+
+	// Prefer: missing=default fills keys a record leaves out with the
+	// column default instead of NULL, for bulk inserts of uneven objects
+	missingDefault := prefs["missing"] == "default"
+	if missingDefault {
+		w.Header().Add("Preference-Applied", "missing=default")
+	}
+
+	// Get all unique columns from all records, and the key each is given
+	// by in the records
+	names := s.config.REST.IdentifierCase
+	keyOf := make(map[string]string)
 	for _, record := range records {
-		for col := range record {
-			colMap[col] = true
+		for key := range record {
+			col := names.name(key)
+			if other, ok := keyOf[col]; ok && other != key {
+				http.Error(w, fmt.Sprintf("invalid column: %q and %q both name %q", other, key, col), http.StatusBadRequest)
+				return
+			}
+			keyOf[col] = key
 		}
 	}
-	columns := make([]string, 0, len(colMap))
-	for col := range colMap {
+	colNames := make([]string, 0, len(keyOf))
+	columns := make([]string, 0, len(keyOf))
+	for col := range keyOf {
+		if err := validateIdentifier(col); err != nil {
+			http.Error(w, fmt.Sprintf("invalid column: %v", err), http.StatusBadRequest)
+			return
+		}
+		colNames = append(colNames, col)
 		columns = append(columns, quoteIdentifier(col))
 	}
 
@@ -1371,9 +1971,12 @@ func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.Response
 	paramIdx := 1
 	for _, record := range records {
 		placeholders := make([]string, 0, len(columns))
-		for _, col := range columns {
-			colName := strings.Trim(col, "\"")
-			val := record[colName]
+		for _, colName := range colNames {
+			val, ok := record[keyOf[colName]]
+			if !ok && missingDefault {
+				placeholders = append(placeholders, "DEFAULT")
+				continue
+			}
 			placeholders = append(placeholders, fmt.Sprintf("$%d", paramIdx))
 			values = append(values, val)
 			paramIdx++
@@ -1382,91 +1985,49 @@ func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.Response
 	}
 
 	// Parse select parameter for RETURNING clause (Supabase compatibility)
-	var returningClause string
-	if selectVals := query["select"]; len(selectVals) > 0 {
-		selectCols := strings.Split(selectVals[0], ",")
-		quotedCols := make([]string, 0, len(selectCols))
-		for _, col := range selectCols {
-			col = strings.TrimSpace(col)
-			if col != "*" {
-				quotedCols = append(quotedCols, quoteIdentifier(col))
-			} else {
-				quotedCols = append(quotedCols, "*")
+	returningClause, err := buildReturningClause(query, names)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Determine the conflict target for an upsert: on_conflict, or the
+	// table's primary key (or unique constraint)
+	var conflictCols []string
+	if isUpsert {
+		var err error
+		if onConflict != "" {
+			conflictCols, err = parseOnConflict(onConflict, names)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid on_conflict: %v", err), http.StatusBadRequest)
+				return
 			}
-		}
-		returningClause = strings.Join(quotedCols, ", ")
-	} else {
-		returningClause = "*"
-	}
-
-	// Determine conflict target for UPSERT (quote it)
-	conflictTarget := onConflict
-	if (onConflict != "" || isUpsert) && conflictTarget == "" {
-		// If on_conflict not specified, try to infer primary key
-		// Common primary key names to try
-		for _, pk := range []string{"id", "ID", "Id", "pk", "PK"} {
-			for _, col := range columns {
-				colName := strings.Trim(col, "\"")
-				if colName == pk {
-					conflictTarget = colName
-					break
-				}
+		} else {
+			conflictCols, err = conflictColumns(ctx, tx, table)
+			if err != nil {
+				writeDBError(w, "constraint lookup error", err, http.StatusBadRequest)
+				return
 			}
-			if conflictTarget != "" {
-				break
+			if len(conflictCols) == 0 {
+				http.Error(w, "on_conflict is required: the table has no primary key or unique constraint", http.StatusBadRequest)
+				return
 			}
 		}
-		// If still no conflict target, use first column
-		if conflictTarget == "" && len(columns) > 0 {
-			conflictTarget = strings.Trim(columns[0], "\"")
-		}
 	}
 
-	var sqlQuery string
-	if onConflict != "" || isUpsert {
-		// UPSERT: INSERT ... ON CONFLICT ... DO UPDATE
-
-		// Build the UPDATE SET clause for conflicting rows
-		updateSets := make([]string, 0)
-		for _, col := range columns {
-			colName := strings.Trim(col, "\"")
-			// Skip the conflict target column(s) in the update
-			if !strings.Contains(conflictTarget, colName) {
-				updateSets = append(updateSets, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
-			}
-		}
-
-		if ignoreDuplicates {
-			// ON CONFLICT DO NOTHING (when ignoreDuplicates is true, always DO NOTHING)
-			sqlQuery = fmt.Sprintf("INSERT INTO public.%s (%s) VALUES %s ON CONFLICT (%s) DO NOTHING RETURNING %s",
-				quotedTable,
-				strings.Join(columns, ", "),
-				strings.Join(valueSets, ", "),
-				quoteIdentifier(conflictTarget),
-				returningClause)
-		} else {
-			// ON CONFLICT ... DO UPDATE SET ...
-			sqlQuery = fmt.Sprintf("INSERT INTO public.%s (%s) VALUES %s ON CONFLICT (%s) DO UPDATE SET %s RETURNING %s",
-				quotedTable,
-				strings.Join(columns, ", "),
-				strings.Join(valueSets, ", "),
-				quoteIdentifier(conflictTarget),
-				strings.Join(updateSets, ", "),
-				returningClause)
-		}
-	} else {
-		// Regular INSERT
-		sqlQuery = fmt.Sprintf("INSERT INTO public.%s (%s) VALUES %s RETURNING %s",
-			quotedTable,
-			strings.Join(columns, ", "),
-			strings.Join(valueSets, ", "),
-			returningClause)
+	sqlQuery := fmt.Sprintf("INSERT INTO public.%s (%s) VALUES %s",
+		quotedTable,
+		strings.Join(columns, ", "),
+		strings.Join(valueSets, ", "))
+	if isUpsert {
+		sqlQuery += " " + upsertClause(conflictCols, colNames, ignoreDuplicates)
 	}
+	sqlQuery += " RETURNING " + returningClause
 
 	// Execute query
-	rows, err := conn.Query(ctx, sqlQuery, values...)
+	rows, err := tx.Query(ctx, sqlQuery, values...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("insert error: %v", err), http.StatusBadRequest)
+		writeDBError(w, "insert error", err, http.StatusBadRequest)
 		return
 	}
 	defer rows.Close()
@@ -1476,7 +2037,7 @@ func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.Response
 	for rows.Next() {
 		row, err := rows.Values()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("row scan error: %v", err), http.StatusInternalServerError)
+			writeDBError(w, "row scan error", err, http.StatusInternalServerError)
 			return
 		}
 
@@ -1490,52 +2051,52 @@ func (s *Server) handlePOST(ctx context.Context, conn *pgx.Conn, w http.Response
 
 	// Special handling for ignoreDuplicates: if no rows returned (conflict occurred),
 	// fetch the existing row to match Supabase behavior
-	if ignoreDuplicates && len(results) == 0 && len(records) == 1 && conflictTarget != "" {
-		// Build WHERE clause to fetch the conflicting row
+	if ignoreDuplicates && len(results) == 0 && len(records) == 1 {
+		// Find the existing row by every column of the conflict target
 		record := records[0]
-		var whereClauses []string
-		var whereArgs []interface{}
-		argIdx := 1
-
-		// Use the conflict target column value to find the existing row
-		conflictColValue := record[conflictTarget]
-		if conflictColValue != nil {
-			whereClauses = append(whereClauses, fmt.Sprintf("\"%s\" = $%d", conflictTarget, argIdx))
-			whereArgs = append(whereArgs, conflictColValue)
-			argIdx++
+		whereClauses := make([]string, 0, len(conflictCols))
+		whereArgs := make([]interface{}, 0, len(conflictCols))
+		for _, col := range conflictCols {
+			val, ok := record[keyOf[col]]
+			if !ok || val == nil {
+				whereClauses = nil
+				break
+			}
+			whereArgs = append(whereArgs, val)
+			whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", quoteIdentifier(col), len(whereArgs)))
 		}
 
 		if len(whereClauses) > 0 {
 			selectQuery := fmt.Sprintf("SELECT %s FROM public.%s WHERE %s",
-				returningClause, table, strings.Join(whereClauses, " AND "))
-
-			selectRows, err := conn.Query(ctx, selectQuery, whereArgs...)
-			if err == nil {
-				defer selectRows.Close()
-				for selectRows.Next() {
-					row, err := selectRows.Values()
-					if err != nil {
-						break
-					}
-					desc := selectRows.FieldDescriptions()
-					result := make(map[string]interface{})
-					for i, col := range desc {
-						result[col.Name] = row[i]
-					}
-					results = append(results, result)
+				returningClause, quotedTable, strings.Join(whereClauses, " AND "))
+
+			selectRows, err := tx.Query(ctx, selectQuery, whereArgs...)
+			if err != nil {
+				writeDBError(w, "select error", err, http.StatusBadRequest)
+				return
+			}
+			defer selectRows.Close()
+			for selectRows.Next() {
+				row, err := selectRows.Values()
+				if err != nil {
+					writeDBError(w, "row scan error", err, http.StatusInternalServerError)
+					return
 				}
+				desc := selectRows.FieldDescriptions()
+				result := make(map[string]interface{})
+				for i, col := range desc {
+					result[col.Name] = row[i]
+				}
+				results = append(results, result)
 			}
 		}
 	}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(results)
+	s.writeMutation(ctx, tx, w, r, table, results)
 }
 
 // handlePATCH processes UPDATE requests
-func (s *Server) handlePATCH(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, table string) {
+func (s *Server) handlePATCH(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, table string) {
 	// Quote table name for SQL
 	quotedTable := quoteIdentifier(table)
 
@@ -1547,43 +2108,43 @@ func (s *Server) handlePATCH(ctx context.Context, conn *pgx.Conn, w http.Respons
 
 	// Parse select columns for returning clause (Supabase supports .select() after update)
 	query := r.URL.Query()
-	var returningClause string
-	if selectVals := query["select"]; len(selectVals) > 0 {
-		columns := strings.Split(selectVals[0], ",")
-		if len(columns) == 1 && columns[0] == "*" {
-			returningClause = "*"
-		} else {
-			quotedCols := make([]string, 0, len(columns))
-			for _, col := range columns {
-				col = strings.TrimSpace(col)
-				if col != "*" {
-					quotedCols = append(quotedCols, quoteIdentifier(col))
-				} else {
-					quotedCols = append(quotedCols, "*")
-				}
-			}
-			returningClause = strings.Join(quotedCols, ", ")
-		}
-	} else {
-		returningClause = "*"
+	names := s.config.REST.IdentifierCase
+	returningClause, err := buildReturningClause(query, names)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+		return
 	}
 
 	// Build UPDATE query
 	var sets []string
 	args := []interface{}{}
 	i := 1
-	for col, val := range data {
+	for key, val := range data {
+		col := names.name(key)
+		if err := validateIdentifier(col); err != nil {
+			http.Error(w, fmt.Sprintf("invalid column: %v", err), http.StatusBadRequest)
+			return
+		}
 		sets = append(sets, fmt.Sprintf("%s = $%d", quoteIdentifier(col), i))
 		args = append(args, val)
 		i++
 	}
 
 	// Add WHERE clause from query parameters (offset by number of SET parameters)
-	whereClause, whereArgs := s.buildWhereClause(query, len(args))
+	whereClause, whereArgs, err := s.buildWhereClause(query, len(args))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
 	if whereClause == "" {
 		http.Error(w, "missing filter", http.StatusBadRequest)
 		return
 	}
+	whereClause, err = limitedWriteFilter(ctx, tx, table, whereClause, query, names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	args = append(args, whereArgs...)
 
 	sqlQuery := fmt.Sprintf("UPDATE public.%s SET %s WHERE %s RETURNING %s",
@@ -1593,9 +2154,9 @@ func (s *Server) handlePATCH(ctx context.Context, conn *pgx.Conn, w http.Respons
 		returningClause)
 
 	// Execute query
-	rows, err := conn.Query(ctx, sqlQuery, args...)
+	rows, err := tx.Query(ctx, sqlQuery, args...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("update error: %v", err), http.StatusBadRequest)
+		writeDBError(w, "update error", err, http.StatusBadRequest)
 		return
 	}
 	defer rows.Close()
@@ -1605,7 +2166,7 @@ func (s *Server) handlePATCH(ctx context.Context, conn *pgx.Conn, w http.Respons
 	for rows.Next() {
 		row, err := rows.Values()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("row scan error: %v", err), http.StatusInternalServerError)
+			writeDBError(w, "row scan error", err, http.StatusInternalServerError)
 			return
 		}
 
@@ -1618,46 +2179,39 @@ func (s *Server) handlePATCH(ctx context.Context, conn *pgx.Conn, w http.Respons
 		results = append(results, result)
 	}
 
-	// Return JSON response (empty array if no rows matched, not an error)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(results)
+	// Empty array if no rows matched, not an error
+	s.writeMutation(ctx, tx, w, r, table, results)
 }
 
 // handleDELETE processes DELETE requests
-func (s *Server) handleDELETE(ctx context.Context, conn *pgx.Conn, w http.ResponseWriter, r *http.Request, table string) {
+func (s *Server) handleDELETE(ctx context.Context, tx pgx.Tx, w http.ResponseWriter, r *http.Request, table string) {
 	// Quote table name for SQL
 	quotedTable := quoteIdentifier(table)
 
 	// Parse select columns for returning clause (Supabase supports .select() after delete)
 	query := r.URL.Query()
-	var returningClause string
-	if selectVals := query["select"]; len(selectVals) > 0 {
-		columns := strings.Split(selectVals[0], ",")
-		if len(columns) == 1 && columns[0] == "*" {
-			returningClause = "*"
-		} else {
-			quotedCols := make([]string, 0, len(columns))
-			for _, col := range columns {
-				col = strings.TrimSpace(col)
-				if col != "*" {
-					quotedCols = append(quotedCols, quoteIdentifier(col))
-				} else {
-					quotedCols = append(quotedCols, "*")
-				}
-			}
-			returningClause = strings.Join(quotedCols, ", ")
-		}
-	} else {
-		returningClause = "*"
+	names := s.config.REST.IdentifierCase
+	returningClause, err := buildReturningClause(query, names)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid select: %v", err), http.StatusBadRequest)
+		return
 	}
 
 	// Add WHERE clause from query parameters
-	whereClause, whereArgs := s.buildWhereClause(query, 0)
+	whereClause, whereArgs, err := s.buildWhereClause(query, 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
 	if whereClause == "" {
 		http.Error(w, "missing filter", http.StatusBadRequest)
 		return
 	}
+	whereClause, err = limitedWriteFilter(ctx, tx, table, whereClause, query, names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Build DELETE query
 	sqlQuery := fmt.Sprintf("DELETE FROM public.%s WHERE %s RETURNING %s",
@@ -1666,9 +2220,9 @@ func (s *Server) handleDELETE(ctx context.Context, conn *pgx.Conn, w http.Respon
 		returningClause)
 
 	// Execute query
-	rows, err := conn.Query(ctx, sqlQuery, whereArgs...)
+	rows, err := tx.Query(ctx, sqlQuery, whereArgs...)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("delete error: %v", err), http.StatusBadRequest)
+		writeDBError(w, "delete error", err, http.StatusBadRequest)
 		return
 	}
 	defer rows.Close()
@@ -1678,7 +2232,7 @@ func (s *Server) handleDELETE(ctx context.Context, conn *pgx.Conn, w http.Respon
 	for rows.Next() {
 		row, err := rows.Values()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("row scan error: %v", err), http.StatusInternalServerError)
+			writeDBError(w, "row scan error", err, http.StatusInternalServerError)
 			return
 		}
 
@@ -1690,14 +2244,20 @@ func (s *Server) handleDELETE(ctx context.Context, conn *pgx.Conn, w http.Respon
 		results = append(results, result)
 	}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(results)
+	s.writeMutation(ctx, tx, w, r, table, results)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	// Report unavailable while GoTrue is starting or restarting, so
+	// clients waiting on /health don't race the auth API
+	if s.authStarted && !s.authProvider.IsRunning() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"status":"starting","auth":"not ready"}`)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, `{"status":"healthy"}`)
 }
@@ -1747,6 +2307,109 @@ func (s *Server) initSchema(ctx context.Context) error {
 
 		-- Enable Row Level Security (mail capture server connects as superuser, bypasses RLS)
 		ALTER TABLE public.captured_emails ENABLE ROW LEVEL SECURITY;
+
+		-- Captured SMS table for development/testing
+		CREATE TABLE IF NOT EXISTS public.captured_sms (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			to_phone TEXT NOT NULL,
+			otp TEXT,
+			user_id UUID,
+			payload JSONB
+		);
+
+		CREATE INDEX IF NOT EXISTS captured_sms_created_at_idx
+			ON public.captured_sms(created_at DESC);
+
+		CREATE INDEX IF NOT EXISTS captured_sms_to_phone_idx
+			ON public.captured_sms(to_phone);
+
+		ALTER TABLE public.captured_sms ENABLE ROW LEVEL SECURITY;
+
+		-- Send SMS hook GoTrue calls in SMS capture mode. It lives in the
+		-- admin schema so clients can't call it through /rest/v1/rpc. A
+		-- pending phone change is sent to the new number.
+		CREATE OR REPLACE FUNCTION admin.capture_sms(event jsonb)
+		RETURNS jsonb LANGUAGE plpgsql AS $fn$
+		BEGIN
+			INSERT INTO public.captured_sms (to_phone, otp, user_id, payload)
+			VALUES (
+				COALESCE(NULLIF(event->'user'->>'phone_change', ''), event->'user'->>'phone'),
+				event->'sms'->>'otp',
+				NULLIF(event->'user'->>'id', '')::uuid,
+				event
+			);
+			RETURN '{}'::jsonb;
+		END
+		$fn$;
+
+		-- created_at/updated_at convention. set_updated_at works like
+		-- Supabase's moddatetime trigger: it sets the column named by its
+		-- argument to now() on every UPDATE. add_timestamps adds both
+		-- columns and the trigger to a table:
+		--   SELECT extensions.add_timestamps('todos');
+		CREATE SCHEMA IF NOT EXISTS extensions;
+
+		CREATE OR REPLACE FUNCTION extensions.set_updated_at()
+		RETURNS trigger LANGUAGE plpgsql AS $fn$
+		BEGIN
+			NEW := jsonb_populate_record(NEW, jsonb_build_object(TG_ARGV[0], now()));
+			RETURN NEW;
+		END
+		$fn$;
+
+		CREATE OR REPLACE FUNCTION extensions.add_timestamps(target regclass)
+		RETURNS void LANGUAGE plpgsql AS $fn$
+		BEGIN
+			EXECUTE format('ALTER TABLE %s
+				ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+				ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now()', target);
+			EXECUTE format('CREATE OR REPLACE TRIGGER handle_updated_at
+				BEFORE UPDATE ON %s
+				FOR EACH ROW EXECUTE FUNCTION extensions.set_updated_at(%L)', target, 'updated_at');
+		END
+		$fn$;
+
+		-- Schema changes are for the database owner, not API roles
+		REVOKE ALL ON FUNCTION extensions.add_timestamps(regclass) FROM PUBLIC;
+
+		-- Supabase API roles. REST requests run as one of these, so RLS
+		-- policies decide what they see. Privileges are granted only when
+		-- the roles are first created, so later REVOKEs are kept.
+		DO $$
+		DECLARE
+			created boolean := false;
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'anon') THEN
+				CREATE ROLE anon NOLOGIN NOINHERIT;
+				created := true;
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'authenticated') THEN
+				CREATE ROLE authenticated NOLOGIN NOINHERIT;
+				created := true;
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'service_role') THEN
+				CREATE ROLE service_role NOLOGIN NOINHERIT BYPASSRLS;
+				created := true;
+			END IF;
+
+			IF created THEN
+				GRANT USAGE ON SCHEMA public, auth TO anon, authenticated, service_role;
+				GRANT ALL ON ALL TABLES IN SCHEMA public TO anon, authenticated, service_role;
+				GRANT ALL ON ALL SEQUENCES IN SCHEMA public TO anon, authenticated, service_role;
+				GRANT ALL ON ALL ROUTINES IN SCHEMA public TO anon, authenticated, service_role;
+				ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON TABLES TO anon, authenticated, service_role;
+				ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON SEQUENCES TO anon, authenticated, service_role;
+				ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON ROUTINES TO anon, authenticated, service_role;
+			END IF;
+		END
+		$$;
+
+		-- service_role reads auth tables through /rest/v1 (Accept-Profile: auth),
+		-- including the ones GoTrue creates later as this role. Reapplied on
+		-- every start so existing data directories get it too.
+		GRANT SELECT ON ALL TABLES IN SCHEMA auth TO service_role;
+		ALTER DEFAULT PRIVILEGES IN SCHEMA auth GRANT SELECT ON TABLES TO service_role;
 	`)
 	return err
 }
@@ -1757,7 +2420,7 @@ func (s *Server) waitForShutdown(ctx context.Context) error {
 
 	select {
 	case sig := <-sigCh:
-		log.Info("received signal, shutting down...", "signal", sig)
+		logger.Info("received signal, shutting down...", "signal", sig)
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -1768,29 +2431,108 @@ func (s *Server) waitForShutdown(ctx context.Context) error {
 
 	if s.httpServer != nil {
 		s.httpServer.Shutdown(shutdownCtx)
+		s.componentStopped(ComponentHTTP)
 	}
 
-	if s.authServer != nil {
-		_ = s.authServer.Stop()
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(shutdownCtx)
+	}
+
+	if s.realtimeServer != nil {
+		s.realtimeServer.Stop()
+		s.componentStopped(ComponentRealtime)
+	}
+
+	if s.schemaCache != nil {
+		s.schemaCache.Stop()
+		s.componentStopped(ComponentSchemaCache)
+	}
+
+	if s.netWorker != nil {
+		s.netWorker.Stop()
+		s.componentStopped(ComponentPgNet)
+	}
+
+	if s.historyWorker != nil {
+		s.historyWorker.Stop()
+		s.componentStopped(ComponentHistory)
+	}
+
+	// After the HTTP server, so no requests are still recording
+	if s.auditLog != nil {
+		s.auditLog.Stop()
+		s.componentStopped(ComponentAuditLog)
+	}
+	if s.usageMeter != nil {
+		s.usageMeter.Stop()
+		s.componentStopped(ComponentUsage)
+	}
+
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+
+	if s.statusMonitor != nil {
+		s.statusMonitor.Stop()
+	}
+
+	if s.authProvider != nil {
+		_ = s.authProvider.Stop()
+		s.componentStopped(ComponentAuth)
 	}
 
 	// Stop mail capture server after GoTrue (reverse of startup order)
 	if s.captureServer != nil {
 		_ = s.captureServer.Stop()
+		s.componentStopped(ComponentMailCapture)
 	}
 
 	if s.prestServer != nil {
 		s.prestServer.Stop()
+		s.componentStopped(ComponentPREST)
 	}
 
 	if s.pgDatabase != nil {
 		s.pgDatabase.Stop()
+		s.componentStopped(ComponentPostgres)
+	}
+
+	// Deliver alerts still in flight
+	s.config.Notifier.Wait()
+
+	// Export the spans of the last requests
+	if s.stopTracing != nil {
+		if err := s.stopTracing(shutdownCtx); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
 	}
 
-	log.Info("Supalite stopped")
+	logger.Info("Supalite stopped")
 	return nil
 }
 
+// captureStore returns the mail capture sink selected by the email config.
+func (s *Server) captureStore() (mailcapture.Store, error) {
+	email := s.config.Email
+	switch email.CaptureSink {
+	case "", "database":
+		return mailcapture.NewPostgresStore(s.pgDatabase.Pool()), nil
+	case "maildir":
+		dir := email.CaptureDir
+		if dir == "" {
+			dir = filepath.Join(s.config.DataDir, "mail")
+		}
+		return mailcapture.NewMaildirStore(dir)
+	case "webhook":
+		if email.CaptureWebhookURL == "" {
+			return nil, fmt.Errorf("the webhook capture sink needs a webhook URL")
+		}
+		return mailcapture.NewWebhookStore(email.CaptureWebhookURL, email.CaptureWebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown capture sink %q (use database, maildir, or webhook)", email.CaptureSink)
+	}
+}
+
 // generateRandomSecret generates a random secret string of specified length
 func generateRandomSecret(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ResponseConfig sets the default shape of REST and RPC response bodies.
+// Clients can override either option per request with a Prefer header:
+// nulls=stripped or nulls=keep, and keys=camelCase or keys=original.
+type ResponseConfig struct {
+	OmitNulls     bool // Drop object fields whose value is null
+	CamelCaseKeys bool // Rewrite snake_case object keys as camelCase
+}
+
+// responseShape resolves the shaping options for a request. When request
+// names are mapped to snake_case, keys are camelCase by default to match.
+func (s *Server) responseShape(r *http.Request) ResponseConfig {
+	shape := s.config.Response
+	if s.config.REST.IdentifierCase == IdentifierCaseSnake {
+		shape.CamelCaseKeys = true
+	}
+	prefs := preferences(r)
+	switch prefs["nulls"] {
+	case "stripped":
+		shape.OmitNulls = true
+	case "keep":
+		shape.OmitNulls = false
+	}
+	switch prefs["keys"] {
+	case "camelCase":
+		shape.CamelCaseKeys = true
+	case "original":
+		shape.CamelCaseKeys = false
+	}
+	return shape
+}
+
+// preferences parses the Prefer header into its key=value preferences.
+func preferences(r *http.Request) map[string]string {
+	prefs := make(map[string]string)
+	for _, value := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(value, ",") {
+			key, val, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			if key != "" {
+				prefs[key] = strings.TrimSpace(val)
+			}
+		}
+	}
+	return prefs
+}
+
+// singularMediaType is the Accept type of supabase-js .single() and
+// .maybeSingle(), which expect one object rather than an array.
+const singularMediaType = "application/vnd.pgrst.object+json"
+
+// wantsSingleObject reports whether the request asked for a single object.
+func wantsSingleObject(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.TrimSpace(mediaType) == singularMediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeRows writes the rows of a REST response. A request for a single
+// object gets the row itself, or 406 with PostgREST's PGRST116 error unless
+// exactly one row matched; the error's details name the row count, which
+// .maybeSingle() reads to turn zero rows into null.
+func (s *Server) writeRows(w http.ResponseWriter, r *http.Request, status int, rows []map[string]interface{}) {
+	if !wantsSingleObject(r) {
+		s.writeJSON(w, r, status, rows)
+		return
+	}
+	if len(rows) != 1 {
+		w.Header().Del("Content-Range")
+		w.Header().Del("Location")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotAcceptable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":    "PGRST116",
+			"message": "JSON object requested, multiple (or no) rows returned",
+			"details": fmt.Sprintf("The result contains %d rows", len(rows)),
+			"hint":    nil,
+		})
+		return
+	}
+	s.writeBody(w, r, status, singularMediaType+"; charset=utf-8", rows[0])
+}
+
+// writeJSON shapes a response body for the request and writes it.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	s.writeBody(w, r, status, "application/json", v)
+}
+
+// writeBody shapes a response body and writes it with the given
+// Content-Type.
+func (s *Server) writeBody(w http.ResponseWriter, r *http.Request, status int, contentType string, v interface{}) {
+	shape := s.responseShape(r)
+	if shape.OmitNulls || shape.CamelCaseKeys {
+		v = shapeValue(v, shape)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// shapeValue applies the shaping options to every object in v, including
+// embedded resources and the contents of JSON columns.
+func shapeValue(v interface{}, shape ResponseConfig) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return shapeObject(v, shape)
+	case []map[string]interface{}:
+		out := make([]map[string]interface{}, len(v))
+		for i, obj := range v {
+			out[i] = shapeObject(obj, shape)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = shapeValue(elem, shape)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func shapeObject(obj map[string]interface{}, shape ResponseConfig) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for key, val := range obj {
+		if val == nil && shape.OmitNulls {
+			continue
+		}
+		name := key
+		if shape.CamelCaseKeys {
+			name = camelCase(key)
+			// A column already named like the converted key keeps its value
+			if _, taken := obj[name]; taken && name != key {
+				name = key
+			}
+		}
+		out[name] = shapeValue(val, shape)
+	}
+	return out
+}
+
+// camelCase converts a snake_case name to camelCase: created_at becomes
+// createdAt. Leading underscores are kept, so _id stays _id.
+func camelCase(name string) string {
+	trimmed := strings.TrimLeft(name, "_")
+	if !strings.Contains(trimmed, "_") {
+		return name
+	}
+
+	var b strings.Builder
+	b.WriteString(name[:len(name)-len(trimmed)])
+	for i, part := range strings.Split(trimmed, "_") {
+		if i == 0 || part == "" {
+			b.WriteString(part)
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(part)
+		b.WriteRune(unicode.ToUpper(first))
+		b.WriteString(part[size:])
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"id":              "id",
+		"created_at":      "createdAt",
+		"user_id":         "userId",
+		"_id":             "_id",
+		"__internal_flag": "__internalFlag",
+		"a__b":            "aB",
+		"trailing_":       "trailing",
+		"straße_name":     "straßeName",
+		"already_camelOK": "alreadyCamelOK",
+	}
+	for in, want := range tests {
+		if got := camelCase(in); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteJSON_Shaping(t *testing.T) {
+	results := []map[string]interface{}{
+		{
+			"user_id": 1,
+			"bio":     nil,
+			"profile": map[string]interface{}{"avatar_url": nil, "display_name": "Ada"},
+			"posts":   []map[string]interface{}{{"post_id": 7, "body": nil}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		config ResponseConfig
+		prefer string
+		want   string
+	}{
+		{"default", ResponseConfig{}, "", `[{"bio":null,"posts":[{"body":null,"post_id":7}],"profile":{"avatar_url":null,"display_name":"Ada"},"user_id":1}]`},
+		{"prefer both", ResponseConfig{}, "nulls=stripped, keys=camelCase", `[{"posts":[{"postId":7}],"profile":{"displayName":"Ada"},"userId":1}]`},
+		{"config nulls", ResponseConfig{OmitNulls: true}, "", `[{"posts":[{"post_id":7}],"profile":{"display_name":"Ada"},"user_id":1}]`},
+		{"prefer overrides config", ResponseConfig{OmitNulls: true, CamelCaseKeys: true}, "nulls=keep,keys=original", `[{"bio":null,"posts":[{"body":null,"post_id":7}],"profile":{"avatar_url":null,"display_name":"Ada"},"user_id":1}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{config: Config{Response: tt.config}}
+			r := httptest.NewRequest(http.MethodGet, "/rest/v1/profiles", nil)
+			if tt.prefer != "" {
+				r.Header.Set("Prefer", tt.prefer)
+			}
+			rec := httptest.NewRecorder()
+			s.writeJSON(rec, r, http.StatusOK, results)
+
+			var got, want interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %s: %v", rec.Body.String(), err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("body = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestResponseShape_SnakeCaseNames(t *testing.T) {
+	s := &Server{config: Config{REST: RESTConfig{IdentifierCase: IdentifierCaseSnake}}}
+	r := httptest.NewRequest(http.MethodGet, "/rest/v1/profiles", nil)
+	if !s.responseShape(r).CamelCaseKeys {
+		t.Error("snake_case names should default to camelCase keys")
+	}
+	r.Header.Set("Prefer", "keys=original")
+	if s.responseShape(r).CamelCaseKeys {
+		t.Error("Prefer: keys=original should keep the column names")
+	}
+}
+
+func TestShapeObject_KeyCollision(t *testing.T) {
+	got := shapeObject(map[string]interface{}{"user_id": 1, "userId": 2}, ResponseConfig{CamelCaseKeys: true})
+	if got["userId"] != 2 || got["user_id"] != 1 {
+		t.Errorf("shapeObject() = %v, want both columns kept", got)
+	}
+}
+
+func TestWriteRows_SingleObject(t *testing.T) {
+	s := &Server{}
+	one := []map[string]interface{}{{"id": 1}}
+	two := []map[string]interface{}{{"id": 1}, {"id": 2}}
+
+	tests := []struct {
+		name       string
+		accept     string
+		rows       []map[string]interface{}
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"array by default", "", one, http.StatusOK, "application/json", `[{"id":1}]`},
+		{"single row", "application/vnd.pgrst.object+json", one, http.StatusOK, "application/vnd.pgrst.object+json; charset=utf-8", `{"id":1}`},
+		{"with parameters", "application/json, application/vnd.pgrst.object+json;nulls=stripped", one, http.StatusOK, "application/vnd.pgrst.object+json; charset=utf-8", `{"id":1}`},
+		{"no rows", "application/vnd.pgrst.object+json", nil, http.StatusNotAcceptable, "application/json", `"The result contains 0 rows"`},
+		{"many rows", "application/vnd.pgrst.object+json", two, http.StatusNotAcceptable, "application/json", `"The result contains 2 rows"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/v1/todos", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			w.Header().Set("Content-Range", "0-1/*")
+			s.writeRows(w, r, http.StatusOK, tt.rows)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusNotAcceptable && w.Header().Get("Content-Range") != "" {
+				t.Error("Content-Range should be dropped from an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/markb/supalite/internal/status"
+)
+
+// startStatus starts probing the APIs for the public status page.
+func (s *Server) startStatus(ctx context.Context) {
+	cfg := *s.config.Status
+	cfg.Checks = s.statusChecks()
+	s.statusMonitor = status.NewMonitor(cfg)
+	s.statusMonitor.Start(ctx)
+}
+
+// statusChecks returns a probe for each public API. They check the
+// dependencies each API needs rather than calling it, so the checks
+// don't show up in logs or count against rate limits.
+func (s *Server) statusChecks() []status.Check {
+	pingDatabase := func(ctx context.Context) error {
+		return s.pgDatabase.Pool().Ping(ctx)
+	}
+
+	return []status.Check{
+		{Name: "rest", Probe: pingDatabase},
+		{Name: "auth", Probe: func(ctx context.Context) error {
+			if s.authProvider == nil || !s.authProvider.IsRunning() {
+				return errors.New("auth server is not running")
+			}
+			return pingDatabase(ctx)
+		}},
+		{Name: "realtime", Probe: func(ctx context.Context) error {
+			if !s.realtimeServer.Listening() {
+				return errors.New("not listening for row changes")
+			}
+			return nil
+		}},
+		{Name: "storage", Probe: func(ctx context.Context) error {
+			if _, err := os.Stat(filepath.Join(s.config.DataDir, "storage")); err != nil {
+				return err
+			}
+			return pingDatabase(ctx)
+		}},
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Server-wide deadlines for ordinary requests. Routes that serve long-lived
+// connections override them with withDeadlines.
+const (
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 30 * time.Second
+	defaultIdleTimeout  = 120 * time.Second
+)
+
+// isStreamingRequest reports whether a request opens a long-lived
+// connection: a protocol upgrade such as a WebSocket, or a Server-Sent
+// Events stream.
+func isStreamingRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" && headerHasToken(r.Header, "Connection", "upgrade") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// headerHasToken reports whether a comma-separated header contains token,
+// case-insensitively.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withDeadlines overrides the http.Server ReadTimeout and WriteTimeout for
+// the requests of a route that match, or for all of them if match is nil.
+// A zero duration removes the deadline, which is what streaming routes need:
+// WebSockets and event streams manage their own liveness.
+func withDeadlines(read, write time.Duration, match func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match == nil || match(r) {
+				rc := http.NewResponseController(w)
+				if err := rc.SetReadDeadline(deadline(read)); err != nil {
+					logger.Debug("failed to set read deadline", "path", r.URL.Path, "error", err)
+				}
+				if err := rc.SetWriteDeadline(deadline(write)); err != nil {
+					logger.Debug("failed to set write deadline", "path", r.URL.Path, "error", err)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// deadline converts a timeout to an absolute deadline; zero means none.
+func deadline(timeout time.Duration) time.Time {
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
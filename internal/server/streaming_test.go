@@ -0,0 +1,64 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsStreamingRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"plain", map[string]string{"Accept": "application/json"}, false},
+		{"websocket", map[string]string{"Upgrade": "websocket", "Connection": "keep-alive, Upgrade"}, true},
+		{"upgrade without connection token", map[string]string{"Upgrade": "websocket"}, false},
+		{"event stream", map[string]string{"Accept": "text/event-stream"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := isStreamingRequest(r); got != tt.want {
+				t.Errorf("isStreamingRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDeadlines(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	srv := httptest.NewUnstartedServer(withDeadlines(0, 0, isStreamingRequest)(slow))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	get := func(accept string) (string, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := get("text/event-stream"); err != nil || body != "done" {
+		t.Errorf("streaming request = %q, %v; want the response despite the write timeout", body, err)
+	}
+	if body, err := get("application/json"); err == nil && body == "done" {
+		t.Error("ordinary request outlived the server write timeout")
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markb/supalite/internal/rls"
+)
+
+// asOfParam reads a table as it was at a point in time:
+// ?as_of=2024-05-01T10:00:00Z.
+const asOfParam = "as_of"
+
+// asOfSource returns what a read with ?as_of selects from in place of the
+// table: its rows at that time, rebuilt from the change history by
+// audit.rows_as_of and typed as the table's rows. It is named after the
+// table, so columns resolve as they would against it. Time travel is a
+// development aid: it's off unless rest.TimeTravel is set, and since past
+// rows skip RLS, it takes the service_role key. Failures come with the
+// status to answer.
+func (s *Server) asOfSource(r *http.Request, schema, table, value string) (string, int, error) {
+	if !s.config.REST.TimeTravel {
+		return "", http.StatusBadRequest, errors.New("as_of needs time travel, which is off (history.time_travel)")
+	}
+	if rls.RoleForClaims(requestClaims(r)) != rls.RoleServiceRole {
+		return "", http.StatusForbidden, errors.New("as_of requires the service_role key")
+	}
+	asOf, err := parseAsOf(value)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+
+	quotedTable := qualifiedTable(schema, table)
+	return fmt.Sprintf("(SELECT r.* FROM audit.rows_as_of(%s::regclass, %s::timestamptz) AS h(record), jsonb_populate_record(NULL::%s, h.record) AS r) AS %s",
+		quoteLiteral(quotedTable), quoteLiteral(asOf.Format(time.RFC3339Nano)), quotedTable, quoteIdentifier(table)), 0, nil
+}
+
+// parseAsOf parses an as_of timestamp in RFC 3339 form. An unescaped + in
+// a query string arrives as a space, so a space before the offset is read
+// as one.
+func parseAsOf(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, strings.Replace(value, " ", "+", 1))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid as_of %q: use an RFC 3339 timestamp such as 2024-05-01T10:00:00Z", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAsOf(t *testing.T) {
+	want := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for _, value := range []string{"2024-05-01T08:00:00Z", "2024-05-01T10:00:00+02:00", "2024-05-01T10:00:00 02:00", "2024-05-01T08:00:00.000Z"} {
+		if got, err := parseAsOf(value); err != nil || !got.Equal(want) {
+			t.Errorf("parseAsOf(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "yesterday", "2024-05-01", "1714550400"} {
+		if _, err := parseAsOf(value); err == nil {
+			t.Errorf("parseAsOf(%q): expected error", value)
+		}
+	}
+}
+
+func TestAsOfSource(t *testing.T) {
+	s := &Server{config: Config{REST: RESTConfig{TimeTravel: true}}}
+
+	source, _, err := s.asOfSource(profileRequest(http.MethodGet, "", "", "service_role"), "public", "todos", "2024-05-01T08:00:00Z")
+	if err != nil {
+		t.Fatalf("asOfSource() failed: %v", err)
+	}
+	want := `(SELECT r.* FROM audit.rows_as_of('"public"."todos"'::regclass, '2024-05-01T08:00:00Z'::timestamptz) AS h(record), jsonb_populate_record(NULL::"public"."todos", h.record) AS r) AS "todos"`
+	if source != want {
+		t.Errorf("asOfSource() = %s, want %s", source, want)
+	}
+
+	tests := []struct {
+		server *Server
+		role   string
+		value  string
+		status int
+	}{
+		{&Server{}, "service_role", "2024-05-01T08:00:00Z", http.StatusBadRequest},
+		{s, "authenticated", "2024-05-01T08:00:00Z", http.StatusForbidden},
+		{s, "anon", "2024-05-01T08:00:00Z", http.StatusForbidden},
+		{s, "service_role", "last week", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		_, status, err := tt.server.asOfSource(profileRequest(http.MethodGet, "", "", tt.role), "public", "todos", tt.value)
+		if err == nil || status != tt.status {
+			t.Errorf("asOfSource(%s, %q) = %d, %v, want %d", tt.role, tt.value, status, err, tt.status)
+		}
+	}
+	if _, _, err := (&Server{}).asOfSource(profileRequest(http.MethodGet, "", "", "service_role"), "public", "todos", ""); !strings.Contains(err.Error(), "time_travel") {
+		t.Errorf("asOfSource() with time travel off = %v", err)
+	}
+}
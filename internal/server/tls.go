@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/markb/supalite/internal/certs"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLSConfig serves the API over HTTPS. Exactly one certificate source
+// is set: CertFile and KeyFile, SelfSigned, or ACMEDomains.
+type TLSConfig struct {
+	CertFile string // PEM certificate chain, reloaded when it changes
+	KeyFile  string // PEM private key
+
+	// Generate a self-signed certificate into DataDir/tls for local
+	// development, valid for localhost and SelfSignedHosts
+	SelfSigned      bool
+	SelfSignedHosts []string
+
+	// Get certificates from Let's Encrypt (or ACMEDirectoryURL) for these
+	// domains, cached in DataDir/tls/acme. The CA must reach this server
+	// on port 443, or on port 80 through HTTPPort.
+	ACMEDomains      []string
+	ACMEEmail        string // Optional: contact for expiry notices
+	ACMEDirectoryURL string // Optional: defaults to Let's Encrypt
+
+	// Optional: also listen for plain HTTP on this port, answering ACME
+	// challenges and redirecting everything else to HTTPS
+	HTTPPort int
+}
+
+// initTLS prepares the certificate source, so a missing or invalid
+// certificate fails startup before anything is started.
+func (s *Server) initTLS() error {
+	t := s.config.TLS
+	if t == nil {
+		return nil
+	}
+	tlsDir := filepath.Join(s.config.DataDir, "tls")
+
+	switch {
+	case len(t.ACMEDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.ACMEDomains...),
+			Cache:      autocert.DirCache(filepath.Join(tlsDir, "acme")),
+			Email:      t.ACMEEmail,
+		}
+		if t.ACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: t.ACMEDirectoryURL}
+		}
+		s.acme = manager
+		s.tlsConfig = manager.TLSConfig()
+		logger.Info("TLS certificates from ACME", "domains", t.ACMEDomains)
+
+	case t.SelfSigned:
+		certFile, keyFile, err := certs.SelfSigned(tlsDir, t.SelfSignedHosts)
+		if err != nil {
+			return err
+		}
+		if err := s.useCertFiles(certFile, keyFile); err != nil {
+			return err
+		}
+		logger.Info("TLS with a self-signed certificate; trust it in your browser or pass it to clients", "path", certFile)
+
+	case t.CertFile != "" && t.KeyFile != "":
+		if err := s.useCertFiles(t.CertFile, t.KeyFile); err != nil {
+			return err
+		}
+		logger.Info("TLS certificate loaded", "path", t.CertFile)
+
+	default:
+		return errors.New("no certificate: set cert and key files, self-signed, or ACME domains")
+	}
+
+	s.tlsConfig.MinVersion = tls.VersionTLS12
+	return nil
+}
+
+// useCertFiles serves the certificate in the given files.
+func (s *Server) useCertFiles(certFile, keyFile string) error {
+	reloader, err := certs.NewReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	s.tlsConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+	return nil
+}
+
+// startHTTPListener serves plain HTTP on TLSConfig.HTTPPort: ACME HTTP-01
+// challenges, when certificates come from ACME, and a redirect to HTTPS
+// for everything else.
+func (s *Server) startHTTPListener() {
+	port := s.config.TLS.HTTPPort
+	var handler http.Handler = http.HandlerFunc(s.redirectToHTTPS)
+	if s.acme != nil {
+		handler = s.acme.HTTPHandler(handler)
+	}
+
+	s.redirectServer = &http.Server{
+		Addr:              net.JoinHostPort(s.config.Host, strconv.Itoa(port)),
+		Handler:           handler,
+		ReadHeaderTimeout: defaultReadTimeout,
+	}
+	go func() {
+		logger.Info("redirecting HTTP to HTTPS", "addr", s.redirectServer.Addr)
+		if err := s.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP listener failed", "addr", s.redirectServer.Addr, "error", err)
+		}
+	}()
+}
+
+// redirectToHTTPS sends a request to the same URL over HTTPS on the
+// server's port.
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	if s.config.Port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(s.config.Port))
+	}
+	http.Redirect(w, r, fmt.Sprintf("https://%s%s", host, r.URL.RequestURI()), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitTLS_SelfSigned(t *testing.T) {
+	dataDir := t.TempDir()
+	s := &Server{config: Config{DataDir: dataDir, TLS: &TLSConfig{SelfSigned: true, SelfSignedHosts: []string{"192.168.1.20"}}}}
+	if err := s.initTLS(); err != nil {
+		t.Fatalf("initTLS() failed: %v", err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	// Clients that trust the generated certificate connect without errors
+	certPEM, err := os.ReadFile(filepath.Join(dataDir, "tls", "selfsigned.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	cert, _ := s.tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	if err := cert.Leaf.VerifyHostname("192.168.1.20"); err != nil {
+		t.Errorf("certificate should cover SelfSignedHosts: %v", err)
+	}
+}
+
+func TestInitTLS_MissingFiles(t *testing.T) {
+	s := &Server{config: Config{DataDir: t.TempDir(), TLS: &TLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}}}
+	if err := s.initTLS(); err == nil {
+		t.Error("expected an error for missing certificate files")
+	}
+}
+
+func TestInitTLS_ACME(t *testing.T) {
+	s := &Server{config: Config{DataDir: t.TempDir(), TLS: &TLSConfig{ACMEDomains: []string{"api.example.com"}}}}
+	if err := s.initTLS(); err != nil {
+		t.Fatalf("initTLS() failed: %v", err)
+	}
+	if s.acme == nil || s.tlsConfig.GetCertificate == nil {
+		t.Fatal("expected an ACME certificate manager")
+	}
+	// Only the configured domains get certificates
+	if _, err := s.tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err == nil {
+		t.Error("expected certificates to be refused for other domains")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port int
+		host string
+		want string
+	}{
+		{8443, "localhost:8080", "https://localhost:8443/rest/v1/todos?id=eq.1"},
+		{443, "api.example.com", "https://api.example.com/rest/v1/todos?id=eq.1"},
+	}
+	for _, tt := range tests {
+		s := &Server{config: Config{Port: tt.port}}
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/rest/v1/todos?id=eq.1", nil)
+		rec := httptest.NewRecorder()
+		s.redirectToHTTPS(rec, req)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+			t.Errorf("redirect = %d %q, want 308 %q", rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// maxReadAttempts bounds how often a GET or HEAD runs when it keeps
+	// failing transiently
+	maxReadAttempts = 3
+
+	// readRetryDelay is the pause before the second attempt, growing
+	// linearly with each attempt after it
+	readRetryDelay = 50 * time.Millisecond
+
+	// retryAfterSeconds is the Retry-After hint sent with transient failures
+	retryAfterSeconds = 1
+)
+
+// isTransient reports whether err is a failure that goes away on its own,
+// so running the same statement again is expected to succeed: conflicts
+// between concurrent transactions, and connections dropped or refused
+// while the server restarts or checkpoints.
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // connection_exception
+	}
+
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// writeDBError reports a failed database call as "<what>: <err>" with the
+// given status. Transient failures answer 503 Service Unavailable with a
+// Retry-After hint instead, and mark a REST request's response so reads
+// can be retried. v2 requests get a PostgREST-style error instead (see
+// writeDBErrorV2).
+func writeDBError(w http.ResponseWriter, what string, err error, status int) {
+	if isTransient(err) {
+		if tw, ok := w.(*txResponseWriter); ok {
+			tw.transient = true
+		}
+		w.Header().Set("Retry-After", fmt.Sprint(retryAfterSeconds))
+		status = http.StatusServiceUnavailable
+	}
+	if isRESTv2(w) {
+		writeDBErrorV2(w, what, err, status)
+		return
+	}
+	http.Error(w, fmt.Sprintf("%s: %v", what, err), status)
+}
+
+// sleepContext pauses for d, returning false if ctx ends first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
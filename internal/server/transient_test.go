@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"wrapped", fmt.Errorf("query: %w", &pgconn.PgError{Code: "40001"}), true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"undefined table", &pgconn.PgError{Code: "42P01"}, false},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("isTransient() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDBError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	tw := newTxResponseWriter(rec)
+	writeDBError(tw, "query error", &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}, http.StatusBadRequest)
+	if tw.status != http.StatusServiceUnavailable || !tw.transient {
+		t.Errorf("transient error: status = %d, transient = %v, want 503 and true", tw.status, tw.transient)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+
+	rec = httptest.NewRecorder()
+	tw = newTxResponseWriter(rec)
+	writeDBError(tw, "query error", &pgconn.PgError{Code: "23505"}, http.StatusBadRequest)
+	if tw.status != http.StatusBadRequest || tw.transient {
+		t.Errorf("permanent error: status = %d, transient = %v, want 400 and false", tw.status, tw.transient)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After = %q, want none", got)
+	}
+}
+
+func TestTxResponseWriterDiscard(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Profile", "auth")
+	tw := newTxResponseWriter(rec)
+
+	writeDBError(tw, "query error", &pgconn.PgError{Code: "40001"}, http.StatusBadRequest)
+	tw.discard()
+
+	if tw.transient || tw.status != 0 || tw.body.Len() != 0 {
+		t.Errorf("discard() left status %d, transient %v, %d body bytes", tw.status, tw.transient, tw.body.Len())
+	}
+	if rec.Header().Get("Retry-After") != "" || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("discard() kept the failed attempt's headers: %v", rec.Header())
+	}
+	if rec.Header().Get("Content-Profile") != "auth" {
+		t.Errorf("discard() dropped a header set before the attempt: %v", rec.Header())
+	}
+
+	tw.Write([]byte("[]"))
+	tw.send()
+	if rec.Code != http.StatusOK || rec.Body.String() != "[]" {
+		t.Errorf("send() = %d %q, want 200 []", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Values of the Prefer: resolution= preference that turn an insert into an
+// upsert
+const (
+	resolutionMerge  = "merge-duplicates"  // ON CONFLICT DO UPDATE
+	resolutionIgnore = "ignore-duplicates" // ON CONFLICT DO NOTHING
+)
+
+// conflictColumns returns the columns an upsert without on_conflict
+// resolves conflicts on: the primary key, or the first unique constraint of
+// a table without one. It is empty if the table has neither.
+func conflictColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT array_agg(a.attname::text ORDER BY k.ord)
+		FROM pg_index i
+		CROSS JOIN LATERAL unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE i.indrelid = to_regclass($1) AND i.indisunique
+			AND i.indpred IS NULL AND i.indexprs IS NULL
+		GROUP BY i.indexrelid, i.indisprimary
+		ORDER BY i.indisprimary DESC, i.indexrelid
+		LIMIT 1
+	`, "public."+quoteIdentifier(table))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[[]string])
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// parseOnConflict splits the on_conflict parameter into column names,
+// mapped by names.
+func parseOnConflict(onConflict string, names IdentifierCase) ([]string, error) {
+	var columns []string
+	for _, col := range strings.Split(onConflict, ",") {
+		col = names.name(strings.TrimSpace(col))
+		if err := validateIdentifier(col); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// upsertClause builds the ON CONFLICT clause of an upsert. Conflicting
+// rows get the inserted values of every column outside the conflict
+// target, or are left alone when ignoring duplicates or when there is
+// nothing else to set.
+func upsertClause(conflictCols, colNames []string, ignoreDuplicates bool) string {
+	target := make(map[string]bool, len(conflictCols))
+	quotedTarget := make([]string, len(conflictCols))
+	for i, col := range conflictCols {
+		target[col] = true
+		quotedTarget[i] = quoteIdentifier(col)
+	}
+	clause := fmt.Sprintf("ON CONFLICT (%s)", strings.Join(quotedTarget, ", "))
+
+	var sets []string
+	for _, col := range colNames {
+		if !target[col] {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdentifier(col), quoteIdentifier(col)))
+		}
+	}
+	if ignoreDuplicates || len(sets) == 0 {
+		return clause + " DO NOTHING"
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
@@ -0,0 +1,67 @@
+package server
+
+import "testing"
+
+func TestParseOnConflict(t *testing.T) {
+	got, err := parseOnConflict("team_id, user_id", IdentifierCaseExact)
+	if err != nil {
+		t.Fatalf("parseOnConflict() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != "team_id" || got[1] != "user_id" {
+		t.Errorf("parseOnConflict() = %v, want [team_id user_id]", got)
+	}
+
+	if _, err := parseOnConflict("id,", IdentifierCaseExact); err == nil {
+		t.Error("parseOnConflict() should reject an empty column")
+	}
+}
+
+func TestUpsertClause(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict []string
+		columns  []string
+		ignore   bool
+		want     string
+	}{
+		{
+			name:     "merge",
+			conflict: []string{"id"},
+			columns:  []string{"id", "name"},
+			want:     `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			name:     "composite key",
+			conflict: []string{"team_id", "user_id"},
+			columns:  []string{"team_id", "user_id", "role"},
+			want:     `ON CONFLICT ("team_id", "user_id") DO UPDATE SET "role" = EXCLUDED."role"`,
+		},
+		{
+			// A column whose name contains the key's name is still updated
+			name:     "similar names",
+			conflict: []string{"id"},
+			columns:  []string{"id", "user_id"},
+			want:     `ON CONFLICT ("id") DO UPDATE SET "user_id" = EXCLUDED."user_id"`,
+		},
+		{
+			name:     "ignore duplicates",
+			conflict: []string{"id"},
+			columns:  []string{"id", "name"},
+			ignore:   true,
+			want:     `ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			name:     "only key columns",
+			conflict: []string{"team_id", "user_id"},
+			columns:  []string{"team_id", "user_id"},
+			want:     `ON CONFLICT ("team_id", "user_id") DO NOTHING`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := upsertClause(tt.conflict, tt.columns, tt.ignore); got != tt.want {
+				t.Errorf("upsertClause() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/markb/supalite/internal/notify"
+	"github.com/markb/supalite/internal/rls"
+	"github.com/markb/supalite/internal/usage"
+)
+
+// usagePaths are the APIs usage is counted for.
+var usagePaths = []string{"/rest", "/auth/v1", "/storage/v1"}
+
+// meterUsage counts each API request against the API key it carries, and
+// refuses it once a hard quota is reached: with 429, or with 507 for
+// uploads past the storage quota. Keys that don't verify are counted
+// together, so made-up keys can't grow the table.
+func (s *Server) meterUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isMeteredPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, role := "", ""
+		if token := usageToken(r); token != "" {
+			jwt := token
+			if resolved, ok := s.resolveAPIKey(token); ok {
+				jwt = resolved
+			}
+			if claims, err := s.verifyUserToken(jwt); err == nil {
+				key, role = usage.KeyID(token), rls.RoleForClaims(claims)
+			}
+		}
+
+		upload := strings.HasPrefix(r.URL.Path, "/storage/v1/object") && (r.Method == http.MethodPost || r.Method == http.MethodPut)
+		if exceeded := s.usageMeter.Allow(key, upload); exceeded != nil {
+			status := http.StatusTooManyRequests
+			if exceeded.Metric == usage.MetricStorageBytes {
+				status = http.StatusInsufficientStorage
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(exceeded.RetryAfter.Seconds()+0.999)))
+			http.Error(w, exceeded.Error(), status)
+			return
+		}
+
+		rows := &usage.Rows{}
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(usage.WithRows(r.Context(), rows)))
+
+		s.usageMeter.Add(key, role, usage.Counts{
+			Requests:    1,
+			RowsRead:    rows.Read(),
+			RowsWritten: rows.Written(),
+			EgressBytes: sw.bytes,
+		})
+	})
+}
+
+// isMeteredPath reports whether usage is counted for a request path.
+func isMeteredPath(urlPath string) bool {
+	for _, prefix := range usagePaths {
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// usageToken returns the API key a request's usage is counted against:
+// the apikey header or query parameter, which identify the application
+// even when a user's session token is sent too.
+func usageToken(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("apikey")); key != "" {
+		return key
+	}
+	if key := r.URL.Query().Get("apikey"); key != "" {
+		return key
+	}
+	return requestToken(r)
+}
+
+// notifyUsageLimit reports a usage quota being reached.
+func (s *Server) notifyUsageLimit(e usage.Event) {
+	if s.config.Notifier == nil {
+		return
+	}
+	event := notify.Event{
+		Kind:      notify.KindQuotaWarning,
+		Severity:  notify.SeverityWarning,
+		Component: "usage",
+		Message:   fmt.Sprintf("%s soft quota reached: %d of %d", e.Metric, e.Used, e.Limit),
+		Details:   map[string]string{"metric": e.Metric},
+	}
+	if e.Hard {
+		event.Kind, event.Severity = notify.KindQuotaExceeded, notify.SeverityCritical
+		event.Message = fmt.Sprintf("%s quota reached: %d of %d, requests are refused until the period ends", e.Metric, e.Used, e.Limit)
+	}
+	if e.Key != "" {
+		event.Details["key"] = e.Key
+	}
+	s.config.Notifier.Notify(event)
+}
+
+// handleUsage returns the usage of the current period, per API key and
+// for the project, with the configured quotas.
+//
+// GET /admin/v1/usage
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.usageMeter.Report())
+}
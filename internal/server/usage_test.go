@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markb/supalite/internal/keys"
+	"github.com/markb/supalite/internal/usage"
+)
+
+func TestMeterUsage(t *testing.T) {
+	keyManager, err := keys.NewManager(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("keys.NewManager() failed: %v", err)
+	}
+	meter := usage.NewMeter(usage.Config{Hard: usage.Limits{Requests: 2}})
+	s := &Server{keyManager: keyManager, usageMeter: meter}
+
+	handler := s.meterUsage(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("apikey", keyManager.GetPublishableKey())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get("/rest/v1/todos"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := get("/rest/v1/todos")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over quota: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// Other paths aren't counted or limited
+	if rec := get("/health"); rec.Code != http.StatusOK {
+		t.Errorf("/health: status %d", rec.Code)
+	}
+
+	report := meter.Report()
+	if len(report.Keys) != 1 || report.Keys[0].Role != "anon" || report.Keys[0].Key != usage.KeyID(keyManager.GetPublishableKey()) {
+		t.Fatalf("Keys = %+v", report.Keys)
+	}
+	if report.Project.Requests != 2 || report.Project.EgressBytes != 4 {
+		t.Errorf("Project = %+v", report.Project)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"path/filepath"
+
+	"github.com/markb/supalite/internal/pg"
+	"github.com/markb/supalite/internal/watchdog"
+)
+
+// startWatchdog starts sampling the data directory's disk, pg_wal, and the
+// memory of the Postgres and GoTrue process trees. An external PostgreSQL
+// is watched by whoever runs it; only the data directory is sampled then.
+func (s *Server) startWatchdog() {
+	cfg := s.config.Watchdog
+	cfg.DataDir = s.config.DataDir
+
+	if s.config.DatabaseURL == "" {
+		clusterPath := pg.ClusterPath(s.config.DataDir)
+		cfg.WALDir = filepath.Join(clusterPath, "pg_wal")
+
+		// The postmaster's pid is read on each sample, so a restarted
+		// Postgres is still found
+		cfg.Processes = append(cfg.Processes, watchdog.Process{
+			Name: "postgres",
+			PID: func() int {
+				postmaster, err := pg.ReadPostmaster(clusterPath)
+				if err != nil {
+					return 0
+				}
+				return postmaster.PID
+			},
+			LimitBytes: int64(s.config.PGLimits.MemoryMB) << 20,
+		})
+	}
+	if s.authServer != nil {
+		cfg.Processes = append(cfg.Processes, watchdog.Process{
+			Name:       "gotrue",
+			PID:        s.authServer.PID,
+			LimitBytes: int64(s.config.AuthLimits.MemoryMB) << 20,
+		})
+	}
+
+	if s.config.Notifier != nil {
+		cfg.OnAlert = s.notifyWatchdogAlert
+	}
+
+	s.watchdog = watchdog.New(cfg)
+	s.watchdog.Start()
+	logger.Info("watchdog started", "refuse_writes", cfg.RefuseWrites)
+}
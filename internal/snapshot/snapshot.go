@@ -0,0 +1,297 @@
+// Package snapshot bookmarks and restores the state of a supalite data
+// directory: the PostgreSQL cluster, stored objects, and the API keys.
+//
+// Snapshots are plain directory copies kept in <data-dir>/snapshots/<name>.
+// A stopped cluster is copied as is. A running one is copied inside an
+// online backup (pg_backup_start/pg_backup_stop), and the backup label is
+// stored with the copy, so PostgreSQL replays the WAL written during the
+// copy on its first start after a restore and comes up consistent.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/pg"
+)
+
+// metadataFile describes a snapshot inside its directory.
+const metadataFile = "snapshot.json"
+
+// contents are the entries of a data directory a snapshot covers. Cached
+// binaries, captured mail, and other snapshots are left out.
+var contents = []string{"data", "storage", "keys.json"}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrNotFound is returned for a snapshot that doesn't exist.
+var ErrNotFound = errors.New("snapshot not found")
+
+// ErrRunning is returned when restoring into a data directory in use.
+var ErrRunning = errors.New("supalite is running on this data directory; stop it before restoring")
+
+// Snapshot describes a stored snapshot.
+type Snapshot struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Online    bool      `json:"online"` // Taken while the server was running
+	Size      int64     `json:"-"`
+}
+
+// Dir returns the directory snapshots of dataDir are kept in.
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "snapshots")
+}
+
+func validateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_', and '-'", name)
+	}
+	return nil
+}
+
+// Create snapshots dataDir under name. conn must be connected to the
+// running server when there is one, and nil when the cluster is stopped.
+func Create(ctx context.Context, dataDir, name string, conn *pgx.Conn) (*Snapshot, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(pg.ClusterPath(dataDir)); err != nil {
+		return nil, fmt.Errorf("no database in %s: %w", dataDir, err)
+	}
+
+	target := filepath.Join(Dir(dataDir), name)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("snapshot %q already exists", name)
+	}
+	if err := os.MkdirAll(Dir(dataDir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Copy next to the final location and rename, so an interrupted
+	// snapshot never shows up in the list
+	building, err := os.MkdirTemp(Dir(dataDir), "."+name+".building-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(building)
+
+	if conn != nil {
+		err = copyOnline(ctx, dataDir, building, conn)
+	} else {
+		err = copyContents(dataDir, building)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{Name: name, CreatedAt: time.Now().UTC(), Online: conn != nil}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(building, metadataFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+
+	if err := os.Rename(building, target); err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	snap.Size = dirSize(target)
+	return snap, nil
+}
+
+// copyOnline copies a running cluster inside an online backup.
+func copyOnline(ctx context.Context, dataDir, target string, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, "SELECT pg_backup_start($1, true)", "supalite snapshot"); err != nil {
+		return fmt.Errorf("failed to start backup: %w", err)
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			conn.Exec(context.Background(), "SELECT pg_backup_stop(false)")
+		}
+	}()
+
+	if err := copyContents(dataDir, target); err != nil {
+		return err
+	}
+
+	var label, spcmap string
+	if err := conn.QueryRow(ctx, "SELECT labelfile, COALESCE(spcmapfile, '') FROM pg_backup_stop(false)").Scan(&label, &spcmap); err != nil {
+		return fmt.Errorf("failed to stop backup: %w", err)
+	}
+	stopped = true
+
+	cluster := pg.ClusterPath(target)
+	if err := os.WriteFile(filepath.Join(cluster, "backup_label"), []byte(label), 0600); err != nil {
+		return fmt.Errorf("failed to write backup label: %w", err)
+	}
+	if spcmap != "" {
+		if err := os.WriteFile(filepath.Join(cluster, "tablespace_map"), []byte(spcmap), 0600); err != nil {
+			return fmt.Errorf("failed to write tablespace map: %w", err)
+		}
+	}
+
+	// Recovery needs the WAL up to the end of the backup, written while
+	// the files were being copied
+	walDir := filepath.Join(cluster, "pg_wal")
+	if err := os.RemoveAll(walDir); err != nil {
+		return err
+	}
+	if err := pg.CopyDir(filepath.Join(pg.ClusterPath(dataDir), "pg_wal"), walDir); err != nil {
+		return fmt.Errorf("failed to copy WAL: %w", err)
+	}
+	return nil
+}
+
+// copyContents copies the snapshotted entries of src into dst.
+func copyContents(src, dst string) error {
+	for _, entry := range contents {
+		from := filepath.Join(src, entry)
+		info, err := os.Stat(from)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(dst, entry)
+		if info.IsDir() {
+			err = pg.CopyDir(from, to)
+		} else {
+			err = copyFile(from, to, info.Mode().Perm())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+// List returns the snapshots of dataDir, oldest first.
+func List(dataDir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(Dir(dataDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() || !validName.MatchString(entry.Name()) {
+			continue
+		}
+		snap, err := read(dataDir, entry.Name())
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, *snap)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].Name < snapshots[j].Name
+		}
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+func read(dataDir, name string) (*Snapshot, error) {
+	dir := filepath.Join(Dir(dataDir), name)
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot metadata: %w", err)
+	}
+	snap.Name = name
+	snap.Size = dirSize(dir)
+	return &snap, nil
+}
+
+// Restore replaces the database, stored objects, and keys of dataDir with
+// those of the named snapshot. The server must be stopped.
+func Restore(dataDir, name string) (*Snapshot, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	snap, err := read(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
+	if postmaster, err := pg.ReadPostmaster(pg.ClusterPath(dataDir)); err == nil && postmaster.Running() {
+		return nil, ErrRunning
+	}
+
+	// Stage the copy first, so a failure leaves the current state intact
+	staging, err := os.MkdirTemp(dataDir, ".restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := copyContents(filepath.Join(Dir(dataDir), name), staging); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range contents {
+		current := filepath.Join(dataDir, entry)
+		if err := os.RemoveAll(current); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", entry, err)
+		}
+		staged := filepath.Join(staging, entry)
+		if _, err := os.Stat(staged); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(staged, current); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", entry, err)
+		}
+	}
+	return snap, nil
+}
+
+// Delete removes the named snapshot.
+func Delete(dataDir, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if _, err := read(dataDir, name); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(Dir(dataDir), name))
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, perm)
+}
@@ -0,0 +1,145 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDataDir lays out a stopped data directory with a fake cluster.
+func writeDataDir(t *testing.T, dataDir, version string) {
+	t.Helper()
+	files := map[string]string{
+		"data/PG_VERSION":        "16",
+		"data/base/1/1234":       version,
+		"storage/avatars/me.png": version,
+		"keys.json":              `{"version":"` + version + `"}`,
+		"bin/v1/gotrue":          "binary",
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCreateRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	writeDataDir(t, dataDir, "before")
+
+	snap, err := Create(context.Background(), dataDir, "known-good", nil)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if snap.Online || snap.Size == 0 {
+		t.Errorf("Create() = %+v, want an offline snapshot with a size", snap)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(dataDir), "known-good", "bin")); !os.IsNotExist(err) {
+		t.Error("snapshot should not include cached binaries")
+	}
+	if _, err := Create(context.Background(), dataDir, "known-good", nil); err == nil {
+		t.Error("Create() should refuse an existing name")
+	}
+
+	// Change everything, including adding a file the snapshot doesn't have
+	writeDataDir(t, dataDir, "after")
+	os.WriteFile(filepath.Join(dataDir, "data", "base", "1", "5678"), []byte("new"), 0600)
+
+	if _, err := Restore(dataDir, "known-good"); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	for _, name := range []string{"data/base/1/1234", "storage/avatars/me.png"} {
+		if got := readFile(t, filepath.Join(dataDir, name)); got != "before" {
+			t.Errorf("%s = %q after restore, want %q", name, got, "before")
+		}
+	}
+	if got := readFile(t, filepath.Join(dataDir, "keys.json")); got != `{"version":"before"}` {
+		t.Errorf("keys.json = %q after restore", got)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "data", "base", "1", "5678")); !os.IsNotExist(err) {
+		t.Error("restore should remove files created after the snapshot")
+	}
+	if got := readFile(t, filepath.Join(dataDir, "bin", "v1", "gotrue")); got != "binary" {
+		t.Error("restore should leave cached binaries alone")
+	}
+
+	// The snapshot itself survives a restore
+	if _, err := Restore(dataDir, "known-good"); err != nil {
+		t.Errorf("second Restore() failed: %v", err)
+	}
+}
+
+func TestListDelete(t *testing.T) {
+	dataDir := t.TempDir()
+	writeDataDir(t, dataDir, "v")
+
+	if snapshots, err := List(dataDir); err != nil || len(snapshots) != 0 {
+		t.Fatalf("List() = %v, %v, want none", snapshots, err)
+	}
+	for _, name := range []string{"first", "second"} {
+		if _, err := Create(context.Background(), dataDir, name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := List(dataDir)
+	if err != nil || len(snapshots) != 2 || snapshots[0].Name != "first" || snapshots[1].Name != "second" {
+		t.Fatalf("List() = %+v, %v, want first and second", snapshots, err)
+	}
+
+	if err := Delete(dataDir, "first"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := Delete(dataDir, "first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of a missing snapshot = %v, want ErrNotFound", err)
+	}
+	if snapshots, _ := List(dataDir); len(snapshots) != 1 {
+		t.Errorf("List() after delete = %+v, want one snapshot", snapshots)
+	}
+}
+
+func TestRestoreWhileRunning(t *testing.T) {
+	dataDir := t.TempDir()
+	writeDataDir(t, dataDir, "v")
+	if _, err := Create(context.Background(), dataDir, "snap", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// This process stands in for a running postmaster
+	pid := fmt.Sprintf("%d\n%s\n0\n5432\n", os.Getpid(), dataDir)
+	os.WriteFile(filepath.Join(dataDir, "data", "postmaster.pid"), []byte(pid), 0600)
+
+	if _, err := Restore(dataDir, "snap"); !errors.Is(err, ErrRunning) {
+		t.Errorf("Restore() = %v, want ErrRunning", err)
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	dataDir := t.TempDir()
+	writeDataDir(t, dataDir, "v")
+
+	for _, name := range []string{"", "../escape", ".hidden", "a/b"} {
+		if _, err := Create(context.Background(), dataDir, name, nil); err == nil {
+			t.Errorf("Create(%q) should fail", name)
+		}
+		if _, err := Restore(dataDir, name); err == nil {
+			t.Errorf("Restore(%q) should fail", name)
+		}
+	}
+}
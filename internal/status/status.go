@@ -0,0 +1,261 @@
+// Package status serves a public status page: the overall health of the
+// server and the availability of each API, with uptime counters since the
+// server started.
+//
+// APIs are probed periodically rather than on each page view, so the page
+// is cheap to serve to monitors and users. It shows no error details, only
+// whether each API is up.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTitle    = "Supalite Status"
+)
+
+// probeTimeout bounds a single probe.
+const probeTimeout = 5 * time.Second
+
+// Overall statuses
+const (
+	StatusOperational = "operational" // Every API is up
+	StatusDegraded    = "degraded"    // Some APIs are down
+	StatusOutage      = "outage"      // Every API is down
+)
+
+// Check is an API whose availability is reported.
+type Check struct {
+	Name  string                          // e.g. "rest"
+	Probe func(ctx context.Context) error // Returns nil while the API is up
+}
+
+// Config holds the configuration for the status page.
+type Config struct {
+	Title    string        // Optional: page heading (default: DefaultTitle)
+	Checks   []Check       // APIs to probe
+	Interval time.Duration // Optional: how often to probe (default: 30s)
+}
+
+// Report is the status page's content.
+type Report struct {
+	Title         string      `json:"title"`
+	Status        string      `json:"status"`
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	Components    []Component `json:"components"`
+}
+
+// Component is the availability of one API.
+type Component struct {
+	Name          string    `json:"name"`
+	Up            bool      `json:"up"`
+	Since         time.Time `json:"since"` // When it last went up or down
+	LastChecked   time.Time `json:"last_checked"`
+	Checks        int64     `json:"checks"`
+	FailedChecks  int64     `json:"failed_checks"`
+	UptimePercent float64   `json:"uptime_percent"` // Share of checks that succeeded
+}
+
+// Monitor probes the APIs and serves the status page.
+type Monitor struct {
+	config Config
+	now    func() time.Time
+
+	mu         sync.Mutex
+	started    time.Time
+	components []Component
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMonitor creates a monitor. Nothing is probed until Start.
+func NewMonitor(cfg Config) *Monitor {
+	if cfg.Title == "" {
+		cfg.Title = DefaultTitle
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	m := &Monitor{config: cfg, now: time.Now}
+	m.started = m.now()
+	for _, check := range cfg.Checks {
+		m.components = append(m.components, Component{Name: check.Name})
+	}
+	return m
+}
+
+// Start probes every API once, so the page is complete from the first
+// request, and then keeps probing in the background until Stop.
+func (m *Monitor) Start(ctx context.Context) {
+	m.probe(ctx)
+
+	probeCtx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-probeCtx.Done():
+				return
+			case <-ticker.C:
+				m.probe(probeCtx)
+			}
+		}
+	}()
+}
+
+// Stop stops probing.
+func (m *Monitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// probe runs every check concurrently and records the results.
+func (m *Monitor) probe(ctx context.Context) {
+	errs := make([]error, len(m.config.Checks))
+	var wg sync.WaitGroup
+	for i, check := range m.config.Checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			errs[i] = check.Probe(checkCtx)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for i := range m.components {
+		c := &m.components[i]
+		up := errs[i] == nil
+		if c.Checks == 0 || up != c.Up {
+			if c.Checks > 0 && up {
+				log.Info("status: API is back up", "api", c.Name)
+			} else if !up {
+				log.Warn("status: API is down", "api", c.Name, "error", errs[i])
+			}
+			c.Since = now
+		}
+		c.Up = up
+		c.LastChecked = now
+		c.Checks++
+		if !up {
+			c.FailedChecks++
+		}
+		c.UptimePercent = float64(c.Checks-c.FailedChecks) / float64(c.Checks) * 100
+	}
+}
+
+// Report returns the current status.
+func (m *Monitor) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := Report{
+		Title:         m.config.Title,
+		StartedAt:     m.started,
+		UptimeSeconds: int64(m.now().Sub(m.started).Seconds()),
+		Components:    append([]Component(nil), m.components...),
+	}
+	down := 0
+	for _, c := range m.components {
+		if !c.Up {
+			down++
+		}
+	}
+	switch {
+	case down == 0:
+		report.Status = StatusOperational
+	case down < len(m.components):
+		report.Status = StatusDegraded
+	default:
+		report.Status = StatusOutage
+	}
+	return report
+}
+
+// Handler serves the status page: JSON for ?format=json or an Accept
+// header asking for JSON, and HTML otherwise. The response is 503 while
+// any API is down, so monitors can alert on the status code alone.
+func (m *Monitor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.Report()
+		code := http.StatusOK
+		if report.Status != StatusOperational {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(code)
+		if err := pageTemplate.Execute(w, report); err != nil {
+			log.Error("failed to render status page", "error", err)
+		}
+	})
+}
+
+var pageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"duration": func(seconds int64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+	"timestamp": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 3rem auto; padding: 0 1rem; color: #1f2937; }
+.banner { padding: 1rem; border-radius: .5rem; font-weight: 600; color: #fff; }
+.operational { background: #16a34a; } .degraded { background: #d97706; } .outage { background: #dc2626; }
+table { width: 100%; border-collapse: collapse; margin-top: 1.5rem; }
+td, th { text-align: left; padding: .5rem; border-bottom: 1px solid #e5e7eb; }
+.up { color: #16a34a; } .down { color: #dc2626; }
+.meta { color: #6b7280; font-size: .875rem; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else if eq .Status "degraded"}}Some systems are down{{else}}All systems are down{{end}}</div>
+<table>
+<tr><th>API</th><th>Status</th><th>Uptime</th><th>Since</th></tr>
+{{range .Components}}<tr><td>{{.Name}}</td><td class="{{if .Up}}up{{else}}down{{end}}">{{if .Up}}Up{{else}}Down{{end}}</td><td>{{printf "%.2f" .UptimePercent}}%</td><td>{{timestamp .Since}}</td></tr>
+{{end}}</table>
+<p class="meta">Up for {{duration .UptimeSeconds}} since {{timestamp .StartedAt}}.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,84 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	authErr := error(nil)
+	m := NewMonitor(Config{Checks: []Check{
+		{Name: "rest", Probe: func(context.Context) error { return nil }},
+		{Name: "auth", Probe: func(context.Context) error { return authErr }},
+	}})
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	m.started = now
+
+	m.probe(context.Background())
+	if r := m.Report(); r.Status != StatusOperational {
+		t.Errorf("Status = %q, want %q", r.Status, StatusOperational)
+	}
+
+	now = now.Add(time.Minute)
+	authErr = errors.New("gotrue is not running")
+	m.probe(context.Background())
+	now = now.Add(time.Minute)
+	m.probe(context.Background())
+	m.probe(context.Background())
+
+	r := m.Report()
+	if r.Status != StatusDegraded || r.UptimeSeconds != 120 {
+		t.Errorf("Report = %+v", r)
+	}
+	auth := r.Components[1]
+	if auth.Up || auth.Checks != 4 || auth.FailedChecks != 3 || auth.UptimePercent != 25 {
+		t.Errorf("auth = %+v", auth)
+	}
+	if !auth.Since.Equal(time.Unix(1060, 0)) {
+		t.Errorf("auth.Since = %v, want when it went down", auth.Since)
+	}
+	if rest := r.Components[0]; !rest.Up || rest.UptimePercent != 100 || !rest.Since.Equal(time.Unix(1000, 0)) {
+		t.Errorf("rest = %+v", rest)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	up := true
+	m := NewMonitor(Config{Title: "Acme API", Checks: []Check{
+		{Name: "rest", Probe: func(context.Context) error {
+			if !up {
+				return errors.New("down")
+			}
+			return nil
+		}},
+	}})
+	m.probe(context.Background())
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Acme API") || !strings.Contains(rec.Body.String(), "All systems operational") {
+		t.Errorf("HTML page = %d %s", rec.Code, rec.Body.String())
+	}
+
+	up = false
+	m.probe(context.Background())
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status?format=json", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 while an API is down", rec.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Status != StatusOutage || len(report.Components) != 1 || report.Components[0].Up {
+		t.Errorf("report = %+v", report)
+	}
+}
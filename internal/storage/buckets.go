@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// bucket is a row of storage.buckets.
+type bucket struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Owner            *string   `json:"owner"`
+	Public           bool      `json:"public"`
+	FileSizeLimit    *int64    `json:"file_size_limit"`
+	AllowedMimeTypes []string  `json:"allowed_mime_types"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+const bucketColumns = "id, name, owner::text, public, file_size_limit, allowed_mime_types, created_at, updated_at"
+
+func scanBucket(row pgx.Row) (*bucket, error) {
+	var b bucket
+	err := row.Scan(&b.ID, &b.Name, &b.Owner, &b.Public, &b.FileSizeLimit, &b.AllowedMimeTypes, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// writeDBError maps a database error to the storage API error Supabase
+// returns for it.
+func writeDBError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			writeError(w, http.StatusConflict, "Duplicate", "The resource already exists")
+			return
+		case "42501": // insufficient_privilege, including RLS violations
+			writeError(w, http.StatusForbidden, "Unauthorized", pgErr.Message)
+			return
+		case "22023": // the token's role doesn't exist
+			writeError(w, http.StatusUnauthorized, "Unauthorized", pgErr.Message)
+			return
+		}
+	}
+	writeError(w, http.StatusInternalServerError, "internal", err.Error())
+}
+
+// handleListBuckets lists the buckets visible to the caller.
+//
+// GET /bucket
+func (s *Server) handleListBuckets(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	rows, err := tx.Query(r.Context(), "SELECT "+bucketColumns+" FROM storage.buckets ORDER BY name")
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	buckets := []*bucket{}
+	for rows.Next() {
+		b, err := scanBucket(rows)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buckets)
+}
+
+// handleGetBucket returns a single bucket.
+//
+// GET /bucket/{bucket}
+func (s *Server) handleGetBucket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	b, err := scanBucket(tx.QueryRow(r.Context(), "SELECT "+bucketColumns+" FROM storage.buckets WHERE id = $1", bucketID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, b)
+}
+
+// bucketRequest is the body of bucket create and update requests.
+type bucketRequest struct {
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	Public           *bool       `json:"public"`
+	FileSizeLimit    interface{} `json:"file_size_limit"`
+	AllowedMimeTypes []string    `json:"allowed_mime_types"`
+}
+
+// handleCreateBucket creates a bucket.
+//
+// POST /bucket
+//
+// Request body:
+//
+//	{"id": "avatars", "name": "avatars", "public": false,
+//	 "file_size_limit": "5MB", "allowed_mime_types": ["image/*"]}
+//
+// Returns {"name": "<id>"}.
+func (s *Server) handleCreateBucket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var req bucketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "invalid request body")
+		return
+	}
+	if req.ID == "" {
+		req.ID = req.Name
+	}
+	if req.Name == "" {
+		req.Name = req.ID
+	}
+	if !validBucketID(req.ID) {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "Bucket name invalid")
+		return
+	}
+	sizeLimit, err := parseFileSizeLimit(req.FileSizeLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", err.Error())
+		return
+	}
+	public := req.Public != nil && *req.Public
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	_, err = tx.Exec(r.Context(), `
+		INSERT INTO storage.buckets (id, name, owner, public, file_size_limit, allowed_mime_types)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		req.ID, req.Name, ownerID(claims), public, sizeLimit, req.AllowedMimeTypes)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"name": req.ID})
+}
+
+// handleUpdateBucket changes a bucket's settings.
+//
+// PUT /bucket/{bucket}
+//
+// Request body:
+//
+//	{"public": true, "file_size_limit": 1048576, "allowed_mime_types": null}
+func (s *Server) handleUpdateBucket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+
+	var req bucketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "invalid request body")
+		return
+	}
+	sizeLimit, err := parseFileSizeLimit(req.FileSizeLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", err.Error())
+		return
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	tag, err := tx.Exec(r.Context(), `
+		UPDATE storage.buckets
+		SET public = coalesce($2, public), file_size_limit = $3, allowed_mime_types = $4, updated_at = now()
+		WHERE id = $1`,
+		bucketID, req.Public, sizeLimit, req.AllowedMimeTypes)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully updated"})
+}
+
+// handleEmptyBucket deletes every object in a bucket that the caller can
+// delete.
+//
+// POST /bucket/{bucket}/empty
+func (s *Server) handleEmptyBucket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var exists bool
+	if err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM storage.buckets WHERE id = $1)", bucketID).Scan(&exists); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+
+	rows, err := tx.Query(r.Context(), "DELETE FROM storage.objects WHERE bucket_id = $1 RETURNING id::text", bucketID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for _, id := range ids {
+		os.Remove(s.objectPath(bucketID, id))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully emptied"})
+}
+
+// handleDeleteBucket deletes an empty bucket.
+//
+// DELETE /bucket/{bucket}
+func (s *Server) handleDeleteBucket(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(r.Context(), conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	tag, err := tx.Exec(r.Context(), "DELETE FROM storage.buckets WHERE id = $1", bucketID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			writeError(w, http.StatusConflict, "InvalidRequest", "The bucket you tried to delete is not empty")
+			return
+		}
+		writeDBError(w, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	os.RemoveAll(s.bucketDir(bucketID))
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully deleted"})
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// uploadsDir holds uploads in progress, under the storage root. Valid
+// bucket IDs can't start with a dot, so it never collides with a bucket
+// directory.
+const uploadsDir = ".uploads"
+
+var (
+	// bucketIDPattern limits bucket IDs to names that are safe as a
+	// directory name.
+	bucketIDPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_. -]*$`)
+
+	// objectNamePattern is the set of characters Supabase Storage accepts
+	// in object keys.
+	objectNamePattern = regexp.MustCompile(`^[\w/!\-.*'() &$@=;:+,?]*$`)
+
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// validBucketID reports whether id can name a bucket.
+func validBucketID(id string) bool {
+	return len(id) <= 100 && bucketIDPattern.MatchString(id)
+}
+
+// validObjectName reports whether name can name an object: a relative
+// path without empty, "." or ".." segments.
+func validObjectName(name string) bool {
+	if name == "" || len(name) > 1024 || !utf8.ValidString(name) || !objectNamePattern.MatchString(name) {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// mimeTypeAllowed reports whether contentType matches a bucket's allowed
+// MIME types. Entries may use a wildcard subtype, as in "image/*". An empty
+// list allows everything.
+func mimeTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mimeType, _, _ := strings.Cut(contentType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mimeType || pattern == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFileSizeLimit parses a bucket file_size_limit, given either as a
+// number of bytes or as a string with a unit such as "20MB".
+func parseFileSizeLimit(value interface{}) (*int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		n := int64(v)
+		return &n, nil
+	case int:
+		n := int64(v)
+		return &n, nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(v))
+		if s == "" {
+			return nil, nil
+		}
+		multiplier := int64(1)
+		for _, unit := range []struct {
+			suffix string
+			size   int64
+		}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+			if trimmed, ok := strings.CutSuffix(s, unit.suffix); ok {
+				s, multiplier = strings.TrimSpace(trimmed), unit.size
+				break
+			}
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid file_size_limit %q", v)
+		}
+		size := int64(n * float64(multiplier))
+		return &size, nil
+	default:
+		return nil, fmt.Errorf("invalid file_size_limit %v", v)
+	}
+}
+
+// bucketDir returns the directory holding a bucket's objects. Buckets
+// created in SQL may have IDs that aren't safe as a directory name; those
+// are stored under a hash of the ID instead.
+func (s *Server) bucketDir(bucketID string) string {
+	if !validBucketID(bucketID) {
+		sum := sha256.Sum256([]byte(bucketID))
+		bucketID = ".bucket-" + hex.EncodeToString(sum[:8])
+	}
+	return filepath.Join(s.config.Root, bucketID)
+}
+
+// objectPath returns where an object's data is stored.
+func (s *Server) objectPath(bucketID, objectID string) string {
+	return filepath.Join(s.bucketDir(bucketID), objectID)
+}
+
+// stagedUpload is object data written to the uploads directory, waiting
+// to be moved into place once its metadata is committed.
+type stagedUpload struct {
+	path string
+	size int64
+	etag string
+}
+
+// errTooLarge is returned by stageUpload when the body exceeds the limit.
+var errTooLarge = fmt.Errorf("object exceeds the maximum allowed size")
+
+// stageUpload writes body to a temporary file, failing with errTooLarge
+// if it is longer than limit bytes.
+func (s *Server) stageUpload(body io.Reader, limit int64) (*stagedUpload, error) {
+	f, err := os.CreateTemp(filepath.Join(s.config.Root, uploadsDir), "upload-")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(body, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > limit {
+		err = errTooLarge
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &stagedUpload{path: f.Name(), size: size, etag: `"` + hex.EncodeToString(hash.Sum(nil)) + `"`}, nil
+}
+
+// commit moves the staged data to its final location.
+func (u *stagedUpload) commit(dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(u.path, dst)
+}
+
+// discard removes the staged data if it wasn't committed.
+func (u *stagedUpload) discard() {
+	os.Remove(u.path)
+}
+
+// copyObjectFile copies object data to a new location.
+func copyObjectFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
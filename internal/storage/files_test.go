@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidObjectName(t *testing.T) {
+	valid := []string{"a.png", "folder/sub/file name (1).txt", "user@example.com/avatar.jpg", ".emptyFolderPlaceholder"}
+	invalid := []string{"", "/abs.png", "a//b", "a/../b", "./a", "trailing/", "tab\tname", "percent%20"}
+
+	for _, name := range valid {
+		if !validObjectName(name) {
+			t.Errorf("validObjectName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range invalid {
+		if validObjectName(name) {
+			t.Errorf("validObjectName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestValidBucketID(t *testing.T) {
+	for _, id := range []string{"avatars", "my-bucket_1", "Public Files"} {
+		if !validBucketID(id) {
+			t.Errorf("validBucketID(%q) = false, want true", id)
+		}
+	}
+	for _, id := range []string{"", ".", "..", ".uploads", "a/b", `a\b`} {
+		if validBucketID(id) {
+			t.Errorf("validBucketID(%q) = true, want false", id)
+		}
+	}
+}
+
+func TestBucketDir_UnsafeID(t *testing.T) {
+	s := &Server{config: Config{Root: "/data/storage"}}
+
+	if got := s.bucketDir("avatars"); got != "/data/storage/avatars" {
+		t.Errorf("bucketDir(avatars) = %q", got)
+	}
+	got := s.bucketDir("../escape")
+	if filepath.Dir(got) != "/data/storage" || strings.Contains(got, "..") {
+		t.Errorf("bucketDir(../escape) = %q, want a directory directly under the root", got)
+	}
+}
+
+func TestMimeTypeAllowed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{"image/png", nil, true},
+		{"image/png", []string{"image/png"}, true},
+		{"image/png", []string{"image/*"}, true},
+		{"IMAGE/PNG; charset=binary", []string{"image/png"}, true},
+		{"text/plain;charset=UTF-8", []string{"image/*"}, false},
+		{"application/pdf", []string{"*/*"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := mimeTypeAllowed(tt.contentType, tt.allowed); got != tt.want {
+			t.Errorf("mimeTypeAllowed(%q, %v) = %v, want %v", tt.contentType, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestParseFileSizeLimit(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int64
+	}{
+		{float64(1024), 1024},
+		{1024, 1024},
+		{"1024", 1024},
+		{"20MB", 20 << 20},
+		{"1.5 kb", 1536},
+		{"1GB", 1 << 30},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFileSizeLimit(tt.value)
+		if err != nil {
+			t.Errorf("parseFileSizeLimit(%v) failed: %v", tt.value, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("parseFileSizeLimit(%v) = %d, want %d", tt.value, *got, tt.want)
+		}
+	}
+
+	if got, err := parseFileSizeLimit(nil); got != nil || err != nil {
+		t.Errorf("parseFileSizeLimit(nil) = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := parseFileSizeLimit("lots"); err == nil {
+		t.Error("parseFileSizeLimit(lots) succeeded, want error")
+	}
+}
+
+func TestStageUpload(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, uploadsDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{config: Config{Root: root}}
+
+	staged, err := s.stageUpload(strings.NewReader("hello"), 5)
+	if err != nil {
+		t.Fatalf("stageUpload() failed: %v", err)
+	}
+	if staged.size != 5 || staged.etag != `"5d41402abc4b2a76b9719d911017c592"` {
+		t.Errorf("staged size %d etag %s", staged.size, staged.etag)
+	}
+
+	dst := s.objectPath("bucket", "id")
+	if err := staged.commit(dst); err != nil {
+		t.Fatalf("commit() failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("committed data = %q", data)
+	}
+
+	if _, err := s.stageUpload(strings.NewReader("hello!"), 5); !errors.Is(err, errTooLarge) {
+		t.Errorf("stageUpload() over the limit = %v, want errTooLarge", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, uploadsDir)); len(entries) != 0 {
+		t.Errorf("uploads directory not cleaned up: %d entries", len(entries))
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	id := "id"
+	entries := []*listEntry{
+		{Name: "b.png", ID: &id, CreatedAt: &older},
+		{Name: "folder"},
+		{Name: "a.png", ID: &id, CreatedAt: &newer},
+	}
+
+	sortEntries(entries, "name", "asc")
+	if entries[0].Name != "a.png" || entries[1].Name != "b.png" || entries[2].Name != "folder" {
+		t.Errorf("by name: %s, %s, %s", entries[0].Name, entries[1].Name, entries[2].Name)
+	}
+
+	sortEntries(entries, "created_at", "desc")
+	if entries[0].Name != "a.png" || entries[1].Name != "b.png" || entries[2].Name != "folder" {
+		t.Errorf("by created_at desc: %s, %s, %s", entries[0].Name, entries[1].Name, entries[2].Name)
+	}
+}
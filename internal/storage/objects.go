@@ -0,0 +1,818 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// defaultContentType is used for uploads without a Content-Type, as
+	// supabase-js does.
+	defaultContentType = "text/plain;charset=UTF-8"
+
+	// defaultCacheControl is used for uploads without a Cache-Control.
+	defaultCacheControl = "no-cache"
+)
+
+// objectMetadata is the metadata column of storage.objects.
+type objectMetadata struct {
+	ETag           string `json:"eTag"`
+	Size           int64  `json:"size"`
+	MimeType       string `json:"mimetype"`
+	CacheControl   string `json:"cacheControl"`
+	LastModified   string `json:"lastModified"`
+	ContentLength  int64  `json:"contentLength"`
+	HTTPStatusCode int    `json:"httpStatusCode"`
+}
+
+// objectInfo is a row of storage.objects as returned by the API.
+type objectInfo struct {
+	ID             string                 `json:"id"`
+	BucketID       string                 `json:"bucket_id"`
+	Name           string                 `json:"name"`
+	Owner          *string                `json:"owner"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	LastAccessedAt time.Time              `json:"last_accessed_at"`
+	Metadata       map[string]interface{} `json:"metadata"`
+}
+
+const objectColumns = "id::text, bucket_id, name, owner::text, created_at, updated_at, last_accessed_at, metadata"
+
+func scanObject(row pgx.Row) (*objectInfo, error) {
+	var o objectInfo
+	err := row.Scan(&o.ID, &o.BucketID, &o.Name, &o.Owner, &o.CreatedAt, &o.UpdatedAt, &o.LastAccessedAt, &o.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// uploadMode selects how an upload treats an existing object.
+type uploadMode int
+
+const (
+	uploadCreate uploadMode = iota // fail if the object exists
+	uploadUpsert                   // create or replace
+	uploadUpdate                   // replace; fail if the object doesn't exist
+)
+
+// uploadResponse is returned by the upload endpoints.
+type uploadResponse struct {
+	ID  string `json:"Id"`
+	Key string `json:"Key"`
+}
+
+// handleUpload uploads a new object, or replaces one when the x-upsert
+// header is "true".
+//
+// POST /object/{bucket}/{path}
+//
+// The body is either the raw file, with its Content-Type and
+// Cache-Control headers, or a multipart form with a cacheControl field
+// and a file part, as sent by supabase-js for File and Blob uploads.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+
+	mode := uploadCreate
+	if r.Header.Get("x-upsert") == "true" {
+		mode = uploadUpsert
+	}
+	s.storeObject(w, r, claims, ownerID(claims), bucketID, name, mode)
+}
+
+// handleUpdate replaces an existing object.
+//
+// PUT /object/{bucket}/{path}
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+	s.storeObject(w, r, claims, ownerID(claims), bucketID, name, uploadUpdate)
+}
+
+// storeObject writes an uploaded object and its metadata. Metadata is
+// written as the caller (claims), so RLS insert and update policies apply.
+func (s *Server) storeObject(w http.ResponseWriter, r *http.Request, claims map[string]interface{}, owner *string, bucketID, name string, mode uploadMode) {
+	ctx := r.Context()
+	if !validObjectName(name) {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "Invalid key: "+name)
+		return
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	// Bucket limits apply to everyone, so they're read without RLS
+	var bucketLimit *int64
+	var allowedTypes []string
+	err := conn.QueryRow(ctx, "SELECT file_size_limit, allowed_mime_types FROM storage.buckets WHERE id = $1", bucketID).
+		Scan(&bucketLimit, &allowedTypes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	limit := s.config.FileSizeLimit
+	if bucketLimit != nil && *bucketLimit > 0 && *bucketLimit < limit {
+		limit = *bucketLimit
+	}
+
+	body, contentType, cacheControl, err := readUploadBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", err.Error())
+		return
+	}
+	if !mimeTypeAllowed(contentType, allowedTypes) {
+		writeError(w, http.StatusUnsupportedMediaType, "invalid_mime_type", fmt.Sprintf("mime type %s is not supported", contentType))
+		return
+	}
+
+	staged, err := s.stageUpload(body, limit)
+	if errors.Is(err, errTooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "Payload too large", "The object exceeded the maximum allowed size")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", fmt.Sprintf("failed to store object: %v", err))
+		return
+	}
+	defer staged.discard()
+
+	metadata := objectMetadata{
+		ETag:           staged.etag,
+		Size:           staged.size,
+		MimeType:       contentType,
+		CacheControl:   cacheControl,
+		LastModified:   time.Now().UTC().Format(http.TimeFormat),
+		ContentLength:  staged.size,
+		HTTPStatusCode: http.StatusOK,
+	}
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	switch mode {
+	case uploadUpdate:
+		err = tx.QueryRow(ctx, `
+			UPDATE storage.objects SET metadata = $3, owner = $4, updated_at = now()
+			WHERE bucket_id = $1 AND name = $2
+			RETURNING id::text`, bucketID, name, metadata, owner).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "not_found", "Object not found")
+			return
+		}
+	case uploadUpsert:
+		err = tx.QueryRow(ctx, `
+			INSERT INTO storage.objects (bucket_id, name, owner, metadata) VALUES ($1, $2, $4, $3)
+			ON CONFLICT (bucket_id, name) DO UPDATE
+			SET metadata = EXCLUDED.metadata, owner = EXCLUDED.owner, updated_at = now()
+			RETURNING id::text`, bucketID, name, metadata, owner).Scan(&id)
+	default:
+		err = tx.QueryRow(ctx, `
+			INSERT INTO storage.objects (bucket_id, name, owner, metadata) VALUES ($1, $2, $4, $3)
+			RETURNING id::text`, bucketID, name, metadata, owner).Scan(&id)
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	dst := s.objectPath(bucketID, id)
+	if err := staged.commit(dst); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", fmt.Sprintf("failed to store object: %v", err))
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		if mode == uploadCreate {
+			os.Remove(dst)
+		}
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, uploadResponse{ID: id, Key: bucketID + "/" + name})
+}
+
+// readUploadBody returns the file data of an upload request along with
+// its content type and cache control.
+func readUploadBody(r *http.Request) (io.Reader, string, string, error) {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if mediaType != "multipart/form-data" {
+		if contentType == "" {
+			contentType = defaultContentType
+		}
+		cacheControl := r.Header.Get("Cache-Control")
+		if cacheControl == "" {
+			cacheControl = defaultCacheControl
+		}
+		return r.Body, contentType, cacheControl, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", "", err
+	}
+	cacheControl := defaultCacheControl
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", "", errors.New("multipart upload has no file")
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		// supabase-js sends cacheControl before the file itself
+		if part.FileName() == "" && part.FormName() != "" {
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			if part.FormName() == "cacheControl" && len(value) > 0 {
+				cacheControl = "max-age=" + string(value)
+			}
+			continue
+		}
+
+		partType := part.Header.Get("Content-Type")
+		if partType == "" {
+			partType = defaultContentType
+		}
+		return part, partType, cacheControl, nil
+	}
+}
+
+// handleDownload serves an object the caller can read.
+//
+// GET /object/{bucket}/{path}
+// GET /object/authenticated/{bucket}/{path}
+//
+// A download query parameter serves the object as an attachment, named
+// by its value or, if empty, by the object's file name.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+	s.serveObject(w, r, claims, bucketID, name)
+}
+
+// handlePublicDownload serves an object from a public bucket without
+// authorization.
+//
+// GET /object/public/{bucket}/{path}
+func (s *Server) handlePublicDownload(w http.ResponseWriter, r *http.Request) {
+	bucketID, name := pathParams(r)
+
+	conn, ok := s.connect(w, r, nil)
+	if !ok {
+		return
+	}
+	var public bool
+	err := conn.QueryRow(r.Context(), "SELECT public FROM storage.buckets WHERE id = $1", bucketID).Scan(&public)
+	conn.Release()
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeDBError(w, err)
+		return
+	}
+	if !public {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+
+	s.serveObject(w, r, nil, bucketID, name)
+}
+
+// serveObject writes an object's data, looking it up as the caller so RLS
+// select policies apply. Range and conditional requests are supported.
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, claims map[string]interface{}, bucketID, name string) {
+	ctx := r.Context()
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	var id string
+	var metadata objectMetadata
+	var updatedAt time.Time
+	err = tx.QueryRow(ctx, "SELECT id::text, coalesce(metadata, '{}'), updated_at FROM storage.objects WHERE bucket_id = $1 AND name = $2",
+		bucketID, name).Scan(&id, &metadata, &updatedAt)
+	tx.Rollback(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	f, err := os.Open(s.objectPath(bucketID, id))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+	defer f.Close()
+
+	if metadata.MimeType != "" {
+		w.Header().Set("Content-Type", metadata.MimeType)
+	}
+	if metadata.CacheControl != "" {
+		w.Header().Set("Cache-Control", metadata.CacheControl)
+	}
+	if metadata.ETag != "" {
+		w.Header().Set("ETag", metadata.ETag)
+	}
+	if filename, ok := r.URL.Query()["download"]; ok {
+		attachmentName := path.Base(name)
+		if filename[0] != "" {
+			attachmentName = filename[0]
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachmentName}))
+	}
+
+	http.ServeContent(w, r, path.Base(name), updatedAt, f)
+}
+
+// handleDeleteObject deletes a single object.
+//
+// DELETE /object/{bucket}/{path}
+func (s *Server) handleDeleteObject(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+	ctx := r.Context()
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	err = tx.QueryRow(ctx, "DELETE FROM storage.objects WHERE bucket_id = $1 AND name = $2 RETURNING id::text", bucketID, name).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	os.Remove(s.objectPath(bucketID, id))
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully deleted"})
+}
+
+// handleDeleteObjects deletes several objects by name and returns the
+// deleted objects. Names that don't exist are ignored.
+//
+// DELETE /object/{bucket}
+//
+// Request body:
+//
+//	{"prefixes": ["folder/a.png", "b.png"]}
+func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+	ctx := r.Context()
+
+	var req struct {
+		Prefixes []string `json:"prefixes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "invalid request body")
+		return
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "DELETE FROM storage.objects WHERE bucket_id = $1 AND name = ANY($2) RETURNING "+objectColumns,
+		bucketID, req.Prefixes)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	deleted := []*objectInfo{}
+	for rows.Next() {
+		o, err := scanObject(rows)
+		if err != nil {
+			rows.Close()
+			writeDBError(w, err)
+			return
+		}
+		deleted = append(deleted, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for _, o := range deleted {
+		os.Remove(s.objectPath(bucketID, o.ID))
+	}
+	writeJSON(w, http.StatusOK, deleted)
+}
+
+// listRequest is the body of POST /object/list/{bucket}.
+type listRequest struct {
+	Prefix string `json:"prefix"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Search string `json:"search"`
+	SortBy struct {
+		Column string `json:"column"`
+		Order  string `json:"order"`
+	} `json:"sortBy"`
+}
+
+// listEntry is a file or folder in a listing. Folders have only a name.
+type listEntry struct {
+	Name           string                 `json:"name"`
+	ID             *string                `json:"id"`
+	UpdatedAt      *time.Time             `json:"updated_at"`
+	CreatedAt      *time.Time             `json:"created_at"`
+	LastAccessedAt *time.Time             `json:"last_accessed_at"`
+	Metadata       map[string]interface{} `json:"metadata"`
+}
+
+// handleListObjects lists the files and folders directly under a prefix.
+//
+// POST /object/list/{bucket}
+//
+// Request body:
+//
+//	{"prefix": "folder", "limit": 100, "offset": 0,
+//	 "sortBy": {"column": "name", "order": "asc"}, "search": ""}
+func (s *Server) handleListObjects(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+	ctx := r.Context()
+
+	var req listRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "invalid request body")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	prefix := strings.TrimPrefix(req.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	likePattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	rows, err := tx.Query(ctx, `
+		SELECT name, id::text, updated_at, created_at, last_accessed_at, metadata
+		FROM storage.objects WHERE bucket_id = $1 AND name LIKE $2`, bucketID, likePattern)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	entries := []*listEntry{}
+	folders := make(map[string]bool)
+	search := strings.ToLower(req.Search)
+	for rows.Next() {
+		var name, id string
+		var updatedAt, createdAt, lastAccessedAt time.Time
+		var metadata map[string]interface{}
+		if err := rows.Scan(&name, &id, &updatedAt, &createdAt, &lastAccessedAt, &metadata); err != nil {
+			rows.Close()
+			writeDBError(w, err)
+			return
+		}
+
+		rest := strings.TrimPrefix(name, prefix)
+		if folder, _, isFolder := strings.Cut(rest, "/"); isFolder {
+			if !folders[folder] && strings.HasPrefix(strings.ToLower(folder), search) {
+				folders[folder] = true
+				entries = append(entries, &listEntry{Name: folder})
+			}
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(rest), search) {
+			continue
+		}
+		entries = append(entries, &listEntry{
+			Name:           rest,
+			ID:             &id,
+			UpdatedAt:      &updatedAt,
+			CreatedAt:      &createdAt,
+			LastAccessedAt: &lastAccessedAt,
+			Metadata:       metadata,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	sortEntries(entries, req.SortBy.Column, req.SortBy.Order)
+
+	if req.Offset >= len(entries) {
+		entries = entries[:0]
+	} else {
+		entries = entries[req.Offset:]
+	}
+	if len(entries) > req.Limit {
+		entries = entries[:req.Limit]
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// sortEntries orders a listing by name (the default) or by a timestamp
+// column. Folders have no timestamps and sort before files.
+func sortEntries(entries []*listEntry, column, order string) {
+	timestamp := func(e *listEntry) *time.Time {
+		switch column {
+		case "created_at":
+			return e.CreatedAt
+		case "updated_at":
+			return e.UpdatedAt
+		case "last_accessed_at":
+			return e.LastAccessedAt
+		}
+		return nil
+	}
+	less := func(a, b *listEntry) bool {
+		ta, tb := timestamp(a), timestamp(b)
+		switch {
+		case ta == nil && tb == nil:
+			return a.Name < b.Name
+		case ta == nil:
+			return true
+		case tb == nil:
+			return false
+		case ta.Equal(*tb):
+			return a.Name < b.Name
+		}
+		return ta.Before(*tb)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if strings.EqualFold(order, "desc") {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// transferRequest is the body of move and copy requests.
+type transferRequest struct {
+	BucketID          string `json:"bucketId"`
+	SourceKey         string `json:"sourceKey"`
+	DestinationKey    string `json:"destinationKey"`
+	DestinationBucket string `json:"destinationBucket"`
+}
+
+// decodeTransfer reads and validates a move or copy request.
+func decodeTransfer(w http.ResponseWriter, r *http.Request) (*transferRequest, bool) {
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "invalid request body")
+		return nil, false
+	}
+	if req.DestinationBucket == "" {
+		req.DestinationBucket = req.BucketID
+	}
+	if !validObjectName(req.DestinationKey) {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "Invalid key: "+req.DestinationKey)
+		return nil, false
+	}
+	return &req, true
+}
+
+// writeTransferError maps move and copy errors, where a foreign key
+// violation means the destination bucket doesn't exist.
+func writeTransferError(w http.ResponseWriter, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		writeError(w, http.StatusNotFound, "Bucket not found", "Bucket not found")
+		return
+	}
+	writeDBError(w, err)
+}
+
+// handleMoveObject renames an object, optionally into another bucket.
+//
+// POST /object/move
+//
+// Request body:
+//
+//	{"bucketId": "avatars", "sourceKey": "a.png", "destinationKey": "b.png",
+//	 "destinationBucket": "archive"}
+func (s *Server) handleMoveObject(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeTransfer(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	err = tx.QueryRow(ctx, `
+		UPDATE storage.objects SET bucket_id = $3, name = $4, updated_at = now()
+		WHERE bucket_id = $1 AND name = $2
+		RETURNING id::text`, req.BucketID, req.SourceKey, req.DestinationBucket, req.DestinationKey).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+	if err != nil {
+		writeTransferError(w, err)
+		return
+	}
+
+	// Data is stored by object ID, so only a bucket change moves the file
+	src, dst := s.objectPath(req.BucketID, id), s.objectPath(req.DestinationBucket, id)
+	if src != dst {
+		if err := os.MkdirAll(s.bucketDir(req.DestinationBucket), 0755); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return
+		}
+		if err := os.Rename(src, dst); err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", fmt.Sprintf("failed to move object: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		if src != dst {
+			os.Rename(dst, src)
+		}
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Successfully moved"})
+}
+
+// handleCopyObject copies an object, optionally into another bucket.
+//
+// POST /object/copy
+//
+// Request body is the same as for /object/move.
+func (s *Server) handleCopyObject(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeTransfer(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var sourceID string
+	var metadata map[string]interface{}
+	err = tx.QueryRow(ctx, "SELECT id::text, metadata FROM storage.objects WHERE bucket_id = $1 AND name = $2",
+		req.BucketID, req.SourceKey).Scan(&sourceID, &metadata)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	var id string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO storage.objects (bucket_id, name, owner, metadata) VALUES ($1, $2, $3, $4)
+		RETURNING id::text`, req.DestinationBucket, req.DestinationKey, ownerID(claims), metadata).Scan(&id)
+	if err != nil {
+		writeTransferError(w, err)
+		return
+	}
+
+	dst := s.objectPath(req.DestinationBucket, id)
+	if err := copyObjectFile(s.objectPath(req.BucketID, sourceID), dst); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", fmt.Sprintf("failed to copy object: %v", err))
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		os.Remove(dst)
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, uploadResponse{ID: id, Key: req.DestinationBucket + "/" + req.DestinationKey})
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BucketSpec is the settings a bucket should have.
+type BucketSpec struct {
+	ID               string
+	Public           bool
+	FileSizeLimit    interface{} // Bytes, or a size such as "5MB"; nil is no limit
+	AllowedMimeTypes []string
+}
+
+// Results of EnsureBucket.
+const (
+	BucketCreated   = "created"
+	BucketUpdated   = "updated"
+	BucketUnchanged = "unchanged"
+)
+
+// EnsureBucket creates a bucket with spec's settings, or changes an
+// existing one's to them, leaving its objects alone. It returns whether
+// the bucket was created, updated, or already matched. The storage schema
+// must exist (see Server.Init).
+func EnsureBucket(ctx context.Context, conn *pgx.Conn, spec BucketSpec) (string, error) {
+	if !validBucketID(spec.ID) {
+		return "", fmt.Errorf("invalid bucket name %q", spec.ID)
+	}
+	sizeLimit, err := parseFileSizeLimit(spec.FileSizeLimit)
+	if err != nil {
+		return "", fmt.Errorf("bucket %s: %w", spec.ID, err)
+	}
+
+	b, err := scanBucket(conn.QueryRow(ctx, "SELECT "+bucketColumns+" FROM storage.buckets WHERE id = $1", spec.ID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err := conn.Exec(ctx, `
+			INSERT INTO storage.buckets (id, name, public, file_size_limit, allowed_mime_types)
+			VALUES ($1, $1, $2, $3, $4)`,
+			spec.ID, spec.Public, sizeLimit, spec.AllowedMimeTypes)
+		if err != nil {
+			return "", fmt.Errorf("failed to create bucket %s: %w", spec.ID, err)
+		}
+		return BucketCreated, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read bucket %s: %w", spec.ID, err)
+	}
+
+	sameLimit := (b.FileSizeLimit == nil) == (sizeLimit == nil) && (sizeLimit == nil || *b.FileSizeLimit == *sizeLimit)
+	if b.Public == spec.Public && sameLimit && slices.Equal(b.AllowedMimeTypes, spec.AllowedMimeTypes) {
+		return BucketUnchanged, nil
+	}
+	_, err = conn.Exec(ctx, `
+		UPDATE storage.buckets
+		SET public = $2, file_size_limit = $3, allowed_mime_types = $4, updated_at = now()
+		WHERE id = $1`,
+		spec.ID, spec.Public, sizeLimit, spec.AllowedMimeTypes)
+	if err != nil {
+		return "", fmt.Errorf("failed to update bucket %s: %w", spec.ID, err)
+	}
+	return BucketUpdated, nil
+}
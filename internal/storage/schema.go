@@ -0,0 +1,71 @@
+package storage
+
+// schemaSQL creates the storage tables and helper functions with the same
+// shape as Supabase Storage, so policies and queries written against
+// storage.objects work unchanged. RLS is enabled as on Supabase: callers
+// other than service_role need policies to use storage.
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS storage;
+
+CREATE TABLE IF NOT EXISTS storage.buckets (
+	id text PRIMARY KEY,
+	name text NOT NULL UNIQUE,
+	owner uuid,
+	public boolean NOT NULL DEFAULT false,
+	file_size_limit bigint,
+	allowed_mime_types text[],
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS storage.objects (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	bucket_id text NOT NULL REFERENCES storage.buckets (id),
+	name text NOT NULL,
+	owner uuid,
+	metadata jsonb,
+	path_tokens text[] GENERATED ALWAYS AS (string_to_array(name, '/')) STORED,
+	version text,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now(),
+	last_accessed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS bucketid_objname ON storage.objects (bucket_id, name);
+CREATE INDEX IF NOT EXISTS name_prefix_search ON storage.objects (name text_pattern_ops);
+
+ALTER TABLE storage.buckets ENABLE ROW LEVEL SECURITY;
+ALTER TABLE storage.objects ENABLE ROW LEVEL SECURITY;
+
+-- Helpers used by typical Supabase storage policies, e.g.
+-- (storage.foldername(name))[1] = auth.uid()::text
+CREATE OR REPLACE FUNCTION storage.foldername(name text) RETURNS text[]
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT (string_to_array(name, '/'))[1:array_length(string_to_array(name, '/'), 1) - 1]
+$$;
+
+CREATE OR REPLACE FUNCTION storage.filename(name text) RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT (string_to_array(name, '/'))[array_length(string_to_array(name, '/'), 1)]
+$$;
+
+CREATE OR REPLACE FUNCTION storage.extension(name text) RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT reverse(split_part(reverse(storage.filename(name)), '.', 1))
+$$;
+
+-- Grant the Supabase API roles access where they exist; RLS decides what
+-- they can actually see
+DO $$
+DECLARE
+	r text;
+BEGIN
+	FOREACH r IN ARRAY ARRAY['anon', 'authenticated', 'service_role'] LOOP
+		IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = r) THEN
+			EXECUTE format('GRANT USAGE ON SCHEMA storage TO %I', r);
+			EXECUTE format('GRANT ALL ON storage.buckets, storage.objects TO %I', r);
+		END IF;
+	END LOOP;
+END;
+$$;
+`
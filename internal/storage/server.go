@@ -0,0 +1,295 @@
+// Package storage implements a Supabase-compatible Storage API.
+//
+// Buckets and object metadata live in the storage schema (storage.buckets
+// and storage.objects, as on Supabase), and object data is kept on the
+// local filesystem under the configured root, one file per object ID.
+// Metadata queries run with the caller's JWT claims and role, so RLS
+// policies written for Supabase Storage apply unchanged.
+//
+// Routes are relative to /storage/v1 and match what supabase-js calls:
+//
+//	GET    /bucket                          list buckets
+//	POST   /bucket                          create bucket
+//	GET    /bucket/{id}                     get bucket
+//	PUT    /bucket/{id}                     update bucket
+//	POST   /bucket/{id}/empty               delete every object in a bucket
+//	DELETE /bucket/{id}                     delete an empty bucket
+//	POST   /object/{bucket}/{path}          upload (x-upsert: true to overwrite)
+//	PUT    /object/{bucket}/{path}          replace an existing object
+//	GET    /object/{bucket}/{path}          download (also /object/authenticated/...)
+//	GET    /object/public/{bucket}/{path}   download from a public bucket
+//	DELETE /object/{bucket}/{path}          delete an object
+//	DELETE /object/{bucket}                 delete objects {"prefixes": [...]}
+//	POST   /object/list/{bucket}            list objects and folders under a prefix
+//	POST   /object/move                     move or rename an object
+//	POST   /object/copy                     copy an object
+//	POST   /object/sign/{bucket}/{path}     create a signed download URL
+//	POST   /object/sign/{bucket}            create signed download URLs
+//	GET    /object/sign/{bucket}/{path}     download with a signed URL token
+//	POST   /object/upload/sign/{bucket}/{path}  create a signed upload URL
+//	PUT    /object/upload/sign/{bucket}/{path}  upload with a signed URL token
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/markb/supalite/internal/rls"
+)
+
+// DefaultFileSizeLimit is the largest upload accepted unless configured
+// otherwise, matching Supabase's default of 50MB.
+const DefaultFileSizeLimit = 50 * 1024 * 1024
+
+// PostgresConnector lends pooled connections to the database.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresConnector interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+	AcquireAs(ctx context.Context, role string) (*pgxpool.Conn, error)
+}
+
+// TokenVerifier verifies API keys and user access tokens.
+//
+// keys.Manager satisfies this interface.
+type TokenVerifier interface {
+	VerifyToken(tokenString string) (jwt.Token, error)
+}
+
+// Config holds the configuration for the storage server.
+type Config struct {
+	Database      PostgresConnector // Database holding the storage schema
+	Verifier      TokenVerifier     // Verifies apikey and Authorization values
+	UserJWTSecret string            // GoTrue's HS256 secret, for user access tokens
+	SigningSecret string            // Signs signed URL tokens
+	Root          string            // Directory holding object data
+	FileSizeLimit int64             // Largest upload in bytes (default: 50MB)
+}
+
+// Server serves the storage API.
+type Server struct {
+	config Config
+	router *chi.Mux
+}
+
+// NewServer creates a new storage server.
+func NewServer(cfg Config) *Server {
+	if cfg.FileSizeLimit <= 0 {
+		cfg.FileSizeLimit = DefaultFileSizeLimit
+	}
+
+	s := &Server{
+		config: cfg,
+		router: chi.NewRouter(),
+	}
+	s.setupRoutes()
+	return s
+}
+
+// Init creates the storage tables and the data directory.
+func (s *Server) Init(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Join(s.config.Root, uploadsDir), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	conn, err := s.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("failed to create storage schema: %w", err)
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler for the storage API, relative to
+// /storage/v1.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+func (s *Server) setupRoutes() {
+	r := s.router
+
+	r.Get("/bucket", s.handleListBuckets)
+	r.Post("/bucket", s.handleCreateBucket)
+	r.Get("/bucket/{bucket}", s.handleGetBucket)
+	r.Put("/bucket/{bucket}", s.handleUpdateBucket)
+	r.Post("/bucket/{bucket}/empty", s.handleEmptyBucket)
+	r.Delete("/bucket/{bucket}", s.handleDeleteBucket)
+
+	r.Post("/object/list/{bucket}", s.handleListObjects)
+	r.Post("/object/move", s.handleMoveObject)
+	r.Post("/object/copy", s.handleCopyObject)
+
+	r.Post("/object/sign/{bucket}", s.handleSignURLs)
+	r.Post("/object/sign/{bucket}/*", s.handleSignURL)
+	r.Get("/object/sign/{bucket}/*", s.handleSignedDownload)
+	r.Head("/object/sign/{bucket}/*", s.handleSignedDownload)
+	r.Post("/object/upload/sign/{bucket}/*", s.handleSignUploadURL)
+	r.Put("/object/upload/sign/{bucket}/*", s.handleSignedUpload)
+
+	r.Get("/object/public/{bucket}/*", s.handlePublicDownload)
+	r.Head("/object/public/{bucket}/*", s.handlePublicDownload)
+	r.Get("/object/authenticated/{bucket}/*", s.handleDownload)
+	r.Head("/object/authenticated/{bucket}/*", s.handleDownload)
+
+	r.Post("/object/{bucket}/*", s.handleUpload)
+	r.Put("/object/{bucket}/*", s.handleUpdate)
+	r.Get("/object/{bucket}/*", s.handleDownload)
+	r.Head("/object/{bucket}/*", s.handleDownload)
+	r.Delete("/object/{bucket}/*", s.handleDeleteObject)
+	r.Delete("/object/{bucket}", s.handleDeleteObjects)
+}
+
+// apiError is the error body returned by Supabase Storage.
+type apiError struct {
+	StatusCode string `json:"statusCode"`
+	Error      string `json:"error"`
+	Message    string `json:"message"`
+}
+
+// writeError writes a storage API error.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{StatusCode: fmt.Sprint(status), Error: code, Message: message})
+}
+
+// writeJSON writes a successful JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// claims returns the verified claims of the request's Authorization
+// bearer token, falling back to the apikey header.
+func (s *Server) claims(r *http.Request) (map[string]interface{}, error) {
+	token := r.Header.Get("apikey")
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		token = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	if token == "" {
+		return nil, errors.New("missing authorization")
+	}
+
+	_, err := s.config.Verifier.VerifyToken(token)
+	if err != nil && s.config.UserJWTSecret != "" {
+		_, err = jwt.ParseString(token, jwt.WithKey(jwa.HS256, []byte(s.config.UserJWTSecret)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the payload as sent, so numeric claims such as exp reach
+	// request.jwt.claims as numbers
+	parts := strings.Split(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authorize returns the request's claims, writing a 403 if they are
+// missing or invalid.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	claims, err := s.claims(r)
+	if err != nil {
+		writeError(w, http.StatusForbidden, "Unauthorized", "invalid or missing authorization: "+err.Error())
+		return nil, false
+	}
+	return claims, true
+}
+
+// begin starts a transaction running as the role and claims of the
+// caller, so RLS policies on the storage tables apply. service_role and a
+// nil claims map run as the connecting superuser; the latter is for access
+// already authorized by a public bucket or a signed URL.
+func (s *Server) begin(ctx context.Context, conn *pgxpool.Conn, claims map[string]interface{}) (pgx.Tx, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// service_role bypasses RLS, as on Supabase
+	if claims == nil || rls.RoleForClaims(claims) == rls.RoleServiceRole {
+		return tx, nil
+	}
+
+	settings, err := rls.Settings(claims)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	for _, setting := range settings {
+		if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", setting.Name, setting.Value); err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
+	}
+
+	role := rls.RoleForClaims(claims)
+	if _, err := tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize()); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// connect borrows a pooled database connection for the caller's role,
+// writing a 500 on failure. Access without claims (public buckets and
+// signed URLs) is anonymous traffic and uses the anon role's connections.
+func (s *Server) connect(w http.ResponseWriter, r *http.Request, claims map[string]interface{}) (*pgxpool.Conn, bool) {
+	conn, err := s.config.Database.AcquireAs(r.Context(), rls.RoleForClaims(claims))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", fmt.Sprintf("database connection error: %v", err))
+		return nil, false
+	}
+	return conn, true
+}
+
+// pathParams returns the bucket and object name of a /{bucket}/* route.
+func pathParams(r *http.Request) (bucket, name string) {
+	bucket = chi.URLParam(r, "bucket")
+	name = chi.URLParam(r, "*")
+
+	// chi routes on the escaped path when it differs from the decoded one
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(bucket); err == nil {
+			bucket = unescaped
+		}
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+	return bucket, name
+}
+
+// ownerID returns the sub claim if it is a UUID, for the owner column.
+func ownerID(claims map[string]interface{}) *string {
+	sub, _ := claims["sub"].(string)
+	if !uuidPattern.MatchString(sub) {
+		return nil
+	}
+	return &sub
+}
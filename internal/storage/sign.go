@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// signedUploadExpiry is how long a signed upload URL is valid, as on
+// Supabase.
+const signedUploadExpiry = 2 * time.Hour
+
+// urlClaims are the claims of a signed URL token. URL is "bucket/name".
+type urlClaims struct {
+	URL    string  `json:"url"`
+	Owner  *string `json:"owner,omitempty"`
+	Upsert bool    `json:"upsert,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// signToken creates a signed URL token for an object.
+func (s *Server) signToken(claims urlClaims, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiresIn))
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.SigningSecret))
+}
+
+// verifyToken checks a signed URL token against the object it is used for.
+func (s *Server) verifyToken(token, bucketID, name string) (*urlClaims, error) {
+	var claims urlClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.config.SigningSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.URL != bucketID+"/"+name {
+		return nil, errors.New("token is not valid for this object")
+	}
+	return &claims, nil
+}
+
+// signRequest is the body of signed URL requests.
+type signRequest struct {
+	ExpiresIn int      `json:"expiresIn"`
+	Paths     []string `json:"paths"`
+}
+
+// signedURLPath returns the path of a signed download URL, relative to
+// /storage/v1.
+func signedURLPath(bucketID, name, token string) string {
+	return fmt.Sprintf("/object/sign/%s/%s?token=%s", bucketID, name, token)
+}
+
+// handleSignURL creates a signed download URL for an object the caller
+// can read.
+//
+// POST /object/sign/{bucket}/{path}
+//
+// Request body:
+//
+//	{"expiresIn": 60}
+//
+// Returns {"signedURL": "/object/sign/<bucket>/<path>?token=..."}.
+func (s *Server) handleSignURL(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+	ctx := r.Context()
+
+	var req signRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ExpiresIn <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "expiresIn must be a positive number of seconds")
+		return
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM storage.objects WHERE bucket_id = $1 AND name = $2)", bucketID, name).Scan(&exists)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found")
+		return
+	}
+
+	token, err := s.signToken(urlClaims{URL: bucketID + "/" + name}, time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"signedURL": signedURLPath(bucketID, name, token)})
+}
+
+// signedURLResult is one entry of a multiple signed URL response.
+type signedURLResult struct {
+	Error     *string `json:"error"`
+	Path      string  `json:"path"`
+	SignedURL *string `json:"signedURL"`
+}
+
+// handleSignURLs creates signed download URLs for several objects.
+//
+// POST /object/sign/{bucket}
+//
+// Request body:
+//
+//	{"expiresIn": 60, "paths": ["a.png", "folder/b.png"]}
+func (s *Server) handleSignURLs(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, _ := pathParams(r)
+	ctx := r.Context()
+
+	var req signRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ExpiresIn <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "expiresIn must be a positive number of seconds")
+		return
+	}
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "SELECT name FROM storage.objects WHERE bucket_id = $1 AND name = ANY($2)", bucketID, req.Paths)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	visible := make(map[string]bool, len(names))
+	for _, name := range names {
+		visible[name] = true
+	}
+
+	results := make([]signedURLResult, 0, len(req.Paths))
+	for _, name := range req.Paths {
+		result := signedURLResult{Path: name}
+		if !visible[name] {
+			message := "Either the object does not exist or you do not have access to it"
+			result.Error = &message
+		} else {
+			token, err := s.signToken(urlClaims{URL: bucketID + "/" + name}, time.Duration(req.ExpiresIn)*time.Second)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "internal", err.Error())
+				return
+			}
+			url := signedURLPath(bucketID, name, token)
+			result.SignedURL = &url
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleSignedDownload serves an object with a signed URL token.
+//
+// GET /object/sign/{bucket}/{path}?token=...
+func (s *Server) handleSignedDownload(w http.ResponseWriter, r *http.Request) {
+	bucketID, name := pathParams(r)
+	if _, err := s.verifyToken(r.URL.Query().Get("token"), bucketID, name); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidSignature", fmt.Sprintf("invalid signed URL: %v", err))
+		return
+	}
+	s.serveObject(w, r, nil, bucketID, name)
+}
+
+// handleSignUploadURL creates a URL that lets anyone holding it upload one
+// object, with the caller's permissions checked now rather than at upload.
+//
+// POST /object/upload/sign/{bucket}/{path}
+//
+// The x-upsert header allows the upload to replace an existing object.
+// Returns {"url": "/object/upload/sign/<bucket>/<path>?token=...", "token": "..."}.
+func (s *Server) handleSignUploadURL(w http.ResponseWriter, r *http.Request) {
+	claims, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	bucketID, name := pathParams(r)
+	ctx := r.Context()
+	if !validObjectName(name) {
+		writeError(w, http.StatusBadRequest, "Invalid Input", "Invalid key: "+name)
+		return
+	}
+	upsert := r.Header.Get("x-upsert") == "true"
+	owner := ownerID(claims)
+
+	conn, ok := s.connect(w, r, claims)
+	if !ok {
+		return
+	}
+	defer conn.Release()
+
+	tx, err := s.begin(ctx, conn, claims)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Check the caller could upload by trying it and rolling back
+	query := "INSERT INTO storage.objects (bucket_id, name, owner) VALUES ($1, $2, $3)"
+	if upsert {
+		query += " ON CONFLICT (bucket_id, name) DO UPDATE SET owner = EXCLUDED.owner"
+	}
+	if _, err := tx.Exec(ctx, query, bucketID, name, owner); err != nil {
+		writeTransferError(w, err)
+		return
+	}
+
+	token, err := s.signToken(urlClaims{URL: bucketID + "/" + name, Owner: owner, Upsert: upsert}, signedUploadExpiry)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"url":   fmt.Sprintf("/object/upload/sign/%s/%s?token=%s", bucketID, name, token),
+		"token": token,
+	})
+}
+
+// handleSignedUpload uploads an object with a signed upload URL token.
+//
+// PUT /object/upload/sign/{bucket}/{path}?token=...
+func (s *Server) handleSignedUpload(w http.ResponseWriter, r *http.Request) {
+	bucketID, name := pathParams(r)
+	claims, err := s.verifyToken(r.URL.Query().Get("token"), bucketID, name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidSignature", fmt.Sprintf("invalid signed URL: %v", err))
+		return
+	}
+
+	mode := uploadCreate
+	if claims.Upsert {
+		mode = uploadUpsert
+	}
+	s.storeObject(w, r, nil, claims.Owner, bucketID, name, mode)
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignedURLToken(t *testing.T) {
+	s := &Server{config: Config{SigningSecret: "test-secret"}}
+	owner := "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+	token, err := s.signToken(urlClaims{URL: "avatars/me.png", Owner: &owner, Upsert: true}, time.Minute)
+	if err != nil {
+		t.Fatalf("signToken() failed: %v", err)
+	}
+
+	claims, err := s.verifyToken(token, "avatars", "me.png")
+	if err != nil {
+		t.Fatalf("verifyToken() failed: %v", err)
+	}
+	if !claims.Upsert || claims.Owner == nil || *claims.Owner != owner {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := s.verifyToken(token, "avatars", "other.png"); err == nil {
+		t.Error("verifyToken() accepted a token for another object")
+	}
+
+	other := &Server{config: Config{SigningSecret: "other-secret"}}
+	if _, err := other.verifyToken(token, "avatars", "me.png"); err == nil {
+		t.Error("verifyToken() accepted a token signed with another secret")
+	}
+
+	expired, err := s.signToken(urlClaims{URL: "avatars/me.png"}, -time.Minute)
+	if err != nil {
+		t.Fatalf("signToken() failed: %v", err)
+	}
+	if _, err := s.verifyToken(expired, "avatars", "me.png"); err == nil {
+		t.Error("verifyToken() accepted an expired token")
+	}
+}
@@ -0,0 +1,186 @@
+// Package tracing exports OpenTelemetry traces over OTLP/HTTP. Until Setup
+// is called, spans started through it are no-ops, so instrumentation can
+// stay in place when tracing is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config selects where traces are sent.
+type Config struct {
+	Endpoint    string  // OTLP/HTTP collector URL, e.g. "http://localhost:4318"
+	ServiceName string  // Optional: service.name of the spans (default "supalite")
+	SampleRatio float64 // Optional: share of new traces recorded, 0-1 (default 1)
+}
+
+// tracesPath is where collectors receive OTLP/HTTP traces.
+const tracesPath = "/v1/traces"
+
+// Setup installs the global tracer provider, exporting to cfg.Endpoint,
+// and the W3C trace context propagator. Incoming traceparent headers are
+// honored, so a sampled caller's traces are always recorded. The returned
+// function flushes buffered spans and stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	endpoint, err := endpointURL(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "supalite"
+	}
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// endpointURL returns the URL traces are posted to: endpoint, with the
+// standard traces path added when it has no path of its own.
+func endpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid tracing endpoint %q: must be an http(s) URL", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = tracesPath
+	}
+	return u.String(), nil
+}
+
+// Tracer returns supalite's tracer.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/markb/supalite")
+}
+
+// Extract returns ctx with the trace context carried by headers, if any.
+func Extract(ctx context.Context, headers http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(headers))
+}
+
+// RecordError marks span as failed with err.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Transport wraps base so each request gets a client span, and carries the
+// trace context to the server in a traceparent header.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(r.Context(), r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.full", r.URL.Redacted()),
+			attribute.String("server.address", r.URL.Host),
+		))
+	defer span.End()
+
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// QueryTracer is a pgx.QueryTracer recording a span for each query, as a
+// child of the span in the query's context.
+type QueryTracer struct{}
+
+var _ pgx.QueryTracer = QueryTracer{}
+
+// maxStatementLength bounds the statement recorded on a span.
+const maxStatementLength = 2048
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	statement := data.SQL
+	if len(statement) > maxStatementLength {
+		statement = statement[:maxStatementLength] + "..."
+	}
+	ctx, _ = Tracer().Start(ctx, queryName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.query.text", statement),
+		))
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		RecordError(span, data.Err)
+	} else {
+		span.SetAttributes(attribute.Int64("db.response.returned_rows", data.CommandTag.RowsAffected()))
+	}
+	span.End()
+}
+
+// queryName names a query's span after its first keyword, e.g. "SELECT",
+// since statements are too long and varied to group spans by.
+func queryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+	// Skip leading comments
+	for strings.HasPrefix(sql, "--") || strings.HasPrefix(sql, "/*") {
+		end, skip := strings.Index(sql, "\n"), 1
+		if strings.HasPrefix(sql, "/*") {
+			end, skip = strings.Index(sql, "*/"), 2
+		}
+		if end < 0 {
+			return "SQL"
+		}
+		sql = strings.TrimSpace(sql[end+skip:])
+	}
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	if keyword := strings.ToUpper(strings.TrimRight(fields[0], ";(")); keyword != "" {
+		return keyword
+	}
+	return "SQL"
+}
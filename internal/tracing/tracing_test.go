@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEndpointURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:4318":             "http://localhost:4318/v1/traces",
+		"https://otel.example.com/":         "https://otel.example.com/v1/traces",
+		"http://localhost:4318/custom/path": "http://localhost:4318/custom/path",
+	}
+	for endpoint, want := range tests {
+		if got, err := endpointURL(endpoint); err != nil || got != want {
+			t.Errorf("endpointURL(%q) = %q, %v, want %q", endpoint, got, err, want)
+		}
+	}
+	for _, endpoint := range []string{"localhost:4318", "grpc://localhost:4317", ""} {
+		if _, err := endpointURL(endpoint); err == nil {
+			t.Errorf("endpointURL(%q) should fail", endpoint)
+		}
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	tests := map[string]string{
+		"select * from todos":                         "SELECT",
+		"  WITH t AS (SELECT 1) SELECT * FROM t":      "WITH",
+		"-- list todos\nSELECT * FROM todos":          "SELECT",
+		"/* supalite */ insert into todos values (1)": "INSERT",
+		"begin;":          "BEGIN",
+		"":                "SQL",
+		"/* unterminated": "SQL",
+	}
+	for sql, want := range tests {
+		if got := queryName(sql); got != want {
+			t.Errorf("queryName(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var traceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, backend.URL+"/token", nil)
+	resp, err := (&http.Client{Transport: Transport(http.DefaultTransport)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	client := spans[0]
+	if client.Name() != "POST /token" || client.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("client span = %q, parent %v", client.Name(), client.Parent().SpanID())
+	}
+	if want := client.SpanContext().SpanID().String(); len(traceparent) != 55 || traceparent[36:52] != want {
+		t.Errorf("traceparent = %q, want the client span %s", traceparent, want)
+	}
+	if client.Status().Code.String() != "Error" {
+		t.Errorf("status = %v, want Error for a 502", client.Status())
+	}
+}
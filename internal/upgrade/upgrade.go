@@ -0,0 +1,261 @@
+// Package upgrade moves a supalite data directory to another PostgreSQL
+// major version.
+//
+// A cluster can only be opened by the major version that created it, so
+// the upgrade goes through a backup: the old cluster is started with its
+// own version and every database is dumped, along with the roles, the new
+// version's initdb creates a fresh cluster, and the dumps are restored
+// into it. The old cluster is moved aside rather than deleted, and the
+// dumps are kept, so nothing is lost if the upgrade or the application on
+// the new version fails.
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/markb/supalite/internal/backup"
+	"github.com/markb/supalite/internal/pg"
+)
+
+// Options describes the data directory to upgrade and the version to
+// upgrade it to.
+type Options struct {
+	DataDir  string
+	To       string // Full PostgreSQL version, e.g. "17.5.0" (see pg.ResolveVersion)
+	Port     uint16 // Port the clusters listen on while upgrading; the server must be stopped
+	Username string
+	Password string
+	Database string // The database supalite serves; every other one is upgraded too
+
+	// Collation for the new cluster, as the old one was created with
+	Locale    string // Optional
+	ICULocale string // Optional
+
+	// Progress is told about each step as it starts
+	Progress func(step string) // Optional
+}
+
+// Result reports a finished upgrade.
+type Result struct {
+	From       string   // Old full version
+	To         string   // New full version
+	Databases  []string // Databases carried over
+	OldCluster string   // Where the old cluster was moved, to remove once the new one is trusted
+	BackupDir  string   // Where the safety backup was written
+}
+
+// Run upgrades the cluster in opts.DataDir to opts.To. It fails without
+// changing the data directory if the cluster isn't older than opts.To or
+// is in use. When the new cluster can't be created or restored, the old
+// one is moved back.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	clusterDir := pg.ClusterPath(opts.DataDir)
+	have, err := pg.ClusterVersion(opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if have == "" {
+		return nil, fmt.Errorf("%s has no PostgreSQL cluster to upgrade; a new one is created with pg_version", opts.DataDir)
+	}
+	if err := checkNewer(have, pg.MajorVersion(opts.To)); err != nil {
+		return nil, err
+	}
+	if postmaster, err := pg.ReadPostmaster(clusterDir); err == nil && postmaster.Running() {
+		return nil, fmt.Errorf("PostgreSQL is running on %s (pid %d); stop the server first", opts.DataDir, postmaster.PID)
+	}
+	from, err := pg.ResolveVersion(have)
+	if err != nil {
+		return nil, err
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	result := &Result{
+		From:       from,
+		To:         opts.To,
+		OldCluster: filepath.Join(opts.DataDir, "data-pg"+have),
+		BackupDir:  filepath.Join(opts.DataDir, "backups", "upgrade-"+stamp),
+	}
+	if _, err := os.Stat(result.OldCluster); err == nil {
+		return nil, fmt.Errorf("%s already exists, from an earlier upgrade; remove it first", result.OldCluster)
+	}
+	// The backup holds password hashes and keys, so keep it private
+	if err := os.MkdirAll(result.BackupDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the backup directory: %w", err)
+	}
+
+	// 1. Back up the old cluster, started with the version that created it
+	progress(fmt.Sprintf("backing up the PostgreSQL %s cluster to %s", from, result.BackupDir))
+	databases, err := dumpCluster(ctx, opts, from, result.BackupDir)
+	if err != nil {
+		os.RemoveAll(result.BackupDir)
+		return nil, fmt.Errorf("backup failed, nothing was changed: %w", err)
+	}
+	result.Databases = databases
+
+	// 2. Keep the old cluster aside, so the new one can take its place
+	if err := os.Rename(clusterDir, result.OldCluster); err != nil {
+		return nil, fmt.Errorf("failed to move the old cluster aside: %w", err)
+	}
+
+	// 3. Create the new cluster and restore into it
+	progress(fmt.Sprintf("creating a PostgreSQL %s cluster and restoring into it", opts.To))
+	if err := restoreCluster(ctx, opts, result); err != nil {
+		if rollbackErr := rollback(clusterDir, result.OldCluster); rollbackErr != nil {
+			return nil, fmt.Errorf("upgrade failed: %w; moving the old cluster back from %s also failed: %v", err, result.OldCluster, rollbackErr)
+		}
+		return nil, fmt.Errorf("upgrade failed, the old cluster was put back: %w", err)
+	}
+	return result, nil
+}
+
+// checkNewer refuses an upgrade to the same or an older major version:
+// pg_dump output only restores into the same or a newer one.
+func checkNewer(have, want string) error {
+	h, err := strconv.Atoi(have)
+	if err != nil {
+		return fmt.Errorf("unrecognized cluster version %q", have)
+	}
+	w, err := strconv.Atoi(want)
+	if err != nil {
+		return fmt.Errorf("unrecognized PostgreSQL version %q", want)
+	}
+	switch {
+	case w == h:
+		return fmt.Errorf("the cluster is already PostgreSQL %s", have)
+	case w < h:
+		return fmt.Errorf("the cluster is PostgreSQL %s; downgrading to %s isn't supported, restore a backup into a new data directory instead", have, want)
+	}
+	return nil
+}
+
+// dumpCluster starts the cluster with version and writes the roles to
+// globals.sql and each database to <name>.dump in dir. It returns the
+// databases dumped.
+func dumpCluster(ctx context.Context, opts Options, version, dir string) ([]string, error) {
+	db, stop, err := startCluster(ctx, opts, version)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY datname")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	databases, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	target, err := backup.TargetFor(ctx, conn, opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := backup.DumpGlobals(ctx, target, filepath.Join(dir, "globals.sql")); err != nil {
+		return nil, err
+	}
+	for _, name := range databases {
+		target.Database = name
+		out := filepath.Join(dir, name+".dump")
+		if err := backup.Dump(ctx, target, backup.DumpOptions{Format: backup.FormatCustom, Out: out, KeepOwners: true}); err != nil {
+			return nil, fmt.Errorf("database %s: %w", name, err)
+		}
+	}
+	return databases, nil
+}
+
+// restoreCluster creates a cluster with the new version and loads the
+// backup written by dumpCluster into it.
+func restoreCluster(ctx context.Context, opts Options, result *Result) error {
+	db, stop, err := startCluster(ctx, opts, opts.To)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	conn, err := db.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	target, err := backup.TargetFor(ctx, conn, opts.DataDir)
+	if err != nil {
+		return err
+	}
+	if err := backup.LoadGlobals(ctx, target, filepath.Join(result.BackupDir, "globals.sql")); err != nil {
+		return fmt.Errorf("failed to restore roles: %w", err)
+	}
+
+	for _, name := range result.Databases {
+		var exists bool
+		if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			if _, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+				return fmt.Errorf("failed to create database %s: %w", name, err)
+			}
+		}
+		target.Database = name
+		if err := backup.RestoreOwned(ctx, target, filepath.Join(result.BackupDir, name+".dump")); err != nil {
+			return fmt.Errorf("database %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// startCluster starts the cluster in opts.DataDir with version, from a
+// runtime directory of its own: embedded-postgres extracts each version's
+// binaries there. stop stops it and removes the runtime directory.
+func startCluster(ctx context.Context, opts Options, version string) (db *pg.EmbeddedDatabase, stop func(), err error) {
+	runtimePath, err := os.MkdirTemp("", "supalite-upgrade-")
+	if err != nil {
+		return nil, nil, err
+	}
+	db = pg.NewEmbeddedDatabase(pg.Config{
+		Port:        opts.Port,
+		Username:    opts.Username,
+		Password:    opts.Password,
+		Database:    opts.Database,
+		DataDir:     opts.DataDir,
+		Version:     version,
+		RuntimePath: runtimePath,
+		Locale:      opts.Locale,
+		ICULocale:   opts.ICULocale,
+	})
+	if err := db.Start(ctx); err != nil {
+		os.RemoveAll(runtimePath)
+		return nil, nil, fmt.Errorf("failed to start PostgreSQL %s: %w", version, err)
+	}
+	return db, func() {
+		db.Stop()
+		os.RemoveAll(runtimePath)
+	}, nil
+}
+
+// rollback replaces the new cluster, if any, with the old one.
+func rollback(clusterDir, oldCluster string) error {
+	if err := os.RemoveAll(clusterDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(oldCluster, clusterDir)
+}
@@ -0,0 +1,68 @@
+package upgrade
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/markb/supalite/internal/pg"
+)
+
+func TestCheckNewer(t *testing.T) {
+	tests := []struct {
+		have, want string
+		wantErr    string
+	}{
+		{"16", "17", ""},
+		{"15", "17", ""},
+		{"16", "16", "already PostgreSQL 16"},
+		{"17", "16", "downgrading"},
+		{"x", "17", "unrecognized"},
+	}
+	for _, tt := range tests {
+		err := checkNewer(tt.have, tt.want)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("checkNewer(%s, %s) = %v, want %q", tt.have, tt.want, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRun_RefusesBeforeChangingAnything(t *testing.T) {
+	dataDir := t.TempDir()
+	opts := Options{DataDir: dataDir, To: "17.5.0"}
+
+	if _, err := Run(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "no PostgreSQL cluster") {
+		t.Errorf("Run(no cluster) = %v", err)
+	}
+
+	clusterDir := pg.ClusterPath(dataDir)
+	if err := os.MkdirAll(clusterDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(clusterDir, "PG_VERSION"), []byte("17\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "already PostgreSQL 17") {
+		t.Errorf("Run(same version) = %v", err)
+	}
+
+	// A postmaster.pid naming a live process means the server is running
+	if err := os.WriteFile(filepath.Join(clusterDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pid := []byte(strings.Join([]string{strconv.Itoa(os.Getpid()), clusterDir, "0", "54329", ""}, "\n"))
+	if err := os.WriteFile(filepath.Join(clusterDir, "postmaster.pid"), pid, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "stop the server first") {
+		t.Errorf("Run(running) = %v", err)
+	}
+
+	entries, _ := os.ReadDir(dataDir)
+	if len(entries) != 1 || entries[0].Name() != "data" {
+		t.Errorf("data directory was changed: %v", entries)
+	}
+}
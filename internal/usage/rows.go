@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Rows counts the rows a request's SQL read and wrote.
+type Rows struct {
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+// Read returns the rows SELECTs returned.
+func (r *Rows) Read() int64 {
+	return r.read.Load()
+}
+
+// Written returns the rows INSERTs, UPDATEs, DELETEs, and MERGEs changed.
+func (r *Rows) Written() int64 {
+	return r.written.Load()
+}
+
+type rowsKey struct{}
+
+// WithRows returns a context whose statements' rows are counted in rows.
+func WithRows(ctx context.Context, rows *Rows) context.Context {
+	return context.WithValue(ctx, rowsKey{}, rows)
+}
+
+func rowsFromContext(ctx context.Context) *Rows {
+	rows, _ := ctx.Value(rowsKey{}).(*Rows)
+	return rows
+}
+
+// Tracer is a pgx.QueryTracer counting the rows of each statement in the
+// Rows of its context, if any, and passing it on to Next.
+type Tracer struct {
+	Next pgx.QueryTracer // Optional: e.g. tracing.QueryTracer
+}
+
+var _ pgx.QueryTracer = Tracer{}
+
+func (t Tracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if t.Next != nil {
+		ctx = t.Next.TraceQueryStart(ctx, conn, data)
+	}
+	return ctx
+}
+
+func (t Tracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if t.Next != nil {
+		t.Next.TraceQueryEnd(ctx, conn, data)
+	}
+	rows := rowsFromContext(ctx)
+	if rows == nil || data.Err != nil {
+		return
+	}
+	tag := data.CommandTag
+	switch {
+	case tag.Select():
+		rows.read.Add(tag.RowsAffected())
+	case tag.Insert(), tag.Update(), tag.Delete(), strings.HasPrefix(tag.String(), "MERGE"):
+		rows.written.Add(tag.RowsAffected())
+	}
+}
@@ -0,0 +1,549 @@
+// Package usage accounts for what API callers use, and enforces quotas on
+// it, for deployments serving several customers from one instance.
+//
+// Usage is counted per API key and for the project as a whole, in
+// calendar periods (a month or a day, in UTC): requests, rows read and
+// written by the SQL those requests ran, and response bytes. Stored
+// objects' total size is measured for the project. Counts are kept in
+// memory and added to admin.api_usage in the background, so they survive
+// restarts:
+//
+//	SELECT period_start, api_key, role, requests, rows_read, rows_written, egress_bytes
+//	FROM admin.api_usage ORDER BY period_start DESC, requests DESC;
+//
+// Soft limits only warn, once per period. Hard limits refuse requests
+// until the period ends: with 429, or with 507 for uploads once the
+// storage limit is reached. Limits apply to the project total, or with
+// PerKey to each API key on its own.
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/markb/supalite/internal/log"
+)
+
+// DefaultFlushInterval is how often counts are written to the database
+// and stored objects are measured.
+const DefaultFlushInterval = 10 * time.Second
+
+// Periods
+const (
+	PeriodMonth = "month"
+	PeriodDay   = "day"
+)
+
+// Metrics, as named in limits and events
+const (
+	MetricRequests     = "requests"
+	MetricRowsRead     = "rows_read"
+	MetricRowsWritten  = "rows_written"
+	MetricEgressBytes  = "egress_bytes"
+	MetricStorageBytes = "storage_bytes"
+)
+
+var logger = log.Component("usage")
+
+const schemaSQL = `
+CREATE SCHEMA IF NOT EXISTS admin;
+
+CREATE TABLE IF NOT EXISTS admin.api_usage (
+	period_start timestamptz NOT NULL,
+	api_key text NOT NULL,
+	role text NOT NULL DEFAULT '',
+	requests bigint NOT NULL DEFAULT 0,
+	rows_read bigint NOT NULL DEFAULT 0,
+	rows_written bigint NOT NULL DEFAULT 0,
+	egress_bytes bigint NOT NULL DEFAULT 0,
+	updated_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (period_start, api_key)
+);
+`
+
+// PostgresAcquirer lends the meter pooled connections, on which it loads
+// and adds to admin.api_usage and measures stored objects.
+//
+// pg.EmbeddedDatabase satisfies this interface.
+type PostgresAcquirer interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Counts is what a caller used.
+type Counts struct {
+	Requests    int64 `json:"requests"`
+	RowsRead    int64 `json:"rows_read"`
+	RowsWritten int64 `json:"rows_written"`
+	EgressBytes int64 `json:"egress_bytes"`
+}
+
+func (c *Counts) add(o Counts) {
+	c.Requests += o.Requests
+	c.RowsRead += o.RowsRead
+	c.RowsWritten += o.RowsWritten
+	c.EgressBytes += o.EgressBytes
+}
+
+// Limits caps usage per period. Zero is unlimited.
+type Limits struct {
+	Requests     int64 `json:"requests,omitempty"`
+	RowsRead     int64 `json:"rows_read,omitempty"`
+	RowsWritten  int64 `json:"rows_written,omitempty"`
+	EgressBytes  int64 `json:"egress_bytes,omitempty"`
+	StorageBytes int64 `json:"storage_bytes,omitempty"` // Always the project total
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Event is a soft or hard limit being reached.
+type Event struct {
+	Hard   bool
+	Key    string // The API key's ID, or "" for the project total
+	Metric string
+	Used   int64
+	Limit  int64
+}
+
+// Config holds the configuration for the meter.
+type Config struct {
+	Database      PostgresAcquirer // Filled in by the server
+	Period        string           // Optional: PeriodMonth (default) or PeriodDay
+	PerKey        bool             // Apply limits to each API key instead of the project total
+	Soft          Limits           // Optional: warn when reached
+	Hard          Limits           // Optional: refuse requests when reached
+	FlushInterval time.Duration    // Optional: default DefaultFlushInterval
+	OnLimit       func(Event)      // Optional: called once per period for each limit reached
+}
+
+// Exceeded is a hard limit a request is refused for.
+type Exceeded struct {
+	Metric     string
+	Used       int64
+	Limit      int64
+	RetryAfter time.Duration // Until the period ends
+}
+
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("%s quota of %d for this period reached", e.Metric, e.Limit)
+}
+
+// KeyUsage is one API key's usage.
+type KeyUsage struct {
+	Key  string `json:"key"`  // ID of the key, or "" for requests without a valid one
+	Role string `json:"role"` // The key's role, e.g. anon
+	Counts
+}
+
+// Report is the usage of the current period.
+type Report struct {
+	PeriodStart  time.Time  `json:"period_start"`
+	PeriodEnd    time.Time  `json:"period_end"`
+	Project      Counts     `json:"project"`
+	StorageBytes int64      `json:"storage_bytes"`
+	Keys         []KeyUsage `json:"keys"`
+	PerKey       bool       `json:"per_key"`
+	Soft         Limits     `json:"soft"`
+	Hard         Limits     `json:"hard"`
+}
+
+// Meter counts usage and checks it against the limits.
+type Meter struct {
+	config Config
+	now    func() time.Time
+
+	mu          sync.Mutex
+	periodStart time.Time
+	periodEnd   time.Time
+	project     Counts
+	keys        map[string]*KeyUsage     // This period's totals, by key ID
+	pending     map[pendingKey]*KeyUsage // Not yet written to the database
+	storage     int64
+	reached     map[string]bool // Limits already reported this period
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMeter creates a new meter.
+func NewMeter(cfg Config) *Meter {
+	if cfg.Period == "" {
+		cfg.Period = PeriodMonth
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	m := &Meter{config: cfg, now: time.Now}
+	m.startPeriod(m.now())
+	return m
+}
+
+// KeyID returns the ID usage of an API key is kept under: a short hash,
+// so keys themselves are never stored or shown.
+func KeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Start creates the usage table, loads the current period's counts, and
+// begins writing new ones.
+func (m *Meter) Start(ctx context.Context) error {
+	conn, err := m.config.Database.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, schemaSQL); err != nil {
+		return fmt.Errorf("failed to create usage table: %w", err)
+	}
+
+	m.mu.Lock()
+	periodStart := m.periodStart
+	m.mu.Unlock()
+	rows, err := conn.Query(ctx, `
+		SELECT api_key, role, requests, rows_read, rows_written, egress_bytes
+		FROM admin.api_usage WHERE period_start = $1`, periodStart)
+	if err != nil {
+		return fmt.Errorf("failed to load usage: %w", err)
+	}
+	loaded := map[string]*KeyUsage{}
+	for rows.Next() {
+		var u KeyUsage
+		if err := rows.Scan(&u.Key, &u.Role, &u.Requests, &u.RowsRead, &u.RowsWritten, &u.EgressBytes); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to load usage: %w", err)
+		}
+		loaded[u.Key] = &u
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load usage: %w", err)
+	}
+
+	m.mu.Lock()
+	for key, u := range loaded {
+		if total, ok := m.keys[key]; ok {
+			total.add(u.Counts)
+		} else {
+			m.keys[key] = u
+		}
+		m.project.add(u.Counts)
+	}
+	m.mu.Unlock()
+
+	m.measureStorage(ctx)
+
+	// The writer outlives the startup context
+	runCtx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(runCtx)
+	}()
+	return nil
+}
+
+// Stop writes the pending counts and stops.
+func (m *Meter) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.flush(ctx); err != nil {
+		logger.Warn("failed to write usage", "error", err)
+	}
+}
+
+// Allow returns the hard limit a request with key would exceed, or nil.
+// upload is set for requests that store objects, which the storage limit
+// refuses.
+func (m *Meter) Allow(key string, upload bool) *Exceeded {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollOver()
+
+	hard := m.config.Hard
+	if hard.IsZero() {
+		return nil
+	}
+	retryAfter := m.periodEnd.Sub(m.now())
+	if upload && hard.StorageBytes > 0 && m.storage >= hard.StorageBytes {
+		return &Exceeded{Metric: MetricStorageBytes, Used: m.storage, Limit: hard.StorageBytes, RetryAfter: retryAfter}
+	}
+
+	counts := m.project
+	if m.config.PerKey {
+		counts = Counts{}
+		if u, ok := m.keys[key]; ok {
+			counts = u.Counts
+		}
+	}
+	for _, c := range checks(counts, hard) {
+		if c.used >= c.limit {
+			return &Exceeded{Metric: c.metric, Used: c.used, Limit: c.limit, RetryAfter: retryAfter}
+		}
+	}
+	return nil
+}
+
+// Add counts a finished request made with key, whose role is role.
+func (m *Meter) Add(key, role string, c Counts) {
+	var events []Event
+
+	m.mu.Lock()
+	m.rollOver()
+	total, ok := m.keys[key]
+	if !ok {
+		total = &KeyUsage{Key: key}
+		m.keys[key] = total
+	}
+	pk := pendingKey{m.periodStart, key}
+	pending, ok := m.pending[pk]
+	if !ok {
+		pending = &KeyUsage{Key: key}
+		m.pending[pk] = pending
+	}
+	for _, u := range []*KeyUsage{total, pending} {
+		if role != "" {
+			u.Role = role
+		}
+		u.add(c)
+	}
+	m.project.add(c)
+
+	counts, eventKey := m.project, ""
+	if m.config.PerKey {
+		counts, eventKey = m.keys[key].Counts, key
+	}
+	events = append(events, m.reach(eventKey, counts, m.config.Soft, false)...)
+	events = append(events, m.reach(eventKey, counts, m.config.Hard, true)...)
+	m.mu.Unlock()
+
+	m.report(events)
+}
+
+// Report returns the usage of the current period, keys by requests made.
+func (m *Meter) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollOver()
+
+	report := Report{
+		PeriodStart:  m.periodStart,
+		PeriodEnd:    m.periodEnd,
+		Project:      m.project,
+		StorageBytes: m.storage,
+		Keys:         make([]KeyUsage, 0, len(m.keys)),
+		PerKey:       m.config.PerKey,
+		Soft:         m.config.Soft,
+		Hard:         m.config.Hard,
+	}
+	for _, u := range m.keys {
+		report.Keys = append(report.Keys, *u)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		if report.Keys[i].Requests != report.Keys[j].Requests {
+			return report.Keys[i].Requests > report.Keys[j].Requests
+		}
+		return report.Keys[i].Key < report.Keys[j].Key
+	})
+	return report
+}
+
+type check struct {
+	metric      string
+	used, limit int64
+}
+
+// checks pairs counts with the limits set on them.
+func checks(c Counts, l Limits) []check {
+	var result []check
+	for _, ch := range []check{
+		{MetricRequests, c.Requests, l.Requests},
+		{MetricRowsRead, c.RowsRead, l.RowsRead},
+		{MetricRowsWritten, c.RowsWritten, l.RowsWritten},
+		{MetricEgressBytes, c.EgressBytes, l.EgressBytes},
+	} {
+		if ch.limit > 0 {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+// reach returns the limits counts reached that weren't reported yet this
+// period. The caller holds m.mu.
+func (m *Meter) reach(key string, counts Counts, limits Limits, hard bool) []Event {
+	var events []Event
+	for _, c := range checks(counts, limits) {
+		if c.used < c.limit {
+			continue
+		}
+		id := fmt.Sprintf("%t/%s/%s", hard, key, c.metric)
+		if m.reached[id] {
+			continue
+		}
+		m.reached[id] = true
+		events = append(events, Event{Hard: hard, Key: key, Metric: c.metric, Used: c.used, Limit: c.limit})
+	}
+	return events
+}
+
+// report logs events and passes them on to OnLimit.
+func (m *Meter) report(events []Event) {
+	for _, e := range events {
+		msg := "usage soft limit reached"
+		if e.Hard {
+			msg = "usage hard limit reached, requests are refused until the period ends"
+		}
+		logger.Warn(msg, "metric", e.Metric, "used", e.Used, "limit", e.Limit, "key", e.Key)
+		if m.config.OnLimit != nil {
+			m.config.OnLimit(e)
+		}
+	}
+}
+
+// startPeriod resets the counts for the period holding now. The caller
+// holds m.mu, or has the meter to itself.
+func (m *Meter) startPeriod(now time.Time) {
+	now = now.UTC()
+	if m.config.Period == PeriodDay {
+		m.periodStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		m.periodEnd = m.periodStart.AddDate(0, 0, 1)
+	} else {
+		m.periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		m.periodEnd = m.periodStart.AddDate(0, 1, 0)
+	}
+	m.project = Counts{}
+	m.keys = map[string]*KeyUsage{}
+	m.reached = map[string]bool{}
+	if m.pending == nil {
+		m.pending = map[pendingKey]*KeyUsage{}
+	}
+}
+
+// rollOver starts a new period once the current one has ended. Counts of
+// the old one not yet written stay pending under it. The caller holds
+// m.mu.
+func (m *Meter) rollOver() {
+	if now := m.now(); !now.Before(m.periodEnd) {
+		m.startPeriod(now)
+	}
+}
+
+// pendingKey is what unwritten counts are kept under.
+type pendingKey struct {
+	periodStart time.Time
+	key         string
+}
+
+func (m *Meter) run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := m.flush(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to write usage", "error", err)
+		}
+		m.measureStorage(ctx)
+	}
+}
+
+// flush adds the pending counts to the usage table. Counts that fail to
+// be written stay pending.
+func (m *Meter) flush(ctx context.Context) error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[pendingKey]*KeyUsage{}
+	m.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	conn, err := m.config.Database.Acquire(ctx)
+	if err == nil {
+		defer conn.Release()
+	}
+	for pk, u := range pending {
+		if err == nil {
+			_, err = conn.Exec(ctx, `
+				INSERT INTO admin.api_usage (period_start, api_key, role, requests, rows_read, rows_written, egress_bytes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (period_start, api_key) DO UPDATE SET
+					role = coalesce(nullif(EXCLUDED.role, ''), api_usage.role),
+					requests = api_usage.requests + EXCLUDED.requests,
+					rows_read = api_usage.rows_read + EXCLUDED.rows_read,
+					rows_written = api_usage.rows_written + EXCLUDED.rows_written,
+					egress_bytes = api_usage.egress_bytes + EXCLUDED.egress_bytes,
+					updated_at = now()
+			`, pk.periodStart, pk.key, u.Role, u.Requests, u.RowsRead, u.RowsWritten, u.EgressBytes)
+			if err == nil {
+				continue
+			}
+		}
+
+		// Keep it for the next try
+		m.mu.Lock()
+		if again, ok := m.pending[pk]; ok {
+			again.add(u.Counts)
+			if again.Role == "" {
+				again.Role = u.Role
+			}
+		} else {
+			m.pending[pk] = u
+		}
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// measureStorage updates the total size of stored objects.
+func (m *Meter) measureStorage(ctx context.Context) {
+	conn, err := m.config.Database.Acquire(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Release()
+
+	var size int64
+	err = conn.QueryRow(ctx, "SELECT coalesce(sum((metadata->>'size')::bigint), 0)::bigint FROM storage.objects").Scan(&size)
+	if err != nil {
+		// No storage schema yet, e.g. in tests
+		return
+	}
+	m.mu.Lock()
+	m.storage = size
+	var events []Event
+	for _, hard := range []bool{false, true} {
+		limit := m.config.Soft.StorageBytes
+		if hard {
+			limit = m.config.Hard.StorageBytes
+		}
+		id := fmt.Sprintf("%t//%s", hard, MetricStorageBytes)
+		if limit > 0 && size >= limit && !m.reached[id] {
+			m.reached[id] = true
+			events = append(events, Event{Hard: hard, Metric: MetricStorageBytes, Used: size, Limit: limit})
+		}
+	}
+	m.mu.Unlock()
+	m.report(events)
+}
@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func newTestMeter(cfg Config, now *time.Time) *Meter {
+	m := NewMeter(cfg)
+	m.now = func() time.Time { return *now }
+	m.startPeriod(*now)
+	return m
+}
+
+func TestMeter_HardLimit(t *testing.T) {
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	var events []Event
+	m := newTestMeter(Config{
+		Soft:    Limits{Requests: 2},
+		Hard:    Limits{Requests: 3},
+		OnLimit: func(e Event) { events = append(events, e) },
+	}, &now)
+
+	for i := 0; i < 3; i++ {
+		if exceeded := m.Allow("a", false); exceeded != nil {
+			t.Fatalf("request %d refused: %v", i+1, exceeded)
+		}
+		m.Add("a", "anon", Counts{Requests: 1})
+	}
+	exceeded := m.Allow("b", false)
+	if exceeded == nil || exceeded.Metric != MetricRequests || exceeded.RetryAfter != time.Hour {
+		t.Fatalf("Allow() after 3 requests = %+v, want requests exceeded for an hour", exceeded)
+	}
+	if len(events) != 2 || events[0].Hard || !events[1].Hard {
+		t.Errorf("events = %+v, want soft then hard", events)
+	}
+
+	// A new month starts from zero
+	now = now.Add(time.Hour)
+	if exceeded := m.Allow("a", false); exceeded != nil {
+		t.Errorf("Allow() in the next period = %v", exceeded)
+	}
+	if report := m.Report(); report.Project.Requests != 0 || !report.PeriodStart.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Report() = %+v", report)
+	}
+	// The old period's counts are still to be written, under that period
+	if pending := m.pending[pendingKey{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "a"}]; pending == nil || pending.Requests != 3 {
+		t.Errorf("pending = %+v", m.pending)
+	}
+}
+
+func TestMeter_PerKey(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	m := newTestMeter(Config{Period: PeriodDay, PerKey: true, Hard: Limits{RowsRead: 100}}, &now)
+
+	m.Add("a", "anon", Counts{Requests: 1, RowsRead: 100})
+	m.Add("b", "anon", Counts{Requests: 1, RowsRead: 10})
+	if m.Allow("a", false) == nil {
+		t.Error("key a is allowed past its limit")
+	}
+	if exceeded := m.Allow("b", false); exceeded != nil {
+		t.Errorf("key b refused: %v", exceeded)
+	}
+
+	report := m.Report()
+	if report.Project.RowsRead != 110 || len(report.Keys) != 2 || report.Keys[0].Key != "a" {
+		t.Errorf("Report() = %+v", report)
+	}
+	if !report.PeriodEnd.Equal(time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PeriodEnd = %v", report.PeriodEnd)
+	}
+}
+
+func TestMeter_StorageLimit(t *testing.T) {
+	now := time.Now()
+	m := newTestMeter(Config{Hard: Limits{StorageBytes: 1000}}, &now)
+	m.storage = 1000
+
+	if exceeded := m.Allow("", true); exceeded == nil || exceeded.Metric != MetricStorageBytes {
+		t.Errorf("upload: Allow() = %+v, want storage exceeded", exceeded)
+	}
+	if exceeded := m.Allow("", false); exceeded != nil {
+		t.Errorf("read: Allow() = %v", exceeded)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var tracer Tracer
+	rows := &Rows{}
+	ctx := WithRows(context.Background(), rows)
+
+	for _, tag := range []string{"SELECT 3", "INSERT 0 2", "UPDATE 1", "DELETE 4", "MERGE 5", "CREATE TABLE"} {
+		qctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{})
+		tracer.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag(tag)})
+	}
+	if rows.Read() != 3 || rows.Written() != 12 {
+		t.Errorf("read %d, written %d, want 3 and 12", rows.Read(), rows.Written())
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	if KeyID("") != "" {
+		t.Error("KeyID(\"\") is not empty")
+	}
+	if id := KeyID("secret"); len(id) != 12 || id == KeyID("other") {
+		t.Errorf("KeyID(secret) = %q", id)
+	}
+}
@@ -0,0 +1,505 @@
+// Package verify checks a running instance against the Supabase client
+// libraries' expectations. It runs the requests supabase-js makes for
+// common operations (queries with filters, inserts and upserts, embedded
+// resources, RPC, sign-up and sign-in, storage uploads) and reports which
+// of them behave as they do on Supabase.
+//
+// The checks use fixture tables and a function, created with SetupSQL and
+// dropped with TeardownSQL, and a throwaway user and bucket that Run
+// deletes when it is done.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Result statuses
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip" // An earlier check it builds on failed
+)
+
+// Fixture names. They are prefixed so they can't clash with a project's
+// own tables.
+const (
+	authorsTable = "supalite_verify_authors"
+	booksTable   = "supalite_verify_books"
+	notesTable   = "supalite_verify_notes"
+	addFunction  = "supalite_verify_add"
+)
+
+// SetupSQL creates the fixtures: two tables related by a foreign key, a
+// table with row level security limiting each user to their own rows, and
+// a function. Existing fixtures from an interrupted run are replaced.
+var SetupSQL = TeardownSQL + `
+CREATE TABLE public.` + authorsTable + ` (
+	id bigint PRIMARY KEY,
+	name text NOT NULL
+);
+CREATE TABLE public.` + booksTable + ` (
+	id bigint PRIMARY KEY,
+	title text NOT NULL,
+	author_id bigint REFERENCES public.` + authorsTable + ` (id)
+);
+CREATE TABLE public.` + notesTable + ` (
+	id bigserial PRIMARY KEY,
+	body text NOT NULL,
+	owner uuid NOT NULL DEFAULT auth.uid()
+);
+ALTER TABLE public.` + notesTable + ` ENABLE ROW LEVEL SECURITY;
+CREATE POLICY own_notes ON public.` + notesTable + ` FOR ALL TO authenticated
+	USING (owner = auth.uid()) WITH CHECK (owner = auth.uid());
+CREATE FUNCTION public.` + addFunction + `(a int, b int) RETURNS int
+	LANGUAGE sql IMMUTABLE AS 'SELECT a + b';
+
+GRANT ALL ON public.` + authorsTable + `, public.` + booksTable + `, public.` + notesTable + ` TO anon, authenticated, service_role;
+GRANT USAGE ON SEQUENCE public.` + notesTable + `_id_seq TO authenticated, service_role;
+GRANT EXECUTE ON FUNCTION public.` + addFunction + `(int, int) TO anon, authenticated, service_role;
+`
+
+// TeardownSQL drops the fixtures.
+const TeardownSQL = `
+DROP TABLE IF EXISTS public.` + booksTable + `, public.` + authorsTable + `, public.` + notesTable + `;
+DROP FUNCTION IF EXISTS public.` + addFunction + `(int, int);
+`
+
+// Config selects the instance to check.
+type Config struct {
+	URL        string       // Base URL, e.g. "http://localhost:8080"
+	AnonKey    string       // The key clients use
+	ServiceKey string       // For the admin calls a test needs (confirming its user, creating a bucket)
+	Client     *http.Client // Optional: default has a 30s timeout
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	API      string        `json:"api"`  // rest, auth, or storage
+	Name     string        `json:"name"` // The supabase-js call checked
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	URL     string   `json:"url"`
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Skipped int      `json:"skipped"`
+}
+
+// OK reports whether every check passed.
+func (r *Report) OK() bool {
+	return r.Failed == 0 && r.Skipped == 0
+}
+
+// check is one step of the matrix. Checks run in order and share state
+// through the runner; one whose prerequisite failed is skipped.
+type check struct {
+	api   string
+	name  string
+	needs string // Name of an earlier check this one builds on
+	run   func(ctx context.Context, r *runner) error
+}
+
+// runner holds the state checks pass along: the signed-in user and the
+// storage bucket.
+type runner struct {
+	config Config
+	client *http.Client
+	suffix string // Makes the user's email and the bucket name unique
+
+	email, password string
+	userID          string
+	userToken       string
+	bucket          string
+}
+
+// Run runs every check against the instance and cleans up what they
+// created, except the fixtures from SetupSQL.
+func Run(ctx context.Context, cfg Config) *Report {
+	r := &runner{config: cfg, client: cfg.Client, suffix: randomSuffix()}
+	if r.client == nil {
+		r.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	r.config.URL = strings.TrimSuffix(cfg.URL, "/")
+	defer r.cleanup()
+
+	report := &Report{URL: r.config.URL}
+	outcome := map[string]string{}
+	for _, c := range checks {
+		result := Result{API: c.api, Name: c.name}
+		if c.needs != "" && outcome[c.needs] != StatusPass {
+			result.Status = StatusSkip
+			result.Error = fmt.Sprintf("needs %s", c.needs)
+		} else {
+			start := time.Now()
+			err := c.run(ctx, r)
+			result.Duration = time.Since(start)
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			} else {
+				result.Status = StatusPass
+			}
+		}
+		outcome[c.name] = result.Status
+
+		switch result.Status {
+		case StatusPass:
+			report.Passed++
+		case StatusFail:
+			report.Failed++
+		default:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+var checks = []check{
+	{api: "rest", name: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		var rows []map[string]any
+		err := r.rest(ctx, http.MethodPost, "/"+authorsTable, "", map[string]string{"Prefer": "return=representation"},
+			[]map[string]any{{"id": 1, "name": "Ada"}, {"id": 2, "name": "Grace"}}, http.StatusCreated, &rows)
+		if err != nil {
+			return err
+		}
+		return expectLen(rows, 2)
+	}},
+	{api: "rest", name: "upsert()", needs: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		var rows []map[string]any
+		err := r.rest(ctx, http.MethodPost, "/"+authorsTable, "", map[string]string{"Prefer": "resolution=merge-duplicates,return=representation"},
+			map[string]any{"id": 2, "name": "Grace Hopper"}, http.StatusCreated, &rows)
+		if err != nil {
+			return err
+		}
+		if err := expectLen(rows, 1); err != nil {
+			return err
+		}
+		return expectField(rows[0], "name", "Grace Hopper")
+	}},
+	{api: "rest", name: "select().in().ilike().order()", needs: "upsert()", run: func(ctx context.Context, r *runner) error {
+		var rows []map[string]any
+		err := r.rest(ctx, http.MethodGet, "/"+authorsTable+"?select=id,name&id=in.(1,2)&name=ilike.*hopper*&order=id.desc&limit=10", "", nil, nil, http.StatusOK, &rows)
+		if err != nil {
+			return err
+		}
+		if err := expectLen(rows, 1); err != nil {
+			return err
+		}
+		return expectField(rows[0], "id", float64(2))
+	}},
+	{api: "rest", name: "select({ count: 'exact', head: true })", needs: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		resp, err := r.do(ctx, http.MethodHead, "/rest/v1/"+authorsTable+"?select=*", r.config.AnonKey, map[string]string{"Prefer": "count=exact"}, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if got := resp.header.Get("Content-Range"); !strings.HasSuffix(got, "/2") {
+			return fmt.Errorf("Content-Range = %q, want a total of 2", got)
+		}
+		return nil
+	}},
+	{api: "rest", name: "eq().single()", needs: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		var row map[string]any
+		err := r.rest(ctx, http.MethodGet, "/"+authorsTable+"?select=*&id=eq.1", "", map[string]string{"Accept": "application/vnd.pgrst.object+json"}, nil, http.StatusOK, &row)
+		if err != nil {
+			return err
+		}
+		return expectField(row, "name", "Ada")
+	}},
+	{api: "rest", name: "update().eq()", needs: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		var rows []map[string]any
+		err := r.rest(ctx, http.MethodPatch, "/"+authorsTable+"?id=eq.1", "", map[string]string{"Prefer": "return=representation"},
+			map[string]any{"name": "Ada Lovelace"}, http.StatusOK, &rows)
+		if err != nil {
+			return err
+		}
+		if err := expectLen(rows, 1); err != nil {
+			return err
+		}
+		return expectField(rows[0], "name", "Ada Lovelace")
+	}},
+	{api: "rest", name: "select() with an embedded resource", needs: "insert().select()", run: func(ctx context.Context, r *runner) error {
+		err := r.rest(ctx, http.MethodPost, "/"+booksTable, "", nil,
+			map[string]any{"id": 1, "title": "Notes on the Analytical Engine", "author_id": 1}, http.StatusCreated, nil)
+		if err != nil {
+			return fmt.Errorf("insert failed: %w", err)
+		}
+		var rows []map[string]any
+		if err := r.rest(ctx, http.MethodGet, "/"+booksTable+"?select=title,"+authorsTable+"(name)", "", nil, nil, http.StatusOK, &rows); err != nil {
+			return err
+		}
+		if err := expectLen(rows, 1); err != nil {
+			return err
+		}
+		author, ok := rows[0][authorsTable].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s = %v, want the embedded author", authorsTable, rows[0][authorsTable])
+		}
+		return expectField(author, "name", "Ada Lovelace")
+	}},
+	{api: "rest", name: "delete().eq()", needs: "select() with an embedded resource", run: func(ctx context.Context, r *runner) error {
+		if err := r.rest(ctx, http.MethodDelete, "/"+booksTable+"?id=eq.1", "", nil, nil, http.StatusNoContent, nil); err != nil {
+			return err
+		}
+		var rows []map[string]any
+		if err := r.rest(ctx, http.MethodGet, "/"+booksTable+"?select=id", "", nil, nil, http.StatusOK, &rows); err != nil {
+			return err
+		}
+		return expectLen(rows, 0)
+	}},
+	{api: "rest", name: "rpc()", run: func(ctx context.Context, r *runner) error {
+		var sum float64
+		if err := r.rest(ctx, http.MethodPost, "/rpc/"+addFunction, "", nil, map[string]any{"a": 2, "b": 3}, http.StatusOK, &sum); err != nil {
+			return err
+		}
+		if sum != 5 {
+			return fmt.Errorf("returned %v, want 5", sum)
+		}
+		return nil
+	}},
+	{api: "auth", name: "auth.signUp()", run: func(ctx context.Context, r *runner) error {
+		r.email = "verify-" + r.suffix + "@example.com"
+		r.password = "verify-" + r.suffix
+		var body struct {
+			ID   string `json:"id"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		if err := r.call(ctx, http.MethodPost, "/auth/v1/signup", r.config.AnonKey, "", nil,
+			map[string]any{"email": r.email, "password": r.password}, http.StatusOK, &body); err != nil {
+			return err
+		}
+		// The user is returned alone while its email awaits confirmation,
+		// and with a session otherwise
+		r.userID = body.User.ID
+		if r.userID == "" {
+			r.userID = body.ID
+		}
+		if r.userID == "" {
+			return errors.New("response has no user")
+		}
+		return nil
+	}},
+	{api: "auth", name: "auth.signInWithPassword()", needs: "auth.signUp()", run: func(ctx context.Context, r *runner) error {
+		// Confirm the email first, in case confirmation is required
+		if err := r.call(ctx, http.MethodPut, "/auth/v1/admin/users/"+r.userID, r.config.ServiceKey, "", nil,
+			map[string]any{"email_confirm": true}, http.StatusOK, nil); err != nil {
+			return fmt.Errorf("confirming the user failed: %w", err)
+		}
+		var session struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := r.call(ctx, http.MethodPost, "/auth/v1/token?grant_type=password", r.config.AnonKey, "", nil,
+			map[string]any{"email": r.email, "password": r.password}, http.StatusOK, &session); err != nil {
+			return err
+		}
+		if session.AccessToken == "" {
+			return errors.New("response has no access_token")
+		}
+		r.userToken = session.AccessToken
+		return nil
+	}},
+	{api: "auth", name: "auth.getUser()", needs: "auth.signInWithPassword()", run: func(ctx context.Context, r *runner) error {
+		var user map[string]any
+		if err := r.call(ctx, http.MethodGet, "/auth/v1/user", r.config.AnonKey, r.userToken, nil, nil, http.StatusOK, &user); err != nil {
+			return err
+		}
+		return expectField(user, "email", r.email)
+	}},
+	{api: "rest", name: "row level security as the signed-in user", needs: "auth.signInWithPassword()", run: func(ctx context.Context, r *runner) error {
+		if err := r.rest(ctx, http.MethodPost, "/"+notesTable, r.userToken, nil, map[string]any{"body": "mine"}, http.StatusCreated, nil); err != nil {
+			return fmt.Errorf("insert as the user failed: %w", err)
+		}
+		var rows []map[string]any
+		if err := r.rest(ctx, http.MethodGet, "/"+notesTable+"?select=body,owner", r.userToken, nil, nil, http.StatusOK, &rows); err != nil {
+			return err
+		}
+		if err := expectLen(rows, 1); err != nil {
+			return fmt.Errorf("as the user: %w", err)
+		}
+		if err := expectField(rows[0], "owner", r.userID); err != nil {
+			return err
+		}
+		if err := r.rest(ctx, http.MethodGet, "/"+notesTable+"?select=body", "", nil, nil, http.StatusOK, &rows); err != nil {
+			return err
+		}
+		if err := expectLen(rows, 0); err != nil {
+			return fmt.Errorf("with the anon key: %w", err)
+		}
+		return nil
+	}},
+	{api: "storage", name: "storage.createBucket()", run: func(ctx context.Context, r *runner) error {
+		bucket := "supalite-verify-" + r.suffix
+		if err := r.call(ctx, http.MethodPost, "/storage/v1/bucket", r.config.ServiceKey, "", nil,
+			map[string]any{"id": bucket, "name": bucket, "public": false}, http.StatusOK, nil); err != nil {
+			return err
+		}
+		r.bucket = bucket
+		return nil
+	}},
+	{api: "storage", name: "storage.from().upload()", needs: "storage.createBucket()", run: func(ctx context.Context, r *runner) error {
+		resp, err := r.do(ctx, http.MethodPost, "/storage/v1/object/"+r.bucket+"/hello.txt", r.config.ServiceKey,
+			map[string]string{"Content-Type": "text/plain"}, []byte(uploadContent))
+		if err != nil {
+			return err
+		}
+		return expectStatus(resp, http.StatusOK)
+	}},
+	{api: "storage", name: "storage.from().download()", needs: "storage.from().upload()", run: func(ctx context.Context, r *runner) error {
+		resp, err := r.do(ctx, http.MethodGet, "/storage/v1/object/"+r.bucket+"/hello.txt", r.config.ServiceKey, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := expectStatus(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if string(resp.body) != uploadContent {
+			return fmt.Errorf("downloaded %q, want %q", resp.body, uploadContent)
+		}
+		return nil
+	}},
+}
+
+const uploadContent = "hello from supalite verify\n"
+
+// cleanup deletes the bucket and user the checks created.
+func (r *runner) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if r.bucket != "" {
+		r.call(ctx, http.MethodDelete, "/storage/v1/object/"+r.bucket, r.config.ServiceKey, "", nil,
+			map[string]any{"prefixes": []string{"hello.txt"}}, http.StatusOK, nil)
+		r.call(ctx, http.MethodDelete, "/storage/v1/bucket/"+r.bucket, r.config.ServiceKey, "", nil, nil, http.StatusOK, nil)
+	}
+	if r.userID != "" {
+		r.call(ctx, http.MethodDelete, "/auth/v1/admin/users/"+r.userID, r.config.ServiceKey, "", nil, nil, http.StatusOK, nil)
+	}
+}
+
+// rest calls the REST API with the anon key, and token as the bearer
+// token when given.
+func (r *runner) rest(ctx context.Context, method, path, token string, headers map[string]string, body any, want int, out any) error {
+	return r.call(ctx, method, "/rest/v1"+path, r.config.AnonKey, token, headers, body, want, out)
+}
+
+// call makes a request with a JSON body, checks its status, and decodes
+// the response into out, if given.
+func (r *runner) call(ctx context.Context, method, path, key, token string, headers map[string]string, body any, want int, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+		headers = withHeader(headers, "Content-Type", "application/json")
+	}
+	if token != "" {
+		headers = withHeader(headers, "Authorization", "Bearer "+token)
+	}
+	resp, err := r.do(ctx, method, path, key, headers, data)
+	if err != nil {
+		return err
+	}
+	if err := expectStatus(resp, want); err != nil {
+		return err
+	}
+	if out != nil {
+		if err := json.Unmarshal(resp.body, out); err != nil {
+			return fmt.Errorf("invalid response body: %w", err)
+		}
+	}
+	return nil
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do makes a request the way supabase-js does: the key in the apikey
+// header, and as the bearer token unless one is given.
+func (r *runner) do(ctx context.Context, method, path, key string, headers map[string]string, body []byte) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.config.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("apikey", key)
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("X-Client-Info", "supalite-verify")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+func withHeader(headers map[string]string, name, value string) map[string]string {
+	merged := map[string]string{name: value}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return merged
+}
+
+// expectStatus fails, with the response body that carries the server's
+// error message, when the status isn't the one Supabase answers with.
+func expectStatus(resp *response, want int) error {
+	if resp.status == want {
+		return nil
+	}
+	msg := strings.TrimSpace(string(resp.body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return fmt.Errorf("status %d, want %d: %s", resp.status, want, msg)
+}
+
+func expectLen(rows []map[string]any, want int) error {
+	if len(rows) != want {
+		return fmt.Errorf("returned %d rows, want %d", len(rows), want)
+	}
+	return nil
+}
+
+func expectField(row map[string]any, name string, want any) error {
+	if got := row[name]; got != want {
+		return fmt.Errorf("%s = %v, want %v", name, got, want)
+	}
+	return nil
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,47 @@
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRun_SkipsDependents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	report := Run(context.Background(), Config{URL: srv.URL + "/", AnonKey: "anon", ServiceKey: "service"})
+
+	if report.OK() {
+		t.Fatal("OK() = true against a failing server")
+	}
+	if len(report.Results) != len(checks) {
+		t.Fatalf("got %d results, want %d", len(report.Results), len(checks))
+	}
+	if report.Passed+report.Failed+report.Skipped != len(checks) || report.Passed != 0 {
+		t.Errorf("passed %d, failed %d, skipped %d", report.Passed, report.Failed, report.Skipped)
+	}
+	for i, c := range checks {
+		result := report.Results[i]
+		want := StatusFail
+		if c.needs != "" {
+			want = StatusSkip
+		}
+		if result.Status != want {
+			t.Errorf("%s: status %s, want %s (%s)", c.name, result.Status, want, result.Error)
+		}
+	}
+}
+
+func TestExpectStatus(t *testing.T) {
+	if err := expectStatus(&response{status: 201}, 201); err != nil {
+		t.Errorf("expectStatus(201, 201) = %v", err)
+	}
+	err := expectStatus(&response{status: 400, body: []byte(`{"message":"bad filter"}` + "\n")}, 200)
+	if err == nil || err.Error() != `status 400, want 200: {"message":"bad filter"}` {
+		t.Errorf("expectStatus(400, 200) = %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package watchdog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// WriteMetrics writes the latest sample in the Prometheus text exposition
+// format.
+func (wd *Watchdog) WriteMetrics(w io.Writer) {
+	wd.mu.RLock()
+	stats := wd.stats
+	exhausted := wd.diskExhausted && wd.config.RefuseWrites
+	refused := wd.refused
+	wd.mu.RUnlock()
+
+	gauge := func(name, help string, value interface{}) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+
+	gauge("supalite_disk_total_bytes", "Size of the filesystem holding the data directory.", stats.DiskTotal)
+	gauge("supalite_disk_free_bytes", "Bytes available on the filesystem holding the data directory.", stats.DiskFree)
+	gauge("supalite_disk_free_ratio", "Share of the filesystem holding the data directory that is free.", stats.FreeRatio())
+	gauge("supalite_disk_min_free_bytes", "Free disk below which writes are refused or flagged.", int64(wd.config.MinFreeMB)<<20)
+	gauge("supalite_wal_bytes", "Size of the Postgres pg_wal directory.", stats.WALBytes)
+	gauge("supalite_writes_refused", "1 while writes are refused for lack of disk, else 0.", boolGauge(exhausted))
+
+	fmt.Fprintf(w, "# HELP supalite_writes_refused_total Writes refused for lack of disk since startup.\n# TYPE supalite_writes_refused_total counter\nsupalite_writes_refused_total %d\n", refused)
+
+	names := make([]string, 0, len(stats.ProcessMemory))
+	for name := range stats.ProcessMemory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "# HELP supalite_process_memory_bytes Proportional memory of a child process and its children.\n# TYPE supalite_process_memory_bytes gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "supalite_process_memory_bytes{process=%q} %d\n", name, stats.ProcessMemory[name])
+	}
+
+	fmt.Fprintf(w, "# HELP supalite_process_memory_limit_bytes Memory limit of a child process.\n# TYPE supalite_process_memory_limit_bytes gauge\n")
+	for _, p := range wd.config.Processes {
+		if p.LimitBytes > 0 {
+			fmt.Fprintf(w, "supalite_process_memory_limit_bytes{process=%q} %d\n", p.Name, p.LimitBytes)
+		}
+	}
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func (wd *Watchdog) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		wd.WriteMetrics(w)
+	})
+}
+
+func boolGauge(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
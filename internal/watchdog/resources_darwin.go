@@ -0,0 +1,24 @@
+//go:build darwin
+
+package watchdog
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskUsage returns the size of the filesystem holding path and the bytes
+// available on it to unprivileged users.
+func diskUsage(path string) (total, free uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
+
+// treeMemory is not implemented on macOS, which has no /proc.
+func treeMemory(pid int) (int64, error) {
+	return 0, errors.New("process memory is only sampled on Linux")
+}
@@ -0,0 +1,119 @@
+//go:build linux
+
+package watchdog
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// diskUsage returns the size of the filesystem holding path and the bytes
+// available on it to unprivileged users.
+func diskUsage(path string) (total, free uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
+
+// treeMemory returns the proportional set size (PSS) of pid and all its
+// descendants. PSS splits shared pages between the processes sharing
+// them, so Postgres' shared buffers are counted once rather than once per
+// backend.
+func treeMemory(pid int) (int64, error) {
+	var total int64
+	for _, p := range descendants(pid) {
+		bytes, err := processMemory(p)
+		if err != nil {
+			if p == pid {
+				return 0, err
+			}
+			continue // a backend that exited meanwhile
+		}
+		total += bytes
+	}
+	return total, nil
+}
+
+// descendants returns pid followed by all processes below it.
+func descendants(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return []int{pid}
+	}
+
+	children := map[int][]int{}
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if parent, ok := parentPID(child); ok {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
+
+// parentPID reads a process's parent from /proc/<pid>/stat. The command
+// name in parentheses may contain spaces, so fields are counted after it.
+func parentPID(pid int) (int, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	parent, err := strconv.Atoi(fields[1])
+	return parent, err == nil
+}
+
+// processMemory returns a process's PSS from smaps_rollup, falling back
+// to its resident set size on kernels without it.
+func processMemory(pid int) (int64, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	if f, err := os.Open(filepath.Join(dir, "smaps_rollup")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rest, ok := strings.CutPrefix(scanner.Text(), "Pss:"); ok {
+				return parseKB(rest)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "VmRSS:"); ok {
+			return parseKB(rest)
+		}
+	}
+	return 0, nil
+}
+
+// parseKB parses a "  1234 kB" value from /proc into bytes.
+func parseKB(s string) (int64, error) {
+	kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "kB")), 10, 64)
+	return kb << 10, err
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package watchdog
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	total, free, err := diskUsage(t.TempDir())
+	if err != nil {
+		t.Fatalf("diskUsage() failed: %v", err)
+	}
+	if total == 0 || free > total {
+		t.Errorf("diskUsage() = %d total, %d free", total, free)
+	}
+}
+
+func TestTreeMemory(t *testing.T) {
+	bytes, err := treeMemory(os.Getpid())
+	if err != nil {
+		t.Fatalf("treeMemory() failed: %v", err)
+	}
+	if bytes <= 0 {
+		t.Errorf("treeMemory() = %d, want the test binary's memory", bytes)
+	}
+}
+
+func TestParentPID(t *testing.T) {
+	parent, ok := parentPID(os.Getpid())
+	if !ok || parent != os.Getppid() {
+		t.Errorf("parentPID() = %d, %v, want %d", parent, ok, os.Getppid())
+	}
+}
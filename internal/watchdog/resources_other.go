@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package watchdog
+
+import "errors"
+
+// diskUsage is only implemented on Linux and macOS.
+func diskUsage(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk usage is only sampled on Linux and macOS")
+}
+
+// treeMemory is only implemented on Linux.
+func treeMemory(pid int) (int64, error) {
+	return 0, errors.New("process memory is only sampled on Linux")
+}
@@ -0,0 +1,300 @@
+// Package watchdog keeps an eye on the resources supalite runs out of in
+// practice: free space on the data directory's disk, WAL piling up in
+// pg_wal, and the memory of the Postgres and GoTrue processes.
+//
+// It samples them periodically, logs a warning when a threshold is
+// crossed, and exposes the samples as Prometheus gauges. It can also
+// refuse writes with 507 Insufficient Storage once free disk drops below
+// a floor, so clients get a clear error instead of Postgres crashing
+// with a full disk (PANIC: could not write to file "pg_wal/...").
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/markb/supalite/internal/log"
+)
+
+const (
+	DefaultInterval        = 15 * time.Second
+	DefaultWarnFreePercent = 10
+	DefaultMinFreeMB       = 256
+	DefaultWALWarnMB       = 1024
+)
+
+// memoryWarnRatio is the share of a process's memory limit past which a
+// warning is logged.
+const memoryWarnRatio = 0.9
+
+// Process is a child process whose memory is sampled, together with the
+// processes it forks (such as Postgres backends).
+type Process struct {
+	Name       string
+	PID        func() int // Returns 0 while the process isn't running
+	LimitBytes int64      // Optional: memory limit, warned about at 90%
+}
+
+// Config holds the configuration for the watchdog.
+type Config struct {
+	DataDir         string        // Directory whose filesystem is watched
+	WALDir          string        // Optional: Postgres pg_wal directory
+	Processes       []Process     // Optional: processes whose memory is sampled
+	Interval        time.Duration // Optional: how often to sample (default: 15s)
+	WarnFreePercent float64       // Optional: warn below this share of free disk (default: 10)
+	MinFreeMB       int           // Optional: free disk below which writes are refused (default: 256)
+	RefuseWrites    bool          // Answer writes with 507 while free disk is below MinFreeMB
+	WALWarnMB       int           // Optional: warn when pg_wal grows past this (default: 1024)
+
+	// OnAlert is called on each threshold crossing, from the sampling
+	// goroutine with the watchdog locked, so it must not block. Optional.
+	OnAlert func(Alert)
+}
+
+// Alert kinds
+const (
+	AlertDiskLow       = "disk_low"       // Free disk fell below WarnFreePercent
+	AlertDiskExhausted = "disk_exhausted" // Free disk fell below MinFreeMB
+	AlertDiskRecovered = "disk_recovered" // Free disk is back above WarnFreePercent
+	AlertWALHigh       = "wal_high"
+	AlertMemoryHigh    = "memory_high"
+)
+
+// Alert reports a threshold crossing.
+type Alert struct {
+	Kind    string
+	Process string // Process whose memory is high, for AlertMemoryHigh
+	Message string
+	Stats   Stats
+}
+
+// Stats is one sample of the watched resources.
+type Stats struct {
+	SampledAt     time.Time
+	DiskTotal     uint64           // Size of the data directory's filesystem, in bytes
+	DiskFree      uint64           // Bytes available to supalite on it
+	WALBytes      int64            // Size of pg_wal
+	ProcessMemory map[string]int64 // Proportional memory of each process tree, in bytes
+}
+
+// FreeRatio returns the share of the disk that is free, or 1 if unknown.
+func (st Stats) FreeRatio() float64 {
+	if st.DiskTotal == 0 {
+		return 1
+	}
+	return float64(st.DiskFree) / float64(st.DiskTotal)
+}
+
+// Watchdog samples resources in the background.
+type Watchdog struct {
+	config Config
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu            sync.RWMutex
+	stats         Stats
+	diskLow       bool            // free disk is below the warning threshold
+	diskExhausted bool            // free disk is below MinFreeMB
+	walHigh       bool            // pg_wal is past WALWarnMB
+	memoryHigh    map[string]bool // process trees near their memory limit
+	refused       int64           // writes refused since startup
+}
+
+// New creates a new watchdog.
+func New(cfg Config) *Watchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.WarnFreePercent <= 0 {
+		cfg.WarnFreePercent = DefaultWarnFreePercent
+	}
+	if cfg.MinFreeMB <= 0 {
+		cfg.MinFreeMB = DefaultMinFreeMB
+	}
+	if cfg.WALWarnMB <= 0 {
+		cfg.WALWarnMB = DefaultWALWarnMB
+	}
+	return &Watchdog{config: cfg, memoryHigh: map[string]bool{}}
+}
+
+// Start takes a first sample and keeps sampling until Stop.
+func (wd *Watchdog) Start() {
+	wd.Sample()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wd.cancel = cancel
+
+	wd.wg.Add(1)
+	go func() {
+		defer wd.wg.Done()
+		ticker := time.NewTicker(wd.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				wd.Sample()
+			}
+		}
+	}()
+}
+
+// Stop stops sampling.
+func (wd *Watchdog) Stop() {
+	if wd.cancel != nil {
+		wd.cancel()
+	}
+	wd.wg.Wait()
+}
+
+// Stats returns the latest sample.
+func (wd *Watchdog) Stats() Stats {
+	wd.mu.RLock()
+	defer wd.mu.RUnlock()
+	return wd.stats
+}
+
+// DiskExhausted reports whether free disk is below MinFreeMB.
+func (wd *Watchdog) DiskExhausted() bool {
+	wd.mu.RLock()
+	defer wd.mu.RUnlock()
+	return wd.diskExhausted
+}
+
+// Sample measures the resources now, logging any threshold crossed since
+// the previous sample.
+func (wd *Watchdog) Sample() {
+	stats := Stats{SampledAt: time.Now(), ProcessMemory: map[string]int64{}}
+
+	if total, free, err := diskUsage(wd.config.DataDir); err == nil {
+		stats.DiskTotal, stats.DiskFree = total, free
+	} else {
+		log.Debug("watchdog: disk usage unavailable", "path", wd.config.DataDir, "error", err)
+	}
+	if wd.config.WALDir != "" {
+		stats.WALBytes = dirSize(wd.config.WALDir)
+	}
+	for _, p := range wd.config.Processes {
+		pid := p.PID()
+		if pid <= 0 {
+			continue
+		}
+		if bytes, err := treeMemory(pid); err == nil {
+			stats.ProcessMemory[p.Name] = bytes
+		}
+	}
+
+	wd.update(stats)
+}
+
+// update stores a sample and logs threshold crossings.
+func (wd *Watchdog) update(stats Stats) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	wd.stats = stats
+
+	if stats.DiskTotal > 0 {
+		freeMB := stats.DiskFree >> 20
+		percent := stats.FreeRatio() * 100
+
+		low := percent < wd.config.WarnFreePercent
+		if low && !wd.diskLow {
+			log.Warn("disk space is low", "path", wd.config.DataDir, "free_mb", freeMB, "free_percent", fmt.Sprintf("%.1f", percent))
+			wd.alert(Alert{Kind: AlertDiskLow, Message: fmt.Sprintf("disk space is low: %d MB (%.1f%%) free on %s", freeMB, percent, wd.config.DataDir)})
+		} else if !low && wd.diskLow {
+			log.Info("disk space recovered", "path", wd.config.DataDir, "free_mb", freeMB, "free_percent", fmt.Sprintf("%.1f", percent))
+			wd.alert(Alert{Kind: AlertDiskRecovered, Message: fmt.Sprintf("disk space recovered: %d MB (%.1f%%) free on %s", freeMB, percent, wd.config.DataDir)})
+		}
+		wd.diskLow = low
+
+		exhausted := freeMB < uint64(wd.config.MinFreeMB)
+		if exhausted && !wd.diskExhausted {
+			if wd.config.RefuseWrites {
+				log.Error("disk nearly full, refusing writes", "path", wd.config.DataDir, "free_mb", freeMB, "min_free_mb", wd.config.MinFreeMB)
+			} else {
+				log.Error("disk nearly full, Postgres will fail when it runs out", "path", wd.config.DataDir, "free_mb", freeMB, "min_free_mb", wd.config.MinFreeMB)
+			}
+			wd.alert(Alert{Kind: AlertDiskExhausted, Message: fmt.Sprintf("disk nearly full: %d MB free on %s (minimum %d MB)", freeMB, wd.config.DataDir, wd.config.MinFreeMB)})
+		} else if !exhausted && wd.diskExhausted && wd.config.RefuseWrites {
+			log.Info("disk space recovered, accepting writes", "path", wd.config.DataDir, "free_mb", freeMB)
+		}
+		wd.diskExhausted = exhausted
+	}
+
+	walHigh := stats.WALBytes > int64(wd.config.WALWarnMB)<<20
+	if walHigh && !wd.walHigh {
+		log.Warn("pg_wal is growing, check for a stuck replication slot or archive command", "wal_mb", stats.WALBytes>>20, "wal_warn_mb", wd.config.WALWarnMB)
+		wd.alert(Alert{Kind: AlertWALHigh, Message: fmt.Sprintf("pg_wal has grown to %d MB", stats.WALBytes>>20)})
+	}
+	wd.walHigh = walHigh
+
+	for _, p := range wd.config.Processes {
+		if p.LimitBytes <= 0 {
+			continue
+		}
+		bytes := stats.ProcessMemory[p.Name]
+		high := float64(bytes) > float64(p.LimitBytes)*memoryWarnRatio
+		if high && !wd.memoryHigh[p.Name] {
+			log.Warn("process is close to its memory limit", "process", p.Name, "memory_mb", bytes>>20, "limit_mb", p.LimitBytes>>20)
+			wd.alert(Alert{Kind: AlertMemoryHigh, Process: p.Name, Message: fmt.Sprintf("%s is close to its memory limit: %d of %d MB", p.Name, bytes>>20, p.LimitBytes>>20)})
+		}
+		wd.memoryHigh[p.Name] = high
+	}
+}
+
+// alert passes a threshold crossing to OnAlert.
+func (wd *Watchdog) alert(a Alert) {
+	if wd.config.OnAlert != nil {
+		a.Stats = wd.stats
+		wd.config.OnAlert(a)
+	}
+}
+
+// RefuseWrites answers requests that change data with 507 Insufficient
+// Storage while free disk is below MinFreeMB and RefuseWrites is set.
+// Reads pass through, so clients and the dashboard keep working.
+func (wd *Watchdog) RefuseWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wd.config.RefuseWrites || isReadOnlyMethod(r.Method) || !wd.DiskExhausted() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		wd.mu.Lock()
+		wd.refused++
+		wd.mu.Unlock()
+
+		w.Header().Set("Retry-After", fmt.Sprint(int(wd.config.Interval.Seconds())))
+		http.Error(w, fmt.Sprintf("insufficient storage: less than %d MB of disk is free, writes are refused until space is freed", wd.config.MinFreeMB), http.StatusInsufficientStorage)
+	})
+}
+
+// isReadOnlyMethod reports whether an HTTP method only reads.
+func isReadOnlyMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
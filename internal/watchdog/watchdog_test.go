@@ -0,0 +1,100 @@
+package watchdog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRefuseWrites(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		refuseWrites bool
+		freeMB       uint64
+		method       string
+		want         int
+	}{
+		{"plenty of disk", true, 10240, http.MethodPost, http.StatusOK},
+		{"write on a full disk", true, 100, http.MethodPost, http.StatusInsufficientStorage},
+		{"delete on a full disk", true, 100, http.MethodDelete, http.StatusInsufficientStorage},
+		{"read on a full disk", true, 100, http.MethodGet, http.StatusOK},
+		{"refusing disabled", false, 100, http.MethodPost, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wd := New(Config{RefuseWrites: tt.refuseWrites, MinFreeMB: 256})
+			wd.update(Stats{DiskTotal: 100 << 30, DiskFree: tt.freeMB << 20})
+
+			rec := httptest.NewRecorder()
+			wd.RefuseWrites(ok).ServeHTTP(rec, httptest.NewRequest(tt.method, "/rest/v1/todos", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnAlert(t *testing.T) {
+	var kinds []string
+	wd := New(Config{MinFreeMB: 256, OnAlert: func(a Alert) { kinds = append(kinds, a.Kind) }})
+
+	wd.update(Stats{DiskTotal: 100 << 30, DiskFree: 50 << 30})
+	wd.update(Stats{DiskTotal: 100 << 30, DiskFree: 5 << 30})
+	wd.update(Stats{DiskTotal: 100 << 30, DiskFree: 4 << 30}) // still low: no new alert
+	wd.update(Stats{DiskTotal: 100 << 30, DiskFree: 100 << 20})
+	wd.update(Stats{DiskTotal: 100 << 30, DiskFree: 50 << 30})
+
+	want := []string{AlertDiskLow, AlertDiskExhausted, AlertDiskRecovered}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("alerts = %v, want %v", kinds, want)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	wd := New(Config{
+		RefuseWrites: true,
+		Processes:    []Process{{Name: "postgres", PID: func() int { return 0 }, LimitBytes: 512 << 20}},
+	})
+	wd.update(Stats{DiskTotal: 1000 << 20, DiskFree: 100 << 20, WALBytes: 64 << 20, ProcessMemory: map[string]int64{"postgres": 200 << 20}})
+	wd.RefuseWrites(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	rec := httptest.NewRecorder()
+	wd.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE supalite_disk_free_bytes gauge",
+		"supalite_disk_free_bytes 104857600\n",
+		"supalite_disk_free_ratio 0.1\n",
+		"supalite_wal_bytes 67108864\n",
+		"supalite_writes_refused 1\n",
+		"supalite_writes_refused_total 1\n",
+		`supalite_process_memory_bytes{process="postgres"} 209715200`,
+		`supalite_process_memory_limit_bytes{process="postgres"} 536870912`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "pg_wal")
+	os.Mkdir(walDir, 0755)
+	os.WriteFile(filepath.Join(walDir, "000000010000000000000001"), make([]byte, 4096), 0600)
+
+	wd := New(Config{DataDir: dir, WALDir: walDir})
+	wd.Sample()
+
+	if got := wd.Stats().WALBytes; got != 4096 {
+		t.Errorf("WALBytes = %d, want 4096", got)
+	}
+}
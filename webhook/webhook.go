@@ -0,0 +1,131 @@
+// Package webhook signs and verifies the webhooks supalite delivers.
+//
+// Signatures follow the Standard Webhooks scheme (https://www.standardwebhooks.com),
+// which GoTrue's HTTP hooks use as well. Each request carries three headers:
+//
+//	webhook-id:        a unique message ID
+//	webhook-timestamp: Unix seconds when the message was sent
+//	webhook-signature: "v1," + base64(HMAC-SHA256(key, id + "." + timestamp + "." + body))
+//
+// The key is the base64 part of a "whsec_..." secret. The signature header
+// may list several space-separated signatures, e.g. while a secret is being
+// rotated; one match is enough.
+//
+// A receiver verifies a request with:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	if err := webhook.Verify(secret, r.Header, body); err != nil {
+//		http.Error(w, err.Error(), http.StatusUnauthorized)
+//		return
+//	}
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header names
+const (
+	HeaderID        = "webhook-id"
+	HeaderTimestamp = "webhook-timestamp"
+	HeaderSignature = "webhook-signature"
+)
+
+// secretPrefix marks a webhook secret.
+const secretPrefix = "whsec_"
+
+// DefaultTolerance is how far a message's timestamp may be from the
+// receiver's clock, to stop replays of old messages.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrMissingHeaders is returned when a signature header is absent.
+	ErrMissingHeaders = errors.New("missing webhook-id, webhook-timestamp, or webhook-signature header")
+
+	// ErrInvalidTimestamp is returned for a timestamp that isn't Unix seconds.
+	ErrInvalidTimestamp = errors.New("invalid webhook-timestamp header")
+
+	// ErrTimestampOutOfRange is returned for a message older or newer than the tolerance.
+	ErrTimestampOutOfRange = errors.New("webhook timestamp is too old or too new")
+
+	// ErrInvalidSignature is returned when no signature matches.
+	ErrInvalidSignature = errors.New("no matching webhook signature")
+)
+
+// NewSecret returns a random "whsec_..." secret.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// decodeSecret returns the HMAC key of a secret. A secret without the
+// whsec_ prefix, or whose rest isn't base64, is used as is.
+func decodeSecret(secret string) []byte {
+	if encoded, ok := strings.CutPrefix(secret, secretPrefix); ok {
+		if key, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			return key
+		}
+	}
+	return []byte(secret)
+}
+
+// Sign returns the webhook-signature header value for a message.
+func Sign(secret, id string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, decodeSecret(secret))
+	fmt.Fprintf(mac, "%s.%d.", id, timestamp.Unix())
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders signs body and sets the three signature headers on header.
+func SetHeaders(header http.Header, secret, id string, timestamp time.Time, body []byte) {
+	header.Set(HeaderID, id)
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HeaderSignature, Sign(secret, id, timestamp, body))
+}
+
+// Verify checks the signature headers of a delivered message against
+// secret, allowing DefaultTolerance of clock difference.
+func Verify(secret string, header http.Header, body []byte) error {
+	return VerifyAt(secret, header, body, time.Now(), DefaultTolerance)
+}
+
+// VerifyAt is Verify with an explicit clock and tolerance. A tolerance of
+// zero or less skips the timestamp check.
+func VerifyAt(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	id := header.Get(HeaderID)
+	timestamp := header.Get(HeaderTimestamp)
+	signatures := header.Get(HeaderSignature)
+	if id == "" || timestamp == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	sent := time.Unix(seconds, 0)
+	if tolerance > 0 && (now.Sub(sent) > tolerance || sent.Sub(now) > tolerance) {
+		return ErrTimestampOutOfRange
+	}
+
+	expected := Sign(secret, id, sent, body)
+	for _, signature := range strings.Fields(signatures) {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
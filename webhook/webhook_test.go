@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignKnownVector(t *testing.T) {
+	// Test vector from the Standard Webhooks specification
+	secret := "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	sent := time.Unix(1614265330, 0)
+	body := []byte(`{"test": 2432232314}`)
+
+	got := Sign(secret, "msg_p5jXN8AQM9LWM0D4loKWxJek", sent, body)
+	if want := "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="; got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, "whsec_") {
+		t.Errorf("NewSecret() = %q, want a whsec_ secret", secret)
+	}
+
+	now := time.Now()
+	body := []byte(`{"to":"a@example.com"}`)
+	signed := func(mutate func(http.Header)) http.Header {
+		header := http.Header{}
+		SetHeaders(header, secret, "msg_1", now, body)
+		if mutate != nil {
+			mutate(header)
+		}
+		return header
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  http.Header
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", secret, signed(nil), body, now, nil},
+		{"rotated secrets", secret, signed(func(h http.Header) { h.Set(HeaderSignature, "v1,b2xk "+h.Get(HeaderSignature)) }), body, now, nil},
+		{"wrong secret", "whsec_b3RoZXI=", signed(nil), body, now, ErrInvalidSignature},
+		{"tampered body", secret, signed(nil), []byte(`{"to":"b@example.com"}`), now, ErrInvalidSignature},
+		{"tampered id", secret, signed(func(h http.Header) { h.Set(HeaderID, "msg_2") }), body, now, ErrInvalidSignature},
+		{"missing header", secret, signed(func(h http.Header) { h.Del(HeaderSignature) }), body, now, ErrMissingHeaders},
+		{"bad timestamp", secret, signed(func(h http.Header) { h.Set(HeaderTimestamp, "yesterday") }), body, now, ErrInvalidTimestamp},
+		{"replayed", secret, signed(nil), body, now.Add(10 * time.Minute), ErrTimestampOutOfRange},
+		{"from the future", secret, signed(nil), body, now.Add(-10 * time.Minute), ErrTimestampOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAt(tt.secret, tt.header, tt.body, tt.now, DefaultTolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAt() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}